@@ -0,0 +1,88 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// clock.go
+package clock
+
+import (
+	"time"
+)
+
+// Clock is the source of time for all protocol timers, the daemons use
+// the real clock while tests and the simulator may use a ManualClock in
+// order to step the timers deterministically
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) *Timer
+	AfterFunc(d time.Duration, f func()) *Timer
+	After(d time.Duration) <-chan time.Time
+	Sleep(d time.Duration)
+}
+
+// Timer mirrors time.Timer so existing select statements on C and calls
+// to Stop/Reset do not change, C is nil for timers created by AfterFunc
+type Timer struct {
+	C <-chan time.Time
+	t timer
+}
+
+type timer interface {
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+func (t *Timer) Stop() bool {
+	return t.t.Stop()
+}
+
+func (t *Timer) Reset(d time.Duration) bool {
+	return t.t.Reset(d)
+}
+
+type realClock struct{}
+
+// NewRealClock returns a clock backed by the time package
+func NewRealClock() Clock {
+	return realClock{}
+}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) *Timer {
+	t := time.NewTimer(d)
+	return &Timer{C: t.C, t: t}
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) *Timer {
+	return &Timer{t: time.AfterFunc(d, f)}
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (realClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
@@ -0,0 +1,185 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// manual.go
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// ManualClock only moves when Advance is called.  Expired timers are
+// fired in deadline order, channel timers are sent to without blocking
+// and AfterFunc timers are run by Advance so that their work is done by
+// the time Advance returns.  The receivers of a channel timer react
+// asynchronously so a channel timer which is restarted on expiry (i.e. a
+// periodic tx timer) is only seen again by a later Advance, callers should
+// advance in steps no larger than the smallest period of interest
+type ManualClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*manualTimer
+}
+
+type manualTimer struct {
+	c      *ManualClock
+	when   time.Time
+	ch     chan time.Time
+	f      func()
+	active bool
+}
+
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{
+		now: start,
+	}
+}
+
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *ManualClock) NewTimer(d time.Duration) *Timer {
+	ch := make(chan time.Time, 1)
+	t := &manualTimer{
+		c:  c,
+		ch: ch,
+	}
+	t.Reset(d)
+	return &Timer{C: ch, t: t}
+}
+
+func (c *ManualClock) AfterFunc(d time.Duration, f func()) *Timer {
+	t := &manualTimer{
+		c: c,
+		f: f,
+	}
+	t.Reset(d)
+	return &Timer{t: t}
+}
+
+func (c *ManualClock) After(d time.Duration) <-chan time.Time {
+	return c.NewTimer(d).C
+}
+
+// Sleep blocks until another go routine advances the clock by d
+func (c *ManualClock) Sleep(d time.Duration) {
+	<-c.After(d)
+}
+
+// Advance moves the clock forward by d firing all timers which expire
+// on the way, Now returns the expiry time of each timer as it fires.
+// AfterFunc functions are called without the clock locked so they may
+// restart their timer, each returns before the next timer is fired
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	end := c.now.Add(d)
+	for {
+		var next *manualTimer
+		for _, t := range c.timers {
+			if !t.when.After(end) &&
+				(next == nil || t.when.Before(next.when)) {
+				next = t
+			}
+		}
+		if next == nil {
+			break
+		}
+		if next.when.After(c.now) {
+			c.now = next.when
+		}
+		if f := next.fireLocked(); f != nil {
+			c.mu.Unlock()
+			f()
+			c.mu.Lock()
+		}
+	}
+	if end.After(c.now) {
+		c.now = end
+	}
+}
+
+// Pending returns the number of timers which have not yet fired
+func (c *ManualClock) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+func (t *manualTimer) removeLocked() {
+	for i, tmp := range t.c.timers {
+		if tmp == t {
+			t.c.timers = append(t.c.timers[:i], t.c.timers[i+1:]...)
+			break
+		}
+	}
+	t.active = false
+}
+
+// fireLocked returns the function of an AfterFunc timer, which the
+// caller must run once the clock is unlocked
+func (t *manualTimer) fireLocked() func() {
+	t.removeLocked()
+	if t.f != nil {
+		return t.f
+	}
+	select {
+	case t.ch <- t.c.now:
+	default:
+	}
+	return nil
+}
+
+func (t *manualTimer) Stop() bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+	wasActive := t.active
+	if wasActive {
+		t.removeLocked()
+	}
+	return wasActive
+}
+
+func (t *manualTimer) Reset(d time.Duration) bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+	wasActive := t.active
+	t.when = t.c.now.Add(d)
+	if !wasActive {
+		t.c.timers = append(t.c.timers, t)
+		t.active = true
+	}
+	// zero or negative durations fire right away just like the
+	// time package, the caller may hold locks the function needs
+	if d <= 0 {
+		if f := t.fireLocked(); f != nil {
+			go f()
+		}
+	}
+	return wasActive
+}
@@ -0,0 +1,132 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// manual_test.go
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestManualClockTimerExpiry(t *testing.T) {
+	start := time.Unix(0, 0)
+	c := NewManualClock(start)
+
+	t1 := c.NewTimer(time.Second * 90)
+	t2 := c.NewTimer(time.Second * 3)
+
+	c.Advance(time.Second * 2)
+	select {
+	case <-t2.C:
+		t.Error("Timer expired before its deadline")
+	default:
+	}
+
+	c.Advance(time.Second * 1)
+	select {
+	case now := <-t2.C:
+		if !now.Equal(start.Add(time.Second * 3)) {
+			t.Error("Timer expired at wrong time", now)
+		}
+	default:
+		t.Error("Timer did not expire at its deadline")
+	}
+
+	// restart prior to expiry pushes out the deadline
+	c.Advance(time.Second * 80)
+	if !t1.Reset(time.Second * 90) {
+		t.Error("Expected timer to be active on reset")
+	}
+	c.Advance(time.Second * 89)
+	select {
+	case <-t1.C:
+		t.Error("Reset timer expired before its new deadline")
+	default:
+	}
+
+	if !t1.Stop() {
+		t.Error("Expected timer to be active on stop")
+	}
+	c.Advance(time.Second * 10)
+	select {
+	case <-t1.C:
+		t.Error("Stopped timer expired")
+	default:
+	}
+	if c.Pending() != 0 {
+		t.Error("Expected no pending timers found", c.Pending())
+	}
+	if !c.Now().Equal(start.Add(time.Second * 182)) {
+		t.Error("Clock did not advance", c.Now())
+	}
+}
+
+func TestManualClockAfterFunc(t *testing.T) {
+	c := NewManualClock(time.Unix(0, 0))
+
+	fired := make(chan int, 2)
+	c.AfterFunc(time.Second*2, func() { fired <- 2 })
+	c.AfterFunc(time.Second*1, func() { fired <- 1 })
+
+	c.Advance(time.Millisecond * 999)
+	if c.Pending() != 2 {
+		t.Error("Expected 2 pending timers found", c.Pending())
+	}
+
+	// functions have returned by the time Advance does, in deadline order
+	c.Advance(time.Second * 5)
+	for i := 1; i <= 2; i++ {
+		select {
+		case v := <-fired:
+			if v != i {
+				t.Error("AfterFunc called out of order expected", i, "actual", v)
+			}
+		default:
+			t.Error("AfterFunc was not called before Advance returned")
+		}
+	}
+}
+
+func TestManualClockAfterFuncRestart(t *testing.T) {
+	start := time.Unix(0, 0)
+	c := NewManualClock(start)
+
+	// a periodic function restarting its own timer fires on each period
+	var timer *Timer
+	fired := make([]time.Time, 0)
+	timer = c.AfterFunc(time.Second, func() {
+		fired = append(fired, c.Now())
+		timer.Reset(time.Second)
+	})
+
+	c.Advance(time.Millisecond * 3500)
+	if len(fired) != 3 ||
+		!fired[0].Equal(start.Add(time.Second)) ||
+		!fired[2].Equal(start.Add(time.Second*3)) {
+		t.Error("Expected periodic AfterFunc to fire each second", fired)
+	}
+	if !c.Now().Equal(start.Add(time.Millisecond*3500)) || c.Pending() != 1 {
+		t.Error("Expected clock at end of advance with the timer restarted", c.Now(), c.Pending())
+	}
+}
@@ -0,0 +1,331 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// errdisable.go
+package errdisable
+
+import (
+	"errors"
+	"fmt"
+	"l2/clock"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Cause is the protection mechanism which shut the port down
+type Cause int
+
+const (
+	CauseNone Cause = iota
+	CauseBpduGuard
+	CauseLacpPartnerMismatch
+)
+
+var CauseStrMap = map[Cause]string{
+	CauseNone:                "None",
+	CauseBpduGuard:           "BpduGuard",
+	CauseLacpPartnerMismatch: "LacpPartnerMismatch",
+}
+
+func (c Cause) String() string {
+	if s, ok := CauseStrMap[c]; ok {
+		return s
+	}
+	return fmt.Sprintf("Cause(%d)", int(c))
+}
+
+// EventType is sent to the registered callbacks
+type EventType int
+
+const (
+	EventErrDisabled EventType = iota + 1
+	EventRecovered
+)
+
+var EventStrMap = map[EventType]string{
+	EventErrDisabled: "ErrDisabled",
+	EventRecovered:   "Recovered",
+}
+
+// Event describes a port entering or leaving the errdisable state, Time
+// is when the event occured and Recovery is when the port is scheduled
+// to recover
+type Event struct {
+	Type     EventType
+	IfIndex  int32
+	Cause    Cause
+	Count    uint32
+	Time     time.Time
+	Recovery time.Time
+}
+
+// Entry is the errdisable record of a port, an entry is kept after the
+// port recovers so that the history of the port may be queried
+type Entry struct {
+	IfIndex int32
+	// port is currently shut down
+	Active bool
+	// cause of the last errdisable
+	Cause Cause
+	// number of times the port has been errdisabled
+	Count uint32
+	// time the port was last errdisabled
+	DisableTime time.Time
+	// time the port will be or was last recovered, zero if the port
+	// requires manual recovery
+	RecoveryTime time.Time
+	// recovery interval, zero means manual recovery
+	Interval time.Duration
+
+	timer *clock.Timer
+}
+
+// ActionFunc shuts down (disable true) or brings back up a port in hw
+type ActionFunc func(ifindex int32, cause Cause, disable bool) error
+
+// Callback is called without any locks held, it should not block
+type Callback func(evt Event)
+
+// ErrDisable tracks the errdisabled ports of a daemon
+type ErrDisable struct {
+	mutex     sync.Mutex
+	clk       clock.Clock
+	action    ActionFunc
+	entries   map[int32]*Entry
+	callbacks map[string]Callback
+}
+
+// New creates an errdisable table, action may be nil if the caller
+// does not need to touch hw
+func New(clk clock.Clock, action ActionFunc) *ErrDisable {
+	if clk == nil {
+		clk = clock.NewRealClock()
+	}
+	return &ErrDisable{
+		clk:       clk,
+		action:    action,
+		entries:   make(map[int32]*Entry),
+		callbacks: make(map[string]Callback),
+	}
+}
+
+// SetClock will move the table to another clock, the entries are kept and
+// the pending recovery timers are restarted on the new clock for the time
+// they had left
+func (e *ErrDisable) SetClock(clk clock.Clock) {
+	if clk == nil {
+		clk = clock.NewRealClock()
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	now := e.clk.Now()
+	e.clk = clk
+	for ifindex, entry := range e.entries {
+		if entry.timer == nil || !entry.timer.Stop() {
+			continue
+		}
+		remaining := entry.RecoveryTime.Sub(now)
+		entry.RecoveryTime = clk.Now().Add(remaining)
+		ifindex, count := ifindex, entry.Count
+		entry.timer = clk.AfterFunc(remaining, func() {
+			e.recover(ifindex, count)
+		})
+	}
+}
+
+// RegisterCallback will register a client for errdisable events
+func (e *ErrDisable) RegisterCallback(name string, cb Callback) {
+	e.mutex.Lock()
+	e.callbacks[name] = cb
+	e.mutex.Unlock()
+}
+
+// DeRegisterCallback will remove a client
+func (e *ErrDisable) DeRegisterCallback(name string) {
+	e.mutex.Lock()
+	delete(e.callbacks, name)
+	e.mutex.Unlock()
+}
+
+// Disable will shut down the port and start the recovery timer, an interval
+// of zero requires the port to be recovered manually.  Disabling a port
+// which is already errdisabled is ignored
+func (e *ErrDisable) Disable(ifindex int32, cause Cause, interval time.Duration) error {
+	if cause == CauseNone {
+		return errors.New(fmt.Sprintf("Invalid errdisable cause for port %d", ifindex))
+	}
+
+	e.mutex.Lock()
+	entry, ok := e.entries[ifindex]
+	if !ok {
+		entry = &Entry{IfIndex: ifindex}
+		e.entries[ifindex] = entry
+	}
+	if entry.Active {
+		e.mutex.Unlock()
+		return nil
+	}
+	entry.Active = true
+	entry.Cause = cause
+	entry.Count++
+	entry.Interval = interval
+	entry.DisableTime = e.clk.Now()
+	entry.RecoveryTime = time.Time{}
+	if interval > 0 {
+		entry.RecoveryTime = entry.DisableTime.Add(interval)
+		count := entry.Count
+		entry.timer = e.clk.AfterFunc(interval, func() {
+			e.recover(ifindex, count)
+		})
+	}
+	evt := entry.event(EventErrDisabled)
+	e.mutex.Unlock()
+
+	var err error
+	if e.action != nil {
+		err = e.action(ifindex, cause, true)
+	}
+	e.notify(evt)
+	return err
+}
+
+// Recover will bring the port back up, used for manual recovery or when
+// the protection mechanism is no longer configured on the port
+func (e *ErrDisable) Recover(ifindex int32) error {
+	e.mutex.Lock()
+	entry, ok := e.entries[ifindex]
+	if !ok || !entry.Active {
+		e.mutex.Unlock()
+		return errors.New(fmt.Sprintf("Port %d is not errdisabled", ifindex))
+	}
+	count := entry.Count
+	e.mutex.Unlock()
+	return e.recover(ifindex, count)
+}
+
+// recover will only act on the errdisable instance identified by count so
+// that a stale recovery timer does not bring up a port that was disabled
+// again after a manual recovery
+func (e *ErrDisable) recover(ifindex int32, count uint32) error {
+	e.mutex.Lock()
+	entry, ok := e.entries[ifindex]
+	if !ok || !entry.Active || entry.Count != count {
+		e.mutex.Unlock()
+		return nil
+	}
+	if entry.timer != nil {
+		entry.timer.Stop()
+		entry.timer = nil
+	}
+	entry.Active = false
+	entry.RecoveryTime = e.clk.Now()
+	cause := entry.Cause
+	evt := entry.event(EventRecovered)
+	e.mutex.Unlock()
+
+	var err error
+	if e.action != nil {
+		err = e.action(ifindex, cause, false)
+	}
+	e.notify(evt)
+	return err
+}
+
+// Delete will remove the port history, the port is not brought back up
+func (e *ErrDisable) Delete(ifindex int32) {
+	e.mutex.Lock()
+	if entry, ok := e.entries[ifindex]; ok {
+		if entry.timer != nil {
+			entry.timer.Stop()
+		}
+		delete(e.entries, ifindex)
+	}
+	e.mutex.Unlock()
+}
+
+// IsDisabled returns true if the port is currently errdisabled by cause,
+// CauseNone matches any cause
+func (e *ErrDisable) IsDisabled(ifindex int32, cause Cause) bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	entry, ok := e.entries[ifindex]
+	return ok && entry.Active && (cause == CauseNone || entry.Cause == cause)
+}
+
+// Get returns a copy of the port entry
+func (e *ErrDisable) Get(ifindex int32) (Entry, bool) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if entry, ok := e.entries[ifindex]; ok {
+		c := *entry
+		c.timer = nil
+		return c, true
+	}
+	return Entry{}, false
+}
+
+// List returns a copy of all entries sorted by ifindex
+func (e *ErrDisable) List() []Entry {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	ifindexList := make([]int, 0, len(e.entries))
+	for ifindex := range e.entries {
+		ifindexList = append(ifindexList, int(ifindex))
+	}
+	sort.Ints(ifindexList)
+	list := make([]Entry, 0, len(ifindexList))
+	for _, ifindex := range ifindexList {
+		c := *e.entries[int32(ifindex)]
+		c.timer = nil
+		list = append(list, c)
+	}
+	return list
+}
+
+func (entry *Entry) event(t EventType) Event {
+	evt := Event{
+		Type:     t,
+		IfIndex:  entry.IfIndex,
+		Cause:    entry.Cause,
+		Count:    entry.Count,
+		Time:     entry.DisableTime,
+		Recovery: entry.RecoveryTime,
+	}
+	if t == EventRecovered {
+		evt.Time = entry.RecoveryTime
+	}
+	return evt
+}
+
+func (e *ErrDisable) notify(evt Event) {
+	e.mutex.Lock()
+	cbList := make([]Callback, 0, len(e.callbacks))
+	for _, cb := range e.callbacks {
+		cbList = append(cbList, cb)
+	}
+	e.mutex.Unlock()
+	for _, cb := range cbList {
+		cb(evt)
+	}
+}
@@ -0,0 +1,190 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// errdisable_test.go
+package errdisable
+
+import (
+	"l2/clock"
+	"testing"
+	"time"
+)
+
+type testAction struct {
+	events chan bool
+}
+
+func (a *testAction) action(ifindex int32, cause Cause, disable bool) error {
+	a.events <- disable
+	return nil
+}
+
+func TestErrDisableAutoRecovery(t *testing.T) {
+	clk := clock.NewManualClock(time.Unix(0, 0))
+	a := &testAction{events: make(chan bool, 10)}
+	e := New(clk, a.action)
+
+	evtChan := make(chan Event, 10)
+	e.RegisterCallback("test", func(evt Event) {
+		evtChan <- evt
+	})
+
+	if err := e.Disable(1, CauseBpduGuard, time.Second*30); err != nil {
+		t.Error("Failed to errdisable port", err)
+	}
+	if disable := <-a.events; !disable {
+		t.Error("Failed port was not shut down")
+	}
+	if evt := <-evtChan; evt.Type != EventErrDisabled ||
+		evt.Cause != CauseBpduGuard ||
+		evt.Count != 1 ||
+		!evt.Recovery.Equal(time.Unix(30, 0)) {
+		t.Error("Failed invalid errdisable event", evt)
+	}
+	if !e.IsDisabled(1, CauseBpduGuard) ||
+		e.IsDisabled(1, CauseLacpPartnerMismatch) ||
+		!e.IsDisabled(1, CauseNone) {
+		t.Error("Failed port errdisable cause not recorded")
+	}
+
+	// already disabled
+	e.Disable(1, CauseBpduGuard, time.Second*30)
+	if entry, _ := e.Get(1); entry.Count != 1 {
+		t.Error("Failed count incremented for port which is already errdisabled", entry.Count)
+	}
+
+	clk.Advance(time.Second * 29)
+	select {
+	case <-a.events:
+		t.Error("Failed port recovered before interval expired")
+	default:
+	}
+
+	clk.Advance(time.Second)
+	select {
+	case disable := <-a.events:
+		if disable {
+			t.Error("Failed port was not brought up")
+		}
+	default:
+		t.Error("Failed port did not recover")
+	}
+	if evt := <-evtChan; evt.Type != EventRecovered ||
+		!evt.Time.Equal(time.Unix(30, 0)) {
+		t.Error("Failed invalid recovery event", evt)
+	}
+	entry, ok := e.Get(1)
+	if !ok || entry.Active || entry.Count != 1 || entry.Cause != CauseBpduGuard {
+		t.Error("Failed port history not kept after recovery", entry)
+	}
+}
+
+func TestErrDisableManualRecovery(t *testing.T) {
+	clk := clock.NewManualClock(time.Unix(0, 0))
+	e := New(clk, nil)
+
+	e.Disable(2, CauseBpduGuard, 0)
+	clk.Advance(time.Hour)
+	if !e.IsDisabled(2, CauseNone) {
+		t.Error("Failed port recovered without manual intervention")
+	}
+	if err := e.Recover(2); err != nil {
+		t.Error("Failed to recover port", err)
+	}
+	if err := e.Recover(2); err == nil {
+		t.Error("Failed recover of port which is not errdisabled should fail")
+	}
+
+	// stale timer from first instance must not recover second instance
+	e.Disable(3, CauseBpduGuard, time.Second*10)
+	e.Recover(3)
+	e.Disable(3, CauseBpduGuard, 0)
+	clk.Advance(time.Second * 10)
+	if !e.IsDisabled(3, CauseNone) {
+		t.Error("Failed stale recovery timer recovered port")
+	}
+
+	e.Disable(1, CauseLacpPartnerMismatch, 0)
+	list := e.List()
+	if len(list) != 3 ||
+		list[0].IfIndex != 1 ||
+		list[1].IfIndex != 2 ||
+		list[2].IfIndex != 3 {
+		t.Error("Failed list not sorted by ifindex", list)
+	}
+}
+
+func TestErrDisableSetClock(t *testing.T) {
+	clk := clock.NewManualClock(time.Unix(0, 0))
+	a := &testAction{events: make(chan bool, 10)}
+	e := New(clk, a.action)
+
+	evtChan := make(chan Event, 10)
+	e.RegisterCallback("test", func(evt Event) {
+		evtChan <- evt
+	})
+
+	e.Disable(1, CauseBpduGuard, time.Second*30)
+	e.Disable(2, CauseBpduGuard, 0)
+	<-a.events
+	<-a.events
+	<-evtChan
+	<-evtChan
+	clk.Advance(time.Second * 10)
+
+	// entries and callbacks are kept, the recovery timer moves along
+	newClk := clock.NewManualClock(time.Unix(1000, 0))
+	e.SetClock(newClk)
+	if clk.Pending() != 0 || newClk.Pending() != 1 {
+		t.Error("Failed recovery timer not moved to new clock", clk.Pending(), newClk.Pending())
+	}
+	entry, ok := e.Get(1)
+	if !ok || !entry.Active || !entry.RecoveryTime.Equal(time.Unix(1020, 0)) {
+		t.Error("Failed invalid recovery time after clock change", entry)
+	}
+	if !e.IsDisabled(2, CauseBpduGuard) {
+		t.Error("Failed manually recovered port lost on clock change")
+	}
+
+	clk.Advance(time.Hour)
+	newClk.Advance(time.Second * 19)
+	select {
+	case <-a.events:
+		t.Error("Failed port recovered before interval expired")
+	default:
+	}
+
+	newClk.Advance(time.Second)
+	select {
+	case disable := <-a.events:
+		if disable {
+			t.Error("Failed port was not brought up")
+		}
+	default:
+		t.Error("Failed port did not recover")
+	}
+	if evt := <-evtChan; evt.Type != EventRecovered ||
+		!evt.Time.Equal(time.Unix(1020, 0)) {
+		t.Error("Failed invalid recovery event", evt)
+	}
+}
@@ -0,0 +1,77 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// cache.go
+package grpcapi
+
+import (
+	"reflect"
+	"sync"
+)
+
+// ConfigCache holds the current config objects of a daemon by key.  An
+// update request only carries the attributes being updated, the rest of
+// the object is taken from the cache
+type ConfigCache struct {
+	mutex sync.RWMutex
+	objs  map[string]interface{}
+}
+
+func NewConfigCache() *ConfigCache {
+	return &ConfigCache{objs: make(map[string]interface{})}
+}
+
+// Set stores a copy of obj, obj must be a pointer to a struct
+func (c *ConfigCache) Set(key string, obj interface{}) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.objs[key] = objCopy(obj)
+}
+
+// Get returns a copy of the object which may be modified by the caller
+func (c *ConfigCache) Get(key string) (interface{}, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	obj, ok := c.objs[key]
+	if !ok {
+		return nil, false
+	}
+	return objCopy(obj), true
+}
+
+func (c *ConfigCache) Delete(key string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.objs, key)
+}
+
+// objCopy returns a shallow copy of the struct pointed to by obj
+func objCopy(obj interface{}) interface{} {
+	val := reflect.ValueOf(obj)
+	if val.Kind() != reflect.Ptr || val.IsNil() {
+		return obj
+	}
+	cp := reflect.New(val.Elem().Type())
+	cp.Elem().Set(val.Elem())
+	return cp.Interface()
+}
@@ -0,0 +1,103 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// convert.go
+package grpcapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// Convert copies the attributes of src to dst by name.  The grpc messages
+// use the same attribute names as the thrift objects so the existing
+// thrift handlers can be reused
+func Convert(src interface{}, dst interface{}) error {
+	data, err := json.Marshal(src)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dst)
+}
+
+// AttrSet will build the thrift update attrset of obj, one entry per
+// attribute of obj, from the names of the attributes to update.  An empty
+// list updates all attributes
+func AttrSet(obj interface{}, attrs []string) ([]bool, error) {
+	objTyp := reflect.TypeOf(obj)
+	if objTyp.Kind() == reflect.Ptr {
+		objTyp = objTyp.Elem()
+	}
+	if objTyp.Kind() != reflect.Struct {
+		return nil, errors.New(fmt.Sprintf("Invalid object type %s", objTyp))
+	}
+
+	attrset := make([]bool, objTyp.NumField())
+	for _, attr := range attrs {
+		found := false
+		for i := 0; i < objTyp.NumField(); i++ {
+			if objTyp.Field(i).Name == attr {
+				attrset[i] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.New(fmt.Sprintf("Unknown attribute %s for %s", attr, objTyp.Name()))
+		}
+	}
+	if len(attrs) == 0 {
+		for i := range attrset {
+			attrset[i] = true
+		}
+	}
+	return attrset, nil
+}
+
+// Overlay will fill the attributes of update which are not set in attrset
+// from orig, so update holds the current object with only the requested
+// attributes changed.  Both must be pointers to the same struct type
+func Overlay(update interface{}, orig interface{}, attrset []bool) error {
+	updateVal := reflect.ValueOf(update)
+	origVal := reflect.ValueOf(orig)
+	if updateVal.Kind() != reflect.Ptr ||
+		origVal.Kind() != reflect.Ptr ||
+		updateVal.Type() != origVal.Type() ||
+		updateVal.Elem().Kind() != reflect.Struct {
+		return errors.New(fmt.Sprintf("Invalid object types %s %s", updateVal.Type(), origVal.Type()))
+	}
+	updateVal = updateVal.Elem()
+	origVal = origVal.Elem()
+	if len(attrset) != updateVal.NumField() {
+		return errors.New(fmt.Sprintf("Invalid attrset length %d for %s", len(attrset), updateVal.Type().Name()))
+	}
+	for i := 0; i < updateVal.NumField(); i++ {
+		if !attrset[i] &&
+			updateVal.Field(i).CanSet() {
+			updateVal.Field(i).Set(origVal.Field(i))
+		}
+	}
+	return nil
+}
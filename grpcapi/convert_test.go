@@ -0,0 +1,154 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// convert_test.go
+package grpcapi
+
+import (
+	"testing"
+)
+
+// thriftObj mimics a generated thrift object
+type thriftObj struct {
+	Vlan    int16   `thrift:"Vlan,1" json:"Vlan"`
+	Name    string  `thrift:"Name,2" json:"Name"`
+	Members []int32 `thrift:"Members,3" json:"Members"`
+	Count   uint64  `thrift:"Count,4" json:"Count"`
+	Enable  bool    `thrift:"Enable,5" json:"Enable"`
+}
+
+// grpcMsg mimics a generated proto3 message
+type grpcMsg struct {
+	Vlan    uint32  `protobuf:"varint,1,opt,name=Vlan" json:"Vlan,omitempty"`
+	Name    string  `protobuf:"bytes,2,opt,name=Name" json:"Name,omitempty"`
+	Members []int32 `protobuf:"varint,3,rep,name=Members" json:"Members,omitempty"`
+	Count   uint64  `protobuf:"varint,4,opt,name=Count" json:"Count,omitempty"`
+	Enable  bool    `protobuf:"varint,5,opt,name=Enable" json:"Enable,omitempty"`
+}
+
+func TestConvert(t *testing.T) {
+	in := &grpcMsg{Vlan: 100, Name: "br100", Members: []int32{1, 2}, Count: 1 << 40, Enable: true}
+	obj := &thriftObj{}
+	if err := Convert(in, obj); err != nil {
+		t.Fatal("Convert failed", err)
+	}
+	if obj.Vlan != 100 ||
+		obj.Name != "br100" ||
+		len(obj.Members) != 2 ||
+		obj.Count != 1<<40 ||
+		!obj.Enable {
+		t.Error("Convert to thrift object invalid", obj)
+	}
+
+	out := &grpcMsg{}
+	if err := Convert(obj, out); err != nil ||
+		out.Vlan != in.Vlan ||
+		out.Members[1] != 2 {
+		t.Error("Convert to grpc message invalid", out, err)
+	}
+
+	// value does not fit the thrift attribute
+	in.Vlan = 1 << 20
+	if err := Convert(in, obj); err == nil {
+		t.Error("Expected Convert to fail on overflow")
+	}
+}
+
+func TestAttrSet(t *testing.T) {
+	attrset, err := AttrSet(thriftObj{}, []string{"Name", "Enable"})
+	if err != nil {
+		t.Fatal("AttrSet failed", err)
+	}
+	expected := []bool{false, true, false, false, true}
+	for i := range expected {
+		if attrset[i] != expected[i] {
+			t.Error("AttrSet invalid", attrset)
+			break
+		}
+	}
+
+	if attrset, err = AttrSet(&thriftObj{}, nil); err != nil || len(attrset) != 5 {
+		t.Error("AttrSet of all attributes invalid", attrset, err)
+	}
+	for _, set := range attrset {
+		if !set {
+			t.Error("Expected all attributes to be set", attrset)
+		}
+	}
+
+	if _, err = AttrSet(thriftObj{}, []string{"Unknown"}); err == nil {
+		t.Error("Expected unknown attribute to fail")
+	}
+	if _, err = AttrSet(1, nil); err == nil {
+		t.Error("Expected non struct object to fail")
+	}
+}
+
+func TestOverlay(t *testing.T) {
+	orig := &thriftObj{Vlan: 100, Name: "br100", Members: []int32{1, 2}, Count: 10, Enable: true}
+
+	// only the name is updated, the rest comes from the original object
+	update := &thriftObj{Vlan: 100, Name: "br200"}
+	attrset, _ := AttrSet(update, []string{"Name"})
+	if err := Overlay(update, orig, attrset); err != nil {
+		t.Fatal("Overlay failed", err)
+	}
+	if update.Name != "br200" ||
+		update.Count != 10 ||
+		len(update.Members) != 2 ||
+		!update.Enable {
+		t.Error("Overlay invalid", update)
+	}
+	if orig.Name != "br100" {
+		t.Error("Overlay modified the original object", orig)
+	}
+
+	if err := Overlay(update, &grpcMsg{}, attrset); err == nil {
+		t.Error("Expected different object types to fail")
+	}
+	if err := Overlay(update, orig, attrset[:2]); err == nil {
+		t.Error("Expected short attrset to fail")
+	}
+}
+
+func TestConfigCache(t *testing.T) {
+	c := NewConfigCache()
+	obj := &thriftObj{Vlan: 100, Name: "br100"}
+	c.Set("100", obj)
+
+	// the cache holds a copy
+	obj.Name = "br200"
+	cached, ok := c.Get("100")
+	if !ok || cached.(*thriftObj).Name != "br100" {
+		t.Error("ConfigCache Get invalid", cached, ok)
+	}
+	cached.(*thriftObj).Enable = true
+	if cached, _ = c.Get("100"); cached.(*thriftObj).Enable {
+		t.Error("ConfigCache Get returned the cached object")
+	}
+
+	c.Delete("100")
+	if _, ok = c.Get("100"); ok {
+		t.Error("Expected object to be deleted")
+	}
+}
@@ -0,0 +1,136 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// grpcapi.go
+package grpcapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/grpc-ecosystem/grpc-gateway/runtime"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"io/ioutil"
+	"net"
+	"net/http"
+)
+
+// suffix of the clients.json entries holding the grpc and json/http
+// gateway ports of a daemon, i.e. lacpd-grpc and lacpd-gw
+const (
+	GrpcClientSuffix    = "-grpc"
+	GatewayClientSuffix = "-gw"
+)
+
+type clientJson struct {
+	Name string `json:"Name"`
+	Port int    `json:"Port"`
+}
+
+// ClientPortsGet looks up the grpc and gateway ports of the daemon in the
+// clients.json file, a port of zero means the server is not configured
+func ClientPortsGet(paramsFile string, daemon string) (grpcPort int, gwPort int) {
+	var clientsList []clientJson
+
+	bytes, err := ioutil.ReadFile(paramsFile)
+	if err != nil {
+		return 0, 0
+	}
+	if err = json.Unmarshal(bytes, &clientsList); err != nil {
+		return 0, 0
+	}
+	for _, client := range clientsList {
+		switch client.Name {
+		case daemon + GrpcClientSuffix:
+			grpcPort = client.Port
+		case daemon + GatewayClientSuffix:
+			gwPort = client.Port
+		}
+	}
+	return grpcPort, gwPort
+}
+
+// GatewayRegisterFunc is the generated Register<Service>HandlerFromEndpoint
+type GatewayRegisterFunc func(ctx context.Context, mux *runtime.ServeMux, endpoint string, opts []grpc.DialOption) error
+
+// Server runs the grpc server of a daemon and the json/http gateway which
+// proxies to it, both run alongside the thrift server
+type Server struct {
+	Name        string
+	GrpcAddr    string
+	GatewayAddr string
+	grpc        *grpc.Server
+}
+
+// NewServer the gateway is not started when gwPort is zero
+func NewServer(name string, grpcPort int, gwPort int) *Server {
+	s := &Server{
+		Name:     name,
+		GrpcAddr: fmt.Sprintf("localhost:%d", grpcPort),
+		grpc:     grpc.NewServer(),
+	}
+	if gwPort != 0 {
+		s.GatewayAddr = fmt.Sprintf("localhost:%d", gwPort)
+	}
+	return s
+}
+
+// GrpcServer is used to register the service implementation
+func (s *Server) GrpcServer() *grpc.Server {
+	return s.grpc
+}
+
+// Start will listen on the grpc and gateway addresses and serve in the
+// background, errors while serving are reported via errCb
+func (s *Server) Start(register GatewayRegisterFunc, errCb func(error)) error {
+	lis, err := net.Listen("tcp", s.GrpcAddr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		if err := s.grpc.Serve(lis); err != nil && errCb != nil {
+			errCb(errors.New(fmt.Sprintf("%s grpc server stopped: %s", s.Name, err)))
+		}
+	}()
+
+	if s.GatewayAddr == "" || register == nil {
+		return nil
+	}
+	mux := runtime.NewServeMux()
+	err = register(context.Background(), mux, s.GrpcAddr, []grpc.DialOption{grpc.WithInsecure()})
+	if err != nil {
+		s.grpc.Stop()
+		return err
+	}
+	go func() {
+		if err := http.ListenAndServe(s.GatewayAddr, mux); err != nil && errCb != nil {
+			errCb(errors.New(fmt.Sprintf("%s gateway stopped: %s", s.Name, err)))
+		}
+	}()
+	return nil
+}
+
+func (s *Server) Stop() {
+	s.grpc.Stop()
+}
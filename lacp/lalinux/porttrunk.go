@@ -22,22 +22,38 @@
 //                                                                                                           
 
 // porttrunk.go
-package main
+package lalinux
 
 import (
+	"errors"
 	"fmt"
 	"github.com/vishvananda/netlink"
+	"io/ioutil"
 	"net"
-//	"os/exec"
 	"strconv"
 	"strings"
-	"time"
 )
 
 // command to show status of lag
 // cat /proc/net/bonding/bond-0
 
-var BondedIfIdxBase int
+// lag hash modes as defined by the lacp model
+const (
+	LagHashL2     = 0
+	LagHashL2L3   = 1
+	LagHashL3L4   = 2
+	LagHashEncap2 = 3
+	LagHashEncap3 = 4
+)
+
+// xmit_hash_policy names as used by the bonding driver
+var xmitHashPolicyStrMap = map[netlink.BondXmitHashPolicy]string{
+	netlink.BOND_XMIT_HASH_POLICY_LAYER2:   "layer2",
+	netlink.BOND_XMIT_HASH_POLICY_LAYER3_4: "layer3+4",
+	netlink.BOND_XMIT_HASH_POLICY_LAYER2_3: "layer2+3",
+	netlink.BOND_XMIT_HASH_POLICY_ENCAP2_3: "encap2+3",
+	netlink.BOND_XMIT_HASH_POLICY_ENCAP3_4: "encap3+4",
+}
 
 // the id of the agg should be part of the name
 // format expected <name>-<#number>
@@ -51,140 +67,138 @@ func GetIdByName(AggName string) int {
 	return i
 }
 
-// BondedLinkCreate will create a bonded interface
-// bondname must be in the format of name-<number>
-func BondLinkCreate(bondname string, mac string, hashmode int) (link netlink.Link, err error) {
-	fmt.Println("in bonnded create for bondname ", bondname)
+// LagHashToXmitHashPolicy converts the lacp model hash mode to the
+// bonding xmit_hash_policy, unknown modes default to layer2
+func LagHashToXmitHashPolicy(hashmode uint32) netlink.BondXmitHashPolicy {
+	switch hashmode {
+	case LagHashL2L3:
+		return netlink.BOND_XMIT_HASH_POLICY_LAYER2_3
+	case LagHashL3L4:
+		return netlink.BOND_XMIT_HASH_POLICY_LAYER3_4
+	case LagHashEncap2:
+		return netlink.BOND_XMIT_HASH_POLICY_ENCAP2_3
+	case LagHashEncap3:
+		return netlink.BOND_XMIT_HASH_POLICY_ENCAP3_4
+	}
+	return netlink.BOND_XMIT_HASH_POLICY_LAYER2
+}
+
+// BondLinkCreate will create a bonded interface in balance-xor mode, the
+// bonding driver does not run lacp, members are added/removed by lacpd as
+// they are attached/detached.  The bond is left down, see BondLinkUpSet
+func BondLinkCreate(bondname string, mac string, hashmode uint32, minlinks int) (link netlink.Link, err error) {
 	hwmac, _ := net.ParseMAC(mac)
 	var linkAttrs = netlink.LinkAttrs{
-		//	Index:        BondedIfIdxBase + GetIdByName(bondname),
 		Name:         bondname,
 		HardwareAddr: hwmac,
 	}
 
-	fmt.Println("linkAttrs.Name=", linkAttrs.Name)
 	bondedif := netlink.NewLinkBond(linkAttrs)
-	bondedif.Mode = netlink.BOND_MODE_BALANCE_RR
-	bondedif.XmitHashPolicy = netlink.BondXmitHashPolicy(hashmode)
-	bondedif.MinLinks = 1
+	bondedif.Mode = netlink.BOND_MODE_BALANCE_XOR
+	bondedif.XmitHashPolicy = LagHashToXmitHashPolicy(hashmode)
+	if minlinks < 1 {
+		minlinks = 1
+	}
+	bondedif.MinLinks = minlinks
 	err = netlink.LinkAdd(bondedif)
 	if err != nil {
-		fmt.Println("err from Bond LinkAdd = ", err)
-		return bondedif, err
-	}
-	/*
-		time.Sleep(time.Second * 1)
-		err = netlink.LinkSetUp(bondedif)
-		if err != nil {
-			fmt.Println("err from Bond LinkSetUp = ", err)
-			return bondedif, err
-		}
-	*/
-	return bondedif, err
+		return bondedif, errors.New(fmt.Sprintf("Bond %s create failed: %s", bondname, err))
+	}
+	return bondedif, nil
+}
+
+// BondLinkUpSet no traffic is sent or received on the bond while it is down
+func BondLinkUpSet(bondname string, up bool) error {
+	bondedif, err := netlink.LinkByName(bondname)
+	if err != nil {
+		return err
+	}
+	if up {
+		return netlink.LinkSetUp(bondedif)
+	}
+	return netlink.LinkSetDown(bondedif)
 }
 
 func BondLinkDelete(bondname string) (err error) {
-	if bondedif, err := netlink.LinkByName(bondname); err == nil {
+	bondedif, err := netlink.LinkByName(bondname)
+	if err == nil {
 		err = netlink.LinkDel(bondedif)
 	}
 	return err
 }
 
-func AddLinkToBond(bondname string, linkname string) (err error) {
-	if bondedif, err := netlink.LinkByName(bondname); err == nil {
-		if linkif, err := netlink.LinkByName(linkname); err == nil {
-
-			// link should be down before we add it to the bonded interface
-			err = netlink.LinkSetDown(linkif)
-			if err != nil {
-				fmt.Println("err from Link LinkSetDown = ", err)
-				return err
-			}
-
-			time.Sleep(time.Second * 1)
-
-			linkif.Attrs().ParentIndex = bondedif.Attrs().Index
-			err = netlink.LinkSetMasterByIndex(linkif, bondedif.Attrs().Index)
-			if err != nil {
-				fmt.Println("err from Add Link to Bond LinkSetMasterByIndex = ", err)
-				return err
-			}
-			fmt.Println("Adding interface", linkname, "to bonded interface", bondname)
-		}
-	}
-	return err
+// BondHashModeSet will change the xmit_hash_policy of an existing bond
+func BondHashModeSet(bondname string, hashmode uint32) error {
+	policy := xmitHashPolicyStrMap[LagHashToXmitHashPolicy(hashmode)]
+	return ioutil.WriteFile(fmt.Sprintf("/sys/class/net/%s/bonding/xmit_hash_policy", bondname), []byte(policy), 0644)
 }
 
-func DelLinkFromBond(bondname string, linkname string) (err error) {
-	if bondedif, err := netlink.LinkByName(bondname); err == nil {
-		if linkif, err := netlink.LinkByName(linkname); err == nil {
-
-			// link should be down before we add it to the bonded interface
-			err = netlink.LinkSetDown(linkif)
-			if err != nil {
-				fmt.Println("err from LinkSetDown = ", err)
-				return err
-			}
-
-			time.Sleep(time.Second * 1)
-			linkif.Attrs().ParentIndex = bondedif.Attrs().Index
-			err = netlink.LinkSetNoMaster(linkif)
-			if err != nil {
-				fmt.Println("err from LinkSetNoMaster = ", err)
-				return err
-			}
-			fmt.Println("Deleting interface", linkname, "from bonded interface", bondname)
+// BondSlavesGet returns the names of the links enslaved to the bond
+func BondSlavesGet(bondname string) ([]string, error) {
+	slaves := make([]string, 0)
+	bondedif, err := netlink.LinkByName(bondname)
+	if err != nil {
+		return slaves, err
+	}
+	links, err := netlink.LinkList()
+	if err != nil {
+		return slaves, err
+	}
+	for _, link := range links {
+		if link.Attrs().MasterIndex == bondedif.Attrs().Index {
+			slaves = append(slaves, link.Attrs().Name)
 		}
 	}
-	return err
+	return slaves, nil
 }
 
-func main() {
-
-	BondedIfIdxBase = 51
-	bondname := "bond0"
-	linkname := "eth0"
-	BondLinkCreate(bondname, "00:DD:EE:AA:DD:00", 0)
-
-	time.Sleep(time.Second * 1)
-	AddLinkToBond(bondname, linkname)
-/*
-	time.Sleep(time.Second * 1)
-
-	binary, lookErr := exec.LookPath("ifconfig")
-	if lookErr != nil {
-		fmt.Println("ifconfig not found lookerr = ", lookErr)
+// AddLinkToBond the bonding driver will only enslave a link which is down,
+// so the link is brought down and back up.  This is seen by the partner as
+// a link flap, so the link should be added before it is in use
+func AddLinkToBond(bondname string, linkname string) (err error) {
+	bondedif, err := netlink.LinkByName(bondname)
+	if err != nil {
+		return err
 	}
-
-	out, err := exec.Command(binary).Output()
+	linkif, err := netlink.LinkByName(linkname)
 	if err != nil {
-		fmt.Println("Error executing ifconfig")
+		return err
 	}
-	fmt.Println(string(out))
-	time.Sleep(time.Second * 1)
-	binary2, lookErr2 := exec.LookPath("cat")
-	if lookErr2 != nil {
-		fmt.Println("cat not found lookerr = ", lookErr2)
+	if linkif.Attrs().MasterIndex == bondedif.Attrs().Index {
+		return nil
 	}
 
-	out, err = exec.Command(binary2, fmt.Sprintf("/proc/net/bonding/%s", bondname)).Output()
+	// link should be down before we add it to the bonded interface
+	err = netlink.LinkSetDown(linkif)
 	if err != nil {
-		fmt.Println("Error executing ifconfig")
+		return err
 	}
-	fmt.Println(string(out))
-
-	fmt.Println("Deleting link from Bond")
-	DelLinkFromBond(bondname, linkname)
+	err = netlink.LinkSetMasterByIndex(linkif, bondedif.Attrs().Index)
+	if err != nil {
+		netlink.LinkSetUp(linkif)
+		return errors.New(fmt.Sprintf("Add %s to bond %s failed: %s", linkname, bondname, err))
+	}
+	return netlink.LinkSetUp(linkif)
+}
 
-	time.Sleep(time.Second * 1)
-	fmt.Println("Deleting Bond")
-	BondLinkDelete(bondname)
+func DelLinkFromBond(bondname string, linkname string) (err error) {
+	bondedif, err := netlink.LinkByName(bondname)
+	if err != nil {
+		return err
+	}
+	linkif, err := netlink.LinkByName(linkname)
+	if err != nil {
+		return err
+	}
+	if linkif.Attrs().MasterIndex != bondedif.Attrs().Index {
+		return nil
+	}
 
-	time.Sleep(time.Second * 1)
-	out, err = exec.Command(binary).Output()
+	err = netlink.LinkSetNoMaster(linkif)
 	if err != nil {
-		fmt.Println("Error executing ifconfig")
+		return errors.New(fmt.Sprintf("Delete %s from bond %s failed: %s", linkname, bondname, err))
 	}
-	fmt.Println(string(out))
-*/
+	// releasing the slave will bring the link down, lacp still needs
+	// to run on the link
+	return netlink.LinkSetUp(linkif)
 }
@@ -30,6 +30,7 @@ import (
 	"git.apache.org/thrift.git/lib/go/thrift"
 	lacp "l2/lacp/protocol"
 	"l2/lacp/rpc"
+	"l2/pktio"
 	"lacpd"
 	"net"
 	"utils/keepalive"
@@ -42,13 +43,27 @@ func main() {
 
 	// lookup port
 	paramsDir := flag.String("params", "./params", "Params directory")
+	pktIoType := flag.String("pktio", pktio.PktIoTypePcap, "Packet I/O backend: pcap, afpacket or memory")
 	flag.Parse()
+	if !pktio.IsSupported(*pktIoType) {
+		fmt.Println("Unsupported packet I/O backend", *pktIoType)
+		return
+	}
+	pktIoCfg := pktio.DefaultConfig()
+	pktIoCfg.Type = *pktIoType
+	lacp.LacpPktIoConfigSet(pktIoCfg)
 	path := *paramsDir
 	if path[len(path)-1] != '/' {
 		path = path + "/"
 	}
 	fileName := path + "clients.json"
 
+	hwPlugin, err := lacp.LacpHwPluginFromParams(path)
+	if err != nil {
+		panic(err)
+	}
+	lacp.LacpHwPluginSet(hwPlugin)
+
 	port := lacp.GetClientPort(fileName, "lacpd")
 	if port != 0 {
 		addr := fmt.Sprintf("localhost:%d", port)
@@ -64,11 +79,18 @@ func main() {
 		server := thrift.NewTSimpleServer4(processor, transport, transportFactory, protocolFactory)
 
 		// connect to any needed services
-		lacp.ConnectToClients(fileName)
+		if hwPlugin.Name() == lacp.LagHwPluginAsicd {
+			lacp.ConnectToClients(fileName)
+		}
 
 		// lets replay any config that is in the db
 		handler.ReadConfigFromDB()
 
+		// grpc and json/http gateway api run alongside the thrift server
+		if err = rpc.StartGrpcServer(handler, fileName); err != nil {
+			fmt.Println("ERROR grpc server not started", err)
+		}
+
 		// Start keepalive routine
 		go keepalive.InitKeepAlive("lacpd", path)
 
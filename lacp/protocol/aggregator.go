@@ -25,7 +25,7 @@
 package lacp
 
 import (
-	//"fmt"
+	"fmt"
 	//"log/syslog"
 	"net"
 	"time"
@@ -130,6 +130,7 @@ type LaAggregator struct {
 	AggName        string // 255 max chars
 	AggType        uint32 // LACP/STATIC
 	AggMinLinks    uint16
+	AggMaxLinks    uint16
 
 	// lacp configuration info
 	Config LacpConfigInfo
@@ -167,6 +168,9 @@ type LaAggregator struct {
 	// date of last oper change
 	timeOfLastOperChange time.Time
 
+	// send oper state up/down notifications
+	AggLinkUpDownNotificationEnable bool
+
 	// aggrigator stats
 	stats LacpAggregatorStats
 
@@ -196,6 +200,38 @@ type LaAggregator struct {
 	// 4 - ENCAP2
 	LagHash uint32
 
+	// Version 2
+	// version of the protocol to run on member ports
+	Version uint8
+	// aAggPortAlgorithm
+	PortAlgorithm uint32
+	// aAggConversationAdminLink, prioritized list of Link Number ID's
+	// per conversation id
+	ConversationAdminLink map[uint16][]uint16
+	// aAggAdminServiceConversationMap, list of Service ID's per
+	// conversation id
+	AdminServiceConversationMap map[uint16][]uint32
+	// aAggAdminDiscardWrongConversation
+	AdminDiscardWrongConversation bool
+	// Conversation_PortList, port which each conversation id
+	// is assigned to, 0 when not assigned
+	operConversationPortList [LacpMaxConversationIds]uint16
+	// cached Actor_Conversation_LinkList_Digest and
+	// Actor_Conversation_Service_Mapping_Digest
+	convLinkListDigest       [16]uint8
+	convServiceMappingDigest [16]uint8
+
+	// Distributed Relay the aggregator is part of, nil when the
+	// aggregator is not part of a Portal
+	dr *DistributedRelay
+
+	// fallback mode and timeout used when ports do not receive
+	// any LACPDU's
+	FallbackMode    uint32
+	FallbackTimeout time.Duration
+	// port which is currently in fallback when mode is static
+	fallbackPortNum uint16
+
 	LacpDebug *LacpDebug
 	log       chan string
 }
@@ -215,12 +251,25 @@ func NewLaAggregator(ac *LaAggConfig) *LaAggregator {
 		actorAdminKey:          ac.Key,
 		AggType:                ac.Type,
 		AggMinLinks:            ac.MinLinks,
+		AggMaxLinks:            ac.MaxLinks,
 		Config:                 ac.Lacp,
 		partnerSystemId:        [6]uint8{0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
 		ready:                  true,
 		PortNumList:            make([]uint16, 0),
 		DistributedPortNumList: make([]string, 0),
 		LagHash:                ac.HashMode,
+		Version:                ac.Version,
+		PortAlgorithm:          ac.PortAlgorithm,
+		ConversationAdminLink:  ac.ConversationAdminLink,
+	}
+	a.AdminServiceConversationMap = ac.AdminServiceConversationMap
+	a.AdminDiscardWrongConversation = ac.AdminDiscardWrongConversation
+	a.LacpConversationDigestsUpdate()
+	a.AggLinkUpDownNotificationEnable = true
+	a.FallbackMode = ac.FallbackMode
+	a.FallbackTimeout = ac.FallbackTimeout
+	if a.FallbackTimeout == 0 {
+		a.FallbackTimeout = LacpFallbackDefaultTimeout
 	}
 
 	a.LacpDebugAggEventLogMain()
@@ -241,6 +290,49 @@ func NewLaAggregator(ac *LaAggConfig) *LaAggregator {
 	return a
 }
 
+// LacpAggOperStateUpdate will program the hw lag based on the distributing
+// ports.  The aggregator comes up, and the lag is created in hw, once at
+// least AggMinLinks ports are distributing.  Below min links the mux
+// machines take the ports out of service so the lag is deleted when the
+// last port stops distributing.  Reason is reported when the aggregator
+// goes down
+func (a *LaAggregator) LacpAggOperStateUpdate(reason uint8) {
+	minLinks := int(a.AggMinLinks)
+	if minLinks == 0 {
+		minLinks = 1
+	}
+
+	hw := LacpHwPluginGet()
+	if len(a.DistributedPortNumList) >= minLinks ||
+		(a.OperState && len(a.DistributedPortNumList) > 0) {
+		if !a.OperState {
+			hwAggId, err := hw.CreateLag(a.lagHwConfigGet(), a.DistributedPortNumList)
+			a.LacpDebug.logger.Info(fmt.Sprintf("%s CreateLag : id %d hash %d portList %v err %v", hw.Name(), hwAggId, a.LagHash, a.DistributedPortNumList, err))
+			a.HwAggId = hwAggId
+			a.OperState = true
+			a.timeOfLastOperChange = time.Now()
+			// TODO UPDATE SQL DB for warm boot purposes
+			a.LacpNotify(LacpNotifyAggOperStateUp, nil, LacpNotifyReasonNone)
+		} else {
+			err := hw.UpdateLagMembers(a.HwAggId, a.lagHwConfigGet(), a.DistributedPortNumList)
+			a.LacpDebug.logger.Info(fmt.Sprintf("%s UpdateLagMembers : id %d portList %v err %v", hw.Name(), a.HwAggId, a.DistributedPortNumList, err))
+		}
+	} else if a.OperState {
+		err := hw.DeleteLag(a.HwAggId, a.lagHwConfigGet())
+		a.LacpDebug.logger.Info(fmt.Sprintf("%s DeleteLag : id %d err %v", hw.Name(), a.HwAggId, err))
+		a.HwAggId = 0
+		// not enough ports active in group, lets mark the lag as operationally down
+		a.OperState = false
+		a.timeOfLastOperChange = time.Now()
+		// TODO UPDATE SQL DB
+		if reason == LacpNotifyReasonNone &&
+			a.LacpAggMinLinksHold() {
+			reason = LacpNotifyReasonMinLinks
+		}
+		a.LacpNotify(LacpNotifyAggOperStateDown, nil, reason)
+	}
+}
+
 // warning for each call the map may change
 func LaGetAggNext(agg **LaAggregator) bool {
 	returnNext := false
@@ -322,6 +414,13 @@ func LaFindAggByKey(Key uint16, agg **LaAggregator) bool {
 }
 
 func (a *LaAggregator) DeleteLaAgg() {
+	// lag should not be left behind in hw
+	if a.OperState {
+		err := LacpHwPluginGet().DeleteLag(a.HwAggId, a.lagHwConfigGet())
+		a.LacpDebug.logger.Info(fmt.Sprintf("Agg %d deleted, DeleteLag hwAggId %d err %v", a.AggId, a.HwAggId, err))
+		a.HwAggId = 0
+		a.OperState = false
+	}
 	for _, sgi := range LacpSysGlobalInfoGet() {
 		lookupKey := AggIdKey{Id: a.AggId, Name: a.AggName}
 		for Key, _ := range sgi.AggMap {
@@ -25,6 +25,7 @@
 package lacp
 
 import (
+	"l2/clock"
 	"strconv"
 	"strings"
 	"time"
@@ -88,7 +89,7 @@ type LacpCdMachine struct {
 	churnTimerInterval time.Duration
 
 	// Interval timers
-	churnTimer *time.Timer
+	churnTimer *clock.Timer
 
 	// machine specific events
 	CdmEvents            chan LacpMachineEvent
@@ -187,11 +188,11 @@ func (cdm *LacpActorCdMachine) LacpCdMachineActorChurn(m fsm.Machine, data inter
 	p := cdm.p
 	p.actorChurn = true
 	if p.AggPortDebug.AggPortDebugActorChurnCount == 0 {
-		cdm.churnCountTimestamp = time.Now()
+		cdm.churnCountTimestamp = LacpClockGet().Now()
 		p.AggPortDebug.AggPortDebugActorChurnCount++
-	} else if time.Now().Second()-cdm.churnCountTimestamp.Second() > 5 {
+	} else if LacpClockGet().Now().Second()-cdm.churnCountTimestamp.Second() > 5 {
 		p.AggPortDebug.AggPortDebugActorChurnCount++
-		cdm.churnCountTimestamp = time.Now()
+		cdm.churnCountTimestamp = LacpClockGet().Now()
 	}
 	cdm.ChurnDetectionTimerStop()
 	return LacpCdmStateActorChurn
@@ -219,11 +220,11 @@ func (cdm *LacpPartnerCdMachine) LacpCdMachinePartnerChurn(m fsm.Machine, data i
 	p := cdm.p
 	p.partnerChurn = true
 	if p.AggPortDebug.AggPortDebugPartnerChurnCount == 0 {
-		cdm.churnCountTimestamp = time.Now()
+		cdm.churnCountTimestamp = LacpClockGet().Now()
 		p.AggPortDebug.AggPortDebugPartnerChurnCount++
-	} else if time.Now().Second()-cdm.churnCountTimestamp.Second() > 5 {
+	} else if LacpClockGet().Now().Second()-cdm.churnCountTimestamp.Second() > 5 {
 		p.AggPortDebug.AggPortDebugPartnerChurnCount++
-		cdm.churnCountTimestamp = time.Now()
+		cdm.churnCountTimestamp = LacpClockGet().Now()
 	}
 
 	cdm.ChurnDetectionTimerStop()
@@ -91,6 +91,8 @@ type LaAggConfig struct {
 	Type uint32
 	// Minimum number of links
 	MinLinks uint16
+	// Maximum number of active links, 0 is unlimited
+	MaxLinks uint16
 	// Enabled
 	Enabled bool
 	// LAG_ports
@@ -104,6 +106,23 @@ type LaAggConfig struct {
 
 	// hash config
 	HashMode uint32
+
+	// Version 2
+	// lacp version 1 or 2
+	Version uint8
+	// port algorithm
+	PortAlgorithm uint32
+	// prioritized list of Link Number ID's per conversation id
+	ConversationAdminLink map[uint16][]uint16
+	// list of Service ID's per conversation id
+	AdminServiceConversationMap map[uint16][]uint32
+	// discard frames received on the wrong link
+	AdminDiscardWrongConversation bool
+
+	// fallback mode when no LACPDU is received
+	FallbackMode uint32
+	// time to wait for an LACPDU before falling back
+	FallbackTimeout time.Duration
 }
 
 type AggPortConfig struct {
@@ -181,8 +200,15 @@ func SaveLaAggConfig(ac *LaAggConfig) {
 		a.actorAdminKey = ac.Key
 		a.AggType = ac.Type
 		a.AggMinLinks = ac.MinLinks
+		a.AggMaxLinks = ac.MaxLinks
 		a.Config = ac.Lacp
 		a.LagHash = ac.HashMode
+		a.Version = ac.Version
+		a.PortAlgorithm = ac.PortAlgorithm
+		a.ConversationAdminLink = ac.ConversationAdminLink
+		a.AdminServiceConversationMap = ac.AdminServiceConversationMap
+		a.AdminDiscardWrongConversation = ac.AdminDiscardWrongConversation
+		a.LacpConversationDigestsUpdate()
 	}
 }
 
@@ -239,6 +265,11 @@ func DeleteLaAgg(Id int) {
 	var a *LaAggregator
 	if LaFindAggById(Id, &a) {
 
+		// aggregator can no longer be part of the Portal
+		if a.dr != nil {
+			DeleteDistributedRelay(a.dr.DrniName)
+		}
+
 		for _, pId := range a.PortNumList {
 			DeleteLaAggPort(pId)
 		}
@@ -249,6 +280,7 @@ func DeleteLaAgg(Id int) {
 func EnableLaAgg(Id int) {
 	var a *LaAggregator
 	if LaFindAggById(Id, &a) {
+		a.AdminState = true
 
 		for _, pId := range a.PortNumList {
 			EnableLaAggPort(pId)
@@ -259,6 +291,7 @@ func EnableLaAgg(Id int) {
 func DisableLaAgg(Id int) {
 	var a *LaAggregator
 	if LaFindAggById(Id, &a) {
+		a.AdminState = false
 
 		for _, pId := range a.PortNumList {
 			DisableLaAggPort(pId)
@@ -514,8 +547,9 @@ func SetLaAggHashMode(aggId int, hashmode uint32) {
 	var a *LaAggregator
 	if LaFindAggById(aggId, &a) {
 		a.LagHash = hashmode
-		if len(a.DistributedPortNumList) > 0 {
-			asicDUpdateLag(a)
+		if a.OperState {
+			err := LacpHwPluginGet().SetLagHash(a.HwAggId, a.lagHwConfigGet())
+			a.LacpDebug.logger.Info(fmt.Sprintf("SetLaAggHashMode: hwAggId %d hash %d err %v", a.HwAggId, hashmode, err))
 		} else {
 			a.LacpDebug.logger.Info("SetLaAggHashMode: Agg not active in HW")
 		}
@@ -524,6 +558,119 @@ func SetLaAggHashMode(aggId int, hashmode uint32) {
 	}
 }
 
+// SetLaAggVersion will set the version of the protocol run on all member
+// ports.  Version 2 enables the Conversation-sensitive Collection and
+// Distribution, negotiated down to Version 1 if the partner is Version 1
+func SetLaAggVersion(aggId int, version uint8) {
+	var a *LaAggregator
+	var p *LaAggPort
+	if LaFindAggById(aggId, &a) {
+		if version == 0 {
+			version = LacpVersion1
+		}
+		a.Version = version
+		for _, pId := range a.PortNumList {
+			if LaFindPortById(pId, &p) {
+				p.actorVersion = version
+				if version < LacpVersion2 {
+					p.enableLongPduXmit = false
+					if p.CsCdMachineFsm != nil {
+						p.CsCdMachineFsm.CsCdmEvents <- LacpMachineEvent{e: LacpCsCdmEventNotConvSensitive,
+							src: PortConfigModuleStr}
+					}
+				}
+				// let the partner know of the version change
+				if p.TxMachineFsm != nil {
+					p.TxMachineFsm.TxmEvents <- LacpMachineEvent{e: LacpTxmEventNtt,
+						src: PortConfigModuleStr}
+				}
+			}
+		}
+	} else {
+		fmt.Println("SetLaAggVersion: Unable to find aggId", aggId)
+	}
+}
+
+// SetLaAggConversationAdminLink will set the prioritized list of Link Number
+// ID's per conversation id, conversation ids not in the map are distributed
+// across the active links
+func SetLaAggConversationAdminLink(aggId int, convAdminLink map[uint16][]uint16) {
+	var a *LaAggregator
+	if LaFindAggById(aggId, &a) {
+		a.ConversationAdminLink = convAdminLink
+		a.LacpConversationDigestsUpdate()
+		a.LacpUpdateConversationPortList()
+	} else {
+		fmt.Println("SetLaAggConversationAdminLink: Unable to find aggId", aggId)
+	}
+}
+
+// SetLaAggDiscardWrongConversation will set the admin Discard Wrong Conversation
+func SetLaAggDiscardWrongConversation(aggId int, discard bool) {
+	var a *LaAggregator
+	if LaFindAggById(aggId, &a) {
+		a.AdminDiscardWrongConversation = discard
+		a.LacpUpdateConversationPortList()
+	} else {
+		fmt.Println("SetLaAggDiscardWrongConversation: Unable to find aggId", aggId)
+	}
+}
+
+// SetLaAggMinLinks will set the minimum number of distributing links
+// required for the aggregator to be operationally up
+func SetLaAggMinLinks(aggId int, minLinks uint16) {
+	var a *LaAggregator
+	if LaFindAggById(aggId, &a) {
+		a.AggMinLinks = minLinks
+		// ports are taken out of, or put back in, service by their
+		// mux machines
+		a.LacpAggMinLinksUpdate()
+		if !a.OperState {
+			a.LacpAggOperStateUpdate(LacpNotifyReasonNone)
+		}
+	} else {
+		fmt.Println("SetLaAggMinLinks: Unable to find aggId", aggId)
+	}
+}
+
+// SetLaAggMaxLinks will set the maximum number of active links, the
+// remaining selected ports are held in standby
+func SetLaAggMaxLinks(aggId int, maxLinks uint16) {
+	var a *LaAggregator
+	if LaFindAggById(aggId, &a) {
+		a.AggMaxLinks = maxLinks
+		a.LacpAggSelectStandby()
+	} else {
+		fmt.Println("SetLaAggMaxLinks: Unable to find aggId", aggId)
+	}
+}
+
+// SetLaAggLinkUpDownNotificationEnable will enable or disable the aggregator
+// oper state notifications, member notifications are always sent
+func SetLaAggLinkUpDownNotificationEnable(aggId int, ena bool) {
+	var a *LaAggregator
+	if LaFindAggById(aggId, &a) {
+		a.AggLinkUpDownNotificationEnable = ena
+	} else {
+		fmt.Println("SetLaAggLinkUpDownNotificationEnable: Unable to find aggId", aggId)
+	}
+}
+
+// SetLaAggFallback will set the fallback mode and timeout, takes effect
+// the next time a port enters the Defaulted state
+func SetLaAggFallback(aggId int, mode uint32, timeout time.Duration) {
+	var a *LaAggregator
+	if LaFindAggById(aggId, &a) {
+		a.FallbackMode = mode
+		a.FallbackTimeout = timeout
+		if a.FallbackTimeout == 0 {
+			a.FallbackTimeout = LacpFallbackDefaultTimeout
+		}
+	} else {
+		fmt.Println("SetLaAggFallback: Unable to find aggId", aggId)
+	}
+}
+
 func AddLaAggPortToAgg(Key uint16, pId uint16) {
 
 	var a *LaAggregator
@@ -539,6 +686,15 @@ func AddLaAggPortToAgg(Key uint16, pId uint16) {
 		a.PortNumList = append(a.PortNumList, p.PortNum)
 		// add reference to aggId
 		p.AggId = a.AggId
+		// run the version of the protocol configured on the agg
+		p.actorVersion = LacpVersion1
+		if a.Version != 0 {
+			p.actorVersion = a.Version
+		}
+		// ports of a Distributed Relay use the Portal System Id
+		if a.dr != nil {
+			a.dr.DrniPortActorInfoInit(p)
+		}
 
 		// attach the port to the aggregator
 		//LacpStateSet(&p.actorAdmin.State, LacpStateAggregationBit)
@@ -0,0 +1,323 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// conversation will handle the Version 2 Conversation ID to link assignment
+// 802.1ax-2014 Section 6.6
+package lacp
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// 802.1ax-2014 Section 6.6 Port Conversation ID 0-4095
+const LacpMaxConversationIds int = 4096
+
+// LacpConversationMaskBitSet will set the bit representing the conversation
+// id within a Port Conversation Mask
+func LacpConversationMaskBitSet(mask *[LacpConversationMaskSize]uint8, cid uint16) {
+	mask[cid/8] |= 1 << (cid % 8)
+}
+
+// LacpConversationMaskBitIsSet will check if the bit representing the conversation
+// id within a Port Conversation Mask is set
+func LacpConversationMaskBitIsSet(mask *[LacpConversationMaskSize]uint8, cid uint16) bool {
+	return mask[cid/8]&(1<<(cid%8)) != 0
+}
+
+// LaAggPortLinkNumberIdSort will sort ports by Link Number ID
+type LaAggPortLinkNumberIdSort []*LaAggPort
+
+func (s LaAggPortLinkNumberIdSort) Len() int           { return len(s) }
+func (s LaAggPortLinkNumberIdSort) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s LaAggPortLinkNumberIdSort) Less(i, j int) bool { return s[i].LinkNumberId < s[j].LinkNumberId }
+
+// LacpConversationDigestsUpdate will regenerate the conversation digests,
+// must be called whenever aAggConversationAdminLink or
+// aAggAdminServiceConversationMap change.  The digests are cached as they
+// are needed for every LACPDU transmitted and received
+func (a *LaAggregator) LacpConversationDigestsUpdate() {
+	a.convLinkListDigest = a.lacpConversationLinkListDigestGenerate()
+	a.convServiceMappingDigest = a.lacpConversationServiceMappingDigestGenerate()
+}
+
+// LacpConversationLinkListDigest will return the Actor_Conversation_LinkList_Digest
+func (a *LaAggregator) LacpConversationLinkListDigest() [16]uint8 {
+	return a.convLinkListDigest
+}
+
+// LacpConversationServiceMappingDigest will return the
+// Actor_Conversation_Service_Mapping_Digest
+func (a *LaAggregator) LacpConversationServiceMappingDigest() [16]uint8 {
+	return a.convServiceMappingDigest
+}
+
+// lacpConversationLinkListDigestGenerate will generate the Actor_Conversation_LinkList_Digest
+// 802.1ax-2014 Section 6.6.2.1.  Each conversation id link list is encoded in
+// conversation id order as a list of 16 bit Link Number ID's terminated by 0
+func (a *LaAggregator) lacpConversationLinkListDigestGenerate() (digest [16]uint8) {
+	buf := make([]byte, 0)
+	for cid := 0; cid < LacpMaxConversationIds; cid++ {
+		for _, link := range a.ConversationAdminLink[uint16(cid)] {
+			buf = append(buf, uint8(link>>8), uint8(link))
+		}
+		buf = append(buf, 0, 0)
+	}
+	digest = md5.Sum(buf)
+	return digest
+}
+
+// lacpConversationServiceMappingDigestGenerate will generate the
+// Actor_Conversation_Service_Mapping_Digest 802.1ax-2014 Section 6.6.2.1.
+// Each conversation id service list is encoded in conversation id order
+// as a list of 32 bit Service ID's terminated by 0
+func (a *LaAggregator) lacpConversationServiceMappingDigestGenerate() (digest [16]uint8) {
+	buf := make([]byte, 0)
+	sid := make([]byte, 4)
+	for cid := 0; cid < LacpMaxConversationIds; cid++ {
+		for _, s := range a.AdminServiceConversationMap[uint16(cid)] {
+			binary.BigEndian.PutUint32(sid, s)
+			buf = append(buf, sid...)
+		}
+		buf = append(buf, 0, 0, 0, 0)
+	}
+	digest = md5.Sum(buf)
+	return digest
+}
+
+// LacpUpdateConversationPortList will assign each conversation id to an active
+// link within the aggregator 802.1ax-2014 Section 6.6.2.4 updateConversationPortList.
+// If a conversation id has been assigned a prioritized list of Link Number ID's
+// via aAggConversationAdminLink then the first active link in the list is used,
+// otherwise the conversation ids are distributed across the active links in
+// Link Number ID order
+func (a *LaAggregator) LacpUpdateConversationPortList() {
+	activePorts := a.lacpConversationActivePorts()
+	a.operConversationPortList = a.lacpConversationPortListCompute(activePorts)
+	a.lacpConversationMasksUpdate()
+	a.LacpDebug.logger.Info(fmt.Sprintf("Conversation Port List updated agg %d active links %d", a.AggId, len(activePorts)))
+}
+
+// LacpConversationStopMoving will stop the conversations which are moving
+// to the joining port on their current link, 802.1ax-2014 Section 6.5.
+// The conversations are not distributed on any link until
+// LacpUpdateConversationPortList is called once the Marker Responses from
+// the current links have been received
+func (a *LaAggregator) LacpConversationStopMoving(joining *LaAggPort) {
+	next := a.lacpConversationPortListCompute(append(a.lacpConversationActivePorts(), joining))
+	for cid := 0; cid < LacpMaxConversationIds; cid++ {
+		if a.operConversationPortList[cid] != next[cid] {
+			a.operConversationPortList[cid] = 0
+		}
+	}
+	a.lacpConversationMasksUpdate()
+}
+
+// lacpConversationActivePorts returns the distributing ports
+func (a *LaAggregator) lacpConversationActivePorts() []*LaAggPort {
+	var p *LaAggPort
+
+	activePorts := make([]*LaAggPort, 0)
+	for _, pId := range a.PortNumList {
+		if LaFindPortById(pId, &p) {
+			for _, intf := range a.DistributedPortNumList {
+				if intf == p.IntfNum {
+					activePorts = append(activePorts, p)
+					break
+				}
+			}
+		}
+	}
+	return activePorts
+}
+
+// lacpConversationPortListCompute will assign each conversation id to one
+// of the active ports
+func (a *LaAggregator) lacpConversationPortListCompute(activePorts []*LaAggPort) (portList [LacpMaxConversationIds]uint16) {
+	sort.Sort(LaAggPortLinkNumberIdSort(activePorts))
+
+	for cid := 0; cid < LacpMaxConversationIds; cid++ {
+		// conversations carried by the neighbor Portal System are
+		// not distributed on the Home aggregation ports
+		if a.dr != nil &&
+			!a.dr.DrniPortConversationIsHome(uint16(cid)) {
+			continue
+		}
+		if links, ok := a.ConversationAdminLink[uint16(cid)]; ok && len(links) > 0 {
+			for _, link := range links {
+				for _, ap := range activePorts {
+					if ap.LinkNumberId == link {
+						portList[cid] = ap.PortNum
+						break
+					}
+				}
+				if portList[cid] != 0 {
+					break
+				}
+			}
+		} else if len(activePorts) > 0 {
+			portList[cid] = activePorts[cid%len(activePorts)].PortNum
+		}
+	}
+	return portList
+}
+
+// lacpConversationMasksUpdate will update each ports operational
+// conversation mask from the conversation assignment
+func (a *LaAggregator) lacpConversationMasksUpdate() {
+	var p *LaAggPort
+
+	for _, pId := range a.PortNumList {
+		if LaFindPortById(pId, &p) {
+			p.actorConversationMask = a.LacpConversationMaskGet(pId)
+			if p.CsCdMachineFsm != nil &&
+				p.actorVersion >= LacpVersion2 {
+				p.CsCdMachineFsm.LacpCsCdmUpdateMaskRequest()
+			}
+		}
+	}
+}
+
+// LacpConversationMaskGet will return the Port_Oper_Conversation_Mask for
+// the port from the current conversation assignment
+func (a *LaAggregator) LacpConversationMaskGet(pId uint16) (mask [LacpConversationMaskSize]uint8) {
+	for cid := 0; cid < LacpMaxConversationIds; cid++ {
+		if a.operConversationPortList[cid] == pId {
+			LacpConversationMaskBitSet(&mask, uint16(cid))
+		}
+	}
+	return mask
+}
+
+// LacpConversationPortGet will return the port which the conversation id
+// is assigned to, 0 if the conversation is not assigned to any link
+func (a *LaAggregator) LacpConversationPortGet(cid uint16) uint16 {
+	if int(cid) < LacpMaxConversationIds {
+		return a.operConversationPortList[cid]
+	}
+	return 0
+}
+
+// IsConversationSensitive is true when both actor and partner are running
+// Version 2 of the protocol
+func (p *LaAggPort) IsConversationSensitive() bool {
+	return p.actorVersion >= LacpVersion2 &&
+		p.partnerVersion >= LacpVersion2
+}
+
+// LacpConversationPasses 802.1ax-2014 Section 6.6.2.2 Port_Oper_Conversation_Mask
+// a frame belonging to the conversation may be transmitted on this port
+func (p *LaAggPort) LacpConversationPasses(cid uint16) bool {
+	return LacpStateIsSet(p.ActorOper.State, LacpStateDistributingBit) &&
+		p.conversationDistributes(cid)
+}
+
+// LacpConversationCollect 802.1ax-2014 Section 6.6.1 when Discard_Wrong_Conversation
+// is set frames received on a port which the conversation is not assigned
+// to are discarded
+func (p *LaAggPort) LacpConversationCollect(cid uint16) bool {
+	return LacpStateIsSet(p.ActorOper.State, LacpStateCollectingBit) &&
+		p.conversationCollects(cid)
+}
+
+func (p *LaAggPort) conversationDistributes(cid uint16) bool {
+	if !p.IsConversationSensitive() {
+		return true
+	}
+	return p.actParSync &&
+		LacpConversationMaskBitIsSet(&p.actorConversationMask, cid)
+}
+
+func (p *LaAggPort) conversationCollects(cid uint16) bool {
+	if !p.IsConversationSensitive() ||
+		!p.discardWrongConversation {
+		return true
+	}
+	return LacpConversationMaskBitIsSet(&p.actorConversationMask, cid)
+}
+
+// LacpConversationHwGet will return the conversations the port may distribute
+// and collect, whether the port is actually collecting/distributing is
+// reflected by the lag membership
+func (p *LaAggPort) LacpConversationHwGet() (conv LagHwPortConversations) {
+	for cid := uint16(0); int(cid) < LacpMaxConversationIds; cid++ {
+		if p.conversationDistributes(cid) {
+			LacpConversationMaskBitSet(&conv.DistributeMask, cid)
+		}
+		if p.conversationCollects(cid) {
+			LacpConversationMaskBitSet(&conv.CollectMask, cid)
+		}
+	}
+	return conv
+}
+
+// LacpConversationHwAllGet will return all conversations, used when the
+// port collects and distributes according to Version 1 rules
+func LacpConversationHwAllGet() (conv LagHwPortConversations) {
+	for i := 0; i < LacpConversationMaskSize; i++ {
+		conv.DistributeMask[i] = 0xff
+		conv.CollectMask[i] = 0xff
+	}
+	return conv
+}
+
+// LacpConversationHwUpdate will program the conversations the port may
+// distribute and collect via the lag hw plugin.  Nothing is programmed
+// while the lag does not exist in hw, a member added to the lag passes all
+// conversations until the next update
+func (p *LaAggPort) LacpConversationHwUpdate(conv LagHwPortConversations) {
+	a := p.AggAttached
+	if a == nil || !a.OperState {
+		return
+	}
+	hw := LacpHwPluginGet()
+	err := hw.SetLagPortConversations(a.HwAggId, a.lagHwConfigGet(), p.IntfNum, conv)
+	if err != nil {
+		p.LaPortLog(fmt.Sprintf("%s SetLagPortConversations : id %d port %s err %v", hw.Name(), a.HwAggId, p.IntfNum, err))
+	}
+}
+
+// LacpV2InfoGet will fill in the actor Version 2 TLV information
+func (p *LaAggPort) LacpV2InfoGet(long bool) LacpV2Info {
+	v2 := LacpV2Info{
+		LinkNumberId: p.LinkNumberId,
+		Long:         long,
+	}
+	if a := p.AggAttached; a != nil {
+		v2.PortAlgorithm = a.PortAlgorithm
+		v2.ConvLinkListDigest = a.LacpConversationLinkListDigest()
+		v2.ConvServiceMappingDigest = a.LacpConversationServiceMappingDigest()
+	}
+	if long {
+		if p.actParSync {
+			v2.MaskState |= LacpMaskStateActParSyncBit
+		}
+		if p.discardWrongConversation {
+			v2.MaskState |= LacpMaskStateDiscardWrongConversationBit
+		}
+		v2.ConvMask = p.actorConversationMask
+	}
+	return v2
+}
@@ -0,0 +1,329 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// CONVERSATION-SENSITIVE COLLECTION AND DISTRIBUTION MACHINE 802.1ax-2014 Section 6.6.2.7
+package lacp
+
+import (
+	"l2/clock"
+	"strconv"
+	"strings"
+	"time"
+	"utils/fsm"
+)
+
+const CsCdMachineModuleStr = "Conversation Sensitive Collection Distribution Machine"
+
+const (
+	LacpCsCdmStateNone = iota + 1
+	LacpCsCdmStateInitialize
+	LacpCsCdmStateUpdateMask
+	LacpCsCdmStateActorCDSChurn
+	LacpCsCdmStatePartnerCDSChurn
+)
+
+var CsCdmStateStrMap map[fsm.State]string
+
+func CsCdMachineStrStateMapCreate() {
+	if CsCdmStateStrMap == nil {
+		CsCdmStateStrMap = make(map[fsm.State]string)
+		CsCdmStateStrMap[LacpCsCdmStateNone] = "None"
+		CsCdmStateStrMap[LacpCsCdmStateInitialize] = "Initialize"
+		CsCdmStateStrMap[LacpCsCdmStateUpdateMask] = "UpdateMask"
+		CsCdmStateStrMap[LacpCsCdmStateActorCDSChurn] = "ActorCDSChurn"
+		CsCdmStateStrMap[LacpCsCdmStatePartnerCDSChurn] = "PartnerCDSChurn"
+	}
+}
+
+const (
+	LacpCsCdmEventBegin = iota + 1
+	LacpCsCdmEventUpdateMask
+	LacpCsCdmEventPartnerMaskRcvd
+	LacpCsCdmEventCDSChurnTimerExpired
+	LacpCsCdmEventNotConvSensitive
+)
+
+// LacpCsCdMachine holds FSM and current State
+// and event channels for State transitions
+type LacpCsCdMachine struct {
+	// for debugging
+	PreviousState fsm.State
+
+	Machine *fsm.Machine
+
+	p *LaAggPort
+
+	// debug log
+	log chan string
+
+	// timer intervals
+	cdsChurnTimerInterval time.Duration
+
+	// Interval timers
+	cdsChurnTimer        *clock.Timer
+	cdsChurnTimerRunning bool
+
+	// machine specific events
+	CsCdmEvents chan LacpMachineEvent
+	// mask updates come from the mux machine of any port in the
+	// aggregator, the channel is never closed so that a port being
+	// stopped does not need to be synchronized with the other ports
+	csCdmUpdateMaskEvent   chan bool
+	CsCdmKillSignalEvent   chan bool
+	CsCdmLogEnableEvent    chan bool
+	cdsChurnCountTimestamp time.Time
+}
+
+func (cscdm *LacpCsCdMachine) Stop() {
+	cscdm.CDSChurnDetectionTimerStop()
+
+	// stop the go routine
+	cscdm.CsCdmKillSignalEvent <- true
+
+	close(cscdm.CsCdmEvents)
+	close(cscdm.CsCdmKillSignalEvent)
+	close(cscdm.CsCdmLogEnableEvent)
+}
+
+// LacpCsCdmUpdateMaskRequest will request the machine to update the
+// conversation mask, the request is not sent when one is already pending
+// as the pending update will pick up the latest mask
+func (cscdm *LacpCsCdMachine) LacpCsCdmUpdateMaskRequest() {
+	select {
+	case cscdm.csCdmUpdateMaskEvent <- true:
+	default:
+	}
+}
+
+func (cscdm *LacpCsCdMachine) PrevState() fsm.State { return cscdm.PreviousState }
+
+// PrevStateSet will set the previous State
+func (cscdm *LacpCsCdMachine) PrevStateSet(s fsm.State) { cscdm.PreviousState = s }
+
+// NewLacpCsCdMachine will create a new instance of the LacpCsCdMachine
+func NewLacpCsCdMachine(port *LaAggPort) *LacpCsCdMachine {
+	cscdm := &LacpCsCdMachine{
+		p:                     port,
+		log:                   port.LacpDebug.LacpLogChan,
+		PreviousState:         LacpCsCdmStateNone,
+		cdsChurnTimerInterval: LacpChurnDetectionTime,
+		CsCdmEvents:           make(chan LacpMachineEvent, 10),
+		csCdmUpdateMaskEvent:  make(chan bool, 1),
+		CsCdmKillSignalEvent:  make(chan bool),
+		CsCdmLogEnableEvent:   make(chan bool)}
+
+	port.CsCdMachineFsm = cscdm
+	cscdm.CDSChurnDetectionTimerStart()
+	cscdm.CDSChurnDetectionTimerStop()
+	return cscdm
+}
+
+// A helpful function that lets us apply arbitrary rulesets to this
+// instances State machine without reallocating the machine.
+func (cscdm *LacpCsCdMachine) Apply(r *fsm.Ruleset) *fsm.Machine {
+	if cscdm.Machine == nil {
+		cscdm.Machine = &fsm.Machine{}
+	}
+
+	// Assign the ruleset to be used for this machine
+	cscdm.Machine.Rules = r
+	cscdm.Machine.Curr = &LacpStateEvent{
+		strStateMap: CsCdmStateStrMap,
+		logEna:      cscdm.p.logEna,
+		logger:      cscdm.LacpCsCdmLog,
+		owner:       CsCdMachineModuleStr,
+	}
+
+	return cscdm.Machine
+}
+
+// LacpCsCdMachineInitialize will clear the conversation mask, port is
+// no longer conversation sensitive and will collect and distribute
+// according to Version 1 rules
+func (cscdm *LacpCsCdMachine) LacpCsCdMachineInitialize(m fsm.Machine, data interface{}) fsm.State {
+	p := cscdm.p
+	p.actParSync = false
+	p.discardWrongConversation = false
+	p.actorConversationMask = [LacpConversationMaskSize]uint8{}
+	p.partnerV2Oper = LacpV2Info{}
+	cscdm.CDSChurnDetectionTimerStop()
+	p.LacpConversationHwUpdate(LacpConversationHwAllGet())
+	return LacpCsCdmStateInitialize
+}
+
+// LacpCsCdMachineUpdateMask will compare the actor and partner conversation
+// info.  If the digests agree both sides have made the same conversation
+// assignment, otherwise the partners Port Conversation Mask must match
+// the actors mask.  Until they match the CDS churn timer runs.
+func (cscdm *LacpCsCdMachine) LacpCsCdMachineUpdateMask(m fsm.Machine, data interface{}) fsm.State {
+	p := cscdm.p
+	prevActParSync := p.actParSync
+
+	if a := p.AggAttached; a != nil {
+		p.actorConversationMask = a.LacpConversationMaskGet(p.PortNum)
+		p.discardWrongConversation = a.AdminDiscardWrongConversation
+	}
+
+	if !p.differPortConversationDigest &&
+		!p.differConvServiceDigests &&
+		!p.differPortAlgorithms {
+		p.actParSync = true
+	} else if p.partnerV2Oper.Long {
+		p.actParSync = p.partnerV2Oper.ConvMask == p.actorConversationMask
+	} else {
+		p.actParSync = false
+	}
+
+	p.LacpConversationHwUpdate(p.LacpConversationHwGet())
+
+	if p.actParSync {
+		cscdm.CDSChurnDetectionTimerStop()
+	} else if !cscdm.cdsChurnTimerRunning {
+		cscdm.CDSChurnDetectionTimerStart()
+	}
+
+	// let the partner know of the mask change
+	if prevActParSync != p.actParSync &&
+		p.TxMachineFsm != nil {
+		p.TxMachineFsm.TxmEvents <- LacpMachineEvent{e: LacpTxmEventNtt,
+			src: CsCdMachineModuleStr}
+	}
+
+	return LacpCsCdmStateUpdateMask
+}
+
+// LacpCsCdMachineCDSChurn the actor and partner have failed to agree on
+// the conversation assignment within the churn detection time
+func (cscdm *LacpCsCdMachine) LacpCsCdMachineCDSChurn(m fsm.Machine, data interface{}) fsm.State {
+	p := cscdm.p
+	nextState := fsm.State(LacpCsCdmStateActorCDSChurn)
+
+	// partner believes it is in sync thus the actor is the one churning
+	if p.partnerV2Oper.MaskState&LacpMaskStateActParSyncBit == 0 {
+		nextState = LacpCsCdmStatePartnerCDSChurn
+	}
+
+	if LacpClockGet().Now().Sub(cscdm.cdsChurnCountTimestamp) > time.Second*5 {
+		if nextState == LacpCsCdmStateActorCDSChurn {
+			p.AggPortDebug.AggPortDebugActorCDSChurnCount++
+		} else {
+			p.AggPortDebug.AggPortDebugPartnerCDSChurnCount++
+		}
+		cscdm.cdsChurnCountTimestamp = LacpClockGet().Now()
+	}
+	cscdm.CDSChurnDetectionTimerStop()
+	return nextState
+}
+
+func LacpCsCdMachineFSMBuild(p *LaAggPort) *LacpCsCdMachine {
+
+	rules := fsm.Ruleset{}
+
+	CsCdMachineStrStateMapCreate()
+
+	// Instantiate a new LacpCsCdMachine
+	// Initial State will be a psuedo State known as "begin" so that
+	// we can transition to the initalize State
+	cscdm := NewLacpCsCdMachine(p)
+
+	allStates := []fsm.State{LacpCsCdmStateNone,
+		LacpCsCdmStateInitialize,
+		LacpCsCdmStateUpdateMask,
+		LacpCsCdmStateActorCDSChurn,
+		LacpCsCdmStatePartnerCDSChurn,
+	}
+
+	for _, s := range allStates {
+		// BEGIN -> INITIALIZE
+		rules.AddRule(s, LacpCsCdmEventBegin, cscdm.LacpCsCdMachineInitialize)
+		if s != LacpCsCdmStateNone {
+			// NOT CONVERSATION SENSITIVE -> INITIALIZE
+			rules.AddRule(s, LacpCsCdmEventNotConvSensitive, cscdm.LacpCsCdMachineInitialize)
+			// MASK UPDATED -> UPDATE MASK
+			rules.AddRule(s, LacpCsCdmEventUpdateMask, cscdm.LacpCsCdMachineUpdateMask)
+			// PARTNER MASK RECEIVED -> UPDATE MASK
+			rules.AddRule(s, LacpCsCdmEventPartnerMaskRcvd, cscdm.LacpCsCdMachineUpdateMask)
+		}
+	}
+
+	// TIMEOUT -> CDS CHURN
+	rules.AddRule(LacpCsCdmStateUpdateMask, LacpCsCdmEventCDSChurnTimerExpired, cscdm.LacpCsCdMachineCDSChurn)
+
+	// Create a new FSM and apply the rules
+	cscdm.Apply(&rules)
+
+	return cscdm
+}
+
+// LacpCsCdMachineMain:  802.1ax-2014
+// Creation of Conversation-sensitive Collection and Distribution State Machine
+// State transitions and callbacks and create go routine to pend on events
+func (p *LaAggPort) LacpCsCdMachineMain() {
+
+	// Build the State machine for Conversation-sensitive Collection and
+	// Distribution according to 802.1ax Section 6.6.2.7
+	cscdm := LacpCsCdMachineFSMBuild(p)
+
+	// set the inital State
+	cscdm.Machine.Start(cscdm.PrevState())
+
+	// lets create a go routing which will wait for the specific events
+	// that the CsCdMachine should handle.
+	go func(m *LacpCsCdMachine) {
+		m.LacpCsCdmLog("Machine Start")
+		defer m.p.wg.Done()
+		for {
+			m.p.AggPortDebug.AggPortDebugActorCDSChurnState = int(m.Machine.Curr.CurrentState())
+			m.p.AggPortDebug.AggPortDebugPartnerCDSChurnState = int(m.Machine.Curr.CurrentState())
+			select {
+			case <-m.CsCdmKillSignalEvent:
+				m.LacpCsCdmLog("Machine End")
+				return
+
+			case <-m.cdsChurnTimer.C:
+				rv := m.Machine.ProcessEvent(CsCdMachineModuleStr, LacpCsCdmEventCDSChurnTimerExpired, nil)
+				if rv != nil {
+					m.LacpCsCdmLog(strings.Join([]string{error.Error(rv), CsCdMachineModuleStr, CsCdmStateStrMap[m.Machine.Curr.CurrentState()], strconv.Itoa(int(LacpCsCdmEventCDSChurnTimerExpired))}, ":"))
+				}
+			case event := <-m.CsCdmEvents:
+
+				rv := m.Machine.ProcessEvent(event.src, event.e, nil)
+
+				if rv != nil {
+					m.LacpCsCdmLog(strings.Join([]string{error.Error(rv), event.src, CsCdmStateStrMap[m.Machine.Curr.CurrentState()], strconv.Itoa(int(event.e))}, ":"))
+				}
+
+				if event.responseChan != nil {
+					SendResponse(CsCdMachineModuleStr, event.responseChan)
+				}
+			case <-m.csCdmUpdateMaskEvent:
+				rv := m.Machine.ProcessEvent(MuxMachineModuleStr, LacpCsCdmEventUpdateMask, nil)
+				if rv != nil {
+					m.LacpCsCdmLog(strings.Join([]string{error.Error(rv), MuxMachineModuleStr, CsCdmStateStrMap[m.Machine.Curr.CurrentState()], strconv.Itoa(int(LacpCsCdmEventUpdateMask))}, ":"))
+				}
+			case ena := <-m.CsCdmLogEnableEvent:
+				m.Machine.Curr.EnableLogging(ena)
+			}
+		}
+	}(cscdm)
+}
@@ -91,6 +91,26 @@ func (a *LaAggregator) LacpDebugAggEventLogMain() {
 	}(a)
 }
 
+func (ipp *DRCPIpp) DrcpDebugIppEventLogMain() {
+
+	ipp.LacpDebug = NewLacpDebug()
+
+	go func(ipp *DRCPIpp) {
+
+		for {
+			select {
+
+			case msg, logEvent := <-ipp.LacpDebug.LacpLogChan:
+				if logEvent {
+					ipp.LacpDebug.logger.Info(strings.Join([]string{ipp.Intf, msg}, "-"))
+				} else {
+					return
+				}
+			}
+		}
+	}(ipp)
+}
+
 func (a *LaAggregator) LacpAggLog(msg string) {
 	a.log <- strings.Join([]string{"AGG", time.Now().String(), msg}, ":")
 }
@@ -113,6 +133,12 @@ func (cdm *LacpPartnerCdMachine) LacpCdmLog(msg string) {
 	}
 }
 
+func (cscdm *LacpCsCdMachine) LacpCsCdmLog(msg string) {
+	if cscdm.Machine.Curr.IsLoggerEna() {
+		cscdm.log <- strings.Join([]string{"CSCDM", time.Now().String(), msg}, ":")
+	}
+}
+
 func (ptxm *LacpPtxMachine) LacpPtxmLog(msg string) {
 	if ptxm.Machine.Curr.IsLoggerEna() {
 		ptxm.log <- strings.Join([]string{"PTXM", time.Now().String(), msg}, ":")
@@ -136,3 +162,21 @@ func (mr *LampMarkerResponderMachine) LampMarkerResponderLog(msg string) {
 		mr.log <- strings.Join([]string{"MARKER RESPONDER", time.Now().String(), msg}, ":")
 	}
 }
+
+func (mg *LampMarkerGeneratorMachine) LampMarkerGeneratorLog(msg string) {
+	if mg.Machine.Curr.IsLoggerEna() {
+		mg.log <- strings.Join([]string{"MARKER GENERATOR", time.Now().String(), msg}, ":")
+	}
+}
+
+func (rxm *DrcpRxMachine) DrcpRxmLog(msg string) {
+	if rxm.Machine.Curr.IsLoggerEna() {
+		rxm.log <- strings.Join([]string{"DRCP RXM", time.Now().String(), msg}, ":")
+	}
+}
+
+func (ptxm *DrcpPtxMachine) DrcpPtxmLog(msg string) {
+	if ptxm.Machine.Curr.IsLoggerEna() {
+		ptxm.log <- strings.Join([]string{"DRCP PTXM", time.Now().String(), msg}, ":")
+	}
+}
@@ -52,11 +52,20 @@ const LacpLongTimeoutTime time.Duration = (time.Second * 90)
 // wait for the Actor or Partner Sync State to stabilize
 const LacpChurnDetectionTime time.Duration = (time.Second * 60)
 
+// number of seconds a Defaulted port waits for an LACPDU before
+// falling back to individual operation
+const LacpFallbackDefaultTimeout time.Duration = (time.Second * 60)
+
 // number of seconds to delay aggregation to allow multiple links to
 // aggregate simultaneously
 const LacpAggregateWaitTime time.Duration = (time.Second * 2)
 
-// the version number of the Actor LACP implementation
+// number of seconds the Marker Generator will wait for a Marker Response
+// before moving conversations to a new link
+const LampMarkerResponseTimeout time.Duration = (time.Second * 1)
+
+// the version number of the Actor LACP implementation, Version 2 is
+// enabled per aggregator see LaAggConfig Version
 const LacpActorSystemLacpVersion int = 0x01
 
 const LacpPortDuplexFull int = 1
@@ -103,6 +112,18 @@ const (
 	LacpModePassive
 )
 
+// LACP fallback modes, used when the partner never sends an LACPDU
+// (i.e. a server PXE booting without a bonding driver)
+const (
+	// ports stay down until an LACPDU is received
+	LacpFallbackModeNone = iota
+	// a single port comes up as an individual link
+	LacpFallbackModeStatic
+	// all ports are detached from the lag and come up as non aggregated
+	// links
+	LacpFallbackModeIndividual
+)
+
 // LacpMachineEvent machine events will be sent
 // with this struct and will provide extra data
 // in order to provide async communication between
@@ -0,0 +1,311 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// drcpdu will encode/decode the Distributed Relay Control Protocol PDU
+// 802.1ax-2014 Section 9.4.3.  gopacket does not know about DRCP so the
+// PDU is carried as the payload of the ethernet frame
+package lacp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/google/gopacket/layers"
+	"net"
+)
+
+// 802.1ax-2014 Section 9.4.3.1 DRCPDU addressing and protocol identification
+const DrcpEthernetType layers.EthernetType = 0x8952
+
+// Nearest non-TPMR Bridge group address
+var DrcpNearestNonTPMRBridgeDMAC = net.HardwareAddr{0x01, 0x80, 0xC2, 0x00, 0x00, 0x03}
+
+const (
+	DrcpSubType uint8 = 0x01
+	DrcpVersion uint8 = 0x01
+)
+
+// 802.1ax-2014 Section 9.4.3.2 TLV types
+const (
+	DrcpTLVTypeTerminator            uint8 = 0x00
+	DrcpTLVTypePortalInfo            uint8 = 0x01
+	DrcpTLVTypePortalConfigInfo      uint8 = 0x02
+	DrcpTLVTypeDRCPState             uint8 = 0x03
+	DrcpTLVTypeHomePortsInfo         uint8 = 0x04
+	DrcpTLVTypeNeighborPortsInfo     uint8 = 0x05
+	DrcpTLVTypeOtherPortsInfo        uint8 = 0x06
+	DrcpTLVTypeHomeGatewayVector     uint8 = 0x07
+	DrcpTLVTypeNeighborGatewayVector uint8 = 0x08
+)
+
+// TLV lengths include the 2 octet type/length header
+const (
+	DrcpTLVPortalInfoLength            = 18
+	DrcpTLVPortalConfigInfoLength      = 45
+	DrcpTLVDRCPStateLength             = 3
+	DrcpTLVPortsInfoMinLength          = 6
+	DrcpTLVHomeGatewayVectorLength     = 6 + LacpConversationMaskSize
+	DrcpTLVNeighborGatewayVectorLength = 6
+)
+
+// 802.1ax-2014 Section 9.4.3.2 Topology_State
+const (
+	DrcpTopologyStatePortalSystemNumberMask        = 0x03
+	DrcpTopologyStateNeighborConfPortalSystemShift = 2
+	DrcpTopologyStateNeighborConfPortalSystemMask  = 0x0C
+	DrcpTopologyState3SystemPortalBit              = 1 << 4
+	DrcpTopologyStateCommonMethodsBit              = 1 << 5
+	DrcpTopologyStateOtherNonNeighborBit           = 1 << 7
+)
+
+// 802.1ax-2014 Section 9.4.3.2 DRCP_State
+const (
+	DrcpStateHomeGatewayBit = 1 << iota
+	DrcpStateNeighborGatewayBit
+	DrcpStateOtherGatewayBit
+	DrcpStateIppActivityBit
+	DrcpStateDRCPTimeoutBit
+	DrcpStateGatewaySyncBit
+	DrcpStatePortSyncBit
+	DrcpStateExpiredBit
+)
+
+// DrcpPortalInfoTlv Portal Information TLV
+type DrcpPortalInfoTlv struct {
+	AggPriority    uint16
+	AggId          [6]uint8
+	PortalPriority uint16
+	PortalAddr     [6]uint8
+}
+
+// DrcpPortalConfigInfoTlv Portal Configuration Information TLV
+type DrcpPortalConfigInfoTlv struct {
+	TopologyState    uint8
+	OperAggKey       uint16
+	PortAlgorithm    uint32
+	GatewayAlgorithm uint32
+	PortDigest       [16]uint8
+	GatewayDigest    [16]uint8
+}
+
+// DrcpPortsInfoTlv Home/Neighbor Ports Information TLV
+type DrcpPortsInfoTlv struct {
+	AdminAggKey       uint16
+	OperPartnerAggKey uint16
+	ActivePorts       []uint32
+}
+
+// DrcpHomeGatewayVectorTlv Home Gateway Vector TLV, each bit represents
+// a Gateway Conversation ID which passes through the Home Gateway
+type DrcpHomeGatewayVectorTlv struct {
+	Sequence uint32
+	Vector   [LacpConversationMaskSize]uint8
+}
+
+// DrcpNeighborGatewayVectorTlv Neighbor Gateway Vector TLV
+type DrcpNeighborGatewayVectorTlv struct {
+	Sequence uint32
+}
+
+// DRCPDU 802.1ax-2014 Section 9.4.3.2
+type DRCPDU struct {
+	Version               uint8
+	PortalInfo            DrcpPortalInfoTlv
+	PortalConfigInfo      DrcpPortalConfigInfoTlv
+	State                 uint8
+	HomePortsInfo         DrcpPortsInfoTlv
+	NeighborPortsInfo     DrcpPortsInfoTlv
+	HomeGatewayVector     DrcpHomeGatewayVectorTlv
+	NeighborGatewayVector DrcpNeighborGatewayVectorTlv
+}
+
+// drcpTlvHeader TLV_type is 6 bits followed by a 10 bit TLV_length
+func drcpTlvHeader(tlvType uint8, length int) []byte {
+	hdr := make([]byte, 2)
+	binary.BigEndian.PutUint16(hdr, uint16(tlvType)<<10|uint16(length&0x3ff))
+	return hdr
+}
+
+func drcpPortsInfoEncode(tlvType uint8, info *DrcpPortsInfoTlv) []byte {
+	data := drcpTlvHeader(tlvType, DrcpTLVPortsInfoMinLength+4*len(info.ActivePorts))
+	val := make([]byte, 4)
+	binary.BigEndian.PutUint16(val, info.AdminAggKey)
+	binary.BigEndian.PutUint16(val[2:], info.OperPartnerAggKey)
+	data = append(data, val...)
+	for _, port := range info.ActivePorts {
+		binary.BigEndian.PutUint32(val, port)
+		data = append(data, val...)
+	}
+	return data
+}
+
+// DRCPDUEncode will encode the DRCPDU starting at the subtype
+func DRCPDUEncode(pdu *DRCPDU) []byte {
+	data := []byte{DrcpSubType, pdu.Version}
+
+	// Portal Information
+	data = append(data, drcpTlvHeader(DrcpTLVTypePortalInfo, DrcpTLVPortalInfoLength)...)
+	val := make([]byte, DrcpTLVPortalInfoLength-2)
+	binary.BigEndian.PutUint16(val, pdu.PortalInfo.AggPriority)
+	copy(val[2:8], pdu.PortalInfo.AggId[:])
+	binary.BigEndian.PutUint16(val[8:], pdu.PortalInfo.PortalPriority)
+	copy(val[10:16], pdu.PortalInfo.PortalAddr[:])
+	data = append(data, val...)
+
+	// Portal Configuration Information
+	data = append(data, drcpTlvHeader(DrcpTLVTypePortalConfigInfo, DrcpTLVPortalConfigInfoLength)...)
+	val = make([]byte, DrcpTLVPortalConfigInfoLength-2)
+	val[0] = pdu.PortalConfigInfo.TopologyState
+	binary.BigEndian.PutUint16(val[1:], pdu.PortalConfigInfo.OperAggKey)
+	binary.BigEndian.PutUint32(val[3:], pdu.PortalConfigInfo.PortAlgorithm)
+	binary.BigEndian.PutUint32(val[7:], pdu.PortalConfigInfo.GatewayAlgorithm)
+	copy(val[11:27], pdu.PortalConfigInfo.PortDigest[:])
+	copy(val[27:43], pdu.PortalConfigInfo.GatewayDigest[:])
+	data = append(data, val...)
+
+	// DRCP State
+	data = append(data, drcpTlvHeader(DrcpTLVTypeDRCPState, DrcpTLVDRCPStateLength)...)
+	data = append(data, pdu.State)
+
+	// Home/Neighbor Ports Information
+	data = append(data, drcpPortsInfoEncode(DrcpTLVTypeHomePortsInfo, &pdu.HomePortsInfo)...)
+	data = append(data, drcpPortsInfoEncode(DrcpTLVTypeNeighborPortsInfo, &pdu.NeighborPortsInfo)...)
+
+	// Home Gateway Vector
+	data = append(data, drcpTlvHeader(DrcpTLVTypeHomeGatewayVector, DrcpTLVHomeGatewayVectorLength)...)
+	val = make([]byte, 4)
+	binary.BigEndian.PutUint32(val, pdu.HomeGatewayVector.Sequence)
+	data = append(data, val...)
+	data = append(data, pdu.HomeGatewayVector.Vector[:]...)
+
+	// Neighbor Gateway Vector
+	data = append(data, drcpTlvHeader(DrcpTLVTypeNeighborGatewayVector, DrcpTLVNeighborGatewayVectorLength)...)
+	val = make([]byte, 4)
+	binary.BigEndian.PutUint32(val, pdu.NeighborGatewayVector.Sequence)
+	data = append(data, val...)
+
+	// Terminator
+	data = append(data, drcpTlvHeader(DrcpTLVTypeTerminator, 0)...)
+	return data
+}
+
+func drcpPortsInfoDecode(val []byte, info *DrcpPortsInfoTlv) error {
+	if len(val) < DrcpTLVPortsInfoMinLength-2 ||
+		(len(val)-(DrcpTLVPortsInfoMinLength-2))%4 != 0 {
+		return errors.New(fmt.Sprintf("DRCPDU invalid Ports Information TLV length %d", len(val)+2))
+	}
+	info.AdminAggKey = binary.BigEndian.Uint16(val)
+	info.OperPartnerAggKey = binary.BigEndian.Uint16(val[2:])
+	info.ActivePorts = make([]uint32, 0)
+	for i := DrcpTLVPortsInfoMinLength - 2; i < len(val); i += 4 {
+		info.ActivePorts = append(info.ActivePorts, binary.BigEndian.Uint32(val[i:]))
+	}
+	return nil
+}
+
+// DRCPDUDecode will decode the DRCPDU, data should start at the subtype.
+// Unknown TLV's are skipped
+func DRCPDUDecode(data []byte, pdu *DRCPDU) error {
+	if len(data) < 2 {
+		return errors.New(fmt.Sprintf("DRCPDU too short %d", len(data)))
+	}
+	if data[0] != DrcpSubType {
+		return errors.New(fmt.Sprintf("DRCPDU invalid subtype %d", data[0]))
+	}
+	pdu.Version = data[1]
+
+	// TLV's which must be present
+	rcvd := make(map[uint8]bool)
+
+	offset := 2
+	for offset+2 <= len(data) {
+		hdr := binary.BigEndian.Uint16(data[offset:])
+		tlvType := uint8(hdr >> 10)
+		length := int(hdr & 0x3ff)
+		if tlvType == DrcpTLVTypeTerminator {
+			break
+		}
+		if length < 2 || offset+length > len(data) {
+			return errors.New(fmt.Sprintf("DRCPDU invalid TLV %d length %d", tlvType, length))
+		}
+		val := data[offset+2 : offset+length]
+
+		switch tlvType {
+		case DrcpTLVTypePortalInfo:
+			if length != DrcpTLVPortalInfoLength {
+				return errors.New(fmt.Sprintf("DRCPDU invalid Portal Information TLV length %d", length))
+			}
+			pdu.PortalInfo.AggPriority = binary.BigEndian.Uint16(val)
+			copy(pdu.PortalInfo.AggId[:], val[2:8])
+			pdu.PortalInfo.PortalPriority = binary.BigEndian.Uint16(val[8:])
+			copy(pdu.PortalInfo.PortalAddr[:], val[10:16])
+		case DrcpTLVTypePortalConfigInfo:
+			if length != DrcpTLVPortalConfigInfoLength {
+				return errors.New(fmt.Sprintf("DRCPDU invalid Portal Configuration TLV length %d", length))
+			}
+			pdu.PortalConfigInfo.TopologyState = val[0]
+			pdu.PortalConfigInfo.OperAggKey = binary.BigEndian.Uint16(val[1:])
+			pdu.PortalConfigInfo.PortAlgorithm = binary.BigEndian.Uint32(val[3:])
+			pdu.PortalConfigInfo.GatewayAlgorithm = binary.BigEndian.Uint32(val[7:])
+			copy(pdu.PortalConfigInfo.PortDigest[:], val[11:27])
+			copy(pdu.PortalConfigInfo.GatewayDigest[:], val[27:43])
+		case DrcpTLVTypeDRCPState:
+			if length != DrcpTLVDRCPStateLength {
+				return errors.New(fmt.Sprintf("DRCPDU invalid DRCP State TLV length %d", length))
+			}
+			pdu.State = val[0]
+		case DrcpTLVTypeHomePortsInfo:
+			if err := drcpPortsInfoDecode(val, &pdu.HomePortsInfo); err != nil {
+				return err
+			}
+		case DrcpTLVTypeNeighborPortsInfo:
+			if err := drcpPortsInfoDecode(val, &pdu.NeighborPortsInfo); err != nil {
+				return err
+			}
+		case DrcpTLVTypeHomeGatewayVector:
+			if length != DrcpTLVHomeGatewayVectorLength {
+				return errors.New(fmt.Sprintf("DRCPDU invalid Home Gateway Vector TLV length %d", length))
+			}
+			pdu.HomeGatewayVector.Sequence = binary.BigEndian.Uint32(val)
+			copy(pdu.HomeGatewayVector.Vector[:], val[4:])
+		case DrcpTLVTypeNeighborGatewayVector:
+			if length != DrcpTLVNeighborGatewayVectorLength {
+				return errors.New(fmt.Sprintf("DRCPDU invalid Neighbor Gateway Vector TLV length %d", length))
+			}
+			pdu.NeighborGatewayVector.Sequence = binary.BigEndian.Uint32(val)
+		}
+		rcvd[tlvType] = true
+		offset += length
+	}
+
+	for _, tlvType := range []uint8{DrcpTLVTypePortalInfo,
+		DrcpTLVTypePortalConfigInfo,
+		DrcpTLVTypeDRCPState,
+		DrcpTLVTypeHomePortsInfo,
+		DrcpTLVTypeNeighborPortsInfo} {
+		if !rcvd[tlvType] {
+			return errors.New(fmt.Sprintf("DRCPDU missing TLV %d", tlvType))
+		}
+	}
+	return nil
+}
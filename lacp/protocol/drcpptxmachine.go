@@ -0,0 +1,273 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// drcpptxmachine.go
+// DRCP Periodic Transmission machine 802.1ax-2014 Section 9.4.15.  The
+// DRCPDU Transmit machine 9.4.19 is folded into this machine, a DRCPDU
+// is transmitted on each periodic timer expiry or whenever NTT is set.
+package lacp
+
+import (
+	"l2/clock"
+	"strconv"
+	"strings"
+	"time"
+	"utils/fsm"
+)
+
+const DrcpPtxMachineModuleStr = "DRCP PTX Machine"
+
+// drcp ptxm States
+const (
+	DrcpPtxmStateNone = iota + 1
+	DrcpPtxmStateNoPeriodic
+	DrcpPtxmStateFastPeriodic
+	DrcpPtxmStateSlowPeriodic
+)
+
+var DrcpPtxmStateStrMap map[fsm.State]string
+
+func DrcpPtxMachineStrStateMapCreate() {
+	DrcpPtxmStateStrMap = make(map[fsm.State]string)
+	DrcpPtxmStateStrMap[DrcpPtxmStateNone] = "None"
+	DrcpPtxmStateStrMap[DrcpPtxmStateNoPeriodic] = "NoPeriodic"
+	DrcpPtxmStateStrMap[DrcpPtxmStateFastPeriodic] = "FastPeriodic"
+	DrcpPtxmStateStrMap[DrcpPtxmStateSlowPeriodic] = "SlowPeriodic"
+}
+
+// drcp ptxm events
+const (
+	DrcpPtxmEventBegin = iota + 1
+	DrcpPtxmEventIppEnabled
+	DrcpPtxmEventIppDisabled
+	DrcpPtxmEventPeriodicTimerExpired
+	DrcpPtxmEventNtt
+)
+
+// DrcpPtxMachine holds FSM and current State
+// and event channels for State transitions
+type DrcpPtxMachine struct {
+	// for debugging
+	PreviousState fsm.State
+
+	Machine *fsm.Machine
+
+	ipp *DRCPIpp
+
+	// debug log
+	log chan string
+
+	// timer interval
+	PeriodicTxTimerInterval time.Duration
+
+	// timers
+	periodicTxTimer *clock.Timer
+
+	// machine specific events
+	DrcpPtxmEvents          chan LacpMachineEvent
+	DrcpPtxmKillSignalEvent chan bool
+	DrcpPtxmLogEnableEvent  chan bool
+}
+
+func (ptxm *DrcpPtxMachine) PrevState() fsm.State { return ptxm.PreviousState }
+
+// PrevStateSet will set the previous State
+func (ptxm *DrcpPtxMachine) PrevStateSet(s fsm.State) { ptxm.PreviousState = s }
+
+// Stop should clean up all resources
+func (ptxm *DrcpPtxMachine) Stop() {
+	ptxm.PeriodicTimerStop()
+
+	// stop the go routine
+	ptxm.DrcpPtxmKillSignalEvent <- true
+
+	close(ptxm.DrcpPtxmEvents)
+	close(ptxm.DrcpPtxmKillSignalEvent)
+	close(ptxm.DrcpPtxmLogEnableEvent)
+}
+
+// NewDrcpPtxMachine will create a new instance of the DrcpPtxMachine
+func NewDrcpPtxMachine(ipp *DRCPIpp) *DrcpPtxMachine {
+	ptxm := &DrcpPtxMachine{
+		ipp:                     ipp,
+		log:                     ipp.LacpDebug.LacpLogChan,
+		PreviousState:           DrcpPtxmStateNone,
+		PeriodicTxTimerInterval: LacpFastPeriodicTime,
+		DrcpPtxmEvents:          make(chan LacpMachineEvent, 10),
+		DrcpPtxmKillSignalEvent: make(chan bool),
+		DrcpPtxmLogEnableEvent:  make(chan bool)}
+
+	ipp.PtxMachineFsm = ptxm
+
+	// create then stop
+	ptxm.PeriodicTimerStart()
+	ptxm.PeriodicTimerStop()
+
+	return ptxm
+}
+
+// A helpful function that lets us apply arbitrary rulesets to this
+// instances State machine without reallocating the machine.
+func (ptxm *DrcpPtxMachine) Apply(r *fsm.Ruleset) *fsm.Machine {
+	if ptxm.Machine == nil {
+		ptxm.Machine = &fsm.Machine{}
+	}
+
+	// Assign the ruleset to be used for this machine
+	ptxm.Machine.Rules = r
+	ptxm.Machine.Curr = &LacpStateEvent{
+		strStateMap: DrcpPtxmStateStrMap,
+		logEna:      ptxm.ipp.logEna,
+		logger:      ptxm.DrcpPtxmLog,
+		owner:       DrcpPtxMachineModuleStr,
+	}
+
+	return ptxm.Machine
+}
+
+// DrcpPtxMachineNoPeriodic function to be called after
+// State transition to NO_PERIODIC
+func (ptxm *DrcpPtxMachine) DrcpPtxMachineNoPeriodic(m fsm.Machine, data interface{}) fsm.State {
+	ptxm.PeriodicTimerStop()
+	return DrcpPtxmStateNoPeriodic
+}
+
+// DrcpPtxMachineFastPeriodic function to be called after
+// State transition to FAST_PERIODIC
+func (ptxm *DrcpPtxMachine) DrcpPtxMachineFastPeriodic(m fsm.Machine, data interface{}) fsm.State {
+	ptxm.PeriodicTimerIntervalSet(LacpFastPeriodicTime)
+	ptxm.PeriodicTimerStart()
+	return DrcpPtxmStateFastPeriodic
+}
+
+// DrcpPtxMachineSlowPeriodic function to be called after
+// State transition to SLOW_PERIODIC
+func (ptxm *DrcpPtxMachine) DrcpPtxMachineSlowPeriodic(m fsm.Machine, data interface{}) fsm.State {
+	ptxm.PeriodicTimerIntervalSet(LacpSlowPeriodicTime)
+	ptxm.PeriodicTimerStart()
+	return DrcpPtxmStateSlowPeriodic
+}
+
+// DrcpPtxMachinePeriodicTx function to be called after the periodic timer
+// has expired, PERIODIC_TX will transmit then return to FAST_PERIODIC or
+// SLOW_PERIODIC depending on the timeout requested by the neighbor
+func (ptxm *DrcpPtxMachine) DrcpPtxMachinePeriodicTx(m fsm.Machine, data interface{}) fsm.State {
+	ptxm.ipp.DrcpTxPdu()
+
+	if LacpStateIsSet(ptxm.ipp.DRFNeighborOperDRCPState, DrcpStateDRCPTimeoutBit) ||
+		!ptxm.ipp.IsNeighborCurrent() {
+		return ptxm.DrcpPtxMachineFastPeriodic(m, data)
+	}
+	return ptxm.DrcpPtxMachineSlowPeriodic(m, data)
+}
+
+// DrcpPtxMachineNtt function to be called when NTT is set, State is not
+// changed
+func (ptxm *DrcpPtxMachine) DrcpPtxMachineNtt(m fsm.Machine, data interface{}) fsm.State {
+	ptxm.ipp.DrcpTxPdu()
+	return ptxm.Machine.Curr.CurrentState()
+}
+
+// DrcpPtxMachineFSMBuild will build the State machine with callbacks
+func DrcpPtxMachineFSMBuild(ipp *DRCPIpp) *DrcpPtxMachine {
+
+	DrcpPtxMachineStrStateMapCreate()
+
+	rules := fsm.Ruleset{}
+
+	// Instantiate a new DrcpPtxMachine
+	// Initial State will be a psuedo State known as "begin" so that
+	// we can transition to the NO PERIODIC State
+	ptxm := NewDrcpPtxMachine(ipp)
+
+	//BEGIN -> NO PERIODIC
+	rules.AddRule(DrcpPtxmStateNone, DrcpPtxmEventBegin, ptxm.DrcpPtxMachineNoPeriodic)
+	rules.AddRule(DrcpPtxmStateNoPeriodic, DrcpPtxmEventBegin, ptxm.DrcpPtxMachineNoPeriodic)
+	rules.AddRule(DrcpPtxmStateFastPeriodic, DrcpPtxmEventBegin, ptxm.DrcpPtxMachineNoPeriodic)
+	rules.AddRule(DrcpPtxmStateSlowPeriodic, DrcpPtxmEventBegin, ptxm.DrcpPtxMachineNoPeriodic)
+	// NOT IPP PORT ENABLED -> NO PERIODIC
+	rules.AddRule(DrcpPtxmStateFastPeriodic, DrcpPtxmEventIppDisabled, ptxm.DrcpPtxMachineNoPeriodic)
+	rules.AddRule(DrcpPtxmStateSlowPeriodic, DrcpPtxmEventIppDisabled, ptxm.DrcpPtxMachineNoPeriodic)
+	// IPP PORT ENABLED -> FAST PERIODIC
+	rules.AddRule(DrcpPtxmStateNoPeriodic, DrcpPtxmEventIppEnabled, ptxm.DrcpPtxMachineFastPeriodic)
+	// PERIODIC TIMER EXPIRED -> PERIODIC TX
+	rules.AddRule(DrcpPtxmStateFastPeriodic, DrcpPtxmEventPeriodicTimerExpired, ptxm.DrcpPtxMachinePeriodicTx)
+	rules.AddRule(DrcpPtxmStateSlowPeriodic, DrcpPtxmEventPeriodicTimerExpired, ptxm.DrcpPtxMachinePeriodicTx)
+	// NTT
+	rules.AddRule(DrcpPtxmStateFastPeriodic, DrcpPtxmEventNtt, ptxm.DrcpPtxMachineNtt)
+	rules.AddRule(DrcpPtxmStateSlowPeriodic, DrcpPtxmEventNtt, ptxm.DrcpPtxMachineNtt)
+
+	// Create a new FSM and apply the rules
+	ptxm.Apply(&rules)
+
+	return ptxm
+}
+
+// DrcpPtxMachineMain:  802.1ax-2014 Figure 9-24
+// Creation of DRCP Periodic Tx State Machine State transitions and callbacks
+func (ipp *DRCPIpp) DrcpPtxMachineMain() {
+
+	// Build the State machine for DRCP Periodic Transmission Machine
+	// according to 802.1ax-2014 Section 9.4.15
+	ptxm := DrcpPtxMachineFSMBuild(ipp)
+
+	// set the inital State
+	ptxm.Machine.Start(ptxm.PrevState())
+
+	// lets create a go routing which will wait for the specific events
+	// that the PtxMachine should handle.
+	go func(m *DrcpPtxMachine) {
+		m.DrcpPtxmLog("Machine Start")
+		defer m.ipp.wg.Done()
+		for {
+			select {
+			case <-m.DrcpPtxmKillSignalEvent:
+				m.DrcpPtxmLog("Machine End")
+				return
+
+			case <-m.periodicTxTimer.C:
+				m.Machine.ProcessEvent(DrcpPtxMachineModuleStr, DrcpPtxmEventPeriodicTimerExpired, nil)
+
+			case event := <-m.DrcpPtxmEvents:
+				rv := m.Machine.ProcessEvent(event.src, event.e, nil)
+				if rv == nil &&
+					m.Machine.Curr.CurrentState() == DrcpPtxmStateNoPeriodic &&
+					m.ipp.IppPortEnabled {
+					rv = m.Machine.ProcessEvent(DrcpPtxMachineModuleStr, DrcpPtxmEventIppEnabled, nil)
+				}
+				// NTT is not relevant while not transmitting
+				if rv != nil && event.e != DrcpPtxmEventNtt {
+					m.DrcpPtxmLog(strings.Join([]string{error.Error(rv), event.src, DrcpPtxmStateStrMap[m.Machine.Curr.CurrentState()], strconv.Itoa(int(event.e))}, ":"))
+				}
+
+				// respond to caller if necessary so that we don't have a deadlock
+				if event.responseChan != nil {
+					SendResponse(DrcpPtxMachineModuleStr, event.responseChan)
+				}
+
+			case ena := <-m.DrcpPtxmLogEnableEvent:
+				m.Machine.Curr.EnableLogging(ena)
+			}
+		}
+	}(ptxm)
+}
@@ -0,0 +1,494 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// drcprxmachine.go
+// DRCPDU Receive machine 802.1ax-2014 Section 9.4.14.  Records the
+// information of the neighbor Portal System received on the Intra-Portal
+// Port and triggers the Distributed Relay to update the Portal state.
+package lacp
+
+import (
+	"fmt"
+	"l2/clock"
+	"strconv"
+	"strings"
+	"time"
+	"utils/fsm"
+)
+
+const DrcpRxMachineModuleStr = "DRCP Rx Machine"
+
+// drcp rxm States
+const (
+	DrcpRxmStateNone = iota + 1
+	DrcpRxmStateInitialize
+	DrcpRxmStateExpired
+	DrcpRxmStatePortalCheck
+	DrcpRxmStateCompatibilityCheck
+	DrcpRxmStateDefaulted
+	DrcpRxmStateDiscard
+	DrcpRxmStateCurrent
+)
+
+var DrcpRxmStateStrMap map[fsm.State]string
+
+func DrcpRxMachineStrStateMapCreate() {
+	DrcpRxmStateStrMap = make(map[fsm.State]string)
+	DrcpRxmStateStrMap[DrcpRxmStateNone] = "None"
+	DrcpRxmStateStrMap[DrcpRxmStateInitialize] = "Initialize"
+	DrcpRxmStateStrMap[DrcpRxmStateExpired] = "Expired"
+	DrcpRxmStateStrMap[DrcpRxmStatePortalCheck] = "PortalCheck"
+	DrcpRxmStateStrMap[DrcpRxmStateCompatibilityCheck] = "CompatibilityCheck"
+	DrcpRxmStateStrMap[DrcpRxmStateDefaulted] = "Defaulted"
+	DrcpRxmStateStrMap[DrcpRxmStateDiscard] = "Discard"
+	DrcpRxmStateStrMap[DrcpRxmStateCurrent] = "Current"
+}
+
+// drcp rxm events
+const (
+	DrcpRxmEventBegin = iota + 1
+	DrcpRxmEventIppEnabled
+	DrcpRxmEventIppDisabled
+	DrcpRxmEventDrcpPduRx
+	DrcpRxmEventCurrentWhileTimerExpired
+	DrcpRxmEventIntentionalFallthrough
+)
+
+type DrcpRxPdu struct {
+	pdu *DRCPDU
+	src string
+}
+
+// DrcpRxMachine holds FSM and current State
+// and event channels for State transitions
+type DrcpRxMachine struct {
+	// for debugging
+	PreviousState fsm.State
+
+	Machine *fsm.Machine
+
+	ipp *DRCPIpp
+
+	// debug log
+	log chan string
+
+	// timer interval
+	currentWhileTimerTimeout time.Duration
+
+	// timers
+	currentWhileTimer *clock.Timer
+
+	// machine specific events
+	DrcpRxmEvents          chan LacpMachineEvent
+	DrcpRxmPktRxEvent      chan DrcpRxPdu
+	DrcpRxmKillSignalEvent chan bool
+	DrcpRxmLogEnableEvent  chan bool
+}
+
+func (rxm *DrcpRxMachine) PrevState() fsm.State { return rxm.PreviousState }
+
+// PrevStateSet will set the previous State
+func (rxm *DrcpRxMachine) PrevStateSet(s fsm.State) { rxm.PreviousState = s }
+
+// Stop should clean up all resources
+func (rxm *DrcpRxMachine) Stop() {
+	rxm.CurrentWhileTimerStop()
+
+	// stop the go routine
+	rxm.DrcpRxmKillSignalEvent <- true
+
+	close(rxm.DrcpRxmEvents)
+	close(rxm.DrcpRxmPktRxEvent)
+	close(rxm.DrcpRxmKillSignalEvent)
+	close(rxm.DrcpRxmLogEnableEvent)
+}
+
+// NewDrcpRxMachine will create a new instance of the DrcpRxMachine
+func NewDrcpRxMachine(ipp *DRCPIpp) *DrcpRxMachine {
+	rxm := &DrcpRxMachine{
+		ipp:                    ipp,
+		log:                    ipp.LacpDebug.LacpLogChan,
+		PreviousState:          DrcpRxmStateNone,
+		DrcpRxmEvents:          make(chan LacpMachineEvent, 10),
+		DrcpRxmPktRxEvent:      make(chan DrcpRxPdu, 100),
+		DrcpRxmKillSignalEvent: make(chan bool),
+		DrcpRxmLogEnableEvent:  make(chan bool)}
+
+	ipp.RxMachineFsm = rxm
+
+	// create then stop
+	rxm.CurrentWhileTimerTimeoutSet(LacpShortTimeoutTime)
+	rxm.CurrentWhileTimerStart()
+	rxm.CurrentWhileTimerStop()
+
+	return rxm
+}
+
+// A helpful function that lets us apply arbitrary rulesets to this
+// instances State machine without reallocating the machine.
+func (rxm *DrcpRxMachine) Apply(r *fsm.Ruleset) *fsm.Machine {
+	if rxm.Machine == nil {
+		rxm.Machine = &fsm.Machine{}
+	}
+
+	// Assign the ruleset to be used for this machine
+	rxm.Machine.Rules = r
+	rxm.Machine.Curr = &LacpStateEvent{
+		strStateMap: DrcpRxmStateStrMap,
+		logEna:      rxm.ipp.logEna,
+		logger:      rxm.DrcpRxmLog,
+		owner:       DrcpRxMachineModuleStr,
+	}
+
+	return rxm.Machine
+}
+
+// DrcpRxMachineInitialize function to be called after
+// State transition to INITIALIZE
+func (rxm *DrcpRxMachine) DrcpRxMachineInitialize(m fsm.Machine, data interface{}) fsm.State {
+	ipp := rxm.ipp
+
+	rxm.CurrentWhileTimerStop()
+
+	rxm.recordDefaultDRCPDU()
+
+	// DRF_Home_Oper_DRCP_State.Expired = FALSE
+	LacpStateClear(&ipp.DRFHomeOperDRCPState, DrcpStateExpiredBit)
+
+	ipp.dr.DrniUpdatePortalState()
+
+	return DrcpRxmStateInitialize
+}
+
+// DrcpRxMachineExpired function to be called after
+// State transition to EXPIRED
+func (rxm *DrcpRxMachine) DrcpRxMachineExpired(m fsm.Machine, data interface{}) fsm.State {
+	ipp := rxm.ipp
+
+	// neighbor is no longer in sync, its conversations will
+	// move to the Home Portal System
+	ipp.neighborValid = false
+	LacpStateClear(&ipp.DRFNeighborOperDRCPState, DrcpStateIppActivityBit)
+	LacpStateClear(&ipp.DRFHomeOperDRCPState, DrcpStateIppActivityBit)
+
+	// DRF_Home_Oper_DRCP_State.DRCP_Timeout = Short Timeout
+	LacpStateSet(&ipp.DRFHomeOperDRCPState, DrcpStateDRCPTimeoutBit)
+	// DRF_Home_Oper_DRCP_State.Expired = TRUE
+	LacpStateSet(&ipp.DRFHomeOperDRCPState, DrcpStateExpiredBit)
+	ipp.DrcpCounter.ExpiredCnt++
+
+	// Set the Short timeout
+	rxm.CurrentWhileTimerTimeoutSet(LacpShortTimeoutTime)
+	rxm.CurrentWhileTimerStart()
+
+	ipp.dr.DrniUpdatePortalState()
+
+	return DrcpRxmStateExpired
+}
+
+// DrcpRxMachinePortalCheck function to be called after
+// State transition to PORTAL_CHECK
+func (rxm *DrcpRxMachine) DrcpRxMachinePortalCheck(m fsm.Machine, data interface{}) fsm.State {
+	pdu := data.(*DRCPDU)
+
+	rxm.recordPortalValues(pdu)
+
+	return DrcpRxmStatePortalCheck
+}
+
+// DrcpRxMachineCompatibilityCheck function to be called after
+// State transition to COMPATIBILITY_CHECK
+func (rxm *DrcpRxMachine) DrcpRxMachineCompatibilityCheck(m fsm.Machine, data interface{}) fsm.State {
+	ipp := rxm.ipp
+	pdu := data.(*DRCPDU)
+
+	// Neighbor is not part of the same Portal
+	if ipp.DifferPortal {
+		return rxm.DrcpRxMachineDiscard(m, data)
+	}
+
+	rxm.recordPortalConfValues(pdu)
+
+	return DrcpRxmStateCompatibilityCheck
+}
+
+// DrcpRxMachineDiscard function to be called after
+// State transition to DISCARD
+func (rxm *DrcpRxMachine) DrcpRxMachineDiscard(m fsm.Machine, data interface{}) fsm.State {
+	ipp := rxm.ipp
+
+	ipp.DrcpCounter.DiscardRx++
+	ipp.neighborValid = false
+	rxm.DrcpRxmLog(fmt.Sprintf("Discarding DRCPDU differ portal %t differ portal system number %t",
+		ipp.DifferPortal, ipp.DifferConfPortalSystemNumber))
+
+	ipp.dr.DrniUpdatePortalState()
+
+	return DrcpRxmStateDiscard
+}
+
+// DrcpRxMachineDefaulted function to be called after
+// State transition to DEFAULTED
+func (rxm *DrcpRxMachine) DrcpRxMachineDefaulted(m fsm.Machine, data interface{}) fsm.State {
+	ipp := rxm.ipp
+
+	// DRF_Home_Oper_DRCP_State.Expired = TRUE
+	LacpStateSet(&ipp.DRFHomeOperDRCPState, DrcpStateExpiredBit)
+
+	rxm.recordDefaultDRCPDU()
+
+	ipp.dr.DrniLog("Neighbor Portal System timed out, Portal System is isolated")
+	ipp.dr.DrniUpdatePortalState()
+
+	return DrcpRxmStateDefaulted
+}
+
+// DrcpRxMachineCurrent function to be called after
+// State transition to CURRENT
+func (rxm *DrcpRxMachine) DrcpRxMachineCurrent(m fsm.Machine, data interface{}) fsm.State {
+	ipp := rxm.ipp
+	pdu := data.(*DRCPDU)
+
+	// Neighbor does not agree with the Portal System Numbers
+	if ipp.DifferConfPortalSystemNumber {
+		return rxm.DrcpRxMachineDiscard(m, data)
+	}
+
+	rxm.recordNeighborState(pdu)
+
+	// DRF_Home_Oper_DRCP_State.Expired = FALSE
+	LacpStateClear(&ipp.DRFHomeOperDRCPState, DrcpStateExpiredBit)
+
+	// neighbor timeout
+	if LacpStateIsSet(ipp.DRFNeighborOperDRCPState, DrcpStateDRCPTimeoutBit) {
+		rxm.CurrentWhileTimerTimeoutSet(LacpShortTimeoutTime)
+	} else {
+		rxm.CurrentWhileTimerTimeoutSet(LacpLongTimeoutTime)
+	}
+	rxm.CurrentWhileTimerStart()
+
+	ipp.dr.DrniUpdatePortalState()
+
+	return DrcpRxmStateCurrent
+}
+
+// recordDefaultDRCPDU 802.1ax-2014 Section 9.4.11, neighbor information
+// is cleared as the neighbor is no longer heard from
+func (rxm *DrcpRxMachine) recordDefaultDRCPDU() {
+	ipp := rxm.ipp
+	dr := ipp.dr
+
+	dr.mutex.Lock()
+	defer dr.mutex.Unlock()
+
+	ipp.neighborValid = false
+	ipp.DRFNeighborPortalSystemNumber = 0
+	ipp.DRFNeighborConfPortalSystemNumber = 0
+	ipp.DRFNeighborAdminAggregatorKey = 0
+	ipp.DRFNeighborOperAggregatorKey = 0
+	ipp.DRFNeighborOperPartnerAggregatorKey = 0
+	ipp.DRFNeighborOperDRCPState = 0
+	ipp.DRFNeighborActivePorts = nil
+	ipp.DRFNeighborGatewaySequence = 0
+	ipp.DRFNeighborGatewayVector = [LacpConversationMaskSize]uint8{}
+	ipp.DRFRcvHomeActivePorts = nil
+	ipp.DRFRcvHomeGatewaySequence = 0
+	ipp.DifferGatewayDigest = false
+	ipp.DifferPortDigest = false
+	LacpStateClear(&ipp.DRFHomeOperDRCPState, DrcpStateIppActivityBit)
+}
+
+// recordPortalValues 802.1ax-2014 Section 9.4.11.  The Aggregator Id of each
+// Portal System is recorded, but only the Portal Address and Priority must
+// match as each Portal System substitutes the Portal System Id
+func (rxm *DrcpRxMachine) recordPortalValues(pdu *DRCPDU) {
+	ipp := rxm.ipp
+	dr := ipp.dr
+
+	ipp.DRFNeighborAggregatorPriority = pdu.PortalInfo.AggPriority
+	ipp.DRFNeighborAggregatorId = pdu.PortalInfo.AggId
+	ipp.DRFNeighborPortalPriority = pdu.PortalInfo.PortalPriority
+	ipp.DRFNeighborPortalAddr = pdu.PortalInfo.PortalAddr
+
+	ipp.DifferPortal = ipp.DRFNeighborPortalAddr != dr.DrniPortalAddr ||
+		ipp.DRFNeighborPortalPriority != dr.DrniPortalPriority
+}
+
+// recordPortalConfValues 802.1ax-2014 Section 9.4.11
+func (rxm *DrcpRxMachine) recordPortalConfValues(pdu *DRCPDU) {
+	ipp := rxm.ipp
+	dr := ipp.dr
+
+	topology := pdu.PortalConfigInfo.TopologyState
+	ipp.DRFNeighborPortalSystemNumber = topology & DrcpTopologyStatePortalSystemNumberMask
+	ipp.DRFNeighborConfPortalSystemNumber = (topology & DrcpTopologyStateNeighborConfPortalSystemMask) >> DrcpTopologyStateNeighborConfPortalSystemShift
+	ipp.DRFNeighborOperAggregatorKey = pdu.PortalConfigInfo.OperAggKey
+	ipp.DRFNeighborPortAlgorithm = pdu.PortalConfigInfo.PortAlgorithm
+	ipp.DRFNeighborGatewayAlgorithm = pdu.PortalConfigInfo.GatewayAlgorithm
+	ipp.DRFNeighborPortDigest = pdu.PortalConfigInfo.PortDigest
+	ipp.DRFNeighborGatewayDigest = pdu.PortalConfigInfo.GatewayDigest
+
+	ipp.DifferConfPortalSystemNumber = ipp.DRFNeighborPortalSystemNumber != dr.DrniPortalSystemNumberNeighbor() ||
+		ipp.DRFNeighborConfPortalSystemNumber != dr.DrniPortalSystemNumber
+
+	ipp.DifferGatewayDigest = ipp.DRFNeighborGatewayAlgorithm != dr.DrniGatewayAlgorithm ||
+		ipp.DRFNeighborGatewayDigest != dr.DrniGatewayDigest()
+	ipp.DifferPortDigest = ipp.DRFNeighborPortDigest != dr.DrniPortDigest()
+	if ipp.DifferGatewayDigest || ipp.DifferPortDigest {
+		rxm.DrcpRxmLog(fmt.Sprintf("Neighbor config differs gateway digest %t port digest %t",
+			ipp.DifferGatewayDigest, ipp.DifferPortDigest))
+	}
+}
+
+// recordNeighborState 802.1ax-2014 Section 9.4.11
+func (rxm *DrcpRxMachine) recordNeighborState(pdu *DRCPDU) {
+	ipp := rxm.ipp
+	dr := ipp.dr
+
+	dr.mutex.Lock()
+	defer dr.mutex.Unlock()
+
+	ipp.neighborValid = true
+	ipp.DRFNeighborOperDRCPState = pdu.State
+	ipp.DRFNeighborAdminAggregatorKey = pdu.HomePortsInfo.AdminAggKey
+	ipp.DRFNeighborOperPartnerAggregatorKey = pdu.HomePortsInfo.OperPartnerAggKey
+	ipp.DRFNeighborActivePorts = pdu.HomePortsInfo.ActivePorts
+	ipp.DRFNeighborGatewaySequence = pdu.HomeGatewayVector.Sequence
+	ipp.DRFNeighborGatewayVector = pdu.HomeGatewayVector.Vector
+	ipp.DRFRcvHomeActivePorts = pdu.NeighborPortsInfo.ActivePorts
+	ipp.DRFRcvHomeGatewaySequence = pdu.NeighborGatewayVector.Sequence
+
+	// DRF_Home_Oper_DRCP_State.IPP_Activity = TRUE
+	LacpStateSet(&ipp.DRFHomeOperDRCPState, DrcpStateIppActivityBit)
+}
+
+// DrcpRxMachineFSMBuild will build the State machine with callbacks
+func DrcpRxMachineFSMBuild(ipp *DRCPIpp) *DrcpRxMachine {
+
+	DrcpRxMachineStrStateMapCreate()
+
+	rules := fsm.Ruleset{}
+
+	// Instantiate a new DrcpRxMachine
+	// Initial State will be a psuedo State known as "begin" so that
+	// we can transition to the initalize State
+	rxm := NewDrcpRxMachine(ipp)
+
+	//BEGIN -> INIT
+	rules.AddRule(DrcpRxmStateNone, DrcpRxmEventBegin, rxm.DrcpRxMachineInitialize)
+	rules.AddRule(DrcpRxmStateInitialize, DrcpRxmEventBegin, rxm.DrcpRxMachineInitialize)
+	rules.AddRule(DrcpRxmStateExpired, DrcpRxmEventBegin, rxm.DrcpRxMachineInitialize)
+	rules.AddRule(DrcpRxmStatePortalCheck, DrcpRxmEventBegin, rxm.DrcpRxMachineInitialize)
+	rules.AddRule(DrcpRxmStateCompatibilityCheck, DrcpRxmEventBegin, rxm.DrcpRxMachineInitialize)
+	rules.AddRule(DrcpRxmStateDefaulted, DrcpRxmEventBegin, rxm.DrcpRxMachineInitialize)
+	rules.AddRule(DrcpRxmStateDiscard, DrcpRxmEventBegin, rxm.DrcpRxMachineInitialize)
+	rules.AddRule(DrcpRxmStateCurrent, DrcpRxmEventBegin, rxm.DrcpRxMachineInitialize)
+	// NOT IPP PORT ENABLED -> INIT
+	rules.AddRule(DrcpRxmStateExpired, DrcpRxmEventIppDisabled, rxm.DrcpRxMachineInitialize)
+	rules.AddRule(DrcpRxmStateDefaulted, DrcpRxmEventIppDisabled, rxm.DrcpRxMachineInitialize)
+	rules.AddRule(DrcpRxmStateDiscard, DrcpRxmEventIppDisabled, rxm.DrcpRxMachineInitialize)
+	rules.AddRule(DrcpRxmStateCurrent, DrcpRxmEventIppDisabled, rxm.DrcpRxMachineInitialize)
+	// IPP PORT ENABLED && DRCP ENABLED -> EXPIRED
+	rules.AddRule(DrcpRxmStateInitialize, DrcpRxmEventIppEnabled, rxm.DrcpRxMachineExpired)
+	// CURRENT WHILE TIMER EXPIRED
+	rules.AddRule(DrcpRxmStateExpired, DrcpRxmEventCurrentWhileTimerExpired, rxm.DrcpRxMachineDefaulted)
+	rules.AddRule(DrcpRxmStateDiscard, DrcpRxmEventCurrentWhileTimerExpired, rxm.DrcpRxMachineDefaulted)
+	rules.AddRule(DrcpRxmStateCurrent, DrcpRxmEventCurrentWhileTimerExpired, rxm.DrcpRxMachineExpired)
+	// PKT RX
+	rules.AddRule(DrcpRxmStateExpired, DrcpRxmEventDrcpPduRx, rxm.DrcpRxMachinePortalCheck)
+	rules.AddRule(DrcpRxmStateDefaulted, DrcpRxmEventDrcpPduRx, rxm.DrcpRxMachinePortalCheck)
+	rules.AddRule(DrcpRxmStateDiscard, DrcpRxmEventDrcpPduRx, rxm.DrcpRxMachinePortalCheck)
+	rules.AddRule(DrcpRxmStateCurrent, DrcpRxmEventDrcpPduRx, rxm.DrcpRxMachinePortalCheck)
+	// PORTAL CHECK -> COMPATIBILITY CHECK or DISCARD
+	rules.AddRule(DrcpRxmStatePortalCheck, DrcpRxmEventIntentionalFallthrough, rxm.DrcpRxMachineCompatibilityCheck)
+	// COMPATIBILITY CHECK -> CURRENT or DISCARD
+	rules.AddRule(DrcpRxmStateCompatibilityCheck, DrcpRxmEventIntentionalFallthrough, rxm.DrcpRxMachineCurrent)
+
+	// Create a new FSM and apply the rules
+	rxm.Apply(&rules)
+
+	return rxm
+}
+
+// DrcpRxMachineMain:  802.1ax-2014 Figure 9-23
+// Creation of DRCP Rx State Machine State transitions and callbacks
+func (ipp *DRCPIpp) DrcpRxMachineMain() {
+
+	// Build the State machine for DRCP Receive Machine according to
+	// 802.1ax-2014 Section 9.4.14 DRCPDU Receive machine
+	rxm := DrcpRxMachineFSMBuild(ipp)
+
+	// set the inital State
+	rxm.Machine.Start(rxm.PrevState())
+
+	// lets create a go routing which will wait for the specific events
+	// that the RxMachine should handle.
+	go func(m *DrcpRxMachine) {
+		m.DrcpRxmLog("Machine Start")
+		defer m.ipp.wg.Done()
+		for {
+			select {
+			case <-m.DrcpRxmKillSignalEvent:
+				m.DrcpRxmLog("Machine End")
+				return
+
+			case <-m.currentWhileTimer.C:
+				// packet in the queue will restart the timer
+				if len(m.DrcpRxmPktRxEvent) == 0 {
+					m.DrcpRxmLog("Current While Timer Expired")
+					m.Machine.ProcessEvent(DrcpRxMachineModuleStr, DrcpRxmEventCurrentWhileTimerExpired, nil)
+				}
+
+			case event := <-m.DrcpRxmEvents:
+				rv := m.Machine.ProcessEvent(event.src, event.e, nil)
+				if rv == nil &&
+					m.Machine.Curr.CurrentState() == DrcpRxmStateInitialize &&
+					m.ipp.IppPortEnabled {
+					rv = m.Machine.ProcessEvent(DrcpRxMachineModuleStr, DrcpRxmEventIppEnabled, nil)
+				}
+				if rv != nil {
+					m.DrcpRxmLog(strings.Join([]string{error.Error(rv), event.src, DrcpRxmStateStrMap[m.Machine.Curr.CurrentState()], strconv.Itoa(int(event.e))}, ":"))
+				}
+
+				// respond to caller if necessary so that we don't have a deadlock
+				if event.responseChan != nil {
+					SendResponse(DrcpRxMachineModuleStr, event.responseChan)
+				}
+
+			case rx := <-m.DrcpRxmPktRxEvent:
+				m.ipp.DrcpCounter.DRCPDUsRx++
+				rv := m.Machine.ProcessEvent(rx.src, DrcpRxmEventDrcpPduRx, rx.pdu)
+				// PORTAL_CHECK and COMPATIBILITY_CHECK are
+				// transient States
+				for rv == nil &&
+					(m.Machine.Curr.CurrentState() == DrcpRxmStatePortalCheck ||
+						m.Machine.Curr.CurrentState() == DrcpRxmStateCompatibilityCheck) {
+					rv = m.Machine.ProcessEvent(DrcpRxMachineModuleStr, DrcpRxmEventIntentionalFallthrough, rx.pdu)
+				}
+
+			case ena := <-m.DrcpRxmLogEnableEvent:
+				m.Machine.Curr.EnableLogging(ena)
+			}
+		}
+	}(rxm)
+}
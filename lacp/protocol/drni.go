@@ -0,0 +1,911 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// drni will handle the Distributed Resilient Network Interconnect
+// 802.1ax-2014 Clause 9.  Two Portal Systems, each running lacpd, are
+// connected by an Intra-Portal Link (IPL) and present a single Portal
+// System Id to the Partner of the aggregator.  The Distributed Relay
+// Control Protocol (DRCP) is run on the Intra-Portal Port (IPP) in order
+// for the Portal Systems to agree on which Portal System carries each
+// Gateway and Port Conversation ID.
+//
+// DRNI is control plane only.  The Gateway and Port Conversation
+// assignments are kept here and reported to the neighbor Portal System,
+// but they are not programmed in hw and frames are never relayed over
+// the IPL.
+package lacp
+
+import (
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"l2/pktio"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const DrniModuleStr = "DRNI"
+
+// 802.1ax-2014 Section 9.3.4 only two Portal System Portals are supported
+const (
+	DrniPortalSystemNumberMin uint8 = 1
+	DrniPortalSystemNumberMax uint8 = 2
+)
+
+// DistributedRelayConfig 802.1ax-2014 Section 7.4.1.1 Distributed Relay attributes
+type DistributedRelayConfig struct {
+	// aDrniName
+	DrniName string
+	// aDrniPortalAddr, in format AA:BB:CC:DD:EE:FF
+	DrniPortalAddress string
+	// aDrniPortalPriority
+	DrniPortalPriority uint16
+	// aDrniPortalSystemNumber 1 or 2
+	DrniPortalSystemNumber uint8
+	// aDrniAggregator
+	DrniAggregator int
+	// aDrniIntraPortalLinkList, only a single IPL is supported
+	DrniIntraPortalPortId uint16
+	DrniIntraPortalIntf   string
+	// aDrniGatewayAlgorithm
+	DrniGatewayAlgorithm uint32
+	// aDrniConvAdminGateway, prioritized list of Portal System Numbers
+	// per Gateway Conversation ID
+	DrniConvAdminGateway map[uint16][]uint8
+}
+
+// DistributedRelay holds the Distributed Relay of a Portal System
+type DistributedRelay struct {
+	DrniName               string
+	DrniPortalAddr         [6]uint8
+	DrniPortalPriority     uint16
+	DrniPortalSystemNumber uint8
+	DrniAggregator         int
+	DrniGatewayAlgorithm   uint32
+	DrniConvAdminGateway   map[uint16][]uint8
+
+	// System of the aggregator prior to the Portal System Id
+	// being substituted, Drni_Aggregator_ID and Drni_Aggregator_Priority
+	aggSysKey LacpSystem
+	a         *LaAggregator
+
+	// DRF_Home_Admin_Aggregator_Key
+	DRFHomeAdminAggregatorKey uint16
+	// DRF_Home_Oper_Aggregator_Key
+	DRFHomeOperAggregatorKey uint16
+
+	// Drni_Portal_System_Gateway_Conversation, Portal System Number
+	// which each Gateway Conversation ID is assigned to, 0 if none
+	DrniGatewayConversation [LacpMaxConversationIds]uint8
+	// Drni_Portal_System_Port_Conversation, Portal System Number
+	// which each Port Conversation ID is assigned to, 0 if none
+	DrniPortConversation [LacpMaxConversationIds]uint8
+
+	// DRF_Home_Gateway_Sequence and Drni_Portal_System_Gateway_Conversation
+	// vector of the Home Portal System
+	homeGatewaySequence uint32
+	homeGatewayVector   [LacpConversationMaskSize]uint8
+	// DRF_Home_Active_Ports last sent to the neighbor
+	homeActivePorts []uint32
+
+	// Portal System Isolated, no neighbor Portal System
+	// information is valid on any IPP
+	PSI bool
+
+	Ipp *DRCPIpp
+
+	// protects the Portal state which is updated by the DRCP machines
+	// as well as the mux machines of the aggregation ports
+	mutex sync.Mutex
+
+	LacpDebug *LacpDebug
+}
+
+// DrcpIppStatsObject 802.1ax-2014 Section 7.4.4 IPP statistics
+type DrcpIppStatsObject struct {
+	DRCPDUsRx  uint64
+	IllegalRx  uint64
+	DRCPDUsTx  uint64
+	UnknownRx  uint64
+	DiscardRx  uint64
+	ExpiredCnt uint64
+}
+
+// DRCPIpp holds the DRCP info of the Intra-Portal Port
+type DRCPIpp struct {
+	// Id used to rx/tx DRCPDU's, should not overlap with
+	// the aggregation port numbers
+	Id   uint16
+	Intf string
+
+	dr     *DistributedRelay
+	handle pktio.Handle
+
+	// IPP_port_enabled
+	IppPortEnabled bool
+
+	// neighbor Portal System information is valid, DRCPDU
+	// Receive machine is in the CURRENT State
+	neighborValid bool
+
+	// DRF_Home_Oper_DRCP_State
+	DRFHomeOperDRCPState uint8
+
+	// information recorded from the neighbor DRCPDU
+	DRFNeighborPortalSystemNumber       uint8
+	DRFNeighborConfPortalSystemNumber   uint8
+	DRFNeighborAggregatorId             [6]uint8
+	DRFNeighborAggregatorPriority       uint16
+	DRFNeighborPortalAddr               [6]uint8
+	DRFNeighborPortalPriority           uint16
+	DRFNeighborAdminAggregatorKey       uint16
+	DRFNeighborOperAggregatorKey        uint16
+	DRFNeighborOperPartnerAggregatorKey uint16
+	DRFNeighborOperDRCPState            uint8
+	DRFNeighborPortAlgorithm            uint32
+	DRFNeighborGatewayAlgorithm         uint32
+	DRFNeighborPortDigest               [16]uint8
+	DRFNeighborGatewayDigest            [16]uint8
+	DRFNeighborActivePorts              []uint32
+	DRFNeighborGatewaySequence          uint32
+	DRFNeighborGatewayVector            [LacpConversationMaskSize]uint8
+	// what the neighbor reports as the Home Portal System info
+	DRFRcvHomeActivePorts     []uint32
+	DRFRcvHomeGatewaySequence uint32
+
+	// Differ_Portal, Differ_Conf_Portal_System_Number, Differ_Gateway_Digest
+	// Differ_Port_Digest 802.1ax-2014 Section 9.4.8
+	DifferPortal                 bool
+	DifferConfPortalSystemNumber bool
+	DifferGatewayDigest          bool
+	DifferPortDigest             bool
+
+	// Counters
+	DrcpCounter DrcpIppStatsObject
+
+	// State machines
+	RxMachineFsm  *DrcpRxMachine
+	PtxMachineFsm *DrcpPtxMachine
+
+	LacpDebug *LacpDebug
+	logEna    bool
+	wg        sync.WaitGroup
+}
+
+// DrniPortalSystemNumberNeighbor will return the Portal System Number of the
+// neighbor Portal System within a two Portal System Portal
+func (dr *DistributedRelay) DrniPortalSystemNumberNeighbor() uint8 {
+	return DrniPortalSystemNumberMax + DrniPortalSystemNumberMin - dr.DrniPortalSystemNumber
+}
+
+func (dr *DistributedRelay) DrniLog(msg string) {
+	dr.LacpDebug.logger.Info(strings.Join([]string{DrniModuleStr, dr.DrniName, msg}, ":"))
+}
+
+func drniSysGlobalInfoGet(sysKey LacpSystem) *LacpSysGlobalInfo {
+	if s, ok := gLacpSysGlobalInfo[sysKey]; ok {
+		return s
+	}
+	return nil
+}
+
+// DrFindByName will find the Distributed Relay by name
+func DrFindByName(name string, dr **DistributedRelay) bool {
+	for _, sgi := range LacpSysGlobalInfoGet() {
+		if d, ok := sgi.DistributedRelayMap[name]; ok {
+			*dr = d
+			return true
+		}
+	}
+	return false
+}
+
+// DrFindByAggId will find the Distributed Relay which the aggregator is part of
+func DrFindByAggId(aggId int, dr **DistributedRelay) bool {
+	for _, sgi := range LacpSysGlobalInfoGet() {
+		for _, d := range sgi.DistributedRelayList {
+			if d.DrniAggregator == aggId {
+				*dr = d
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// DrFindIppById will find the Intra-Portal Port by id
+func DrFindIppById(id uint16, ipp **DRCPIpp) bool {
+	for _, sgi := range LacpSysGlobalInfoGet() {
+		for _, d := range sgi.DistributedRelayList {
+			if d.Ipp != nil && d.Ipp.Id == id {
+				*ipp = d.Ipp
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// DrFindIppByName will find the Intra-Portal Port by interface name
+func DrFindIppByName(intf string, ipp **DRCPIpp) bool {
+	for _, sgi := range LacpSysGlobalInfoGet() {
+		for _, d := range sgi.DistributedRelayList {
+			if d.Ipp != nil && d.Ipp.Intf == intf {
+				*ipp = d.Ipp
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// DrGetNext will return the next Distributed Relay
+func DrGetNext(dr **DistributedRelay) bool {
+	returnNext := false
+	for _, sgi := range LacpSysGlobalInfoGet() {
+		for _, d := range sgi.DistributedRelayList {
+			if *dr == nil {
+				*dr = d
+				return true
+			} else if (*dr).DrniName == d.DrniName {
+				returnNext = true
+			} else if returnNext {
+				*dr = d
+				return true
+			}
+		}
+	}
+	*dr = nil
+	return false
+}
+
+// CreateDistributedRelay will create the Distributed Relay and attach it to
+// the aggregator, the aggregator must already exist
+func CreateDistributedRelay(cfg *DistributedRelayConfig) error {
+	var a *LaAggregator
+	var d *DistributedRelay
+
+	if DrFindByName(cfg.DrniName, &d) {
+		return errors.New(fmt.Sprintf("DRNI: Distributed Relay %s already exists", cfg.DrniName))
+	}
+	if cfg.DrniPortalSystemNumber < DrniPortalSystemNumberMin ||
+		cfg.DrniPortalSystemNumber > DrniPortalSystemNumberMax {
+		return errors.New(fmt.Sprintf("DRNI: Invalid Portal System Number %d", cfg.DrniPortalSystemNumber))
+	}
+	mac, err := net.ParseMAC(cfg.DrniPortalAddress)
+	if err != nil {
+		return errors.New(fmt.Sprintf("DRNI: Invalid Portal Address %s", cfg.DrniPortalAddress))
+	}
+	if !LaFindAggById(cfg.DrniAggregator, &a) {
+		return errors.New(fmt.Sprintf("DRNI: Unable to find aggregator %d", cfg.DrniAggregator))
+	}
+	if DrFindByAggId(cfg.DrniAggregator, &d) {
+		return errors.New(fmt.Sprintf("DRNI: Aggregator %d already part of Distributed Relay %s", cfg.DrniAggregator, d.DrniName))
+	}
+	var ipp *DRCPIpp
+	if LaFindPortById(cfg.DrniIntraPortalPortId, new(*LaAggPort)) ||
+		DrFindIppById(cfg.DrniIntraPortalPortId, &ipp) {
+		return errors.New(fmt.Sprintf("DRNI: Intra-Portal Port %d already in use", cfg.DrniIntraPortalPortId))
+	}
+
+	dr := &DistributedRelay{
+		DrniName:               cfg.DrniName,
+		DrniPortalAddr:         convertNetHwAddressToSysIdKey(mac),
+		DrniPortalPriority:     cfg.DrniPortalPriority,
+		DrniPortalSystemNumber: cfg.DrniPortalSystemNumber,
+		DrniAggregator:         cfg.DrniAggregator,
+		DrniGatewayAlgorithm:   cfg.DrniGatewayAlgorithm,
+		DrniConvAdminGateway:   cfg.DrniConvAdminGateway,
+		PSI:                    true,
+		LacpDebug:              NewLacpDebug(),
+	}
+
+	dr.DrniAttachAggregator(a)
+
+	sgi := LacpSysGlobalInfoByIdGet(dr.aggSysKey)
+	sgi.DistributedRelayMap[dr.DrniName] = dr
+	sgi.DistributedRelayList = append(sgi.DistributedRelayList, dr)
+
+	// until the neighbor is heard from all conversations are
+	// owned by this Portal System
+	dr.DrniUpdatePortalState()
+
+	// machines will move on from their initial State if the IPL is up
+	ipp = NewDRCPIpp(dr, cfg.DrniIntraPortalPortId, cfg.DrniIntraPortalIntf)
+	ipp.IsIppPortOperStatusUp()
+	ipp.BEGIN(false)
+
+	dr.DrniLog(fmt.Sprintf("Created Portal System %d aggregator %d ipp %s", dr.DrniPortalSystemNumber, dr.DrniAggregator, ipp.Intf))
+	return nil
+}
+
+// DeleteDistributedRelay will stop DRCP and restore the aggregator to its
+// own System Id
+func DeleteDistributedRelay(name string) error {
+	var dr *DistributedRelay
+	if !DrFindByName(name, &dr) {
+		return errors.New(fmt.Sprintf("DRNI: Unable to find Distributed Relay %s", name))
+	}
+
+	if ipp := dr.Ipp; ipp != nil {
+		// machines may still be updating the Portal state
+		dr.mutex.Lock()
+		dr.Ipp = nil
+		dr.mutex.Unlock()
+		ipp.DRCPIppDelete()
+	}
+
+	dr.DrniDetachAggregator()
+
+	if sgi := drniSysGlobalInfoGet(dr.aggSysKey); sgi != nil {
+		delete(sgi.DistributedRelayMap, dr.DrniName)
+		for i, d := range sgi.DistributedRelayList {
+			if d == dr {
+				sgi.DistributedRelayList = append(sgi.DistributedRelayList[:i], sgi.DistributedRelayList[i+1:]...)
+				break
+			}
+		}
+	}
+	dr.DrniLog("Deleted")
+	return nil
+}
+
+// DrniAttachAggregator will substitute the Portal System Id for the System Id
+// of the aggregator and its ports 802.1ax-2014 Section 9.3.2.  Other
+// aggregators of the System keep the System Id, ports added to the
+// aggregator later on are given the Portal System Id by AddLaAggPortToAgg
+func (dr *DistributedRelay) DrniAttachAggregator(a *LaAggregator) {
+	var p *LaAggPort
+
+	mac, _ := net.ParseMAC(a.Config.SystemIdMac)
+	dr.aggSysKey = LacpSystem{actor_System: convertNetHwAddressToSysIdKey(mac),
+		Actor_System_priority: a.Config.SystemPriority}
+
+	dr.a = a
+	a.dr = dr
+	dr.DRFHomeAdminAggregatorKey = a.actorAdminKey
+	dr.DRFHomeOperAggregatorKey = a.actorAdminKey
+
+	for _, pId := range a.PortNumList {
+		if LaFindPortById(pId, &p) {
+			dr.DrniPortActorInfoSet(p)
+		}
+	}
+}
+
+// DrniDetachAggregator will restore the System Id of the aggregator and its
+// ports
+func (dr *DistributedRelay) DrniDetachAggregator() {
+	var p *LaAggPort
+
+	a := dr.a
+	if a == nil {
+		return
+	}
+	a.dr = nil
+	dr.a = nil
+	for _, pId := range a.PortNumList {
+		if LaFindPortById(pId, &p) {
+			drniPortActorInfoSet(p, dr.aggSysKey, p.PortNum, p.Key)
+		}
+	}
+	// conversations are no longer shared with the neighbor
+	a.LacpUpdateConversationPortList()
+}
+
+// DrniPortActorInfoSet will set the Portal System Id, port and key of an
+// aggregation port which is part of the Distributed Relay.  Port Numbers
+// within the Portal are made unique by encoding the Portal System Number
+// in the two most significant bits 802.1ax-2014 Section 9.3.3
+func (dr *DistributedRelay) DrniPortActorInfoSet(p *LaAggPort) {
+	sysId := LacpSystem{actor_System: dr.DrniPortalAddr,
+		Actor_System_priority: dr.DrniPortalPriority}
+	port := p.PortNum&0x3fff | uint16(dr.DrniPortalSystemNumber)<<14
+	drniPortActorInfoSet(p, sysId, port, dr.DRFHomeOperAggregatorKey)
+}
+
+// DrniPortActorInfoInit is the same as DrniPortActorInfoSet but for ports
+// which are being added to the aggregator, selection is left to the caller
+func (dr *DistributedRelay) DrniPortActorInfoInit(p *LaAggPort) {
+	sysId := LacpSystem{actor_System: dr.DrniPortalAddr,
+		Actor_System_priority: dr.DrniPortalPriority}
+	port := p.PortNum&0x3fff | uint16(dr.DrniPortalSystemNumber)<<14
+	drniPortActorInfoInit(p, sysId, port, dr.DRFHomeOperAggregatorKey)
+}
+
+func drniPortActorInfoInit(p *LaAggPort, sysId LacpSystem, port uint16, key uint16) {
+	p.actorAdmin.System = sysId
+	p.ActorOper.System = sysId
+	p.actorAdmin.port = port
+	p.ActorOper.port = port
+	p.actorAdmin.Key = key
+	p.ActorOper.Key = key
+}
+
+func drniPortActorInfoSet(p *LaAggPort, sysId LacpSystem, port uint16, key uint16) {
+	drniPortActorInfoInit(p, sysId, port, key)
+
+	// partner will need to reselect
+	p.LaAggPortActorAdminInfoSet(sysId.actor_System, sysId.Actor_System_priority)
+
+	if p.IsPortOperStatusUp() &&
+		p.aggSelected == LacpAggUnSelected {
+		p.checkConfigForSelection()
+	}
+
+	if p.TxMachineFsm != nil {
+		p.TxMachineFsm.TxmEvents <- LacpMachineEvent{e: LacpTxmEventNtt,
+			src: DrniModuleStr}
+	}
+}
+
+// DrniHomeActivePorts DRF_Home_Active_Ports, the distributing ports of the
+// aggregator on the Home Portal System
+func (dr *DistributedRelay) DrniHomeActivePorts() []uint32 {
+	var p *LaAggPort
+
+	activePorts := make([]uint32, 0)
+	a := dr.a
+	if a == nil {
+		return activePorts
+	}
+	for _, pId := range a.PortNumList {
+		if LaFindPortById(pId, &p) {
+			for _, intf := range a.DistributedPortNumList {
+				if intf == p.IntfNum {
+					activePorts = append(activePorts, uint32(p.ActorOper.port))
+					break
+				}
+			}
+		}
+	}
+	sort.Sort(drniPortList(activePorts))
+	return activePorts
+}
+
+type drniPortList []uint32
+
+func (s drniPortList) Len() int           { return len(s) }
+func (s drniPortList) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s drniPortList) Less(i, j int) bool { return s[i] < s[j] }
+
+func drniPortListIsEqual(a, b []uint32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// DrniGatewayDigest Gateway_Digest 802.1ax-2014 Section 9.4.3.2, digest of
+// aDrniConvAdminGateway encoded in Gateway Conversation ID order as a list
+// of Portal System Numbers terminated by 0
+func (dr *DistributedRelay) DrniGatewayDigest() (digest [16]uint8) {
+	buf := make([]byte, 0)
+	for cid := 0; cid < LacpMaxConversationIds; cid++ {
+		buf = append(buf, dr.DrniConvAdminGateway[uint16(cid)]...)
+		buf = append(buf, 0)
+	}
+	digest = md5.Sum(buf)
+	return digest
+}
+
+// DrniPortDigest Port_Digest 802.1ax-2014 Section 9.4.3.2
+func (dr *DistributedRelay) DrniPortDigest() (digest [16]uint8) {
+	if dr.a != nil {
+		digest = dr.a.LacpConversationLinkListDigest()
+	}
+	return digest
+}
+
+// drniGatewayPriorityList will return the prioritized list of Portal Systems
+// for the Gateway Conversation ID.  When no admin list is configured, or the
+// Portal Systems do not agree on the admin config, even conversations prefer
+// Portal System 1 and odd conversations prefer Portal System 2
+func (dr *DistributedRelay) drniGatewayPriorityList(cid uint16, useAdmin bool) []uint8 {
+	if useAdmin {
+		if prio, ok := dr.DrniConvAdminGateway[cid]; ok && len(prio) > 0 {
+			return prio
+		}
+	}
+	if cid%2 == 0 {
+		return []uint8{DrniPortalSystemNumberMin, DrniPortalSystemNumberMax}
+	}
+	return []uint8{DrniPortalSystemNumberMax, DrniPortalSystemNumberMin}
+}
+
+// DrniUpdatePortalState 802.1ax-2014 Section 9.4.11 updatePortalState,
+// updateKey, setGatewayConversation and setPortConversation.  Should be called
+// whenever the Home or Neighbor Portal System information changes.  When the
+// neighbor is no longer heard from all Gateway and Port Conversations fail
+// over to the Home Portal System
+func (dr *DistributedRelay) DrniUpdatePortalState() {
+	dr.mutex.Lock()
+	ntt, keyChangedPorts := dr.drniUpdatePortalState()
+	ipp := dr.Ipp
+	dr.mutex.Unlock()
+
+	// the lock is not held while informing the machines as the mux
+	// machines of the aggregation ports also update the Portal state
+	for _, p := range keyChangedPorts {
+		if p.TxMachineFsm != nil {
+			p.TxMachineFsm.TxmEvents <- LacpMachineEvent{e: LacpTxmEventNtt,
+				src: DrniModuleStr}
+		}
+	}
+
+	if ntt &&
+		ipp != nil && ipp.PtxMachineFsm != nil {
+		// a pending NTT will already send the latest info
+		select {
+		case ipp.PtxMachineFsm.DrcpPtxmEvents <- LacpMachineEvent{e: DrcpPtxmEventNtt,
+			src: DrniModuleStr}:
+		default:
+		}
+	}
+}
+
+// drniUpdatePortalState will return true if the neighbor needs to be
+// informed of a change, along with the aggregation ports whose key has
+// changed and need to inform the partner
+func (dr *DistributedRelay) drniUpdatePortalState() (bool, []*LaAggPort) {
+	ipp := dr.Ipp
+	home := dr.DrniPortalSystemNumber
+	neighbor := dr.DrniPortalSystemNumberNeighbor()
+
+	neighborValid := ipp != nil && ipp.IsNeighborCurrent()
+	ntt := dr.PSI == neighborValid
+	dr.PSI = !neighborValid
+
+	keyChanged, keyChangedPorts := dr.drniUpdateKey(neighborValid)
+	if keyChanged {
+		ntt = true
+	}
+
+	homeActivePorts := dr.DrniHomeActivePorts()
+	if !drniPortListIsEqual(homeActivePorts, dr.homeActivePorts) {
+		dr.homeActivePorts = homeActivePorts
+		ntt = true
+	}
+
+	homeGateway := true
+	neighborGateway := neighborValid &&
+		LacpStateIsSet(ipp.DRFNeighborOperDRCPState, DrcpStateHomeGatewayBit)
+	homeHasPorts := len(homeActivePorts) > 0
+	neighborHasPorts := neighborValid && len(ipp.DRFNeighborActivePorts) > 0
+	// Portal Systems with differing admin config fall back to the
+	// default distribution so that they still agree
+	useAdmin := !neighborValid || !ipp.DifferGatewayDigest
+
+	var homeVector [LacpConversationMaskSize]uint8
+	var neighborVector [LacpConversationMaskSize]uint8
+	portConversationChanged := false
+	for cid := 0; cid < LacpMaxConversationIds; cid++ {
+		prio := dr.drniGatewayPriorityList(uint16(cid), useAdmin)
+
+		gateway := uint8(0)
+		for _, sys := range prio {
+			if (sys == home && homeGateway) ||
+				(sys == neighbor && neighborGateway) {
+				gateway = sys
+				break
+			}
+		}
+		dr.DrniGatewayConversation[cid] = gateway
+		if gateway == home {
+			LacpConversationMaskBitSet(&homeVector, uint16(cid))
+		} else if gateway == neighbor {
+			LacpConversationMaskBitSet(&neighborVector, uint16(cid))
+		}
+
+		// frames should not cross the IPL if it can be avoided, prefer
+		// the Portal System which has the gateway for the conversation
+		port := uint8(0)
+		for _, sys := range append([]uint8{gateway}, prio...) {
+			if (sys == home && homeHasPorts) ||
+				(sys == neighbor && neighborHasPorts) {
+				port = sys
+				break
+			}
+		}
+		if dr.DrniPortConversation[cid] != port {
+			dr.DrniPortConversation[cid] = port
+			portConversationChanged = true
+		}
+	}
+
+	if homeVector != dr.homeGatewayVector {
+		dr.homeGatewayVector = homeVector
+		dr.homeGatewaySequence++
+		ntt = true
+	}
+
+	if ipp != nil {
+		state := ipp.DRFHomeOperDRCPState
+		LacpStateSet(&state, DrcpStateHomeGatewayBit)
+		if neighborGateway {
+			LacpStateSet(&state, DrcpStateNeighborGatewayBit)
+		} else {
+			LacpStateClear(&state, DrcpStateNeighborGatewayBit)
+		}
+		// Gateway_Sync, both Portal Systems agree on the gateway
+		// conversations
+		if neighborValid &&
+			ipp.DRFRcvHomeGatewaySequence == dr.homeGatewaySequence &&
+			ipp.DRFNeighborGatewayVector == neighborVector {
+			LacpStateSet(&state, DrcpStateGatewaySyncBit)
+		} else {
+			LacpStateClear(&state, DrcpStateGatewaySyncBit)
+		}
+		// Port_Sync, neighbor has the current Home ports
+		if neighborValid &&
+			drniPortListIsEqual(ipp.DRFRcvHomeActivePorts, homeActivePorts) {
+			LacpStateSet(&state, DrcpStatePortSyncBit)
+		} else {
+			LacpStateClear(&state, DrcpStatePortSyncBit)
+		}
+		if state != ipp.DRFHomeOperDRCPState {
+			ipp.DRFHomeOperDRCPState = state
+			ntt = true
+		}
+	}
+
+	if portConversationChanged {
+		dr.DrniLog(fmt.Sprintf("Port Conversations updated psi %t home ports %v", dr.PSI, homeActivePorts))
+		if dr.a != nil {
+			dr.a.LacpUpdateConversationPortList()
+		}
+	}
+
+	return ntt, keyChangedPorts
+}
+
+// drniUpdateKey 802.1ax-2014 Section 9.4.11 updateKey, the Oper Aggregator
+// Key is the lowest non zero Admin Aggregator Key of the Portal Systems so
+// that the Partner sees the same key from both Portal Systems.  When isolated
+// the Portal System with the higher number uses a key which encodes its
+// Portal System Number so that the Partner will not aggregate links to two
+// Portal Systems which can no longer talk to each other.
+// Return true if the key has changed along with the ports which were
+// updated, the caller is responsible for sending NTT to the ports
+func (dr *DistributedRelay) drniUpdateKey(neighborValid bool) (bool, []*LaAggPort) {
+	var p *LaAggPort
+
+	key := dr.DRFHomeAdminAggregatorKey
+	if neighborValid {
+		nkey := dr.Ipp.DRFNeighborAdminAggregatorKey
+		if nkey != 0 && (key == 0 || nkey < key) {
+			key = nkey
+		}
+	} else if dr.DrniPortalSystemNumber != DrniPortalSystemNumberMin {
+		key = key&0x3fff | uint16(dr.DrniPortalSystemNumber)<<14
+	}
+
+	if key == dr.DRFHomeOperAggregatorKey {
+		return false, nil
+	}
+
+	dr.DrniLog(fmt.Sprintf("Oper Aggregator Key changed from %d to %d", dr.DRFHomeOperAggregatorKey, key))
+	dr.DRFHomeOperAggregatorKey = key
+	ports := make([]*LaAggPort, 0)
+	if dr.a != nil {
+		for _, pId := range dr.a.PortNumList {
+			if LaFindPortById(pId, &p) {
+				p.actorAdmin.Key = key
+				p.ActorOper.Key = key
+				ports = append(ports, p)
+			}
+		}
+	}
+	return true, ports
+}
+
+// DrniGatewayConversationIsHome will return true if frames of the Gateway
+// Conversation ID pass through the Home Gateway
+func (dr *DistributedRelay) DrniGatewayConversationIsHome(cid uint16) bool {
+	return int(cid) < LacpMaxConversationIds &&
+		dr.DrniGatewayConversation[cid] == dr.DrniPortalSystemNumber
+}
+
+// DrniPortConversationIsHome will return true if frames of the Port
+// Conversation ID are distributed on the Home aggregation ports
+func (dr *DistributedRelay) DrniPortConversationIsHome(cid uint16) bool {
+	return int(cid) < LacpMaxConversationIds &&
+		dr.DrniPortConversation[cid] == dr.DrniPortalSystemNumber
+}
+
+// NewDRCPIpp will create the Intra-Portal Port
+func NewDRCPIpp(dr *DistributedRelay, id uint16, intf string) *DRCPIpp {
+	ipp := &DRCPIpp{
+		Id:     id,
+		Intf:   intf,
+		dr:     dr,
+		logEna: true,
+	}
+	dr.Ipp = ipp
+
+	// Start Ipp Logger
+	ipp.DrcpDebugIppEventLogMain()
+
+	// Short Timeout until the neighbor is heard from
+	LacpStateSet(&ipp.DRFHomeOperDRCPState, DrcpStateDRCPTimeoutBit)
+
+	pktIoCfg := LacpPktIoConfigGet()
+	if pktIoCfg.Filter == "" {
+		pktIoCfg.Filter = LacpPktIoIppFilter
+	}
+	handle, err := pktio.Open(ipp.Intf, pktIoCfg)
+	if err != nil {
+		// failure here may be ok as this may be SIM
+		if !strings.Contains(ipp.Intf, "SIM") {
+			fmt.Println("Error creating", pktIoCfg.Type, "handle for ipp", ipp.Id, ipp.Intf, err)
+		}
+		return ipp
+	}
+	fmt.Println("Creating Listener for ipp intf", ipp.Intf)
+	ipp.handle = handle
+	in := ipp.handle.Packets()
+	// start rx routine
+	LaRxMain(ipp.Id, in)
+	return ipp
+}
+
+func (ipp *DRCPIpp) IsIppPortOperStatusUp() bool {
+	ipp.IppPortEnabled = LacpHwPluginGet().GetLinkState(ipp.Intf)
+	return ipp.IppPortEnabled
+}
+
+// IsNeighborCurrent will return true when the neighbor Portal System
+// information is valid
+func (ipp *DRCPIpp) IsNeighborCurrent() bool {
+	return ipp.neighborValid
+}
+
+// BEGIN will initiate the State machines of the Intra-Portal Port
+func (ipp *DRCPIpp) BEGIN(restart bool) {
+	if !restart {
+		// Rx Machine
+		ipp.DrcpRxMachineMain()
+		// Periodic Tx Machine
+		ipp.DrcpPtxMachineMain()
+	}
+
+	if ipp.RxMachineFsm != nil {
+		ipp.wg.Add(1)
+		ipp.RxMachineFsm.DrcpRxmEvents <- LacpMachineEvent{e: DrcpRxmEventBegin,
+			src: DrniModuleStr}
+	}
+	if ipp.PtxMachineFsm != nil {
+		ipp.wg.Add(1)
+		ipp.PtxMachineFsm.DrcpPtxmEvents <- LacpMachineEvent{e: DrcpPtxmEventBegin,
+			src: DrniModuleStr}
+	}
+}
+
+// DrcpIppEnabled IPP_port_enabled && DRCP_Enabled
+func (ipp *DRCPIpp) DrcpIppEnabled() {
+	ipp.IppPortEnabled = true
+	ipp.RxMachineFsm.DrcpRxmEvents <- LacpMachineEvent{e: DrcpRxmEventIppEnabled,
+		src: DrniModuleStr}
+	ipp.PtxMachineFsm.DrcpPtxmEvents <- LacpMachineEvent{e: DrcpPtxmEventIppEnabled,
+		src: DrniModuleStr}
+}
+
+// DrcpIppDisabled will inform the machines that the IPL is down
+func (ipp *DRCPIpp) DrcpIppDisabled() {
+	ipp.IppPortEnabled = false
+	ipp.RxMachineFsm.DrcpRxmEvents <- LacpMachineEvent{e: DrcpRxmEventIppDisabled,
+		src: DrniModuleStr}
+	ipp.PtxMachineFsm.DrcpPtxmEvents <- LacpMachineEvent{e: DrcpPtxmEventIppDisabled,
+		src: DrniModuleStr}
+}
+
+// DRCPIppDelete will stop all the State machines of the Intra-Portal Port
+func (ipp *DRCPIpp) DRCPIppDelete() {
+	if ipp.RxMachineFsm != nil {
+		ipp.RxMachineFsm.Stop()
+	}
+	if ipp.PtxMachineFsm != nil {
+		ipp.PtxMachineFsm.Stop()
+	}
+	// lets wait for all the State machines to have stopped
+	ipp.wg.Wait()
+
+	if ipp.handle != nil {
+		ipp.handle.Close()
+	}
+
+	ipp.LacpDebug.logger.Info(fmt.Sprintf("Logger stopped for ipp %d", ipp.Id))
+	ipp.LacpDebug.Stop()
+}
+
+// DrcpPduGet will fill in the DRCPDU from the Home Portal System info
+func (ipp *DRCPIpp) DrcpPduGet() *DRCPDU {
+	dr := ipp.dr
+
+	dr.mutex.Lock()
+	defer dr.mutex.Unlock()
+
+	topology := dr.DrniPortalSystemNumber & DrcpTopologyStatePortalSystemNumberMask
+	topology |= (dr.DrniPortalSystemNumberNeighbor() << DrcpTopologyStateNeighborConfPortalSystemShift) & DrcpTopologyStateNeighborConfPortalSystemMask
+
+	var operPartnerKey uint16
+	var portAlgorithm uint32
+	if dr.a != nil {
+		operPartnerKey = uint16(dr.a.PartnerOperKey)
+		portAlgorithm = dr.a.PortAlgorithm
+	}
+
+	pdu := &DRCPDU{
+		Version: DrcpVersion,
+		PortalInfo: DrcpPortalInfoTlv{
+			AggPriority:    dr.aggSysKey.Actor_System_priority,
+			AggId:          dr.aggSysKey.actor_System,
+			PortalPriority: dr.DrniPortalPriority,
+			PortalAddr:     dr.DrniPortalAddr,
+		},
+		PortalConfigInfo: DrcpPortalConfigInfoTlv{
+			TopologyState:    topology,
+			OperAggKey:       dr.DRFHomeOperAggregatorKey,
+			PortAlgorithm:    portAlgorithm,
+			GatewayAlgorithm: dr.DrniGatewayAlgorithm,
+			PortDigest:       dr.DrniPortDigest(),
+			GatewayDigest:    dr.DrniGatewayDigest(),
+		},
+		State: ipp.DRFHomeOperDRCPState,
+		HomePortsInfo: DrcpPortsInfoTlv{
+			AdminAggKey:       dr.DRFHomeAdminAggregatorKey,
+			OperPartnerAggKey: operPartnerKey,
+			ActivePorts:       dr.homeActivePorts,
+		},
+		NeighborPortsInfo: DrcpPortsInfoTlv{
+			AdminAggKey:       ipp.DRFNeighborAdminAggregatorKey,
+			OperPartnerAggKey: ipp.DRFNeighborOperPartnerAggregatorKey,
+			ActivePorts:       ipp.DRFNeighborActivePorts,
+		},
+		HomeGatewayVector: DrcpHomeGatewayVectorTlv{
+			Sequence: dr.homeGatewaySequence,
+			Vector:   dr.homeGatewayVector,
+		},
+		NeighborGatewayVector: DrcpNeighborGatewayVectorTlv{
+			Sequence: ipp.DRFNeighborGatewaySequence,
+		},
+	}
+	return pdu
+}
+
+// DrcpTxPdu will transmit a DRCPDU on the Intra-Portal Link
+func (ipp *DRCPIpp) DrcpTxPdu() {
+	if !ipp.IppPortEnabled {
+		return
+	}
+	if ipp.DrcpIppTx(ipp.DrcpPduGet()) {
+		ipp.DrcpCounter.DRCPDUsTx += 1
+	}
+}
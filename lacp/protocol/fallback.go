@@ -0,0 +1,168 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// fallback allows a port whose partner does not run LACP (i.e. a server
+// PXE booting without a bonding driver) to come up as an individual link
+package lacp
+
+import (
+	"fmt"
+)
+
+// IsFallback returns true when the port is forwarding as an individual
+// link because of fallback
+func (p *LaAggPort) IsFallback() bool {
+	return p.fallback
+}
+
+// LacpFallbackIndividual returns true when the port has fallen back to an
+// individual link, the port is detached from the aggregator and must not be
+// selected until an LACPDU is received
+func (p *LaAggPort) LacpFallbackIndividual() bool {
+	var a *LaAggregator
+	return p.fallback &&
+		LaFindAggById(p.AggId, &a) &&
+		a.FallbackMode == LacpFallbackModeIndividual
+}
+
+// LacpFallbackHold returns true when the port is Defaulted on an aggregator
+// with fallback configured and has not yet fallen back, such ports must not
+// move past Attached
+func (p *LaAggPort) LacpFallbackHold() bool {
+	var a *LaAggregator
+	return p.lacpEnabled &&
+		!p.fallback &&
+		LacpStateIsSet(p.ActorOper.State, LacpStateDefaultedBit) &&
+		LaFindAggById(p.AggId, &a) &&
+		a.FallbackMode != LacpFallbackModeNone
+}
+
+// LacpRxMachineFallbackTimerStart will start the fallback timer if the
+// aggregator has fallback configured
+func (rxm *LacpRxMachine) LacpRxMachineFallbackTimerStart() {
+	p := rxm.p
+	var a *LaAggregator
+
+	if !p.lacpEnabled || p.fallback {
+		return
+	}
+
+	if LaFindAggById(p.AggId, &a) &&
+		a.FallbackMode != LacpFallbackModeNone {
+		rxm.LacpRxmLog(fmt.Sprintf("Starting Fallback Timer %s", a.FallbackTimeout))
+		rxm.FallbackTimerTimeoutSet(a.FallbackTimeout)
+		rxm.FallbackTimerStart()
+	}
+}
+
+// LacpRxMachineFallback is called when the fallback timer expires, no
+// LACPDU has been received so the port will come up as an individual
+// link.  In static mode only one port per aggregator may fallback and it
+// forwards as the only member of the lag.  In individual mode each port
+// is detached from the aggregator and forwards as a non aggregated link
+func (rxm *LacpRxMachine) LacpRxMachineFallback() {
+	p := rxm.p
+	var a *LaAggregator
+
+	if rxm.Machine.Curr.CurrentState() != LacpRxmStateDefaulted ||
+		p.fallback ||
+		!LaFindAggById(p.AggId, &a) ||
+		a.FallbackMode == LacpFallbackModeNone {
+		return
+	}
+
+	if a.FallbackMode == LacpFallbackModeStatic {
+		if a.fallbackPortNum != 0 &&
+			a.fallbackPortNum != p.PortNum {
+			rxm.LacpRxmLog(fmt.Sprintf("Fallback not allowed port %d already in fallback", a.fallbackPortNum))
+			return
+		}
+		a.fallbackPortNum = p.PortNum
+	}
+
+	rxm.LacpRxmLog("Entering Fallback, no LACPDU received")
+	p.fallback = true
+
+	if a.FallbackMode == LacpFallbackModeIndividual {
+		LacpStateClear(&p.ActorOper.State, LacpStateAggregationBit)
+		if p.MuxMachineFsm != nil &&
+			p.aggSelected != LacpAggUnSelected {
+			p.aggSelected = LacpAggUnSelected
+			p.MuxMachineFsm.MuxmEvents <- LacpMachineEvent{e: LacpMuxmEventSelectedEqualUnselected,
+				src: RxMachineModuleStr}
+		}
+		return
+	}
+
+	// treat the partner as in sync, collecting and distributing
+	LacpStateSet(&p.partnerAdmin.State, LacpStateAggregatibleUp)
+	rxm.recordDefault()
+
+	if p.MuxMachineFsm == nil {
+		return
+	}
+	switch p.MuxMachineFsm.Machine.Curr.CurrentState() {
+	case LacpMuxmStateDetached, LacpMuxmStateCDetached:
+		// port may have been unselected when the partner info was defaulted
+		p.checkConfigForSelection()
+	case LacpMuxmStateCollecting:
+		p.MuxMachineFsm.MuxmEvents <- LacpMachineEvent{e: LacpMuxmEventSelectedEqualSelectedPartnerSyncCollecting,
+			src: RxMachineModuleStr}
+	}
+}
+
+// LacpRxMachineFallbackExit will stop the fallback timer and if the port
+// was in fallback will allow another Defaulted port to take its place
+func (rxm *LacpRxMachine) LacpRxMachineFallbackExit() {
+	p := rxm.p
+	var a *LaAggregator
+	var op *LaAggPort
+
+	rxm.FallbackTimerStop()
+
+	if !p.fallback {
+		return
+	}
+
+	rxm.LacpRxmLog("Exiting Fallback")
+	p.fallback = false
+	// an individual port may aggregate again
+	if LacpStateIsSet(p.actorAdmin.State, LacpStateAggregationBit) {
+		LacpStateSet(&p.ActorOper.State, LacpStateAggregationBit)
+	}
+
+	if LaFindAggById(p.AggId, &a) &&
+		a.fallbackPortNum == p.PortNum {
+		a.fallbackPortNum = 0
+		// the other ports rx machines will restart their own
+		// fallback timer if still Defaulted
+		for _, pId := range a.PortNumList {
+			if pId != p.PortNum &&
+				LaFindPortById(pId, &op) &&
+				op.RxMachineFsm != nil {
+				op.DistributeMachineEvents([]chan LacpMachineEvent{op.RxMachineFsm.RxmEvents},
+					[]LacpMachineEvent{LacpMachineEvent{e: LacpRxmEventFallbackRetry}}, false)
+			}
+		}
+	}
+}
@@ -26,6 +26,7 @@ package lacp
 
 import (
 	"fmt"
+	"l2/pktio"
 	"net"
 	//"github.com/google/gopacket/layers"
 	"time"
@@ -33,7 +34,24 @@ import (
 
 var LacpStartTime time.Time
 
-type TxCallback func(port uint16, data interface{})
+// Slow Protocols and DRCPDU frames are all that lacp needs to receive
+const (
+	LacpPktIoPortFilter = "ether proto 0x8809"
+	LacpPktIoIppFilter  = "ether proto 0x8952"
+)
+
+// packet I/O backend used when opening the port and ipp interfaces
+var gLacpPktIoConfig = pktio.DefaultConfig()
+
+// LacpPktIoConfigSet will select the packet I/O backend used by
+// ports and ipps created after this call
+func LacpPktIoConfigSet(cfg pktio.Config) {
+	gLacpPktIoConfig = cfg
+}
+
+func LacpPktIoConfigGet() pktio.Config {
+	return gLacpPktIoConfig
+}
 
 type PortIdKey struct {
 	Name string
@@ -61,8 +79,9 @@ type LacpSysGlobalInfo struct {
 	// false == NOT COUPLING, true == COUPLING
 	muxCoupling bool
 
-	// list of tx function which should be called for a given port
-	TxCallbacks map[string][]TxCallback
+	// Distributed Relays of the System
+	DistributedRelayMap  map[string]*DistributedRelay
+	DistributedRelayList []*DistributedRelay
 }
 
 // holds default lacp State info
@@ -115,9 +134,10 @@ func LacpSysGlobalInfoInit(sysId LacpSystem) *LacpSysGlobalInfo {
 			AggList:                    make([]*LaAggregator, 0),
 			SystemDefaultParams:        LacpSystem{Actor_System_priority: 0x8000},
 			PartnerSystemDefaultParams: LacpSystem{Actor_System_priority: 0x0},
-			TxCallbacks:                make(map[string][]TxCallback),
 			SysKey:                     sysKey,
 		}
+		gLacpSysGlobalInfo[sysKey].DistributedRelayMap = make(map[string]*DistributedRelay)
+		gLacpSysGlobalInfo[sysKey].DistributedRelayList = make([]*DistributedRelay, 0)
 
 		gLacpSysGlobalInfoList = append(gLacpSysGlobalInfoList, gLacpSysGlobalInfo[sysKey])
 
@@ -163,38 +183,3 @@ func (g *LacpSysGlobalInfo) LacpSysGlobalAggListGet() []*LaAggregator {
 func (g *LacpSysGlobalInfo) LacpSysGlobalAggPortListGet() []*LaAggPort {
 	return g.PortList
 }
-
-func (g *LacpSysGlobalInfo) LaSysGlobalRegisterTxCallback(intf string, f TxCallback) {
-	g.TxCallbacks[intf] = append(g.TxCallbacks[intf], f)
-}
-
-func (g *LacpSysGlobalInfo) LaSysGlobalDeRegisterTxCallback(intf string) {
-	delete(g.TxCallbacks, intf)
-}
-
-func LaSysGlobalTxCallbackListGet(p *LaAggPort) []TxCallback {
-
-	var a *LaAggregator
-	var sysId LacpSystem
-	if LaFindAggById(p.AggId, &a) {
-		mac, _ := net.ParseMAC(a.Config.SystemIdMac)
-		sysId.actor_System = convertNetHwAddressToSysIdKey(mac)
-		sysId.Actor_System_priority = a.Config.SystemPriority
-	}
-	if s, sok := gLacpSysGlobalInfo[sysId]; sok {
-		if fList, pok := s.TxCallbacks[p.IntfNum]; pok {
-			return fList
-		}
-	}
-
-	// temporary function
-	x := func(port uint16, data interface{}) {
-		p.LacpDebug.logger.Info(fmt.Sprintf("TX not registered for port\n", p.IntfNum, p.portId))
-		//lacp := data.(*layers.LACP)
-		//fmt.Printf("%#v\n", *lacp)
-	}
-
-	debugTxList := make([]TxCallback, 0)
-	debugTxList = append(debugTxList, x)
-	return debugTxList
-}
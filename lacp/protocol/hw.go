@@ -137,31 +137,31 @@ func asicDHashModeGet(hashmode uint32) (laghash int32) {
 }
 
 // create the lag with hashing algorithm and ports
-func asicDCreateLag(a *LaAggregator) (hwAggId int32) {
+func asicDCreateLag(hashmode uint32, ports []string) (hwAggId int32, err error) {
 	if asicdclnt.ClientHdl != nil {
-		hwAggId, _ = asicdclnt.ClientHdl.CreateLag(asicDHashModeGet(a.LagHash),
-			asicDPortBmpFormatGet(a.DistributedPortNumList))
-		a.LacpDebug.logger.Info(fmt.Sprintf("asicDCreateLag : id %d hash %d hwhash %d portList %s\n", hwAggId, a.LagHash, asicDHashModeGet(a.LagHash), asicDPortBmpFormatGet(a.DistributedPortNumList)))
+		hwAggId, err = asicdclnt.ClientHdl.CreateLag(asicDHashModeGet(hashmode),
+			asicDPortBmpFormatGet(ports))
 	}
-	return hwAggId
+	return hwAggId, err
 }
 
 // delete the lag
-func asicDDeleteLag(a *LaAggregator) {
+func asicDDeleteLag(hwAggId int32) (err error) {
 	if asicdclnt.ClientHdl != nil {
-		asicdclnt.ClientHdl.DeleteLag(a.HwAggId)
+		_, err = asicdclnt.ClientHdl.DeleteLag(hwAggId)
 	}
+	return err
 }
 
 // update the lag ports or hashing algorithm
-func asicDUpdateLag(a *LaAggregator) {
+func asicDUpdateLag(hwAggId int32, hashmode uint32, ports []string) (err error) {
 
 	if asicdclnt.ClientHdl != nil {
-		asicdclnt.ClientHdl.UpdateLag(a.HwAggId,
-			asicDHashModeGet(a.LagHash),
-			asicDPortBmpFormatGet(a.DistributedPortNumList))
-		a.LacpDebug.logger.Info(fmt.Sprintf("asicDUpdateLag : id %d hash %d hwhash %d portList %s\n", a.HwAggId, a.LagHash, asicDHashModeGet(a.LagHash), asicDPortBmpFormatGet(a.DistributedPortNumList)))
+		_, err = asicdclnt.ClientHdl.UpdateLag(hwAggId,
+			asicDHashModeGet(hashmode),
+			asicDPortBmpFormatGet(ports))
 	}
+	return err
 }
 
 func asicdGetPortLinkStatus(intfNum string) bool {
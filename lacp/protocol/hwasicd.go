@@ -0,0 +1,91 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// hwasicd.go
+package lacp
+
+import (
+	"sync"
+)
+
+// AsicdLagHwPlugin programs the lag via the asicd thrift client, calls are
+// a no-op when asicd is not connected
+type AsicdLagHwPlugin struct {
+	mutex sync.Mutex
+	// asicd updates the hash and the ports together, keep the ports
+	// last programmed per lag for hash updates
+	ports map[int32][]string
+}
+
+func NewAsicdLagHwPlugin() *AsicdLagHwPlugin {
+	return &AsicdLagHwPlugin{
+		ports: make(map[int32][]string),
+	}
+}
+
+func (h *AsicdLagHwPlugin) Name() string {
+	return LagHwPluginAsicd
+}
+
+func (h *AsicdLagHwPlugin) CreateLag(cfg LagHwConfig, ports []string) (int32, error) {
+	hwAggId, err := asicDCreateLag(cfg.HashMode, ports)
+	if err == nil {
+		h.mutex.Lock()
+		h.ports[hwAggId] = append([]string(nil), ports...)
+		h.mutex.Unlock()
+	}
+	return hwAggId, err
+}
+
+func (h *AsicdLagHwPlugin) UpdateLagMembers(hwAggId int32, cfg LagHwConfig, ports []string) error {
+	h.mutex.Lock()
+	h.ports[hwAggId] = append([]string(nil), ports...)
+	h.mutex.Unlock()
+	return asicDUpdateLag(hwAggId, cfg.HashMode, ports)
+}
+
+func (h *AsicdLagHwPlugin) DeleteLag(hwAggId int32, cfg LagHwConfig) error {
+	h.mutex.Lock()
+	delete(h.ports, hwAggId)
+	h.mutex.Unlock()
+	return asicDDeleteLag(hwAggId)
+}
+
+func (h *AsicdLagHwPlugin) SetLagHash(hwAggId int32, cfg LagHwConfig) error {
+	h.mutex.Lock()
+	ports := h.ports[hwAggId]
+	h.mutex.Unlock()
+	return asicDUpdateLag(hwAggId, cfg.HashMode, ports)
+}
+
+// SetLagPortConversations asicd has no per member conversation selection,
+// the lag distributes across all members by hash.  Ports remain
+// conversation sensitive in the protocol so the partner will report
+// any disagreement via the CDS churn counters
+func (h *AsicdLagHwPlugin) SetLagPortConversations(hwAggId int32, cfg LagHwConfig, intf string, conv LagHwPortConversations) error {
+	return nil
+}
+
+func (h *AsicdLagHwPlugin) GetLinkState(intf string) bool {
+	return asicdGetPortLinkStatus(intf)
+}
@@ -0,0 +1,232 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// hwlinux.go
+package lacp
+
+import (
+	"fmt"
+	"github.com/vishvananda/netlink"
+	"l2/lacp/lalinux"
+	"net"
+	"strings"
+	"sync"
+)
+
+// LinuxLagHwPlugin programs the lag as a linux bond (see lalinux), lacp is
+// run by lacpd so the bond is created in balance-xor mode.  A link must be
+// down to be enslaved, so the members are enslaved when they are attached
+// to the aggregator rather than when they start distributing, and the bond
+// is only up while the lag exists
+type LinuxLagHwPlugin struct {
+	mutex sync.Mutex
+	// links brought down in order to enslave/release them, their link
+	// down events are not reported until the link is back up
+	bouncing map[string]bool
+}
+
+func NewLinuxLagHwPlugin() *LinuxLagHwPlugin {
+	return &LinuxLagHwPlugin{
+		bouncing: make(map[string]bool),
+	}
+}
+
+func (h *LinuxLagHwPlugin) Name() string {
+	return LagHwPluginLinux
+}
+
+// linuxBondName the hw id (bond ifindex) is not known before the bond is
+// created so the name of the aggregator is used unless it is not a valid
+// interface name
+func linuxBondName(cfg LagHwConfig) string {
+	if cfg.Name != "" &&
+		len(cfg.Name) < 16 &&
+		!strings.ContainsAny(cfg.Name, "/ ") {
+		return cfg.Name
+	}
+	return fmt.Sprintf("bond%d", cfg.Id)
+}
+
+// linuxBondGet will create the bond if it does not exist
+func linuxBondGet(cfg LagHwConfig) (netlink.Link, error) {
+	bondname := linuxBondName(cfg)
+	if bond, err := netlink.LinkByName(bondname); err == nil {
+		return bond, nil
+	}
+	return lalinux.BondLinkCreate(bondname, cfg.Mac.String(), cfg.HashMode, 1)
+}
+
+// linuxBondIsMember returns true when the link is enslaved to the bond
+func linuxBondIsMember(bond netlink.Link, intf string) bool {
+	link, err := netlink.LinkByName(intf)
+	return err == nil &&
+		link.Attrs().MasterIndex == bond.Attrs().Index
+}
+
+// linuxBondDeleteUnused deletes the bond once it is down and has no members
+func linuxBondDeleteUnused(cfg LagHwConfig) error {
+	bondname := linuxBondName(cfg)
+	bond, err := netlink.LinkByName(bondname)
+	if err != nil {
+		return nil
+	}
+	slaves, err := lalinux.BondSlavesGet(bondname)
+	if err != nil {
+		return err
+	}
+	if len(slaves) > 0 ||
+		bond.Attrs().Flags&net.FlagUp == net.FlagUp {
+		return nil
+	}
+	return lalinux.BondLinkDelete(bondname)
+}
+
+// CreateLag the distributing ports have already been enslaved when they
+// were attached, the bond is brought up
+func (h *LinuxLagHwPlugin) CreateLag(cfg LagHwConfig, ports []string) (int32, error) {
+	bond, err := linuxBondGet(cfg)
+	if err != nil {
+		return 0, err
+	}
+	return int32(bond.Attrs().Index), lalinux.BondLinkUpSet(linuxBondName(cfg), true)
+}
+
+// UpdateLagMembers the bond has no per slave distribution, the members
+// follow the attached ports, see AttachLagPort
+func (h *LinuxLagHwPlugin) UpdateLagMembers(hwAggId int32, cfg LagHwConfig, ports []string) error {
+	return nil
+}
+
+// DeleteLag the bond is brought down, it is deleted once the attached
+// ports have been released
+func (h *LinuxLagHwPlugin) DeleteLag(hwAggId int32, cfg LagHwConfig) error {
+	if err := lalinux.BondLinkUpSet(linuxBondName(cfg), false); err != nil {
+		return err
+	}
+	return linuxBondDeleteUnused(cfg)
+}
+
+func (h *LinuxLagHwPlugin) SetLagHash(hwAggId int32, cfg LagHwConfig) error {
+	return lalinux.BondHashModeSet(linuxBondName(cfg), cfg.HashMode)
+}
+
+// SetLagPortConversations the bond selects the slave by xmit_hash_policy
+// only, there is no per slave conversation selection
+func (h *LinuxLagHwPlugin) SetLagPortConversations(hwAggId int32, cfg LagHwConfig, intf string, conv LagHwPortConversations) error {
+	return nil
+}
+
+// AttachLagPort enslaves the port, this brings the link down and up so it
+// is done before Actor Sync is reported to the partner
+func (h *LinuxLagHwPlugin) AttachLagPort(cfg LagHwConfig, intf string) error {
+	bond, err := linuxBondGet(cfg)
+	if err != nil {
+		return err
+	}
+	if linuxBondIsMember(bond, intf) {
+		return nil
+	}
+	h.linkBounceStart(intf)
+	err = lalinux.AddLinkToBond(linuxBondName(cfg), intf)
+	if err != nil {
+		h.linkBounceStop(intf)
+	}
+	return err
+}
+
+// DetachLagPort releases the port, the bond is deleted with the last port
+// unless the lag still exists
+func (h *LinuxLagHwPlugin) DetachLagPort(cfg LagHwConfig, intf string) error {
+	bond, err := netlink.LinkByName(linuxBondName(cfg))
+	if err != nil ||
+		!linuxBondIsMember(bond, intf) {
+		return nil
+	}
+	h.linkBounceStart(intf)
+	if err := lalinux.DelLinkFromBond(linuxBondName(cfg), intf); err != nil {
+		h.linkBounceStop(intf)
+		return err
+	}
+	return linuxBondDeleteUnused(cfg)
+}
+
+func (h *LinuxLagHwPlugin) linkBounceStart(intf string) {
+	h.mutex.Lock()
+	h.bouncing[intf] = true
+	h.mutex.Unlock()
+}
+
+func (h *LinuxLagHwPlugin) linkBounceStop(intf string) {
+	h.mutex.Lock()
+	delete(h.bouncing, intf)
+	h.mutex.Unlock()
+}
+
+// linkBounceFilter returns true when the link event was caused by an
+// enslave/release of the link and should not be reported
+func (h *LinuxLagHwPlugin) linkBounceFilter(intf string, up bool) bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if !h.bouncing[intf] {
+		return false
+	}
+	if up {
+		delete(h.bouncing, intf)
+		return false
+	}
+	return true
+}
+
+func (h *LinuxLagHwPlugin) GetLinkState(intf string) bool {
+	link, err := netlink.LinkByName(intf)
+	if err != nil {
+		// in the case of tests we may not find the actual link
+		return true
+	}
+	return linuxLinkUp(link.Attrs())
+}
+
+func linuxLinkUp(attrs *netlink.LinkAttrs) bool {
+	return attrs.Flags&net.FlagUp == net.FlagUp &&
+		attrs.OperState != netlink.OperDown &&
+		attrs.OperState != netlink.OperLowerLayerDown
+}
+
+// LinkEventsSubscribe lacpd is not connected to asicd when the linux plugin
+// is used so the link up/down of the ports is learned from netlink
+func (h *LinuxLagHwPlugin) LinkEventsSubscribe(cb func(intf string, up bool), done <-chan struct{}) error {
+	ch := make(chan netlink.LinkUpdate, 64)
+	if err := netlink.LinkSubscribe(ch, done); err != nil {
+		return err
+	}
+	go func() {
+		for update := range ch {
+			attrs := update.Link.Attrs()
+			up := linuxLinkUp(attrs)
+			if !h.linkBounceFilter(attrs.Name, up) {
+				cb(attrs.Name, up)
+			}
+		}
+	}()
+	return nil
+}
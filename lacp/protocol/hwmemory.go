@@ -0,0 +1,196 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// hwmemory.go
+package lacp
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+const (
+	LagHwOpCreate  = "CreateLag"
+	LagHwOpUpdate  = "UpdateLagMembers"
+	LagHwOpDelete  = "DeleteLag"
+	LagHwOpSetHash = "SetLagHash"
+	LagHwOpSetConv = "SetLagPortConversations"
+)
+
+// MemoryLag is a lag as programmed in the memory plugin
+type MemoryLag struct {
+	Config LagHwConfig
+	Ports  []string
+	// Conversations per member, a member without an entry passes
+	// all conversations
+	Conversations map[string]LagHwPortConversations
+}
+
+// LagHwCall records a call made to the memory plugin
+type LagHwCall struct {
+	Op       string
+	HwAggId  int32
+	HashMode uint32
+	Ports    []string
+}
+
+// MemoryLagHwPlugin keeps the lags in memory, used by the simulator and by
+// tests to assert what was programmed
+type MemoryLagHwPlugin struct {
+	mutex     sync.Mutex
+	nextAggId int32
+	lags      map[int32]*MemoryLag
+	calls     []LagHwCall
+	// LinkState link state returned per port, defaults to up
+	LinkState map[string]bool
+}
+
+func NewMemoryLagHwPlugin() *MemoryLagHwPlugin {
+	return &MemoryLagHwPlugin{
+		nextAggId: 1,
+		lags:      make(map[int32]*MemoryLag),
+		LinkState: make(map[string]bool),
+	}
+}
+
+func (h *MemoryLagHwPlugin) Name() string {
+	return LagHwPluginMemory
+}
+
+func sortedPorts(ports []string) []string {
+	p := append([]string(nil), ports...)
+	sort.Strings(p)
+	return p
+}
+
+func (h *MemoryLagHwPlugin) CreateLag(cfg LagHwConfig, ports []string) (int32, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	hwAggId := h.nextAggId
+	h.nextAggId++
+	h.lags[hwAggId] = &MemoryLag{Config: cfg, Ports: sortedPorts(ports), Conversations: make(map[string]LagHwPortConversations)}
+	h.calls = append(h.calls, LagHwCall{Op: LagHwOpCreate, HwAggId: hwAggId, HashMode: cfg.HashMode, Ports: sortedPorts(ports)})
+	return hwAggId, nil
+}
+
+func (h *MemoryLagHwPlugin) UpdateLagMembers(hwAggId int32, cfg LagHwConfig, ports []string) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.calls = append(h.calls, LagHwCall{Op: LagHwOpUpdate, HwAggId: hwAggId, HashMode: cfg.HashMode, Ports: sortedPorts(ports)})
+	lag, ok := h.lags[hwAggId]
+	if !ok {
+		return errors.New(fmt.Sprintf("Unknown lag %d", hwAggId))
+	}
+	lag.Ports = sortedPorts(ports)
+	// members which left the lag no longer have conversations
+	for intf, _ := range lag.Conversations {
+		member := false
+		for _, p := range lag.Ports {
+			member = member || p == intf
+		}
+		if !member {
+			delete(lag.Conversations, intf)
+		}
+	}
+	return nil
+}
+
+func (h *MemoryLagHwPlugin) DeleteLag(hwAggId int32, cfg LagHwConfig) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.calls = append(h.calls, LagHwCall{Op: LagHwOpDelete, HwAggId: hwAggId})
+	if _, ok := h.lags[hwAggId]; !ok {
+		return errors.New(fmt.Sprintf("Unknown lag %d", hwAggId))
+	}
+	delete(h.lags, hwAggId)
+	return nil
+}
+
+func (h *MemoryLagHwPlugin) SetLagHash(hwAggId int32, cfg LagHwConfig) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.calls = append(h.calls, LagHwCall{Op: LagHwOpSetHash, HwAggId: hwAggId, HashMode: cfg.HashMode})
+	lag, ok := h.lags[hwAggId]
+	if !ok {
+		return errors.New(fmt.Sprintf("Unknown lag %d", hwAggId))
+	}
+	lag.Config.HashMode = cfg.HashMode
+	return nil
+}
+
+func (h *MemoryLagHwPlugin) SetLagPortConversations(hwAggId int32, cfg LagHwConfig, intf string, conv LagHwPortConversations) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.calls = append(h.calls, LagHwCall{Op: LagHwOpSetConv, HwAggId: hwAggId, Ports: []string{intf}})
+	lag, ok := h.lags[hwAggId]
+	if !ok {
+		return errors.New(fmt.Sprintf("Unknown lag %d", hwAggId))
+	}
+	lag.Conversations[intf] = conv
+	return nil
+}
+
+func (h *MemoryLagHwPlugin) GetLinkState(intf string) bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if up, ok := h.LinkState[intf]; ok {
+		return up
+	}
+	return true
+}
+
+// Lag returns a copy of the lag programmed with the hw id
+func (h *MemoryLagHwPlugin) Lag(hwAggId int32) (MemoryLag, bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	lag, ok := h.lags[hwAggId]
+	if !ok {
+		return MemoryLag{}, false
+	}
+	conv := make(map[string]LagHwPortConversations, len(lag.Conversations))
+	for intf, c := range lag.Conversations {
+		conv[intf] = c
+	}
+	return MemoryLag{Config: lag.Config, Ports: append([]string(nil), lag.Ports...), Conversations: conv}, true
+}
+
+// Calls returns a copy of the calls recorded, optionally filtered by op
+func (h *MemoryLagHwPlugin) Calls(op string) []LagHwCall {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	calls := make([]LagHwCall, 0)
+	for _, c := range h.calls {
+		if op == "" || c.Op == op {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+func (h *MemoryLagHwPlugin) Reset() {
+	h.mutex.Lock()
+	h.calls = nil
+	h.mutex.Unlock()
+}
@@ -0,0 +1,146 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// hwplugin.go
+package lacp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"sync"
+)
+
+const (
+	LagHwPluginAsicd  = "asicd"
+	LagHwPluginLinux  = "linux"
+	LagHwPluginMemory = "memory"
+)
+
+// LagHwConfig is the aggregator info needed to create a lag in hw
+type LagHwConfig struct {
+	Id       int
+	Name     string
+	Mac      net.HardwareAddr
+	HashMode uint32
+}
+
+// LagHwPortConversations are the conversation ids a lag member may
+// distribute and collect, 802.1ax-2014 Section 6.6 Conversation-sensitive
+// Collection and Distribution.  All conversations are set when the port
+// is not conversation sensitive
+type LagHwPortConversations struct {
+	DistributeMask [LacpConversationMaskSize]uint8
+	// Discard_Wrong_Conversation, frames of conversations not in the
+	// mask are discarded on receive
+	CollectMask [LacpConversationMaskSize]uint8
+}
+
+// LagHwPlugin abstracts the programming of the aggregator in hw.  The lag
+// only exists in hw while the aggregator is operationally up, ports are
+// the names of the distributing ports
+type LagHwPlugin interface {
+	Name() string
+	// CreateLag returns the hw id of the lag
+	CreateLag(cfg LagHwConfig, ports []string) (int32, error)
+	UpdateLagMembers(hwAggId int32, cfg LagHwConfig, ports []string) error
+	DeleteLag(hwAggId int32, cfg LagHwConfig) error
+	SetLagHash(hwAggId int32, cfg LagHwConfig) error
+	// SetLagPortConversations restricts the conversations distributed
+	// and collected on a member of the lag
+	SetLagPortConversations(hwAggId int32, cfg LagHwConfig, intf string, conv LagHwPortConversations) error
+	// GetLinkState returns true when the link of the port is up
+	GetLinkState(intf string) bool
+}
+
+// LagHwLinkEvents is implemented by the plugins which report the link
+// up/down of the ports themselves, with asicd the link events are received
+// from the asicd notifications
+type LagHwLinkEvents interface {
+	// LinkEventsSubscribe calls cb on each link change until done is closed
+	LinkEventsSubscribe(cb func(intf string, up bool), done <-chan struct{}) error
+}
+
+// LagHwPortAttach is implemented by the plugins which add a port to the lag
+// when it is attached to the aggregator rather than when it is distributing
+type LagHwPortAttach interface {
+	AttachLagPort(cfg LagHwConfig, intf string) error
+	DetachLagPort(cfg LagHwConfig, intf string) error
+}
+
+var gLagHwPluginMutex sync.RWMutex
+var gLagHwPlugin LagHwPlugin = NewAsicdLagHwPlugin()
+
+// LacpHwPluginSet should be called before any aggregator is created
+func LacpHwPluginSet(p LagHwPlugin) {
+	gLagHwPluginMutex.Lock()
+	gLagHwPlugin = p
+	gLagHwPluginMutex.Unlock()
+}
+
+func LacpHwPluginGet() LagHwPlugin {
+	gLagHwPluginMutex.RLock()
+	defer gLagHwPluginMutex.RUnlock()
+	return gLagHwPlugin
+}
+
+type LacpHwConfigJson struct {
+	HwPlugin string `json:"HwPlugin"`
+}
+
+// LacpHwPluginFromParams will create the plugin configured in
+// lacpd.conf of the params directory, asicd is used when the file
+// does not exist or does not specify a plugin
+func LacpHwPluginFromParams(paramsDir string) (LagHwPlugin, error) {
+	var cfg LacpHwConfigJson
+
+	fileName := paramsDir + "lacpd.conf"
+	bytes, err := ioutil.ReadFile(fileName)
+	if err == nil {
+		err = json.Unmarshal(bytes, &cfg)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Error in Unmarshalling %s: %s", fileName, err))
+		}
+	}
+
+	switch cfg.HwPlugin {
+	case "", LagHwPluginAsicd:
+		return NewAsicdLagHwPlugin(), nil
+	case LagHwPluginLinux:
+		return NewLinuxLagHwPlugin(), nil
+	case LagHwPluginMemory:
+		return NewMemoryLagHwPlugin(), nil
+	}
+	return nil, errors.New(fmt.Sprintf("Unsupported hw plugin %s in %s", cfg.HwPlugin, fileName))
+}
+
+func (a *LaAggregator) lagHwConfigGet() LagHwConfig {
+	return LagHwConfig{
+		Id:       a.AggId,
+		Name:     a.AggName,
+		Mac:      net.HardwareAddr(a.aggMacAddr[:]),
+		HashMode: a.LagHash,
+	}
+}
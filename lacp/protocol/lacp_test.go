@@ -29,8 +29,9 @@ package lacp
 
 import (
 	"fmt"
-	"github.com/google/gopacket"
 	"github.com/google/gopacket/layers"
+	"l2/clock"
+	"l2/pktio"
 	"net"
 	"testing"
 	"time"
@@ -1095,17 +1096,10 @@ func TestTwoAggsBackToBackSinglePort(t *testing.T) {
 	LaSystemPeer := LacpSystem{Actor_System_priority: 128,
 		actor_System: [6]uint8{0x00, 0x00, 0x00, 0x00, 0x00, 0xC8}}
 
-	bridge := SimulationBridge{
-		port1:       LaAggPortActor,
-		port2:       LaAggPortPeer,
-		rxLacpPort1: make(chan gopacket.Packet, 10),
-		rxLacpPort2: make(chan gopacket.Packet, 10),
-	}
+	LacpSysGlobalInfoInit(LaSystemActor)
+	LacpSysGlobalInfoInit(LaSystemPeer)
 
-	ActorSystem := LacpSysGlobalInfoInit(LaSystemActor)
-	PeerSystem := LacpSysGlobalInfoInit(LaSystemPeer)
-	ActorSystem.LaSysGlobalRegisterTxCallback(LaAggPortActorIf, bridge.TxViaGoChannel)
-	PeerSystem.LaSysGlobalRegisterTxCallback(LaAggPortPeerIf, bridge.TxViaGoChannel)
+	defer UsedForTestOnlyLacpPktIoMemory([2]string{LaAggPortActorIf, LaAggPortPeerIf})()
 
 	p1conf := &LaAggPortConfig{
 		Id:     LaAggPortActor,
@@ -1146,11 +1140,6 @@ func TestTwoAggsBackToBackSinglePort(t *testing.T) {
 	CreateLaAggPort(p1conf)
 	CreateLaAggPort(p2conf)
 
-	// port 1
-	LaRxMain(bridge.port1, bridge.rxLacpPort1)
-	// port 2
-	LaRxMain(bridge.port2, bridge.rxLacpPort2)
-
 	a1conf := &LaAggConfig{
 		Mac: [6]uint8{0x00, 0x00, 0x01, 0x01, 0x01, 0x01},
 		Id:  100,
@@ -1217,8 +1206,6 @@ func TestTwoAggsBackToBackSinglePort(t *testing.T) {
 		t.Error("Unable to find port just created")
 	}
 
-	bridge.rxLacpPort1 = nil
-	bridge.rxLacpPort2 = nil
 	// cleanup the provisioning
 	DeleteLaAgg(a1conf.Id)
 	DeleteLaAgg(a2conf.Id)
@@ -1235,7 +1222,14 @@ func TestTwoAggsBackToBackSinglePort(t *testing.T) {
 // TestTwoAggsBackToBackSinglePortTimeout will allow for
 // two ports to sync up then force a timeout by disabling
 // one end of the connection by setting the mode to "ON"
-func xTestTwoAggsBackToBackSinglePortTimeout(t *testing.T) {
+func TestTwoAggsBackToBackSinglePortTimeout(t *testing.T) {
+
+	// timers are stepped by the test so the timeout does not
+	// depend on the wall clock
+	clk := clock.NewManualClock(time.Now())
+	prevClk := LacpClockGet()
+	LacpClockSet(clk)
+	defer LacpClockSet(prevClk)
 
 	const LaAggPortActor = 11
 	const LaAggPortPeer = 21
@@ -1247,22 +1241,10 @@ func xTestTwoAggsBackToBackSinglePortTimeout(t *testing.T) {
 	LaSystemPeer := LacpSystem{Actor_System_priority: 128,
 		actor_System: [6]uint8{0x00, 0x00, 0x00, 0x00, 0x00, 0xC8}}
 
-	bridge := SimulationBridge{
-		port1:       LaAggPortActor,
-		port2:       LaAggPortPeer,
-		rxLacpPort1: make(chan gopacket.Packet),
-		rxLacpPort2: make(chan gopacket.Packet),
-	}
+	LacpSysGlobalInfoInit(LaSystemActor)
+	LacpSysGlobalInfoInit(LaSystemPeer)
 
-	ActorSystem := LacpSysGlobalInfoInit(LaSystemActor)
-	PeerSystem := LacpSysGlobalInfoInit(LaSystemPeer)
-	ActorSystem.LaSysGlobalRegisterTxCallback(LaAggPortActorIf, bridge.TxViaGoChannel)
-	PeerSystem.LaSysGlobalRegisterTxCallback(LaAggPortPeerIf, bridge.TxViaGoChannel)
-
-	// port 1
-	go LaRxMain(bridge.port1, bridge.rxLacpPort1)
-	// port 2
-	go LaRxMain(bridge.port2, bridge.rxLacpPort2)
+	defer UsedForTestOnlyLacpPktIoMemory([2]string{LaAggPortActorIf, LaAggPortPeerIf})()
 
 	p1conf := &LaAggPortConfig{
 		Id:      LaAggPortActor,
@@ -1332,24 +1314,15 @@ func xTestTwoAggsBackToBackSinglePortTimeout(t *testing.T) {
 	AddLaAggPortToAgg(a1conf.Key, p1conf.Id)
 	AddLaAggPortToAgg(a2conf.Key, p2conf.Id)
 
-	//time.Sleep(time.Second * 30)
-	testWait := make(chan bool)
-
 	var p1 *LaAggPort
 	var p2 *LaAggPort
 	if LaFindPortById(p1conf.Id, &p1) &&
 		LaFindPortById(p2conf.Id, &p2) {
 
-		go func() {
-			for i := 0; i < 10 &&
-				(p1.MuxMachineFsm.Machine.Curr.CurrentState() != LacpMuxmStateDistributing ||
-					p2.MuxMachineFsm.Machine.Curr.CurrentState() != LacpMuxmStateDistributing); i++ {
-				time.Sleep(time.Second * 1)
-			}
-			testWait <- true
-		}()
-
-		<-testWait
+		UsedForTestOnlyLacpAdvanceUntil(clk, 20, func() bool {
+			return p1.MuxMachineFsm.Machine.Curr.CurrentState() == LacpMuxmStateDistributing &&
+				p2.MuxMachineFsm.Machine.Curr.CurrentState() == LacpMuxmStateDistributing
+		})
 
 		State1 := GetLaAggPortActorOperState(p1conf.Id)
 		State2 := GetLaAggPortActorOperState(p2conf.Id)
@@ -1369,26 +1342,12 @@ func xTestTwoAggsBackToBackSinglePortTimeout(t *testing.T) {
 		// Lets disable lacp for p1
 		SetLaAggPortLacpMode(p1conf.Id, LacpModeOn)
 
-		go func() {
-			var i int
-			for i = 0; i < 10 &&
-				(p1.RxMachineFsm.Machine.Curr.CurrentState() != LacpRxmStateDefaulted ||
-					p1.MuxMachineFsm.Machine.Curr.CurrentState() != LacpMuxmStateDetached ||
-					p2.RxMachineFsm.Machine.Curr.CurrentState() != LacpRxmStateDefaulted ||
-					p2.MuxMachineFsm.Machine.Curr.CurrentState() != LacpMuxmStateDetached); i++ {
-
-				time.Sleep(time.Second * 1)
-			}
-
-			if i == 10 {
-				testWait <- false
-			} else {
-				testWait <- true
-			}
-
-		}()
-
-		testResult := <-testWait
+		testResult := UsedForTestOnlyLacpAdvanceUntil(clk, 20, func() bool {
+			return p1.RxMachineFsm.Machine.Curr.CurrentState() == LacpRxmStateLacpDisabled &&
+				p1.MuxMachineFsm.Machine.Curr.CurrentState() == LacpMuxmStateDetached &&
+				p2.RxMachineFsm.Machine.Curr.CurrentState() == LacpRxmStateDefaulted &&
+				p2.MuxMachineFsm.Machine.Curr.CurrentState() == LacpMuxmStateDetached
+		})
 		if !testResult {
 			t.Error(fmt.Sprintf("Actor and Peer States are not correct Expected P1 RXM/MUX",
 				LacpRxmStateLacpDisabled, LacpMuxmStateDetached, "Actual", p1.RxMachineFsm.Machine.Curr.CurrentState(),
@@ -1450,3 +1409,1294 @@ func xTestLaAggPortTxMachineInvalidStateTransitions(t *testing.T) {
 // TODO add more tests
 // 1) invalid events on stats
 // 2) pkt events
+
+func TestLacpV2PduEncodeDecode(t *testing.T) {
+
+	lacppdu := &layers.LACP{
+		Version: layers.LACPVersion2,
+		Actor: layers.LACPInfoTlv{TlvType: layers.LACPTLVActorInfo,
+			Length: layers.LACPActorTlvLength,
+			Info: layers.LACPPortInfo{
+				System: layers.LACPSystem{SystemId: [6]uint8{0x00, 0x01, 0x02, 0x03, 0x04, 0x05},
+					SystemPriority: 128},
+				Key:     100,
+				PortPri: 0x80,
+				Port:    10,
+				State:   LacpStateActivityBit | LacpStateAggregationBit,
+			},
+		},
+		Partner: layers.LACPInfoTlv{TlvType: layers.LACPTLVPartnerInfo,
+			Length: layers.LACPActorTlvLength,
+		},
+		Collector: layers.LACPCollectorInfoTlv{
+			TlvType: layers.LACPTLVCollectorInfo,
+			Length:  layers.LACPCollectorTlvLength,
+		},
+	}
+
+	pdu := &LacpV2Pdu{
+		Lacp: lacppdu,
+		V2: LacpV2Info{
+			PortAlgorithm:            LacpPortAlgorithmCVID,
+			LinkNumberId:             10,
+			ConvLinkListDigest:       [16]uint8{0x01, 0x02, 0x03},
+			ConvServiceMappingDigest: [16]uint8{0x0a, 0x0b, 0x0c},
+		},
+	}
+
+	// short pdu is padded to the minimum size
+	data := LacpV2PduEncode(pdu)
+	if len(data) != LacpPduMinLength {
+		t.Error("Short LACPDU length expected", LacpPduMinLength, "actual", len(data))
+	}
+
+	v2 := LacpV2Info{}
+	if err := LacpV2PduDecode(data, &v2); err != nil {
+		t.Error("Failed to decode short LACPDU", err)
+	}
+	if v2 != pdu.V2 {
+		t.Error("Short LACPDU decode mismatch expected", pdu.V2, "actual", v2)
+	}
+
+	// long pdu contains the conversation masks
+	pdu.V2.Long = true
+	pdu.V2.MaskState = LacpMaskStateActParSyncBit | LacpMaskStateDiscardWrongConversationBit
+	for _, cid := range []uint16{0, 1023, 1024, 2050, 4095} {
+		LacpConversationMaskBitSet(&pdu.V2.ConvMask, cid)
+	}
+
+	data = LacpV2PduEncode(pdu)
+	v2 = LacpV2Info{}
+	if err := LacpV2PduDecode(data, &v2); err != nil {
+		t.Error("Failed to decode long LACPDU", err)
+	}
+	if v2 != pdu.V2 {
+		t.Error("Long LACPDU decode mismatch expected", pdu.V2, "actual", v2)
+	}
+	if !LacpConversationMaskBitIsSet(&v2.ConvMask, 2050) ||
+		LacpConversationMaskBitIsSet(&v2.ConvMask, 2049) {
+		t.Error("Long LACPDU conversation mask incorrect")
+	}
+
+	// version 1 pdu should not be decoded
+	data[0] = LacpVersion1
+	if err := LacpV2PduDecode(data, &v2); err == nil {
+		t.Error("Expected failure decoding Version 1 LACPDU")
+	}
+}
+
+func TestLacpConversationPortList(t *testing.T) {
+
+	// must be called to initialize the global
+	sysId := LacpSystem{Actor_System_priority: 128,
+		actor_System: [6]uint8{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}}
+	sgi := LacpSysGlobalInfoInit(sysId)
+
+	aconf := &LaAggConfig{
+		Id:  3000,
+		Key: 300,
+		Lacp: LacpConfigInfo{Interval: LacpSlowPeriodicTime,
+			Mode:           LacpModeActive,
+			SystemIdMac:    "00:01:02:03:04:05",
+			SystemPriority: 128},
+		Version: LacpVersion2,
+		// conversation 10 prefers link 3 then link 1
+		ConversationAdminLink: map[uint16][]uint16{10: []uint16{3, 1}},
+	}
+	a := NewLaAggregator(aconf)
+
+	ports := make([]*LaAggPort, 0)
+	for i := uint16(1); i <= 3; i++ {
+		p := &LaAggPort{
+			PortNum:      i,
+			IntfNum:      fmt.Sprintf("SIMeth3.%d", i),
+			LinkNumberId: i,
+			actorVersion: LacpVersion2,
+		}
+		ports = append(ports, p)
+		sgi.PortList = append(sgi.PortList, p)
+		a.PortNumList = append(a.PortNumList, i)
+	}
+
+	// links 1 and 2 distributing
+	a.DistributedPortNumList = append(a.DistributedPortNumList, ports[0].IntfNum, ports[1].IntfNum)
+	a.LacpUpdateConversationPortList()
+
+	if a.LacpConversationPortGet(0) != 1 ||
+		a.LacpConversationPortGet(1) != 2 ||
+		a.LacpConversationPortGet(4095) != 2 {
+		t.Error("Conversation ids not distributed across active links")
+	}
+	if a.LacpConversationPortGet(10) != 1 {
+		t.Error("Conversation 10 expected on port 1 actual", a.LacpConversationPortGet(10))
+	}
+	if LacpConversationMaskBitIsSet(&ports[1].actorConversationMask, 10) ||
+		!LacpConversationMaskBitIsSet(&ports[1].actorConversationMask, 1) {
+		t.Error("Port 2 conversation mask incorrect")
+	}
+
+	// conversation passes only on the assigned link
+	ports[0].partnerVersion = LacpVersion2
+	ports[0].actParSync = true
+	LacpStateSet(&ports[0].ActorOper.State, LacpStateDistributingBit)
+	if !ports[0].LacpConversationPasses(10) ||
+		ports[0].LacpConversationPasses(1) {
+		t.Error("Port 1 conversation passes incorrect")
+	}
+
+	// discard wrong conversation
+	ports[1].partnerVersion = LacpVersion2
+	ports[1].discardWrongConversation = true
+	LacpStateSet(&ports[1].ActorOper.State, LacpStateCollectingBit)
+	if ports[1].LacpConversationCollect(0) ||
+		!ports[1].LacpConversationCollect(1) {
+		t.Error("Port 2 discard wrong conversation incorrect")
+	}
+
+	// partner is version 1 all conversations are collected
+	ports[1].partnerVersion = LacpVersion1
+	if !ports[1].LacpConversationCollect(0) {
+		t.Error("Port 2 expected to collect all conversations with Version 1 partner")
+	}
+
+	// link 3 is joining, conversations moving to it are stopped on
+	// their current link until the Marker completes
+	a.LacpConversationStopMoving(ports[2])
+	if a.LacpConversationPortGet(10) != 0 ||
+		a.LacpConversationPortGet(2) != 0 {
+		t.Error("Conversations moving to port 3 expected to be stopped")
+	}
+	if a.LacpConversationPortGet(0) != 1 ||
+		a.LacpConversationPortGet(1) != 2 {
+		t.Error("Conversations not moving expected to stay on their link")
+	}
+	if LacpConversationMaskBitIsSet(&ports[0].actorConversationMask, 10) ||
+		!LacpConversationMaskBitIsSet(&ports[0].actorConversationMask, 0) {
+		t.Error("Port 1 conversation mask incorrect while conversations stopped")
+	}
+
+	// link 3 becomes active conversation 10 moves to preferred link
+	a.DistributedPortNumList = append(a.DistributedPortNumList, ports[2].IntfNum)
+	a.LacpUpdateConversationPortList()
+	if a.LacpConversationPortGet(10) != 3 {
+		t.Error("Conversation 10 expected on port 3 actual", a.LacpConversationPortGet(10))
+	}
+
+	// masks are programmed in hw once the lag exists
+	hw := NewMemoryLagHwPlugin()
+	prevHw := LacpHwPluginGet()
+	LacpHwPluginSet(hw)
+	defer LacpHwPluginSet(prevHw)
+	a.LacpAggOperStateUpdate(LacpNotifyReasonNone)
+	for _, p := range ports {
+		p.AggAttached = a
+		p.partnerVersion = LacpVersion2
+		p.actParSync = true
+		p.discardWrongConversation = true
+		p.actorConversationMask = a.LacpConversationMaskGet(p.PortNum)
+		p.LacpConversationHwUpdate(p.LacpConversationHwGet())
+	}
+	lag, _ := hw.Lag(a.HwAggId)
+	conv, ok := lag.Conversations[ports[2].IntfNum]
+	if !ok ||
+		!LacpConversationMaskBitIsSet(&conv.DistributeMask, 10) ||
+		!LacpConversationMaskBitIsSet(&conv.CollectMask, 10) {
+		t.Error("Conversation 10 expected to be programmed on port 3", lag.Conversations)
+	}
+	if conv = lag.Conversations[ports[0].IntfNum]; LacpConversationMaskBitIsSet(&conv.DistributeMask, 10) ||
+		LacpConversationMaskBitIsSet(&conv.CollectMask, 10) {
+		t.Error("Conversation 10 not expected to be programmed on port 1")
+	}
+
+	// partner negotiates down to Version 1, all conversations pass
+	ports[0].partnerVersion = LacpVersion1
+	ports[0].LacpConversationHwUpdate(ports[0].LacpConversationHwGet())
+	lag, _ = hw.Lag(a.HwAggId)
+	if conv = lag.Conversations[ports[0].IntfNum]; conv != LacpConversationHwAllGet() {
+		t.Error("Expected all conversations to be programmed on port 1 with Version 1 partner")
+	}
+
+	// digests are only regenerated on config change
+	digest := a.LacpConversationLinkListDigest()
+	a.ConversationAdminLink = map[uint16][]uint16{10: []uint16{1}}
+	if a.LacpConversationLinkListDigest() != digest {
+		t.Error("Expected cached digest until the config is applied")
+	}
+	SetLaAggConversationAdminLink(aconf.Id, a.ConversationAdminLink)
+	if a.LacpConversationLinkListDigest() == digest {
+		t.Error("Expected digest to change with conversation admin link")
+	}
+
+	// mask updates to a port whose machine is no longer running must
+	// not block the aggregator
+	ports[0].CsCdMachineFsm = &LacpCsCdMachine{csCdmUpdateMaskEvent: make(chan bool, 1)}
+	a.LacpUpdateConversationPortList()
+	a.LacpUpdateConversationPortList()
+	ports[0].CsCdMachineFsm = nil
+
+	sgi.PortList = sgi.PortList[:0]
+	a.DeleteLaAgg()
+	for _, sgi := range LacpSysGlobalInfoGet() {
+		if len(sgi.AggList) > 0 || len(sgi.AggMap) > 0 {
+			t.Error("System Agg List or Map is not empty", sgi.AggList, sgi.AggMap)
+		}
+	}
+}
+
+func TestLampMarkerPduEncodeDecode(t *testing.T) {
+
+	pdu := &LaMarkerPdu{
+		TlvType:                LampTlvTypeMarkerInfo,
+		RequesterPort:          10,
+		RequesterSystem:        [6]uint8{0x00, 0x01, 0x02, 0x03, 0x04, 0x05},
+		RequesterTransactionId: 0x01020304,
+	}
+
+	data := LampMarkerPduEncode(pdu)
+	if len(data) != LampPduLength {
+		t.Error("Marker PDU length expected", LampPduLength, "actual", len(data))
+	}
+
+	// response is a copy of the marker with the tlv type changed
+	data[1] = LampTlvTypeMarkerResponse
+
+	rsp := &LaMarkerPdu{}
+	if err := LampMarkerPduDecode(data, rsp); err != nil {
+		t.Error("Marker PDU decode failed", err)
+	}
+	if rsp.TlvType != LampTlvTypeMarkerResponse ||
+		rsp.RequesterPort != pdu.RequesterPort ||
+		rsp.RequesterSystem != pdu.RequesterSystem ||
+		rsp.RequesterTransactionId != pdu.RequesterTransactionId {
+		t.Error("Marker PDU decode mismatch expected", pdu, "actual", rsp)
+	}
+
+	// invalid tlv type
+	data[1] = 0x03
+	if err := LampMarkerPduDecode(data, rsp); err == nil {
+		t.Error("Marker PDU decode expected error on invalid tlv type")
+	}
+
+	// too short
+	if err := LampMarkerPduDecode(data[:5], rsp); err == nil {
+		t.Error("Marker PDU decode expected error on short pdu")
+	}
+}
+
+// TestLampMarkerFlushAsync will verify that a flush does not block the
+// caller, completes on a Marker Response or a timeout and that a flush to
+// a deleted port does not panic
+func TestLampMarkerFlushAsync(t *testing.T) {
+
+	clk := clock.NewManualClock(time.Now())
+	prevClk := LacpClockGet()
+	LacpClockSet(clk)
+	defer LacpClockSet(prevClk)
+
+	var p *LaAggPort
+
+	// must be called to initialize the global
+	sysId := LacpSystem{Actor_System_priority: 128,
+		actor_System: [6]uint8{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}}
+	LacpSysGlobalInfoInit(sysId)
+
+	pconf := &LaAggPortConfig{
+		Id:     50,
+		Prio:   0x80,
+		Key:    500,
+		AggId:  500,
+		Enable: false,
+		Mode:   LacpModeActive,
+		Properties: PortProperties{
+			Mac:    net.HardwareAddr{0x00, 0x32, 0xDE, 0xAD, 0xBE, 0xEF},
+			Speed:  1000000000,
+			Duplex: LacpPortDuplexFull,
+			Mtu:    1500,
+		},
+		IntfId:   "SIMeth5.0",
+		TraceEna: false,
+	}
+	CreateLaAggPort(pconf)
+	if !LaFindPortById(pconf.Id, &p) {
+		t.Fatal("Unable to find port just created")
+	}
+	mg := p.MarkerGeneratorFsm
+
+	flushed := make(chan bool, 1)
+	isFlushed := func() bool {
+		select {
+		case <-flushed:
+			return true
+		default:
+			return false
+		}
+	}
+	waiting := func() bool {
+		return mg.Machine.Curr.CurrentState() == LampMarkerGeneratorStateWaitForResponse
+	}
+
+	// the caller is not blocked while waiting for the response
+	LampMarkerFlush([]*LaAggPort{p}, func() { flushed <- true })
+	for i := 0; i < 100 && !waiting(); i++ {
+		time.Sleep(time.Millisecond * 10)
+	}
+	if !waiting() {
+		t.Fatal("Expected Marker Generator to wait for response actual",
+			LampMarkerGeneratorStateStrMap[mg.Machine.Curr.CurrentState()])
+	}
+	if isFlushed() {
+		t.Error("Flush completed before Marker Response was received")
+	}
+
+	// matching response completes the flush
+	ProcessLampResponseFrame(p.PortNum, &LaMarkerPdu{
+		TlvType:                LampTlvTypeMarkerResponse,
+		RequesterPort:          p.PortNum,
+		RequesterSystem:        p.ActorOper.System.actor_System,
+		RequesterTransactionId: mg.transactionId,
+	})
+	rcvd := false
+	for i := 0; i < 100 && !rcvd; i++ {
+		rcvd = isFlushed()
+		time.Sleep(time.Millisecond * 10)
+	}
+	if !rcvd {
+		t.Error("Expected flush to complete on Marker Response")
+	}
+
+	// no response the flush completes on timeout
+	LampMarkerFlush([]*LaAggPort{p}, func() { flushed <- true })
+	if !UsedForTestOnlyLacpAdvanceUntil(clk, 10, isFlushed) {
+		t.Error("Expected flush to complete on Marker Response timeout")
+	}
+	if p.LacpCounter.AggPortStatsMarkerResponseTimeouts != 1 {
+		t.Error("Expected one Marker Response timeout actual", p.LacpCounter.AggPortStatsMarkerResponseTimeouts)
+	}
+
+	// port is deleted, request must not be sent to the stopped machine
+	DeleteLaAggPort(pconf.Id)
+	if mg.LampMarkerFlushRequest(make(chan bool, 1)) {
+		t.Error("Expected flush request to fail on stopped Marker Generator")
+	}
+	LampMarkerFlush([]*LaAggPort{p}, func() { flushed <- true })
+	if !UsedForTestOnlyLacpAdvanceUntil(clk, 10, isFlushed) {
+		t.Error("Expected flush to complete on deleted port")
+	}
+	for _, sgi := range LacpSysGlobalInfoGet() {
+		if len(sgi.PortList) > 0 || len(sgi.PortMap) > 0 {
+			t.Error("System Port List or Map is not empty", sgi.PortList, sgi.PortMap)
+		}
+	}
+}
+
+func TestDRCPDUEncodeDecode(t *testing.T) {
+
+	pdu := &DRCPDU{
+		Version: DrcpVersion,
+		PortalInfo: DrcpPortalInfoTlv{
+			AggPriority:    128,
+			AggId:          [6]uint8{0x00, 0x00, 0x00, 0x00, 0x00, 0x64},
+			PortalPriority: 100,
+			PortalAddr:     [6]uint8{0x00, 0x00, 0x00, 0xAA, 0xBB, 0xCC},
+		},
+		PortalConfigInfo: DrcpPortalConfigInfoTlv{
+			TopologyState:    1 | 2<<DrcpTopologyStateNeighborConfPortalSystemShift,
+			OperAggKey:       100,
+			PortAlgorithm:    1,
+			GatewayAlgorithm: 2,
+			PortDigest:       [16]uint8{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+			GatewayDigest:    [16]uint8{16, 15, 14, 13, 12, 11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1},
+		},
+		State: DrcpStateHomeGatewayBit | DrcpStateDRCPTimeoutBit,
+		HomePortsInfo: DrcpPortsInfoTlv{
+			AdminAggKey:       100,
+			OperPartnerAggKey: 200,
+			ActivePorts:       []uint32{0x4001, 0x4002},
+		},
+		NeighborPortsInfo: DrcpPortsInfoTlv{
+			AdminAggKey: 150,
+		},
+		HomeGatewayVector: DrcpHomeGatewayVectorTlv{
+			Sequence: 5,
+		},
+		NeighborGatewayVector: DrcpNeighborGatewayVectorTlv{
+			Sequence: 7,
+		},
+	}
+	LacpConversationMaskBitSet(&pdu.HomeGatewayVector.Vector, 4094)
+
+	data := DRCPDUEncode(pdu)
+
+	rx := &DRCPDU{}
+	if err := DRCPDUDecode(data, rx); err != nil {
+		t.Error("DRCPDU decode failed", err)
+	}
+	if rx.Version != pdu.Version ||
+		rx.PortalInfo != pdu.PortalInfo ||
+		rx.PortalConfigInfo != pdu.PortalConfigInfo ||
+		rx.State != pdu.State ||
+		rx.HomeGatewayVector != pdu.HomeGatewayVector ||
+		rx.NeighborGatewayVector != pdu.NeighborGatewayVector {
+		t.Error("DRCPDU decode mismatch expected", pdu, "actual", rx)
+	}
+	if rx.HomePortsInfo.AdminAggKey != 100 ||
+		rx.HomePortsInfo.OperPartnerAggKey != 200 ||
+		!drniPortListIsEqual(rx.HomePortsInfo.ActivePorts, pdu.HomePortsInfo.ActivePorts) {
+		t.Error("DRCPDU Home Ports Info mismatch", rx.HomePortsInfo)
+	}
+	if rx.NeighborPortsInfo.AdminAggKey != 150 ||
+		len(rx.NeighborPortsInfo.ActivePorts) != 0 {
+		t.Error("DRCPDU Neighbor Ports Info mismatch", rx.NeighborPortsInfo)
+	}
+
+	// frames may be padded after the terminator
+	padded := append(append([]byte{}, data...), make([]byte, 20)...)
+	if err := DRCPDUDecode(padded, rx); err != nil {
+		t.Error("DRCPDU decode of padded frame failed", err)
+	}
+
+	// invalid subtype
+	bad := append([]byte{}, data...)
+	bad[0] = 0x02
+	if err := DRCPDUDecode(bad, &DRCPDU{}); err == nil {
+		t.Error("DRCPDU decode expected error on invalid subtype")
+	}
+
+	// truncated within a TLV
+	if err := DRCPDUDecode(data[:10], &DRCPDU{}); err == nil {
+		t.Error("DRCPDU decode expected error on truncated pdu")
+	}
+}
+
+// TestDrniTwoPortalSystemsFailover will create a Portal out of two systems
+// connected by an Intra-Portal Link, each with a single link to a server.
+// The server should aggregate both links as the Portal Systems present the
+// same Portal System Id.  When one Portal System is removed the remaining
+// Portal System should take over all the Gateway Conversations
+func TestDrniTwoPortalSystemsFailover(t *testing.T) {
+
+	const LaAggPortA = 11
+	const LaAggPortB = 12
+	const LaAggPortS1 = 21
+	const LaAggPortS2 = 22
+	const IppA = 51
+	const IppB = 52
+	LaAggPortAIf := "SIMAeth0"
+	LaAggPortBIf := "SIMBeth0"
+	LaAggPortS1If := "SIMS1eth0"
+	LaAggPortS2If := "SIMS2eth0"
+	IppAIf := "SIMAipl0"
+	IppBIf := "SIMBipl0"
+
+	LaSystemA := LacpSystem{Actor_System_priority: 128,
+		actor_System: [6]uint8{0x00, 0x00, 0x00, 0x00, 0x00, 0x64}}
+	LaSystemB := LacpSystem{Actor_System_priority: 128,
+		actor_System: [6]uint8{0x00, 0x00, 0x00, 0x00, 0x00, 0x65}}
+	LaSystemS := LacpSystem{Actor_System_priority: 128,
+		actor_System: [6]uint8{0x00, 0x00, 0x00, 0x00, 0x00, 0xC8}}
+
+	SystemA := LacpSysGlobalInfoInit(LaSystemA)
+	SystemB := LacpSysGlobalInfoInit(LaSystemB)
+	LacpSysGlobalInfoInit(LaSystemS)
+
+	// A and B are each wired to the partner system S, the ipl
+	// connects the two portal systems
+	defer UsedForTestOnlyLacpPktIoMemory(
+		[2]string{LaAggPortAIf, LaAggPortS1If},
+		[2]string{LaAggPortBIf, LaAggPortS2If},
+		[2]string{IppAIf, IppBIf})()
+
+	portConf := func(id uint16, key uint16, intf string) *LaAggPortConfig {
+		return &LaAggPortConfig{
+			Id:     id,
+			Prio:   0x80,
+			Key:    key,
+			AggId:  int(key),
+			Enable: true,
+			Mode:   LacpModeActive,
+			Properties: PortProperties{
+				Mac:    net.HardwareAddr{0x00, uint8(id), 0xDE, 0xAD, 0xBE, 0xEF},
+				Speed:  1000000000,
+				Duplex: LacpPortDuplexFull,
+				Mtu:    1500,
+			},
+			IntfId:   intf,
+			TraceEna: false,
+		}
+	}
+	aggConf := func(id int, key uint16, sysId string) *LaAggConfig {
+		return &LaAggConfig{
+			Id:  id,
+			Key: key,
+			Lacp: LacpConfigInfo{Interval: LacpFastPeriodicTime,
+				Mode:           LacpModeActive,
+				SystemIdMac:    sysId,
+				SystemPriority: 128},
+		}
+	}
+
+	// aggregator keys must be unique within the simulation as lookups
+	// are done across all systems, the Portal will use the lowest key
+	pAconf := portConf(LaAggPortA, 100, LaAggPortAIf)
+	pBconf := portConf(LaAggPortB, 150, LaAggPortBIf)
+	pS1conf := portConf(LaAggPortS1, 200, LaAggPortS1If)
+	pS2conf := portConf(LaAggPortS2, 200, LaAggPortS2If)
+	aAconf := aggConf(100, 100, "00:00:00:00:00:64")
+	aBconf := aggConf(150, 150, "00:00:00:00:00:65")
+	aSconf := aggConf(200, 200, "00:00:00:00:00:C8")
+
+	CreateLaAggPort(pAconf)
+	CreateLaAggPort(pBconf)
+	CreateLaAggPort(pS1conf)
+	CreateLaAggPort(pS2conf)
+
+	CreateLaAgg(aAconf)
+	CreateLaAgg(aBconf)
+	CreateLaAgg(aSconf)
+
+	drAconf := &DistributedRelayConfig{
+		DrniName:               "drA",
+		DrniPortalAddress:      "00:00:00:AA:BB:CC",
+		DrniPortalPriority:     100,
+		DrniPortalSystemNumber: 1,
+		DrniAggregator:         aAconf.Id,
+		DrniIntraPortalPortId:  IppA,
+		DrniIntraPortalIntf:    IppAIf,
+	}
+	drBconf := &DistributedRelayConfig{
+		DrniName:               "drB",
+		DrniPortalAddress:      "00:00:00:AA:BB:CC",
+		DrniPortalPriority:     100,
+		DrniPortalSystemNumber: 2,
+		DrniAggregator:         aBconf.Id,
+		DrniIntraPortalPortId:  IppB,
+		DrniIntraPortalIntf:    IppBIf,
+	}
+	if err := CreateDistributedRelay(drAconf); err != nil {
+		t.Error("Unable to create Distributed Relay", err)
+	}
+	if err := CreateDistributedRelay(drBconf); err != nil {
+		t.Error("Unable to create Distributed Relay", err)
+	}
+	// only the ports of the Portal aggregator use the Portal System Id
+	if SystemA.SystemDefaultParams.actor_System != LaSystemA.actor_System ||
+		SystemB.SystemDefaultParams.actor_System != LaSystemB.actor_System {
+		t.Error("System default params changed by Distributed Relay", SystemA.SystemDefaultParams, SystemB.SystemDefaultParams)
+	}
+	// same aggregator can't be part of two Distributed Relays
+	drBconf.DrniName = "drC"
+	if err := CreateDistributedRelay(drBconf); err == nil {
+		t.Error("Expected error creating Distributed Relay with aggregator already in use")
+	}
+
+	var drA, drB *DistributedRelay
+	var pS1, pS2 *LaAggPort
+	if DrFindByName("drA", &drA) &&
+		DrFindByName("drB", &drB) &&
+		LaFindPortById(LaAggPortS1, &pS1) &&
+		LaFindPortById(LaAggPortS2, &pS2) {
+
+		// wait for the Portal to form and the server to aggregate
+		// both links
+		for i := 0; i < 15 &&
+			(!drA.Ipp.IsNeighborCurrent() ||
+				!drB.Ipp.IsNeighborCurrent() ||
+				pS1.MuxMachineFsm.Machine.Curr.CurrentState() != LacpMuxmStateDistributing ||
+				pS2.MuxMachineFsm.Machine.Curr.CurrentState() != LacpMuxmStateDistributing); i++ {
+			time.Sleep(time.Second * 1)
+		}
+
+		if !drA.Ipp.IsNeighborCurrent() || !drB.Ipp.IsNeighborCurrent() {
+			t.Error("Portal Systems did not see each other over the Intra-Portal Link")
+		}
+		if drA.PSI || drB.PSI {
+			t.Error("Portal Systems still isolated", drA.PSI, drB.PSI)
+		}
+		if pS1.PartnerOper.System.actor_System != drA.DrniPortalAddr ||
+			pS2.PartnerOper.System.actor_System != drB.DrniPortalAddr {
+			t.Error("Server does not see the Portal System Id", pS1.PartnerOper.System, pS2.PartnerOper.System)
+		}
+		if pS1.PartnerOper.Key != 100 || pS2.PartnerOper.Key != 100 {
+			t.Error("Portal Systems did not agree on the aggregator key", pS1.PartnerOper.Key, pS2.PartnerOper.Key)
+		}
+		if pS1.MuxMachineFsm.Machine.Curr.CurrentState() != LacpMuxmStateDistributing ||
+			pS2.MuxMachineFsm.Machine.Curr.CurrentState() != LacpMuxmStateDistributing {
+			t.Error("Server links are not both distributing",
+				MuxmStateStrMap[pS1.MuxMachineFsm.Machine.Curr.CurrentState()],
+				MuxmStateStrMap[pS2.MuxMachineFsm.Machine.Curr.CurrentState()])
+		}
+
+		// by default even Gateway Conversations are carried by Portal
+		// System 1 and odd by Portal System 2
+		for cid := uint16(0); int(cid) < LacpMaxConversationIds; cid++ {
+			if drA.DrniGatewayConversationIsHome(cid) == drB.DrniGatewayConversationIsHome(cid) ||
+				drA.DrniGatewayConversationIsHome(cid) != (cid%2 == 0) {
+				t.Error("Portal Systems do not agree on Gateway Conversation", cid)
+				break
+			}
+		}
+
+		// remove Portal System 2, Portal System 1 should take over all
+		// conversations once the neighbor has timed out
+		if err := DeleteDistributedRelay("drB"); err != nil {
+			t.Error("Unable to delete Distributed Relay", err)
+		}
+		for i := 0; i < 10 && !drA.PSI; i++ {
+			time.Sleep(time.Second * 1)
+		}
+		if !drA.PSI {
+			t.Error("Portal System 1 did not detect the loss of Portal System 2")
+		}
+		for cid := uint16(0); int(cid) < LacpMaxConversationIds; cid++ {
+			if !drA.DrniGatewayConversationIsHome(cid) ||
+				!drA.DrniPortConversationIsHome(cid) {
+				t.Error("Portal System 1 did not take over conversation", cid)
+				break
+			}
+		}
+	} else {
+		t.Error("Unable to find Distributed Relay or port just created")
+	}
+
+	// cleanup the provisioning
+	DeleteDistributedRelay("drA")
+	DeleteLaAgg(aAconf.Id)
+	DeleteLaAgg(aBconf.Id)
+	DeleteLaAgg(aSconf.Id)
+	for _, sgi := range LacpSysGlobalInfoGet() {
+		if len(sgi.AggList) > 0 || len(sgi.AggMap) > 0 {
+			t.Error("System Agg List or Map is not empty", sgi.AggList, sgi.AggMap)
+		}
+		if len(sgi.PortList) > 0 || len(sgi.PortMap) > 0 {
+			t.Error("System Port List or Map is not empty", sgi.PortList, sgi.PortMap)
+		}
+		if len(sgi.DistributedRelayList) > 0 || len(sgi.DistributedRelayMap) > 0 {
+			t.Error("System Distributed Relay List or Map is not empty", sgi.DistributedRelayList, sgi.DistributedRelayMap)
+		}
+	}
+}
+
+// UsedForTestOnlyLacpFallbackSetup will create an aggregator with two ports
+// and no partner using the given fallback mode
+func UsedForTestOnlyLacpFallbackSetup(t *testing.T, mode uint32) (*LaAggConfig, *LaAggPort, *LaAggPort) {
+
+	const LaAggPort1 = 40
+	const LaAggPort2 = 41
+	// must be called to initialize the global
+	sysId := LacpSystem{Actor_System_priority: 128,
+		actor_System: [6]uint8{0x00, 0x00, 0x00, 0x00, 0x01, 0x90}}
+	LacpSysGlobalInfoInit(sysId)
+
+	portConf := func(id uint16, intf string) *LaAggPortConfig {
+		return &LaAggPortConfig{
+			Id:     id,
+			Prio:   0x80,
+			Key:    400,
+			AggId:  400,
+			Enable: true,
+			Mode:   LacpModeActive,
+			Properties: PortProperties{
+				Mac:    net.HardwareAddr{0x00, uint8(id), 0xDE, 0xAD, 0xBE, 0xEF},
+				Speed:  1000000000,
+				Duplex: LacpPortDuplexFull,
+				Mtu:    1500,
+			},
+			IntfId:   intf,
+			TraceEna: false,
+		}
+	}
+	p1conf := portConf(LaAggPort1, "SIMeth4.0")
+	p2conf := portConf(LaAggPort2, "SIMeth4.1")
+
+	CreateLaAggPort(p1conf)
+	CreateLaAggPort(p2conf)
+
+	aconf := &LaAggConfig{
+		Mac: [6]uint8{0x00, 0x00, 0x04, 0x04, 0x04, 0x04},
+		Id:  400,
+		Key: 400,
+		Lacp: LacpConfigInfo{Interval: LacpFastPeriodicTime,
+			Mode:           LacpModeActive,
+			SystemIdMac:    "00:00:00:00:01:90",
+			SystemPriority: 128},
+		FallbackMode:    mode,
+		FallbackTimeout: time.Second * 1,
+	}
+
+	// Create Aggregation
+	CreateLaAgg(aconf)
+
+	var p1 *LaAggPort
+	var p2 *LaAggPort
+	if !LaFindPortById(p1conf.Id, &p1) ||
+		!LaFindPortById(p2conf.Id, &p2) {
+		t.Fatal("Unable to find port just created")
+	}
+	return aconf, p1, p2
+}
+
+// UsedForTestOnlyLacpFallbackTeardown will delete the aggregator and
+// check that the provisioning is cleaned up
+func UsedForTestOnlyLacpFallbackTeardown(t *testing.T, aconf *LaAggConfig) {
+	DeleteLaAgg(aconf.Id)
+	for _, sgi := range LacpSysGlobalInfoGet() {
+		if len(sgi.AggList) > 0 || len(sgi.AggMap) > 0 {
+			t.Error("System Agg List or Map is not empty", sgi.AggList, sgi.AggMap)
+		}
+		if len(sgi.PortList) > 0 || len(sgi.PortMap) > 0 {
+			t.Error("System Port List or Map is not empty", sgi.PortList, sgi.PortMap)
+		}
+	}
+}
+
+// UsedForTestOnlyLacpAdvanceUntil steps the clock until done returns true.
+// The machine timers are channel timers so each step yields to let the
+// machine go routines process the expiry and the frames it causes
+func UsedForTestOnlyLacpAdvanceUntil(clk *clock.ManualClock, steps int, done func() bool) bool {
+	for i := 0; i < steps; i++ {
+		if done() {
+			return true
+		}
+		clk.Advance(time.Millisecond * 500)
+		time.Sleep(time.Millisecond * 10)
+	}
+	return done()
+}
+
+// UsedForTestOnlyLacpPktIoMemory selects the memory backend and connects
+// each pair of interfaces back to back, the returned func disconnects the
+// wires and restores the previous backend
+func UsedForTestOnlyLacpPktIoMemory(wires ...[2]string) func() {
+	prevPktIoCfg := LacpPktIoConfigGet()
+	pktIoCfg := pktio.DefaultConfig()
+	pktIoCfg.Type = pktio.PktIoTypeMemory
+	LacpPktIoConfigSet(pktIoCfg)
+	for _, w := range wires {
+		pktio.ConnectWire(w[0], w[1])
+	}
+	return func() {
+		for _, w := range wires {
+			pktio.DisconnectWire(w[0])
+		}
+		LacpPktIoConfigSet(prevPktIoCfg)
+	}
+}
+
+// TestLacpFallbackStatic will create an aggregator with two ports and no
+// partner, after the fallback timeout only one port should come up.  When
+// that port goes down the other port should take its place
+func TestLacpFallbackStatic(t *testing.T) {
+
+	clk := clock.NewManualClock(time.Now())
+	prevClk := LacpClockGet()
+	LacpClockSet(clk)
+	defer LacpClockSet(prevClk)
+
+	aconf, p1, p2 := UsedForTestOnlyLacpFallbackSetup(t, LacpFallbackModeStatic)
+
+	distributing := func(p *LaAggPort) bool {
+		return p.MuxMachineFsm.Machine.Curr.CurrentState() == LacpMuxmStateDistributing
+	}
+	UsedForTestOnlyLacpAdvanceUntil(clk, 40, func() bool {
+		return distributing(p1) || distributing(p2)
+	})
+	// allow the other port to expire its fallback timer
+	UsedForTestOnlyLacpAdvanceUntil(clk, 6, func() bool { return false })
+
+	fallbackCnt := 0
+	distributingCnt := 0
+	var fp, op *LaAggPort
+	for _, p := range []*LaAggPort{p1, p2} {
+		if p.IsFallback() {
+			fallbackCnt++
+			fp = p
+		} else {
+			op = p
+		}
+		if distributing(p) {
+			distributingCnt++
+		}
+	}
+	if fallbackCnt != 1 || distributingCnt != 1 {
+		t.Error("Expected one port in fallback distributing, fallback", fallbackCnt, "distributing", distributingCnt)
+	} else {
+		// the other port falls back once the fallback port goes down
+		fp.LaAggPortDisable()
+		if !UsedForTestOnlyLacpAdvanceUntil(clk, 40, func() bool {
+			return op.IsFallback() && distributing(op)
+		}) {
+			t.Error("Expected other port to fallback once the fallback port is down, fallback", op.IsFallback(),
+				"mux state", MuxmStateStrMap[op.MuxMachineFsm.Machine.Curr.CurrentState()])
+		}
+		if fp.IsFallback() {
+			t.Error("Expected disabled port to exit fallback")
+		}
+	}
+
+	UsedForTestOnlyLacpFallbackTeardown(t, aconf)
+}
+
+// TestLacpFallbackIndividual will create an aggregator with two ports and
+// no partner, after the fallback timeout both ports should be detached from
+// the lag and come up as non aggregated links
+func TestLacpFallbackIndividual(t *testing.T) {
+
+	clk := clock.NewManualClock(time.Now())
+	prevClk := LacpClockGet()
+	LacpClockSet(clk)
+	defer LacpClockSet(prevClk)
+
+	aconf, p1, p2 := UsedForTestOnlyLacpFallbackSetup(t, LacpFallbackModeIndividual)
+
+	individual := func(p *LaAggPort) bool {
+		return p.IsFallback() &&
+			p.aggSelected == LacpAggUnSelected &&
+			p.MuxMachineFsm.Machine.Curr.CurrentState() == LacpMuxmStateDetached
+	}
+	if !UsedForTestOnlyLacpAdvanceUntil(clk, 40, func() bool {
+		return individual(p1) && individual(p2)
+	}) {
+		t.Error("Expected both ports to fallback as individual links", p1.IsFallback(), p2.IsFallback(),
+			MuxmStateStrMap[p1.MuxMachineFsm.Machine.Curr.CurrentState()],
+			MuxmStateStrMap[p2.MuxMachineFsm.Machine.Curr.CurrentState()])
+	}
+	// remain detached, the ports must not be reselected into the lag
+	UsedForTestOnlyLacpAdvanceUntil(clk, 6, func() bool { return false })
+	for _, p := range []*LaAggPort{p1, p2} {
+		if !individual(p) {
+			t.Error("Expected port to remain an individual link", p.PortNum)
+		}
+		if LacpStateIsSet(p.ActorOper.State, LacpStateAggregationBit) {
+			t.Error("Expected individual port to clear the Actor Aggregation bit", p.PortNum)
+		}
+	}
+	var a *LaAggregator
+	if LaFindAggById(aconf.Id, &a) &&
+		len(a.DistributedPortNumList) != 0 {
+		t.Error("Expected no distributing members in the lag", a.DistributedPortNumList)
+	}
+
+	// link down leaves fallback
+	p1.LaAggPortDisable()
+	if !UsedForTestOnlyLacpAdvanceUntil(clk, 4, func() bool { return !p1.IsFallback() }) {
+		t.Error("Expected disabled port to exit fallback")
+	}
+
+	UsedForTestOnlyLacpFallbackTeardown(t, aconf)
+}
+
+// TestLacpMaxLinksStandby will create two aggregators back to back with two
+// links, max links on the actor will hold one port in standby until the
+// active port is disabled
+func TestLacpMaxLinksStandby(t *testing.T) {
+
+	const LaAggPortActor1 = 61
+	const LaAggPortActor2 = 62
+	const LaAggPortPeer1 = 71
+	const LaAggPortPeer2 = 72
+	LaAggPortActor1If := "SIMeth6.1"
+	LaAggPortActor2If := "SIMeth6.2"
+	LaAggPortPeer1If := "SIMeth7.1"
+	LaAggPortPeer2If := "SIMeth7.2"
+	// must be called to initialize the global
+	LaSystemActor := LacpSystem{Actor_System_priority: 128,
+		actor_System: [6]uint8{0x00, 0x00, 0x00, 0x00, 0x00, 0x64}}
+	LaSystemPeer := LacpSystem{Actor_System_priority: 128,
+		actor_System: [6]uint8{0x00, 0x00, 0x00, 0x00, 0x00, 0xC8}}
+
+	LacpSysGlobalInfoInit(LaSystemActor)
+	LacpSysGlobalInfoInit(LaSystemPeer)
+
+	defer UsedForTestOnlyLacpPktIoMemory(
+		[2]string{LaAggPortActor1If, LaAggPortPeer1If},
+		[2]string{LaAggPortActor2If, LaAggPortPeer2If})()
+
+	portConf := func(id uint16, key uint16, intf string) *LaAggPortConfig {
+		return &LaAggPortConfig{
+			Id:     id,
+			Prio:   0x80,
+			Key:    key,
+			AggId:  int(key),
+			Enable: true,
+			Mode:   LacpModeActive,
+			Properties: PortProperties{
+				Mac:    net.HardwareAddr{0x00, uint8(id), 0xDE, 0xAD, 0xBE, 0xEF},
+				Speed:  1000000000,
+				Duplex: LacpPortDuplexFull,
+				Mtu:    1500,
+			},
+			IntfId:   intf,
+			TraceEna: false,
+		}
+	}
+
+	CreateLaAggPort(portConf(LaAggPortActor1, 500, LaAggPortActor1If))
+	CreateLaAggPort(portConf(LaAggPortActor2, 500, LaAggPortActor2If))
+	CreateLaAggPort(portConf(LaAggPortPeer1, 600, LaAggPortPeer1If))
+	CreateLaAggPort(portConf(LaAggPortPeer2, 600, LaAggPortPeer2If))
+
+	a1conf := &LaAggConfig{
+		Id:       500,
+		Key:      500,
+		MaxLinks: 1,
+		Lacp: LacpConfigInfo{Interval: LacpFastPeriodicTime,
+			Mode:           LacpModeActive,
+			SystemIdMac:    "00:00:00:00:00:64",
+			SystemPriority: 128},
+	}
+	a2conf := &LaAggConfig{
+		Id:  600,
+		Key: 600,
+		Lacp: LacpConfigInfo{Interval: LacpFastPeriodicTime,
+			Mode:           LacpModeActive,
+			SystemIdMac:    "00:00:00:00:00:C8",
+			SystemPriority: 128},
+	}
+
+	CreateLaAgg(a1conf)
+	CreateLaAgg(a2conf)
+
+	var a *LaAggregator
+	var p1 *LaAggPort
+	var p2 *LaAggPort
+	if LaFindAggById(a1conf.Id, &a) &&
+		LaFindPortById(LaAggPortActor1, &p1) &&
+		LaFindPortById(LaAggPortActor2, &p2) {
+
+		// actor has the lower System ID so its lowest port is active
+		for i := 0; i < 15 &&
+			(p1.MuxMachineFsm.Machine.Curr.CurrentState() != LacpMuxmStateDistributing ||
+				!p2.IsStandby()); i++ {
+			time.Sleep(time.Second * 1)
+		}
+
+		if p1.MuxMachineFsm.Machine.Curr.CurrentState() != LacpMuxmStateDistributing {
+			t.Error("Active port expected Distributing actual", MuxmStateStrMap[p1.MuxMachineFsm.Machine.Curr.CurrentState()])
+		}
+		if !p2.IsStandby() ||
+			p2.MuxMachineFsm.Machine.Curr.CurrentState() != LacpMuxmStateWaiting {
+			t.Error("Standby port expected Waiting actual", MuxmStateStrMap[p2.MuxMachineFsm.Machine.Curr.CurrentState()])
+		}
+		if !a.OperState {
+			t.Error("Aggregator should be operationally up")
+		}
+
+		// active port failure should promote the standby port
+		DisableLaAggPort(LaAggPortActor1)
+
+		for i := 0; i < 10 &&
+			p2.MuxMachineFsm.Machine.Curr.CurrentState() != LacpMuxmStateDistributing; i++ {
+			time.Sleep(time.Second * 1)
+		}
+
+		if p2.IsStandby() ||
+			p2.MuxMachineFsm.Machine.Curr.CurrentState() != LacpMuxmStateDistributing {
+			t.Error("Standby port was not promoted, state", MuxmStateStrMap[p2.MuxMachineFsm.Machine.Curr.CurrentState()])
+		}
+
+		// min links above the number of attached ports takes the port
+		// out of service, the partner is told the port is not in sync
+		SetLaAggMinLinks(a1conf.Id, 2)
+		for i := 0; i < 10 &&
+			(a.OperState ||
+				p2.MuxMachineFsm.Machine.Curr.CurrentState() != LacpMuxmStateAttached); i++ {
+			time.Sleep(time.Second * 1)
+		}
+		if a.OperState {
+			t.Error("Aggregator should be operationally down below min links")
+		}
+		if p2.MuxMachineFsm.Machine.Curr.CurrentState() != LacpMuxmStateAttached ||
+			LacpStateIsSet(p2.ActorOper.State, LacpStateSyncBit) {
+			t.Error("Port below min links expected Attached and out of sync actual", MuxmStateStrMap[p2.MuxMachineFsm.Machine.Curr.CurrentState()], p2.ActorOper.State)
+		}
+		SetLaAggMinLinks(a1conf.Id, 1)
+		for i := 0; i < 10 &&
+			(!a.OperState ||
+				p2.MuxMachineFsm.Machine.Curr.CurrentState() != LacpMuxmStateDistributing); i++ {
+			time.Sleep(time.Second * 1)
+		}
+		if !a.OperState ||
+			p2.MuxMachineFsm.Machine.Curr.CurrentState() != LacpMuxmStateDistributing {
+			t.Error("Aggregator should be operationally up at min links, port state", MuxmStateStrMap[p2.MuxMachineFsm.Machine.Curr.CurrentState()])
+		}
+	} else {
+		t.Error("Unable to find aggregator or port just created")
+	}
+
+	// cleanup the provisioning
+	DeleteLaAgg(a1conf.Id)
+	DeleteLaAgg(a2conf.Id)
+	for _, sgi := range LacpSysGlobalInfoGet() {
+		if len(sgi.AggList) > 0 || len(sgi.AggMap) > 0 {
+			t.Error("System Agg List or Map is not empty", sgi.AggList, sgi.AggMap)
+		}
+		if len(sgi.PortList) > 0 || len(sgi.PortMap) > 0 {
+			t.Error("System Port List or Map is not empty", sgi.PortList, sgi.PortMap)
+		}
+	}
+}
+
+// TestLacpNotifyAggOperState will verify that the aggregator oper state
+// notifications are sent to registered clients with the correct reason
+func TestLacpNotifyAggOperState(t *testing.T) {
+	sysId := LacpSystem{Actor_System_priority: 128,
+		actor_System: [6]uint8{0x00, 0x00, 0x00, 0x00, 0x02, 0x58}}
+	LacpSysGlobalInfoInit(sysId)
+
+	aconf := &LaAggConfig{
+		Id:  800,
+		Key: 800,
+		Lacp: LacpConfigInfo{Interval: LacpFastPeriodicTime,
+			Mode:           LacpModeActive,
+			SystemIdMac:    "00:00:00:00:02:58",
+			SystemPriority: 128},
+	}
+	CreateLaAgg(aconf)
+
+	type notification struct {
+		msgType uint8
+		msg     LacpNotifyAggMsg
+	}
+	notifications := make([]notification, 0)
+	LacpNotifyRegisterCallback("test", func(msgType uint8, msg LacpNotifyAggMsg) {
+		notifications = append(notifications, notification{msgType, msg})
+	})
+
+	var a *LaAggregator
+	if LaFindAggById(aconf.Id, &a) {
+		// simulate a port distributing
+		a.DistributedPortNumList = append(a.DistributedPortNumList, "SIMeth8.0")
+		a.LacpAggOperStateUpdate(LacpNotifyReasonNone)
+		if len(notifications) != 1 ||
+			notifications[0].msgType != LacpNotifyAggOperStateUp ||
+			notifications[0].msg.AggId != int32(aconf.Id) {
+			t.Error("Expected Agg Oper State Up notification", notifications)
+		}
+
+		// simulate the port being taken out of service below min links
+		SetLaAggMinLinks(aconf.Id, 2)
+		a.DistributedPortNumList = a.DistributedPortNumList[:0]
+		a.LacpAggOperStateUpdate(LacpNotifyReasonNone)
+		if len(notifications) != 2 ||
+			notifications[1].msgType != LacpNotifyAggOperStateDown ||
+			notifications[1].msg.Reason != LacpNotifyReasonMinLinks {
+			t.Error("Expected Agg Oper State Down notification due to MinLinks", notifications)
+		}
+
+		// no notification when disabled
+		SetLaAggLinkUpDownNotificationEnable(aconf.Id, false)
+		SetLaAggMinLinks(aconf.Id, 1)
+		a.DistributedPortNumList = append(a.DistributedPortNumList, "SIMeth8.0")
+		a.LacpAggOperStateUpdate(LacpNotifyReasonNone)
+		if !a.OperState || len(notifications) != 2 {
+			t.Error("Expected Agg Oper State Up without notification", a.OperState, notifications)
+		}
+		a.DistributedPortNumList = a.DistributedPortNumList[:0]
+	} else {
+		t.Error("Unable to find aggregator just created")
+	}
+	LacpNotifyDeRegisterCallback("test")
+
+	DeleteLaAgg(aconf.Id)
+	for _, sgi := range LacpSysGlobalInfoGet() {
+		if len(sgi.AggList) > 0 || len(sgi.AggMap) > 0 {
+			t.Error("System Agg List or Map is not empty", sgi.AggList, sgi.AggMap)
+		}
+	}
+}
+
+// TestLacpPktIoMemoryBackToBack will bring up two aggregators back to back
+// over the pktio memory backend so the full rx/tx path of the port is used
+func TestLacpPktIoMemoryBackToBack(t *testing.T) {
+
+	const LaAggPortActor = 81
+	const LaAggPortPeer = 91
+	LaAggPortActorIf := "SIMeth8.1"
+	LaAggPortPeerIf := "SIMeth9.1"
+	// must be called to initialize the global
+	LaSystemActor := LacpSystem{Actor_System_priority: 128,
+		actor_System: [6]uint8{0x00, 0x00, 0x00, 0x00, 0x00, 0x64}}
+	LaSystemPeer := LacpSystem{Actor_System_priority: 128,
+		actor_System: [6]uint8{0x00, 0x00, 0x00, 0x00, 0x00, 0xC8}}
+	LacpSysGlobalInfoInit(LaSystemActor)
+	LacpSysGlobalInfoInit(LaSystemPeer)
+
+	defer UsedForTestOnlyLacpPktIoMemory([2]string{LaAggPortActorIf, LaAggPortPeerIf})()
+
+	a1conf := &LaAggConfig{
+		Id:  900,
+		Key: 900,
+		Lacp: LacpConfigInfo{Interval: LacpFastPeriodicTime,
+			Mode:           LacpModeActive,
+			SystemIdMac:    "00:00:00:00:00:64",
+			SystemPriority: 128},
+	}
+	a2conf := &LaAggConfig{
+		Id:  910,
+		Key: 910,
+		Lacp: LacpConfigInfo{Interval: LacpFastPeriodicTime,
+			Mode:           LacpModeActive,
+			SystemIdMac:    "00:00:00:00:00:C8",
+			SystemPriority: 128},
+	}
+
+	// aggs are created first so that the ports are attached
+	// to the correct system
+	CreateLaAgg(a1conf)
+	CreateLaAgg(a2conf)
+
+	portConf := func(id uint16, key uint16, intf string) *LaAggPortConfig {
+		return &LaAggPortConfig{
+			Id:     id,
+			Prio:   0x80,
+			Key:    key,
+			AggId:  int(key),
+			Enable: true,
+			Mode:   LacpModeActive,
+			Properties: PortProperties{
+				Mac:    net.HardwareAddr{0x00, uint8(id), 0xDE, 0xAD, 0xBE, 0xEF},
+				Speed:  1000000000,
+				Duplex: LacpPortDuplexFull,
+				Mtu:    1500,
+			},
+			IntfId:   intf,
+			TraceEna: false,
+		}
+	}
+
+	CreateLaAggPort(portConf(LaAggPortActor, 900, LaAggPortActorIf))
+	CreateLaAggPort(portConf(LaAggPortPeer, 910, LaAggPortPeerIf))
+
+	var p1 *LaAggPort
+	var p2 *LaAggPort
+	if LaFindPortById(LaAggPortActor, &p1) &&
+		LaFindPortById(LaAggPortPeer, &p2) {
+
+		if p1.handle == nil || p2.handle == nil {
+			t.Error("Memory backend handle was not created for ports")
+		}
+
+		for i := 0; i < 10 &&
+			(p1.MuxMachineFsm.Machine.Curr.CurrentState() != LacpMuxmStateDistributing ||
+				p2.MuxMachineFsm.Machine.Curr.CurrentState() != LacpMuxmStateDistributing); i++ {
+			time.Sleep(time.Second * 1)
+		}
+
+		const portUpState = LacpStateActivityBit | LacpStateAggregationBit |
+			LacpStateSyncBit | LacpStateCollectingBit | LacpStateDistributingBit
+
+		State1 := GetLaAggPortActorOperState(LaAggPortActor)
+		State2 := GetLaAggPortActorOperState(LaAggPortPeer)
+		if !LacpStateIsSet(State1, portUpState) {
+			t.Error(fmt.Sprintf("Actor Port State 0x%x did not come up properly with peer expected 0x%x", State1, portUpState))
+		}
+		if !LacpStateIsSet(State2, portUpState) {
+			t.Error(fmt.Sprintf("Peer Port State 0x%x did not come up properly with actor expected 0x%x", State2, portUpState))
+		}
+	} else {
+		t.Error("Unable to find port just created")
+	}
+
+	// cleanup the provisioning
+	DeleteLaAgg(a1conf.Id)
+	DeleteLaAgg(a2conf.Id)
+	for _, sgi := range LacpSysGlobalInfoGet() {
+		if len(sgi.AggList) > 0 || len(sgi.AggMap) > 0 {
+			t.Error("System Agg List or Map is not empty", sgi.AggList, sgi.AggMap)
+		}
+		if len(sgi.PortList) > 0 || len(sgi.PortMap) > 0 {
+			t.Error("System Port List or Map is not empty", sgi.PortList, sgi.PortMap)
+		}
+	}
+}
+
+// TestLacpHwPluginMemory will check what is programmed in hw as ports
+// start/stop distributing
+func TestLacpHwPluginMemory(t *testing.T) {
+	hw := NewMemoryLagHwPlugin()
+	prevHw := LacpHwPluginGet()
+	LacpHwPluginSet(hw)
+	defer LacpHwPluginSet(prevHw)
+
+	sysId := LacpSystem{Actor_System_priority: 128,
+		actor_System: [6]uint8{0x00, 0x00, 0x00, 0x00, 0x03, 0x21}}
+	LacpSysGlobalInfoInit(sysId)
+
+	aconf := &LaAggConfig{
+		Id:   801,
+		Key:  801,
+		Name: "agg801",
+		Lacp: LacpConfigInfo{Interval: LacpFastPeriodicTime,
+			Mode:           LacpModeActive,
+			SystemIdMac:    "00:00:00:00:03:21",
+			SystemPriority: 128},
+	}
+	CreateLaAgg(aconf)
+
+	var a *LaAggregator
+	if !LaFindAggById(aconf.Id, &a) {
+		t.Fatal("Unable to find aggregator just created")
+	}
+
+	a.DistributedPortNumList = append(a.DistributedPortNumList, "SIMeth8.2")
+	a.LacpAggOperStateUpdate(LacpNotifyReasonNone)
+	lag, ok := hw.Lag(a.HwAggId)
+	if !ok ||
+		lag.Config.Name != aconf.Name ||
+		len(lag.Ports) != 1 || lag.Ports[0] != "SIMeth8.2" {
+		t.Error("Expected lag to be created with first distributing port", a.HwAggId, lag)
+	}
+
+	a.DistributedPortNumList = append(a.DistributedPortNumList, "SIMeth8.3")
+	a.LacpAggOperStateUpdate(LacpNotifyReasonNone)
+	if lag, _ = hw.Lag(a.HwAggId); len(lag.Ports) != 2 {
+		t.Error("Expected lag members to be updated", lag)
+	}
+
+	SetLaAggHashMode(aconf.Id, 2)
+	if lag, _ = hw.Lag(a.HwAggId); lag.Config.HashMode != 2 {
+		t.Error("Expected lag hash to be updated", lag)
+	}
+
+	// below min links the ports are taken out of service and the lag
+	// is deleted once the last port stops distributing
+	hwAggId := a.HwAggId
+	SetLaAggMinLinks(aconf.Id, 3)
+	if _, ok = hw.Lag(hwAggId); !ok {
+		t.Error("Expected lag to remain while ports are distributing", hwAggId)
+	}
+	a.DistributedPortNumList = a.DistributedPortNumList[:0]
+	a.LacpAggOperStateUpdate(LacpNotifyReasonNone)
+	if _, ok = hw.Lag(hwAggId); ok || a.HwAggId != 0 {
+		t.Error("Expected lag to be deleted below min links", hwAggId, a.HwAggId)
+	}
+	expected := []string{LagHwOpCreate, LagHwOpUpdate, LagHwOpSetHash, LagHwOpDelete}
+	calls := hw.Calls("")
+	if len(calls) != len(expected) {
+		t.Error("Unexpected hw calls", calls)
+	} else {
+		for i, c := range calls {
+			if c.Op != expected[i] || c.HwAggId != hwAggId {
+				t.Error("Unexpected hw call", i, c)
+			}
+		}
+	}
+
+	hw.LinkState["SIMeth8.2"] = false
+	if LacpHwPluginGet().GetLinkState("SIMeth8.2") {
+		t.Error("Expected link state to be taken from hw plugin")
+	}
+
+	a.DistributedPortNumList = a.DistributedPortNumList[:0]
+	DeleteLaAgg(aconf.Id)
+	for _, sgi := range LacpSysGlobalInfoGet() {
+		if len(sgi.AggList) > 0 || len(sgi.AggMap) > 0 {
+			t.Error("System Agg List or Map is not empty", sgi.AggList, sgi.AggMap)
+		}
+	}
+
+	// plugin is selected from the params directory
+	if p, err := LacpHwPluginFromParams("/nonexistent/"); err != nil || p.Name() != LagHwPluginAsicd {
+		t.Error("Expected asicd plugin when lacpd.conf does not exist", err)
+	}
+}
@@ -0,0 +1,258 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// lacpv2pdu will encode/decode the Version 2 LACPDU TLV's 802.1ax-2014 Section 6.4.2.4
+// gopacket only understands the Version 1 LACPDU so the Version 2 TLV's
+// are handled here
+package lacp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/google/gopacket/layers"
+)
+
+const (
+	LacpVersion1 uint8 = 0x01
+	LacpVersion2 uint8 = 0x02
+)
+
+// 6.4.2.4 Version 2 TLV types
+const (
+	LacpTLVTerminator                     uint8 = 0x00
+	LacpTLVPortAlgorithm                  uint8 = 0x04
+	LacpTLVPortConversationIdDigest       uint8 = 0x05
+	LacpTLVPortConversationMask1          uint8 = 0x06
+	LacpTLVPortConversationMask2          uint8 = 0x07
+	LacpTLVPortConversationMask3          uint8 = 0x08
+	LacpTLVPortConversationMask4          uint8 = 0x09
+	LacpTLVPortConversationServiceMapping uint8 = 0x0A
+)
+
+// 6.4.2.4 Version 2 TLV lengths
+const (
+	LacpPortAlgorithmTlvLength                  uint8 = 6
+	LacpPortConversationIdDigestTlvLength       uint8 = 20
+	LacpPortConversationMask1TlvLength          uint8 = 131
+	LacpPortConversationMaskTlvLength           uint8 = 130
+	LacpPortConversationServiceMappingTlvLength uint8 = 18
+)
+
+// 6.4.2.4.3 Port Conversation Mask State
+const (
+	LacpMaskStateActParSyncBit = 1 << iota
+	LacpMaskStatePortalSystemIsolatedBit
+	LacpMaskStateDiscardWrongConversationBit
+)
+
+// Port Algorithm values 6.4.2.4.1
+const (
+	LacpPortAlgorithmUnspecified = iota
+	LacpPortAlgorithmCVID
+	LacpPortAlgorithmSVID
+	LacpPortAlgorithmISID
+	LacpPortAlgorithmTESID
+	LacpPortAlgorithmECMPFlowHash
+)
+
+// each Conversation Mask TLV carries 1024 conversation ids
+const LacpConversationMaskTlvSize int = 128
+
+// 4096 conversation ids one bit per id
+const LacpConversationMaskSize int = LacpConversationMaskTlvSize * 4
+
+// offset from the version field to the first Version 2 TLV
+// version + actor + partner + collector
+const LacpV2TlvOffset int = 1 + 20 + 20 + 16
+
+// 802.1ax-2014 Section 6.4.2.3 a LACPDU is not smaller than the
+// minimum frame size, subtype through pad this is 110 octets
+// minus the subtype which is filled in by the slow protocol layer
+const LacpPduMinLength int = 109
+
+// LacpV2Info holds the Version 2 TLV information
+// carried in a LACPDU
+type LacpV2Info struct {
+	// 6.4.2.4.1
+	PortAlgorithm uint32
+	// 6.4.2.4.2
+	LinkNumberId       uint16
+	ConvLinkListDigest [16]uint8
+	// 6.4.2.4.4
+	ConvServiceMappingDigest [16]uint8
+	// 6.4.2.4.3 only valid in a Long LACPDU
+	Long      bool
+	MaskState uint8
+	ConvMask  [LacpConversationMaskSize]uint8
+}
+
+// LacpV2Pdu is the Version 1 LACPDU with the Version 2 TLV's
+type LacpV2Pdu struct {
+	Lacp *layers.LACP
+	V2   LacpV2Info
+}
+
+func lacpEncodePortInfoTlv(data []byte, tlv *layers.LACPInfoTlv) {
+	data[0] = uint8(tlv.TlvType)
+	data[1] = tlv.Length
+	binary.BigEndian.PutUint16(data[2:], tlv.Info.System.SystemPriority)
+	copy(data[4:10], tlv.Info.System.SystemId[:])
+	binary.BigEndian.PutUint16(data[10:], tlv.Info.Key)
+	binary.BigEndian.PutUint16(data[12:], tlv.Info.PortPri)
+	binary.BigEndian.PutUint16(data[14:], tlv.Info.Port)
+	data[16] = tlv.Info.State
+}
+
+// LacpV2PduEncode will encode the LACPDU starting with the version field
+// as the subtype is filled in by the slow protocol layer
+func LacpV2PduEncode(pdu *LacpV2Pdu) []byte {
+	lacp := pdu.Lacp
+	v2 := &pdu.V2
+
+	length := LacpV2TlvOffset +
+		int(LacpPortAlgorithmTlvLength) +
+		int(LacpPortConversationIdDigestTlvLength) +
+		int(LacpPortConversationServiceMappingTlvLength)
+	if v2.Long {
+		length += int(LacpPortConversationMask1TlvLength) +
+			int(LacpPortConversationMaskTlvLength)*3
+	}
+	// terminator
+	length += 2
+	if length < LacpPduMinLength {
+		length = LacpPduMinLength
+	}
+
+	data := make([]byte, length)
+	data[0] = uint8(lacp.Version)
+	lacpEncodePortInfoTlv(data[1:], &lacp.Actor)
+	lacpEncodePortInfoTlv(data[21:], &lacp.Partner)
+	data[41] = uint8(lacp.Collector.TlvType)
+	data[42] = lacp.Collector.Length
+	binary.BigEndian.PutUint16(data[43:], lacp.Collector.MaxDelay)
+
+	i := LacpV2TlvOffset
+	data[i] = LacpTLVPortAlgorithm
+	data[i+1] = LacpPortAlgorithmTlvLength
+	binary.BigEndian.PutUint32(data[i+2:], v2.PortAlgorithm)
+	i += int(LacpPortAlgorithmTlvLength)
+
+	data[i] = LacpTLVPortConversationIdDigest
+	data[i+1] = LacpPortConversationIdDigestTlvLength
+	binary.BigEndian.PutUint16(data[i+2:], v2.LinkNumberId)
+	copy(data[i+4:i+20], v2.ConvLinkListDigest[:])
+	i += int(LacpPortConversationIdDigestTlvLength)
+
+	if v2.Long {
+		data[i] = LacpTLVPortConversationMask1
+		data[i+1] = LacpPortConversationMask1TlvLength
+		data[i+2] = v2.MaskState
+		copy(data[i+3:i+3+LacpConversationMaskTlvSize], v2.ConvMask[0:LacpConversationMaskTlvSize])
+		i += int(LacpPortConversationMask1TlvLength)
+
+		for j, tlvType := range []uint8{LacpTLVPortConversationMask2,
+			LacpTLVPortConversationMask3,
+			LacpTLVPortConversationMask4} {
+			start := (j + 1) * LacpConversationMaskTlvSize
+			data[i] = tlvType
+			data[i+1] = LacpPortConversationMaskTlvLength
+			copy(data[i+2:i+2+LacpConversationMaskTlvSize], v2.ConvMask[start:start+LacpConversationMaskTlvSize])
+			i += int(LacpPortConversationMaskTlvLength)
+		}
+	}
+
+	data[i] = LacpTLVPortConversationServiceMapping
+	data[i+1] = LacpPortConversationServiceMappingTlvLength
+	copy(data[i+2:i+18], v2.ConvServiceMappingDigest[:])
+
+	// remaining bytes are the terminator and pad which are zero
+	return data
+}
+
+// LacpV2PduDecode will decode the Version 2 TLV's, data should start
+// with the version field
+func LacpV2PduDecode(data []byte, v2 *LacpV2Info) error {
+
+	if len(data) < LacpV2TlvOffset {
+		return errors.New(fmt.Sprintf("LACPDU too short %d", len(data)))
+	}
+
+	if data[0] < LacpVersion2 {
+		return errors.New(fmt.Sprintf("LACPDU version %d does not contain Version 2 TLV's", data[0]))
+	}
+
+	masksRcvd := 0
+	for i := LacpV2TlvOffset; i+1 < len(data); {
+		tlvType := data[i]
+		tlvLen := int(data[i+1])
+		if tlvType == LacpTLVTerminator {
+			break
+		}
+		if tlvLen < 2 || i+tlvLen > len(data) {
+			return errors.New(fmt.Sprintf("LACPDU invalid TLV %d length %d", tlvType, tlvLen))
+		}
+		tlv := data[i : i+tlvLen]
+
+		switch tlvType {
+		case LacpTLVPortAlgorithm:
+			if tlvLen != int(LacpPortAlgorithmTlvLength) {
+				return errors.New(fmt.Sprintf("LACPDU invalid Port Algorithm TLV length %d", tlvLen))
+			}
+			v2.PortAlgorithm = binary.BigEndian.Uint32(tlv[2:])
+		case LacpTLVPortConversationIdDigest:
+			if tlvLen != int(LacpPortConversationIdDigestTlvLength) {
+				return errors.New(fmt.Sprintf("LACPDU invalid Conversation ID Digest TLV length %d", tlvLen))
+			}
+			v2.LinkNumberId = binary.BigEndian.Uint16(tlv[2:])
+			copy(v2.ConvLinkListDigest[:], tlv[4:20])
+		case LacpTLVPortConversationMask1:
+			if tlvLen != int(LacpPortConversationMask1TlvLength) {
+				return errors.New(fmt.Sprintf("LACPDU invalid Conversation Mask 1 TLV length %d", tlvLen))
+			}
+			v2.MaskState = tlv[2]
+			copy(v2.ConvMask[0:LacpConversationMaskTlvSize], tlv[3:])
+			masksRcvd++
+		case LacpTLVPortConversationMask2,
+			LacpTLVPortConversationMask3,
+			LacpTLVPortConversationMask4:
+			if tlvLen != int(LacpPortConversationMaskTlvLength) {
+				return errors.New(fmt.Sprintf("LACPDU invalid Conversation Mask TLV %d length %d", tlvType, tlvLen))
+			}
+			start := int(tlvType-LacpTLVPortConversationMask1) * LacpConversationMaskTlvSize
+			copy(v2.ConvMask[start:start+LacpConversationMaskTlvSize], tlv[2:])
+			masksRcvd++
+		case LacpTLVPortConversationServiceMapping:
+			if tlvLen != int(LacpPortConversationServiceMappingTlvLength) {
+				return errors.New(fmt.Sprintf("LACPDU invalid Service Mapping TLV length %d", tlvLen))
+			}
+			copy(v2.ConvServiceMappingDigest[:], tlv[2:18])
+		default:
+			// 802.1ax-2014 Section 6.4.2.4 unknown TLV's are ignored
+		}
+		i += tlvLen
+	}
+	// a Long LACPDU must contain all 4 masks
+	v2.Long = masksRcvd == 4
+	return nil
+}
@@ -0,0 +1,403 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// markerGenerator.go
+// Marker Generator/Receiver 802.1ax-2014 Section 6.5.  Used to preserve
+// frame ordering when conversations are moved between links of an
+// aggregator.  A Marker PDU is sent on a link after distribution of the
+// conversations has stopped, once the Marker Response is received (or the
+// response times out) the conversations can be moved to their new link.
+package lacp
+
+import (
+	"l2/clock"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"utils/fsm"
+)
+
+const MarkerGeneratorModuleStr = "LAMP Marker Generator"
+
+// Lamp Marker Generator States
+const (
+	LampMarkerGeneratorStateNone = iota + 1
+	LampMarkerGeneratorStateIdle
+	LampMarkerGeneratorStateWaitForResponse
+)
+
+var LampMarkerGeneratorStateStrMap map[fsm.State]string
+
+func LampMarkerGeneratorStrStateMapCreate() {
+	LampMarkerGeneratorStateStrMap = make(map[fsm.State]string)
+	LampMarkerGeneratorStateStrMap[LampMarkerGeneratorStateNone] = "None"
+	LampMarkerGeneratorStateStrMap[LampMarkerGeneratorStateIdle] = "Idle"
+	LampMarkerGeneratorStateStrMap[LampMarkerGeneratorStateWaitForResponse] = "WaitForResponse"
+}
+
+// lamp generator events
+const (
+	LampMarkerGeneratorEventBegin = iota + 1
+	LampMarkerGeneratorEventSendMarker
+	LampMarkerGeneratorEventMarkerResponseRx
+	LampMarkerGeneratorEventResponseTimerExpired
+)
+
+type LampRxMarkerResponsePdu struct {
+	pdu *LaMarkerPdu
+	src string
+}
+
+// LampMarkerGeneratorMachine holds FSM and current State
+// and event channels for State transitions
+type LampMarkerGeneratorMachine struct {
+	// for debugging
+	PreviousState fsm.State
+
+	Machine *fsm.Machine
+
+	p *LaAggPort
+
+	// debug log
+	log chan string
+
+	// timer interval
+	markerResponseTimerInterval time.Duration
+
+	// timers
+	markerResponseTimer *clock.Timer
+
+	// outstanding marker
+	transactionId uint32
+	// requester waiting for the outstanding marker to complete
+	flushDone chan bool
+
+	// flush requests come from other ports machines, no request may be
+	// sent once the machine is stopped
+	flushMutex   sync.Mutex
+	flushStopped bool
+
+	// machine specific events
+	LampMarkerGeneratorEvents          chan LacpMachineEvent
+	LampMarkerGeneratorFlushEvent      chan chan bool
+	LampMarkerGeneratorPktRxEvent      chan LampRxMarkerResponsePdu
+	LampMarkerGeneratorKillSignalEvent chan bool
+	LampMarkerGeneratorLogEnableEvent  chan bool
+}
+
+func (mg *LampMarkerGeneratorMachine) PrevState() fsm.State { return mg.PreviousState }
+
+// PrevStateSet will set the previous State
+func (mg *LampMarkerGeneratorMachine) PrevStateSet(s fsm.State) { mg.PreviousState = s }
+
+// Stop should clean up all resources
+func (mg *LampMarkerGeneratorMachine) Stop() {
+	mg.MarkerResponseTimerStop()
+
+	// stop the go routine
+	mg.LampMarkerGeneratorKillSignalEvent <- true
+
+	mg.flushMutex.Lock()
+	mg.flushStopped = true
+	close(mg.LampMarkerGeneratorFlushEvent)
+	mg.flushMutex.Unlock()
+
+	close(mg.LampMarkerGeneratorEvents)
+	close(mg.LampMarkerGeneratorPktRxEvent)
+	close(mg.LampMarkerGeneratorKillSignalEvent)
+	close(mg.LampMarkerGeneratorLogEnableEvent)
+}
+
+// A helpful function that lets us apply arbitrary rulesets to this
+// instances State machine without reallocating the machine.
+func (mg *LampMarkerGeneratorMachine) Apply(r *fsm.Ruleset) *fsm.Machine {
+	if mg.Machine == nil {
+		mg.Machine = &fsm.Machine{}
+	}
+
+	// Assign the ruleset to be used for this machine
+	mg.Machine.Rules = r
+	mg.Machine.Curr = &LacpStateEvent{
+		strStateMap: LampMarkerGeneratorStateStrMap,
+		logEna:      mg.p.logEna,
+		logger:      mg.LampMarkerGeneratorLog,
+		owner:       MarkerGeneratorModuleStr,
+	}
+
+	return mg.Machine
+}
+
+// NewLampMarkerGenerator will create a new instance of the LampMarkerGeneratorMachine
+func NewLampMarkerGenerator(port *LaAggPort) *LampMarkerGeneratorMachine {
+	mg := &LampMarkerGeneratorMachine{
+		p:                                  port,
+		log:                                port.LacpDebug.LacpLogChan,
+		PreviousState:                      LampMarkerGeneratorStateNone,
+		markerResponseTimerInterval:        LampMarkerResponseTimeout,
+		LampMarkerGeneratorEvents:          make(chan LacpMachineEvent, 10),
+		LampMarkerGeneratorFlushEvent:      make(chan chan bool, 10),
+		LampMarkerGeneratorPktRxEvent:      make(chan LampRxMarkerResponsePdu, 100),
+		LampMarkerGeneratorKillSignalEvent: make(chan bool),
+		LampMarkerGeneratorLogEnableEvent:  make(chan bool)}
+
+	port.MarkerGeneratorFsm = mg
+
+	// create then stop
+	mg.MarkerResponseTimerStart()
+	mg.MarkerResponseTimerStop()
+
+	return mg
+}
+
+// LampMarkerFlushRequest will request a Marker be sent, done is sent the
+// result once the response is received or times out.  Returns false when
+// the request could not be made because the machine is stopped or busy
+func (mg *LampMarkerGeneratorMachine) LampMarkerFlushRequest(done chan bool) bool {
+	mg.flushMutex.Lock()
+	defer mg.flushMutex.Unlock()
+	if mg.flushStopped {
+		return false
+	}
+	select {
+	case mg.LampMarkerGeneratorFlushEvent <- done:
+		return true
+	default:
+		return false
+	}
+}
+
+// flushComplete will inform the requester that the marker has completed
+func (mg *LampMarkerGeneratorMachine) flushComplete(rcvd bool) {
+	if mg.flushDone != nil {
+		mg.flushDone <- rcvd
+		mg.flushDone = nil
+	}
+}
+
+func (mg *LampMarkerGeneratorMachine) LampMarkerGeneratorIdle(m fsm.Machine, data interface{}) fsm.State {
+	mg.MarkerResponseTimerStop()
+	mg.flushComplete(false)
+	return LampMarkerGeneratorStateIdle
+}
+
+// LampMarkerGeneratorSendMarker will transmit a Marker PDU with a new
+// transaction id and wait for the response
+func (mg *LampMarkerGeneratorMachine) LampMarkerGeneratorSendMarker(m fsm.Machine, data interface{}) fsm.State {
+	p := mg.p
+
+	// a new marker supersedes any outstanding marker
+	mg.flushComplete(false)
+	if done, ok := data.(chan bool); ok {
+		mg.flushDone = done
+	}
+
+	mg.transactionId++
+	marker := &LaMarkerPdu{
+		TlvType:                LampTlvTypeMarkerInfo,
+		RequesterPort:          p.PortNum,
+		RequesterSystem:        p.ActorOper.System.actor_System,
+		RequesterTransactionId: mg.transactionId,
+	}
+
+	if p.LaAggPortTx(marker) {
+		p.LacpCounter.AggPortStatsMarkerPDUsTx += 1
+	}
+
+	mg.MarkerResponseTimerStart()
+	return LampMarkerGeneratorStateWaitForResponse
+}
+
+// LampMarkerGeneratorResponseRx will validate that the response is for the
+// outstanding marker
+func (mg *LampMarkerGeneratorMachine) LampMarkerGeneratorResponseRx(m fsm.Machine, data interface{}) fsm.State {
+	p := mg.p
+	resp := data.(*LaMarkerPdu)
+
+	if mg.flushDone == nil {
+		// response arrived after timeout
+		p.LacpCounter.AggPortStatsMarkerResponseUnexpectedRx += 1
+		return LampMarkerGeneratorStateIdle
+	}
+
+	if resp.RequesterPort != p.PortNum ||
+		resp.RequesterSystem != p.ActorOper.System.actor_System ||
+		resp.RequesterTransactionId != mg.transactionId {
+		p.LacpCounter.AggPortStatsMarkerResponseUnexpectedRx += 1
+		return LampMarkerGeneratorStateWaitForResponse
+	}
+
+	mg.MarkerResponseTimerStop()
+	mg.flushComplete(true)
+	return LampMarkerGeneratorStateIdle
+}
+
+// LampMarkerGeneratorResponseTimeout no response received, the
+// conversations will be moved anyways
+func (mg *LampMarkerGeneratorMachine) LampMarkerGeneratorResponseTimeout(m fsm.Machine, data interface{}) fsm.State {
+	p := mg.p
+	p.LacpCounter.AggPortStatsMarkerResponseTimeouts += 1
+	mg.flushComplete(false)
+	return LampMarkerGeneratorStateIdle
+}
+
+func LampMarkerGeneratorFSMBuild(p *LaAggPort) *LampMarkerGeneratorMachine {
+
+	LampMarkerGeneratorStrStateMapCreate()
+
+	rules := fsm.Ruleset{}
+
+	// Instantiate a new LampMarkerGeneratorMachine
+	// Initial State will be a psuedo State known as "begin" so that
+	// we can transition to the initalize State
+	mg := NewLampMarkerGenerator(p)
+
+	//BEGIN -> IDLE
+	rules.AddRule(LampMarkerGeneratorStateNone, LampMarkerGeneratorEventBegin, mg.LampMarkerGeneratorIdle)
+	rules.AddRule(LampMarkerGeneratorStateIdle, LampMarkerGeneratorEventBegin, mg.LampMarkerGeneratorIdle)
+	rules.AddRule(LampMarkerGeneratorStateWaitForResponse, LampMarkerGeneratorEventBegin, mg.LampMarkerGeneratorIdle)
+
+	// SEND MARKER -> WAIT FOR RESPONSE
+	rules.AddRule(LampMarkerGeneratorStateIdle, LampMarkerGeneratorEventSendMarker, mg.LampMarkerGeneratorSendMarker)
+	rules.AddRule(LampMarkerGeneratorStateWaitForResponse, LampMarkerGeneratorEventSendMarker, mg.LampMarkerGeneratorSendMarker)
+
+	// RESPONSE RX -> IDLE
+	rules.AddRule(LampMarkerGeneratorStateIdle, LampMarkerGeneratorEventMarkerResponseRx, mg.LampMarkerGeneratorResponseRx)
+	rules.AddRule(LampMarkerGeneratorStateWaitForResponse, LampMarkerGeneratorEventMarkerResponseRx, mg.LampMarkerGeneratorResponseRx)
+
+	// TIMEOUT -> IDLE
+	rules.AddRule(LampMarkerGeneratorStateWaitForResponse, LampMarkerGeneratorEventResponseTimerExpired, mg.LampMarkerGeneratorResponseTimeout)
+
+	// Create a new FSM and apply the rules
+	mg.Apply(&rules)
+
+	return mg
+}
+
+// LampMarkerGeneratorMain:  802.1ax-2014 Section 6.5
+// Creation of Marker Generator State Machine State transitions and callbacks
+// and create go routine to pend on events
+func (p *LaAggPort) LampMarkerGeneratorMain() {
+
+	mg := LampMarkerGeneratorFSMBuild(p)
+
+	// set the inital State
+	mg.Machine.Start(mg.PrevState())
+
+	// lets create a go routing which will wait for the specific events
+	// that the Marker Generator should handle.
+	go func(m *LampMarkerGeneratorMachine) {
+		m.LampMarkerGeneratorLog("Machine Start")
+		defer m.p.wg.Done()
+		for {
+			select {
+			case <-m.LampMarkerGeneratorKillSignalEvent:
+				// don't leave the requester waiting
+				m.flushComplete(false)
+				m.LampMarkerGeneratorLog("Machine End")
+				return
+
+			case <-m.markerResponseTimer.C:
+				rv := m.Machine.ProcessEvent(MarkerGeneratorModuleStr, LampMarkerGeneratorEventResponseTimerExpired, nil)
+				if rv != nil {
+					m.LampMarkerGeneratorLog(strings.Join([]string{error.Error(rv), MarkerGeneratorModuleStr, LampMarkerGeneratorStateStrMap[m.Machine.Curr.CurrentState()], strconv.Itoa(int(LampMarkerGeneratorEventResponseTimerExpired))}, ":"))
+				}
+
+			case event := <-m.LampMarkerGeneratorEvents:
+				rv := m.Machine.ProcessEvent(event.src, event.e, nil)
+
+				if rv != nil {
+					m.LampMarkerGeneratorLog(strings.Join([]string{error.Error(rv), event.src, LampMarkerGeneratorStateStrMap[m.Machine.Curr.CurrentState()], strconv.Itoa(int(event.e))}, ":"))
+				}
+
+				// respond to caller if necessary so that we don't have a deadlock
+				if event.responseChan != nil {
+					SendResponse(MarkerGeneratorModuleStr, event.responseChan)
+				}
+
+			case done := <-m.LampMarkerGeneratorFlushEvent:
+				rv := m.Machine.ProcessEvent(MarkerGeneratorModuleStr, LampMarkerGeneratorEventSendMarker, done)
+				if rv != nil {
+					m.LampMarkerGeneratorLog(strings.Join([]string{error.Error(rv), MarkerGeneratorModuleStr, LampMarkerGeneratorStateStrMap[m.Machine.Curr.CurrentState()], strconv.Itoa(int(LampMarkerGeneratorEventSendMarker))}, ":"))
+					// marker was not sent
+					done <- false
+				}
+
+			case rx := <-m.LampMarkerGeneratorPktRxEvent:
+				m.p.LacpCounter.AggPortStatsMarkerResponsePDUsRx += 1
+
+				rv := m.Machine.ProcessEvent(rx.src, LampMarkerGeneratorEventMarkerResponseRx, rx.pdu)
+				if rv != nil {
+					m.LampMarkerGeneratorLog(strings.Join([]string{error.Error(rv), rx.src, LampMarkerGeneratorStateStrMap[m.Machine.Curr.CurrentState()], strconv.Itoa(int(LampMarkerGeneratorEventMarkerResponseRx))}, ":"))
+				}
+
+			case ena := <-m.LampMarkerGeneratorLogEnableEvent:
+				m.Machine.Curr.EnableLogging(ena)
+			}
+		}
+	}(mg)
+}
+
+// LampMarkerFlush will send a Marker PDU on each of the ports, done is
+// called from another go routine once all the Marker Responses have been
+// received or timed out.  The caller is not blocked
+func LampMarkerFlush(ports []*LaAggPort, done func()) {
+	doneList := make([]chan bool, 0)
+	for _, p := range ports {
+		if p.MarkerGeneratorFsm != nil {
+			d := make(chan bool, 1)
+			if p.MarkerGeneratorFsm.LampMarkerFlushRequest(d) {
+				doneList = append(doneList, d)
+			}
+		}
+	}
+
+	// the response timer in each machine should guarantee a response
+	// but don't wait forever in case a port is deleted
+	timeout := LacpClockGet().After(LampMarkerResponseTimeout * 2)
+	go func() {
+		defer done()
+		for _, d := range doneList {
+			select {
+			case <-d:
+			case <-timeout:
+				return
+			}
+		}
+	}()
+}
+
+// LampMarkerFlushPortsGet will return the ports which a Marker can be
+// flushed on, ports which are not operational are skipped as a response
+// will never be received
+func LampMarkerFlushPortsGet(candidates []*LaAggPort) []*LaAggPort {
+	ports := make([]*LaAggPort, 0)
+	for _, p := range candidates {
+		if p.PortEnabled &&
+			p.LinkOperStatus &&
+			p.MarkerGeneratorFsm != nil {
+			ports = append(ports, p)
+		}
+	}
+	return ports
+}
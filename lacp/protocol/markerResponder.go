@@ -152,8 +152,8 @@ func (mr *LampMarkerResponderMachine) LampMarkerResponderRespondToMarker(m fsm.M
 		return LampMarkerResponderStateWaitForMarker
 	}
 
-	// we only want to handle marker pdu, not response since we are not
-	// generating
+	// we only want to handle marker pdu, responses are handled by the
+	// Marker Generator
 	if lampPduInfo.Marker.TlvType != layers.LAMPTLVMarkerInfo {
 		if lampPduInfo.Marker.TlvType == layers.LAMPTLVMarkerResponder {
 			p.LacpCounter.AggPortStatsMarkerResponsePDUsRx += 1
@@ -168,9 +168,7 @@ func (mr *LampMarkerResponderMachine) LampMarkerResponderRespondToMarker(m fsm.M
 		lampResponsePdu := lampPduInfo
 		lampResponsePdu.Marker.TlvType = layers.LAMPTLVMarkerResponder
 
-		for _, ftx := range LaSysGlobalTxCallbackListGet(p) {
-			//txm.LacpTxmLog(fmt.Sprintf("Sending Tx packet port %d pkts %d", p.PortNum, txm.txPkts))
-			ftx(p.PortNum, lampResponsePdu)
+		if p.LaAggPortTx(lampResponsePdu) {
 			p.LacpCounter.AggPortStatsMarkerResponsePDUsTx += 1
 		}
 	}
@@ -242,7 +240,6 @@ func (p *LaAggPort) LampMarkerResponderMain() {
 			case rx := <-m.LampMarkerResponderPktRxEvent:
 				//m.LacpRxmLog(fmt.Sprintf("RXM: received packet %d %s", m.p.PortNum, rx.src))
 				// lets check if the port has moved
-				p.LacpCounter.AggPortStatsMarkerPDUsRx += 1
 
 				rv := m.Machine.ProcessEvent(MarkerResponderModuleStr, LampMarkerResponderEventLampPktRx, rx.pdu)
 				if rv != nil {
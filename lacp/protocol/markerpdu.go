@@ -24,19 +24,63 @@
 // markerpdu
 package lacp
 
-import ()
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
 
+// 802.1ax-2014 Section 6.5.3 Marker PDU
+const (
+	LampVersion                 uint8 = 0x01
+	LampTlvTypeMarkerInfo       uint8 = 0x01
+	LampTlvTypeMarkerResponse   uint8 = 0x02
+	LampMarkerInformationLength uint8 = 16
+)
+
+// subType through reserved is 110 octets, subType filled in by the
+// slow protocol layer
+const LampPduLength int = 109
+
+// LaMarkerPdu holds the Marker Information/Response TLV, the
+// Marker Response is a copy of the Marker Information with the TLV
+// type changed
 type LaMarkerPdu struct {
-	subType                   uint8 // 2
-	version                   uint8
-	tlv_type_marker_info      uint8
-	marker_information_length uint8 // 16
-	requestor_port            uint16
-	requestor_System          [6]uint8
-	requestor_transaction_id  [4]uint8
-	pad                       uint16
-	tlv_type_terminator       uint8 // terminator 0x00
-	terminator_length         uint8
-	reserved                  [90]uint8
-	// FCS 4 bytes
+	TlvType                uint8
+	RequesterPort          uint16
+	RequesterSystem        [6]uint8
+	RequesterTransactionId uint32
+}
+
+// LampMarkerPduEncode will encode the Marker PDU starting at the version field
+func LampMarkerPduEncode(pdu *LaMarkerPdu) []byte {
+	data := make([]byte, LampPduLength)
+	data[0] = LampVersion
+	data[1] = pdu.TlvType
+	data[2] = LampMarkerInformationLength
+	binary.BigEndian.PutUint16(data[3:], pdu.RequesterPort)
+	copy(data[5:11], pdu.RequesterSystem[:])
+	binary.BigEndian.PutUint32(data[11:], pdu.RequesterTransactionId)
+	// pad, terminator and reserved are zero
+	return data
+}
+
+// LampMarkerPduDecode will decode the Marker PDU, data should start at the
+// version field
+func LampMarkerPduDecode(data []byte, pdu *LaMarkerPdu) error {
+	if len(data) < 1+int(LampMarkerInformationLength) {
+		return errors.New(fmt.Sprintf("Marker PDU too short %d", len(data)))
+	}
+	if data[1] != LampTlvTypeMarkerInfo &&
+		data[1] != LampTlvTypeMarkerResponse {
+		return errors.New(fmt.Sprintf("Marker PDU invalid TLV type %d", data[1]))
+	}
+	if data[2] != LampMarkerInformationLength {
+		return errors.New(fmt.Sprintf("Marker PDU invalid TLV length %d", data[2]))
+	}
+	pdu.TlvType = data[1]
+	pdu.RequesterPort = binary.BigEndian.Uint16(data[3:])
+	copy(pdu.RequesterSystem[:], data[5:11])
+	pdu.RequesterTransactionId = binary.BigEndian.Uint32(data[11:])
+	return nil
 }
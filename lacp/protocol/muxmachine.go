@@ -28,6 +28,7 @@ package lacp
 
 import (
 	"fmt"
+	"l2/clock"
 	"sort"
 	"strconv"
 	"strings"
@@ -80,6 +81,10 @@ func MuxxMachineStrStateMapCreate() {
 	MuxmEventStrMap[LacpMuxmEventNotPartnerSync] = "Event Partner Oper Sync state is NOT set"
 	MuxmEventStrMap[LacpMuxmEventNotPartnerCollecting] = "Event Partner Oper Collecting state is not set"
 	MuxmEventStrMap[LacpMuxmEventSelectedEqualSelectedPartnerSyncCollecting] = "Event Selected equals Selected and Partner Oper Sync and Collecting state is set"
+	MuxmEventStrMap[LacpMuxmEventStandbyUpdate] = "Event Standby selection changed"
+	MuxmEventStrMap[LacpMuxmEventMinLinksUpdate] = "Event Min Links changed"
+	MuxmEventStrMap[LacpMuxmEventMinLinksHold] = "Event Below Min Links"
+	MuxmEventStrMap[LacpMuxmEventMinLinksRelease] = "Event At Min Links"
 
 }
 
@@ -93,6 +98,12 @@ const (
 	LacpMuxmEventNotPartnerSync
 	LacpMuxmEventNotPartnerCollecting
 	LacpMuxmEventSelectedEqualSelectedPartnerSyncCollecting
+	// re-evaluate the standby selection of the port
+	LacpMuxmEventStandbyUpdate
+	// re-evaluate the min links hold of the port
+	LacpMuxmEventMinLinksUpdate
+	LacpMuxmEventMinLinksHold
+	LacpMuxmEventMinLinksRelease
 )
 
 // LacpRxMachine holds FSM and current State
@@ -116,7 +127,15 @@ type LacpMuxMachine struct {
 	waitWhileTimerRunning bool
 
 	// timers
-	waitWhileTimer *time.Timer
+	waitWhileTimer *clock.Timer
+
+	// conversations which move between links wait for the Marker
+	// Responses, the continuation is run by this machine once the flush
+	// with the same generation completes
+	markerFlushGen   uint32
+	markerFlushCont  func()
+	markerFlushJoin  bool
+	markerFlushEvent chan uint32
 
 	// machine specific events
 	MuxmEvents          chan LacpMachineEvent
@@ -150,6 +169,7 @@ func NewLacpMuxMachine(port *LaAggPort) *LacpMuxMachine {
 		waitWhileTimerTimeout: LacpAggregateWaitTime,
 		PreviousState:         LacpMuxmStateNone,
 		MuxmEvents:            make(chan LacpMachineEvent, 10),
+		markerFlushEvent:      make(chan uint32, 10),
 		MuxmKillSignalEvent:   make(chan bool),
 		MuxmLogEnableEvent:    make(chan bool)}
 
@@ -187,12 +207,30 @@ func (muxm *LacpMuxMachine) SendTxMachineNtt() {
 		src: MuxMachineModuleStr}
 }
 
+// LacpMuxmAttachedSet records whether the mux is attached to the aggregator
+// and informs the other ports of the aggregator as they may now be above
+// or below min links
+func (muxm *LacpMuxMachine) LacpMuxmAttachedSet(attached bool) {
+	var a *LaAggregator
+	p := muxm.p
+
+	if p.muxAttached == attached {
+		return
+	}
+	p.muxAttached = attached
+	if LaFindAggById(p.AggId, &a) &&
+		a.AggMinLinks > 1 {
+		a.LacpAggMinLinksUpdate()
+	}
+}
+
 // LacpMuxmDetached
 func (muxm *LacpMuxMachine) LacpMuxmDetached(m fsm.Machine, data interface{}) fsm.State {
 	p := muxm.p
 
 	// DETACH MUX FROM AGGREGATOR
 	muxm.DetachMuxFromAggregator()
+	muxm.LacpMuxmAttachedSet(false)
 
 	// Actor Oper State Sync = FALSE
 	LacpStateClear(&p.ActorOper.State, LacpStateSyncBit)
@@ -230,6 +268,7 @@ func (muxm *LacpMuxMachine) LacpMuxmWaiting(m fsm.Machine, data interface{}) fsm
 	p := muxm.p
 
 	skipWaitWhileTimer := false
+	muxm.LacpMuxmAttachedSet(false)
 
 	// only need to kick off the timer if ready is not true
 	// ready will be true if all other ports are attached
@@ -261,21 +300,30 @@ func (muxm *LacpMuxMachine) LacpMuxmAttached(m fsm.Machine, data interface{}) fs
 	p := muxm.p
 	// Attach Mux to Aggregator
 	muxm.AttachMuxToAggregator()
-
-	// Actor Oper State Sync = TRUE
-	//muxm.LacpMuxmLog("Setting Actor Sync Bit")
-	LacpStateSet(&p.ActorOper.State, LacpStateSyncBit)
-	// inform cdm
-	p.CdMachineFsm.CdmEvents <- LacpMachineEvent{e: LacpCdmEventActorOperPortStateSyncOn,
-		src: MuxMachineModuleStr}
+	muxm.LacpMuxmAttachedSet(true)
+
+	// Actor Oper State Sync = TRUE, unless the aggregator is below min
+	// links in which case the partner must not use the link
+	if p.LacpMinLinksHold() {
+		muxm.LacpMuxmLog("Below min links, clearing Actor Sync Bit")
+		LacpStateClear(&p.ActorOper.State, LacpStateSyncBit)
+		p.CdMachineFsm.CdmEvents <- LacpMachineEvent{e: LacpCdmEventActorOperPortStateSyncOff,
+			src: MuxMachineModuleStr}
+	} else {
+		//muxm.LacpMuxmLog("Setting Actor Sync Bit")
+		LacpStateSet(&p.ActorOper.State, LacpStateSyncBit)
+		// inform cdm
+		p.CdMachineFsm.CdmEvents <- LacpMachineEvent{e: LacpCdmEventActorOperPortStateSyncOn,
+			src: MuxMachineModuleStr}
+	}
 
 	// debug
 	if p.AggPortDebug.AggPortDebugActorSyncTransitionCount == 0 {
-		muxm.actorSyncTransitionTimestamp = time.Now()
+		muxm.actorSyncTransitionTimestamp = LacpClockGet().Now()
 		p.AggPortDebug.AggPortDebugActorSyncTransitionCount++
-	} else if time.Now().Second()-muxm.actorSyncTransitionTimestamp.Second() > 5 {
+	} else if LacpClockGet().Now().Second()-muxm.actorSyncTransitionTimestamp.Second() > 5 {
 		p.AggPortDebug.AggPortDebugActorSyncTransitionCount++
-		muxm.actorSyncTransitionTimestamp = time.Now()
+		muxm.actorSyncTransitionTimestamp = LacpClockGet().Now()
 	}
 
 	// Actor Oper State Collecting = FALSE
@@ -338,6 +386,7 @@ func (muxm *LacpMuxMachine) LacpMuxmCDetached(m fsm.Machine, data interface{}) f
 
 	// DETACH MUX FROM AGGREGATOR
 	muxm.DetachMuxFromAggregator()
+	muxm.LacpMuxmAttachedSet(false)
 
 	// Actor Oper State Sync = FALSE
 	// Actor Oper State Collecting = FALSE
@@ -362,6 +411,7 @@ func (muxm *LacpMuxMachine) LacpMuxmCDetached(m fsm.Machine, data interface{}) f
 func (muxm *LacpMuxMachine) LacpMuxmCWaiting(m fsm.Machine, data interface{}) fsm.State {
 	//p := muxm.p
 
+	muxm.LacpMuxmAttachedSet(false)
 	muxm.WaitWhileTimerStart()
 
 	return LacpMuxmStateWaiting
@@ -373,6 +423,7 @@ func (muxm *LacpMuxMachine) LacpMuxmCAttached(m fsm.Machine, data interface{}) f
 
 	// Attach Mux to Aggregator
 	muxm.AttachMuxToAggregator()
+	muxm.LacpMuxmAttachedSet(true)
 
 	// Actor Oper State Sync = TRUE
 	LacpStateSet(&p.ActorOper.State, LacpStateSyncBit)
@@ -453,6 +504,11 @@ func (p *LaAggPort) LacpMuxMachineFSMBuild() *LacpMuxMachine {
 	rules.AddRule(LacpMuxmStateDistributing, LacpMuxmEventSelectedEqualStandby, muxm.LacpMuxmCollecting)
 	rules.AddRule(LacpMuxmStateDistributing, LacpMuxmEventNotPartnerSync, muxm.LacpMuxmCollecting)
 	rules.AddRule(LacpMuxmStateDistributing, LacpMuxmEventNotPartnerCollecting, muxm.LacpMuxmCollecting)
+	// BELOW MIN LINKS -> ATTACHED, re-entering ATTACHED updates Actor Sync
+	rules.AddRule(LacpMuxmStateAttached, LacpMuxmEventMinLinksHold, muxm.LacpMuxmAttached)
+	rules.AddRule(LacpMuxmStateCollecting, LacpMuxmEventMinLinksHold, muxm.LacpMuxmAttached)
+	rules.AddRule(LacpMuxmStateDistributing, LacpMuxmEventMinLinksHold, muxm.LacpMuxmCollecting)
+	rules.AddRule(LacpMuxmStateAttached, LacpMuxmEventMinLinksRelease, muxm.LacpMuxmAttached)
 
 	// MUX Coupled
 	//BEGIN -> DETACHED
@@ -510,6 +566,14 @@ func (p *LaAggPort) LacpMuxMachineMain() {
 				m.LacpMuxmLog("Machine End")
 				return
 
+			case gen := <-m.markerFlushEvent:
+				if gen == m.markerFlushGen &&
+					m.markerFlushCont != nil {
+					cont := m.markerFlushCont
+					m.markerFlushCont = nil
+					cont()
+				}
+
 			case <-m.waitWhileTimer.C:
 				m.LacpMuxmLog("MUXM: Wait While Timer Expired")
 				// lets evaluate selection
@@ -533,24 +597,62 @@ func (p *LaAggPort) LacpMuxMachineMain() {
 				//m.LacpMuxmLog(fmt.Sprintf("Event received %d src %s", event.e, event.src))
 				eventStr := strings.Join([]string{"from", event.src, MuxmEventStrMap[int(event.e)]}, " ")
 
+				// selection changes made by the aggregator are applied
+				// by the machine which owns the port
+				if event.e == LacpMuxmEventStandbyUpdate {
+					event.e = m.LacpMuxmStandbyEvaluate()
+				} else if event.e == LacpMuxmEventMinLinksUpdate {
+					event.e = m.LacpMuxmMinLinksEvaluate()
+				}
+				noChange := event.e == LacpMuxmEventStandbyUpdate ||
+					event.e == LacpMuxmEventMinLinksUpdate
+
 				// process the event
-				rv := m.Machine.ProcessEvent(event.src, event.e, nil)
+				var rv error
+				if !noChange {
+					rv = m.Machine.ProcessEvent(event.src, event.e, nil)
+				}
 
 				if rv != nil {
 					m.LacpMuxmLog(strings.Join([]string{error.Error(rv), event.src, MuxmStateStrMap[m.Machine.Curr.CurrentState()], strconv.Itoa(int(event.e))}, ":"))
-				} else {
+				} else if !noChange {
 
 					// continuation events
+					if event.e == LacpMuxmEventSelectedEqualStandby &&
+						p.aggSelected == LacpAggStandby {
+						// Standby will cause a downward transition to waiting State
+						eventStr = strings.Join([]string{eventStr,
+							"and\nfrom", MuxMachineModuleStr, MuxmEventStrMap[LacpMuxmEventSelectedEqualStandby]}, " ")
+						for i := 0; i < 3 &&
+							m.Machine.Curr.CurrentState() != LacpMuxmStateWaiting &&
+							m.Machine.Curr.CurrentState() != LacpMuxmStateCWaiting; i++ {
+							m.Machine.ProcessEvent(MuxMachineModuleStr, LacpMuxmEventSelectedEqualStandby, nil)
+						}
+					}
+					if event.e == LacpMuxmEventMinLinksHold {
+						// below min links will cause a downward transition to attached State
+						for i := 0; i < 2 &&
+							m.Machine.Curr.CurrentState() != LacpMuxmStateAttached; i++ {
+							m.Machine.ProcessEvent(MuxMachineModuleStr, LacpMuxmEventMinLinksHold, nil)
+						}
+					}
 					if m.Machine.Curr.CurrentState() == LacpMuxmStateDetached ||
 						m.Machine.Curr.CurrentState() == LacpMuxmStateCDetached {
 						// if port is attached then we know that provisioning found
 						// a valid agg thus port should be attached.
 						if p.AggAttached != nil &&
-							p.PortEnabled {
-							// change the selection to be Selected
+							p.PortEnabled &&
+							!p.LacpFallbackIndividual() {
+							// change the selection to be Selected, max links
+							// may require this port to be standby
 							p.aggSelected = LacpAggSelected
+							if p.AggAttached.LacpAggPortIsStandby(p) {
+								p.aggSelected = LacpAggStandby
+							}
 							//muxm.LacpMuxmLog("Setting Actor Aggregation Bit")
 							LacpStateSet(&p.ActorOper.State, LacpStateAggregationBit)
+							// this port may take over from a lower priority port
+							p.AggAttached.LacpAggSelectStandby()
 
 							eventStr = strings.Join([]string{eventStr,
 								"and\nfrom", MuxMachineModuleStr, MuxmEventStrMap[LacpMuxmEventSelectedEqualSelected]}, " ")
@@ -570,7 +672,9 @@ func (p *LaAggPort) LacpMuxMachineMain() {
 					if (m.Machine.Curr.CurrentState() == LacpMuxmStateAttached ||
 						m.Machine.Curr.CurrentState() == LacpMuxmStateCAttached) &&
 						p.aggSelected == LacpAggSelected &&
-						LacpStateIsSet(p.PartnerOper.State, LacpStateSyncBit) {
+						LacpStateIsSet(p.PartnerOper.State, LacpStateSyncBit) &&
+						!p.LacpFallbackHold() &&
+						!p.LacpMinLinksHold() {
 
 						eventStr = strings.Join([]string{eventStr,
 							"and\nfrom", MuxMachineModuleStr, MuxmEventStrMap[LacpMuxmEventSelectedEqualSelectedAndPartnerSync]}, " ")
@@ -664,6 +768,68 @@ func (muxm *LacpMuxMachine) LacpMuxmWaitingEvaluateSelected(sendResponse bool) {
 	}
 }
 
+// LacpMuxmStandbyEvaluate will apply a change in the standby selection of
+// the port, returns the event which moves the port to the new selection or
+// LacpMuxmEventStandbyUpdate if no transition is required
+func (muxm *LacpMuxMachine) LacpMuxmStandbyEvaluate() fsm.Event {
+	var a *LaAggregator
+	p := muxm.p
+
+	if (p.aggSelected != LacpAggSelected &&
+		p.aggSelected != LacpAggStandby) ||
+		!LaFindAggById(p.AggId, &a) {
+		return LacpMuxmEventStandbyUpdate
+	}
+
+	state := muxm.Machine.Curr.CurrentState()
+	standby := a.LacpAggPortIsStandby(p)
+	if standby && p.aggSelected == LacpAggSelected {
+		muxm.LacpMuxmLog(fmt.Sprintf("Agg %d max links %d reached, port %d is standby", a.AggId, a.AggMaxLinks, p.PortNum))
+		p.aggSelected = LacpAggStandby
+		// ports which are not yet attached will be held in waiting
+		if state != LacpMuxmStateNone &&
+			state != LacpMuxmStateDetached &&
+			state != LacpMuxmStateWaiting &&
+			state != LacpMuxmStateCDetached &&
+			state != LacpMuxmStateCWaiting {
+			return LacpMuxmEventSelectedEqualStandby
+		}
+	} else if !standby && p.aggSelected == LacpAggStandby {
+		muxm.LacpMuxmLog(fmt.Sprintf("Agg %d promoting port %d from standby", a.AggId, p.PortNum))
+		p.aggSelected = LacpAggSelected
+		// standby ports are held in waiting
+		if state == LacpMuxmStateWaiting ||
+			state == LacpMuxmStateCWaiting {
+			return LacpMuxmEventSelectedEqualSelectedAndReady
+		}
+	}
+	return LacpMuxmEventStandbyUpdate
+}
+
+// LacpMuxmMinLinksEvaluate will hold the port in Attached, with Actor Sync
+// cleared, while the aggregator is below min links and release it once min
+// links is reached.  Returns the event which applies the change or
+// LacpMuxmEventMinLinksUpdate if no transition is required
+func (muxm *LacpMuxMachine) LacpMuxmMinLinksEvaluate() fsm.Event {
+	p := muxm.p
+
+	hold := p.LacpMinLinksHold()
+	switch muxm.Machine.Curr.CurrentState() {
+	case LacpMuxmStateAttached:
+		if hold == LacpStateIsSet(p.ActorOper.State, LacpStateSyncBit) {
+			if hold {
+				return LacpMuxmEventMinLinksHold
+			}
+			return LacpMuxmEventMinLinksRelease
+		}
+	case LacpMuxmStateCollecting, LacpMuxmStateDistributing:
+		if hold {
+			return LacpMuxmEventMinLinksHold
+		}
+	}
+	return LacpMuxmEventMinLinksUpdate
+}
+
 // AttachMuxToAggregator is a required function defined in 802.1ax-2014
 // Section 6.4.9
 // This function causes the Aggregation Port’s Control Parser/Multiplexer
@@ -675,6 +841,11 @@ func (muxm *LacpMuxMachine) AttachMuxToAggregator() {
 	if LaFindAggById(p.AggId, &p.AggAttached) {
 		LacpStateSet(&p.ActorOper.State, LacpStateAggregationBit)
 		muxm.LacpMuxmLog("Attach Mux To Aggregator Enter, send Add PORT to ASICD")
+		if hw, ok := LacpHwPluginGet().(LagHwPortAttach); ok && !p.muxAttached {
+			if err := hw.AttachLagPort(p.AggAttached.lagHwConfigGet(), p.IntfNum); err != nil {
+				muxm.LacpMuxmLog(fmt.Sprintf("Attach port to lag failed %s", err))
+			}
+		}
 	}
 }
 
@@ -686,12 +857,19 @@ func (muxm *LacpMuxMachine) AttachMuxToAggregator() {
 func (muxm *LacpMuxMachine) DetachMuxFromAggregator() {
 	// TODO send message to asic deamon delete
 	muxm.LacpMuxmLog("Detach Mux From Aggregator Enter")
-	//p := muxm.p
+	p := muxm.p
 	//p.AggAttached = nil
 	// should already be in unselected State
 	//p.aggSelected = LacpAggUnSelected
 
 	// Remove port from HW lag group
+	if hw, ok := LacpHwPluginGet().(LagHwPortAttach); ok &&
+		p.muxAttached &&
+		p.AggAttached != nil {
+		if err := hw.DetachLagPort(p.AggAttached.lagHwConfigGet(), p.IntfNum); err != nil {
+			muxm.LacpMuxmLog(fmt.Sprintf("Detach port from lag failed %s", err))
+		}
+	}
 }
 
 // EnableCollecting is a required function defined in 802.1ax-2014
@@ -723,6 +901,45 @@ func (muxm *LacpMuxMachine) DisableCollecting() {
 // to which the Aggregation Port is attached to start distributing frames
 // to the Aggregation Port.
 func (muxm *LacpMuxMachine) EnableDistributing() {
+	a := muxm.p.AggAttached
+
+	if a != nil {
+
+		// conversations may move to this port from the other links,
+		// they are stopped on those links and this port only starts
+		// distributing once the frames already sent have been delivered
+		ports := LampMarkerFlushPortsGet(a.lacpConversationActivePorts())
+		if len(ports) > 0 {
+			a.LacpConversationStopMoving(muxm.p)
+			muxm.LacpMuxmLog("Sending Marker on distributing ports")
+			muxm.LacpMuxmMarkerFlush(ports, muxm.distributingStart, true)
+			return
+		}
+		muxm.distributingStart()
+	}
+}
+
+// LacpMuxmMarkerFlush will send a Marker on the ports, cont is run by this
+// machine once the Marker Responses have been received or timed out.  Only
+// the continuation of the latest flush is run, join indicates that the port
+// is waiting to start distributing
+func (muxm *LacpMuxMachine) LacpMuxmMarkerFlush(ports []*LaAggPort, cont func(), join bool) {
+	muxm.markerFlushGen++
+	gen := muxm.markerFlushGen
+	muxm.markerFlushCont = cont
+	muxm.markerFlushJoin = join
+	flushEvent := muxm.markerFlushEvent
+	LampMarkerFlush(ports, func() {
+		// the machine may have been stopped, never block
+		select {
+		case flushEvent <- gen:
+		default:
+		}
+	})
+}
+
+// distributingStart adds the port to the distributing ports of the lag
+func (muxm *LacpMuxMachine) distributingStart() {
 	p := muxm.p
 	a := muxm.p.AggAttached
 
@@ -734,13 +951,16 @@ func (muxm *LacpMuxMachine) EnableDistributing() {
 		sort.Strings(a.DistributedPortNumList)
 
 		muxm.LacpMuxmLog(fmt.Sprintf("Agg %d hwAggId %d EnableDistributing PortsListLen %d PortList %v", p.AggId, a.HwAggId, len(a.DistributedPortNumList), a.DistributedPortNumList))
-		if len(a.DistributedPortNumList) == 1 {
-			a.HwAggId = asicDCreateLag(a)
-			a.OperState = true
-			// TODO UPDATE SQL DB for warm boot purposes
-		} else {
-			asicDUpdateLag(a)
+		a.LacpNotify(LacpNotifyAggMemberAdded, p, LacpNotifyReasonNone)
+		a.LacpAggOperStateUpdate(LacpNotifyReasonNone)
+		// Version 2 conversation ids need to be reassigned
+		a.LacpUpdateConversationPortList()
+		// neighbor Portal System needs to know about the active port
+		if a.dr != nil {
+			a.dr.DrniUpdatePortalState()
 		}
+		// a recovered port may take over from a lower priority port
+		a.LacpAggSelectStandby()
 	}
 }
 
@@ -756,6 +976,14 @@ func (muxm *LacpMuxMachine) DisableDistributing() {
 
 	if a != nil {
 
+		// a port waiting to start distributing must not once the Marker
+		// completes, the conversations stopped for it return to their link
+		if muxm.markerFlushJoin &&
+			muxm.markerFlushCont != nil {
+			muxm.markerFlushCont = nil
+			a.LacpUpdateConversationPortList()
+		}
+
 		portFound = false
 		for j := 0; j < len(a.DistributedPortNumList) && !portFound; j++ {
 			if p.IntfNum == a.DistributedPortNumList[j] {
@@ -769,16 +997,29 @@ func (muxm *LacpMuxMachine) DisableDistributing() {
 
 			muxm.LacpMuxmLog(fmt.Sprintf("Agg %d DisableDistributing PortsListLen %d PortList %v", p.AggId, len(a.DistributedPortNumList), a.DistributedPortNumList))
 
-			asicDUpdateLag(a)
-
-			if len(a.DistributedPortNumList) == 0 {
-				muxm.LacpMuxmLog("Sending Lag Delete to ASICD")
-				asicDDeleteLag(a)
-				a.HwAggId = 0
-				// no more ports active in group, lets mark the lag as operationally down
-				a.OperState = false
-				// TODO UPDATE SQL DB
+			reason := p.LacpNotifyReasonGet()
+			a.LacpNotify(LacpNotifyAggMemberRemoved, p, reason)
+			a.LacpAggOperStateUpdate(reason)
+			// Version 2 conversation ids need to be reassigned once
+			// the frames already sent on this port have been delivered,
+			// until then they are not distributed on any link.  A port
+			// which is down can't receive a response
+			moveConversations := func() {
+				a.LacpUpdateConversationPortList()
+				// neighbor Portal System may need to take over the
+				// conversations of this port
+				if a.dr != nil {
+					a.dr.DrniUpdatePortalState()
+				}
+			}
+			if ports := LampMarkerFlushPortsGet([]*LaAggPort{p}); len(ports) > 0 {
+				muxm.LacpMuxmLog("Sending Marker on port")
+				muxm.LacpMuxmMarkerFlush(ports, moveConversations, false)
+			} else {
+				moveConversations()
 			}
+			// a standby port may take over for this port
+			a.LacpAggSelectStandby()
 		}
 	}
 }
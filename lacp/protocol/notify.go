@@ -0,0 +1,166 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// notify will inform registered clients of aggregator oper state and
+// membership changes so that they do not need to poll lacpd
+package lacp
+
+import (
+	"fmt"
+	"time"
+)
+
+// notification types
+const (
+	LacpNotifyAggOperStateUp = iota + 1
+	LacpNotifyAggOperStateDown
+	LacpNotifyAggMemberAdded
+	LacpNotifyAggMemberRemoved
+)
+
+// reason for the notification
+const (
+	LacpNotifyReasonNone = iota
+	LacpNotifyReasonAdminDown
+	LacpNotifyReasonPortDown
+	LacpNotifyReasonMinLinks
+	LacpNotifyReasonPartnerTimeout
+	LacpNotifyReasonPartnerOutOfSync
+	LacpNotifyReasonChurn
+	LacpNotifyReasonStandby
+	LacpNotifyReasonUnselected
+)
+
+var LacpNotifyStrMap = map[uint8]string{
+	LacpNotifyAggOperStateUp:   "AggOperStateUp",
+	LacpNotifyAggOperStateDown: "AggOperStateDown",
+	LacpNotifyAggMemberAdded:   "AggMemberAdded",
+	LacpNotifyAggMemberRemoved: "AggMemberRemoved",
+}
+
+var LacpNotifyReasonStrMap = map[uint8]string{
+	LacpNotifyReasonNone:             "None",
+	LacpNotifyReasonAdminDown:        "AdminDown",
+	LacpNotifyReasonPortDown:         "PortDown",
+	LacpNotifyReasonMinLinks:         "MinLinks",
+	LacpNotifyReasonPartnerTimeout:   "PartnerTimeout",
+	LacpNotifyReasonPartnerOutOfSync: "PartnerOutOfSync",
+	LacpNotifyReasonChurn:            "Churn",
+	LacpNotifyReasonStandby:          "Standby",
+	LacpNotifyReasonUnselected:       "Unselected",
+}
+
+// LacpNotifyAggMsg is sent for all notification types, IfIndex is only
+// valid for member notifications
+type LacpNotifyAggMsg struct {
+	AggId     int32
+	AggName   string
+	IfIndex   int32
+	IntfRef   string
+	OperState bool
+	Reason    uint8
+	ReasonStr string
+	TimeStamp string
+}
+
+// LacpNotifyCallback is called in the context of the State machine which
+// caused the change so it should not block
+type LacpNotifyCallback func(msgType uint8, msg LacpNotifyAggMsg)
+
+var gLacpNotifyCallbackMap = make(map[string]LacpNotifyCallback)
+
+// LacpNotifyRegisterCallback will register a client for notifications
+func LacpNotifyRegisterCallback(name string, cb LacpNotifyCallback) {
+	gLacpNotifyCallbackMap[name] = cb
+}
+
+// LacpNotifyDeRegisterCallback will remove a client
+func LacpNotifyDeRegisterCallback(name string) {
+	delete(gLacpNotifyCallbackMap, name)
+}
+
+// LacpNotify will send the notification to all registered clients, port
+// is nil for aggregator oper state notifications
+func (a *LaAggregator) LacpNotify(msgType uint8, p *LaAggPort, reason uint8) {
+
+	if (msgType == LacpNotifyAggOperStateUp ||
+		msgType == LacpNotifyAggOperStateDown) &&
+		!a.AggLinkUpDownNotificationEnable {
+		return
+	}
+
+	msg := LacpNotifyAggMsg{
+		AggId:     int32(a.AggId),
+		AggName:   a.AggName,
+		OperState: a.OperState,
+		Reason:    reason,
+		ReasonStr: LacpNotifyReasonStrMap[reason],
+		TimeStamp: time.Now().String(),
+	}
+	if p != nil {
+		msg.IfIndex = int32(p.PortNum)
+		msg.IntfRef = p.IntfNum
+	}
+
+	if a.LacpDebug != nil {
+		a.LacpDebug.logger.Info(fmt.Sprintf("Agg %d notify %s port %d reason %s", a.AggId, LacpNotifyStrMap[msgType], msg.IfIndex, msg.ReasonStr))
+	}
+
+	for _, cb := range gLacpNotifyCallbackMap {
+		cb(msgType, msg)
+	}
+}
+
+// LacpNotifyReasonGet will determine why a port is no longer distributing
+func (p *LaAggPort) LacpNotifyReasonGet() uint8 {
+	var a *LaAggregator
+
+	if LaFindAggById(p.AggId, &a) &&
+		!a.AdminState {
+		return LacpNotifyReasonAdminDown
+	}
+	if !p.PortEnabled ||
+		!p.LinkOperStatus {
+		return LacpNotifyReasonPortDown
+	}
+	if p.LacpMinLinksHold() {
+		return LacpNotifyReasonMinLinks
+	}
+	if p.actorChurn ||
+		p.partnerChurn {
+		return LacpNotifyReasonChurn
+	}
+	if p.aggSelected == LacpAggStandby {
+		return LacpNotifyReasonStandby
+	}
+	if p.aggSelected == LacpAggUnSelected {
+		return LacpNotifyReasonUnselected
+	}
+	if LacpStateIsSet(p.ActorOper.State, LacpStateExpiredBit) ||
+		p.RxMachineFsm != nil &&
+			p.RxMachineFsm.Machine != nil &&
+			p.RxMachineFsm.Machine.Curr.CurrentState() == LacpRxmStateDefaulted {
+		return LacpNotifyReasonPartnerTimeout
+	}
+	return LacpNotifyReasonPartnerOutOfSync
+}
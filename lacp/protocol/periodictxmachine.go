@@ -26,6 +26,7 @@ package lacp
 
 import (
 	//"fmt"
+	"l2/clock"
 	"time"
 	"utils/fsm"
 )
@@ -80,7 +81,7 @@ type LacpPtxMachine struct {
 	PeriodicTxTimerInterval time.Duration
 
 	// timer
-	periodicTxTimer *time.Timer
+	periodicTxTimer *clock.Timer
 
 	// machine specific events
 	PtxmEvents chan LacpMachineEvent
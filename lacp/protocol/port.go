@@ -26,9 +26,8 @@ package lacp
 
 import (
 	"fmt"
-	"github.com/google/gopacket"
 	"github.com/google/gopacket/layers"
-	"github.com/google/gopacket/pcap"
+	"l2/pktio"
 	"net"
 	"strings"
 	"sync"
@@ -189,6 +188,10 @@ type AggPortStatsObject struct {
 	AggPortStatsLACPDUsTx            uint64
 	AggPortStatsMarkerPDUsTx         uint64
 	AggPortStatsMarkerResponsePDUsTx uint64
+	// Marker Generator, no response received for a Marker PDU
+	AggPortStatsMarkerResponseTimeouts uint64
+	// Marker Generator, response did not match outstanding Marker PDU
+	AggPortStatsMarkerResponseUnexpectedRx uint64
 }
 
 //GET
@@ -267,6 +270,14 @@ type LaAggPort struct {
 	partnerAdmin LacpPortInfo
 	PartnerOper  LacpPortInfo
 
+	// port is forwarding as an individual link because no LACPDU was
+	// received within the aggregator fallback timeout
+	fallback bool
+
+	// mux is Attached, Collecting or Distributing, counts towards the
+	// aggregator min links
+	muxAttached bool
+
 	// State machines
 	RxMachineFsm       *LacpRxMachine
 	PtxMachineFsm      *LacpPtxMachine
@@ -275,6 +286,8 @@ type LaAggPort struct {
 	PCdMachineFsm      *LacpPartnerCdMachine
 	MuxMachineFsm      *LacpMuxMachine
 	MarkerResponderFsm *LampMarkerResponderMachine
+	CsCdMachineFsm     *LacpCsCdMachine
+	MarkerGeneratorFsm *LampMarkerGeneratorMachine
 
 	// Counters
 	LacpCounter AggPortStatsObject
@@ -293,7 +306,7 @@ type LaAggPort struct {
 	wg       sync.WaitGroup
 
 	// handle used to tx packets to linux if
-	handle *pcap.Handle
+	handle pktio.Handle
 
 	// Version 2
 	partnerLacpPduVersionNumber int
@@ -301,6 +314,24 @@ type LaAggPort struct {
 	// packet is 1 byte, but spec says save as int.
 	// going to save as byte
 	partnerVersion uint8
+	// version of the protocol being run on this port
+	actorVersion uint8
+	// 802.1ax-2014 Section 6.6.2.2 Link_Number_ID
+	LinkNumberId uint16
+	// Version 2 TLV info received from partner
+	partnerV2Oper LacpV2Info
+	// Port_Oper_Conversation_Mask
+	actorConversationMask [LacpConversationMaskSize]uint8
+	// ActPar_Sync
+	actParSync bool
+	// Differ_Port_Conversation_Digests
+	differPortConversationDigest bool
+	// Differ_Conversation_Service_Digests
+	differConvServiceDigests bool
+	// Differ_Port_Algorithms
+	differPortAlgorithms bool
+	// Discard_Wrong_Conversation
+	discardWrongConversation bool
 
 	sysId net.HardwareAddr
 }
@@ -324,6 +355,19 @@ func LaFindPortById(pId uint16, port **LaAggPort) bool {
 	return false
 }
 
+// find a port from the global map table by interface name
+func LaFindPortByName(intf string, port **LaAggPort) bool {
+	for _, sgi := range LacpSysGlobalInfoGet() {
+		for _, p := range sgi.LacpSysGlobalAggPortListGet() {
+			if p.IntfNum == intf {
+				*port = p
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func LaConvertPortAndPriToPortId(pId uint16, prio uint16) int {
 	return int(pId | prio<<16)
 }
@@ -394,10 +438,14 @@ func NewLaAggPort(config *LaAggPortConfig) *LaAggPort {
 	// otherwise lets use the default
 	var a *LaAggregator
 	var sysId LacpSystem
+	version := LacpVersion1
 	if LaFindAggByKey(config.Key, &a) {
 		mac, _ := net.ParseMAC(a.Config.SystemIdMac)
 		sysId.actor_System = convertNetHwAddressToSysIdKey(mac)
 		sysId.Actor_System_priority = a.Config.SystemPriority
+		if a.Version != 0 {
+			version = a.Version
+		}
 	}
 	sgi := LacpSysGlobalInfoByIdGet(sysId)
 
@@ -419,6 +467,8 @@ func NewLaAggPort(config *LaAggPortConfig) *LaAggPort {
 			Mtu:    config.Properties.Mtu},
 		logEna:       true,
 		portChan:     make(chan string),
+		actorVersion: version,
+		LinkNumberId: uint16(config.Id),
 		AggPortDebug: AggPortDebugInformationObject{AggPortDebugInformationID: int(config.Id)}}
 
 	// Start Port Logger
@@ -451,28 +501,26 @@ func NewLaAggPort(config *LaAggPortConfig) *LaAggPort {
 
 	sgi.PortList = append(sgi.PortList, p)
 
-	handle, err := pcap.OpenLive(p.IntfNum, 65536, false, 50*time.Millisecond)
+	pktIoCfg := LacpPktIoConfigGet()
+	if pktIoCfg.Filter == "" {
+		pktIoCfg.Filter = LacpPktIoPortFilter
+	}
+	handle, err := pktio.Open(p.IntfNum, pktIoCfg)
 	if err != nil {
 		// failure here may be ok as this may be SIM
 		if !strings.Contains(p.IntfNum, "SIM") {
-			fmt.Println("Error creating pcap OpenLive handle for port", p.PortNum, p.IntfNum, err)
+			fmt.Println("Error creating", pktIoCfg.Type, "handle for port", p.PortNum, p.IntfNum, err)
 		}
 		return p
 	}
 	fmt.Println("Creating Listener for intf", p.IntfNum)
 	//p.LaPortLog(fmt.Sprintf("Creating Listener for intf", p.IntfNum))
 	p.handle = handle
-	src := gopacket.NewPacketSource(p.handle, layers.LayerTypeEthernet)
-	in := src.Packets()
+	in := p.handle.Packets()
 	// start rx routine
 	LaRxMain(p.PortNum, in)
 	fmt.Println("Rx Main Started for port", p.PortNum)
 
-	// register the tx func
-	sgi.LaSysGlobalRegisterTxCallback(p.IntfNum, TxViaLinuxIf)
-
-	fmt.Println("Tx Callback Registered with")
-
 	//fmt.Println("New Port:\n%#v", *p)
 
 	return p
@@ -493,7 +541,7 @@ func (p *LaAggPort) IsPortAdminEnabled() bool {
 }
 
 func (p *LaAggPort) IsPortOperStatusUp() bool {
-	p.LinkOperStatus = asicdGetPortLinkStatus(p.IntfNum)
+	p.LinkOperStatus = LacpHwPluginGet().GetLinkState(p.IntfNum)
 	return p.LinkOperStatus
 }
 
@@ -524,18 +572,6 @@ func (p *LaAggPort) LaAggPortDelete() {
 
 func (p *LaAggPort) Stop() {
 
-	var a *LaAggregator
-	var sysId LacpSystem
-	if LaFindAggById(p.AggId, &a) {
-		mac, _ := net.ParseMAC(a.Config.SystemIdMac)
-		sysId.actor_System = convertNetHwAddressToSysIdKey(mac)
-		sysId.Actor_System_priority = a.Config.SystemPriority
-	}
-
-	sgi := LacpSysGlobalInfoByIdGet(sysId)
-	if sgi != nil {
-		sgi.LaSysGlobalDeRegisterTxCallback(p.IntfNum)
-	}
 	// close rx/tx processing
 	// TODO figure out why this call does not return
 	if p.handle != nil {
@@ -582,6 +618,16 @@ func (p *LaAggPort) Stop() {
 	} else {
 		p.wg.Done()
 	}
+	if p.CsCdMachineFsm != nil {
+		p.CsCdMachineFsm.Stop()
+	} else {
+		p.wg.Done()
+	}
+	if p.MarkerGeneratorFsm != nil {
+		p.MarkerGeneratorFsm.Stop()
+	} else {
+		p.wg.Done()
+	}
 	// lets wait for all the State machines to have stopped
 	p.wg.Wait()
 	fmt.Println("All machines stopped for port", p.PortNum)
@@ -626,6 +672,10 @@ func (p *LaAggPort) BEGIN(restart bool) {
 		p.LacpTxMachineMain()
 		// Marker Responder
 		p.LampMarkerResponderMain()
+		// Conversation-sensitive Collection and Distribution
+		p.LacpCsCdMachineMain()
+		// Marker Generator
+		p.LampMarkerGeneratorMain()
 	}
 
 	// wait group used when stopping all the
@@ -639,6 +689,8 @@ func (p *LaAggPort) BEGIN(restart bool) {
 	// 4) Periodic Tx Machine
 	// 5) Churn Detection Machine * 2
 	// 6) Marker Responder
+	// 7) Conversation-sensitive Collection and Distribution Machine
+	// 8) Marker Generator
 	// Rxm
 	if p.RxMachineFsm != nil {
 		mEvtChan = append(mEvtChan, p.RxMachineFsm.RxmEvents)
@@ -689,6 +741,20 @@ func (p *LaAggPort) BEGIN(restart bool) {
 			src: PortConfigModuleStr})
 		p.wg.Add(1)
 	}
+	// CsCdm
+	if p.CsCdMachineFsm != nil {
+		mEvtChan = append(mEvtChan, p.CsCdMachineFsm.CsCdmEvents)
+		evt = append(evt, LacpMachineEvent{e: LacpCsCdmEventBegin,
+			src: PortConfigModuleStr})
+		p.wg.Add(1)
+	}
+	// Marker Generator
+	if p.MarkerGeneratorFsm != nil {
+		mEvtChan = append(mEvtChan, p.MarkerGeneratorFsm.LampMarkerGeneratorEvents)
+		evt = append(evt, LacpMachineEvent{e: LampMarkerGeneratorEventBegin,
+			src: PortConfigModuleStr})
+		p.wg.Add(1)
+	}
 	// call the begin event for each
 	// distribute the port disable event to various machines
 	p.DistributeMachineEvents(mEvtChan, evt, true)
@@ -783,8 +849,21 @@ func (p *LaAggPort) LaAggPortDisable() {
 	evt = append(evt, LacpMachineEvent{e: LacpTxmEventLacpDisabled,
 		src: PortConfigModuleStr})
 
+	// CsCdm
+	if p.CsCdMachineFsm != nil {
+		mEvtChan = append(mEvtChan, p.CsCdMachineFsm.CsCdmEvents)
+		evt = append(evt, LacpMachineEvent{e: LacpCsCdmEventNotConvSensitive,
+			src: PortConfigModuleStr})
+	}
+
 	// distribute the port disable event to various machines
 	p.DistributeMachineEvents(mEvtChan, evt, true)
+
+	// a standby port may take over for this port
+	var a *LaAggregator
+	if LaFindAggById(p.AggId, &a) {
+		a.LacpAggSelectStandby()
+	}
 }
 
 // LaAggPortEnabled will update the status on the port
@@ -965,6 +1044,12 @@ func (p *LaAggPort) ModeGet() int {
 	return LacpModeGet(p.ActorOper.State, p.lacpEnabled)
 }
 
+// VersionGet will return the lacp version being run by the
+// actor and the version last reported by the partner
+func (p *LaAggPort) VersionGet() (uint8, uint8) {
+	return p.actorVersion, p.partnerVersion
+}
+
 func LacpCopyLacpPortInfoFromPkt(fromPortInfoPtr *layers.LACPPortInfo, toPortInfoPtr *LacpPortInfo) {
 	toPortInfoPtr.Key = fromPortInfoPtr.Key
 	toPortInfoPtr.port = fromPortInfoPtr.Port
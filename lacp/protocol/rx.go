@@ -56,7 +56,9 @@ func LaRxMain(pId uint16, rxPktChan chan gopacket.Packet) {
 					//fmt.Println("RxMain: port", rxMainPort)
 					//fmt.Println("RX:", packet)
 
-					if marker, lacp := IsControlFrame(rxMainPort, packet); lacp || marker {
+					if IsDrcpFrame(packet) {
+						ProcessDrcpFrame(rxMainPort, packet)
+					} else if marker, lacp := IsControlFrame(rxMainPort, packet); lacp || marker {
 						//fmt.Println("IsControl Frame ", marker, lacp)
 						if lacp {
 							lacpLayer := packet.Layer(layers.LayerTypeLACP)
@@ -67,7 +69,20 @@ func LaRxMain(pId uint16, rxPktChan chan gopacket.Packet) {
 								// lacp data
 								lacp := lacpLayer.(*layers.LACP)
 
-								ProcessLacpFrame(rxMainPort, lacp)
+								// gopacket does not decode the Version 2 TLV's
+								var v2 *LacpV2Info
+								if uint8(lacp.Version) >= LacpVersion2 {
+									slowProtocolLayer := packet.Layer(layers.LayerTypeSlowProtocol)
+									if slowProtocolLayer != nil {
+										v2 = &LacpV2Info{}
+										if err := LacpV2PduDecode(slowProtocolLayer.LayerPayload(), v2); err != nil {
+											fmt.Println("Received invalid LACPDU Version 2 TLV", err)
+											v2 = nil
+										}
+									}
+								}
+
+								ProcessLacpFrame(rxMainPort, lacp, v2)
 							}
 						} else if marker {
 							lampLayer := packet.Layer(layers.LayerTypeLAMP)
@@ -78,7 +93,20 @@ func LaRxMain(pId uint16, rxPktChan chan gopacket.Packet) {
 								// lamp data
 								lamp := lampLayer.(*layers.LAMP)
 
-								ProcessLampFrame(rxMainPort, lamp)
+								// responses belong to the Marker Generator
+								if lamp.Marker.TlvType == layers.LAMPTLVMarkerResponder {
+									slowProtocolLayer := packet.Layer(layers.LayerTypeSlowProtocol)
+									if slowProtocolLayer != nil {
+										marker := &LaMarkerPdu{}
+										if err := LampMarkerPduDecode(slowProtocolLayer.LayerPayload(), marker); err != nil {
+											fmt.Println("Received invalid Marker Response PDU", err)
+										} else {
+											ProcessLampResponseFrame(rxMainPort, marker)
+										}
+									}
+								} else {
+									ProcessLampFrame(rxMainPort, lamp)
+								}
 							}
 						} else {
 							fmt.Println("Discard Packet not an lacp frame")
@@ -156,7 +184,8 @@ func IsControlFrame(pId uint16, packet gopacket.Packet) (bool, bool) {
 
 // ProcessLacpFrame will lookup the cooresponding port from which the
 // packet arrived and forward the packet to the Rx Machine for processing
-func ProcessLacpFrame(pId uint16, lacp *layers.LACP) {
+// v2 is only valid when the packet contained the Version 2 TLV's
+func ProcessLacpFrame(pId uint16, lacp *layers.LACP, v2 *LacpV2Info) {
 	var p *LaAggPort
 
 	//fmt.Println(lacp)
@@ -165,6 +194,7 @@ func ProcessLacpFrame(pId uint16, lacp *layers.LACP) {
 		//fmt.Println(lacp)
 		p.RxMachineFsm.RxmPktRxEvent <- LacpRxLacpPdu{
 			pdu: lacp,
+			v2:  v2,
 			src: RxModuleStr}
 	} else {
 		fmt.Println("LACP: Unable to find port", pId)
@@ -183,3 +213,52 @@ func ProcessLampFrame(pId uint16, lamp *layers.LAMP) {
 		fmt.Println("LAMP: Unable to find port", pId)
 	}
 }
+
+// ProcessLampResponseFrame will forward the Marker Response to the
+// Marker Generator of the port which sent the Marker
+func ProcessLampResponseFrame(pId uint16, marker *LaMarkerPdu) {
+	var p *LaAggPort
+
+	if LaFindPortById(pId, &p) {
+		p.MarkerGeneratorFsm.LampMarkerGeneratorPktRxEvent <- LampRxMarkerResponsePdu{
+			pdu: marker,
+			src: RxModuleStr}
+	} else {
+		fmt.Println("LAMP: Unable to find port", pId)
+	}
+}
+
+// IsDrcpFrame will check if the frame is a DRCPDU received on the
+// Intra-Portal Link 802.1ax-2014 Section 9.4.3
+func IsDrcpFrame(packet gopacket.Packet) bool {
+	ethernetLayer := packet.Layer(layers.LayerTypeEthernet)
+	if ethernetLayer == nil {
+		return false
+	}
+	ethernet := ethernetLayer.(*layers.Ethernet)
+	return ethernet.EthernetType == DrcpEthernetType
+}
+
+// ProcessDrcpFrame will decode the DRCPDU and forward it to the DRCPDU
+// Receive machine of the Intra-Portal Port which it arrived on
+func ProcessDrcpFrame(pId uint16, packet gopacket.Packet) {
+	var ipp *DRCPIpp
+
+	if !DrFindIppById(pId, &ipp) {
+		fmt.Println("DRCP: Unable to find ipp", pId)
+		return
+	}
+
+	ethernet := packet.Layer(layers.LayerTypeEthernet).(*layers.Ethernet)
+	drcp := &DRCPDU{}
+	if err := DRCPDUDecode(ethernet.LayerPayload(), drcp); err != nil {
+		ipp.DrcpCounter.IllegalRx += 1
+		fmt.Println("Received invalid DRCPDU", err)
+		return
+	}
+	if ipp.RxMachineFsm != nil {
+		ipp.RxMachineFsm.DrcpRxmPktRxEvent <- DrcpRxPdu{
+			pdu: drcp,
+			src: RxModuleStr}
+	}
+}
@@ -27,6 +27,7 @@ package lacp
 import (
 	"fmt"
 	"github.com/google/gopacket/layers"
+	"l2/clock"
 	"reflect"
 	"strconv"
 	"strings"
@@ -72,10 +73,14 @@ const (
 	LacpRxmEventLacpEnabled
 	LacpRxmEventLacpPktRx
 	LacpRxmEventKillSignal
+	// another port of the aggregator left fallback, restart the
+	// fallback timer if still Defaulted
+	LacpRxmEventFallbackRetry
 )
 
 type LacpRxLacpPdu struct {
 	pdu          *layers.LACP
+	v2           *LacpV2Info
 	src          string
 	responseChan chan string
 }
@@ -95,9 +100,14 @@ type LacpRxMachine struct {
 
 	// timer interval
 	currentWhileTimerTimeout time.Duration
+	fallbackTimerTimeout     time.Duration
 
 	// timers
-	currentWhileTimer *time.Timer
+	currentWhileTimer *clock.Timer
+	fallbackTimer     *clock.Timer
+
+	// Version 2 TLV's of the packet being processed
+	rxV2 *LacpV2Info
 
 	// machine specific events
 	RxmEvents          chan LacpMachineEvent
@@ -114,6 +124,7 @@ func (rxm *LacpRxMachine) PrevStateSet(s fsm.State) { rxm.PreviousState = s }
 // Stop should clean up all resources
 func (rxm *LacpRxMachine) Stop() {
 	rxm.CurrentWhileTimerStop()
+	rxm.FallbackTimerStop()
 
 	// stop the go routine
 	rxm.RxmKillSignalEvent <- true
@@ -141,6 +152,9 @@ func NewLacpRxMachine(port *LaAggPort) *LacpRxMachine {
 	// create then stop
 	rxm.CurrentWhileTimerStart()
 	rxm.CurrentWhileTimerStop()
+	rxm.FallbackTimerTimeoutSet(LacpFallbackDefaultTimeout)
+	rxm.FallbackTimerStart()
+	rxm.FallbackTimerStop()
 
 	return rxm
 }
@@ -173,6 +187,9 @@ func (rxm *LacpRxMachine) LacpRxMachineInitialize(m fsm.Machine, data interface{
 		rxm.CurrentWhileTimerTimeoutSet(timeoutTime)
 		rxm.CurrentWhileTimerStart()
 	}
+	// leave fallback, port will reselect
+	rxm.LacpRxMachineFallbackExit()
+
 	// Lets ensure that the port moves to the correct defaulted State
 	// after initialization.  Default params will change after lacp
 	// packets have arrived
@@ -203,6 +220,9 @@ func (rxm *LacpRxMachine) LacpRxMachineInitialize(m fsm.Machine, data interface{
 func (rxm *LacpRxMachine) LacpRxMachinePortDisabled(m fsm.Machine, data interface{}) fsm.State {
 	p := rxm.p
 
+	// link is down so fallback no longer applies
+	rxm.LacpRxMachineFallbackExit()
+
 	// Partner Port Oper State Sync = False
 	LacpStateClear(&p.PartnerOper.State, LacpStateSyncBit)
 
@@ -262,6 +282,7 @@ func (rxm *LacpRxMachine) LacpRxMachineLacpDisabled(m fsm.Machine, data interfac
 	// stop the current while timer as it does not need to run as LACP is now
 	// disabled
 	rxm.CurrentWhileTimerStop()
+	rxm.LacpRxMachineFallbackExit()
 
 	// Unselect the aggregator
 	//p.aggSelected = LacpAggUnSelected
@@ -312,6 +333,9 @@ func (rxm *LacpRxMachine) LacpRxMachineCurrent(m fsm.Machine, data interface{})
 	// Version 1, V2 will require a serialize/deserialize routine since TLV's are involved
 	lacpPduInfo := data.(*layers.LACP)
 
+	// partner is now running LACP, return to normal operation
+	rxm.LacpRxMachineFallbackExit()
+
 	// update selection logic
 	rxm.updateSelected(lacpPduInfo)
 
@@ -319,8 +343,9 @@ func (rxm *LacpRxMachine) LacpRxMachineCurrent(m fsm.Machine, data interface{})
 	ntt := rxm.updateNTT(lacpPduInfo)
 
 	// Version 2 or higher check
-	if LacpActorSystemLacpVersion >= 0x2 {
+	if p.actorVersion >= LacpVersion2 {
 		rxm.recordVersionNumber(lacpPduInfo)
+		rxm.recordV2Info(rxm.rxV2)
 	}
 
 	// record the current packet State
@@ -374,8 +399,9 @@ func (rxm *LacpRxMachine) InformMachinesOfStateChanges() {
 		// lets inform the MUX of a possible State change
 		if LacpStateIsSet(p.PartnerOper.State, LacpStateSyncBit) {
 			if p.aggSelected == LacpAggSelected {
-				if p.MuxMachineFsm.Machine.Curr.CurrentState() == LacpMuxmStateAttached ||
-					p.MuxMachineFsm.Machine.Curr.CurrentState() == LacpMuxmStateCAttached {
+				if (p.MuxMachineFsm.Machine.Curr.CurrentState() == LacpMuxmStateAttached ||
+					p.MuxMachineFsm.Machine.Curr.CurrentState() == LacpMuxmStateCAttached) &&
+					!p.LacpMinLinksHold() {
 					p.MuxMachineFsm.MuxmEvents <- LacpMachineEvent{e: LacpMuxmEventSelectedEqualSelectedAndPartnerSync,
 						src: RxMachineModuleStr}
 				} else if p.MuxMachineFsm.Machine.Curr.CurrentState() == LacpMuxmStateCollecting {
@@ -510,7 +536,21 @@ func (p *LaAggPort) LacpRxMachineMain() {
 					m.Machine.ProcessEvent(RxMachineModuleStr, LacpRxmEventCurrentWhileTimerExpired, nil)
 				}
 
+			case <-m.fallbackTimer.C:
+				m.LacpRxmLog("RXM: Fallback Timer Expired")
+				m.LacpRxMachineFallback()
+
 			case event := <-m.RxmEvents:
+				if event.e == LacpRxmEventFallbackRetry {
+					// not a state transition
+					if m.Machine.Curr.CurrentState() == LacpRxmStateDefaulted {
+						m.LacpRxMachineFallbackTimerStart()
+					}
+					if event.responseChan != nil {
+						SendResponse(RxMachineModuleStr, event.responseChan)
+					}
+					break
+				}
 				rv := m.Machine.ProcessEvent(event.src, event.e, nil)
 				if rv == nil {
 					p := m.p
@@ -561,7 +601,9 @@ func (p *LaAggPort) LacpRxMachineMain() {
 					// Expired/Defaulted/Current. each
 					// State will transition to current
 					// all other States should be ignored.
+					m.rxV2 = rx.v2
 					m.Machine.ProcessEvent(RxModuleStr, LacpRxmEventLacpPktRx, rx.pdu)
+					m.rxV2 = nil
 				}
 
 				// respond to caller if necessary so that we don't have a deadlock
@@ -696,10 +738,17 @@ func (rxm *LacpRxMachine) recordDefault() {
 		}
 	}
 
+	// port in fallback forwards as if the partner were in sync
+	if p.fallback {
+		LacpStateSet(&p.PartnerOper.State, LacpStateAggregatibleUp)
+	}
+
 	if p.MuxMachineFsm != nil &&
 		(p.MuxMachineFsm.Machine.Curr.CurrentState() == LacpMuxmStateAttached ||
 			p.MuxMachineFsm.Machine.Curr.CurrentState() == LacpMuxmStateCAttached) &&
-		p.aggSelected == LacpAggSelected {
+		p.aggSelected == LacpAggSelected &&
+		!p.LacpFallbackHold() &&
+		!p.LacpMinLinksHold() {
 		p.MuxMachineFsm.MuxmEvents <- LacpMachineEvent{e: LacpMuxmEventSelectedEqualSelectedAndPartnerSync,
 			src: RxMachineModuleStr}
 	}
@@ -739,6 +788,65 @@ func (rxm *LacpRxMachine) recordVersionNumber(lacpPduInfo *layers.LACP) {
 	p.partnerVersion = uint8(lacpPduInfo.Version)
 }
 
+// recordV2Info 802.1ax-2014 Section 6.6.2.6 will record the partner
+// Version 2 TLV info and compare against the actor info.  If the partner
+// is running Version 1 then the port is no longer conversation sensitive
+func (rxm *LacpRxMachine) recordV2Info(v2 *LacpV2Info) {
+
+	p := rxm.p
+
+	if p.partnerVersion < LacpVersion2 || v2 == nil {
+		// negotiate down to Version 1
+		if p.enableLongPduXmit || p.actParSync {
+			rxm.LacpRxmLog("Partner is Version 1, Conversation-sensitive Collection and Distribution disabled")
+		}
+		p.enableLongPduXmit = false
+		if p.CsCdMachineFsm != nil &&
+			p.CsCdMachineFsm.Machine.Curr.CurrentState() != LacpCsCdmStateInitialize {
+			p.CsCdMachineFsm.CsCdmEvents <- LacpMachineEvent{e: LacpCsCdmEventNotConvSensitive,
+				src: RxMachineModuleStr}
+		}
+		return
+	}
+
+	maskRcvd := v2.Long
+	if !maskRcvd {
+		// keep the last mask received from the partner
+		v2.ConvMask = p.partnerV2Oper.ConvMask
+		v2.MaskState = p.partnerV2Oper.MaskState
+		v2.Long = p.partnerV2Oper.Long
+	}
+	p.partnerV2Oper = *v2
+
+	if a := p.AggAttached; a != nil {
+		p.differPortConversationDigest = v2.ConvLinkListDigest != a.LacpConversationLinkListDigest()
+		p.differConvServiceDigests = v2.ConvServiceMappingDigest != a.LacpConversationServiceMappingDigest()
+		p.differPortAlgorithms = v2.PortAlgorithm != a.PortAlgorithm
+	}
+
+	// 6.4.2.4.3 masks need to be exchanged only when the
+	// conversation assignment may differ
+	enableLongPduXmit := p.differPortConversationDigest ||
+		p.differConvServiceDigests ||
+		p.differPortAlgorithms
+	if enableLongPduXmit != p.enableLongPduXmit {
+		p.enableLongPduXmit = enableLongPduXmit
+		if p.TxMachineFsm != nil {
+			p.TxMachineFsm.TxmEvents <- LacpMachineEvent{e: LacpTxmEventNtt,
+				src: RxMachineModuleStr}
+		}
+	}
+
+	if p.CsCdMachineFsm != nil {
+		e := LacpCsCdmEventUpdateMask
+		if maskRcvd {
+			e = LacpCsCdmEventPartnerMaskRcvd
+		}
+		p.CsCdMachineFsm.CsCdmEvents <- LacpMachineEvent{e: fsm.Event(e),
+			src: RxMachineModuleStr}
+	}
+}
+
 // currentWhileTimerValid checks the State against
 // the Actor Port Oper State Timeout
 func (rxm *LacpRxMachine) CurrentWhileTimerValid() (time.Duration, bool) {
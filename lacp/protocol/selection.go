@@ -25,8 +25,10 @@
 package lacp
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/google/gopacket/layers"
+	"sort"
 	"sync"
 )
 
@@ -170,7 +172,8 @@ func (a *LaAggregator) LacpMuxCheckSelectionLogic(p *LaAggPort, sendResponse boo
 				var port *LaAggPort
 				p.MuxMachineFsm.LacpMuxmLog(fmt.Sprintf("LacpMuxCheckSelectionLogic: looking for port %d", id))
 				if LaFindPortById(id, &port) &&
-					port.readyN {
+					port.readyN &&
+					port.aggSelected == LacpAggSelected {
 					// trigger event to mux
 					// event should be defered in the processing
 					port.MuxMachineFsm.Machine.ProcessEvent(MuxMachineModuleStr, LacpMuxmEventSelectedEqualSelectedAndReady, nil)
@@ -239,6 +242,144 @@ func (rxm *LacpRxMachine) updateDefaultSelected() {
 				src: RxMachineModuleStr}
 		}
 	}
+
+	// partner is not running LACP, if configured wait for the fallback
+	// timeout before bringing the port up as an individual link
+	rxm.LacpRxMachineFallbackTimerStart()
+}
+
+// LaAggPortStandbySort will sort ports by the Port Identifier of the
+// System which controls standby selection
+type LaAggPortStandbySort []*LaAggPort
+
+func (s LaAggPortStandbySort) Len() int      { return len(s) }
+func (s LaAggPortStandbySort) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s LaAggPortStandbySort) Less(i, j int) bool {
+	iPri, iPort := s[i].lacpStandbyPortId()
+	jPri, jPort := s[j].lacpStandbyPortId()
+	if iPri != jPri {
+		return iPri < jPri
+	}
+	return iPort < jPort
+}
+
+// lacpStandbyPortId 802.1ax-2014 Section 6.7.1
+// The System with the numerically lower System Identifier controls which
+// ports are active, ports are ordered by that Systems Port Priority and
+// Port Number
+func (p *LaAggPort) lacpStandbyPortId() (uint16, uint16) {
+	actorSysId := LacpSystemIdGet(p.ActorOper.System)
+	partnerSysId := LacpSystemIdGet(p.PartnerOper.System)
+
+	if LacpStateIsSet(p.ActorOper.State, LacpStateDefaultedBit) ||
+		bytes.Compare(actorSysId[:], partnerSysId[:]) <= 0 {
+		return p.ActorOper.Port_pri, p.ActorOper.port
+	}
+	return p.PartnerOper.Port_pri, p.PartnerOper.port
+}
+
+// LacpAggPortIsStandby 802.1ax-2014 Section 6.7.1
+// When more ports are selected than the aggregator max links allows the
+// lowest priority ports are placed in STANDBY, return true if the port is
+// one of them
+func (a *LaAggregator) LacpAggPortIsStandby(p *LaAggPort) bool {
+	if a.AggMaxLinks == 0 {
+		return false
+	}
+
+	ports := make([]*LaAggPort, 0)
+	for _, pId := range a.PortNumList {
+		var op *LaAggPort
+		if pId == p.PortNum {
+			ports = append(ports, p)
+		} else if LaFindPortById(pId, &op) &&
+			op.PortEnabled &&
+			(op.aggSelected == LacpAggSelected ||
+				op.aggSelected == LacpAggStandby) {
+			ports = append(ports, op)
+		}
+	}
+	sort.Sort(LaAggPortStandbySort(ports))
+
+	for i, op := range ports {
+		if op == p {
+			return i >= int(a.AggMaxLinks)
+		}
+	}
+	return false
+}
+
+// LacpAggSelectStandby will inform the mux machine of each selected port
+// whose standby selection has changed.  Standby ports are held in WAITING
+// and are promoted as soon as an active port fails.  The mux machine owns
+// the selection of its port so it will re-evaluate and apply the change
+func (a *LaAggregator) LacpAggSelectStandby() {
+	for _, pId := range a.PortNumList {
+		var p *LaAggPort
+		if LaFindPortById(pId, &p) &&
+			p.PortEnabled &&
+			p.MuxMachineFsm != nil &&
+			(p.aggSelected == LacpAggSelected ||
+				p.aggSelected == LacpAggStandby) &&
+			a.LacpAggPortIsStandby(p) != (p.aggSelected == LacpAggStandby) {
+			p.DistributeMachineEvents([]chan LacpMachineEvent{p.MuxMachineFsm.MuxmEvents},
+				[]LacpMachineEvent{LacpMachineEvent{e: LacpMuxmEventStandbyUpdate}}, false)
+		}
+	}
+}
+
+// lacpMinLinksReady returns true when the port is attached to the
+// aggregator, such ports count towards the aggregator min links
+func (p *LaAggPort) lacpMinLinksReady() bool {
+	return p.aggSelected == LacpAggSelected &&
+		p.muxAttached
+}
+
+// LacpAggMinLinksHold returns true when fewer than min links ports are
+// attached to the aggregator
+func (a *LaAggregator) LacpAggMinLinksHold() bool {
+	if a.AggMinLinks <= 1 {
+		return false
+	}
+	ready := 0
+	for _, pId := range a.PortNumList {
+		var p *LaAggPort
+		if LaFindPortById(pId, &p) &&
+			p.lacpMinLinksReady() {
+			ready++
+		}
+	}
+	return ready < int(a.AggMinLinks)
+}
+
+// LacpMinLinksHold returns true when the port must not move past Attached
+// because fewer than min links ports of the aggregator are attached.  While
+// held the Actor Sync is cleared so that the partner does not use the link
+func (p *LaAggPort) LacpMinLinksHold() bool {
+	var a *LaAggregator
+
+	return LaFindAggById(p.AggId, &a) &&
+		a.LacpAggMinLinksHold()
+}
+
+// LacpAggMinLinksUpdate will inform the mux machine of each attached port
+// that the number of attached ports or the min links has changed so that
+// the ports may be held in, or released from, Attached
+func (a *LaAggregator) LacpAggMinLinksUpdate() {
+	for _, pId := range a.PortNumList {
+		var p *LaAggPort
+		if LaFindPortById(pId, &p) &&
+			p.MuxMachineFsm != nil {
+			p.DistributeMachineEvents([]chan LacpMachineEvent{p.MuxMachineFsm.MuxmEvents},
+				[]LacpMachineEvent{LacpMachineEvent{e: LacpMuxmEventMinLinksUpdate}}, false)
+		}
+	}
+}
+
+// IsStandby returns true when the port has been selected but is held in
+// standby because the aggregator max links has been reached
+func (p *LaAggPort) IsStandby() bool {
+	return p.aggSelected == LacpAggStandby
 }
 
 // checkConfigForSelection will send selection bit to State machine
@@ -252,13 +393,20 @@ func (p *LaAggPort) checkConfigForSelection() bool {
 		if (p.MuxMachineFsm.Machine.Curr.CurrentState() == LacpMuxmStateDetached ||
 			p.MuxMachineFsm.Machine.Curr.CurrentState() == LacpMuxmStateCDetached) &&
 			p.Key == a.actorAdminKey &&
-			p.PortEnabled {
+			p.PortEnabled &&
+			!p.LacpFallbackIndividual() {
 
 			p.LaPortLog("checkConfigForSelection: selected")
 
-			// set port as selected
+			// set port as selected, max links may require this
+			// port to be standby
 			p.aggSelected = LacpAggSelected
+			if a.LacpAggPortIsStandby(p) {
+				p.aggSelected = LacpAggStandby
+			}
 			LacpStateSet(&p.ActorOper.State, LacpStateAggregationBit)
+			// this port may take over from a lower priority port
+			a.LacpAggSelectStandby()
 
 			mEvtChan := make([]chan LacpMachineEvent, 0)
 			evt := make([]LacpMachineEvent, 0)
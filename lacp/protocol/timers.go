@@ -25,14 +25,30 @@
 package lacp
 
 import (
+	"l2/clock"
 	"time"
 )
 
+// clock used by all the state machine timers
+var gLacpClock clock.Clock = clock.NewRealClock()
+
+// LacpClockSet will replace the clock used by the state machine timers,
+// tests use a clock.ManualClock in order to step the timers.  Must be
+// called before any ports are created as running timers keep the clock
+// they were created with
+func LacpClockSet(c clock.Clock) {
+	gLacpClock = c
+}
+
+func LacpClockGet() clock.Clock {
+	return gLacpClock
+}
+
 // WaitWhileTimerStart
 // Start the timer
 func (muxm *LacpMuxMachine) WaitWhileTimerStart() {
 	if muxm.waitWhileTimer == nil {
-		muxm.waitWhileTimer = time.NewTimer(muxm.waitWhileTimerTimeout)
+		muxm.waitWhileTimer = LacpClockGet().NewTimer(muxm.waitWhileTimerTimeout)
 	} else {
 		muxm.waitWhileTimer.Reset(muxm.waitWhileTimerTimeout)
 	}
@@ -55,7 +71,7 @@ func (muxm *LacpMuxMachine) WaitWhileTimerTimeoutSet(timeout time.Duration) {
 
 func (rxm *LacpRxMachine) CurrentWhileTimerStart() {
 	if rxm.currentWhileTimer == nil {
-		rxm.currentWhileTimer = time.NewTimer(rxm.currentWhileTimerTimeout)
+		rxm.currentWhileTimer = LacpClockGet().NewTimer(rxm.currentWhileTimerTimeout)
 	} else {
 		rxm.currentWhileTimer.Reset(rxm.currentWhileTimerTimeout)
 	}
@@ -71,9 +87,29 @@ func (rxm *LacpRxMachine) CurrentWhileTimerTimeoutSet(timeout time.Duration) {
 	rxm.currentWhileTimerTimeout = timeout
 }
 
+// FallbackTimerStart used by the Rx Machine while Defaulted to determine
+// when the port should fallback to individual operation
+func (rxm *LacpRxMachine) FallbackTimerStart() {
+	if rxm.fallbackTimer == nil {
+		rxm.fallbackTimer = LacpClockGet().NewTimer(rxm.fallbackTimerTimeout)
+	} else {
+		rxm.fallbackTimer.Reset(rxm.fallbackTimerTimeout)
+	}
+}
+
+func (rxm *LacpRxMachine) FallbackTimerStop() {
+	if rxm.fallbackTimer != nil {
+		rxm.fallbackTimer.Stop()
+	}
+}
+
+func (rxm *LacpRxMachine) FallbackTimerTimeoutSet(timeout time.Duration) {
+	rxm.fallbackTimerTimeout = timeout
+}
+
 func (ptxm *LacpPtxMachine) PeriodicTimerStart() {
 	if ptxm.periodicTxTimer == nil {
-		ptxm.periodicTxTimer = time.NewTimer(ptxm.PeriodicTxTimerInterval)
+		ptxm.periodicTxTimer = LacpClockGet().NewTimer(ptxm.PeriodicTxTimerInterval)
 	} else {
 		ptxm.periodicTxTimer.Reset(ptxm.PeriodicTxTimerInterval)
 	}
@@ -91,7 +127,7 @@ func (ptxm *LacpPtxMachine) PeriodicTimerIntervalSet(interval time.Duration) {
 
 func (cdm *LacpCdMachine) ChurnDetectionTimerStart() {
 	if cdm.churnTimer == nil {
-		cdm.churnTimer = time.NewTimer(cdm.churnTimerInterval)
+		cdm.churnTimer = LacpClockGet().NewTimer(cdm.churnTimerInterval)
 	} else {
 		cdm.churnTimer.Reset(cdm.churnTimerInterval)
 	}
@@ -107,6 +143,38 @@ func (cdm *LacpCdMachine) ChurnDetectionTimerIntervalSet(interval time.Duration)
 	cdm.churnTimerInterval = interval
 }
 
+func (cscdm *LacpCsCdMachine) CDSChurnDetectionTimerStart() {
+	if cscdm.cdsChurnTimer == nil {
+		cscdm.cdsChurnTimer = LacpClockGet().NewTimer(cscdm.cdsChurnTimerInterval)
+	} else {
+		cscdm.cdsChurnTimer.Reset(cscdm.cdsChurnTimerInterval)
+	}
+	cscdm.cdsChurnTimerRunning = true
+}
+
+func (cscdm *LacpCsCdMachine) CDSChurnDetectionTimerStop() {
+	if cscdm.cdsChurnTimer != nil {
+		cscdm.cdsChurnTimer.Stop()
+		cscdm.cdsChurnTimerRunning = false
+	}
+}
+
+// MarkerResponseTimerStart used by the Marker Generator to wait for a
+// Marker Response 802.1ax-2014 Section 6.5.4.1
+func (mg *LampMarkerGeneratorMachine) MarkerResponseTimerStart() {
+	if mg.markerResponseTimer == nil {
+		mg.markerResponseTimer = LacpClockGet().NewTimer(mg.markerResponseTimerInterval)
+	} else {
+		mg.markerResponseTimer.Reset(mg.markerResponseTimerInterval)
+	}
+}
+
+func (mg *LampMarkerGeneratorMachine) MarkerResponseTimerStop() {
+	if mg.markerResponseTimer != nil {
+		mg.markerResponseTimer.Stop()
+	}
+}
+
 // TxGuardTimerStart used by Tx Machine as described in
 // 802.1ax-2014 Section 6.4.17 in order to not transmit
 // more than 3 packets in this interval
@@ -115,7 +183,7 @@ func (txm *LacpTxMachine) TxGuardTimerStart() {
 	//	txm.LacpTxmLog("Starting Guard Timer")
 	//}
 	if txm.txGuardTimer == nil {
-		txm.txGuardTimer = time.AfterFunc(LacpFastPeriodicTime, txm.LacpTxGuardGeneration)
+		txm.txGuardTimer = LacpClockGet().AfterFunc(LacpFastPeriodicTime, txm.LacpTxGuardGeneration)
 	} else {
 		txm.txGuardTimer.Reset(LacpFastPeriodicTime)
 	}
@@ -128,3 +196,43 @@ func (txm *LacpTxMachine) TxGuardTimerStop() {
 		txm.txGuardTimer.Stop()
 	}
 }
+
+// CurrentWhileTimerStart used by the DRCPDU Receive machine 802.1ax-2014
+// Section 9.4.14 to detect that the neighbor Portal System has gone away
+func (rxm *DrcpRxMachine) CurrentWhileTimerStart() {
+	if rxm.currentWhileTimer == nil {
+		rxm.currentWhileTimer = LacpClockGet().NewTimer(rxm.currentWhileTimerTimeout)
+	} else {
+		rxm.currentWhileTimer.Reset(rxm.currentWhileTimerTimeout)
+	}
+}
+
+func (rxm *DrcpRxMachine) CurrentWhileTimerStop() {
+	if rxm.currentWhileTimer != nil {
+		rxm.currentWhileTimer.Stop()
+	}
+}
+
+func (rxm *DrcpRxMachine) CurrentWhileTimerTimeoutSet(timeout time.Duration) {
+	rxm.currentWhileTimerTimeout = timeout
+}
+
+// PeriodicTimerStart used by the DRCP Periodic Transmission machine
+// 802.1ax-2014 Section 9.4.15
+func (ptxm *DrcpPtxMachine) PeriodicTimerStart() {
+	if ptxm.periodicTxTimer == nil {
+		ptxm.periodicTxTimer = LacpClockGet().NewTimer(ptxm.PeriodicTxTimerInterval)
+	} else {
+		ptxm.periodicTxTimer.Reset(ptxm.PeriodicTxTimerInterval)
+	}
+}
+
+func (ptxm *DrcpPtxMachine) PeriodicTimerStop() {
+	if ptxm.periodicTxTimer != nil {
+		ptxm.periodicTxTimer.Stop()
+	}
+}
+
+func (ptxm *DrcpPtxMachine) PeriodicTimerIntervalSet(interval time.Duration) {
+	ptxm.PeriodicTxTimerInterval = interval
+}
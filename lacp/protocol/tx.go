@@ -31,93 +31,91 @@ import (
 	"net"
 )
 
-// bridge will simulate communication between two channels
-type SimulationBridge struct {
-	port1       uint16
-	port2       uint16
-	rxLacpPort1 chan gopacket.Packet
-	rxLacpPort2 chan gopacket.Packet
-	rxLampPort1 chan gopacket.Packet
-	rxLampPort2 chan gopacket.Packet
-}
-
-func (bridge *SimulationBridge) TxViaGoChannel(port uint16, pdu interface{}) {
-
-	var p *LaAggPort
-	if LaFindPortById(port, &p) {
-
-		// Set up all the layers' fields we can.
-		eth := layers.Ethernet{
-			SrcMAC:       net.HardwareAddr{0x00, uint8(p.PortNum & 0xff), 0x00, 0x01, 0x01, 0x01},
-			DstMAC:       layers.SlowProtocolDMAC,
-			EthernetType: layers.EthernetTypeSlowProtocol,
-		}
+// LaAggPortTx will send a LACPDU or Marker PDU out the port interface, the
+// pdu is not counted as sent when the port has no packet handle
+func (p *LaAggPort) LaAggPortTx(pdu interface{}) bool {
+	if p.handle == nil {
+		p.LacpDebug.logger.Info(fmt.Sprintf("TX no packet handle for port %d %s", p.PortNum, p.IntfNum))
+		return false
+	}
 
-		slow := layers.SlowProtocol{
-			SubType: layers.SlowProtocolTypeLACP,
-		}
+	// interfaces of the memory backend are not known to the kernel
+	// so use the provisioned port mac
+	srcMac := p.macProperties.Mac
+	txIface, err := net.InterfaceByName(p.IntfNum)
+	if err == nil {
+		srcMac = txIface.HardwareAddr
+	}
 
-		lacp := pdu.(*layers.LACP)
+	// conver the packet to a go packet
+	// Set up all the layers' fields we can.
+	eth := layers.Ethernet{
+		SrcMAC:       srcMac,
+		DstMAC:       layers.SlowProtocolDMAC,
+		EthernetType: layers.EthernetTypeSlowProtocol,
+	}
 
-		// Set up buffer and options for serialization.
-		buf := gopacket.NewSerializeBuffer()
-		opts := gopacket.SerializeOptions{
-			FixLengths:       true,
-			ComputeChecksums: true,
-		}
+	slow := layers.SlowProtocol{
+		SubType: layers.SlowProtocolTypeLACP,
+	}
 
+	// Set up buffer and options for serialization.
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{
+		FixLengths:       true,
+		ComputeChecksums: true,
+	}
+	switch lacp := pdu.(type) {
+	case *layers.LACP:
 		gopacket.SerializeLayers(buf, opts, &eth, &slow, lacp)
-		pkt := gopacket.NewPacket(buf.Bytes(), layers.LinkTypeEthernet, gopacket.Default)
-
-		if port != bridge.port1 && bridge.rxLacpPort1 != nil {
-			//fmt.Println("TX channel: Tx From port", port, "bridge Port Rx", bridge.port1)
-			//fmt.Println("TX:", pkt)
-			bridge.rxLacpPort1 <- pkt
-		} else if bridge.rxLacpPort2 != nil {
-			//fmt.Println("TX channel: Tx From port", port, "bridge Port Rx", bridge.port2)
-			bridge.rxLacpPort2 <- pkt
-		}
-	} else {
-		fmt.Println("Unable to find port in tx")
+	case *LacpV2Pdu:
+		// gopacket does not know about the Version 2 TLV's
+		gopacket.SerializeLayers(buf, opts, &eth, &slow, gopacket.Payload(LacpV2PduEncode(lacp)))
+	case *layers.LAMP:
+		slow.SubType = layers.SlowProtocolTypeLAMP
+		gopacket.SerializeLayers(buf, opts, &eth, &slow, lacp)
+	case *LaMarkerPdu:
+		slow.SubType = layers.SlowProtocolTypeLAMP
+		gopacket.SerializeLayers(buf, opts, &eth, &slow, gopacket.Payload(LampMarkerPduEncode(lacp)))
+	default:
+		p.LacpDebug.logger.Info(fmt.Sprintf("TX unknown pdu %T on port %d", pdu, p.PortNum))
+		return false
+	}
+	if err := p.handle.WritePacketData(buf.Bytes()); err != nil {
+		p.LacpDebug.logger.Info(fmt.Sprintf("%s\n", err))
+		return false
 	}
+	return true
 }
 
-func TxViaLinuxIf(port uint16, pdu interface{}) {
-	var p *LaAggPort
-	if LaFindPortById(port, &p) {
-
-		txIface, err := net.InterfaceByName(p.IntfNum)
-
-		if err == nil {
-			// conver the packet to a go packet
-			// Set up all the layers' fields we can.
-			eth := layers.Ethernet{
-				SrcMAC:       txIface.HardwareAddr,
-				DstMAC:       layers.SlowProtocolDMAC,
-				EthernetType: layers.EthernetTypeSlowProtocol,
-			}
+// DrcpIppTx will send a DRCPDU out the Intra-Portal Port interface
+func (ipp *DRCPIpp) DrcpIppTx(drcp *DRCPDU) bool {
+	if ipp.handle == nil {
+		ipp.LacpDebug.logger.Info(fmt.Sprintf("TX no packet handle for ipp %d %s", ipp.Id, ipp.Intf))
+		return false
+	}
 
-			slow := layers.SlowProtocol{
-				SubType: layers.SlowProtocolTypeLACP,
-			}
+	srcMac := convertSysIdKeyToNetHwAddress(ipp.dr.DrniPortalAddr)
+	txIface, err := net.InterfaceByName(ipp.Intf)
+	if err == nil {
+		srcMac = txIface.HardwareAddr
+	}
 
-			lacp := pdu.(*layers.LACP)
+	eth := layers.Ethernet{
+		SrcMAC:       srcMac,
+		DstMAC:       DrcpNearestNonTPMRBridgeDMAC,
+		EthernetType: DrcpEthernetType,
+	}
 
-			// Set up buffer and options for serialization.
-			buf := gopacket.NewSerializeBuffer()
-			opts := gopacket.SerializeOptions{
-				FixLengths:       true,
-				ComputeChecksums: true,
-			}
-			// Send one packet for every address.
-			gopacket.SerializeLayers(buf, opts, &eth, &slow, lacp)
-			if err := p.handle.WritePacketData(buf.Bytes()); err != nil {
-				p.LacpDebug.logger.Info(fmt.Sprintf("%s\n", err))
-			}
-		} else {
-			fmt.Println("ERROR could not find interface", p.IntfNum, err)
-		}
-	} else {
-		fmt.Println("Unable to find port", port)
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{
+		FixLengths:       true,
+		ComputeChecksums: true,
+	}
+	gopacket.SerializeLayers(buf, opts, &eth, gopacket.Payload(DRCPDUEncode(drcp)))
+	if err := ipp.handle.WritePacketData(buf.Bytes()); err != nil {
+		ipp.LacpDebug.logger.Info(fmt.Sprintf("%s\n", err))
+		return false
 	}
+	return true
 }
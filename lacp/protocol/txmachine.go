@@ -29,9 +29,9 @@ package lacp
 import (
 	"fmt"
 	"github.com/google/gopacket/layers"
+	"l2/clock"
 	"strconv"
 	"strings"
-	"time"
 	"utils/fsm"
 )
 
@@ -92,7 +92,7 @@ type LacpTxMachine struct {
 	log chan string
 
 	// timer needed for 802.1ax-20014 section 6.4.16
-	txGuardTimer *time.Timer
+	txGuardTimer *clock.Timer
 
 	// machine specific events
 	TxmEvents          chan LacpMachineEvent
@@ -212,16 +212,27 @@ func (txm *LacpTxMachine) LacpTxMachineOn(m fsm.Machine, data interface{}) fsm.S
 				},
 			}
 
-			// transmit the packet
-			for _, ftx := range LaSysGlobalTxCallbackListGet(p) {
-				//txm.LacpTxmLog(fmt.Sprintf("Sending Tx packet port %d pkts %d", p.PortNum, txm.txPkts))
-				ftx(p.PortNum, lacp)
-				p.LacpCounter.AggPortStatsLACPDUsTx += 1
-			}
 			// Version 2 consideration if enable_long_pdu_xmit and
 			// LongLACPPDUTransmit are True:
 			// LACPDU will be a Long LACPDU formatted by 802.1ax-2014 Section
 			// 6.4.2 and including Port Conversation Mask TLV 6.4.2.4.3
+			// If the partner has reported Version 1 then negotiate down
+			// and only send Version 1 LACPDU's
+			var pdu interface{} = lacp
+			if p.actorVersion >= LacpVersion2 &&
+				p.partnerVersion != LacpVersion1 {
+				lacp.Version = layers.LACPVersion2
+				pdu = &LacpV2Pdu{
+					Lacp: lacp,
+					V2:   p.LacpV2InfoGet(p.enableLongPduXmit),
+				}
+			}
+
+			// transmit the packet
+			//txm.LacpTxmLog(fmt.Sprintf("Sending Tx packet port %d pkts %d", p.PortNum, txm.txPkts))
+			if p.LaAggPortTx(pdu) {
+				p.LacpCounter.AggPortStatsLACPDUsTx += 1
+			}
 			txm.ntt = false
 
 			// lets force another transmit
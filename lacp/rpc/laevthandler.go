@@ -47,6 +47,10 @@ func processLinkDownEvent(linkId int) {
 		p.LinkOperStatus = false
 		//}
 	}
+	var ipp *lacp.DRCPIpp
+	if lacp.DrFindIppById(uint16(linkId), &ipp) {
+		ipp.DrcpIppDisabled()
+	}
 }
 
 func processLinkUpEvent(linkId int) {
@@ -57,6 +61,10 @@ func processLinkUpEvent(linkId int) {
 		p.LinkOperStatus = true
 		//}
 	}
+	var ipp *lacp.DRCPIpp
+	if lacp.DrFindIppById(uint16(linkId), &ipp) {
+		ipp.DrcpIppEnabled()
+	}
 }
 
 func processAsicdEvents(sub *nanomsg.SubSocket) {
@@ -129,6 +137,37 @@ func setupEventHandler(sub *nanomsg.SubSocket, address string, subtype int) {
 	processEvents(sub, subtype)
 }
 
+// processLinkNameEvent link events from the hw plugin identify the link by
+// name and are reported on any link change, so only state changes are acted on
+func processLinkNameEvent(intf string, up bool) {
+	var p *lacp.LaAggPort
+	var ipp *lacp.DRCPIpp
+	if lacp.LaFindPortByName(intf, &p) &&
+		p.LinkOperStatus != up {
+		fmt.Printf("Msg linkstatus = %t msg port = %s\n", up, intf)
+		if up {
+			processLinkUpEvent(int(p.PortNum))
+		} else {
+			processLinkDownEvent(int(p.PortNum))
+		}
+	} else if lacp.DrFindIppByName(intf, &ipp) &&
+		ipp.IppPortEnabled != up {
+		if up {
+			processLinkUpEvent(int(ipp.Id))
+		} else {
+			processLinkDownEvent(int(ipp.Id))
+		}
+	}
+}
+
 func startEvtHandler() {
-	go setupEventHandler(AsicdSub, asicdCommonDefs.PUB_SOCKET_ADDR, SUB_ASICD)
+	hw := lacp.LacpHwPluginGet()
+	if hw.Name() == lacp.LagHwPluginAsicd {
+		go setupEventHandler(AsicdSub, asicdCommonDefs.PUB_SOCKET_ADDR, SUB_ASICD)
+	} else if le, ok := hw.(lacp.LagHwLinkEvents); ok {
+		err := le.LinkEventsSubscribe(processLinkNameEvent, make(chan struct{}))
+		if err != nil {
+			fmt.Println("Failed to subscribe to", hw.Name(), "link events", err)
+		}
+	}
 }
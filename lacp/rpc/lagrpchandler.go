@@ -0,0 +1,226 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// lagrpchandler.go
+package rpc
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/net/context"
+	"l2/grpcapi"
+	"lacpd"
+	"lacpdpb"
+)
+
+// LACPDGrpcHandler implements the grpc service by converting the grpc messages
+// to/from the thrift objects and calling the thrift service handler
+type LACPDGrpcHandler struct {
+	la *LACPDServiceHandler
+}
+
+func NewLACPDGrpcHandler(la *LACPDServiceHandler) *LACPDGrpcHandler {
+	return &LACPDGrpcHandler{la: la}
+}
+
+// laConfigs holds the config objects applied by the thrift handlers, an
+// update only carries the attributes being updated and is applied on top
+// of the current object
+var laConfigs = grpcapi.NewConfigCache()
+
+func laPortChannelKey(config *lacpd.LaPortChannel) string {
+	return fmt.Sprintf("LaPortChannel-%d", config.LagId)
+}
+
+func distributedRelayKey(config *lacpd.DistributedRelay) string {
+	return fmt.Sprintf("DistributedRelay-%s", config.DrniName)
+}
+
+func (g *LACPDGrpcHandler) CreateLaPortChannel(ctx context.Context, in *lacpdpb.LaPortChannel) (*lacpdpb.Result, error) {
+	config := lacpd.NewLaPortChannel()
+	if err := grpcapi.Convert(in, config); err != nil {
+		return nil, err
+	}
+	ok, err := g.la.CreateLaPortChannel(config)
+	return &lacpdpb.Result{Ok: ok}, err
+}
+
+func (g *LACPDGrpcHandler) UpdateLaPortChannel(ctx context.Context, in *lacpdpb.LaPortChannelUpdate) (*lacpdpb.Result, error) {
+	config := lacpd.NewLaPortChannel()
+	if err := grpcapi.Convert(in.Obj, config); err != nil {
+		return nil, err
+	}
+	attrset, err := grpcapi.AttrSet(config, in.Attrs)
+	if err != nil {
+		return nil, err
+	}
+	// the key attributes select the current object, only the requested
+	// attributes are taken from the update
+	obj, exists := laConfigs.Get(laPortChannelKey(config))
+	if !exists {
+		return nil, errors.New(fmt.Sprintf("LaPortChannel %d not found", config.LagId))
+	}
+	origconfig := obj.(*lacpd.LaPortChannel)
+	if err = grpcapi.Overlay(config, origconfig, attrset); err != nil {
+		return nil, err
+	}
+	ok, err := g.la.UpdateLaPortChannel(origconfig, config, attrset, nil)
+	return &lacpdpb.Result{Ok: ok}, err
+}
+
+func (g *LACPDGrpcHandler) DeleteLaPortChannel(ctx context.Context, in *lacpdpb.LaPortChannel) (*lacpdpb.Result, error) {
+	config := lacpd.NewLaPortChannel()
+	if err := grpcapi.Convert(in, config); err != nil {
+		return nil, err
+	}
+	ok, err := g.la.DeleteLaPortChannel(config)
+	return &lacpdpb.Result{Ok: ok}, err
+}
+
+func (g *LACPDGrpcHandler) CreateDistributedRelay(ctx context.Context, in *lacpdpb.DistributedRelay) (*lacpdpb.Result, error) {
+	config := lacpd.NewDistributedRelay()
+	if err := grpcapi.Convert(in, config); err != nil {
+		return nil, err
+	}
+	ok, err := g.la.CreateDistributedRelay(config)
+	return &lacpdpb.Result{Ok: ok}, err
+}
+
+func (g *LACPDGrpcHandler) UpdateDistributedRelay(ctx context.Context, in *lacpdpb.DistributedRelayUpdate) (*lacpdpb.Result, error) {
+	config := lacpd.NewDistributedRelay()
+	if err := grpcapi.Convert(in.Obj, config); err != nil {
+		return nil, err
+	}
+	attrset, err := grpcapi.AttrSet(config, in.Attrs)
+	if err != nil {
+		return nil, err
+	}
+	// the key attributes select the current object, only the requested
+	// attributes are taken from the update
+	obj, exists := laConfigs.Get(distributedRelayKey(config))
+	if !exists {
+		return nil, errors.New(fmt.Sprintf("DistributedRelay %s not found", config.DrniName))
+	}
+	origconfig := obj.(*lacpd.DistributedRelay)
+	if err = grpcapi.Overlay(config, origconfig, attrset); err != nil {
+		return nil, err
+	}
+	ok, err := g.la.UpdateDistributedRelay(origconfig, config, attrset, nil)
+	return &lacpdpb.Result{Ok: ok}, err
+}
+
+func (g *LACPDGrpcHandler) DeleteDistributedRelay(ctx context.Context, in *lacpdpb.DistributedRelay) (*lacpdpb.Result, error) {
+	config := lacpd.NewDistributedRelay()
+	if err := grpcapi.Convert(in, config); err != nil {
+		return nil, err
+	}
+	ok, err := g.la.DeleteDistributedRelay(config)
+	return &lacpdpb.Result{Ok: ok}, err
+}
+
+func (g *LACPDGrpcHandler) GetLaPortChannelState(ctx context.Context, in *lacpdpb.LaPortChannelState) (*lacpdpb.LaPortChannelState, error) {
+	obj, err := g.la.GetLaPortChannelState(in.LagId)
+	if err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		return nil, errors.New(fmt.Sprintf("LaPortChannelState %d not found", in.LagId))
+	}
+	out := &lacpdpb.LaPortChannelState{}
+	err = grpcapi.Convert(obj, out)
+	return out, err
+}
+
+func (g *LACPDGrpcHandler) GetBulkLaPortChannelState(ctx context.Context, in *lacpdpb.BulkRequest) (*lacpdpb.LaPortChannelStateGetInfo, error) {
+	obj, err := g.la.GetBulkLaPortChannelState(lacpd.Int(in.FromIndex), lacpd.Int(in.Count))
+	if err != nil {
+		return nil, err
+	}
+	out := &lacpdpb.LaPortChannelStateGetInfo{}
+	err = grpcapi.Convert(obj, out)
+	return out, err
+}
+
+func (g *LACPDGrpcHandler) GetLaPortChannelMemberState(ctx context.Context, in *lacpdpb.LaPortChannelMemberState) (*lacpdpb.LaPortChannelMemberState, error) {
+	obj, err := g.la.GetLaPortChannelMemberState(in.IfIndex)
+	if err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		return nil, errors.New(fmt.Sprintf("LaPortChannelMemberState %d not found", in.IfIndex))
+	}
+	out := &lacpdpb.LaPortChannelMemberState{}
+	err = grpcapi.Convert(obj, out)
+	return out, err
+}
+
+func (g *LACPDGrpcHandler) GetBulkLaPortChannelMemberState(ctx context.Context, in *lacpdpb.BulkRequest) (*lacpdpb.LaPortChannelMemberStateGetInfo, error) {
+	obj, err := g.la.GetBulkLaPortChannelMemberState(lacpd.Int(in.FromIndex), lacpd.Int(in.Count))
+	if err != nil {
+		return nil, err
+	}
+	out := &lacpdpb.LaPortChannelMemberStateGetInfo{}
+	err = grpcapi.Convert(obj, out)
+	return out, err
+}
+
+func (g *LACPDGrpcHandler) GetDistributedRelayState(ctx context.Context, in *lacpdpb.DistributedRelayState) (*lacpdpb.DistributedRelayState, error) {
+	obj, err := g.la.GetDistributedRelayState(in.DrniName)
+	if err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		return nil, errors.New(fmt.Sprintf("DistributedRelayState %s not found", in.DrniName))
+	}
+	out := &lacpdpb.DistributedRelayState{}
+	err = grpcapi.Convert(obj, out)
+	return out, err
+}
+
+func (g *LACPDGrpcHandler) GetBulkDistributedRelayState(ctx context.Context, in *lacpdpb.BulkRequest) (*lacpdpb.DistributedRelayStateGetInfo, error) {
+	obj, err := g.la.GetBulkDistributedRelayState(lacpd.Int(in.FromIndex), lacpd.Int(in.Count))
+	if err != nil {
+		return nil, err
+	}
+	out := &lacpdpb.DistributedRelayStateGetInfo{}
+	err = grpcapi.Convert(obj, out)
+	return out, err
+}
+
+// StartGrpcServer starts the grpc server and json/http gateway of lacpd
+// when the lacpd-grpc entry is present in clients.json
+func StartGrpcServer(la *LACPDServiceHandler, fileName string) error {
+	grpcPort, gwPort := grpcapi.ClientPortsGet(fileName, "lacpd")
+	if grpcPort == 0 {
+		return nil
+	}
+	s := grpcapi.NewServer("lacpd", grpcPort, gwPort)
+	lacpdpb.RegisterLACPDServicesServer(s.GrpcServer(), NewLACPDGrpcHandler(la))
+	err := s.Start(lacpdpb.RegisterLACPDServicesHandlerFromEndpoint, func(err error) {
+		fmt.Println(err)
+	})
+	if err == nil {
+		fmt.Println("Started LACP grpc server on", s.GrpcAddr, "gateway on", s.GatewayAddr)
+	}
+	return err
+}
@@ -31,7 +31,7 @@ import (
 	lacp "l2/lacp/protocol"
 	"lacpd"
 	"models"
-	//"net"
+	"net"
 	"reflect"
 	"strconv"
 	"strings"
@@ -48,6 +48,8 @@ func NewLACPDServiceHandler() *LACPDServiceHandler {
 	lacp.LacpStartTime = time.Now()
 	// link up/down events for now
 	startEvtHandler()
+	// aggregator up/down and member notifications
+	startNotificationPublisher()
 	return &LACPDServiceHandler{}
 }
 
@@ -240,6 +242,65 @@ func ConvertCdmMachineStateToYangState(state int) int32 {
 	return yangstate
 }
 
+// ConvertCsCdmMachineStateToYangState will convert the Conversation-sensitive
+// Collection and Distribution machine state to churn/no churn
+func ConvertCsCdmMachineStateToYangState(state int, churnState int) int32 {
+	var yangstate int32
+	if state == churnState {
+		yangstate = 1
+	}
+	return yangstate
+}
+
+// ConvertModelLacpVersionToLaAggVersion defaults to version 1
+func ConvertModelLacpVersionToLaAggVersion(yangVersion int32) uint8 {
+	if yangVersion >= int32(lacp.LacpVersion2) {
+		return lacp.LacpVersion2
+	}
+	return lacp.LacpVersion1
+}
+
+// ConvertModelFallbackTimeoutToLaAggFallbackTimeout will convert the
+// fallback timeout in seconds, 0 selects the default timeout
+func ConvertModelFallbackTimeoutToLaAggFallbackTimeout(yangTimeout int32) time.Duration {
+	if yangTimeout <= 0 {
+		return lacp.LacpFallbackDefaultTimeout
+	}
+	return time.Duration(yangTimeout) * time.Second
+}
+
+// ConvertModelConversationAdminLinkToLaAgg will convert a list of
+// "<conversation id>:<link number id>[,<link number id>...]"
+// into a map of conversation id to prioritized Link Number ID's
+func ConvertModelConversationAdminLinkToLaAgg(adminLinks []string) map[uint16][]uint16 {
+	convAdminLink := make(map[uint16][]uint16)
+	for _, entry := range adminLinks {
+		fields := strings.Split(entry, ":")
+		if len(fields) != 2 {
+			fmt.Println("LACP: Invalid Conversation Admin Link", entry)
+			continue
+		}
+		cid, err := strconv.Atoi(strings.TrimSpace(fields[0]))
+		if err != nil || cid < 0 || cid >= lacp.LacpMaxConversationIds {
+			fmt.Println("LACP: Invalid Conversation Admin Link conversation id", entry)
+			continue
+		}
+		links := make([]uint16, 0)
+		for _, l := range strings.Split(fields[1], ",") {
+			link, err := strconv.Atoi(strings.TrimSpace(l))
+			if err != nil || link <= 0 || link > 0xffff {
+				fmt.Println("LACP: Invalid Conversation Admin Link link number id", entry)
+				continue
+			}
+			links = append(links, uint16(link))
+		}
+		if len(links) > 0 {
+			convAdminLink[uint16(cid)] = links
+		}
+	}
+	return convAdminLink
+}
+
 var gAggKeyMap map[string]uint16
 var gAggKeyVal uint16
 var gAggKeyFreeList []uint16
@@ -370,6 +431,7 @@ func (la LACPDServiceHandler) CreateLaPortChannel(config *lacpd.LaPortChannel) (
 			// Type of LAG STATIC or LACP
 			Type:     ConvertModelLagTypeToLaAggType(config.LagType),
 			MinLinks: uint16(config.MinLinks),
+			MaxLinks: uint16(config.MaxLinks),
 			Enabled:  ConvertAdminStateStringToBool(config.AdminState),
 			// lacp config
 			Lacp: lacp.LacpConfigInfo{
@@ -382,7 +444,13 @@ func (la LACPDServiceHandler) CreateLaPortChannel(config *lacpd.LaPortChannel) (
 			//	Mtu: int(config.Mtu),
 			//},
 			HashMode: uint32(config.LagHash),
+			// version 2
+			Version:                       ConvertModelLacpVersionToLaAggVersion(config.LacpVersion),
+			ConversationAdminLink:         ConvertModelConversationAdminLinkToLaAgg(config.ConversationAdminLink),
+			AdminDiscardWrongConversation: config.DiscardWrongConversation,
 		}
+		conf.FallbackMode = uint32(config.FallbackMode)
+		conf.FallbackTimeout = ConvertModelFallbackTimeoutToLaAggFallbackTimeout(config.FallbackTimeout)
 		lacp.CreateLaAgg(conf)
 
 		var a *lacp.LaAggregator
@@ -417,6 +485,7 @@ func (la LACPDServiceHandler) CreateLaPortChannel(config *lacpd.LaPortChannel) (
 				)
 			}
 		}
+		laConfigs.Set(laPortChannelKey(config), config)
 	}
 	return true, nil
 }
@@ -427,6 +496,7 @@ func (la LACPDServiceHandler) DeleteLaPortChannel(config *lacpd.LaPortChannel) (
 
 	// Aggregation found now lets delete
 	lacp.DeleteLaAgg(GetIdByName(nameKey))
+	laConfigs.Delete(laPortChannelKey(config))
 	return true, nil
 }
 
@@ -489,6 +559,7 @@ func (la LACPDServiceHandler) UpdateLaPortChannel(origconfig *lacpd.LaPortChanne
 		// Type of LAG STATIC or LACP
 		Type:     ConvertModelLagTypeToLaAggType(updateconfig.LagType),
 		MinLinks: uint16(updateconfig.MinLinks),
+		MaxLinks: uint16(updateconfig.MaxLinks),
 		Enabled:  ConvertAdminStateStringToBool(updateconfig.AdminState),
 		// lacp config
 		Lacp: lacp.LacpConfigInfo{
@@ -498,7 +569,13 @@ func (la LACPDServiceHandler) UpdateLaPortChannel(origconfig *lacpd.LaPortChanne
 			SystemPriority: uint16(updateconfig.SystemPriority),
 		},
 		HashMode: uint32(updateconfig.LagHash),
+		// version 2
+		Version:                       ConvertModelLacpVersionToLaAggVersion(updateconfig.LacpVersion),
+		ConversationAdminLink:         ConvertModelConversationAdminLinkToLaAgg(updateconfig.ConversationAdminLink),
+		AdminDiscardWrongConversation: updateconfig.DiscardWrongConversation,
 	}
+	conf.FallbackMode = uint32(updateconfig.FallbackMode)
+	conf.FallbackTimeout = ConvertModelFallbackTimeoutToLaAggFallbackTimeout(updateconfig.FallbackTimeout)
 
 	// lets deal with Members attribute first
 	for i := 0; i < objTyp.NumField(); i++ {
@@ -563,6 +640,7 @@ func (la LACPDServiceHandler) UpdateLaPortChannel(origconfig *lacpd.LaPortChanne
 					DisableLaAgg(conf)
 					lacp.SaveLaAggConfig(conf)
 				}
+				laConfigs.Set(laPortChannelKey(updateconfig), updateconfig)
 				return true, nil
 
 			} else if objName == "LagType" {
@@ -586,6 +664,24 @@ func (la LACPDServiceHandler) UpdateLaPortChannel(origconfig *lacpd.LaPortChanne
 					break
 				// this may cause lag to go down if min ports is > actual ports
 				case "MinLinks":
+					SetLaAggMinLinks(conf)
+					break
+				// ports above max links are moved to standby
+				case "MaxLinks":
+					SetLaAggMaxLinks(conf)
+					break
+				// version 2 partner may negotiate down to version 1
+				case "LacpVersion":
+					SetLaAggVersion(conf)
+					break
+				case "ConversationAdminLink":
+					SetLaAggConversationAdminLink(conf)
+					break
+				case "DiscardWrongConversation":
+					SetLaAggDiscardWrongConversation(conf)
+					break
+				case "FallbackMode", "FallbackTimeout":
+					SetLaAggFallback(conf)
 					break
 				default:
 					// unhandled config
@@ -595,6 +691,7 @@ func (la LACPDServiceHandler) UpdateLaPortChannel(origconfig *lacpd.LaPortChanne
 			}
 		}
 	}
+	laConfigs.Set(laPortChannelKey(updateconfig), updateconfig)
 	return true, nil
 }
 
@@ -731,8 +828,38 @@ func SetLaAggSystemInfo(conf *lacp.LaAggConfig) error {
 	return nil
 }
 
+func SetLaAggVersion(conf *lacp.LaAggConfig) error {
+	lacp.SetLaAggVersion(conf.Id, conf.Version)
+	return nil
+}
+
+func SetLaAggConversationAdminLink(conf *lacp.LaAggConfig) error {
+	lacp.SetLaAggConversationAdminLink(conf.Id, conf.ConversationAdminLink)
+	return nil
+}
+
+func SetLaAggDiscardWrongConversation(conf *lacp.LaAggConfig) error {
+	lacp.SetLaAggDiscardWrongConversation(conf.Id, conf.AdminDiscardWrongConversation)
+	return nil
+}
+
+func SetLaAggMinLinks(conf *lacp.LaAggConfig) error {
+	lacp.SetLaAggMinLinks(conf.Id, conf.MinLinks)
+	return nil
+}
+
+func SetLaAggMaxLinks(conf *lacp.LaAggConfig) error {
+	lacp.SetLaAggMaxLinks(conf.Id, conf.MaxLinks)
+	return nil
+}
+
+func SetLaAggFallback(conf *lacp.LaAggConfig) error {
+	lacp.SetLaAggFallback(conf.Id, conf.FallbackMode, conf.FallbackTimeout)
+	return nil
+}
+
 // SetPortLacpLogEnable will enable on a per port basis logging
-// modStr - PORT, RXM, TXM, PTXM, TXM, CDM, ALL
+// modStr - PORT, RXM, TXM, PTXM, TXM, CDM, CSCDM, MARKER, ALL
 // modStr can be a string containing one or more of the above
 func (la LACPDServiceHandler) SetPortLacpLogEnable(Id lacpd.Uint16, modStr string, ena bool) (lacpd.Int, error) {
 	modules := make(map[string]chan bool)
@@ -744,6 +871,8 @@ func (la LACPDServiceHandler) SetPortLacpLogEnable(Id lacpd.Uint16, modStr strin
 		modules["TXM"] = p.TxMachineFsm.TxmLogEnableEvent
 		modules["CDM"] = p.CdMachineFsm.CdmLogEnableEvent
 		modules["MUXM"] = p.MuxMachineFsm.MuxmLogEnableEvent
+		modules["CSCDM"] = p.CsCdMachineFsm.CsCdmLogEnableEvent
+		modules["MARKER"] = p.MarkerGeneratorFsm.LampMarkerGeneratorLogEnableEvent
 
 		for k, v := range modules {
 			if strings.Contains(k, "PORT") || strings.Contains(k, "ALL") {
@@ -775,11 +904,15 @@ func (la LACPDServiceHandler) GetPortChannelState(portChannel *lacpd.LaPortChann
 			pcs.OperState = "UP"
 		}
 		pcs.MinLinks = int16(a.AggMinLinks)
+		pcs.MaxLinks = int16(a.AggMaxLinks)
 		pcs.Interval = ConvertLaAggIntervalToLacpPeriod(a.Config.Interval)
 		pcs.LacpMode = ConvertLaAggModeToModelLacpMode(a.Config.Mode)
 		pcs.SystemIdMac = a.Config.SystemIdMac
 		pcs.SystemPriority = int16(a.Config.SystemPriority)
 		pcs.LagHash = int32(a.LagHash)
+		pcs.LacpVersion = int32(a.Version)
+		pcs.FallbackMode = int32(a.FallbackMode)
+		pcs.FallbackTimeout = int32(a.FallbackTimeout / time.Second)
 		//pcs.Ifindex = int32(a.HwAggId)
 		for _, m := range a.PortNumList {
 			pcs.Members = append(pcs.Members, int32(m))
@@ -835,11 +968,15 @@ func (la LACPDServiceHandler) GetBulkLaPortChannelState(fromIndex lacpd.Int, cou
 				nextLagState.OperState = "UP"
 			}
 			nextLagState.MinLinks = int16(a.AggMinLinks)
+			nextLagState.MaxLinks = int16(a.AggMaxLinks)
 			nextLagState.Interval = ConvertLaAggIntervalToLacpPeriod(a.Config.Interval)
 			nextLagState.LacpMode = ConvertLaAggModeToModelLacpMode(a.Config.Mode)
 			nextLagState.SystemIdMac = a.Config.SystemIdMac
 			nextLagState.SystemPriority = int16(a.Config.SystemPriority)
 			nextLagState.LagHash = int32(a.LagHash)
+			nextLagState.LacpVersion = int32(a.Version)
+			nextLagState.FallbackMode = int32(a.FallbackMode)
+			nextLagState.FallbackTimeout = int32(a.FallbackTimeout / time.Second)
 			//nextLagState.Ifindex = int32(a.HwAggId)
 			for _, m := range a.PortNumList {
 				nextLagState.Members = append(nextLagState.Members, int32(m))
@@ -884,6 +1021,8 @@ func (la LACPDServiceHandler) GetPortChannelMemberState(portchannelmemberstate *
 		pcms.Collecting = lacp.LacpStateIsSet(p.ActorOper.State, lacp.LacpStateCollectingBit)
 		pcms.Distributing = lacp.LacpStateIsSet(p.ActorOper.State, lacp.LacpStateDistributingBit)
 		pcms.Defaulted = lacp.LacpStateIsSet(p.ActorOper.State, lacp.LacpStateDefaultedBit)
+		pcms.Fallback = p.IsFallback()
+		pcms.Standby = p.IsStandby()
 
 		if pcms.Distributing {
 			pcms.OperState = "UP"
@@ -944,6 +1083,8 @@ func (la LACPDServiceHandler) GetPortChannelMemberState(portchannelmemberstate *
 		pcms.LampInResponsePdu = int64(p.LacpCounter.AggPortStatsMarkerResponsePDUsRx)
 		pcms.LampOutPdu = int64(p.LacpCounter.AggPortStatsMarkerPDUsTx)
 		pcms.LampOutResponsePdu = int64(p.LacpCounter.AggPortStatsMarkerResponsePDUsTx)
+		pcms.LampResponseTimeouts = int64(p.LacpCounter.AggPortStatsMarkerResponseTimeouts)
+		pcms.LampUnexpectedResponsePdu = int64(p.LacpCounter.AggPortStatsMarkerResponseUnexpectedRx)
 
 		// debug
 		pcms.DebugId = int32(p.AggPortDebug.AggPortDebugInformationID)
@@ -959,8 +1100,13 @@ func (la LACPDServiceHandler) GetPortChannelMemberState(portchannelmemberstate *
 		pcms.PartnerSyncTransitionCount = int64(p.AggPortDebug.AggPortDebugPartnerSyncTransitionCount)
 		pcms.ActorChangeCount = int64(p.AggPortDebug.AggPortDebugActorChangeCount)
 		pcms.PartnerChangeCount = int64(p.AggPortDebug.AggPortDebugPartnerChangeCount)
-		pcms.ActorCdsChurnMachine = int32(p.AggPortDebug.AggPortDebugActorCDSChurnState)
-		pcms.PartnerCdsChurnMachine = int32(p.AggPortDebug.AggPortDebugPartnerCDSChurnState)
+		pcms.ActorCdsChurnMachine = ConvertCsCdmMachineStateToYangState(p.AggPortDebug.AggPortDebugActorCDSChurnState, lacp.LacpCsCdmStateActorCDSChurn)
+		pcms.PartnerCdsChurnMachine = ConvertCsCdmMachineStateToYangState(p.AggPortDebug.AggPortDebugPartnerCDSChurnState, lacp.LacpCsCdmStatePartnerCDSChurn)
+
+		// version 2
+		actorVersion, partnerVersion := p.VersionGet()
+		pcms.LacpVersion = int32(actorVersion)
+		pcms.PartnerLacpVersion = int32(partnerVersion)
 		pcms.ActorCdsChurnCount = int64(p.AggPortDebug.AggPortDebugActorCDSChurnCount)
 		pcms.PartnerCdsChurnCount = int64(p.AggPortDebug.AggPortDebugPartnerCDSChurnCount)
 	} else {
@@ -998,6 +1144,8 @@ func (la LACPDServiceHandler) GetBulkLaPortChannelMemberState(fromIndex lacpd.In
 			nextLagMemberState.Collecting = lacp.LacpStateIsSet(p.ActorOper.State, lacp.LacpStateCollectingBit)
 			nextLagMemberState.Distributing = lacp.LacpStateIsSet(p.ActorOper.State, lacp.LacpStateDistributingBit)
 			nextLagMemberState.Defaulted = lacp.LacpStateIsSet(p.ActorOper.State, lacp.LacpStateDefaultedBit)
+			nextLagMemberState.Fallback = p.IsFallback()
+			nextLagMemberState.Standby = p.IsStandby()
 
 			if nextLagMemberState.Distributing {
 				nextLagMemberState.OperState = "UP"
@@ -1058,6 +1206,8 @@ func (la LACPDServiceHandler) GetBulkLaPortChannelMemberState(fromIndex lacpd.In
 			nextLagMemberState.LampInResponsePdu = int64(p.LacpCounter.AggPortStatsMarkerResponsePDUsRx)
 			nextLagMemberState.LampOutPdu = int64(p.LacpCounter.AggPortStatsMarkerPDUsTx)
 			nextLagMemberState.LampOutResponsePdu = int64(p.LacpCounter.AggPortStatsMarkerResponsePDUsTx)
+			nextLagMemberState.LampResponseTimeouts = int64(p.LacpCounter.AggPortStatsMarkerResponseTimeouts)
+			nextLagMemberState.LampUnexpectedResponsePdu = int64(p.LacpCounter.AggPortStatsMarkerResponseUnexpectedRx)
 
 			// debug
 			nextLagMemberState.DebugId = int32(p.AggPortDebug.AggPortDebugInformationID)
@@ -1073,8 +1223,13 @@ func (la LACPDServiceHandler) GetBulkLaPortChannelMemberState(fromIndex lacpd.In
 			nextLagMemberState.PartnerSyncTransitionCount = int64(p.AggPortDebug.AggPortDebugPartnerSyncTransitionCount)
 			nextLagMemberState.ActorChangeCount = int64(p.AggPortDebug.AggPortDebugActorChangeCount)
 			nextLagMemberState.PartnerChangeCount = int64(p.AggPortDebug.AggPortDebugPartnerChangeCount)
-			nextLagMemberState.ActorCdsChurnMachine = int32(p.AggPortDebug.AggPortDebugActorCDSChurnState)
-			nextLagMemberState.PartnerCdsChurnMachine = int32(p.AggPortDebug.AggPortDebugPartnerCDSChurnState)
+			nextLagMemberState.ActorCdsChurnMachine = ConvertCsCdmMachineStateToYangState(p.AggPortDebug.AggPortDebugActorCDSChurnState, lacp.LacpCsCdmStateActorCDSChurn)
+			nextLagMemberState.PartnerCdsChurnMachine = ConvertCsCdmMachineStateToYangState(p.AggPortDebug.AggPortDebugPartnerCDSChurnState, lacp.LacpCsCdmStatePartnerCDSChurn)
+
+			// version 2
+			actorVersion, partnerVersion := p.VersionGet()
+			nextLagMemberState.LacpVersion = int32(actorVersion)
+			nextLagMemberState.PartnerLacpVersion = int32(partnerVersion)
 			nextLagMemberState.ActorCdsChurnCount = int64(p.AggPortDebug.AggPortDebugActorCDSChurnCount)
 			nextLagMemberState.PartnerCdsChurnCount = int64(p.AggPortDebug.AggPortDebugPartnerCDSChurnCount)
 
@@ -1101,3 +1256,161 @@ func (la LACPDServiceHandler) GetBulkLaPortChannelMemberState(fromIndex lacpd.In
 
 	return obj, nil
 }
+
+// ConvertModelConvAdminGatewayToDrni will convert a list of
+// "<conversation id>:<portal system number>[,<portal system number>]"
+// into a map of gateway conversation id to prioritized Portal System Numbers
+func ConvertModelConvAdminGatewayToDrni(adminGateways []string) map[uint16][]uint8 {
+	convAdminGateway := make(map[uint16][]uint8)
+	for _, entry := range adminGateways {
+		fields := strings.Split(entry, ":")
+		if len(fields) != 2 {
+			fmt.Println("DRNI: Invalid Conversation Admin Gateway", entry)
+			continue
+		}
+		cid, err := strconv.Atoi(strings.TrimSpace(fields[0]))
+		if err != nil || cid < 0 || cid >= lacp.LacpMaxConversationIds {
+			fmt.Println("DRNI: Invalid Conversation Admin Gateway conversation id", entry)
+			continue
+		}
+		systems := make([]uint8, 0)
+		for _, s := range strings.Split(fields[1], ",") {
+			sys, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil ||
+				sys < int(lacp.DrniPortalSystemNumberMin) ||
+				sys > int(lacp.DrniPortalSystemNumberMax) {
+				fmt.Println("DRNI: Invalid Conversation Admin Gateway portal system number", entry)
+				continue
+			}
+			systems = append(systems, uint8(sys))
+		}
+		if len(systems) > 0 {
+			convAdminGateway[uint16(cid)] = systems
+		}
+	}
+	return convAdminGateway
+}
+
+func (la LACPDServiceHandler) CreateDistributedRelay(config *lacpd.DistributedRelay) (bool, error) {
+
+	conf := &lacp.DistributedRelayConfig{
+		DrniName:               config.DrniName,
+		DrniPortalAddress:      config.PortalAddress,
+		DrniPortalPriority:     uint16(config.PortalPriority),
+		DrniPortalSystemNumber: uint8(config.PortalSystemNumber),
+		DrniAggregator:         int(config.LagId),
+		DrniIntraPortalPortId:  uint16(config.IntraPortalPortId),
+		DrniIntraPortalIntf:    config.IntraPortalLink,
+		DrniGatewayAlgorithm:   uint32(config.GatewayAlgorithm),
+		DrniConvAdminGateway:   ConvertModelConvAdminGatewayToDrni(config.ConvAdminGateway),
+	}
+	if err := lacp.CreateDistributedRelay(conf); err != nil {
+		return false, err
+	}
+	laConfigs.Set(distributedRelayKey(config), config)
+	return true, nil
+}
+
+func (la LACPDServiceHandler) DeleteDistributedRelay(config *lacpd.DistributedRelay) (bool, error) {
+
+	if err := lacp.DeleteDistributedRelay(config.DrniName); err != nil {
+		return false, err
+	}
+	laConfigs.Delete(distributedRelayKey(config))
+	return true, nil
+}
+
+// UpdateDistributedRelay will recreate the Distributed Relay as any change
+// to the Portal requires the Portal Systems to re-synchronize
+func (la LACPDServiceHandler) UpdateDistributedRelay(origconfig *lacpd.DistributedRelay, updateconfig *lacpd.DistributedRelay, attrset []bool, op []*lacpd.PatchOpInfo) (bool, error) {
+
+	if origconfig.DrniName != updateconfig.DrniName {
+		return false, errors.New(fmt.Sprintf("DRNI: Unable to change the name of Distributed Relay %s", origconfig.DrniName))
+	}
+	if ok, err := la.DeleteDistributedRelay(origconfig); !ok {
+		return ok, err
+	}
+	return la.CreateDistributedRelay(updateconfig)
+}
+
+func convertDistributedRelayToState(dr *lacp.DistributedRelay, drs *lacpd.DistributedRelayState) {
+	drs.DrniName = dr.DrniName
+	drs.PortalAddress = net.HardwareAddr(dr.DrniPortalAddr[:]).String()
+	drs.PortalPriority = int32(dr.DrniPortalPriority)
+	drs.PortalSystemNumber = int32(dr.DrniPortalSystemNumber)
+	drs.LagId = int32(dr.DrniAggregator)
+	drs.PortalSystemIsolated = dr.PSI
+	drs.OperAggregatorKey = int32(dr.DRFHomeOperAggregatorKey)
+	for cid := 0; cid < lacp.LacpMaxConversationIds; cid++ {
+		if dr.DrniGatewayConversationIsHome(uint16(cid)) {
+			drs.HomeGatewayConversations++
+		}
+		if dr.DrniPortConversationIsHome(uint16(cid)) {
+			drs.HomePortConversations++
+		}
+	}
+	if ipp := dr.Ipp; ipp != nil {
+		drs.IntraPortalLink = ipp.Intf
+		drs.IntraPortalPortId = int32(ipp.Id)
+		drs.NeighborPortalSystemNumber = int32(ipp.DRFNeighborPortalSystemNumber)
+		drs.NeighborValid = ipp.IsNeighborCurrent()
+		drs.DrcpState = int32(ipp.DRFHomeOperDRCPState)
+		drs.NeighborDrcpState = int32(ipp.DRFNeighborOperDRCPState)
+		drs.DRCPDUsRx = int64(ipp.DrcpCounter.DRCPDUsRx)
+		drs.DRCPDUsTx = int64(ipp.DrcpCounter.DRCPDUsTx)
+		drs.IllegalRx = int64(ipp.DrcpCounter.IllegalRx)
+		drs.DiscardRx = int64(ipp.DrcpCounter.DiscardRx)
+	}
+}
+
+func (la LACPDServiceHandler) GetDistributedRelayState(drniName string) (*lacpd.DistributedRelayState, error) {
+	drs := &lacpd.DistributedRelayState{}
+
+	var dr *lacp.DistributedRelay
+	if lacp.DrFindByName(drniName, &dr) {
+		convertDistributedRelayToState(dr, drs)
+	} else {
+		return drs, errors.New(fmt.Sprintf("DRNI: Unable to find Distributed Relay %s", drniName))
+	}
+	return drs, nil
+}
+
+func (la LACPDServiceHandler) GetBulkDistributedRelayState(fromIndex lacpd.Int, count lacpd.Int) (obj *lacpd.DistributedRelayStateGetInfo, err error) {
+
+	var drStateList []lacpd.DistributedRelayState = make([]lacpd.DistributedRelayState, count)
+	var returnDrStates []*lacpd.DistributedRelayState
+	var returnDrStateGetInfo lacpd.DistributedRelayStateGetInfo
+	var dr *lacp.DistributedRelay
+	validCount := lacpd.Int(0)
+	toIndex := fromIndex
+	obj = &returnDrStateGetInfo
+
+	for currIndex := lacpd.Int(0); validCount != count && lacp.DrGetNext(&dr); currIndex++ {
+
+		if currIndex < fromIndex {
+			continue
+		} else {
+			nextDrState := &drStateList[validCount]
+			convertDistributedRelayToState(dr, nextDrState)
+
+			if len(returnDrStates) == 0 {
+				returnDrStates = make([]*lacpd.DistributedRelayState, 0)
+			}
+			returnDrStates = append(returnDrStates, nextDrState)
+			validCount++
+			toIndex++
+		}
+	}
+	moreRoutes := false
+	if dr != nil {
+		moreRoutes = lacp.DrGetNext(&dr)
+	}
+
+	obj.DistributedRelayStateList = returnDrStates
+	obj.StartIdx = fromIndex
+	obj.EndIdx = toIndex + 1
+	obj.More = moreRoutes
+	obj.Count = validCount
+
+	return obj, nil
+}
@@ -0,0 +1,103 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// lanotifyhandler.go
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/op/go-nanomsg"
+	lacp "l2/lacp/protocol"
+)
+
+// address other daemons subscribe to for lacpd notifications
+const LACPD_PUB_SOCKET_ADDR = "ipc:///tmp/lacpd_all.ipc"
+
+// LacpdNotification is published for each notification, Msg is the json
+// encoded lacp.LacpNotifyAggMsg and MsgType is one of lacp.LacpNotify*
+type LacpdNotification struct {
+	MsgType uint8
+	Msg     []byte
+}
+
+var LacpdPub *nanomsg.PubSocket
+
+// notifications are queued so that the State machines never block
+// on the publisher socket
+var lacpdNotifyChan chan LacpdNotification
+
+func processNotification(msgType uint8, msg lacp.LacpNotifyAggMsg) {
+	msgBuf, err := json.Marshal(msg)
+	if err != nil {
+		fmt.Println("Error in marshalling notification msg", err)
+		return
+	}
+	select {
+	case lacpdNotifyChan <- LacpdNotification{MsgType: msgType, Msg: msgBuf}:
+	default:
+		fmt.Println("Notification queue full, dropping", lacp.LacpNotifyStrMap[msgType])
+	}
+}
+
+func publishNotifications(pub *nanomsg.PubSocket) {
+	for notification := range lacpdNotifyChan {
+		buf, err := json.Marshal(notification)
+		if err != nil {
+			fmt.Println("Error in marshalling notification", err)
+			continue
+		}
+		_, err = pub.Send(buf, nanomsg.DontWait)
+		if err != nil {
+			fmt.Println("Error in publishing notification", err)
+		}
+	}
+}
+
+func setupNotificationPublisher(address string) {
+	fmt.Println("Setting up notification publisher")
+	pub, err := nanomsg.NewPubSocket()
+	if err != nil {
+		fmt.Println("Failed to open pub socket")
+		return
+	}
+	ep, err := pub.Bind(address)
+	if err != nil {
+		fmt.Println("Failed to bind pub socket - ", ep)
+		return
+	}
+	fmt.Println("Bound to ", ep.Address)
+	err = pub.SetSendBuffer(1024 * 1024)
+	if err != nil {
+		fmt.Println("Failed to set send buffer size")
+		return
+	}
+	LacpdPub = pub
+	lacpdNotifyChan = make(chan LacpdNotification, 100)
+	lacp.LacpNotifyRegisterCallback("lacpd", processNotification)
+	go publishNotifications(pub)
+}
+
+func startNotificationPublisher() {
+	setupNotificationPublisher(LACPD_PUB_SOCKET_ADDR)
+}
@@ -28,6 +28,7 @@ import (
 	"l2/lldp/config"
 	"l2/lldp/server"
 	"strconv"
+	"strings"
 	"sync"
 )
 
@@ -64,38 +65,133 @@ func validateExistingIntfConfig(ifIndex int32) (bool, error) {
 	return exists, nil
 }
 
-func SendIntfConfig(ifIndex int32, enable bool) (bool, error) {
+func validateDisabledTLVs(disabledTLVs []string) (bool, error) {
+	for _, tlv := range disabledTLVs {
+		if !config.IsOptionalTLV(tlv) {
+			return false, errors.New("Invalid TLV " + tlv + ", only optional TLV's " +
+				strings.Join(config.OptionalTLVs, ", ") + " can be disabled")
+		}
+	}
+	return true, nil
+}
+
+/*  ELIN is a 10 to 25 digit number
+ */
+func validateMedLocationElin(elin string) (bool, error) {
+	if elin == "" {
+		return true, nil
+	}
+	if len(elin) < 10 || len(elin) > 25 {
+		return false, errors.New("Invalid ELIN " + elin + ", ELIN should be 10 to 25 digits")
+	}
+	for _, c := range elin {
+		if c < '0' || c > '9' {
+			return false, errors.New("Invalid ELIN " + elin + ", ELIN should be 10 to 25 digits")
+		}
+	}
+	return true, nil
+}
+
+func validateMedNetworkPolicy(policy *config.MedNetworkPolicy) (bool, error) {
+	if !config.IsMedApplication(policy.Application) {
+		return false, errors.New("Invalid application " + policy.Application)
+	}
+	if policy.VlanId < 0 || policy.VlanId > 4094 {
+		return false, errors.New("Invalid VlanId " + strconv.Itoa(int(policy.VlanId)) +
+			", VlanId should be 0 (priority tagged) to 4094")
+	}
+	if policy.Priority < 0 || policy.Priority > 7 {
+		return false, errors.New("Invalid Priority " + strconv.Itoa(int(policy.Priority)) +
+			", Priority should be 0 to 7")
+	}
+	if policy.Dscp < 0 || policy.Dscp > 63 {
+		return false, errors.New("Invalid Dscp " + strconv.Itoa(int(policy.Dscp)) +
+			", Dscp should be 0 to 63")
+	}
+	return true, nil
+}
+
+func SendIntfConfig(ifIndex int32, enable bool, disabledTLVs []string,
+	medLocationElin string) (bool, error) {
 	// Validate ifIndex before sending the config to server
 	proceed, err := validateExistingIntfConfig(ifIndex)
 	if !proceed {
 		return proceed, err
 	}
-	lldpapi.server.IntfCfgCh <- &config.Intf{ifIndex, enable}
+	proceed, err = validateDisabledTLVs(disabledTLVs)
+	if !proceed {
+		return proceed, err
+	}
+	proceed, err = validateMedLocationElin(medLocationElin)
+	if !proceed {
+		return proceed, err
+	}
+	lldpapi.server.IntfCfgCh <- &config.Intf{ifIndex, enable, disabledTLVs, medLocationElin}
 	return proceed, err
 }
 
-func UpdateIntfConfig(ifIndex int32, enable bool) (bool, error) {
+func UpdateIntfConfig(ifIndex int32, enable bool, disabledTLVs []string,
+	medLocationElin string) (bool, error) {
 	proceed, err := validateExistingIntfConfig(ifIndex)
 	if !proceed {
 		return proceed, err
 	}
-	lldpapi.server.IntfCfgCh <- &config.Intf{ifIndex, enable}
+	proceed, err = validateDisabledTLVs(disabledTLVs)
+	if !proceed {
+		return proceed, err
+	}
+	proceed, err = validateMedLocationElin(medLocationElin)
+	if !proceed {
+		return proceed, err
+	}
+	lldpapi.server.IntfCfgCh <- &config.Intf{ifIndex, enable, disabledTLVs, medLocationElin}
 	return proceed, err
 }
 
-func SendGlobalConfig(vrf string, enable bool) (bool, error) {
+func SendMedNetworkPolicy(policy *config.MedNetworkPolicy) (bool, error) {
+	proceed, err := validateExistingIntfConfig(policy.IfIndex)
+	if !proceed {
+		return proceed, err
+	}
+	proceed, err = validateMedNetworkPolicy(policy)
+	if !proceed {
+		return proceed, err
+	}
+	lldpapi.server.MedPolicyCfgCh <- policy
+	return proceed, err
+}
+
+func DeleteMedNetworkPolicy(ifIndex int32, application string) (bool, error) {
+	proceed, err := validateExistingIntfConfig(ifIndex)
+	if !proceed {
+		return proceed, err
+	}
+	lldpapi.server.MedPolicyDelCh <- &config.MedNetworkPolicy{
+		IfIndex:     ifIndex,
+		Application: application,
+	}
+	return proceed, err
+}
+
+func SendGlobalConfig(vrf string, enable bool, disabledTLVs []string) (bool, error) {
 	if lldpapi.server.Global != nil {
 		return false, errors.New("Create/Delete on Global Object is not allowed, please do Update")
 	}
-	lldpapi.server.GblCfgCh <- &config.Global{vrf, enable}
+	if proceed, err := validateDisabledTLVs(disabledTLVs); !proceed {
+		return proceed, err
+	}
+	lldpapi.server.GblCfgCh <- &config.Global{vrf, enable, disabledTLVs}
 	return true, nil
 }
 
-func UpdateGlobalConfig(vrf string, enable bool) (bool, error) {
+func UpdateGlobalConfig(vrf string, enable bool, disabledTLVs []string) (bool, error) {
 	if lldpapi.server.Global == nil {
 		return false, errors.New("Update can only be performed if the global object for LLDP is created")
 	}
-	lldpapi.server.GblCfgCh <- &config.Global{vrf, enable}
+	if proceed, err := validateDisabledTLVs(disabledTLVs); !proceed {
+		return proceed, err
+	}
+	lldpapi.server.GblCfgCh <- &config.Global{vrf, enable, disabledTLVs}
 	return true, nil
 }
 
@@ -103,11 +199,51 @@ func SendPortStateChange(ifIndex int32, state string) {
 	lldpapi.server.IfStateCh <- &config.PortState{ifIndex, state}
 }
 
+func SendPortVlanChange(ifIndex int32, pvid int32, vlans []config.PortVlan) {
+	lldpapi.server.IfVlanCh <- &config.PortVlans{ifIndex, pvid, vlans}
+}
+
+func SendPortLagChange(ifIndex int32, enabled bool, aggId int32) {
+	lldpapi.server.IfLagCh <- &config.PortLag{ifIndex, enabled, aggId}
+}
+
+func GetIntfConfig(ifIndex int32) (config.Intf, bool) {
+	return lldpapi.server.GetIntfConfig(ifIndex)
+}
+
+func GetMedNetworkPolicy(ifIndex int32, application string) (config.MedNetworkPolicy, bool) {
+	return lldpapi.server.GetMedNetworkPolicy(ifIndex, application)
+}
+
+func GetGlobalConfig(vrf string) (config.Global, bool) {
+	return lldpapi.server.GetGlobalConfig(vrf)
+}
+
 func GetIntfStates(idx int, cnt int) (int, int, []config.IntfState) {
 	n, c, result := lldpapi.server.GetIntfStates(idx, cnt)
 	return n, c, result
 }
 
+func GetIntfStats(idx int, cnt int) (int, int, []config.IntfStats) {
+	return lldpapi.server.GetIntfStats(idx, cnt)
+}
+
+func GetIntfStat(ifIndex int32) (config.IntfStats, bool) {
+	return lldpapi.server.GetIntfStat(ifIndex)
+}
+
+func GetGlobalStats() config.GlobalStats {
+	return lldpapi.server.GetGlobalStats()
+}
+
+func ClearStats(ifIndex int32) (bool, error) {
+	err := lldpapi.server.ClearStats(ifIndex)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 func UpdateCache() {
 	lldpapi.server.UpdateCacheCh <- true
 }
@@ -23,14 +23,117 @@
 
 package config
 
+import (
+	"time"
+)
+
+// Optional TLVs which may be disabled globally or per interface
+const (
+	TLV_PORT_DESCRIPTION    = "PortDescription"
+	TLV_SYSTEM_NAME         = "SystemName"
+	TLV_SYSTEM_DESCRIPTION  = "SystemDescription"
+	TLV_SYSTEM_CAPABILITIES = "SystemCapabilities"
+	TLV_MANAGEMENT_ADDRESS  = "ManagementAddress"
+
+	// IEEE 802.1 organizationally specific tlv's
+	TLV_PORT_VLAN_ID          = "PortVlanId"
+	TLV_PORT_PROTOCOL_VLAN_ID = "PortProtocolVlanId"
+	TLV_VLAN_NAME             = "VlanName"
+	TLV_LINK_AGGREGATION      = "LinkAggregation"
+
+	// IEEE 802.3 organizationally specific tlv's
+	TLV_MAC_PHY_CONFIG_STATUS = "MacPhyConfigStatus"
+	TLV_POWER_VIA_MDI         = "PowerViaMdi"
+	TLV_MAX_FRAME_SIZE        = "MaxFrameSize"
+
+	// LLDP-MED tlv's, the others are not sent when MedCapabilities is
+	// disabled
+	TLV_MED_CAPABILITIES   = "MedCapabilities"
+	TLV_MED_NETWORK_POLICY = "MedNetworkPolicy"
+	TLV_MED_LOCATION       = "MedLocation"
+	TLV_MED_EXTENDED_POWER = "MedExtendedPower"
+	TLV_MED_INVENTORY      = "MedInventory"
+)
+
+var OptionalTLVs = []string{
+	TLV_PORT_DESCRIPTION,
+	TLV_SYSTEM_NAME,
+	TLV_SYSTEM_DESCRIPTION,
+	TLV_SYSTEM_CAPABILITIES,
+	TLV_MANAGEMENT_ADDRESS,
+	TLV_PORT_VLAN_ID,
+	TLV_PORT_PROTOCOL_VLAN_ID,
+	TLV_VLAN_NAME,
+	TLV_LINK_AGGREGATION,
+	TLV_MAC_PHY_CONFIG_STATUS,
+	TLV_POWER_VIA_MDI,
+	TLV_MAX_FRAME_SIZE,
+	TLV_MED_CAPABILITIES,
+	TLV_MED_NETWORK_POLICY,
+	TLV_MED_LOCATION,
+	TLV_MED_EXTENDED_POWER,
+	TLV_MED_INVENTORY,
+}
+
+// LLDP-MED network policy application types
+var MedApplications = map[string]uint8{
+	"Voice":               1,
+	"VoiceSignaling":      2,
+	"GuestVoice":          3,
+	"GuestVoiceSignaling": 4,
+	"SoftphoneVoice":      5,
+	"VideoConferencing":   6,
+	"StreamingVideo":      7,
+	"VideoSignaling":      8,
+}
+
+// System capabilities bits of the System Capabilities TLV
+const (
+	SYS_CAP_OTHER    = 1 << 0
+	SYS_CAP_REPEATER = 1 << 1
+	SYS_CAP_BRIDGE   = 1 << 2
+	SYS_CAP_WLAN_AP  = 1 << 3
+	SYS_CAP_ROUTER   = 1 << 4
+)
+
+type SystemCapabilities struct {
+	Supported uint16
+	Enabled   uint16
+}
+
 type Global struct {
-	Vrf    string
-	Enable bool
+	Vrf          string
+	Enable       bool
+	DisabledTLVs []string
 }
 
 type Intf struct {
-	IfIndex int32
-	Enable  bool
+	IfIndex      int32
+	Enable       bool
+	DisabledTLVs []string
+	// emergency location identification number sent to MED endpoints
+	MedLocationElin string
+}
+
+// MedNetworkPolicy is the vlan, priority and dscp an application of the
+// MED endpoints on the port should use, VlanId zero means priority tagged
+type MedNetworkPolicy struct {
+	IfIndex     int32
+	Application string
+	VlanId      int32
+	Tagged      bool
+	Priority    int32
+	Dscp        int32
+}
+
+type Inventory struct {
+	HardwareRevision string
+	FirmwareRevision string
+	SoftwareRevision string
+	SerialNumber     string
+	Manufacturer     string
+	ModelName        string
+	AssetId          string
 }
 
 type PortInfo struct {
@@ -39,6 +142,50 @@ type PortInfo struct {
 	OperState   string
 	MacAddr     string
 	Description string
+	// mac/phy, Speed is in Mbps
+	Speed      int32
+	FullDuplex bool
+	Autoneg    bool
+	Mtu        int32
+	// vlan membership, Pvid is the untagged vlan of the port
+	Pvid  int32
+	Vlans []PortVlan
+	// link aggregation membership learned from lacpd
+	Lag PortLag
+	// nil when the port does not supply power
+	Poe *PortPoe
+}
+
+type PortVlan struct {
+	VlanId int32
+	Name   string
+}
+
+type PortVlans struct {
+	IfIndex int32
+	Pvid    int32
+	Vlans   []PortVlan
+}
+
+type PortLag struct {
+	IfIndex int32
+	// the port is distributing in the aggregator AggId
+	Enabled bool
+	AggId   int32
+}
+
+// Power via MDI of a PSE port
+type PortPoe struct {
+	Enabled      bool
+	PairsControl bool
+	// 1 signal, 2 spare
+	PowerPair uint8
+	// power class 0 - 4
+	PowerClass uint8
+	// 1 critical, 2 high, 3 low
+	Priority uint8
+	// power available to the PD in 0.1 W
+	PowerValue uint16
 }
 
 type PortState struct {
@@ -49,9 +196,77 @@ type PortState struct {
 type IntfState struct {
 	IfIndex      int32
 	Enable       bool
+	RemIndex     int32
 	LocalPort    string
 	PeerMac      string
 	Port         string
 	HoldTime     string
 	Capabilities string
+	// IEEE 802.1 and 802.3 tlv's of the neighbor
+	PeerPortVlanId       int32
+	PeerProtocolVlanIds  []int32
+	PeerVlanNames        []string
+	PeerLagSupported     bool
+	PeerLagEnabled       bool
+	PeerLagPortId        int32
+	PeerAutonegSupported bool
+	PeerAutonegEnabled   bool
+	PeerMauType          int32
+	PeerPowerSupported   bool
+	PeerPowerEnabled     bool
+	PeerPowerClass       int32
+	PeerMaxFrameSize     int32
+	// set when the neighbor disagrees with the local port
+	PortVlanIdMismatch bool
+	LagMismatch        bool
+	// LLDP-MED, MedEnabled is set while MED tlv's are sent on the port
+	MedEnabled              bool
+	PeerMedDeviceType       string
+	PeerMedNetworkPolicies  []string
+	PeerMedLocation         string
+	PeerMedPowerPriority    int32
+	PeerMedPowerValue       int32
+	PeerMedHardwareRevision string
+	PeerMedFirmwareRevision string
+	PeerMedSoftwareRevision string
+	PeerMedSerialNumber     string
+	PeerMedManufacturer     string
+	PeerMedModelName        string
+	PeerMedAssetId          string
+}
+
+type IntfStats struct {
+	IfIndex               int32
+	LocalPort             string
+	FramesOutTotal        uint32
+	FramesInTotal         uint32
+	FramesInErrorsTotal   uint32
+	FramesDiscardedTotal  uint32
+	TLVsDiscardedTotal    uint32
+	TLVsUnrecognizedTotal uint32
+	AgeoutsTotal          uint32
+	TooManyNeighbors      bool
+}
+
+type GlobalStats struct {
+	Vrf                     string
+	RemTablesLastChangeTime time.Time
+	RemTablesInserts        uint32
+	RemTablesDeletes        uint32
+	RemTablesDrops          uint32
+	RemTablesAgeouts        uint32
+}
+
+func IsMedApplication(name string) bool {
+	_, exists := MedApplications[name]
+	return exists
+}
+
+func IsOptionalTLV(name string) bool {
+	for _, tlv := range OptionalTLVs {
+		if tlv == name {
+			return true
+		}
+	}
+	return false
 }
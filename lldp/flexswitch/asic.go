@@ -33,6 +33,7 @@ import (
 	"l2/lldp/api"
 	"l2/lldp/config"
 	"l2/lldp/utils"
+	"sort"
 	"strconv"
 	"time"
 	"utils/ipcutils"
@@ -41,8 +42,23 @@ import (
 type AsicPlugin struct {
 	asicdClient    *asicdServices.ASICDServicesClient
 	asicdSubSocket *nanomsg.SubSocket
+	// vlan membership of the ports, read during init and updated by the
+	// asicd listener only
+	vlans     map[int32]*vlanInfo
+	portNames map[string]int32
 }
 
+type vlanInfo struct {
+	name     string
+	tagged   map[int32]bool
+	untagged map[int32]bool
+}
+
+const (
+	ASICD_PORT_FULL_DUPLEX = "Full Duplex"
+	ASICD_PORT_AUTONEG_ON  = "ON"
+)
+
 func connectAsicd(filePath string, asicdClient chan *asicdServices.ASICDServicesClient) {
 	fileName := filePath + CLIENTS_FILE_NAME
 
@@ -91,6 +107,8 @@ func NewAsicPlugin(fileName string) (*AsicPlugin, error) {
 
 	mgr := &AsicPlugin{
 		asicdClient: asicdClient,
+		vlans:       make(map[int32]*vlanInfo),
+		portNames:   make(map[string]int32),
 	}
 	return mgr, nil
 
@@ -131,7 +149,12 @@ func (p *AsicPlugin) getPortStates() []*config.PortInfo {
 			} else {
 				port.MacAddr = pObj.MacAddr
 				port.Description = pObj.Description
+				port.Speed = pObj.Speed
+				port.FullDuplex = pObj.Duplex == ASICD_PORT_FULL_DUPLEX
+				port.Autoneg = pObj.Autoneg == ASICD_PORT_AUTONEG_ON
+				port.Mtu = pObj.Mtu
 			}
+			p.portNames[obj.Name] = obj.IfIndex
 			portStates = append(portStates, port)
 		}
 		if more == false {
@@ -142,11 +165,137 @@ func (p *AsicPlugin) getPortStates() []*config.PortInfo {
 	return portStates
 }
 
+/*  Helper function to get bulk vlan config and vlan names from asicd
+ */
+func (p *AsicPlugin) getVlans() {
+	currMarker := int64(0)
+	count := 10
+	for {
+		bulkInfo, err := p.asicdClient.GetBulkVlan(asicdServices.Int(currMarker),
+			asicdServices.Int(count))
+		if err != nil {
+			debug.Logger.Err(fmt.Sprintln("getting bulk vlan config from asicd",
+				"failed with reason", err))
+			break
+		}
+		currMarker = int64(bulkInfo.EndIdx)
+		for i := 0; i < int(bulkInfo.Count); i++ {
+			obj := bulkInfo.VlanList[i]
+			vlan := &vlanInfo{
+				tagged:   make(map[int32]bool),
+				untagged: make(map[int32]bool),
+			}
+			for _, name := range obj.IntfList {
+				if ifIndex, exists := p.portNames[name]; exists {
+					vlan.tagged[ifIndex] = true
+				}
+			}
+			for _, name := range obj.UntagIntfList {
+				if ifIndex, exists := p.portNames[name]; exists {
+					vlan.untagged[ifIndex] = true
+				}
+			}
+			p.vlans[obj.VlanId] = vlan
+		}
+		if bool(bulkInfo.More) == false {
+			break
+		}
+	}
+	currMarker = 0
+	for {
+		bulkInfo, err := p.asicdClient.GetBulkVlanState(asicdServices.Int(currMarker),
+			asicdServices.Int(count))
+		if err != nil {
+			debug.Logger.Err(fmt.Sprintln("getting bulk vlan state from asicd",
+				"failed with reason", err))
+			break
+		}
+		currMarker = int64(bulkInfo.EndIdx)
+		for i := 0; i < int(bulkInfo.Count); i++ {
+			obj := bulkInfo.VlanStateList[i]
+			if vlan, exists := p.vlans[obj.VlanId]; exists {
+				vlan.name = obj.VlanName
+			}
+		}
+		if bool(bulkInfo.More) == false {
+			break
+		}
+	}
+}
+
+/*  Vlans of the port ordered by vlan id, the pvid is the vlan in which the
+ *  port is untagged
+ */
+func (p *AsicPlugin) getPortVlans(ifIndex int32) (int32, []config.PortVlan) {
+	var pvid int32
+	vlanIds := make([]int, 0)
+	for vlanId, vlan := range p.vlans {
+		if vlan.tagged[ifIndex] || vlan.untagged[ifIndex] {
+			vlanIds = append(vlanIds, int(vlanId))
+		}
+	}
+	sort.Ints(vlanIds)
+	vlans := make([]config.PortVlan, 0, len(vlanIds))
+	for _, vlanId := range vlanIds {
+		vlan := p.vlans[int32(vlanId)]
+		if pvid == 0 && vlan.untagged[ifIndex] {
+			pvid = int32(vlanId)
+		}
+		vlans = append(vlans, config.PortVlan{
+			VlanId: int32(vlanId),
+			Name:   vlan.name,
+		})
+	}
+	return pvid, vlans
+}
+
 func (p *AsicPlugin) GetPortsInfo() []*config.PortInfo {
 	portStates := p.getPortStates()
+	p.getVlans()
+	for _, port := range portStates {
+		port.Pvid, port.Vlans = p.getPortVlans(port.IfIndex)
+	}
 	return portStates
 }
 
+/*  Update the vlan membership on vlan create/update/delete and inform the
+ *  server about the vlans of the ports which were or are members
+ */
+func (p *AsicPlugin) updateVlan(msgType uint8, msg asicdCommonDefs.VlanNotifyMsg) {
+	vlanId := int32(msg.VlanId)
+	affected := make(map[int32]bool)
+	if old, exists := p.vlans[vlanId]; exists {
+		for ifIndex, _ := range old.tagged {
+			affected[ifIndex] = true
+		}
+		for ifIndex, _ := range old.untagged {
+			affected[ifIndex] = true
+		}
+	}
+	if msgType == asicdCommonDefs.NOTIFY_VLAN_DELETE {
+		delete(p.vlans, vlanId)
+	} else {
+		vlan := &vlanInfo{
+			name:     msg.VlanName,
+			tagged:   make(map[int32]bool),
+			untagged: make(map[int32]bool),
+		}
+		for _, ifIndex := range msg.TagPorts {
+			vlan.tagged[ifIndex] = true
+			affected[ifIndex] = true
+		}
+		for _, ifIndex := range msg.UntagPorts {
+			vlan.untagged[ifIndex] = true
+			affected[ifIndex] = true
+		}
+		p.vlans[vlanId] = vlan
+	}
+	for ifIndex, _ := range affected {
+		pvid, vlans := p.getPortVlans(ifIndex)
+		api.SendPortVlanChange(ifIndex, pvid, vlans)
+	}
+}
+
 func (p *AsicPlugin) connectSubSocket() error {
 	var err error
 	address := asicdCommonDefs.PUB_SOCKET_ADDR
@@ -208,6 +357,16 @@ func (p *AsicPlugin) listenAsicdUpdates() {
 			} else {
 				api.SendPortStateChange(l2IntfStateNotifyMsg.IfIndex, "DOWN")
 			}
+		case asicdCommonDefs.NOTIFY_VLAN_CREATE, asicdCommonDefs.NOTIFY_VLAN_UPDATE,
+			asicdCommonDefs.NOTIFY_VLAN_DELETE:
+			var vlanNotifyMsg asicdCommonDefs.VlanNotifyMsg
+			err = json.Unmarshal(msg.Msg, &vlanNotifyMsg)
+			if err != nil {
+				debug.Logger.Err(fmt.Sprintln("Unable to Unmarshal vlan",
+					"notification:", msg.Msg))
+				continue
+			}
+			p.updateVlan(msg.MsgType, vlanNotifyMsg)
 		}
 	}
 
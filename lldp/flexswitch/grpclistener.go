@@ -0,0 +1,275 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// grpclistener.go
+package flexswitch
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/net/context"
+	"l2/grpcapi"
+	"l2/lldp/api"
+	"l2/lldp/utils"
+	"lldpd"
+	"lldpdpb"
+)
+
+// LLDPDGrpcHandler implements the grpc service by converting the grpc messages
+// to/from the thrift objects and calling the thrift service handler
+type LLDPDGrpcHandler struct {
+	h *ConfigHandler
+}
+
+func NewLLDPDGrpcHandler(h *ConfigHandler) *LLDPDGrpcHandler {
+	return &LLDPDGrpcHandler{h: h}
+}
+
+func (g *LLDPDGrpcHandler) CreateLLDPIntf(ctx context.Context, in *lldpdpb.LLDPIntf) (*lldpdpb.Result, error) {
+	config := lldpd.NewLLDPIntf()
+	if err := grpcapi.Convert(in, config); err != nil {
+		return nil, err
+	}
+	ok, err := g.h.CreateLLDPIntf(config)
+	return &lldpdpb.Result{Ok: ok}, err
+}
+
+func (g *LLDPDGrpcHandler) UpdateLLDPIntf(ctx context.Context, in *lldpdpb.LLDPIntfUpdate) (*lldpdpb.Result, error) {
+	config := lldpd.NewLLDPIntf()
+	if err := grpcapi.Convert(in.Obj, config); err != nil {
+		return nil, err
+	}
+	attrset, err := grpcapi.AttrSet(config, in.Attrs)
+	if err != nil {
+		return nil, err
+	}
+	// the key attributes select the current object, only the requested
+	// attributes are taken from the update
+	cur, exists := api.GetIntfConfig(config.IfIndex)
+	if !exists {
+		return nil, errors.New(fmt.Sprintf("LLDPIntf %d not found", config.IfIndex))
+	}
+	origconfig := lldpd.NewLLDPIntf()
+	if err = grpcapi.Convert(&cur, origconfig); err != nil {
+		return nil, err
+	}
+	if err = grpcapi.Overlay(config, origconfig, attrset); err != nil {
+		return nil, err
+	}
+	ok, err := g.h.UpdateLLDPIntf(origconfig, config, attrset, nil)
+	return &lldpdpb.Result{Ok: ok}, err
+}
+
+func (g *LLDPDGrpcHandler) DeleteLLDPIntf(ctx context.Context, in *lldpdpb.LLDPIntf) (*lldpdpb.Result, error) {
+	config := lldpd.NewLLDPIntf()
+	if err := grpcapi.Convert(in, config); err != nil {
+		return nil, err
+	}
+	ok, err := g.h.DeleteLLDPIntf(config)
+	return &lldpdpb.Result{Ok: ok}, err
+}
+
+func (g *LLDPDGrpcHandler) CreateLLDPGlobal(ctx context.Context, in *lldpdpb.LLDPGlobal) (*lldpdpb.Result, error) {
+	config := lldpd.NewLLDPGlobal()
+	if err := grpcapi.Convert(in, config); err != nil {
+		return nil, err
+	}
+	ok, err := g.h.CreateLLDPGlobal(config)
+	return &lldpdpb.Result{Ok: ok}, err
+}
+
+func (g *LLDPDGrpcHandler) UpdateLLDPGlobal(ctx context.Context, in *lldpdpb.LLDPGlobalUpdate) (*lldpdpb.Result, error) {
+	config := lldpd.NewLLDPGlobal()
+	if err := grpcapi.Convert(in.Obj, config); err != nil {
+		return nil, err
+	}
+	attrset, err := grpcapi.AttrSet(config, in.Attrs)
+	if err != nil {
+		return nil, err
+	}
+	// the key attributes select the current object, only the requested
+	// attributes are taken from the update
+	cur, exists := api.GetGlobalConfig(config.Vrf)
+	if !exists {
+		return nil, errors.New(fmt.Sprintf("LLDPGlobal %s not found", config.Vrf))
+	}
+	origconfig := lldpd.NewLLDPGlobal()
+	if err = grpcapi.Convert(&cur, origconfig); err != nil {
+		return nil, err
+	}
+	if err = grpcapi.Overlay(config, origconfig, attrset); err != nil {
+		return nil, err
+	}
+	ok, err := g.h.UpdateLLDPGlobal(origconfig, config, attrset, nil)
+	return &lldpdpb.Result{Ok: ok}, err
+}
+
+func (g *LLDPDGrpcHandler) DeleteLLDPGlobal(ctx context.Context, in *lldpdpb.LLDPGlobal) (*lldpdpb.Result, error) {
+	config := lldpd.NewLLDPGlobal()
+	if err := grpcapi.Convert(in, config); err != nil {
+		return nil, err
+	}
+	ok, err := g.h.DeleteLLDPGlobal(config)
+	return &lldpdpb.Result{Ok: ok}, err
+}
+
+func (g *LLDPDGrpcHandler) CreateLLDPMedNetworkPolicy(ctx context.Context, in *lldpdpb.LLDPMedNetworkPolicy) (*lldpdpb.Result, error) {
+	config := lldpd.NewLLDPMedNetworkPolicy()
+	if err := grpcapi.Convert(in, config); err != nil {
+		return nil, err
+	}
+	ok, err := g.h.CreateLLDPMedNetworkPolicy(config)
+	return &lldpdpb.Result{Ok: ok}, err
+}
+
+func (g *LLDPDGrpcHandler) UpdateLLDPMedNetworkPolicy(ctx context.Context, in *lldpdpb.LLDPMedNetworkPolicyUpdate) (*lldpdpb.Result, error) {
+	config := lldpd.NewLLDPMedNetworkPolicy()
+	if err := grpcapi.Convert(in.Obj, config); err != nil {
+		return nil, err
+	}
+	attrset, err := grpcapi.AttrSet(config, in.Attrs)
+	if err != nil {
+		return nil, err
+	}
+	// the key attributes select the current object, only the requested
+	// attributes are taken from the update
+	cur, exists := api.GetMedNetworkPolicy(config.IfIndex, config.Application)
+	if !exists {
+		return nil, errors.New(fmt.Sprintf("LLDPMedNetworkPolicy %d %s not found", config.IfIndex, config.Application))
+	}
+	origconfig := lldpd.NewLLDPMedNetworkPolicy()
+	if err = grpcapi.Convert(&cur, origconfig); err != nil {
+		return nil, err
+	}
+	if err = grpcapi.Overlay(config, origconfig, attrset); err != nil {
+		return nil, err
+	}
+	ok, err := g.h.UpdateLLDPMedNetworkPolicy(origconfig, config, attrset, nil)
+	return &lldpdpb.Result{Ok: ok}, err
+}
+
+func (g *LLDPDGrpcHandler) DeleteLLDPMedNetworkPolicy(ctx context.Context, in *lldpdpb.LLDPMedNetworkPolicy) (*lldpdpb.Result, error) {
+	config := lldpd.NewLLDPMedNetworkPolicy()
+	if err := grpcapi.Convert(in, config); err != nil {
+		return nil, err
+	}
+	ok, err := g.h.DeleteLLDPMedNetworkPolicy(config)
+	return &lldpdpb.Result{Ok: ok}, err
+}
+
+func (g *LLDPDGrpcHandler) GetLLDPIntfState(ctx context.Context, in *lldpdpb.LLDPIntfState) (*lldpdpb.LLDPIntfState, error) {
+	obj, err := g.h.GetLLDPIntfState(in.IfIndex)
+	if err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		return nil, errors.New(fmt.Sprintf("LLDPIntfState %d not found", in.IfIndex))
+	}
+	out := &lldpdpb.LLDPIntfState{}
+	err = grpcapi.Convert(obj, out)
+	return out, err
+}
+
+func (g *LLDPDGrpcHandler) GetBulkLLDPIntfState(ctx context.Context, in *lldpdpb.BulkRequest) (*lldpdpb.LLDPIntfStateGetInfo, error) {
+	obj, err := g.h.GetBulkLLDPIntfState(lldpd.Int(in.FromIndex), lldpd.Int(in.Count))
+	if err != nil {
+		return nil, err
+	}
+	out := &lldpdpb.LLDPIntfStateGetInfo{}
+	err = grpcapi.Convert(obj, out)
+	return out, err
+}
+
+func (g *LLDPDGrpcHandler) GetLLDPIntfStatsState(ctx context.Context, in *lldpdpb.LLDPIntfStatsState) (*lldpdpb.LLDPIntfStatsState, error) {
+	obj, err := g.h.GetLLDPIntfStatsState(in.IfIndex)
+	if err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		return nil, errors.New(fmt.Sprintf("LLDPIntfStatsState %d not found", in.IfIndex))
+	}
+	out := &lldpdpb.LLDPIntfStatsState{}
+	err = grpcapi.Convert(obj, out)
+	return out, err
+}
+
+func (g *LLDPDGrpcHandler) GetBulkLLDPIntfStatsState(ctx context.Context, in *lldpdpb.BulkRequest) (*lldpdpb.LLDPIntfStatsStateGetInfo, error) {
+	obj, err := g.h.GetBulkLLDPIntfStatsState(lldpd.Int(in.FromIndex), lldpd.Int(in.Count))
+	if err != nil {
+		return nil, err
+	}
+	out := &lldpdpb.LLDPIntfStatsStateGetInfo{}
+	err = grpcapi.Convert(obj, out)
+	return out, err
+}
+
+func (g *LLDPDGrpcHandler) GetLLDPGlobalStatsState(ctx context.Context, in *lldpdpb.LLDPGlobalStatsState) (*lldpdpb.LLDPGlobalStatsState, error) {
+	obj, err := g.h.GetLLDPGlobalStatsState(in.Vrf)
+	if err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		return nil, errors.New(fmt.Sprintf("LLDPGlobalStatsState %s not found", in.Vrf))
+	}
+	out := &lldpdpb.LLDPGlobalStatsState{}
+	err = grpcapi.Convert(obj, out)
+	return out, err
+}
+
+func (g *LLDPDGrpcHandler) GetBulkLLDPGlobalStatsState(ctx context.Context, in *lldpdpb.BulkRequest) (*lldpdpb.LLDPGlobalStatsStateGetInfo, error) {
+	obj, err := g.h.GetBulkLLDPGlobalStatsState(lldpd.Int(in.FromIndex), lldpd.Int(in.Count))
+	if err != nil {
+		return nil, err
+	}
+	out := &lldpdpb.LLDPGlobalStatsStateGetInfo{}
+	err = grpcapi.Convert(obj, out)
+	return out, err
+}
+
+func (g *LLDPDGrpcHandler) ClearLLDPIntfStats(ctx context.Context, in *lldpdpb.ClearLLDPIntfStats) (*lldpdpb.Result, error) {
+	config := lldpd.NewClearLLDPIntfStats()
+	if err := grpcapi.Convert(in, config); err != nil {
+		return nil, err
+	}
+	ok, err := g.h.ExecuteActionClearLLDPIntfStats(config)
+	return &lldpdpb.Result{Ok: ok}, err
+}
+
+// startGrpcServer starts the grpc server and json/http gateway of lldpd
+// when the lldpd-grpc entry is present in clients.json, the thrift server
+// keeps running alongside
+func (p *NBPlugin) startGrpcServer(fileName string) error {
+	grpcPort, gwPort := grpcapi.ClientPortsGet(fileName, "lldpd")
+	if grpcPort == 0 {
+		return nil
+	}
+	s := grpcapi.NewServer("lldpd", grpcPort, gwPort)
+	lldpdpb.RegisterLLDPDServicesServer(s.GrpcServer(), NewLLDPDGrpcHandler(p.handler))
+	err := s.Start(lldpdpb.RegisterLLDPDServicesHandlerFromEndpoint, func(err error) {
+		debug.Logger.Err(fmt.Sprintln(err))
+	})
+	if err == nil {
+		debug.Logger.Info(fmt.Sprintln("Started grpc server on", s.GrpcAddr, "gateway on", s.GatewayAddr))
+	}
+	return err
+}
@@ -0,0 +1,187 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// lacp.go
+package flexswitch
+
+import (
+	"encoding/json"
+	"fmt"
+	nanomsg "github.com/op/go-nanomsg"
+	lacp "l2/lacp/protocol"
+	lacprpc "l2/lacp/rpc"
+	"l2/lldp/api"
+	"l2/lldp/utils"
+	"lacpd"
+	"strconv"
+	"time"
+	"utils/ipcutils"
+)
+
+/*  LacpPlugin learns the lag membership of the ports from lacpd, the initial
+ *  membership is read in bulk and kept up to date by the lacpd notifications
+ */
+type LacpPlugin struct {
+	fileName       string
+	lacpdClient    *lacpd.LACPDServicesClient
+	lacpdSubSocket *nanomsg.SubSocket
+}
+
+func NewLacpPlugin(fileName string) *LacpPlugin {
+	return &LacpPlugin{
+		fileName: fileName,
+	}
+}
+
+/*  Connect to lacpd, retry until lacpd is up. Nil is returned when lacpd is
+ *  not part of clients.json
+ */
+func connectLacpd(filePath string) *lacpd.LACPDServicesClient {
+	clientJson, err := getClient(filePath+CLIENTS_FILE_NAME, "lacpd")
+	if err != nil || clientJson == nil {
+		return nil
+	}
+	address := "localhost:" + strconv.Itoa(clientJson.Port)
+	clientTransport, protocolFactory, err := ipcutils.CreateIPCHandles(address)
+	if err != nil {
+		debug.Logger.Info("Failed to connect to LACPd, retrying until success")
+		count := 0
+		ticker := time.NewTicker(time.Duration(1000) * time.Millisecond)
+		for _ = range ticker.C {
+			clientTransport, protocolFactory, err = ipcutils.CreateIPCHandles(address)
+			if err == nil {
+				ticker.Stop()
+				break
+			}
+			count++
+			if (count % 10) == 0 {
+				debug.Logger.Info("Still waiting to connect to LACPd")
+			}
+		}
+	}
+	return lacpd.NewLACPDServicesClientFactory(clientTransport, protocolFactory)
+}
+
+func (p *LacpPlugin) connectSubSocket() error {
+	var err error
+	address := lacprpc.LACPD_PUB_SOCKET_ADDR
+	if p.lacpdSubSocket, err = nanomsg.NewSubSocket(); err != nil {
+		debug.Logger.Err(fmt.Sprintln("Failed to create LACP subscribe socket, error:",
+			err))
+		return err
+	}
+	if err = p.lacpdSubSocket.Subscribe(""); err != nil {
+		debug.Logger.Err(fmt.Sprintln("Failed to subscribe to LACP subscribe socket",
+			"error:", err))
+		return err
+	}
+	if _, err = p.lacpdSubSocket.Connect(address); err != nil {
+		debug.Logger.Err(fmt.Sprintln("Failed to connect to LACP publisher socket",
+			"address:", address, "error:", err))
+		return err
+	}
+	if err = p.lacpdSubSocket.SetRecvBuffer(1024 * 1024); err != nil {
+		debug.Logger.Err(fmt.Sprintln("Failed to set the buffer size for LACP publisher",
+			"socket, error:", err))
+		return err
+	}
+	debug.Logger.Info(fmt.Sprintln("Connected to LACP publisher at address:", address))
+	return nil
+}
+
+/*  Helper function to get bulk lag member state from lacpd, a port is
+ *  aggregated while it is distributing
+ */
+func (p *LacpPlugin) getLagMembers() {
+	currMarker := int64(0)
+	count := 10
+	for {
+		bulkInfo, err := p.lacpdClient.GetBulkLaPortChannelMemberState(
+			lacpd.Int(currMarker), lacpd.Int(count))
+		if err != nil {
+			debug.Logger.Err(fmt.Sprintln("getting bulk lag member state from",
+				"lacpd failed with reason", err))
+			break
+		}
+		currMarker = int64(bulkInfo.EndIdx)
+		for i := 0; i < int(bulkInfo.Count); i++ {
+			obj := bulkInfo.LaPortChannelMemberStateList[i]
+			if obj.Distributing && obj.LagId != 0 {
+				api.SendPortLagChange(obj.IfIndex, true, obj.LagId)
+			}
+		}
+		if bool(bulkInfo.More) == false {
+			break
+		}
+	}
+}
+
+func (p *LacpPlugin) listenLacpdUpdates() {
+	for {
+		rxBuf, err := p.lacpdSubSocket.Recv(0)
+		if err != nil {
+			debug.Logger.Err(fmt.Sprintln(
+				"Recv on lacpd Subscriber socket failed with error:", err))
+			continue
+		}
+		var msg lacprpc.LacpdNotification
+		err = json.Unmarshal(rxBuf, &msg)
+		if err != nil {
+			debug.Logger.Err(fmt.Sprintln("Unable to Unmarshal lacpd msg:", rxBuf))
+			continue
+		}
+		var aggMsg lacp.LacpNotifyAggMsg
+		err = json.Unmarshal(msg.Msg, &aggMsg)
+		if err != nil {
+			debug.Logger.Err(fmt.Sprintln("Unable to Unmarshal lacpd agg msg:", msg.Msg))
+			continue
+		}
+		switch msg.MsgType {
+		case lacp.LacpNotifyAggMemberAdded:
+			api.SendPortLagChange(aggMsg.IfIndex, true, aggMsg.AggId)
+		case lacp.LacpNotifyAggMemberRemoved:
+			api.SendPortLagChange(aggMsg.IfIndex, false, 0)
+		}
+	}
+}
+
+func (p *LacpPlugin) run() {
+	p.lacpdClient = connectLacpd(p.fileName)
+	if p.lacpdClient == nil {
+		debug.Logger.Info("LACPd not found, lag membership is not advertised")
+		return
+	}
+	// subscribe before reading the members so that no change is missed
+	if err := p.connectSubSocket(); err != nil {
+		return
+	}
+	p.getLagMembers()
+	p.listenLacpdUpdates()
+}
+
+/*  Connecting to lacpd may take a while, the server is informed about the
+ *  lag membership once it is known
+ */
+func (p *LacpPlugin) Start() {
+	go p.run()
+}
@@ -59,6 +59,9 @@ func (p *NBPlugin) Start() error {
 		return err
 	}
 	debug.Logger.Info(fmt.Sprintln("Got Client Info for", clientJson.Name, " port", clientJson.Port))
+	if err = p.startGrpcServer(fileName); err != nil {
+		debug.Logger.Err(fmt.Sprintln("Failed to start the grpc server, err:", err))
+	}
 	// create processor, transport and protocol for server
 	processor := lldpd.NewLLDPDServicesProcessor(p.handler)
 	transportFactory := thrift.NewTBufferedTransportFactory(8192)
@@ -80,7 +83,8 @@ func (p *NBPlugin) Start() error {
 }
 
 func (h *ConfigHandler) CreateLLDPIntf(config *lldpd.LLDPIntf) (r bool, err error) {
-	return api.SendIntfConfig(config.IfIndex, config.Enable)
+	return api.SendIntfConfig(config.IfIndex, config.Enable, config.DisabledTLVs,
+		config.MedLocationElin)
 }
 
 func (h *ConfigHandler) DeleteLLDPIntf(config *lldpd.LLDPIntf) (r bool, err error) {
@@ -91,11 +95,36 @@ func (h *ConfigHandler) UpdateLLDPIntf(origconfig *lldpd.LLDPIntf,
 	newconfig *lldpd.LLDPIntf, attrset []bool, op []*lldpd.PatchOpInfo) (r bool, err error) {
 	// On update we do not care for old config... just push the new config to api layer
 	// and let the api layer handle the information
-	return api.UpdateIntfConfig(newconfig.IfIndex, newconfig.Enable)
+	return api.UpdateIntfConfig(newconfig.IfIndex, newconfig.Enable, newconfig.DisabledTLVs,
+		newconfig.MedLocationElin)
+}
+
+func convertLLDPMedNetworkPolicy(policy *lldpd.LLDPMedNetworkPolicy) *config.MedNetworkPolicy {
+	return &config.MedNetworkPolicy{
+		IfIndex:     policy.IfIndex,
+		Application: policy.Application,
+		VlanId:      policy.VlanId,
+		Tagged:      policy.Tagged,
+		Priority:    policy.Priority,
+		Dscp:        policy.Dscp,
+	}
+}
+
+func (h *ConfigHandler) CreateLLDPMedNetworkPolicy(config *lldpd.LLDPMedNetworkPolicy) (r bool, err error) {
+	return api.SendMedNetworkPolicy(convertLLDPMedNetworkPolicy(config))
+}
+
+func (h *ConfigHandler) DeleteLLDPMedNetworkPolicy(config *lldpd.LLDPMedNetworkPolicy) (r bool, err error) {
+	return api.DeleteMedNetworkPolicy(config.IfIndex, config.Application)
+}
+
+func (h *ConfigHandler) UpdateLLDPMedNetworkPolicy(origconfig *lldpd.LLDPMedNetworkPolicy,
+	newconfig *lldpd.LLDPMedNetworkPolicy, attrset []bool, op []*lldpd.PatchOpInfo) (r bool, err error) {
+	return api.SendMedNetworkPolicy(convertLLDPMedNetworkPolicy(newconfig))
 }
 
 func (h *ConfigHandler) CreateLLDPGlobal(config *lldpd.LLDPGlobal) (r bool, err error) {
-	return api.SendGlobalConfig(config.Vrf, config.Enable)
+	return api.SendGlobalConfig(config.Vrf, config.Enable, config.DisabledTLVs)
 }
 
 func (h *ConfigHandler) DeleteLLDPGlobal(config *lldpd.LLDPGlobal) (r bool, err error) {
@@ -106,7 +135,7 @@ func (h *ConfigHandler) UpdateLLDPGlobal(origconfig *lldpd.LLDPGlobal,
 	newconfig *lldpd.LLDPGlobal, attrset []bool, op []*lldpd.PatchOpInfo) (r bool, err error) {
 	// On update we do not care for old config... just push the new config to api layer
 	// and let the api layer handle the information
-	return api.UpdateGlobalConfig(newconfig.Vrf, newconfig.Enable)
+	return api.UpdateGlobalConfig(newconfig.Vrf, newconfig.Enable, newconfig.DisabledTLVs)
 }
 
 func (h *ConfigHandler) convertLLDPIntfStateEntryToThriftEntry(
@@ -118,6 +147,34 @@ func (h *ConfigHandler) convertLLDPIntfStateEntryToThriftEntry(
 	entry.HoldTime = state.HoldTime
 	entry.Enable = state.Enable
 	entry.IfIndex = state.IfIndex
+	entry.PeerPortVlanId = state.PeerPortVlanId
+	entry.PeerProtocolVlanIds = state.PeerProtocolVlanIds
+	entry.PeerVlanNames = state.PeerVlanNames
+	entry.PeerLagSupported = state.PeerLagSupported
+	entry.PeerLagEnabled = state.PeerLagEnabled
+	entry.PeerLagPortId = state.PeerLagPortId
+	entry.PeerAutonegSupported = state.PeerAutonegSupported
+	entry.PeerAutonegEnabled = state.PeerAutonegEnabled
+	entry.PeerMauType = state.PeerMauType
+	entry.PeerPowerSupported = state.PeerPowerSupported
+	entry.PeerPowerEnabled = state.PeerPowerEnabled
+	entry.PeerPowerClass = state.PeerPowerClass
+	entry.PeerMaxFrameSize = state.PeerMaxFrameSize
+	entry.PortVlanIdMismatch = state.PortVlanIdMismatch
+	entry.LagMismatch = state.LagMismatch
+	entry.MedEnabled = state.MedEnabled
+	entry.PeerMedDeviceType = state.PeerMedDeviceType
+	entry.PeerMedNetworkPolicies = state.PeerMedNetworkPolicies
+	entry.PeerMedLocation = state.PeerMedLocation
+	entry.PeerMedPowerPriority = state.PeerMedPowerPriority
+	entry.PeerMedPowerValue = state.PeerMedPowerValue
+	entry.PeerMedHardwareRevision = state.PeerMedHardwareRevision
+	entry.PeerMedFirmwareRevision = state.PeerMedFirmwareRevision
+	entry.PeerMedSoftwareRevision = state.PeerMedSoftwareRevision
+	entry.PeerMedSerialNumber = state.PeerMedSerialNumber
+	entry.PeerMedManufacturer = state.PeerMedManufacturer
+	entry.PeerMedModelName = state.PeerMedModelName
+	entry.PeerMedAssetId = state.PeerMedAssetId
 	return entry
 }
 
@@ -149,3 +206,88 @@ func (h *ConfigHandler) GetBulkLLDPIntfState(fromIndex lldpd.Int,
 func (h *ConfigHandler) GetLLDPIntfState(ifIndex int32) (*lldpd.LLDPIntfState, error) {
 	return nil, nil
 }
+
+func (h *ConfigHandler) convertLLDPIntfStatsEntryToThriftEntry(
+	stats config.IntfStats) *lldpd.LLDPIntfStatsState {
+	entry := lldpd.NewLLDPIntfStatsState()
+	entry.IfIndex = stats.IfIndex
+	entry.LocalPort = stats.LocalPort
+	entry.FramesOutTotal = int32(stats.FramesOutTotal)
+	entry.FramesInTotal = int32(stats.FramesInTotal)
+	entry.FramesInErrorsTotal = int32(stats.FramesInErrorsTotal)
+	entry.FramesDiscardedTotal = int32(stats.FramesDiscardedTotal)
+	entry.TLVsDiscardedTotal = int32(stats.TLVsDiscardedTotal)
+	entry.TLVsUnrecognizedTotal = int32(stats.TLVsUnrecognizedTotal)
+	entry.AgeoutsTotal = int32(stats.AgeoutsTotal)
+	entry.TooManyNeighbors = stats.TooManyNeighbors
+	return entry
+}
+
+func (h *ConfigHandler) GetBulkLLDPIntfStatsState(fromIndex lldpd.Int,
+	count lldpd.Int) (*lldpd.LLDPIntfStatsStateGetInfo, error) {
+
+	nextIdx, currCount, lldpIntfStatsEntries := api.GetIntfStats(
+		int(fromIndex), int(count))
+	if lldpIntfStatsEntries == nil {
+		return nil, errors.New("No interface found")
+	}
+
+	lldpEntryResp := make([]*lldpd.LLDPIntfStatsState, len(lldpIntfStatsEntries))
+
+	for idx, item := range lldpIntfStatsEntries {
+		lldpEntryResp[idx] = h.convertLLDPIntfStatsEntryToThriftEntry(item)
+	}
+
+	lldpEntryBulk := lldpd.NewLLDPIntfStatsStateGetInfo()
+	lldpEntryBulk.StartIdx = fromIndex
+	lldpEntryBulk.EndIdx = lldpd.Int(nextIdx)
+	lldpEntryBulk.Count = lldpd.Int(currCount)
+	lldpEntryBulk.More = (nextIdx != 0)
+	lldpEntryBulk.LLDPIntfStatsStateList = lldpEntryResp
+
+	return lldpEntryBulk, nil
+}
+
+func (h *ConfigHandler) GetLLDPIntfStatsState(ifIndex int32) (*lldpd.LLDPIntfStatsState, error) {
+	stats, exists := api.GetIntfStat(ifIndex)
+	if !exists {
+		return nil, errors.New(fmt.Sprintf("No entry for ifIndex %d", ifIndex))
+	}
+	return h.convertLLDPIntfStatsEntryToThriftEntry(stats), nil
+}
+
+func (h *ConfigHandler) convertLLDPGlobalStatsEntryToThriftEntry(
+	stats config.GlobalStats) *lldpd.LLDPGlobalStatsState {
+	entry := lldpd.NewLLDPGlobalStatsState()
+	entry.Vrf = stats.Vrf
+	if !stats.RemTablesLastChangeTime.IsZero() {
+		entry.RemTablesLastChangeTime = stats.RemTablesLastChangeTime.String()
+	}
+	entry.RemTablesInserts = int32(stats.RemTablesInserts)
+	entry.RemTablesDeletes = int32(stats.RemTablesDeletes)
+	entry.RemTablesDrops = int32(stats.RemTablesDrops)
+	entry.RemTablesAgeouts = int32(stats.RemTablesAgeouts)
+	return entry
+}
+
+func (h *ConfigHandler) GetBulkLLDPGlobalStatsState(fromIndex lldpd.Int,
+	count lldpd.Int) (*lldpd.LLDPGlobalStatsStateGetInfo, error) {
+	// there is a single global object
+	lldpEntryBulk := lldpd.NewLLDPGlobalStatsStateGetInfo()
+	lldpEntryBulk.StartIdx = fromIndex
+	if fromIndex == 0 && count > 0 {
+		lldpEntryBulk.Count = 1
+		lldpEntryBulk.LLDPGlobalStatsStateList = []*lldpd.LLDPGlobalStatsState{
+			h.convertLLDPGlobalStatsEntryToThriftEntry(api.GetGlobalStats()),
+		}
+	}
+	return lldpEntryBulk, nil
+}
+
+func (h *ConfigHandler) GetLLDPGlobalStatsState(vrf string) (*lldpd.LLDPGlobalStatsState, error) {
+	return h.convertLLDPGlobalStatsEntryToThriftEntry(api.GetGlobalStats()), nil
+}
+
+func (h *ConfigHandler) ExecuteActionClearLLDPIntfStats(config *lldpd.ClearLLDPIntfStats) (bool, error) {
+	return api.ClearStats(config.IfIndex)
+}
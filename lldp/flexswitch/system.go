@@ -24,27 +24,174 @@ package flexswitch
 
 import (
 	"encoding/json"
-	_ "errors"
 	"fmt"
 	nanomsg "github.com/op/go-nanomsg"
 	"infra/sysd/sysdCommonDefs"
+	"io/ioutil"
 	"l2/lldp/api"
+	"l2/lldp/config"
 	"l2/lldp/utils"
-	_ "strconv"
-	_ "sysd"
-	_ "time"
-	_ "utils/ipcutils"
+	"strconv"
+	"strings"
+	"sync"
+	"sysd"
+	"time"
+	"utils/ipcutils"
 )
 
+const (
+	// hardware inventory of the switch sent to the MED endpoints
+	SYSTEM_DMI_PATH = "/sys/class/dmi/id/"
+)
+
+// system capabilities advertised when any of the daemons is running
+var capabilityDaemons = map[uint16][]string{
+	config.SYS_CAP_BRIDGE: []string{"asicd", "stpd", "lacpd"},
+	config.SYS_CAP_ROUTER: []string{"ribd", "arpd", "bgpd", "ospfd"},
+}
+
 type SystemPlugin struct {
+	fileName      string
+	sysdClient    *sysd.SYSDServicesClient
 	sysdSubSocket *nanomsg.SubSocket
+
+	// daemons which sysd reports as up, the system capabilities are
+	// derived from them
+	capsMutex sync.RWMutex
+	daemons   map[string]bool
+	sysCaps   config.SystemCapabilities
+
+	// read once, the hardware does not change
+	inventory config.Inventory
 }
 
 func NewSystemPlugin(fileName string) (*SystemPlugin, error) {
-	mgr := &SystemPlugin{}
+	mgr := &SystemPlugin{
+		fileName: fileName,
+		daemons:  make(map[string]bool),
+	}
+	mgr.inventory = getInventory()
 	return mgr, nil
 }
 
+func readDmi(name string) string {
+	data, err := ioutil.ReadFile(SYSTEM_DMI_PATH + name)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+/*  Hardware inventory from the dmi information of the platform, the software
+ *  revision is the version of the system
+ */
+func getInventory() config.Inventory {
+	return config.Inventory{
+		HardwareRevision: readDmi("product_version"),
+		FirmwareRevision: readDmi("bios_version"),
+		SerialNumber:     readDmi("product_serial"),
+		Manufacturer:     readDmi("sys_vendor"),
+		ModelName:        readDmi("product_name"),
+		AssetId:          readDmi("chassis_asset_tag"),
+	}
+}
+
+func (p *SystemPlugin) GetInventory() config.Inventory {
+	return p.inventory
+}
+
+/*  Bridge/router capabilities derived from the daemons which are running,
+ *  what is running is also what is enabled
+ */
+func getRunningCapabilities(daemons map[string]bool) config.SystemCapabilities {
+	var sysCaps config.SystemCapabilities
+	for capability, names := range capabilityDaemons {
+		for _, name := range names {
+			if daemons[name] {
+				sysCaps.Supported |= capability
+				sysCaps.Enabled |= capability
+				break
+			}
+		}
+	}
+	return sysCaps
+}
+
+func (p *SystemPlugin) GetSystemCapabilities() config.SystemCapabilities {
+	p.capsMutex.RLock()
+	defer p.capsMutex.RUnlock()
+	return p.sysCaps
+}
+
+/*  Daemon state learned from sysd, the frames are built again when the
+ *  system capabilities change
+ */
+func (p *SystemPlugin) daemonStateSet(name string, up bool) {
+	p.capsMutex.Lock()
+	p.daemons[name] = up
+	sysCaps := getRunningCapabilities(p.daemons)
+	changed := sysCaps != p.sysCaps
+	p.sysCaps = sysCaps
+	p.capsMutex.Unlock()
+	if changed {
+		debug.Logger.Info(fmt.Sprintln("System capabilities changed to", sysCaps))
+		api.UpdateCache()
+	}
+}
+
+/*  Connect to sysd, retry until sysd is up. Nil is returned when sysd is
+ *  not part of clients.json
+ */
+func connectSysd(filePath string) *sysd.SYSDServicesClient {
+	clientJson, err := getClient(filePath+CLIENTS_FILE_NAME, "sysd")
+	if err != nil || clientJson == nil {
+		return nil
+	}
+	address := "localhost:" + strconv.Itoa(clientJson.Port)
+	clientTransport, protocolFactory, err := ipcutils.CreateIPCHandles(address)
+	if err != nil {
+		debug.Logger.Info("Failed to connect to Sysd, retrying until success")
+		count := 0
+		ticker := time.NewTicker(time.Duration(1000) * time.Millisecond)
+		for _ = range ticker.C {
+			clientTransport, protocolFactory, err = ipcutils.CreateIPCHandles(address)
+			if err == nil {
+				ticker.Stop()
+				break
+			}
+			count++
+			if (count % 10) == 0 {
+				debug.Logger.Info("Still waiting to connect to Sysd")
+			}
+		}
+	}
+	return sysd.NewSYSDServicesClientFactory(clientTransport, protocolFactory)
+}
+
+/*  Helper function to get bulk daemon state from sysd
+ */
+func (p *SystemPlugin) getDaemonStates() {
+	upState := sysdCommonDefs.ConvertDaemonStateCodeToString(sysdCommonDefs.UP)
+	currMarker := int64(0)
+	count := 10
+	for {
+		bulkInfo, err := p.sysdClient.GetBulkDaemonState(sysd.Int(currMarker), sysd.Int(count))
+		if err != nil {
+			debug.Logger.Err(fmt.Sprintln("getting bulk daemon state from",
+				"sysd failed with reason", err))
+			break
+		}
+		currMarker = int64(bulkInfo.EndIdx)
+		for i := 0; i < int(bulkInfo.Count); i++ {
+			obj := bulkInfo.DaemonStateList[i]
+			p.daemonStateSet(obj.Name, obj.State == upState)
+		}
+		if bool(bulkInfo.More) == false {
+			break
+		}
+	}
+}
+
 func (p *SystemPlugin) connectSubSocket() error {
 	var err error
 	address := sysdCommonDefs.PUB_SOCKET_ADDR
@@ -95,14 +242,36 @@ func (p *SystemPlugin) listenSystemdUpdates() {
 		switch msg.Type {
 		case sysdCommonDefs.SYSTEM_Info:
 			api.UpdateCache()
+		case sysdCommonDefs.KA_DAEMON:
+			var status sysdCommonDefs.DaemonStatus
+			err = json.Unmarshal(msg.Payload, &status)
+			if err != nil {
+				debug.Logger.Err(fmt.Sprintln("Unable to Unmarshal daemon status err:", err))
+				continue
+			}
+			p.daemonStateSet(status.Name, status.Status == sysdCommonDefs.UP)
 		}
 	}
 }
 
-func (p *SystemPlugin) Start() {
-	err := p.connectSubSocket()
-	if err != nil {
+func (p *SystemPlugin) run() {
+	// subscribe before reading the daemon states so that no change is
+	// missed
+	if err := p.connectSubSocket(); err != nil {
 		return
 	}
-	go p.listenSystemdUpdates()
+	p.sysdClient = connectSysd(p.fileName)
+	if p.sysdClient != nil {
+		p.getDaemonStates()
+	} else {
+		debug.Logger.Info("Sysd not found, daemon states are only learned from notifications")
+	}
+	p.listenSystemdUpdates()
+}
+
+/*  Connecting to sysd may take a while, the system capabilities are
+ *  advertised once the daemon states are known
+ */
+func (p *SystemPlugin) Start() {
+	go p.run()
 }
@@ -0,0 +1,113 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// system_test.go
+package flexswitch
+
+import (
+	"l2/lldp/api"
+	"l2/lldp/config"
+	"l2/lldp/server"
+	"l2/lldp/utils"
+	"testing"
+	"utils/logging"
+)
+
+func TestRunningCapabilities(t *testing.T) {
+	bridge := config.SystemCapabilities{
+		Supported: config.SYS_CAP_BRIDGE,
+		Enabled:   config.SYS_CAP_BRIDGE,
+	}
+	router := config.SystemCapabilities{
+		Supported: config.SYS_CAP_ROUTER,
+		Enabled:   config.SYS_CAP_ROUTER,
+	}
+	both := config.SystemCapabilities{
+		Supported: config.SYS_CAP_BRIDGE | config.SYS_CAP_ROUTER,
+		Enabled:   config.SYS_CAP_BRIDGE | config.SYS_CAP_ROUTER,
+	}
+	for _, test := range []struct {
+		name    string
+		daemons map[string]bool
+		sysCaps config.SystemCapabilities
+	}{
+		{"nothing running", map[string]bool{}, config.SystemCapabilities{}},
+		{"asicd", map[string]bool{"asicd": true}, bridge},
+		{"stpd", map[string]bool{"stpd": true}, bridge},
+		{"lacpd", map[string]bool{"lacpd": true}, bridge},
+		{"lacpd down", map[string]bool{"lacpd": false}, config.SystemCapabilities{}},
+		{"ribd", map[string]bool{"ribd": true}, router},
+		{"arpd", map[string]bool{"arpd": true}, router},
+		{"bgpd and ospfd", map[string]bool{"bgpd": true, "ospfd": true}, router},
+		{"stpd and bgpd", map[string]bool{"stpd": true, "bgpd": true}, both},
+		{"stpd up and bgpd down", map[string]bool{"stpd": true, "bgpd": false}, bridge},
+		{"unrelated daemons", map[string]bool{"lldpd": true, "sysd": true}, config.SystemCapabilities{}},
+	} {
+		if sysCaps := getRunningCapabilities(test.daemons); sysCaps != test.sysCaps {
+			t.Error("Expected", test.name, "system capabilities", test.sysCaps, "got", sysCaps)
+		}
+	}
+}
+
+func TestDaemonStateSet(t *testing.T) {
+	if debug.Logger == nil {
+		logger, _ := logging.NewLogger("lldpd", "LLDP", false)
+		debug.SetLogger(logger)
+	}
+	// the frames are built again by the server when the cache is updated
+	svr := &server.LLDPServer{UpdateCacheCh: make(chan bool, 1)}
+	api.Init(svr)
+
+	p := &SystemPlugin{daemons: make(map[string]bool)}
+	for _, test := range []struct {
+		name      string
+		up        bool
+		supported uint16
+		updated   bool
+	}{
+		{"stpd", true, config.SYS_CAP_BRIDGE, true},
+		{"lacpd", true, config.SYS_CAP_BRIDGE, false},
+		{"stpd", false, config.SYS_CAP_BRIDGE, false},
+		{"lacpd", false, 0, true},
+		{"ospfd", true, config.SYS_CAP_ROUTER, true},
+		{"asicd", true, config.SYS_CAP_BRIDGE | config.SYS_CAP_ROUTER, true},
+		{"lldpd", true, config.SYS_CAP_BRIDGE | config.SYS_CAP_ROUTER, false},
+		{"ospfd", false, config.SYS_CAP_BRIDGE, true},
+	} {
+		p.daemonStateSet(test.name, test.up)
+		sysCaps := p.GetSystemCapabilities()
+		if sysCaps.Supported != test.supported ||
+			sysCaps.Enabled != test.supported {
+			t.Error("Expected system capabilities", test.supported, "after", test.name, "up", test.up, "got", sysCaps)
+		}
+		updated := false
+		select {
+		case <-svr.UpdateCacheCh:
+			updated = true
+		default:
+		}
+		if updated != test.updated {
+			t.Error("Expected cache update", test.updated, "after", test.name, "up", test.up, "got", updated)
+		}
+	}
+}
@@ -30,6 +30,7 @@ import (
 	"l2/lldp/flexswitch"
 	"l2/lldp/server"
 	"l2/lldp/utils"
+	"l2/pktio"
 	"utils/keepalive"
 	"utils/logging"
 )
@@ -37,7 +38,13 @@ import (
 func main() {
 	fmt.Println("Starting lldp daemon")
 	paramsDir := flag.String("params", "./params", "Params directory")
+	pktIoType := flag.String("pktio", pktio.PktIoTypePcap, "Packet I/O backend: pcap, afpacket or memory")
+	maxNeighbors := flag.Int("maxneighbors", server.LLDP_DEFAULT_MAX_NEIGHBORS, "Max neighbors learned per port, 0 for no limit")
 	flag.Parse()
+	if !pktio.IsSupported(*pktIoType) {
+		fmt.Println("Unsupported packet I/O backend", *pktIoType)
+		return
+	}
 	fileName := *paramsDir
 	if fileName[len(fileName)-1] != '/' {
 		fileName = fileName + "/"
@@ -72,12 +79,17 @@ func main() {
 
 		// Create lldp server handler
 		lldpSvr := server.LLDPNewServer(aPlugin, lPlugin, sPlugin)
+		lldpSvr.SetPktIoType(*pktIoType)
+		lldpSvr.SetMaxNeighbors(*maxNeighbors)
 		// Start Api Layer
 		api.Init(lldpSvr)
 
 		// Until Server is connected to clients do not start with RPC
 		lldpSvr.LLDPStartServer(*paramsDir)
 
+		// Lag membership is learned from lacpd once the ports are known
+		flexswitch.NewLacpPlugin(fileName).Start()
+
 		// Start keepalive routine
 		go keepalive.InitKeepAlive("lldpd", fileName)
 		debug.Logger.Info("Starting LLDP RPC listener....")
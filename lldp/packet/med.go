@@ -0,0 +1,408 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// med.go
+package packet
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/google/gopacket/layers"
+	"l2/lldp/config"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	LLDP_OUI_TIA = 0x0012bb
+
+	// ANSI/TIA-1057 subtypes
+	LLDP_MED_SUBTYPE_CAPABILITIES      = 1
+	LLDP_MED_SUBTYPE_NETWORK_POLICY    = 2
+	LLDP_MED_SUBTYPE_LOCATION          = 3
+	LLDP_MED_SUBTYPE_EXTENDED_POWER    = 4
+	LLDP_MED_SUBTYPE_HARDWARE_REVISION = 5
+	LLDP_MED_SUBTYPE_FIRMWARE_REVISION = 6
+	LLDP_MED_SUBTYPE_SOFTWARE_REVISION = 7
+	LLDP_MED_SUBTYPE_SERIAL_NUMBER     = 8
+	LLDP_MED_SUBTYPE_MANUFACTURER      = 9
+	LLDP_MED_SUBTYPE_MODEL_NAME        = 10
+	LLDP_MED_SUBTYPE_ASSET_ID          = 11
+
+	// LLDP-MED capabilities bits
+	LLDP_MED_CAP_CAPABILITIES   = 1 << 0
+	LLDP_MED_CAP_NETWORK_POLICY = 1 << 1
+	LLDP_MED_CAP_LOCATION       = 1 << 2
+	LLDP_MED_CAP_EXT_POWER_PSE  = 1 << 3
+	LLDP_MED_CAP_EXT_POWER_PD   = 1 << 4
+	LLDP_MED_CAP_INVENTORY      = 1 << 5
+
+	// LLDP-MED device types
+	LLDP_MED_DEVICE_ENDPOINT_CLASS_I     = 1
+	LLDP_MED_DEVICE_ENDPOINT_CLASS_II    = 2
+	LLDP_MED_DEVICE_ENDPOINT_CLASS_III   = 3
+	LLDP_MED_DEVICE_NETWORK_CONNECTIVITY = 4
+
+	// location data formats
+	LLDP_MED_LOCATION_COORDINATE = 1
+	LLDP_MED_LOCATION_CIVIC      = 2
+	LLDP_MED_LOCATION_ELIN       = 3
+
+	// extended power via mdi, power type PSE and power source primary
+	LLDP_MED_POWER_TYPE_PSE       = 0
+	LLDP_MED_POWER_SOURCE_PRIMARY = 1
+
+	LLDP_MED_MAX_INVENTORY_LEN = 32
+
+	// frames sent at the fast start interval once a MED endpoint is detected
+	LLDP_MED_FAST_START_REPEAT_COUNT = 3
+	LLDP_MED_FAST_START_INTERVAL     = 1 * time.Second
+)
+
+var medDeviceTypeNames = map[uint8]string{
+	LLDP_MED_DEVICE_ENDPOINT_CLASS_I:     "EndpointClassI",
+	LLDP_MED_DEVICE_ENDPOINT_CLASS_II:    "EndpointClassII",
+	LLDP_MED_DEVICE_ENDPOINT_CLASS_III:   "EndpointClassIII",
+	LLDP_MED_DEVICE_NETWORK_CONNECTIVITY: "NetworkConnectivity",
+}
+
+type MedCapabilities struct {
+	Supported  uint16
+	DeviceType uint8
+}
+
+type MedNetworkPolicy struct {
+	Application uint8
+	// the policy is required by the device but unknown
+	Unknown  bool
+	Tagged   bool
+	VlanId   uint16
+	Priority uint8
+	Dscp     uint8
+}
+
+type MedLocation struct {
+	Format uint8
+	Data   []byte
+}
+
+type MedExtendedPower struct {
+	Type     uint8
+	Source   uint8
+	Priority uint8
+	// power in 0.1 W
+	Value uint16
+}
+
+// MedInfo holds the LLDP-MED tlv's of a neighbor, Capabilities is nil when
+// the neighbor is not a MED device
+type MedInfo struct {
+	Capabilities    *MedCapabilities
+	NetworkPolicies []MedNetworkPolicy
+	Locations       []MedLocation
+	Power           *MedExtendedPower
+	Inventory       config.Inventory
+}
+
+// MedTxInfo is the information sent in the LLDP-MED tlv's of a port
+type MedTxInfo struct {
+	Policies     []config.MedNetworkPolicy
+	LocationElin string
+	Inventory    config.Inventory
+}
+
+/*  The neighbor is a MED endpoint, which starts the MED tlv's on the port
+ */
+func (m MedInfo) IsEndpoint() bool {
+	return m.Capabilities != nil &&
+		m.Capabilities.DeviceType >= LLDP_MED_DEVICE_ENDPOINT_CLASS_I &&
+		m.Capabilities.DeviceType <= LLDP_MED_DEVICE_ENDPOINT_CLASS_III
+}
+
+func (m MedInfo) DeviceTypeString() string {
+	if m.Capabilities == nil {
+		return ""
+	}
+	if name, exists := medDeviceTypeNames[m.Capabilities.DeviceType]; exists {
+		return name
+	}
+	return fmt.Sprintf("Unknown(%d)", m.Capabilities.DeviceType)
+}
+
+func (p MedNetworkPolicy) String() string {
+	app := fmt.Sprintf("%d", p.Application)
+	for name, code := range config.MedApplications {
+		if code == p.Application {
+			app = name
+		}
+	}
+	if p.Unknown {
+		return app + " unknown"
+	}
+	tagged := "untagged"
+	if p.Tagged {
+		tagged = "tagged"
+	}
+	return fmt.Sprintf("%s vlan %d %s priority %d dscp %d", app, p.VlanId, tagged,
+		p.Priority, p.Dscp)
+}
+
+/*  Location as a string, civic addresses are returned as the country code
+ *  followed by the CAtype=CAvalue pairs
+ */
+func (l MedLocation) String() string {
+	switch l.Format {
+	case LLDP_MED_LOCATION_ELIN:
+		return "ELIN " + string(l.Data)
+	case LLDP_MED_LOCATION_CIVIC:
+		// LCI length, what, country code, CA elements
+		if len(l.Data) < 4 {
+			break
+		}
+		parts := []string{"Civic " + string(l.Data[2:4])}
+		ca := l.Data[4:]
+		for len(ca) >= 2 && len(ca) >= 2+int(ca[1]) {
+			parts = append(parts, fmt.Sprintf("%d=%s", ca[0], string(ca[2:2+int(ca[1])])))
+			ca = ca[2+int(ca[1]):]
+		}
+		return strings.Join(parts, " ")
+	case LLDP_MED_LOCATION_COORDINATE:
+		return fmt.Sprintf("Coordinate %x", l.Data)
+	}
+	return fmt.Sprintf("Unknown(%d) %x", l.Format, l.Data)
+}
+
+/*  LLDP-MED capabilities 2 bytes
+ *  Device type 1 byte
+ */
+func EncodeMedCapabilitiesTLV(caps MedCapabilities) *layers.LinkLayerDiscoveryValue {
+	b := make([]byte, 3)
+	binary.BigEndian.PutUint16(b[0:2], caps.Supported)
+	b[2] = caps.DeviceType
+	return EncodeOrgTLV(LLDP_OUI_TIA, LLDP_MED_SUBTYPE_CAPABILITIES, b)
+}
+
+/*  Application type 1 byte
+ *  Unknown policy 1 bit, tagged 1 bit, reserved 1 bit, vlan id 12 bits,
+ *  L2 priority 3 bits, DSCP 6 bits
+ */
+func EncodeMedNetworkPolicyTLV(policy MedNetworkPolicy) *layers.LinkLayerDiscoveryValue {
+	b := make([]byte, 4)
+	b[0] = policy.Application
+	var flags uint32
+	if policy.Unknown {
+		flags |= 1 << 23
+	}
+	if policy.Tagged {
+		flags |= 1 << 22
+	}
+	flags |= uint32(policy.VlanId&0xfff) << 9
+	flags |= uint32(policy.Priority&0x7) << 6
+	flags |= uint32(policy.Dscp & 0x3f)
+	b[1] = byte(flags >> 16)
+	b[2] = byte(flags >> 8)
+	b[3] = byte(flags)
+	return EncodeOrgTLV(LLDP_OUI_TIA, LLDP_MED_SUBTYPE_NETWORK_POLICY, b)
+}
+
+/*  Location data format 1 byte
+ *  Location id N bytes
+ */
+func EncodeMedLocationTLV(location MedLocation) *layers.LinkLayerDiscoveryValue {
+	b := make([]byte, 1+len(location.Data))
+	b[0] = location.Format
+	copy(b[1:], location.Data)
+	return EncodeOrgTLV(LLDP_OUI_TIA, LLDP_MED_SUBTYPE_LOCATION, b)
+}
+
+/*  Power type 2 bits, power source 2 bits, power priority 4 bits
+ *  Power value 2 bytes in 0.1 W
+ */
+func EncodeMedExtendedPowerTLV(power MedExtendedPower) *layers.LinkLayerDiscoveryValue {
+	b := make([]byte, 3)
+	b[0] = (power.Type&0x3)<<6 | (power.Source&0x3)<<4 | power.Priority&0xf
+	binary.BigEndian.PutUint16(b[1:3], power.Value)
+	return EncodeOrgTLV(LLDP_OUI_TIA, LLDP_MED_SUBTYPE_EXTENDED_POWER, b)
+}
+
+/*  Inventory string up to 32 bytes
+ */
+func EncodeMedInventoryTLV(subtype uint8, value string) *layers.LinkLayerDiscoveryValue {
+	if len(value) > LLDP_MED_MAX_INVENTORY_LEN {
+		value = value[:LLDP_MED_MAX_INVENTORY_LEN]
+	}
+	return EncodeOrgTLV(LLDP_OUI_TIA, subtype, []byte(value))
+}
+
+type medPoliciesByApplication []config.MedNetworkPolicy
+
+func (p medPoliciesByApplication) Len() int      { return len(p) }
+func (p medPoliciesByApplication) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p medPoliciesByApplication) Less(i, j int) bool {
+	return config.MedApplications[p[i].Application] < config.MedApplications[p[j].Application]
+}
+
+/*  LLDP-MED tlv's of a network connectivity device, the ones in disabledTLVs
+ *  are not added and none are added when the capabilities tlv is disabled.
+ *  Extended power is only sent by ports which supply power and the empty
+ *  inventory strings are not sent
+ */
+func createMedPayload(port config.PortInfo, med *MedTxInfo, disabledTLVs map[string]bool) []byte {
+	var payload []byte
+	if disabledTLVs[config.TLV_MED_CAPABILITIES] {
+		return payload
+	}
+	caps := MedCapabilities{
+		Supported: LLDP_MED_CAP_CAPABILITIES | LLDP_MED_CAP_NETWORK_POLICY |
+			LLDP_MED_CAP_LOCATION | LLDP_MED_CAP_INVENTORY,
+		DeviceType: LLDP_MED_DEVICE_NETWORK_CONNECTIVITY,
+	}
+	if port.Poe != nil {
+		caps.Supported |= LLDP_MED_CAP_EXT_POWER_PSE
+	}
+	payload = append(payload, EncodeTLV(EncodeMedCapabilitiesTLV(caps))...)
+
+	if !disabledTLVs[config.TLV_MED_NETWORK_POLICY] {
+		policies := make([]config.MedNetworkPolicy, len(med.Policies))
+		copy(policies, med.Policies)
+		sort.Sort(medPoliciesByApplication(policies))
+		for _, policy := range policies {
+			payload = append(payload, EncodeTLV(EncodeMedNetworkPolicyTLV(MedNetworkPolicy{
+				Application: config.MedApplications[policy.Application],
+				Tagged:      policy.Tagged,
+				VlanId:      uint16(policy.VlanId),
+				Priority:    uint8(policy.Priority),
+				Dscp:        uint8(policy.Dscp),
+			}))...)
+		}
+	}
+	if !disabledTLVs[config.TLV_MED_LOCATION] && med.LocationElin != "" {
+		payload = append(payload, EncodeTLV(EncodeMedLocationTLV(MedLocation{
+			Format: LLDP_MED_LOCATION_ELIN,
+			Data:   []byte(med.LocationElin),
+		}))...)
+	}
+	if !disabledTLVs[config.TLV_MED_EXTENDED_POWER] && port.Poe != nil {
+		payload = append(payload, EncodeTLV(EncodeMedExtendedPowerTLV(MedExtendedPower{
+			Type:     LLDP_MED_POWER_TYPE_PSE,
+			Source:   LLDP_MED_POWER_SOURCE_PRIMARY,
+			Priority: port.Poe.Priority,
+			Value:    port.Poe.PowerValue,
+		}))...)
+	}
+	if !disabledTLVs[config.TLV_MED_INVENTORY] {
+		inventory := []struct {
+			subtype uint8
+			value   string
+		}{
+			{LLDP_MED_SUBTYPE_HARDWARE_REVISION, med.Inventory.HardwareRevision},
+			{LLDP_MED_SUBTYPE_FIRMWARE_REVISION, med.Inventory.FirmwareRevision},
+			{LLDP_MED_SUBTYPE_SOFTWARE_REVISION, med.Inventory.SoftwareRevision},
+			{LLDP_MED_SUBTYPE_SERIAL_NUMBER, med.Inventory.SerialNumber},
+			{LLDP_MED_SUBTYPE_MANUFACTURER, med.Inventory.Manufacturer},
+			{LLDP_MED_SUBTYPE_MODEL_NAME, med.Inventory.ModelName},
+			{LLDP_MED_SUBTYPE_ASSET_ID, med.Inventory.AssetId},
+		}
+		for _, item := range inventory {
+			if item.value != "" {
+				payload = append(payload, EncodeTLV(EncodeMedInventoryTLV(item.subtype,
+					item.value))...)
+			}
+		}
+	}
+	return payload
+}
+
+/*  Decode the LLDP-MED tlv's of a received frame, the unknown subtypes are
+ *  counted as unrecognized and malformed ones as discarded
+ */
+func DecodeMedTLVs(tlvs []layers.LLDPOrgSpecificTLV) (med MedInfo, unrecognized uint32,
+	discarded uint32) {
+	for _, tlv := range tlvs {
+		if uint32(tlv.OUI) != LLDP_OUI_TIA {
+			continue
+		}
+		info := tlv.Info
+		valid := true
+		switch tlv.SubType {
+		case LLDP_MED_SUBTYPE_CAPABILITIES:
+			if valid = len(info) >= 3; valid {
+				med.Capabilities = &MedCapabilities{
+					Supported:  binary.BigEndian.Uint16(info[0:2]),
+					DeviceType: info[2],
+				}
+			}
+		case LLDP_MED_SUBTYPE_NETWORK_POLICY:
+			if valid = len(info) >= 4; valid {
+				flags := uint32(info[1])<<16 | uint32(info[2])<<8 | uint32(info[3])
+				med.NetworkPolicies = append(med.NetworkPolicies, MedNetworkPolicy{
+					Application: info[0],
+					Unknown:     flags&(1<<23) != 0,
+					Tagged:      flags&(1<<22) != 0,
+					VlanId:      uint16(flags>>9) & 0xfff,
+					Priority:    uint8(flags>>6) & 0x7,
+					Dscp:        uint8(flags) & 0x3f,
+				})
+			}
+		case LLDP_MED_SUBTYPE_LOCATION:
+			if valid = len(info) >= 1; valid {
+				data := make([]byte, len(info)-1)
+				copy(data, info[1:])
+				med.Locations = append(med.Locations, MedLocation{
+					Format: info[0],
+					Data:   data,
+				})
+			}
+		case LLDP_MED_SUBTYPE_EXTENDED_POWER:
+			if valid = len(info) >= 3; valid {
+				med.Power = &MedExtendedPower{
+					Type:     info[0] >> 6,
+					Source:   (info[0] >> 4) & 0x3,
+					Priority: info[0] & 0xf,
+					Value:    binary.BigEndian.Uint16(info[1:3]),
+				}
+			}
+		case LLDP_MED_SUBTYPE_HARDWARE_REVISION:
+			med.Inventory.HardwareRevision = string(info)
+		case LLDP_MED_SUBTYPE_FIRMWARE_REVISION:
+			med.Inventory.FirmwareRevision = string(info)
+		case LLDP_MED_SUBTYPE_SOFTWARE_REVISION:
+			med.Inventory.SoftwareRevision = string(info)
+		case LLDP_MED_SUBTYPE_SERIAL_NUMBER:
+			med.Inventory.SerialNumber = string(info)
+		case LLDP_MED_SUBTYPE_MANUFACTURER:
+			med.Inventory.Manufacturer = string(info)
+		case LLDP_MED_SUBTYPE_MODEL_NAME:
+			med.Inventory.ModelName = string(info)
+		case LLDP_MED_SUBTYPE_ASSET_ID:
+			med.Inventory.AssetId = string(info)
+		default:
+			unrecognized++
+		}
+		if !valid {
+			discarded++
+		}
+	}
+	return med, unrecognized, discarded
+}
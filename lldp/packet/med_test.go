@@ -0,0 +1,204 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// med_test.go
+package packet
+
+import (
+	"github.com/google/gopacket/layers"
+	"l2/lldp/config"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMedTLVEncodeDecode(t *testing.T) {
+	caps := MedCapabilities{
+		Supported:  LLDP_MED_CAP_CAPABILITIES | LLDP_MED_CAP_NETWORK_POLICY,
+		DeviceType: LLDP_MED_DEVICE_ENDPOINT_CLASS_III,
+	}
+	policies := []MedNetworkPolicy{
+		{
+			Application: config.MedApplications["Voice"],
+			Tagged:      true,
+			VlanId:      4094,
+			Priority:    7,
+			Dscp:        63,
+		},
+		{
+			Application: config.MedApplications["VideoSignaling"],
+			Unknown:     true,
+		},
+	}
+	location := MedLocation{
+		Format: LLDP_MED_LOCATION_ELIN,
+		Data:   []byte("5551234567"),
+	}
+	power := MedExtendedPower{
+		Type:     LLDP_MED_POWER_TYPE_PSE,
+		Source:   LLDP_MED_POWER_SOURCE_PRIMARY,
+		Priority: 2,
+		Value:    154,
+	}
+	longSerial := strings.Repeat("s", LLDP_MED_MAX_INVENTORY_LEN+1)
+	tlvs := []layers.LLDPOrgSpecificTLV{
+		orgTLV(EncodeMedCapabilitiesTLV(caps)),
+		orgTLV(EncodeMedNetworkPolicyTLV(policies[0])),
+		orgTLV(EncodeMedNetworkPolicyTLV(policies[1])),
+		orgTLV(EncodeMedLocationTLV(location)),
+		orgTLV(EncodeMedExtendedPowerTLV(power)),
+		orgTLV(EncodeMedInventoryTLV(LLDP_MED_SUBTYPE_SOFTWARE_REVISION, "1.0")),
+		orgTLV(EncodeMedInventoryTLV(LLDP_MED_SUBTYPE_SERIAL_NUMBER, longSerial)),
+		orgTLV(EncodeMedInventoryTLV(LLDP_MED_SUBTYPE_ASSET_ID, "asset")),
+		// 802.1 tlv's are left to DecodeOrgTLVs
+		orgTLV(EncodePortVlanIdTLV(10)),
+	}
+	med, unrecognized, discarded := DecodeMedTLVs(tlvs)
+	if unrecognized != 0 || discarded != 0 {
+		t.Error("Expected no unrecognized or discarded tlv's, got", unrecognized, discarded)
+	}
+	if med.Capabilities == nil || *med.Capabilities != caps {
+		t.Error("Expected capabilities", caps, "got", med.Capabilities)
+	}
+	if !med.IsEndpoint() {
+		t.Error("Expected a class III endpoint, got", med.DeviceTypeString())
+	}
+	if !reflect.DeepEqual(med.NetworkPolicies, policies) {
+		t.Error("Expected network policies", policies, "got", med.NetworkPolicies)
+	}
+	if !reflect.DeepEqual(med.Locations, []MedLocation{location}) {
+		t.Error("Expected location", location, "got", med.Locations)
+	}
+	if med.Power == nil || *med.Power != power {
+		t.Error("Expected extended power", power, "got", med.Power)
+	}
+	inventory := config.Inventory{
+		SoftwareRevision: "1.0",
+		SerialNumber:     longSerial[:LLDP_MED_MAX_INVENTORY_LEN],
+		AssetId:          "asset",
+	}
+	if med.Inventory != inventory {
+		t.Error("Expected inventory", inventory, "got", med.Inventory)
+	}
+}
+
+func TestMedTLVDecodeUnrecognizedAndMalformed(t *testing.T) {
+	tlvs := []layers.LLDPOrgSpecificTLV{
+		{OUI: layers.IEEEOUI(LLDP_OUI_TIA), SubType: 99, Info: []byte{1}},
+		{OUI: layers.IEEEOUI(LLDP_OUI_TIA), SubType: LLDP_MED_SUBTYPE_CAPABILITIES,
+			Info: []byte{0, 1}},
+		{OUI: layers.IEEEOUI(LLDP_OUI_TIA), SubType: LLDP_MED_SUBTYPE_NETWORK_POLICY,
+			Info: []byte{1, 0, 0}},
+		{OUI: layers.IEEEOUI(LLDP_OUI_TIA), SubType: LLDP_MED_SUBTYPE_LOCATION},
+	}
+	med, unrecognized, discarded := DecodeMedTLVs(tlvs)
+	if unrecognized != 1 {
+		t.Error("Expected 1 unrecognized tlv, got", unrecognized)
+	}
+	if discarded != 3 {
+		t.Error("Expected 3 discarded tlv's, got", discarded)
+	}
+	if med.Capabilities != nil || len(med.NetworkPolicies) != 0 || len(med.Locations) != 0 {
+		t.Error("Expected malformed tlv's to be ignored, got", med)
+	}
+	if med.IsEndpoint() {
+		t.Error("Expected a neighbor without capabilities not to be an endpoint")
+	}
+}
+
+func TestMedPayload(t *testing.T) {
+	port := config.PortInfo{
+		IfIndex: 1,
+		Poe: &config.PortPoe{
+			Enabled:    true,
+			Priority:   1,
+			PowerValue: 300,
+		},
+	}
+	txInfo := &MedTxInfo{
+		// sent in the order of the application codes
+		Policies: []config.MedNetworkPolicy{
+			{IfIndex: 1, Application: "VoiceSignaling", VlanId: 20, Priority: 3, Dscp: 24},
+			{IfIndex: 1, Application: "Voice", VlanId: 10, Tagged: true, Priority: 5, Dscp: 46},
+		},
+		LocationElin: "5551234567",
+		Inventory: config.Inventory{
+			SoftwareRevision: "1.0",
+			ModelName:        "switch",
+		},
+	}
+	med, unrecognized, discarded := DecodeMedTLVs(payloadOrgTLVs(t,
+		createMedPayload(port, txInfo, map[string]bool{})))
+	if unrecognized != 0 || discarded != 0 {
+		t.Error("Expected no unrecognized or discarded tlv's, got", unrecognized, discarded)
+	}
+	if med.Capabilities == nil ||
+		med.Capabilities.DeviceType != LLDP_MED_DEVICE_NETWORK_CONNECTIVITY ||
+		med.Capabilities.Supported&LLDP_MED_CAP_EXT_POWER_PSE == 0 {
+		t.Error("Expected a network connectivity device supplying power, got",
+			med.Capabilities)
+	}
+	if med.IsEndpoint() {
+		t.Error("Expected a network connectivity device not to be an endpoint")
+	}
+	policies := []MedNetworkPolicy{
+		{Application: 1, Tagged: true, VlanId: 10, Priority: 5, Dscp: 46},
+		{Application: 2, VlanId: 20, Priority: 3, Dscp: 24},
+	}
+	if !reflect.DeepEqual(med.NetworkPolicies, policies) {
+		t.Error("Expected network policies", policies, "got", med.NetworkPolicies)
+	}
+	if len(med.Locations) != 1 || med.Locations[0].String() != "ELIN 5551234567" {
+		t.Error("Expected ELIN location 5551234567, got", med.Locations)
+	}
+	if med.Power == nil || med.Power.Priority != 1 || med.Power.Value != 300 {
+		t.Error("Expected extended power priority 1 value 300, got", med.Power)
+	}
+	if med.Inventory != txInfo.Inventory {
+		t.Error("Expected inventory", txInfo.Inventory, "got", med.Inventory)
+	}
+
+	// disabled tlv's are not sent, nor is extended power by a port which
+	// does not supply power
+	port.Poe = nil
+	med, _, _ = DecodeMedTLVs(payloadOrgTLVs(t,
+		createMedPayload(port, txInfo, map[string]bool{
+			config.TLV_MED_NETWORK_POLICY: true,
+			config.TLV_MED_INVENTORY:      true,
+		})))
+	if med.Capabilities == nil || len(med.Locations) != 1 {
+		t.Error("Expected capabilities and location to be sent, got", med)
+	}
+	if len(med.NetworkPolicies) != 0 || med.Power != nil ||
+		med.Inventory != (config.Inventory{}) {
+		t.Error("Expected no network policies, extended power or inventory, got", med)
+	}
+
+	// nothing is sent without the capabilities tlv
+	payload := createMedPayload(port, txInfo, map[string]bool{
+		config.TLV_MED_CAPABILITIES: true,
+	})
+	if len(payload) != 0 {
+		t.Error("Expected an empty payload without capabilities, got", payload)
+	}
+}
@@ -0,0 +1,413 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// orgtlv.go
+package packet
+
+import (
+	"encoding/binary"
+	"github.com/google/gopacket/layers"
+	"l2/lldp/config"
+)
+
+const (
+	LLDP_OUI_8021 = 0x0080c2
+	LLDP_OUI_8023 = 0x00120f
+
+	// IEEE 802.1 subtypes
+	LLDP_8021_SUBTYPE_PORT_VLAN_ID     = 1
+	LLDP_8021_SUBTYPE_PROTOCOL_VLAN_ID = 2
+	LLDP_8021_SUBTYPE_VLAN_NAME        = 3
+	LLDP_8021_SUBTYPE_LINK_AGGREGATION = 7
+
+	// IEEE 802.3 subtypes, the 802.3 link aggregation tlv is deprecated in
+	// favour of the 802.1 one and is only decoded
+	LLDP_8023_SUBTYPE_MAC_PHY          = 1
+	LLDP_8023_SUBTYPE_POWER_VIA_MDI    = 2
+	LLDP_8023_SUBTYPE_LINK_AGGREGATION = 3
+	LLDP_8023_SUBTYPE_MAX_FRAME_SIZE   = 4
+
+	LLDP_MAX_VLAN_NAME_LEN = 32
+	// ethernet header, vlan tag and fcs on top of the mtu
+	LLDP_FRAME_OVERHEAD = 22
+)
+
+// PMD auto-negotiation advertised capability bits, bOther is the msb
+const (
+	MAC_PHY_CAP_OTHER        = 0x8000
+	MAC_PHY_CAP_10BASET_HD   = 0x4000
+	MAC_PHY_CAP_10BASET_FD   = 0x2000
+	MAC_PHY_CAP_100BASETX_HD = 0x0800
+	MAC_PHY_CAP_100BASETX_FD = 0x0400
+	MAC_PHY_CAP_1000BASET_HD = 0x0002
+	MAC_PHY_CAP_1000BASET_FD = 0x0001
+)
+
+// Operational MAU types (RFC 4836), zero when unknown
+const (
+	MAU_TYPE_10BASET_HD   = 10
+	MAU_TYPE_10BASET_FD   = 11
+	MAU_TYPE_100BASETX_HD = 15
+	MAU_TYPE_100BASETX_FD = 16
+	MAU_TYPE_1000BASET_HD = 29
+	MAU_TYPE_1000BASET_FD = 30
+	MAU_TYPE_10GBASER     = 33
+)
+
+type PortProtocolVlan struct {
+	Supported bool
+	Enabled   bool
+	Id        uint16
+}
+
+type VlanName struct {
+	Id   uint16
+	Name string
+}
+
+type LinkAggregation struct {
+	Supported bool
+	Enabled   bool
+	// ifIndex of the aggregator, zero when not aggregated
+	PortId uint32
+}
+
+// Dot1Info holds the IEEE 802.1 tlv's of a neighbor, PortVlanId zero means
+// the tlv was not received or port based vlans are not supported
+type Dot1Info struct {
+	PortVlanId      uint16
+	ProtocolVlans   []PortProtocolVlan
+	VlanNames       []VlanName
+	LinkAggregation *LinkAggregation
+}
+
+type MacPhyConfigStatus struct {
+	AutonegSupported  bool
+	AutonegEnabled    bool
+	AutonegAdvertised uint16
+	MauType           uint16
+}
+
+type PowerViaMdi struct {
+	PortClassPSE bool
+	Supported    bool
+	Enabled      bool
+	PairsControl bool
+	PowerPair    uint8
+	// power class 0 - 4
+	PowerClass uint8
+}
+
+// Dot3Info holds the IEEE 802.3 tlv's of a neighbor, MaxFrameSize zero
+// means the tlv was not received
+type Dot3Info struct {
+	MacPhy       *MacPhyConfigStatus
+	Power        *PowerViaMdi
+	MaxFrameSize uint16
+}
+
+/*  TLV Type = 127
+ *  Value: 4 + N bytes
+ *     OUI 3 bytes
+ *     Subtype 1 byte
+ *     Information string N bytes
+ */
+func EncodeOrgTLV(oui uint32, subtype uint8, info []byte) *layers.LinkLayerDiscoveryValue {
+	value := make([]byte, 4+len(info))
+	value[0] = byte(oui >> 16)
+	value[1] = byte(oui >> 8)
+	value[2] = byte(oui)
+	value[3] = subtype
+	copy(value[4:], info)
+	return &layers.LinkLayerDiscoveryValue{
+		Type:   layers.LLDPTLVOrgSpecific,
+		Length: uint16(len(value)),
+		Value:  value,
+	}
+}
+
+func boolBit(set bool, bit uint8) uint8 {
+	if set {
+		return bit
+	}
+	return 0
+}
+
+/*  Port VLAN ID 2 bytes
+ */
+func EncodePortVlanIdTLV(pvid int32) *layers.LinkLayerDiscoveryValue {
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, uint16(pvid))
+	return EncodeOrgTLV(LLDP_OUI_8021, LLDP_8021_SUBTYPE_PORT_VLAN_ID, b)
+}
+
+/*  Flags 1 byte: bit 1 supported, bit 2 enabled
+ *  Port and protocol VLAN ID 2 bytes
+ */
+func EncodeProtocolVlanIdTLV(ppvid PortProtocolVlan) *layers.LinkLayerDiscoveryValue {
+	b := make([]byte, 3)
+	b[0] = boolBit(ppvid.Supported, 1<<1) | boolBit(ppvid.Enabled, 1<<2)
+	binary.BigEndian.PutUint16(b[1:3], ppvid.Id)
+	return EncodeOrgTLV(LLDP_OUI_8021, LLDP_8021_SUBTYPE_PROTOCOL_VLAN_ID, b)
+}
+
+/*  VLAN ID 2 bytes
+ *  VLAN name length 1 byte
+ *  VLAN name up to 32 bytes
+ */
+func EncodeVlanNameTLV(vlan config.PortVlan) *layers.LinkLayerDiscoveryValue {
+	name := vlan.Name
+	if len(name) > LLDP_MAX_VLAN_NAME_LEN {
+		name = name[:LLDP_MAX_VLAN_NAME_LEN]
+	}
+	b := make([]byte, 3+len(name))
+	binary.BigEndian.PutUint16(b[0:2], uint16(vlan.VlanId))
+	b[2] = byte(len(name))
+	copy(b[3:], name)
+	return EncodeOrgTLV(LLDP_OUI_8021, LLDP_8021_SUBTYPE_VLAN_NAME, b)
+}
+
+/*  Aggregation status 1 byte: bit 0 capability, bit 1 status
+ *  Aggregated port ID 4 bytes
+ */
+func EncodeLinkAggregationTLV(lag LinkAggregation) *layers.LinkLayerDiscoveryValue {
+	b := make([]byte, 5)
+	b[0] = boolBit(lag.Supported, 1<<0) | boolBit(lag.Enabled, 1<<1)
+	binary.BigEndian.PutUint32(b[1:5], lag.PortId)
+	return EncodeOrgTLV(LLDP_OUI_8021, LLDP_8021_SUBTYPE_LINK_AGGREGATION, b)
+}
+
+/*  Auto-negotiation support/status 1 byte: bit 0 supported, bit 1 enabled
+ *  PMD auto-negotiation advertised capability 2 bytes
+ *  Operational MAU type 2 bytes
+ */
+func EncodeMacPhyTLV(macPhy MacPhyConfigStatus) *layers.LinkLayerDiscoveryValue {
+	b := make([]byte, 5)
+	b[0] = boolBit(macPhy.AutonegSupported, 1<<0) | boolBit(macPhy.AutonegEnabled, 1<<1)
+	binary.BigEndian.PutUint16(b[1:3], macPhy.AutonegAdvertised)
+	binary.BigEndian.PutUint16(b[3:5], macPhy.MauType)
+	return EncodeOrgTLV(LLDP_OUI_8023, LLDP_8023_SUBTYPE_MAC_PHY, b)
+}
+
+/*  MDI power support 1 byte: bit 0 port class PSE, bit 1 supported,
+ *  bit 2 enabled, bit 3 pairs control
+ *  PSE power pair 1 byte
+ *  Power class 1 byte, class 0 - 4 is sent as 1 - 5
+ */
+func EncodePowerViaMdiTLV(power PowerViaMdi) *layers.LinkLayerDiscoveryValue {
+	b := make([]byte, 3)
+	b[0] = boolBit(power.PortClassPSE, 1<<0) | boolBit(power.Supported, 1<<1) |
+		boolBit(power.Enabled, 1<<2) | boolBit(power.PairsControl, 1<<3)
+	b[1] = power.PowerPair
+	b[2] = power.PowerClass + 1
+	return EncodeOrgTLV(LLDP_OUI_8023, LLDP_8023_SUBTYPE_POWER_VIA_MDI, b)
+}
+
+/*  Maximum frame size 2 bytes
+ */
+func EncodeMaxFrameSizeTLV(size uint16) *layers.LinkLayerDiscoveryValue {
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, size)
+	return EncodeOrgTLV(LLDP_OUI_8023, LLDP_8023_SUBTYPE_MAX_FRAME_SIZE, b)
+}
+
+/*  MAC/PHY configuration of the port, the configured speed and duplex are
+ *  advertised when auto-negotiation is enabled
+ */
+func MacPhyFromPort(port config.PortInfo) MacPhyConfigStatus {
+	macPhy := MacPhyConfigStatus{
+		AutonegSupported: true,
+		AutonegEnabled:   port.Autoneg,
+	}
+	var hd, fd uint16
+	switch port.Speed {
+	case 10:
+		hd, fd = MAU_TYPE_10BASET_HD, MAU_TYPE_10BASET_FD
+		macPhy.AutonegAdvertised = MAC_PHY_CAP_10BASET_HD
+		if port.FullDuplex {
+			macPhy.AutonegAdvertised = MAC_PHY_CAP_10BASET_FD
+		}
+	case 100:
+		hd, fd = MAU_TYPE_100BASETX_HD, MAU_TYPE_100BASETX_FD
+		macPhy.AutonegAdvertised = MAC_PHY_CAP_100BASETX_HD
+		if port.FullDuplex {
+			macPhy.AutonegAdvertised = MAC_PHY_CAP_100BASETX_FD
+		}
+	case 1000:
+		hd, fd = MAU_TYPE_1000BASET_HD, MAU_TYPE_1000BASET_FD
+		macPhy.AutonegAdvertised = MAC_PHY_CAP_1000BASET_HD
+		if port.FullDuplex {
+			macPhy.AutonegAdvertised = MAC_PHY_CAP_1000BASET_FD
+		}
+	case 10000:
+		hd, fd = MAU_TYPE_10GBASER, MAU_TYPE_10GBASER
+		macPhy.AutonegAdvertised = MAC_PHY_CAP_OTHER
+	default:
+		macPhy.AutonegAdvertised = MAC_PHY_CAP_OTHER
+	}
+	if port.FullDuplex {
+		macPhy.MauType = fd
+	} else {
+		macPhy.MauType = hd
+	}
+	if !port.Autoneg {
+		macPhy.AutonegAdvertised = 0
+	}
+	return macPhy
+}
+
+/*  IEEE 802.1 and 802.3 tlv's of the port, the ones in disabledTLVs are not
+ *  added. Power via MDI is only sent by ports which supply power
+ */
+func createOrgPayload(port config.PortInfo, disabledTLVs map[string]bool) []byte {
+	var payload []byte
+	add := func(name string, tlv *layers.LinkLayerDiscoveryValue) {
+		if !disabledTLVs[name] {
+			payload = append(payload, EncodeTLV(tlv)...)
+		}
+	}
+	add(config.TLV_PORT_VLAN_ID, EncodePortVlanIdTLV(port.Pvid))
+	// protocol based vlans are not supported
+	add(config.TLV_PORT_PROTOCOL_VLAN_ID, EncodeProtocolVlanIdTLV(PortProtocolVlan{}))
+	if !disabledTLVs[config.TLV_VLAN_NAME] {
+		for _, vlan := range port.Vlans {
+			payload = append(payload, EncodeTLV(EncodeVlanNameTLV(vlan))...)
+		}
+	}
+	// any port can be added to a lag
+	add(config.TLV_LINK_AGGREGATION, EncodeLinkAggregationTLV(LinkAggregation{
+		Supported: true,
+		Enabled:   port.Lag.Enabled,
+		PortId:    uint32(port.Lag.AggId),
+	}))
+	add(config.TLV_MAC_PHY_CONFIG_STATUS, EncodeMacPhyTLV(MacPhyFromPort(port)))
+	if port.Poe != nil {
+		add(config.TLV_POWER_VIA_MDI, EncodePowerViaMdiTLV(PowerViaMdi{
+			PortClassPSE: true,
+			Supported:    true,
+			Enabled:      port.Poe.Enabled,
+			PairsControl: port.Poe.PairsControl,
+			PowerPair:    port.Poe.PowerPair,
+			PowerClass:   port.Poe.PowerClass,
+		}))
+	}
+	if port.Mtu > 0 {
+		add(config.TLV_MAX_FRAME_SIZE, EncodeMaxFrameSizeTLV(uint16(port.Mtu+LLDP_FRAME_OVERHEAD)))
+	}
+	return payload
+}
+
+/*  Decode the IEEE 802.1 and 802.3 tlv's of a received frame. Tlv's of other
+ *  organizations or unknown subtypes are counted as unrecognized and
+ *  malformed ones as discarded
+ */
+func DecodeOrgTLVs(tlvs []layers.LLDPOrgSpecificTLV) (dot1 Dot1Info, dot3 Dot3Info,
+	unrecognized uint32, discarded uint32) {
+	for _, tlv := range tlvs {
+		info := tlv.Info
+		valid := true
+		switch uint32(tlv.OUI) {
+		case LLDP_OUI_8021:
+			switch tlv.SubType {
+			case LLDP_8021_SUBTYPE_PORT_VLAN_ID:
+				if valid = len(info) >= 2; valid {
+					dot1.PortVlanId = binary.BigEndian.Uint16(info[0:2])
+				}
+			case LLDP_8021_SUBTYPE_PROTOCOL_VLAN_ID:
+				if valid = len(info) >= 3; valid {
+					dot1.ProtocolVlans = append(dot1.ProtocolVlans, PortProtocolVlan{
+						Supported: info[0]&(1<<1) != 0,
+						Enabled:   info[0]&(1<<2) != 0,
+						Id:        binary.BigEndian.Uint16(info[1:3]),
+					})
+				}
+			case LLDP_8021_SUBTYPE_VLAN_NAME:
+				if valid = len(info) >= 3 && len(info) >= 3+int(info[2]); valid {
+					dot1.VlanNames = append(dot1.VlanNames, VlanName{
+						Id:   binary.BigEndian.Uint16(info[0:2]),
+						Name: string(info[3 : 3+int(info[2])]),
+					})
+				}
+			case LLDP_8021_SUBTYPE_LINK_AGGREGATION:
+				if valid = len(info) >= 5; valid {
+					dot1.LinkAggregation = decodeLinkAggregation(info)
+				}
+			default:
+				unrecognized++
+			}
+		case LLDP_OUI_8023:
+			switch tlv.SubType {
+			case LLDP_8023_SUBTYPE_MAC_PHY:
+				if valid = len(info) >= 5; valid {
+					dot3.MacPhy = &MacPhyConfigStatus{
+						AutonegSupported:  info[0]&(1<<0) != 0,
+						AutonegEnabled:    info[0]&(1<<1) != 0,
+						AutonegAdvertised: binary.BigEndian.Uint16(info[1:3]),
+						MauType:           binary.BigEndian.Uint16(info[3:5]),
+					}
+				}
+			case LLDP_8023_SUBTYPE_POWER_VIA_MDI:
+				if valid = len(info) >= 3; valid {
+					power := &PowerViaMdi{
+						PortClassPSE: info[0]&(1<<0) != 0,
+						Supported:    info[0]&(1<<1) != 0,
+						Enabled:      info[0]&(1<<2) != 0,
+						PairsControl: info[0]&(1<<3) != 0,
+						PowerPair:    info[1],
+					}
+					if info[2] > 0 {
+						power.PowerClass = info[2] - 1
+					}
+					dot3.Power = power
+				}
+			case LLDP_8023_SUBTYPE_LINK_AGGREGATION:
+				// the 802.1 tlv takes precedence
+				if valid = len(info) >= 5; valid && dot1.LinkAggregation == nil {
+					dot1.LinkAggregation = decodeLinkAggregation(info)
+				}
+			case LLDP_8023_SUBTYPE_MAX_FRAME_SIZE:
+				if valid = len(info) >= 2; valid {
+					dot3.MaxFrameSize = binary.BigEndian.Uint16(info[0:2])
+				}
+			default:
+				unrecognized++
+			}
+		case LLDP_OUI_TIA:
+			// decoded by DecodeMedTLVs
+		default:
+			unrecognized++
+		}
+		if !valid {
+			discarded++
+		}
+	}
+	return dot1, dot3, unrecognized, discarded
+}
+
+func decodeLinkAggregation(info []byte) *LinkAggregation {
+	return &LinkAggregation{
+		Supported: info[0]&(1<<0) != 0,
+		Enabled:   info[0]&(1<<1) != 0,
+		PortId:    binary.BigEndian.Uint32(info[1:5]),
+	}
+}
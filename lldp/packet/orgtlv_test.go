@@ -0,0 +1,283 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// orgtlv_test.go
+package packet
+
+import (
+	"encoding/binary"
+	"github.com/google/gopacket/layers"
+	"l2/lldp/config"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// org specific tlv as decoded by gopacket from an encoded tlv value
+func orgTLV(tlv *layers.LinkLayerDiscoveryValue) layers.LLDPOrgSpecificTLV {
+	v := tlv.Value
+	return layers.LLDPOrgSpecificTLV{
+		OUI:     layers.IEEEOUI(uint32(v[0])<<16 | uint32(v[1])<<8 | uint32(v[2])),
+		SubType: v[3],
+		Info:    v[4:],
+	}
+}
+
+// split an encoded payload back into org specific tlv's
+func payloadOrgTLVs(t *testing.T, payload []byte) []layers.LLDPOrgSpecificTLV {
+	var tlvs []layers.LLDPOrgSpecificTLV
+	for len(payload) > 0 {
+		if len(payload) < 2 {
+			t.Fatal("Truncated tlv header in payload", payload)
+		}
+		typeLen := binary.BigEndian.Uint16(payload[0:2])
+		length := int(typeLen & 0x1ff)
+		if layers.LLDPTLVType(typeLen>>9) != layers.LLDPTLVOrgSpecific {
+			t.Fatal("Expected an org specific tlv, got type", typeLen>>9)
+		}
+		if len(payload) < 2+length {
+			t.Fatal("Truncated tlv value in payload", payload)
+		}
+		tlvs = append(tlvs, orgTLV(&layers.LinkLayerDiscoveryValue{
+			Type:   layers.LLDPTLVOrgSpecific,
+			Length: uint16(length),
+			Value:  payload[2 : 2+length],
+		}))
+		payload = payload[2+length:]
+	}
+	return tlvs
+}
+
+func TestOrgTLVEncodeDecode(t *testing.T) {
+	lag := LinkAggregation{
+		Supported: true,
+		Enabled:   true,
+		PortId:    100001,
+	}
+	macPhy := MacPhyConfigStatus{
+		AutonegSupported:  true,
+		AutonegEnabled:    true,
+		AutonegAdvertised: MAC_PHY_CAP_1000BASET_FD,
+		MauType:           MAU_TYPE_1000BASET_FD,
+	}
+	power := PowerViaMdi{
+		PortClassPSE: true,
+		Supported:    true,
+		Enabled:      true,
+		PairsControl: false,
+		PowerPair:    1,
+		PowerClass:   0,
+	}
+	ppvid := PortProtocolVlan{
+		Supported: true,
+		Enabled:   false,
+		Id:        20,
+	}
+	longName := strings.Repeat("v", LLDP_MAX_VLAN_NAME_LEN+8)
+	tlvs := []layers.LLDPOrgSpecificTLV{
+		orgTLV(EncodePortVlanIdTLV(10)),
+		orgTLV(EncodeProtocolVlanIdTLV(ppvid)),
+		orgTLV(EncodeVlanNameTLV(config.PortVlan{VlanId: 10, Name: "vlan10"})),
+		orgTLV(EncodeVlanNameTLV(config.PortVlan{VlanId: 4094, Name: longName})),
+		orgTLV(EncodeLinkAggregationTLV(lag)),
+		orgTLV(EncodeMacPhyTLV(macPhy)),
+		orgTLV(EncodePowerViaMdiTLV(power)),
+		orgTLV(EncodeMaxFrameSizeTLV(1522)),
+	}
+	dot1, dot3, unrecognized, discarded := DecodeOrgTLVs(tlvs)
+	if unrecognized != 0 || discarded != 0 {
+		t.Error("Expected no unrecognized or discarded tlv's, got", unrecognized, discarded)
+	}
+	if dot1.PortVlanId != 10 {
+		t.Error("Expected port vlan id 10, got", dot1.PortVlanId)
+	}
+	if !reflect.DeepEqual(dot1.ProtocolVlans, []PortProtocolVlan{ppvid}) {
+		t.Error("Expected protocol vlans", ppvid, "got", dot1.ProtocolVlans)
+	}
+	vlanNames := []VlanName{
+		{Id: 10, Name: "vlan10"},
+		{Id: 4094, Name: longName[:LLDP_MAX_VLAN_NAME_LEN]},
+	}
+	if !reflect.DeepEqual(dot1.VlanNames, vlanNames) {
+		t.Error("Expected vlan names", vlanNames, "got", dot1.VlanNames)
+	}
+	if dot1.LinkAggregation == nil || *dot1.LinkAggregation != lag {
+		t.Error("Expected link aggregation", lag, "got", dot1.LinkAggregation)
+	}
+	if dot3.MacPhy == nil || *dot3.MacPhy != macPhy {
+		t.Error("Expected mac/phy", macPhy, "got", dot3.MacPhy)
+	}
+	if dot3.Power == nil || *dot3.Power != power {
+		t.Error("Expected power via mdi", power, "got", dot3.Power)
+	}
+	if dot3.MaxFrameSize != 1522 {
+		t.Error("Expected max frame size 1522, got", dot3.MaxFrameSize)
+	}
+
+	// power class 4 is sent as 5
+	power.PowerClass = 4
+	tlv := EncodePowerViaMdiTLV(power)
+	if tlv.Value[len(tlv.Value)-1] != 5 {
+		t.Error("Expected power class 4 to be sent as 5, got", tlv.Value[len(tlv.Value)-1])
+	}
+	_, dot3, _, _ = DecodeOrgTLVs([]layers.LLDPOrgSpecificTLV{orgTLV(tlv)})
+	if dot3.Power == nil || dot3.Power.PowerClass != 4 {
+		t.Error("Expected power class 4, got", dot3.Power)
+	}
+}
+
+func TestOrgTLVDecodeUnrecognizedAndMalformed(t *testing.T) {
+	tlvs := []layers.LLDPOrgSpecificTLV{
+		// unknown 802.1 and 802.3 subtypes
+		{OUI: layers.IEEEOUI(LLDP_OUI_8021), SubType: 99, Info: []byte{1, 2}},
+		{OUI: layers.IEEEOUI(LLDP_OUI_8023), SubType: 99, Info: []byte{1, 2}},
+		// unknown organization
+		{OUI: layers.IEEEOUI(0x123456), SubType: 1, Info: []byte{1}},
+		// LLDP-MED is left to DecodeMedTLVs
+		orgTLV(EncodeMedInventoryTLV(LLDP_MED_SUBTYPE_MODEL_NAME, "phone")),
+		// too short
+		{OUI: layers.IEEEOUI(LLDP_OUI_8021), SubType: LLDP_8021_SUBTYPE_PORT_VLAN_ID, Info: []byte{1}},
+		{OUI: layers.IEEEOUI(LLDP_OUI_8023), SubType: LLDP_8023_SUBTYPE_MAC_PHY, Info: []byte{1, 2, 3}},
+		// vlan name length beyond the tlv
+		{OUI: layers.IEEEOUI(LLDP_OUI_8021), SubType: LLDP_8021_SUBTYPE_VLAN_NAME,
+			Info: []byte{0, 10, 8, 'v', 'l'}},
+	}
+	dot1, dot3, unrecognized, discarded := DecodeOrgTLVs(tlvs)
+	if unrecognized != 3 {
+		t.Error("Expected 3 unrecognized tlv's, got", unrecognized)
+	}
+	if discarded != 3 {
+		t.Error("Expected 3 discarded tlv's, got", discarded)
+	}
+	if dot1.PortVlanId != 0 || len(dot1.VlanNames) != 0 || dot3.MacPhy != nil {
+		t.Error("Expected malformed tlv's to be ignored, got", dot1, dot3)
+	}
+}
+
+func TestOrgTLVLinkAggregation8023(t *testing.T) {
+	lag8021 := LinkAggregation{Supported: true, Enabled: true, PortId: 1}
+	lag8023 := LinkAggregation{Supported: true, Enabled: false, PortId: 2}
+	// same information as the 802.1 tlv
+	tlv8023 := EncodeOrgTLV(LLDP_OUI_8023, LLDP_8023_SUBTYPE_LINK_AGGREGATION,
+		EncodeLinkAggregationTLV(lag8023).Value[4:])
+
+	// the deprecated 802.3 tlv is used when it is the only one received
+	dot1, _, _, _ := DecodeOrgTLVs([]layers.LLDPOrgSpecificTLV{orgTLV(tlv8023)})
+	if dot1.LinkAggregation == nil || *dot1.LinkAggregation != lag8023 {
+		t.Error("Expected link aggregation", lag8023, "got", dot1.LinkAggregation)
+	}
+	// otherwise the 802.1 tlv takes precedence
+	dot1, _, _, _ = DecodeOrgTLVs([]layers.LLDPOrgSpecificTLV{
+		orgTLV(EncodeLinkAggregationTLV(lag8021)),
+		orgTLV(tlv8023),
+	})
+	if dot1.LinkAggregation == nil || *dot1.LinkAggregation != lag8021 {
+		t.Error("Expected link aggregation", lag8021, "got", dot1.LinkAggregation)
+	}
+}
+
+func TestOrgPayload(t *testing.T) {
+	port := config.PortInfo{
+		IfIndex:    1,
+		Speed:      1000,
+		FullDuplex: true,
+		Autoneg:    true,
+		Mtu:        1500,
+		Pvid:       10,
+		Vlans: []config.PortVlan{
+			{VlanId: 10, Name: "vlan10"},
+			{VlanId: 20, Name: "vlan20"},
+		},
+		Lag: config.PortLag{
+			Enabled: true,
+			AggId:   100001,
+		},
+		Poe: &config.PortPoe{
+			Enabled:    true,
+			PowerPair:  1,
+			PowerClass: 3,
+		},
+	}
+	dot1, dot3, unrecognized, discarded := DecodeOrgTLVs(payloadOrgTLVs(t,
+		createOrgPayload(port, map[string]bool{})))
+	if unrecognized != 0 || discarded != 0 {
+		t.Error("Expected no unrecognized or discarded tlv's, got", unrecognized, discarded)
+	}
+	if dot1.PortVlanId != 10 {
+		t.Error("Expected port vlan id 10, got", dot1.PortVlanId)
+	}
+	if len(dot1.ProtocolVlans) != 1 || dot1.ProtocolVlans[0].Supported {
+		t.Error("Expected protocol vlans to be unsupported, got", dot1.ProtocolVlans)
+	}
+	if len(dot1.VlanNames) != 2 || dot1.VlanNames[1].Name != "vlan20" {
+		t.Error("Expected the names of vlans 10 and 20, got", dot1.VlanNames)
+	}
+	lag := LinkAggregation{Supported: true, Enabled: true, PortId: 100001}
+	if dot1.LinkAggregation == nil || *dot1.LinkAggregation != lag {
+		t.Error("Expected link aggregation", lag, "got", dot1.LinkAggregation)
+	}
+	if dot3.MacPhy == nil || *dot3.MacPhy != MacPhyFromPort(port) {
+		t.Error("Expected mac/phy", MacPhyFromPort(port), "got", dot3.MacPhy)
+	}
+	if dot3.Power == nil || !dot3.Power.PortClassPSE || dot3.Power.PowerClass != 3 {
+		t.Error("Expected a PSE of power class 3, got", dot3.Power)
+	}
+	if dot3.MaxFrameSize != 1500+LLDP_FRAME_OVERHEAD {
+		t.Error("Expected max frame size", 1500+LLDP_FRAME_OVERHEAD, "got", dot3.MaxFrameSize)
+	}
+
+	// disabled tlv's are not sent, nor is power via mdi by a port which
+	// does not supply power
+	port.Poe = nil
+	dot1, dot3, _, _ = DecodeOrgTLVs(payloadOrgTLVs(t,
+		createOrgPayload(port, map[string]bool{
+			config.TLV_VLAN_NAME:      true,
+			config.TLV_MAX_FRAME_SIZE: true,
+		})))
+	if len(dot1.VlanNames) != 0 {
+		t.Error("Expected no vlan names, got", dot1.VlanNames)
+	}
+	if dot3.MaxFrameSize != 0 {
+		t.Error("Expected no max frame size, got", dot3.MaxFrameSize)
+	}
+	if dot3.Power != nil {
+		t.Error("Expected no power via mdi, got", dot3.Power)
+	}
+	if dot1.PortVlanId != 10 || dot3.MacPhy == nil {
+		t.Error("Expected port vlan id and mac/phy to be sent, got", dot1, dot3)
+	}
+}
+
+func TestMacPhyFromPort(t *testing.T) {
+	macPhy := MacPhyFromPort(config.PortInfo{Speed: 100, Autoneg: true})
+	if macPhy.MauType != MAU_TYPE_100BASETX_HD ||
+		macPhy.AutonegAdvertised != MAC_PHY_CAP_100BASETX_HD {
+		t.Error("Expected 100BASE-TX half duplex, got", macPhy)
+	}
+	// nothing is advertised without auto-negotiation
+	macPhy = MacPhyFromPort(config.PortInfo{Speed: 10000, FullDuplex: true})
+	if macPhy.MauType != MAU_TYPE_10GBASER || macPhy.AutonegEnabled ||
+		macPhy.AutonegAdvertised != 0 {
+		t.Error("Expected 10GBASE-R without auto-negotiation, got", macPhy)
+	}
+}
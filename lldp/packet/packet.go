@@ -25,7 +25,9 @@ package packet
 
 import (
 	"github.com/google/gopacket/layers"
+	"l2/clock"
 	"net"
+	"sync"
 	"time"
 )
 
@@ -35,15 +37,59 @@ const (
 	LLDP_TOTAL_TLV_SUPPORTED = 8
 )
 
-type RX struct {
-	// ethernet frame Info (used for rx/tx)
-	SrcMAC net.HardwareAddr // NOTE: Please be informed this is Peer Mac Addr
-	DstMAC net.HardwareAddr
+// RemoteKey identifies a neighbor in the remote systems table of a port,
+// i.e. the chassis id and port id (MSAP identifier) of the neighbor
+type RemoteKey struct {
+	ChassisIdSubtype layers.LLDPChassisIDSubType
+	ChassisId        string
+	PortIdSubtype    layers.LLDPPortIDSubtype
+	PortId           string
+}
+
+// Neighbor is an entry of the remote systems table
+type Neighbor struct {
+	Key RemoteKey
+	// assigned in the order the neighbors are learned on the port
+	RemIndex int32
+	// NOTE: Please be informed this is Peer Mac Addr
+	SrcMAC net.HardwareAddr
 
 	// lldp rx information
 	RxFrame         *layers.LinkLayerDiscovery
 	RxLinkInfo      *layers.LinkLayerDiscoveryInfo
-	ClearCacheTimer *time.Timer
+	Dot1            Dot1Info
+	Dot3            Dot3Info
+	Med             MedInfo
+	LastUpdate      time.Time
+	ClearCacheTimer *clock.Timer
+}
+
+type RX struct {
+	// ethernet frame Info (used for rx/tx)
+	SrcMAC net.HardwareAddr // NOTE: Please be informed this is Peer Mac Addr of the last rx frame
+	DstMAC net.HardwareAddr
+
+	// remote systems table, entries are deleted by their own ttl timer
+	// which runs outside of the server go routine hence the lock, which
+	// also protects the statistics
+	neighborsMutex sync.Mutex
+	neighbors      map[RemoteKey]*Neighbor
+	nextRemIndex   int32
+	// max neighbors in the table, zero means no limit
+	MaxNeighbors int
+
+	// set while a new neighbor was dropped because the table is full,
+	// cleared once the ttl of the dropped neighbors expires
+	tooManyNeighbors       bool
+	tooManyNeighborsTimer  *clock.Timer
+	tooManyNeighborsExpiry time.Time
+
+	// statistics
+	stats         PortStats
+	remTableStats RemTableStats
+
+	// clock used by the ttl timers
+	clk clock.Clock
 }
 
 type TX struct {
@@ -54,5 +100,9 @@ type TX struct {
 	MessageTxHoldMultiplier int
 	useCacheFrame           bool
 	cacheFrame              []byte
-	TxTimer                 *time.Timer
+	TxTimer                 *clock.Timer
+	// LLDP-MED tlv's are sent once a MED endpoint is detected, the first
+	// frames being sent at the fast start interval
+	medEnabled        bool
+	medFastStartCount int
 }
@@ -0,0 +1,211 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// remote.go
+package packet
+
+import (
+	"fmt"
+	"github.com/google/gopacket/layers"
+	"l2/lldp/utils"
+	"net"
+	"sort"
+	"time"
+)
+
+type neighborsByRemIndex []Neighbor
+
+func (n neighborsByRemIndex) Len() int           { return len(n) }
+func (n neighborsByRemIndex) Swap(i, j int)      { n[i], n[j] = n[j], n[i] }
+func (n neighborsByRemIndex) Less(i, j int) bool { return n[i].RemIndex < n[j].RemIndex }
+
+func NewRemoteKey(frame *layers.LinkLayerDiscovery) RemoteKey {
+	return RemoteKey{
+		ChassisIdSubtype: frame.ChassisID.Subtype,
+		ChassisId:        string(frame.ChassisID.ID),
+		PortIdSubtype:    frame.PortID.Subtype,
+		PortId:           string(frame.PortID.ID),
+	}
+}
+
+/*  Learn or refresh the neighbor in the remote systems table and (re)start
+ *  its ttl timer. A frame with ttl zero (shutdown) deletes the neighbor. A
+ *  new neighbor is dropped when the table is full, tooManyNeighbors is then
+ *  set until the ttl of the dropped frame expires
+ */
+func (p *RX) UpdateNeighbor(srcMac net.HardwareAddr, frame *layers.LinkLayerDiscovery,
+	info *layers.LinkLayerDiscoveryInfo) *Neighbor {
+	key := NewRemoteKey(frame)
+
+	p.neighborsMutex.Lock()
+	defer p.neighborsMutex.Unlock()
+
+	nbr, exists := p.neighbors[key]
+	if frame.TTL == 0 {
+		p.stats.FramesInTotal++
+		if exists {
+			debug.Logger.Info(fmt.Sprintln("Shutdown frame received, deleting neighbor",
+				key.ChassisId, key.PortId))
+			p.deleteNeighborLocked(nbr)
+		}
+		return nil
+	}
+	if !exists {
+		if p.MaxNeighbors > 0 && len(p.neighbors) >= p.MaxNeighbors {
+			p.stats.FramesDiscardedTotal++
+			p.stats.TLVsDiscardedTotal += uint32(len(frame.Values))
+			p.remTableStats.Drops++
+			p.tooManyNeighborsLocked(frame.TTL)
+			return nil
+		}
+		p.nextRemIndex++
+		nbr = &Neighbor{
+			Key:      key,
+			RemIndex: p.nextRemIndex,
+		}
+		p.neighbors[key] = nbr
+		p.remTableStats.Inserts++
+		p.remTableChangedLocked()
+	}
+	dot1, dot3, unrecognized, discarded := DecodeOrgTLVs(info.OrgTLVs)
+	med, medUnrecognized, medDiscarded := DecodeMedTLVs(info.OrgTLVs)
+	p.stats.FramesInTotal++
+	p.stats.TLVsUnrecognizedTotal += uint32(len(info.Unknown)) + unrecognized + medUnrecognized
+	p.stats.TLVsDiscardedTotal += discarded + medDiscarded
+	// Store lldp frame information, new copies are made so that entries
+	// returned by NeighborsGet are never modified
+	nbr.SrcMAC = srcMac
+	nbr.RxFrame = new(layers.LinkLayerDiscovery)
+	*nbr.RxFrame = *frame
+	nbr.RxLinkInfo = new(layers.LinkLayerDiscoveryInfo)
+	*nbr.RxLinkInfo = *info
+	nbr.Dot1 = dot1
+	nbr.Dot3 = dot3
+	nbr.Med = med
+	nbr.LastUpdate = p.clk.Now()
+
+	ttl := time.Duration(frame.TTL) * time.Second
+	if nbr.ClearCacheTimer != nil {
+		// timer is running reset the time so that it doesn't expire
+		nbr.ClearCacheTimer.Reset(ttl)
+	} else {
+		// On timer expiration we will delete the neighbor, unless it was
+		// deleted and learned again in the meantime
+		nbr.ClearCacheTimer = p.clk.AfterFunc(ttl, func() {
+			p.neighborsMutex.Lock()
+			defer p.neighborsMutex.Unlock()
+			if cur, ok := p.neighbors[key]; ok && cur == nbr {
+				debug.Logger.Info(fmt.Sprintln("Recipient info delete timer expired for",
+					"neighbor", key.ChassisId, key.PortId,
+					"and hence deleting peer information from runtime"))
+				p.deleteNeighborLocked(nbr)
+				p.stats.AgeoutsTotal++
+				p.remTableStats.Ageouts++
+			}
+		})
+	}
+	return nbr
+}
+
+func (p *RX) deleteNeighborLocked(nbr *Neighbor) {
+	if nbr.ClearCacheTimer != nil {
+		nbr.ClearCacheTimer.Stop()
+	}
+	delete(p.neighbors, nbr.Key)
+	p.remTableStats.Deletes++
+	p.remTableChangedLocked()
+}
+
+func (p *RX) tooManyNeighborsLocked(ttl uint16) {
+	p.tooManyNeighbors = true
+	// the flag is kept until the longest ttl of the dropped frames expires
+	expiry := p.clk.Now().Add(time.Duration(ttl) * time.Second)
+	if p.tooManyNeighborsTimer != nil && !expiry.After(p.tooManyNeighborsExpiry) {
+		return
+	}
+	p.tooManyNeighborsExpiry = expiry
+	d := time.Duration(ttl) * time.Second
+	if p.tooManyNeighborsTimer != nil {
+		p.tooManyNeighborsTimer.Reset(d)
+		return
+	}
+	p.tooManyNeighborsTimer = p.clk.AfterFunc(d, func() {
+		p.neighborsMutex.Lock()
+		defer p.neighborsMutex.Unlock()
+		p.tooManyNeighbors = false
+	})
+}
+
+/*  Copy of the neighbors in the remote systems table ordered by RemIndex
+ */
+func (p *RX) NeighborsGet() []Neighbor {
+	p.neighborsMutex.Lock()
+	defer p.neighborsMutex.Unlock()
+	nbrs := make([]Neighbor, 0, len(p.neighbors))
+	for _, nbr := range p.neighbors {
+		nbrs = append(nbrs, *nbr)
+	}
+	sort.Sort(neighborsByRemIndex(nbrs))
+	return nbrs
+}
+
+func (p *RX) NeighborCount() int {
+	p.neighborsMutex.Lock()
+	defer p.neighborsMutex.Unlock()
+	return len(p.neighbors)
+}
+
+/*  Any of the neighbors is a MED endpoint
+ */
+func (p *RX) MedEndpointDetected() bool {
+	p.neighborsMutex.Lock()
+	defer p.neighborsMutex.Unlock()
+	for _, nbr := range p.neighbors {
+		if nbr.Med.IsEndpoint() {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *RX) TooManyNeighbors() bool {
+	p.neighborsMutex.Lock()
+	defer p.neighborsMutex.Unlock()
+	return p.tooManyNeighbors
+}
+
+/*  Delete all neighbors and stop their ttl timers, used when rx stops on the
+ *  port
+ */
+func (p *RX) ClearNeighbors() {
+	p.neighborsMutex.Lock()
+	defer p.neighborsMutex.Unlock()
+	for _, nbr := range p.neighbors {
+		p.deleteNeighborLocked(nbr)
+	}
+	if p.tooManyNeighborsTimer != nil {
+		p.tooManyNeighborsTimer.Stop()
+	}
+	p.tooManyNeighbors = false
+	p.tooManyNeighborsExpiry = time.Time{}
+}
@@ -0,0 +1,268 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// remote_test.go
+package packet
+
+import (
+	"github.com/google/gopacket/layers"
+	"l2/clock"
+	"l2/lldp/utils"
+	"net"
+	"testing"
+	"time"
+	"utils/logging"
+)
+
+var testSrcMac = net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+
+// the ttl timers log when a neighbor is deleted
+func testRxInit(maxNeighbors int) (*RX, *clock.ManualClock) {
+	if debug.Logger == nil {
+		logger, _ := logging.NewLogger("lldpd", "LLDP", false)
+		debug.SetLogger(logger)
+	}
+	clk := clock.NewManualClock(time.Now())
+	return RxInit(clk, maxNeighbors), clk
+}
+
+func testFrame(chassisId string, portId string, ttl uint16,
+	orgTLVs ...*layers.LinkLayerDiscoveryValue) (*layers.LinkLayerDiscovery,
+	*layers.LinkLayerDiscoveryInfo) {
+	frame := &layers.LinkLayerDiscovery{
+		ChassisID: layers.LLDPChassisID{
+			Subtype: layers.LLDPChassisIDSubTypeLocal,
+			ID:      []byte(chassisId),
+		},
+		PortID: layers.LLDPPortID{
+			Subtype: layers.LLDPPortIDSubtypeIfaceName,
+			ID:      []byte(portId),
+		},
+		TTL: ttl,
+	}
+	frame.Values = []layers.LinkLayerDiscoveryValue{
+		{Type: layers.LLDPTLVChassisID},
+		{Type: layers.LLDPTLVPortID},
+		{Type: layers.LLDPTLVTTL},
+	}
+	info := &layers.LinkLayerDiscoveryInfo{}
+	for _, tlv := range orgTLVs {
+		frame.Values = append(frame.Values, *tlv)
+		info.OrgTLVs = append(info.OrgTLVs, orgTLV(tlv))
+	}
+	return frame, info
+}
+
+func testUpdate(rx *RX, chassisId string, portId string, ttl uint16) *Neighbor {
+	frame, info := testFrame(chassisId, portId, ttl)
+	return rx.UpdateNeighbor(testSrcMac, frame, info)
+}
+
+func TestRemoteTableAging(t *testing.T) {
+	rx, clk := testRxInit(0)
+	start := clk.Now()
+
+	nbr := testUpdate(rx, "chassis1", "fpPort1", 10)
+	if nbr == nil || nbr.RemIndex != 1 {
+		t.Fatal("Expected neighbor to be learned with RemIndex 1, got", nbr)
+	}
+	remStats := rx.RemTableStatsGet()
+	if remStats.Inserts != 1 || !remStats.LastChangeTime.Equal(start) {
+		t.Error("Expected 1 insert at", start, "got", remStats)
+	}
+
+	// refreshing the neighbor restarts its ttl
+	clk.Advance(5 * time.Second)
+	testUpdate(rx, "chassis1", "fpPort1", 10)
+	nbrs := rx.NeighborsGet()
+	if len(nbrs) != 1 || !nbrs[0].LastUpdate.Equal(start.Add(5*time.Second)) {
+		t.Error("Expected neighbor to be refreshed at", start.Add(5*time.Second), "got", nbrs)
+	}
+	if rx.RemTableStatsGet().Inserts != 1 {
+		t.Error("Expected a refresh not to be counted as an insert")
+	}
+	clk.Advance(6 * time.Second)
+	if rx.NeighborCount() != 1 {
+		t.Error("Expected refreshed neighbor not to age out after its first ttl")
+	}
+
+	// ttl timers expire from the clock Advance
+	clk.Advance(4 * time.Second)
+	if rx.NeighborCount() != 0 {
+		t.Fatal("Expected neighbor to age out, got", rx.NeighborsGet())
+	}
+	stats := rx.StatsGet()
+	if stats.FramesInTotal != 2 || stats.AgeoutsTotal != 1 {
+		t.Error("Expected 2 frames in and 1 ageout, got", stats)
+	}
+	remStats = rx.RemTableStatsGet()
+	if remStats.Ageouts != 1 || remStats.Deletes != 1 ||
+		!remStats.LastChangeTime.Equal(start.Add(15*time.Second)) {
+		t.Error("Expected 1 ageout and delete at", start.Add(15*time.Second), "got", remStats)
+	}
+	if clk.Pending() != 0 {
+		t.Error("Expected no timers running, got", clk.Pending())
+	}
+
+	// a neighbor learned again gets a new RemIndex
+	nbr = testUpdate(rx, "chassis1", "fpPort1", 10)
+	if nbr == nil || nbr.RemIndex != 2 {
+		t.Error("Expected neighbor to be learned again with RemIndex 2, got", nbr)
+	}
+}
+
+func TestRemoteTableShutdown(t *testing.T) {
+	rx, clk := testRxInit(0)
+
+	// shutdown of an unknown neighbor
+	if nbr := testUpdate(rx, "chassis1", "fpPort1", 0); nbr != nil {
+		t.Error("Expected shutdown frame not to learn a neighbor, got", nbr)
+	}
+	testUpdate(rx, "chassis1", "fpPort1", 120)
+	testUpdate(rx, "chassis1", "fpPort2", 120)
+	if rx.NeighborCount() != 2 {
+		t.Fatal("Expected 2 neighbors, got", rx.NeighborCount())
+	}
+
+	if nbr := testUpdate(rx, "chassis1", "fpPort1", 0); nbr != nil {
+		t.Error("Expected shutdown frame to return no neighbor, got", nbr)
+	}
+	nbrs := rx.NeighborsGet()
+	if len(nbrs) != 1 || nbrs[0].Key.PortId != "fpPort2" {
+		t.Error("Expected only neighbor fpPort2 to be left, got", nbrs)
+	}
+	if clk.Pending() != 1 {
+		t.Error("Expected the ttl timer of the deleted neighbor to be stopped, pending",
+			clk.Pending())
+	}
+	stats := rx.StatsGet()
+	if stats.FramesInTotal != 4 || stats.AgeoutsTotal != 0 {
+		t.Error("Expected 4 frames in and no ageouts, got", stats)
+	}
+	remStats := rx.RemTableStatsGet()
+	if remStats.Inserts != 2 || remStats.Deletes != 1 || remStats.Ageouts != 0 {
+		t.Error("Expected 2 inserts, 1 delete and no ageouts, got", remStats)
+	}
+}
+
+func TestRemoteTableTooManyNeighbors(t *testing.T) {
+	rx, clk := testRxInit(2)
+
+	testUpdate(rx, "chassis1", "fpPort1", 120)
+	testUpdate(rx, "chassis2", "fpPort1", 120)
+	if rx.TooManyNeighbors() {
+		t.Error("Expected a full table not to set too many neighbors")
+	}
+	if nbr := testUpdate(rx, "chassis3", "fpPort1", 30); nbr != nil {
+		t.Error("Expected neighbor to be dropped when the table is full, got", nbr)
+	}
+	if !rx.TooManyNeighbors() {
+		t.Error("Expected too many neighbors after a drop")
+	}
+	stats := rx.StatsGet()
+	if stats.FramesInTotal != 2 || stats.FramesDiscardedTotal != 1 ||
+		stats.TLVsDiscardedTotal != 3 {
+		t.Error("Expected 2 frames in and 1 frame with 3 tlv's discarded, got", stats)
+	}
+	remStats := rx.RemTableStatsGet()
+	if remStats.Inserts != 2 || remStats.Drops != 1 {
+		t.Error("Expected 2 inserts and 1 drop, got", remStats)
+	}
+
+	// known neighbors are still refreshed
+	if nbr := testUpdate(rx, "chassis1", "fpPort1", 120); nbr == nil {
+		t.Error("Expected known neighbor to be refreshed when the table is full")
+	}
+
+	// the flag is kept until the longest ttl of the dropped frames expires
+	testUpdate(rx, "chassis4", "fpPort1", 60)
+	testUpdate(rx, "chassis5", "fpPort1", 10)
+	clk.Advance(30 * time.Second)
+	if !rx.TooManyNeighbors() {
+		t.Error("Expected too many neighbors until the ttl of 60 seconds expires")
+	}
+	clk.Advance(30 * time.Second)
+	if rx.TooManyNeighbors() {
+		t.Error("Expected too many neighbors to be cleared once the ttl expires")
+	}
+	if rx.NeighborCount() != 2 || rx.RemTableStatsGet().Drops != 3 {
+		t.Error("Expected 2 neighbors and 3 drops, got", rx.NeighborCount(),
+			rx.RemTableStatsGet())
+	}
+
+	rx.ClearNeighbors()
+	if rx.NeighborCount() != 0 || rx.RemTableStatsGet().Deletes != 2 {
+		t.Error("Expected all neighbors to be deleted, got", rx.NeighborsGet())
+	}
+	if clk.Pending() != 0 {
+		t.Error("Expected no timers running after clear, got", clk.Pending())
+	}
+}
+
+func TestRemoteStats(t *testing.T) {
+	rx, _ := testRxInit(0)
+
+	frame, info := testFrame("chassis1", "fpPort1", 120,
+		EncodePortVlanIdTLV(10),
+		EncodeMedCapabilitiesTLV(MedCapabilities{
+			Supported:  LLDP_MED_CAP_CAPABILITIES,
+			DeviceType: LLDP_MED_DEVICE_ENDPOINT_CLASS_I,
+		}),
+		// unknown 802.1 and MED subtypes
+		EncodeOrgTLV(LLDP_OUI_8021, 99, []byte{1}),
+		EncodeOrgTLV(LLDP_OUI_TIA, 99, []byte{1}),
+		// malformed max frame size
+		EncodeOrgTLV(LLDP_OUI_8023, LLDP_8023_SUBTYPE_MAX_FRAME_SIZE, []byte{1}))
+	info.Unknown = []layers.LinkLayerDiscoveryValue{{Type: 100}}
+	nbr := rx.UpdateNeighbor(testSrcMac, frame, info)
+	if nbr == nil || nbr.Dot1.PortVlanId != 10 || !nbr.Med.IsEndpoint() {
+		t.Fatal("Expected neighbor with port vlan id 10 and MED endpoint, got", nbr)
+	}
+	if !rx.MedEndpointDetected() {
+		t.Error("Expected MED endpoint to be detected")
+	}
+	rx.CountFrameOut()
+	stats := rx.StatsGet()
+	expected := PortStats{
+		FramesOutTotal:        1,
+		FramesInTotal:         1,
+		TLVsDiscardedTotal:    1,
+		TLVsUnrecognizedTotal: 3,
+	}
+	if stats != expected {
+		t.Error("Expected stats", expected, "got", stats)
+	}
+
+	rx.ClearStats()
+	if stats = rx.StatsGet(); stats != (PortStats{}) {
+		t.Error("Expected stats to be cleared, got", stats)
+	}
+	rx.ClearRemTableStats()
+	if remStats := rx.RemTableStatsGet(); remStats != (RemTableStats{}) {
+		t.Error("Expected remote table stats to be cleared, got", remStats)
+	}
+	// clearing the stats leaves the table alone
+	if rx.NeighborCount() != 1 {
+		t.Error("Expected neighbor to be kept, got", rx.NeighborCount())
+	}
+}
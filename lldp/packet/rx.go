@@ -29,14 +29,18 @@ import (
 	"github.com/google/gopacket"
 	"github.com/google/gopacket/layers"
 	_ "github.com/google/gopacket/pcap"
+	"l2/clock"
 	"l2/lldp/utils"
 	"net"
-	"time"
 )
 
-func RxInit() *RX {
+func RxInit(clk clock.Clock, maxNeighbors int) *RX {
 	var err error
-	rxInfo := &RX{}
+	rxInfo := &RX{
+		clk:          clk,
+		neighbors:    make(map[RemoteKey]*Neighbor),
+		MaxNeighbors: maxNeighbors,
+	}
 	rxInfo.DstMAC, err = net.ParseMAC(LLDP_PROTO_DST_MAC)
 	if err != nil {
 		debug.Logger.Err(fmt.Sprintln("parsing lldp protocol Mac failed",
@@ -65,66 +69,51 @@ func (p *RX) VerifyFrame(lldpInfo *layers.LinkLayerDiscovery) error {
 	return nil
 }
 
-func (p *RX) Process(gblInfo *RX, pkt gopacket.Packet) error {
+/*  Process the received frame and update the remote systems table. The
+ *  neighbor which was learned or refreshed is returned, nil is returned when
+ *  the neighbor was deleted by a shutdown frame or the frame was dropped
+ *  because the table is full
+ */
+func (p *RX) Process(pkt gopacket.Packet) (*Neighbor, error) {
+	srcMac, frame, info, err := p.decodeFrame(pkt)
+	if err != nil {
+		p.countFrameInError(frame)
+		return nil, err
+	}
+	return p.UpdateNeighbor(srcMac, frame, info), nil
+}
+
+/*  Decode the lldp layers of the frame, the lldp layer is returned along with
+ *  the error when only the verification failed
+ */
+func (p *RX) decodeFrame(pkt gopacket.Packet) (net.HardwareAddr,
+	*layers.LinkLayerDiscovery, *layers.LinkLayerDiscoveryInfo, error) {
 	ethernetLayer := pkt.Layer(layers.LayerTypeEthernet)
 	if ethernetLayer == nil {
-		return errors.New("Invalid eth layer")
+		return nil, nil, nil, errors.New("Invalid eth layer")
 	}
 	eth := ethernetLayer.(*layers.Ethernet)
 	// copy src mac and dst mac
-	gblInfo.SrcMAC = eth.SrcMAC
-	if gblInfo.DstMAC.String() != eth.DstMAC.String() {
-		return errors.New("Invalid DST MAC in rx frame")
+	p.SrcMAC = eth.SrcMAC
+	if p.DstMAC.String() != eth.DstMAC.String() {
+		return nil, nil, nil, errors.New("Invalid DST MAC in rx frame")
 	}
 	// Get lldp manadatory layer and optional info
 	lldpLayer := pkt.Layer(layers.LayerTypeLinkLayerDiscovery)
 	lldpLayerInfo := pkt.Layer(layers.LayerTypeLinkLayerDiscoveryInfo)
 	// Verify that the information is not nil
-	if lldpLayer == nil || lldpLayerInfo == nil {
-		return errors.New("Invalid Frame")
+	if lldpLayer == nil {
+		return nil, nil, nil, errors.New("Invalid Frame")
+	}
+	frame := lldpLayer.(*layers.LinkLayerDiscovery)
+	if lldpLayerInfo == nil {
+		return nil, frame, nil, errors.New("Invalid Frame")
 	}
 
 	// Verify that the mandatory layer info is indeed correct
-	err := p.VerifyFrame(lldpLayer.(*layers.LinkLayerDiscovery))
+	err := p.VerifyFrame(frame)
 	if err != nil {
-		return err
-	}
-	if gblInfo.RxFrame == nil {
-		gblInfo.RxFrame = new(layers.LinkLayerDiscovery)
-	}
-	// Store lldp frame information received from direct connection
-	*gblInfo.RxFrame = *lldpLayer.(*layers.LinkLayerDiscovery)
-
-	if gblInfo.RxLinkInfo == nil {
-		gblInfo.RxLinkInfo = new(layers.LinkLayerDiscoveryInfo)
-	}
-	// Store lldp link layer optional tlv information
-	*gblInfo.RxLinkInfo = *lldpLayerInfo.(*layers.LinkLayerDiscoveryInfo)
-	return nil
-}
-
-/*
- *  Handle TTL timer. Once the timer expires, we will delete the remote entry
- *  if timer is running then reset the value
- */
-func (gblInfo *RX) CheckPeerEntry(port string) {
-	if gblInfo.ClearCacheTimer != nil {
-		// timer is running reset the time so that it doesn't expire
-		gblInfo.ClearCacheTimer.Reset(time.Duration(
-			gblInfo.RxFrame.TTL) * time.Second)
-	} else {
-		var clearPeerInfo_func func()
-		// On timer expiration we will delete peer info and set it to nil
-		clearPeerInfo_func = func() {
-			debug.Logger.Info("Recipient info delete timer expired for " +
-				"peer connected to port " + port +
-				" and hence deleting peer information from runtime")
-			gblInfo.RxFrame = nil
-			gblInfo.RxLinkInfo = nil
-		}
-		// First time start function
-		gblInfo.ClearCacheTimer = time.AfterFunc(
-			time.Duration(gblInfo.RxFrame.TTL)*time.Second,
-			clearPeerInfo_func)
+		return nil, frame, nil, err
 	}
+	return eth.SrcMAC, frame, lldpLayerInfo.(*layers.LinkLayerDiscoveryInfo), nil
 }
@@ -0,0 +1,101 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// stats.go
+package packet
+
+import (
+	"github.com/google/gopacket/layers"
+	"time"
+)
+
+// PortStats are the 802.1AB statistics of a port
+type PortStats struct {
+	FramesOutTotal        uint32
+	FramesInTotal         uint32
+	FramesInErrorsTotal   uint32
+	FramesDiscardedTotal  uint32
+	TLVsDiscardedTotal    uint32
+	TLVsUnrecognizedTotal uint32
+	AgeoutsTotal          uint32
+}
+
+// RemTableStats count the changes to the remote systems table of a port,
+// summed up over all the ports they are the global statistics
+type RemTableStats struct {
+	LastChangeTime time.Time
+	Inserts        uint32
+	// Deletes includes the ageouts
+	Deletes uint32
+	Drops   uint32
+	Ageouts uint32
+}
+
+/*  Count a frame which was sent out on the port
+ */
+func (p *RX) CountFrameOut() {
+	p.neighborsMutex.Lock()
+	defer p.neighborsMutex.Unlock()
+	p.stats.FramesOutTotal++
+}
+
+/*  Count a frame which failed validation, frame is nil when the lldp layer
+ *  could not be decoded
+ */
+func (p *RX) countFrameInError(frame *layers.LinkLayerDiscovery) {
+	p.neighborsMutex.Lock()
+	defer p.neighborsMutex.Unlock()
+	p.stats.FramesInErrorsTotal++
+	p.stats.FramesDiscardedTotal++
+	if frame != nil {
+		p.stats.TLVsDiscardedTotal += uint32(len(frame.Values))
+	}
+}
+
+func (p *RX) remTableChangedLocked() {
+	p.remTableStats.LastChangeTime = p.clk.Now()
+}
+
+func (p *RX) StatsGet() PortStats {
+	p.neighborsMutex.Lock()
+	defer p.neighborsMutex.Unlock()
+	return p.stats
+}
+
+func (p *RX) RemTableStatsGet() RemTableStats {
+	p.neighborsMutex.Lock()
+	defer p.neighborsMutex.Unlock()
+	return p.remTableStats
+}
+
+func (p *RX) ClearStats() {
+	p.neighborsMutex.Lock()
+	defer p.neighborsMutex.Unlock()
+	p.stats = PortStats{}
+}
+
+func (p *RX) ClearRemTableStats() {
+	p.neighborsMutex.Lock()
+	defer p.neighborsMutex.Unlock()
+	p.remTableStats = RemTableStats{}
+}
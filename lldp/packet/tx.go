@@ -26,7 +26,6 @@ package packet
 import (
 	"encoding/binary"
 	_ "encoding/json"
-	"errors"
 	"fmt"
 	"github.com/google/gopacket"
 	"github.com/google/gopacket/layers"
@@ -34,8 +33,18 @@ import (
 	"l2/lldp/utils"
 	"models"
 	"net"
+	"time"
 )
 
+// name of the optional tlv's which may be disabled
+var optionalTLVNames = map[layers.LLDPTLVType]string{
+	layers.LLDPTLVPortDescription: config.TLV_PORT_DESCRIPTION,
+	layers.LLDPTLVSysName:         config.TLV_SYSTEM_NAME,
+	layers.LLDPTLVSysDescription:  config.TLV_SYSTEM_DESCRIPTION,
+	layers.LLDPTLVSysCapabilities: config.TLV_SYSTEM_CAPABILITIES,
+	layers.LLDPTLVMgmtAddress:     config.TLV_MANAGEMENT_ADDRESS,
+}
+
 func Min(x, y int) int {
 	if x < y {
 		return x
@@ -74,8 +83,10 @@ func TxInit(interval, hold int) *TX {
  *  A new frame will be constructed:
  *		1) if it is first time send
  *		2) if there is config object update
+ *  LLDP-MED tlv's are only added when med is not nil
  */
-func (gblInfo *TX) SendFrame(port config.PortInfo, sysInfo *models.SystemParam) []byte {
+func (gblInfo *TX) SendFrame(port config.PortInfo, sysInfo *models.SystemParam,
+	sysCaps config.SystemCapabilities, disabledTLVs map[string]bool, med *MedTxInfo) []byte {
 	temp := make([]byte, 0)
 	// if cached then directly send the packet
 	if gblInfo.useCacheFrame {
@@ -87,7 +98,7 @@ func (gblInfo *TX) SendFrame(port config.PortInfo, sysInfo *models.SystemParam)
 		// Chassis ID: Mac Address of Port
 		// Port ID: Port Name
 		// TTL: calculated during port init default is 30 * 4 = 120
-		payload := gblInfo.createPayload(srcmac, port, sysInfo)
+		payload := gblInfo.createPayload(srcmac, port, sysInfo, sysCaps, disabledTLVs, med)
 		if payload == nil {
 			debug.Logger.Err(fmt.Sprintln("Creating payload failed for port", port))
 			gblInfo.useCacheFrame = false
@@ -95,7 +106,6 @@ func (gblInfo *TX) SendFrame(port config.PortInfo, sysInfo *models.SystemParam)
 		}
 		// Additional TLV's... @TODO: get it done later on
 		// System information... like "show version" command at Cisco
-		// System Capabilites...
 
 		// Construct ethernet information
 		eth := &layers.Ethernet{
@@ -127,9 +137,11 @@ func (gblInfo *TX) SendFrame(port config.PortInfo, sysInfo *models.SystemParam)
 	}
 }
 
-/*  helper function to create payload from lldp frame struct
+/*  helper function to create payload from lldp frame struct, the optional
+ *  tlv's in disabledTLVs are not added
  */
-func (gblInfo *TX) createPayload(srcmac []byte, port config.PortInfo, sysInfo *models.SystemParam) []byte {
+func (gblInfo *TX) createPayload(srcmac []byte, port config.PortInfo, sysInfo *models.SystemParam,
+	sysCaps config.SystemCapabilities, disabledTLVs map[string]bool, med *MedTxInfo) []byte {
 	var payload []byte
 	var err error
 	tlvType := layers.LLDPTLVChassisID // start with chassis id always
@@ -141,6 +153,10 @@ func (gblInfo *TX) createPayload(srcmac []byte, port config.PortInfo, sysInfo *m
 				"Mandatory TLV's")
 			break
 		}
+		if name, optional := optionalTLVNames[tlvType]; optional && disabledTLVs[name] {
+			tlvType++
+			continue
+		}
 		tlv := &layers.LinkLayerDiscoveryValue{}
 		switch tlvType {
 		case layers.LLDPTLVChassisID: // Chassis ID
@@ -176,7 +192,9 @@ func (gblInfo *TX) createPayload(srcmac []byte, port config.PortInfo, sysInfo *m
 			debug.Logger.Info(fmt.Sprintln("System Name", tlv))
 
 		case layers.LLDPTLVSysCapabilities:
-			err = errors.New("Tlv not supported")
+			tlv.Type = layers.LLDPTLVSysCapabilities
+			tlv.Value = EncodeSysCapabilitiesTLV(sysCaps)
+			debug.Logger.Info(fmt.Sprintln("System Capabilities", tlv))
 
 		case layers.LLDPTLVMgmtAddress:
 			/*
@@ -204,6 +222,12 @@ func (gblInfo *TX) createPayload(srcmac []byte, port config.PortInfo, sysInfo *m
 		err = nil
 		tlvType++
 	}
+	if sysInfo != nil {
+		payload = append(payload, createOrgPayload(port, disabledTLVs)...)
+		if med != nil {
+			payload = append(payload, createMedPayload(port, med, disabledTLVs)...)
+		}
+	}
 
 	// After all TLV's are added we need to go ahead and Add LLDPTLVEnd
 	tlv := &layers.LinkLayerDiscoveryValue{}
@@ -265,6 +289,18 @@ func EncodeMgmtTLV(tlv *layers.LLDPMgmtAddress) []byte {
 	return b
 }
 
+/*  TLV Type = 7
+ *  Value: 4 bytes
+ *     System Capabilities uint16
+ *     Enabled Capabilities uint16
+ */
+func EncodeSysCapabilitiesTLV(sysCaps config.SystemCapabilities) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint16(b[0:2], sysCaps.Supported)
+	binary.BigEndian.PutUint16(b[2:4], sysCaps.Enabled)
+	return b
+}
+
 func (t *TX) UseCache() bool {
 	return t.useCacheFrame
 }
@@ -273,6 +309,45 @@ func (t *TX) SetCache(use bool) {
 	t.useCacheFrame = use
 }
 
+func (t *TX) MedEnabled() bool {
+	return t.medEnabled
+}
+
+/*  Start or stop sending the LLDP-MED tlv's, the frame is built again on next
+ *  send
+ */
+func (t *TX) SetMedEnabled(enable bool) {
+	t.medEnabled = enable
+	if !enable {
+		t.medFastStartCount = 0
+	}
+	t.useCacheFrame = false
+}
+
+/*  A MED endpoint was detected, start sending the LLDP-MED tlv's and send the
+ *  next frames at the fast start interval.  Must be called from the server go
+ *  routine which sends the frames and restarts the tx timer
+ */
+func (t *TX) StartMedFastStart() {
+	t.SetMedEnabled(true)
+	// the first fast start frame is sent on the timer reset below
+	t.medFastStartCount = LLDP_MED_FAST_START_REPEAT_COUNT - 1
+	if t.TxTimer != nil {
+		t.TxTimer.Reset(LLDP_MED_FAST_START_INTERVAL)
+	}
+}
+
+/*  Interval until the next frame is sent, fast start frames are sent every
+ *  second
+ */
+func (t *TX) NextTxInterval() time.Duration {
+	if t.medFastStartCount > 0 {
+		t.medFastStartCount--
+		return LLDP_MED_FAST_START_INTERVAL
+	}
+	return time.Duration(t.MessageTxInterval) * time.Second
+}
+
 /*  We have deleted the pcap handler and hence we will invalid the cache buffer
  */
 func (gblInfo *TX) DeleteCacheFrame() {
@@ -0,0 +1,229 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// tx_test.go
+package packet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/google/gopacket/layers"
+	"l2/lldp/config"
+	"l2/lldp/utils"
+	"models"
+	"net"
+	"reflect"
+	"testing"
+	"utils/logging"
+)
+
+// tx logs every tlv which is added to the frame
+func testTxInit() *TX {
+	if debug.Logger == nil {
+		logger, _ := logging.NewLogger("lldpd", "LLDP", false)
+		debug.SetLogger(logger)
+	}
+	return TxInit(30, 4)
+}
+
+// split an encoded payload into tlv's, the end tlv is included
+func payloadTLVs(t *testing.T, payload []byte) []layers.LinkLayerDiscoveryValue {
+	var tlvs []layers.LinkLayerDiscoveryValue
+	for len(payload) > 0 {
+		if len(payload) < 2 {
+			t.Fatal("Truncated tlv header in payload", payload)
+		}
+		typeLen := binary.BigEndian.Uint16(payload[0:2])
+		length := int(typeLen & 0x1ff)
+		if len(payload) < 2+length {
+			t.Fatal("Truncated tlv value in payload", payload)
+		}
+		tlvs = append(tlvs, layers.LinkLayerDiscoveryValue{
+			Type:   layers.LLDPTLVType(typeLen >> 9),
+			Length: uint16(length),
+			Value:  payload[2 : 2+length],
+		})
+		payload = payload[2+length:]
+	}
+	return tlvs
+}
+
+func TestSysCapabilitiesTLV(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		supported uint16
+		enabled   uint16
+		value     []byte
+	}{
+		{"none", 0, 0, []byte{0x00, 0x00, 0x00, 0x00}},
+		{"bridge", config.SYS_CAP_BRIDGE, config.SYS_CAP_BRIDGE,
+			[]byte{0x00, 0x04, 0x00, 0x04}},
+		{"router", config.SYS_CAP_ROUTER, config.SYS_CAP_ROUTER,
+			[]byte{0x00, 0x10, 0x00, 0x10}},
+		{"bridge and router",
+			config.SYS_CAP_BRIDGE | config.SYS_CAP_ROUTER,
+			config.SYS_CAP_BRIDGE | config.SYS_CAP_ROUTER,
+			[]byte{0x00, 0x14, 0x00, 0x14}},
+		{"router supported but not enabled",
+			config.SYS_CAP_BRIDGE | config.SYS_CAP_ROUTER,
+			config.SYS_CAP_BRIDGE,
+			[]byte{0x00, 0x14, 0x00, 0x04}},
+		{"other and wlan access point",
+			config.SYS_CAP_OTHER | config.SYS_CAP_WLAN_AP,
+			config.SYS_CAP_WLAN_AP,
+			[]byte{0x00, 0x09, 0x00, 0x08}},
+	} {
+		sysCaps := config.SystemCapabilities{
+			Supported: test.supported,
+			Enabled:   test.enabled,
+		}
+		if value := EncodeSysCapabilitiesTLV(sysCaps); !bytes.Equal(value, test.value) {
+			t.Error("Expected", test.name, "system capabilities", test.value, "got", value)
+		}
+	}
+}
+
+func TestCreatePayloadTLVSelection(t *testing.T) {
+	tx := testTxInit()
+	port := config.PortInfo{
+		IfIndex:     1,
+		Name:        "fpPort1",
+		MacAddr:     "00:11:22:33:44:55",
+		Description: "uplink",
+		Pvid:        1,
+	}
+	sysInfo := &models.SystemParam{
+		Hostname:    "switch1",
+		Description: "snaproute flexswitch",
+		MgmtIp:      "10.1.1.1",
+	}
+	sysCaps := config.SystemCapabilities{
+		Supported: config.SYS_CAP_BRIDGE | config.SYS_CAP_ROUTER,
+		Enabled:   config.SYS_CAP_BRIDGE,
+	}
+	srcmac, _ := net.ParseMAC(port.MacAddr)
+	mandatory := []layers.LLDPTLVType{
+		layers.LLDPTLVChassisID,
+		layers.LLDPTLVPortID,
+		layers.LLDPTLVTTL,
+	}
+	all := append(append([]layers.LLDPTLVType{}, mandatory...),
+		layers.LLDPTLVPortDescription,
+		layers.LLDPTLVSysName,
+		layers.LLDPTLVSysDescription,
+		layers.LLDPTLVSysCapabilities,
+		layers.LLDPTLVMgmtAddress)
+
+	for _, test := range []struct {
+		name     string
+		sysInfo  *models.SystemParam
+		disabled map[string]bool
+		// basic tlv's sent before the org specific and end tlv's
+		tlvs []layers.LLDPTLVType
+	}{
+		{"all enabled", sysInfo, nil, all},
+		{"system description disabled", sysInfo,
+			map[string]bool{config.TLV_SYSTEM_DESCRIPTION: true},
+			append(append([]layers.LLDPTLVType{}, mandatory...),
+				layers.LLDPTLVPortDescription,
+				layers.LLDPTLVSysName,
+				layers.LLDPTLVSysCapabilities,
+				layers.LLDPTLVMgmtAddress)},
+		{"system capabilities and management address disabled", sysInfo,
+			map[string]bool{
+				config.TLV_SYSTEM_CAPABILITIES: true,
+				config.TLV_MANAGEMENT_ADDRESS:  true,
+			},
+			append(append([]layers.LLDPTLVType{}, mandatory...),
+				layers.LLDPTLVPortDescription,
+				layers.LLDPTLVSysName,
+				layers.LLDPTLVSysDescription)},
+		{"all optional disabled", sysInfo,
+			map[string]bool{
+				config.TLV_PORT_DESCRIPTION:    true,
+				config.TLV_SYSTEM_NAME:         true,
+				config.TLV_SYSTEM_DESCRIPTION:  true,
+				config.TLV_SYSTEM_CAPABILITIES: true,
+				config.TLV_MANAGEMENT_ADDRESS:  true,
+			},
+			mandatory},
+		{"org specific disabled", sysInfo,
+			map[string]bool{config.TLV_PORT_VLAN_ID: true},
+			all},
+		{"no system information", nil, nil, mandatory},
+	} {
+		var tlvs []layers.LLDPTLVType
+		orgTLVs := 0
+		var sysCapsValue []byte
+		payload := tx.createPayload(srcmac, port, test.sysInfo,
+			sysCaps, test.disabled, nil)
+		decoded := payloadTLVs(t, payload)
+		for i, tlv := range decoded {
+			switch tlv.Type {
+			case layers.LLDPTLVEnd:
+				if i != len(decoded)-1 {
+					t.Error("Expected", test.name, "end tlv to be last, got", i, len(decoded))
+				}
+			case layers.LLDPTLVOrgSpecific:
+				orgTLVs++
+			default:
+				if orgTLVs != 0 {
+					t.Error("Expected", test.name, "basic tlv's before org specific, got", tlv.Type)
+				}
+				if tlv.Type == layers.LLDPTLVSysCapabilities {
+					sysCapsValue = tlv.Value
+				}
+				tlvs = append(tlvs, tlv.Type)
+			}
+		}
+		if decoded[len(decoded)-1].Type != layers.LLDPTLVEnd {
+			t.Error("Expected", test.name, "payload to end with the end tlv")
+		}
+		if !reflect.DeepEqual(tlvs, test.tlvs) {
+			t.Error("Expected", test.name, "tlv's", test.tlvs, "got", tlvs)
+		}
+		if sysCapsValue != nil &&
+			!bytes.Equal(sysCapsValue, EncodeSysCapabilitiesTLV(sysCaps)) {
+			t.Error("Expected", test.name, "system capabilities", sysCaps, "got", sysCapsValue)
+		}
+
+		// the system description is not found anywhere in the frame
+		// when it is suppressed
+		described := bytes.Contains(payload, []byte(sysInfo.Description))
+		expectDescribed := test.sysInfo != nil &&
+			!test.disabled[config.TLV_SYSTEM_DESCRIPTION]
+		if described != expectDescribed {
+			t.Error("Expected", test.name, "system description sent", expectDescribed, "got", described)
+		}
+
+		// org specific tlv's are only sent along with the system
+		// information
+		expectOrg := 0
+		if test.sysInfo != nil {
+			expectOrg = len(payloadTLVs(t, createOrgPayload(port, test.disabled)))
+		}
+		if orgTLVs != expectOrg {
+			t.Error("Expected", test.name, expectOrg, "org specific tlv's, got", orgTLVs)
+		}
+	}
+}
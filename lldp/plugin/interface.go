@@ -38,6 +38,8 @@ type ConfigIntf interface {
 
 type SystemIntf interface {
 	Start()
+	GetSystemCapabilities() config.SystemCapabilities
+	GetInventory() config.Inventory
 	/*
 		GetSwitchMac() string
 		GetDescription() string
@@ -69,11 +69,39 @@ func (svr *LLDPServer) readLLDPIntfConfig() {
 		case false:
 			gblInfo.Disable()
 		}
+		gblInfo.disabledTLVs = dbEntry.DisabledTLVs
+		gblInfo.medLocationElin = dbEntry.MedLocationElin
 		svr.lldpGblInfo[dbEntry.IfIndex] = gblInfo
 	}
 	debug.Logger.Info("Done with LLDPIntf")
 }
 
+func (svr *LLDPServer) readLLDPMedNetworkPolicyConfig() {
+	debug.Logger.Info("Reading LLDPMedNetworkPolicy from db")
+	var dbObj models.LLDPMedNetworkPolicy
+	objList, err := svr.lldpDbHdl.GetAllObjFromDb(dbObj)
+	if err != nil {
+		debug.Logger.Err(fmt.Sprintln("DB querry faile for LLDPMedNetworkPolicy Config", err))
+	}
+	for _, obj := range objList {
+		dbEntry := obj.(models.LLDPMedNetworkPolicy)
+		gblInfo, _ := svr.lldpGblInfo[dbEntry.IfIndex]
+		if gblInfo.medPolicies == nil {
+			gblInfo.medPolicies = make(map[string]config.MedNetworkPolicy)
+		}
+		gblInfo.medPolicies[dbEntry.Application] = config.MedNetworkPolicy{
+			IfIndex:     dbEntry.IfIndex,
+			Application: dbEntry.Application,
+			VlanId:      dbEntry.VlanId,
+			Tagged:      dbEntry.Tagged,
+			Priority:    dbEntry.Priority,
+			Dscp:        dbEntry.Dscp,
+		}
+		svr.lldpGblInfo[dbEntry.IfIndex] = gblInfo
+	}
+	debug.Logger.Info("Done with LLDPMedNetworkPolicy")
+}
+
 func (svr *LLDPServer) readLLDPGlobalConfig() {
 	debug.Logger.Info("Reading LLDPGlobal from db")
 	var dbObj models.LLDPGlobal
@@ -91,6 +119,7 @@ func (svr *LLDPServer) readLLDPGlobalConfig() {
 		}
 		svr.Global.Vrf = dbEntry.Vrf
 		svr.Global.Enable = dbEntry.Enable
+		svr.Global.DisabledTLVs = dbEntry.DisabledTLVs
 	}
 	debug.Logger.Info("Done with LLDPGlobal")
 }
@@ -102,5 +131,6 @@ func (svr *LLDPServer) ReadDB() error {
 	}
 	svr.readLLDPGlobalConfig()
 	svr.readLLDPIntfConfig()
+	svr.readLLDPMedNetworkPolicyConfig()
 	return nil
 }
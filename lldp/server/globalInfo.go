@@ -25,10 +25,11 @@ package server
 
 import (
 	"github.com/google/gopacket"
-	"github.com/google/gopacket/pcap"
+	"l2/clock"
 	"l2/lldp/config"
 	"l2/lldp/packet"
 	"l2/lldp/plugin"
+	"l2/pktio"
 	"models"
 	"time"
 	"utils/dbutils"
@@ -46,18 +47,24 @@ type SendPktChannel struct {
 type LLDPGlobalInfo struct {
 	// Port information
 	Port config.PortInfo
-	// Pcap Handler for Each Port
-	PcapHandle *pcap.Handle
+	// Packet I/O Handler for Each Port
+	PktHandle pktio.Handle
 	// rx information
 	RxInfo *packet.RX
 	// tx information
 	TxInfo *packet.TX
 	// State info
 	enable bool
+	// optional tlv's which are not sent on the port, on top of the
+	// globally disabled tlv's
+	disabledTLVs []string
+	// LLDP-MED network policies keyed by application and location sent to
+	// the MED endpoints
+	medPolicies     map[string]config.MedNetworkPolicy
+	medLocationElin string
 
 	// Go Routine Killer Channels
 	RxKill chan bool
-	TxDone chan bool
 }
 
 type LLDPServer struct {
@@ -80,11 +87,18 @@ type LLDPServer struct {
 	lldpIntfStateSlice   []int32
 	lldpUpIntfStateSlice []int32
 
-	// lldp pcap handler default config values
+	// lldp packet I/O handler default config values
+	lldpPktIoType   string
 	lldpSnapshotLen int32
 	lldpPromiscuous bool
 	lldpTimeout     time.Duration
 
+	// clock driving the tx and ttl timers
+	lldpClock clock.Clock
+
+	// max neighbors in the remote systems table of each port
+	lldpMaxNeighbors int
+
 	// lldp packet rx channel
 	lldpRxPktCh chan InPktChannel
 	// lldp send packet channel
@@ -95,6 +109,13 @@ type LLDPServer struct {
 	IntfCfgCh chan *config.Intf
 	// lldp asic notification channel
 	IfStateCh chan *config.PortState
+	// lldp port vlan membership notification channel
+	IfVlanCh chan *config.PortVlans
+	// lldp port lag membership notification channel
+	IfLagCh chan *config.PortLag
+	// lldp-med network policy config channels
+	MedPolicyCfgCh chan *config.MedNetworkPolicy
+	MedPolicyDelCh chan *config.MedNetworkPolicy
 	// Update Cache notification channel
 	UpdateCacheCh chan bool
 
@@ -118,5 +139,6 @@ const (
 	LLDP_BPF_FILTER                 = "ether proto 0x88cc"
 	LLDP_DEFAULT_TX_INTERVAL        = 30
 	LLDP_DEFAULT_TX_HOLD_MULTIPLIER = 4
+	LLDP_DEFAULT_MAX_NEIGHBORS      = 8
 	LLDP_MIN_FRAME_LENGTH           = 12 // this is 12 bytes
 )
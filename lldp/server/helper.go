@@ -27,13 +27,13 @@ import (
 	"errors"
 	"fmt"
 	"github.com/google/gopacket/layers"
-	"github.com/google/gopacket/pcap"
+	"l2/clock"
 	"l2/lldp/config"
 	"l2/lldp/packet"
 	"l2/lldp/utils"
+	"l2/pktio"
 	"models"
 	"net"
-	"time"
 )
 
 func Min(x, y int) int {
@@ -52,29 +52,27 @@ func Max(x, y int) int {
 
 /*  Init l2 port information for global runtime information
  */
-func (gblInfo *LLDPGlobalInfo) InitRuntimeInfo(portConf *config.PortInfo) {
+func (gblInfo *LLDPGlobalInfo) InitRuntimeInfo(portConf *config.PortInfo, clk clock.Clock, maxNeighbors int) {
 	gblInfo.Port = *portConf
-	gblInfo.RxInfo = packet.RxInit()
+	gblInfo.RxInfo = packet.RxInit(clk, maxNeighbors)
 	gblInfo.TxInfo = packet.TxInit(LLDP_DEFAULT_TX_INTERVAL, LLDP_DEFAULT_TX_HOLD_MULTIPLIER)
 	gblInfo.RxKill = make(chan bool)
-	gblInfo.TxDone = make(chan bool)
 }
 
 /*  De-Init l2 port information
  */
 func (gblInfo *LLDPGlobalInfo) DeInitRuntimeInfo() {
 	gblInfo.StopCacheTimer()
-	gblInfo.DeletePcapHandler()
-	gblInfo.FreeDynamicMemory()
+	gblInfo.DeletePktHandler()
 }
 
-/*  Delete l2 port pcap handler
+/*  Delete l2 port packet I/O handler
  */
-func (gblInfo *LLDPGlobalInfo) DeletePcapHandler() {
-	if gblInfo.PcapHandle != nil {
-		// @FIXME: some bug in close handling that causes 5 mins delay
-		gblInfo.PcapHandle.Close()
-		gblInfo.PcapHandle = nil
+func (gblInfo *LLDPGlobalInfo) DeletePktHandler() {
+	if gblInfo.PktHandle != nil {
+		// @FIXME: some bug in pcap close handling that causes 5 mins delay
+		gblInfo.PktHandle.Close()
+		gblInfo.PktHandle = nil
 	}
 }
 
@@ -102,38 +100,25 @@ func (gblInfo *LLDPGlobalInfo) isEnabled() bool {
 	return gblInfo.enable
 }
 
-/*  Stop RX cache timer
+/*  Stop RX cache timer of all the neighbors and delete them
  */
 func (gblInfo *LLDPGlobalInfo) StopCacheTimer() {
-	if gblInfo.RxInfo.ClearCacheTimer == nil {
+	if gblInfo.RxInfo == nil {
 		return
 	}
-	gblInfo.RxInfo.ClearCacheTimer.Stop()
+	gblInfo.RxInfo.ClearNeighbors()
 }
 
-/*  Return back all the memory which was allocated using new
+/*  Create Packet I/O Handler, the backend applies the LLDP BPF filter
  */
-func (gblInfo *LLDPGlobalInfo) FreeDynamicMemory() {
-	gblInfo.RxInfo.RxFrame = nil
-	gblInfo.RxInfo.RxLinkInfo = nil
-}
-
-/*  Create Pcap Handler
- */
-func (gblInfo *LLDPGlobalInfo) CreatePcapHandler(lldpSnapshotLen int32,
-	lldpPromiscuous bool, lldpTimeout time.Duration) error {
-	pcapHdl, err := pcap.OpenLive(gblInfo.Port.Name, lldpSnapshotLen, lldpPromiscuous, lldpTimeout)
+func (gblInfo *LLDPGlobalInfo) CreatePktHandler(cfg pktio.Config) error {
+	pktHdl, err := pktio.Open(gblInfo.Port.Name, cfg)
 	if err != nil {
-		debug.Logger.Err(fmt.Sprintln("Creating Pcap Handler failed for", gblInfo.Port.Name, "Error:", err))
-		return errors.New("Creating Pcap Failed")
+		debug.Logger.Err(fmt.Sprintln("Creating", cfg.Type, "Handler failed for", gblInfo.Port.Name,
+			"filter", cfg.Filter, "Error:", err))
+		return errors.New("Creating Packet Handler Failed")
 	}
-	err = pcapHdl.SetBPFFilter(LLDP_BPF_FILTER)
-	if err != nil {
-		debug.Logger.Err(fmt.Sprintln("setting filter", LLDP_BPF_FILTER, "for", gblInfo.Port.Name,
-			"failed with error:", err))
-		return errors.New("Setting BPF Filter Failed")
-	}
-	gblInfo.PcapHandle = pcapHdl
+	gblInfo.PktHandle = pktHdl
 	return nil
 }
 
@@ -141,10 +126,10 @@ func (gblInfo *LLDPGlobalInfo) CreatePcapHandler(lldpSnapshotLen int32,
  *	 Based on SubType Return the string, mac address then form string using
  *	 net package
  */
-func (gblInfo *LLDPGlobalInfo) GetChassisIdInfo() string {
+func (gblInfo *LLDPGlobalInfo) GetChassisIdInfo(nbr *packet.Neighbor) string {
 
 	retVal := ""
-	switch gblInfo.RxInfo.RxFrame.ChassisID.Subtype {
+	switch nbr.RxFrame.ChassisID.Subtype {
 	case layers.LLDPChassisIDSubTypeReserved:
 		debug.Logger.Debug("Need to handle this case")
 	case layers.LLDPChassisIDSubTypeChassisComp:
@@ -155,7 +140,7 @@ func (gblInfo *LLDPGlobalInfo) GetChassisIdInfo() string {
 		debug.Logger.Debug("Need to handle this case")
 	case layers.LLDPChassisIDSubTypeMACAddr:
 		var mac net.HardwareAddr
-		mac = gblInfo.RxInfo.RxFrame.ChassisID.ID
+		mac = nbr.RxFrame.ChassisID.ID
 		return mac.String()
 	case layers.LLDPChassisIDSubTypeNetworkAddr:
 		debug.Logger.Debug("Need to handle this case")
@@ -174,10 +159,10 @@ func (gblInfo *LLDPGlobalInfo) GetChassisIdInfo() string {
  *	 Based on SubType Return the string, mac address then form string using
  *	 net package
  */
-func (gblInfo *LLDPGlobalInfo) GetPortIdInfo() string {
+func (gblInfo *LLDPGlobalInfo) GetPortIdInfo(nbr *packet.Neighbor) string {
 
 	retVal := ""
-	switch gblInfo.RxInfo.RxFrame.PortID.Subtype {
+	switch nbr.RxFrame.PortID.Subtype {
 	case layers.LLDPPortIDSubtypeReserved:
 		debug.Logger.Debug("Need to handle this case")
 	case layers.LLDPPortIDSubtypeIfaceAlias:
@@ -186,12 +171,12 @@ func (gblInfo *LLDPGlobalInfo) GetPortIdInfo() string {
 		debug.Logger.Debug("Need to handle this case")
 	case layers.LLDPPortIDSubtypeMACAddr:
 		var mac net.HardwareAddr
-		mac = gblInfo.RxInfo.RxFrame.ChassisID.ID
+		mac = nbr.RxFrame.PortID.ID
 		return mac.String()
 	case layers.LLDPPortIDSubtypeNetworkAddr:
 		debug.Logger.Debug("Need to handle this case")
 	case layers.LLDPPortIDSubtypeIfaceName:
-		return string(gblInfo.RxInfo.RxFrame.PortID.ID)
+		return string(nbr.RxFrame.PortID.ID)
 	case layers.LLDPPortIDSubtypeAgentCircuitID:
 		debug.Logger.Debug("Need to handle this case")
 	case layers.LLDPPortIDSubtypeLocal:
@@ -205,18 +190,18 @@ func (gblInfo *LLDPGlobalInfo) GetPortIdInfo() string {
 
 /*  dump received lldp frame and other TX information
  */
-func (gblInfo LLDPGlobalInfo) DumpFrame() {
+func (gblInfo LLDPGlobalInfo) DumpFrame(nbr *packet.Neighbor) {
 	debug.Logger.Debug(fmt.Sprintln("L2 Port:", gblInfo.Port.IfIndex, "Port IfIndex:",
 		gblInfo.Port.IfIndex))
-	debug.Logger.Debug(fmt.Sprintln("SrcMAC:", gblInfo.RxInfo.SrcMAC.String(),
+	debug.Logger.Debug(fmt.Sprintln("SrcMAC:", nbr.SrcMAC.String(),
 		"DstMAC:", gblInfo.RxInfo.DstMAC.String()))
 	debug.Logger.Debug(fmt.Sprintln("ChassisID info is",
-		gblInfo.RxInfo.RxFrame.ChassisID))
+		nbr.RxFrame.ChassisID))
 	debug.Logger.Debug(fmt.Sprintln("PortID info is",
-		gblInfo.RxInfo.RxFrame.PortID))
-	debug.Logger.Debug(fmt.Sprintln("TTL info is", gblInfo.RxInfo.RxFrame.TTL))
+		nbr.RxFrame.PortID))
+	debug.Logger.Debug(fmt.Sprintln("TTL info is", nbr.RxFrame.TTL))
 	debug.Logger.Debug(fmt.Sprintln("Optional Values is",
-		gblInfo.RxInfo.RxLinkInfo))
+		nbr.RxLinkInfo))
 }
 
 /*  Api used to get entry.. This is mainly used by LLDP Server API Layer when it get config from
@@ -227,6 +212,43 @@ func (svr *LLDPServer) EntryExist(ifIndex int32) bool {
 	return exists
 }
 
+/*  Api to get the current config of the interface
+ */
+func (svr *LLDPServer) GetIntfConfig(ifIndex int32) (config.Intf, bool) {
+	var entry config.Intf
+	gblInfo, exists := svr.lldpGblInfo[ifIndex]
+	if !exists {
+		return entry, false
+	}
+	entry.IfIndex = ifIndex
+	entry.Enable = gblInfo.isEnabled()
+	entry.DisabledTLVs = gblInfo.disabledTLVs
+	entry.MedLocationElin = gblInfo.medLocationElin
+	return entry, true
+}
+
+/*  Api to get the current MED network policy of the application on the
+ *  interface
+ */
+func (svr *LLDPServer) GetMedNetworkPolicy(ifIndex int32, application string) (config.MedNetworkPolicy, bool) {
+	gblInfo, exists := svr.lldpGblInfo[ifIndex]
+	if !exists {
+		return config.MedNetworkPolicy{}, false
+	}
+	policy, exists := gblInfo.medPolicies[application]
+	return policy, exists
+}
+
+/*  Api to get the current global config
+ */
+func (svr *LLDPServer) GetGlobalConfig(vrf string) (config.Global, bool) {
+	if svr.Global == nil ||
+		svr.Global.Vrf != vrf {
+		return config.Global{}, false
+	}
+	return *svr.Global, true
+}
+
 /*  Api to get System information used for TX Frame
  */
 func (svr *LLDPServer) GetSystemInfo() {
@@ -263,6 +285,13 @@ func (svr *LLDPServer) GetSystemInfo() {
 func (svr *LLDPServer) UpdateCache() {
 	// set sysInfo to nil
 	svr.SysInfo = nil
+	svr.InvalidateTxCache()
+}
+
+/*  Api to build the frame again on next send, without reading the system
+ *  information again
+ */
+func (svr *LLDPServer) InvalidateTxCache() {
 	for _, ifIndex := range svr.lldpUpIntfStateSlice {
 		gblInfo, exists := svr.lldpGblInfo[ifIndex]
 		if !exists {
@@ -271,3 +300,21 @@ func (svr *LLDPServer) UpdateCache() {
 		gblInfo.TxInfo.SetCache(false)
 	}
 }
+
+/*  Optional tlv's which are not sent on the port, disabled either globally or
+ *  on the port
+ */
+func (svr *LLDPServer) GetDisabledTLVs(ifIndex int32) map[string]bool {
+	disabledTLVs := make(map[string]bool)
+	if svr.Global != nil {
+		for _, tlv := range svr.Global.DisabledTLVs {
+			disabledTLVs[tlv] = true
+		}
+	}
+	if gblInfo, exists := svr.lldpGblInfo[ifIndex]; exists {
+		for _, tlv := range gblInfo.disabledTLVs {
+			disabledTLVs[tlv] = true
+		}
+	}
+	return disabledTLVs
+}
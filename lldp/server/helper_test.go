@@ -0,0 +1,104 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// helper_test.go
+package server
+
+import (
+	"bytes"
+	"l2/lldp/config"
+	"l2/lldp/packet"
+	"l2/lldp/utils"
+	"models"
+	"reflect"
+	"testing"
+	"utils/logging"
+)
+
+func TestGetDisabledTLVs(t *testing.T) {
+	if debug.Logger == nil {
+		logger, _ := logging.NewLogger("lldpd", "LLDP", false)
+		debug.SetLogger(logger)
+	}
+	port := config.PortInfo{
+		IfIndex: 1,
+		Name:    "fpPort1",
+		MacAddr: "00:11:22:33:44:55",
+	}
+	sysInfo := &models.SystemParam{
+		Hostname:    "switch1",
+		Description: "snaproute flexswitch",
+		MgmtIp:      "10.1.1.1",
+	}
+	for _, test := range []struct {
+		name     string
+		global   *config.Global
+		intf     []string
+		ifIndex  int32
+		disabled map[string]bool
+	}{
+		{"nothing disabled", &config.Global{}, nil, 1, map[string]bool{}},
+		{"no global config", nil,
+			[]string{config.TLV_SYSTEM_NAME}, 1,
+			map[string]bool{config.TLV_SYSTEM_NAME: true}},
+		{"disabled globally",
+			&config.Global{DisabledTLVs: []string{config.TLV_SYSTEM_DESCRIPTION}}, nil, 1,
+			map[string]bool{config.TLV_SYSTEM_DESCRIPTION: true}},
+		{"disabled on the port",
+			&config.Global{}, []string{config.TLV_SYSTEM_DESCRIPTION}, 1,
+			map[string]bool{config.TLV_SYSTEM_DESCRIPTION: true}},
+		{"disabled globally and on the port",
+			&config.Global{DisabledTLVs: []string{config.TLV_SYSTEM_DESCRIPTION, config.TLV_VLAN_NAME}},
+			[]string{config.TLV_SYSTEM_DESCRIPTION, config.TLV_MANAGEMENT_ADDRESS}, 1,
+			map[string]bool{
+				config.TLV_SYSTEM_DESCRIPTION: true,
+				config.TLV_VLAN_NAME:          true,
+				config.TLV_MANAGEMENT_ADDRESS: true,
+			}},
+		{"other port",
+			&config.Global{DisabledTLVs: []string{config.TLV_VLAN_NAME}},
+			[]string{config.TLV_SYSTEM_DESCRIPTION}, 2,
+			map[string]bool{config.TLV_VLAN_NAME: true}},
+	} {
+		svr := &LLDPServer{
+			Global: test.global,
+			lldpGblInfo: map[int32]LLDPGlobalInfo{
+				1: {Port: port, disabledTLVs: test.intf},
+			},
+		}
+		disabled := svr.GetDisabledTLVs(test.ifIndex)
+		if !reflect.DeepEqual(disabled, test.disabled) {
+			t.Error("Expected", test.name, "disabled tlv's", test.disabled, "got", disabled)
+		}
+
+		// the system description is suppressed from the frame sent on the
+		// port
+		frame := packet.TxInit(30, 4).SendFrame(port, sysInfo,
+			config.SystemCapabilities{}, disabled, nil)
+		described := bytes.Contains(frame, []byte(sysInfo.Description))
+		if described == test.disabled[config.TLV_SYSTEM_DESCRIPTION] {
+			t.Error("Expected", test.name, "system description sent",
+				!test.disabled[config.TLV_SYSTEM_DESCRIPTION], "got", described)
+		}
+	}
+}
@@ -26,18 +26,16 @@ package server
 import (
 	"errors"
 	"fmt"
-	"github.com/google/gopacket"
-	"github.com/google/gopacket/pcap"
 	"l2/lldp/utils"
+	"l2/pktio"
 	"time"
 )
 
 /* Go routine to recieve lldp frames. This go routine is created for all the
  * ports which are in up state.
  */
-func (svr *LLDPServer) ReceiveFrames(pHandle *pcap.Handle, ifIndex int32) {
-	pktSrc := gopacket.NewPacketSource(pHandle, pHandle.LinkType())
-	in := pktSrc.Packets()
+func (svr *LLDPServer) ReceiveFrames(pHandle pktio.Handle, ifIndex int32) {
+	in := pHandle.Packets()
 	// process packets
 	gblInfo, exists := svr.lldpGblInfo[ifIndex]
 	if !exists {
@@ -78,7 +76,7 @@ func (svr *LLDPServer) ReceiveFrames(pHandle *pcap.Handle, ifIndex int32) {
 			// 2) during os exit
 			// Because this is read we do not need to worry about
 			// doing any locks...
-			if gblInfo.PcapHandle == nil {
+			if gblInfo.PktHandle == nil {
 				debug.Logger.Info("Pcap closed terminate go routine for " + gblInfo.Port.Name)
 				return
 			}
@@ -94,7 +92,8 @@ func (svr *LLDPServer) ReceiveFrames(pHandle *pcap.Handle, ifIndex int32) {
 }
 
 /*  lldp server go routine to handle tx timer... once the timer fires we will
-*  send the ifindex on the channel to handle send info
+*  send the ifindex on the channel to handle send info, the timer is started
+*  again by the server once the frame is sent
  */
 func (svr *LLDPServer) TransmitFrames(ifIndex int32) {
 	var TxTimerHandler_func func()
@@ -102,16 +101,6 @@ func (svr *LLDPServer) TransmitFrames(ifIndex int32) {
 		svr.lldpTxPktCh <- SendPktChannel{
 			ifIndex: ifIndex,
 		}
-		gblInfo, exists := svr.lldpGblInfo[ifIndex]
-		if !exists {
-			debug.Logger.Info(fmt.Sprintln("No Entry for", ifIndex, "terminate go routine"))
-			return
-		}
-		// Wait until the packet is send out on the wire... Once done then reset the timer and
-		// update global info again
-		<-gblInfo.TxDone
-		gblInfo.TxInfo.TxTimer.Reset(time.Duration(gblInfo.TxInfo.MessageTxInterval) * time.Second)
-		svr.lldpGblInfo[ifIndex] = gblInfo
 	}
 	// Create an After Func and go routine for it, so that on timer stop TX is stopped automatically
 	gblInfo, exists := svr.lldpGblInfo[ifIndex]
@@ -120,7 +109,7 @@ func (svr *LLDPServer) TransmitFrames(ifIndex int32) {
 		return
 	}
 
-	gblInfo.TxInfo.TxTimer = time.AfterFunc(time.Duration(gblInfo.TxInfo.MessageTxInterval)*time.Second,
+	gblInfo.TxInfo.TxTimer = svr.lldpClock.AfterFunc(time.Duration(gblInfo.TxInfo.MessageTxInterval)*time.Second,
 		TxTimerHandler_func)
 	svr.lldpGblInfo[ifIndex] = gblInfo
 }
@@ -135,10 +124,10 @@ func (gblInfo *LLDPGlobalInfo) WritePacket(pkt []byte) bool {
 	if len(pkt) == 0 {
 		return false
 	}
-	if gblInfo.PcapHandle != nil {
-		err = gblInfo.PcapHandle.WritePacketData(pkt)
+	if gblInfo.PktHandle != nil {
+		err = gblInfo.PktHandle.WritePacketData(pkt)
 	} else {
-		err = errors.New("Pcap Handle is invalid for " + gblInfo.Port.Name)
+		err = errors.New("Packet Handle is invalid for " + gblInfo.Port.Name)
 	}
 	if err != nil {
 		debug.Logger.Err(fmt.Sprintln("Sending packet failed Error:",
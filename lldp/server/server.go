@@ -25,9 +25,12 @@ package server
 
 import (
 	"fmt"
+	"l2/clock"
 	"l2/lldp/config"
+	"l2/lldp/packet"
 	"l2/lldp/plugin"
 	"l2/lldp/utils"
+	"l2/pktio"
 	_ "models"
 	"os"
 	"os/signal"
@@ -65,20 +68,59 @@ func (svr *LLDPServer) InitGlobalDS() {
 	svr.lldpRxPktCh = make(chan InPktChannel, LLDP_RX_PKT_CHANNEL_SIZE)
 	svr.lldpTxPktCh = make(chan SendPktChannel, LLDP_TX_PKT_CHANNEL_SIZE)
 	svr.lldpExit = make(chan bool)
+	svr.lldpPktIoType = pktio.PktIoTypePcap
 	svr.lldpSnapshotLen = 1024
 	svr.lldpPromiscuous = false
 	// LLDP Notifications are atleast 5 seconds apart with default being
 	// 30 seconds. So, we can have the leavrage the pcap timeout (read from
 	// buffer) to be 1 second.
 	svr.lldpTimeout = 1 * time.Second
+	svr.lldpClock = clock.NewRealClock()
+	svr.lldpMaxNeighbors = LLDP_DEFAULT_MAX_NEIGHBORS
 	svr.GblCfgCh = make(chan *config.Global)
 	svr.IntfCfgCh = make(chan *config.Intf)
 	svr.IfStateCh = make(chan *config.PortState)
+	svr.IfVlanCh = make(chan *config.PortVlans)
+	svr.IfLagCh = make(chan *config.PortLag)
+	svr.MedPolicyCfgCh = make(chan *config.MedNetworkPolicy)
+	svr.MedPolicyDelCh = make(chan *config.MedNetworkPolicy)
 	svr.UpdateCacheCh = make(chan bool)
 
 	// All Plugin Info
 }
 
+/* Select the packet I/O backend used for ports started after this call
+ */
+func (svr *LLDPServer) SetPktIoType(pktIoType string) {
+	svr.lldpPktIoType = pktIoType
+}
+
+/* Select the clock used by the tx and ttl timers of ports started after
+ * this call, tests use a clock.ManualClock to step the timers
+ */
+func (svr *LLDPServer) SetClock(clk clock.Clock) {
+	svr.lldpClock = clk
+}
+
+/* Limit the neighbors learned per port of ports started after this call,
+ * zero means no limit
+ */
+func (svr *LLDPServer) SetMaxNeighbors(maxNeighbors int) {
+	svr.lldpMaxNeighbors = maxNeighbors
+}
+
+/* Packet I/O config used when starting rx/tx on a port
+ */
+func (svr *LLDPServer) PktIoConfigGet() pktio.Config {
+	return pktio.Config{
+		Type:        svr.lldpPktIoType,
+		SnapLen:     svr.lldpSnapshotLen,
+		Promiscuous: svr.lldpPromiscuous,
+		Timeout:     svr.lldpTimeout,
+		Filter:      LLDP_BPF_FILTER,
+	}
+}
+
 /* De-Allocate memory to all the object which are being used by LLDP server
  */
 func (svr *LLDPServer) DeInitGlobalDS() {
@@ -113,7 +155,7 @@ func (svr *LLDPServer) CloseAllPktHandlers() {
  */
 func (svr *LLDPServer) InitL2PortInfo(portInfo *config.PortInfo) {
 	gblInfo, _ := svr.lldpGblInfo[portInfo.IfIndex]
-	gblInfo.InitRuntimeInfo(portInfo)
+	gblInfo.InitRuntimeInfo(portInfo, svr.lldpClock, svr.lldpMaxNeighbors)
 	svr.lldpGblInfo[portInfo.IfIndex] = gblInfo
 
 	// Only start rx/tx if, Globally LLDP is enabled, Interface LLDP is enabled and port is in UP state
@@ -143,6 +185,14 @@ func (svr *LLDPServer) LLDPStartServer(paramsDir string) {
 		svr.ReadDB()
 	}
 	// Get Port Information from Asic, only after reading from DB
+	svr.LLDPStartPorts()
+}
+
+/*  Start rx/tx on the ports learned from the asic plugin and the go routine
+ *  to handle all the channels. The simulator which has no DB calls this
+ *  directly
+ */
+func (svr *LLDPServer) LLDPStartPorts() {
 	portsInfo := svr.asicPlugin.GetPortsInfo()
 	for _, port := range portsInfo {
 		svr.InitL2PortInfo(port) // is it a bug for starting rx/tx before channel handler??
@@ -200,15 +250,15 @@ func (svr *LLDPServer) StartRxTx(ifIndex int32) {
 	if svr.Global.Enable == false {
 		return
 	}
-	if gblInfo.PcapHandle != nil {
+	if gblInfo.PktHandle != nil {
 		debug.Logger.Info("Pcap already exist means the port changed it states")
 		// Move the port to up state and continue
 		svr.lldpUpIntfStateSlice = append(svr.lldpUpIntfStateSlice, gblInfo.Port.IfIndex)
 		return // returning because the go routine is already up and running for the port
 	}
-	err := gblInfo.CreatePcapHandler(svr.lldpSnapshotLen, svr.lldpPromiscuous, svr.lldpTimeout)
+	err := gblInfo.CreatePktHandler(svr.PktIoConfigGet())
 	if err != nil {
-		debug.Logger.Alert("Creating Pcap Handler for " + gblInfo.Port.Name +
+		debug.Logger.Alert("Creating Packet Handler for " + gblInfo.Port.Name +
 			" failed and hence we will not start LLDP on the port")
 		return
 	}
@@ -217,7 +267,7 @@ func (svr *LLDPServer) StartRxTx(ifIndex int32) {
 		strconv.Itoa(int(gblInfo.Port.IfIndex)))
 
 	// Everything set up, so now lets start with receiving frames and transmitting frames go routine...
-	go svr.ReceiveFrames(gblInfo.PcapHandle, ifIndex)
+	go svr.ReceiveFrames(gblInfo.PktHandle, ifIndex)
 	svr.TransmitFrames(ifIndex)
 	svr.lldpUpIntfStateSlice = append(svr.lldpUpIntfStateSlice, gblInfo.Port.IfIndex)
 }
@@ -243,9 +293,12 @@ func (svr *LLDPServer) StopRxTx(ifIndex int32) {
 	// stop the timer
 	gblInfo.TxInfo.StopTxTimer()
 	// Delete Pcap Handler
-	gblInfo.DeletePcapHandler()
+	gblInfo.DeletePktHandler()
 	// invalid the cache information
 	gblInfo.TxInfo.DeleteCacheFrame()
+	// neighbors are learned again once rx is started
+	gblInfo.StopCacheTimer()
+	gblInfo.TxInfo.SetMedEnabled(false)
 	//gblInfo.killerWaitGroup.Add(2)
 	svr.lldpGblInfo[ifIndex] = gblInfo
 	svr.DeletePortFromUpState(ifIndex)
@@ -356,22 +409,141 @@ func (svr *LLDPServer) handleIntfConfig(ifIndex int32, enable bool) {
 	}
 }
 
+/*  handle the optional tlv's disabled on the port, the frame is built again
+ *  on next send
+ */
+func (svr *LLDPServer) handleIntfTLVConfig(ifIndex int32, disabledTLVs []string) {
+	gblInfo, found := svr.lldpGblInfo[ifIndex]
+	if !found {
+		return
+	}
+	gblInfo.disabledTLVs = disabledTLVs
+	if gblInfo.TxInfo != nil {
+		gblInfo.TxInfo.SetCache(false)
+	}
+	svr.lldpGblInfo[ifIndex] = gblInfo
+}
+
+/*  handle the location sent to the MED endpoints of the port
+ */
+func (svr *LLDPServer) handleIntfMedConfig(ifIndex int32, locationElin string) {
+	gblInfo, found := svr.lldpGblInfo[ifIndex]
+	if !found {
+		return
+	}
+	gblInfo.medLocationElin = locationElin
+	if gblInfo.TxInfo != nil {
+		gblInfo.TxInfo.SetCache(false)
+	}
+	svr.lldpGblInfo[ifIndex] = gblInfo
+}
+
+/*  add/update or delete the MED network policy of an application on the port
+ */
+func (svr *LLDPServer) handleMedPolicyConfig(policy *config.MedNetworkPolicy, del bool) {
+	gblInfo, found := svr.lldpGblInfo[policy.IfIndex]
+	if !found {
+		debug.Logger.Err(fmt.Sprintln("No entry for ifIndex", policy.IfIndex,
+			"in runtime information"))
+		return
+	}
+	if gblInfo.medPolicies == nil {
+		gblInfo.medPolicies = make(map[string]config.MedNetworkPolicy)
+	}
+	if del {
+		delete(gblInfo.medPolicies, policy.Application)
+	} else {
+		gblInfo.medPolicies[policy.Application] = *policy
+	}
+	if gblInfo.TxInfo != nil {
+		gblInfo.TxInfo.SetCache(false)
+	}
+	svr.lldpGblInfo[policy.IfIndex] = gblInfo
+}
+
+/*  LLDP-MED information sent on the port, nil when no MED endpoint is
+ *  detected on the port
+ */
+func (svr *LLDPServer) GetMedTxInfo(ifIndex int32) *packet.MedTxInfo {
+	gblInfo, exists := svr.lldpGblInfo[ifIndex]
+	if !exists || gblInfo.TxInfo == nil || !gblInfo.TxInfo.MedEnabled() {
+		return nil
+	}
+	med := &packet.MedTxInfo{
+		Policies:     make([]config.MedNetworkPolicy, 0, len(gblInfo.medPolicies)),
+		LocationElin: gblInfo.medLocationElin,
+		Inventory:    svr.SysPlugin.GetInventory(),
+	}
+	for _, policy := range gblInfo.medPolicies {
+		med.Policies = append(med.Policies, policy)
+	}
+	if svr.SysInfo != nil {
+		med.Inventory.SoftwareRevision = svr.SysInfo.Version
+	}
+	return med
+}
+
+/*  handle vlan membership change of the port, the frame is built again on
+ *  next send
+ */
+func (svr *LLDPServer) UpdatePortVlans(vlans *config.PortVlans) {
+	gblInfo, found := svr.lldpGblInfo[vlans.IfIndex]
+	if !found {
+		return
+	}
+	gblInfo.Port.Pvid = vlans.Pvid
+	gblInfo.Port.Vlans = vlans.Vlans
+	if gblInfo.TxInfo != nil {
+		gblInfo.TxInfo.SetCache(false)
+	}
+	svr.lldpGblInfo[vlans.IfIndex] = gblInfo
+}
+
+/*  handle lag membership change of the port, the frame is built again on
+ *  next send
+ */
+func (svr *LLDPServer) UpdatePortLag(lag *config.PortLag) {
+	gblInfo, found := svr.lldpGblInfo[lag.IfIndex]
+	if !found {
+		return
+	}
+	debug.Logger.Debug(fmt.Sprintln("Lag membership change for", gblInfo.Port.Name,
+		"enabled:", lag.Enabled, "aggId:", lag.AggId))
+	gblInfo.Port.Lag = *lag
+	if gblInfo.TxInfo != nil {
+		gblInfo.TxInfo.SetCache(false)
+	}
+	svr.lldpGblInfo[lag.IfIndex] = gblInfo
+}
+
 /*  API to send a frame when tx timer expires per port
  */
 func (svr *LLDPServer) SendFrame(ifIndex int32) {
 	gblInfo, exists := svr.lldpGblInfo[ifIndex]
 	// extra check for pcap handle
-	if exists && gblInfo.PcapHandle != nil {
+	if exists && gblInfo.PktHandle != nil {
+		// stop the MED tlv's once the MED endpoints aged out
+		if gblInfo.TxInfo.MedEnabled() && !gblInfo.RxInfo.MedEndpointDetected() {
+			gblInfo.TxInfo.SetMedEnabled(false)
+		}
 		if gblInfo.TxInfo.UseCache() == false {
 			svr.GetSystemInfo()
 		}
-		rv := gblInfo.WritePacket(gblInfo.TxInfo.SendFrame(gblInfo.Port, svr.SysInfo))
+		rv := gblInfo.WritePacket(gblInfo.TxInfo.SendFrame(gblInfo.Port, svr.SysInfo,
+			svr.SysPlugin.GetSystemCapabilities(), svr.GetDisabledTLVs(ifIndex),
+			svr.GetMedTxInfo(ifIndex)))
 		if rv == false {
 			gblInfo.TxInfo.SetCache(rv)
+		} else {
+			gblInfo.RxInfo.CountFrameOut()
+		}
+		// the fast start count and the tx timer are only changed by the
+		// server go routine
+		if gblInfo.TxInfo.TxTimer != nil {
+			gblInfo.TxInfo.TxTimer.Reset(gblInfo.TxInfo.NextTxInterval())
 		}
 		svr.lldpGblInfo[ifIndex] = gblInfo
 	}
-	gblInfo.TxDone <- true
 }
 
 /* To handle all the channels in lldp server... For detail look at the
@@ -387,18 +559,25 @@ func (svr *LLDPServer) ChannelHanlder() {
 			}
 			gblInfo, exists := svr.lldpGblInfo[rcvdInfo.ifIndex]
 			if exists {
-				err := gblInfo.RxInfo.Process(gblInfo.RxInfo, rcvdInfo.pkt)
+				// learn/refresh the neighbor and reset/start its ttl timer
+				nbr, err := gblInfo.RxInfo.Process(rcvdInfo.pkt)
 				if err != nil {
 					debug.Logger.Err(fmt.Sprintln("err", err,
 						" while processing rx frame on port",
 						gblInfo.Port.Name))
 					continue
 				}
-				// reset/start timer for recipient information
-				gblInfo.RxInfo.CheckPeerEntry(gblInfo.Port.Name)
 				svr.lldpGblInfo[rcvdInfo.ifIndex] = gblInfo
+				if nbr == nil {
+					continue
+				}
 				// dump the frame
-				gblInfo.DumpFrame()
+				gblInfo.DumpFrame(nbr)
+				if nbr.Med.IsEndpoint() && !gblInfo.TxInfo.MedEnabled() {
+					debug.Logger.Info(fmt.Sprintln("MED endpoint detected on port",
+						gblInfo.Port.Name, "starting fast start"))
+					gblInfo.TxInfo.StartMedFastStart()
+				}
 			}
 		case exit := <-svr.lldpExit:
 			if exit {
@@ -422,18 +601,43 @@ func (svr *LLDPServer) ChannelHanlder() {
 			}
 			svr.Global.Enable = gbl.Enable
 			svr.Global.Vrf = gbl.Vrf
+			svr.Global.DisabledTLVs = gbl.DisabledTLVs
 			svr.handleGlobalConfig()
+			// tlv selection may have changed
+			svr.InvalidateTxCache()
 		case intf, ok := <-svr.IntfCfgCh: // Change in interface config
 			if !ok {
 				continue
 			}
 			debug.Logger.Info(fmt.Sprintln("Server received Intf Config", intf))
+			svr.handleIntfTLVConfig(intf.IfIndex, intf.DisabledTLVs)
+			svr.handleIntfMedConfig(intf.IfIndex, intf.MedLocationElin)
 			svr.handleIntfConfig(intf.IfIndex, intf.Enable)
 		case ifState, ok := <-svr.IfStateCh: // Change in Port State..
 			if !ok {
 				continue
 			}
 			svr.UpdateL2IntfStateChange(ifState.IfIndex, ifState.IfState)
+		case vlans, ok := <-svr.IfVlanCh: // Change in Port Vlan membership
+			if !ok {
+				continue
+			}
+			svr.UpdatePortVlans(vlans)
+		case lag, ok := <-svr.IfLagCh: // Change in Port Lag membership
+			if !ok {
+				continue
+			}
+			svr.UpdatePortLag(lag)
+		case policy, ok := <-svr.MedPolicyCfgCh: // Change in MED network policy
+			if !ok {
+				continue
+			}
+			svr.handleMedPolicyConfig(policy, false)
+		case policy, ok := <-svr.MedPolicyDelCh:
+			if !ok {
+				continue
+			}
+			svr.handleMedPolicyConfig(policy, true)
 		case _, ok := <-svr.UpdateCacheCh:
 			if !ok {
 				continue
@@ -24,33 +24,139 @@
 package server
 
 import (
+	"errors"
 	"fmt"
 	"l2/lldp/config"
+	"l2/lldp/packet"
 	"l2/lldp/utils"
 	"strconv"
+	"strings"
 )
 
 /*  helper function to convert Mandatory TLV's (chassisID, portID, TTL) from byte
- *  format to string
+ *  format to string, the peer information is left empty when nbr is nil
  */
-func (svr *LLDPServer) PopulateMandatoryTLV(ifIndex int32, entry *config.IntfState) bool {
-	gblInfo, exists := svr.lldpGblInfo[ifIndex]
-	if !exists {
-		debug.Logger.Err(fmt.Sprintln("Entry not found for", ifIndex))
-		return exists
-	}
+func (svr *LLDPServer) PopulateMandatoryTLV(gblInfo *LLDPGlobalInfo, nbr *packet.Neighbor,
+	entry *config.IntfState) {
 	entry.LocalPort = gblInfo.Port.Name
-	if gblInfo.RxInfo.RxFrame != nil {
-		entry.PeerMac = gblInfo.GetChassisIdInfo()
-		entry.Port = gblInfo.GetPortIdInfo()
-		entry.HoldTime = strconv.Itoa(int(gblInfo.RxInfo.RxFrame.TTL))
+	if nbr != nil && nbr.RxFrame != nil {
+		entry.RemIndex = nbr.RemIndex
+		entry.PeerMac = gblInfo.GetChassisIdInfo(nbr)
+		entry.Port = gblInfo.GetPortIdInfo(nbr)
+		entry.HoldTime = strconv.Itoa(int(nbr.RxFrame.TTL))
 	}
 	entry.IfIndex = gblInfo.Port.IfIndex
 	entry.Enable = gblInfo.enable
-	return exists
 }
 
-/*  Server get bulk for lldp up intf state's
+/*  helper function to convert the IEEE 802.1 and 802.3 tlv's of the neighbor
+ *  and flag the ones which disagree with the local port. The native vlan
+ *  mismatches when both sides send a non zero pvid
+ */
+func (svr *LLDPServer) PopulateOrgTLV(gblInfo *LLDPGlobalInfo, nbr *packet.Neighbor,
+	entry *config.IntfState) {
+	if nbr == nil {
+		return
+	}
+	dot1 := nbr.Dot1
+	entry.PeerPortVlanId = int32(dot1.PortVlanId)
+	entry.PortVlanIdMismatch = dot1.PortVlanId != 0 && gblInfo.Port.Pvid != 0 &&
+		int32(dot1.PortVlanId) != gblInfo.Port.Pvid
+	for _, ppvid := range dot1.ProtocolVlans {
+		if ppvid.Enabled {
+			entry.PeerProtocolVlanIds = append(entry.PeerProtocolVlanIds, int32(ppvid.Id))
+		}
+	}
+	for _, vlan := range dot1.VlanNames {
+		entry.PeerVlanNames = append(entry.PeerVlanNames,
+			fmt.Sprintf("%d:%s", vlan.Id, vlan.Name))
+	}
+	if lag := dot1.LinkAggregation; lag != nil {
+		entry.PeerLagSupported = lag.Supported
+		entry.PeerLagEnabled = lag.Enabled
+		entry.PeerLagPortId = int32(lag.PortId)
+		entry.LagMismatch = lag.Enabled != gblInfo.Port.Lag.Enabled
+	}
+	dot3 := nbr.Dot3
+	if macPhy := dot3.MacPhy; macPhy != nil {
+		entry.PeerAutonegSupported = macPhy.AutonegSupported
+		entry.PeerAutonegEnabled = macPhy.AutonegEnabled
+		entry.PeerMauType = int32(macPhy.MauType)
+	}
+	if power := dot3.Power; power != nil {
+		entry.PeerPowerSupported = power.Supported
+		entry.PeerPowerEnabled = power.Enabled
+		entry.PeerPowerClass = int32(power.PowerClass)
+	}
+	entry.PeerMaxFrameSize = int32(dot3.MaxFrameSize)
+}
+
+/*  helper function to convert the LLDP-MED tlv's of the neighbor
+ */
+func (svr *LLDPServer) PopulateMedTLV(gblInfo *LLDPGlobalInfo, nbr *packet.Neighbor,
+	entry *config.IntfState) {
+	entry.MedEnabled = gblInfo.TxInfo != nil && gblInfo.TxInfo.MedEnabled()
+	if nbr == nil {
+		return
+	}
+	med := nbr.Med
+	entry.PeerMedDeviceType = med.DeviceTypeString()
+	for _, policy := range med.NetworkPolicies {
+		entry.PeerMedNetworkPolicies = append(entry.PeerMedNetworkPolicies, policy.String())
+	}
+	if len(med.Locations) > 0 {
+		locations := make([]string, 0, len(med.Locations))
+		for _, location := range med.Locations {
+			locations = append(locations, location.String())
+		}
+		entry.PeerMedLocation = strings.Join(locations, ", ")
+	}
+	if med.Power != nil {
+		entry.PeerMedPowerPriority = int32(med.Power.Priority)
+		entry.PeerMedPowerValue = int32(med.Power.Value)
+	}
+	entry.PeerMedHardwareRevision = med.Inventory.HardwareRevision
+	entry.PeerMedFirmwareRevision = med.Inventory.FirmwareRevision
+	entry.PeerMedSoftwareRevision = med.Inventory.SoftwareRevision
+	entry.PeerMedSerialNumber = med.Inventory.SerialNumber
+	entry.PeerMedManufacturer = med.Inventory.Manufacturer
+	entry.PeerMedModelName = med.Inventory.ModelName
+	entry.PeerMedAssetId = med.Inventory.AssetId
+}
+
+/*  Intf state rows of the up ports, one row per neighbor in the remote systems
+ *  table of the port. A port without neighbors has a single row with no peer
+ *  information
+ */
+func (svr *LLDPServer) getIntfStateRows() []config.IntfState {
+	rows := make([]config.IntfState, 0, len(svr.lldpUpIntfStateSlice))
+	for _, ifIndex := range svr.lldpUpIntfStateSlice {
+		gblInfo, exists := svr.lldpGblInfo[ifIndex]
+		if !exists {
+			debug.Logger.Err(fmt.Sprintln("Entry not found for", ifIndex))
+			continue
+		}
+		nbrs := gblInfo.RxInfo.NeighborsGet()
+		if len(nbrs) == 0 {
+			var entry config.IntfState
+			svr.PopulateMandatoryTLV(&gblInfo, nil, &entry)
+			svr.PopulateMedTLV(&gblInfo, nil, &entry)
+			rows = append(rows, entry)
+			continue
+		}
+		for idx := range nbrs {
+			var entry config.IntfState
+			svr.PopulateMandatoryTLV(&gblInfo, &nbrs[idx], &entry)
+			svr.PopulateOrgTLV(&gblInfo, &nbrs[idx], &entry)
+			svr.PopulateMedTLV(&gblInfo, &nbrs[idx], &entry)
+			rows = append(rows, entry)
+		}
+	}
+	return rows
+}
+
+/*  Server get bulk for lldp up intf state's, idx and cnt are in rows i.e.
+ *  neighbors
  */
 func (svr *LLDPServer) GetIntfStates(idx, cnt int) (int, int, []config.IntfState) {
 	var nextIdx int
@@ -61,24 +167,108 @@ func (svr *LLDPServer) GetIntfStates(idx, cnt int) (int, int, []config.IntfState
 		return 0, 0, nil
 	}
 
-	length := len(svr.lldpUpIntfStateSlice)
-	result := make([]config.IntfState, cnt)
+	rows := svr.getIntfStateRows()
+	length := len(rows)
+	if idx >= length {
+		return 0, 0, nil
+	}
+	end := Min(idx+cnt, length)
+	result := rows[idx:end]
+	count = len(result)
+	if end < length {
+		nextIdx = end
+	}
+	return nextIdx, count, result
+}
+
+/*  Server get bulk for lldp intf statistics, all the ports are returned
+ *  whether up or down
+ */
+func (svr *LLDPServer) GetIntfStats(idx, cnt int) (int, int, []config.IntfStats) {
+	var nextIdx int
+
+	length := len(svr.lldpIntfStateSlice)
+	if idx >= length {
+		return 0, 0, nil
+	}
+	end := Min(idx+cnt, length)
+	result := make([]config.IntfStats, 0, end-idx)
+	for _, ifIndex := range svr.lldpIntfStateSlice[idx:end] {
+		entry, exists := svr.GetIntfStat(ifIndex)
+		if !exists {
+			continue
+		}
+		result = append(result, entry)
+	}
+	if end < length {
+		nextIdx = end
+	}
+	return nextIdx, len(result), result
+}
 
-	var i, j int
+func (svr *LLDPServer) GetIntfStat(ifIndex int32) (config.IntfStats, bool) {
+	var entry config.IntfStats
+	gblInfo, exists := svr.lldpGblInfo[ifIndex]
+	if !exists || gblInfo.RxInfo == nil {
+		return entry, false
+	}
+	stats := gblInfo.RxInfo.StatsGet()
+	entry.IfIndex = gblInfo.Port.IfIndex
+	entry.LocalPort = gblInfo.Port.Name
+	entry.FramesOutTotal = stats.FramesOutTotal
+	entry.FramesInTotal = stats.FramesInTotal
+	entry.FramesInErrorsTotal = stats.FramesInErrorsTotal
+	entry.FramesDiscardedTotal = stats.FramesDiscardedTotal
+	entry.TLVsDiscardedTotal = stats.TLVsDiscardedTotal
+	entry.TLVsUnrecognizedTotal = stats.TLVsUnrecognizedTotal
+	entry.AgeoutsTotal = stats.AgeoutsTotal
+	entry.TooManyNeighbors = gblInfo.RxInfo.TooManyNeighbors()
+	return entry, true
+}
 
-	for i, j = 0, idx; i < cnt && j < length; j++ {
-		key := svr.lldpUpIntfStateSlice[j]
-		succes := svr.PopulateMandatoryTLV(key, &result[i])
-		if !succes {
-			result = nil
-			return 0, 0, nil
+/*  Global remote systems table statistics, the sum over all the ports
+ */
+func (svr *LLDPServer) GetGlobalStats() config.GlobalStats {
+	var entry config.GlobalStats
+	if svr.Global != nil {
+		entry.Vrf = svr.Global.Vrf
+	}
+	for _, ifIndex := range svr.lldpIntfStateSlice {
+		gblInfo, exists := svr.lldpGblInfo[ifIndex]
+		if !exists || gblInfo.RxInfo == nil {
+			continue
+		}
+		stats := gblInfo.RxInfo.RemTableStatsGet()
+		entry.RemTablesInserts += stats.Inserts
+		entry.RemTablesDeletes += stats.Deletes
+		entry.RemTablesDrops += stats.Drops
+		entry.RemTablesAgeouts += stats.Ageouts
+		if stats.LastChangeTime.After(entry.RemTablesLastChangeTime) {
+			entry.RemTablesLastChangeTime = stats.LastChangeTime
 		}
-		i++
 	}
+	return entry
+}
 
-	if j == length {
-		nextIdx = 0
+/*  Clear the statistics of the port, ifIndex zero clears the statistics of
+ *  all the ports along with the global statistics
+ */
+func (svr *LLDPServer) ClearStats(ifIndex int32) error {
+	if ifIndex != 0 {
+		gblInfo, exists := svr.lldpGblInfo[ifIndex]
+		if !exists || gblInfo.RxInfo == nil {
+			return errors.New(fmt.Sprintf("No entry for ifIndex %d", ifIndex))
+		}
+		gblInfo.RxInfo.ClearStats()
+		return nil
 	}
-	count = i
-	return nextIdx, count, result
+	for _, key := range svr.lldpIntfStateSlice {
+		gblInfo, exists := svr.lldpGblInfo[key]
+		if !exists || gblInfo.RxInfo == nil {
+			continue
+		}
+		gblInfo.RxInfo.ClearStats()
+		gblInfo.RxInfo.ClearRemTableStats()
+	}
+	return nil
 }
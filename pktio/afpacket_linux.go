@@ -0,0 +1,246 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// afpacket backend, raw AF_PACKET socket bound to the interface with the
+// BPF filter attached to the socket so that only the protocol frames are
+// copied to user space.  The filter is compiled here rather than by
+// libpcap so that the backend does not need cgo
+package pktio
+
+import (
+	"fmt"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// struct packet_mreq from linux/if_packet.h
+type afPacketMreq struct {
+	ifindex int32
+	mrType  uint16
+	alen    uint16
+	address [8]uint8
+}
+
+type afPacketHandle struct {
+	fd      int
+	snapLen int32
+	rxChan  chan gopacket.Packet
+	quit    chan bool
+	once    sync.Once
+}
+
+func init() {
+	Register(PktIoTypeAfPacket, openAfPacket)
+}
+
+func afPacketHtons(v uint16) uint16 {
+	return (v << 8) | (v >> 8)
+}
+
+func afPacketBpfStmt(code uint16, k uint32) syscall.SockFilter {
+	return syscall.SockFilter{Code: code, K: k}
+}
+
+func afPacketBpfJeq(k uint32, jt, jf uint8) syscall.SockFilter {
+	return syscall.SockFilter{
+		Code: syscall.BPF_JMP | syscall.BPF_JEQ | syscall.BPF_K,
+		Jt:   jt,
+		Jf:   jf,
+		K:    k,
+	}
+}
+
+// afPacketFilterCompile will build the classic BPF program for the
+// filterParse terms, each term jumps to the accept on a match and
+// falls through to the next term otherwise
+func afPacketFilterCompile(expr string, snapLen int32) ([]syscall.SockFilter, error) {
+	terms, err := filterParse(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	prog := make([]syscall.SockFilter, 0)
+	// jumps to the accept are patched once the length is known
+	accept := make([]int, 0)
+	for _, t := range terms {
+		if t.dst == nil {
+			prog = append(prog,
+				afPacketBpfStmt(syscall.BPF_LD|syscall.BPF_H|syscall.BPF_ABS, 12),
+				afPacketBpfJeq(uint32(t.proto), 0, 0))
+			accept = append(accept, len(prog)-1)
+		} else {
+			hi := uint32(t.dst[0])<<24 | uint32(t.dst[1])<<16 |
+				uint32(t.dst[2])<<8 | uint32(t.dst[3])
+			lo := uint32(t.dst[4])<<8 | uint32(t.dst[5])
+			prog = append(prog,
+				afPacketBpfStmt(syscall.BPF_LD|syscall.BPF_W|syscall.BPF_ABS, 0),
+				afPacketBpfJeq(hi, 0, 2),
+				afPacketBpfStmt(syscall.BPF_LD|syscall.BPF_H|syscall.BPF_ABS, 4),
+				afPacketBpfJeq(lo, 0, 0))
+			accept = append(accept, len(prog)-1)
+		}
+	}
+	prog = append(prog,
+		afPacketBpfStmt(syscall.BPF_RET|syscall.BPF_K, 0),
+		afPacketBpfStmt(syscall.BPF_RET|syscall.BPF_K, uint32(snapLen)))
+
+	for _, i := range accept {
+		jt := len(prog) - 1 - i - 1
+		if jt > 0xff {
+			return nil, fmt.Errorf("pktio: filter too long %s", expr)
+		}
+		prog[i].Jt = uint8(jt)
+	}
+	return prog, nil
+}
+
+func openAfPacket(ifName string, cfg Config) (Handle, error) {
+	intf, err := net.InterfaceByName(ifName)
+	if err != nil {
+		return nil, err
+	}
+
+	proto := afPacketHtons(syscall.ETH_P_ALL)
+	fd, err := syscall.Socket(syscall.AF_PACKET, syscall.SOCK_RAW, int(proto))
+	if err != nil {
+		return nil, err
+	}
+
+	// filter must be attached before bind so that no unfiltered
+	// frames are queued on the socket
+	if cfg.Filter != "" {
+		var filter []syscall.SockFilter
+		filter, err = afPacketFilterCompile(cfg.Filter, cfg.SnapLen)
+		if err == nil {
+			err = syscall.AttachLsf(fd, filter)
+		}
+		if err != nil {
+			syscall.Close(fd)
+			return nil, err
+		}
+	}
+
+	err = syscall.Bind(fd, &syscall.SockaddrLinklayer{
+		Protocol: proto,
+		Ifindex:  intf.Index,
+	})
+	if err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+
+	// protocol frames are sent to reserved multicast addresses which the
+	// nic may filter unless all multicast is accepted
+	mreq := afPacketMreq{
+		ifindex: int32(intf.Index),
+		mrType:  syscall.PACKET_MR_ALLMULTI,
+	}
+	if cfg.Promiscuous {
+		mreq.mrType = syscall.PACKET_MR_PROMISC
+	}
+	_, _, errno := syscall.Syscall6(syscall.SYS_SETSOCKOPT, uintptr(fd),
+		syscall.SOL_PACKET, syscall.PACKET_ADD_MEMBERSHIP,
+		uintptr(unsafe.Pointer(&mreq)), unsafe.Sizeof(mreq), 0)
+	if errno != 0 {
+		syscall.Close(fd)
+		return nil, errno
+	}
+
+	// read timeout allows the reader to notice the handle was closed
+	tv := syscall.NsecToTimeval(int64(cfg.Timeout))
+	if err = syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+
+	h := &afPacketHandle{
+		fd:      fd,
+		snapLen: cfg.SnapLen,
+		rxChan:  make(chan gopacket.Packet, 10),
+		quit:    make(chan bool),
+	}
+	go h.rxMain()
+	return h, nil
+}
+
+func (h *afPacketHandle) rxMain() {
+	defer close(h.rxChan)
+	defer syscall.Close(h.fd)
+
+	buf := make([]byte, h.snapLen)
+	for {
+		select {
+		case <-h.quit:
+			return
+		default:
+		}
+
+		n, _, err := syscall.Recvfrom(h.fd, buf, 0)
+		if err != nil {
+			if err == syscall.EAGAIN || err == syscall.EINTR {
+				continue
+			}
+			return
+		}
+
+		data := make([]byte, n)
+		copy(data, buf[:n])
+		pkt := gopacket.NewPacket(data, layers.LinkTypeEthernet, gopacket.Default)
+		m := pkt.Metadata()
+		m.Timestamp = time.Now()
+		m.CaptureLength = n
+		m.Length = n
+
+		select {
+		case h.rxChan <- pkt:
+		case <-h.quit:
+			return
+		}
+	}
+}
+
+func (h *afPacketHandle) Packets() chan gopacket.Packet {
+	return h.rxChan
+}
+
+func (h *afPacketHandle) WritePacketData(data []byte) error {
+	select {
+	case <-h.quit:
+		return syscall.EBADF
+	default:
+	}
+	_, err := syscall.Write(h.fd, data)
+	return err
+}
+
+// Close will stop the reader, the socket is closed once the reader exits
+func (h *afPacketHandle) Close() {
+	h.once.Do(func() {
+		close(h.quit)
+	})
+}
@@ -0,0 +1,264 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// memory backend, a virtual wire between two interface names so that
+// a full stack may be run without real interfaces or root privileges
+package pktio
+
+import (
+	"bytes"
+	"errors"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"l2/clock"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const PktIoMemoryRxChanSize = 64
+
+var PktIoErrClosed = errors.New("pktio: handle closed")
+
+// Impairment is applied to the frames transmitted by an interface
+type Impairment struct {
+	Delay time.Duration
+	// 0-100 percent of the frames are dropped
+	LossPercent int
+}
+
+// the memory backend matches the filterParse terms in go
+type memoryFilterFunc func(data []byte) bool
+
+type memoryHandle struct {
+	ifName string
+	filter []memoryFilterFunc
+	rxChan chan gopacket.Packet
+	closed bool
+}
+
+// memoryWire holds both the wiring and the open ends, like pcap more
+// than one handle may be open on an interface and each gets a copy
+// of the frame
+type memoryWire struct {
+	mutex      sync.Mutex
+	peer       map[string]string
+	ends       map[string][]*memoryHandle
+	impairment map[string]Impairment
+	rand       *rand.Rand
+	clk        clock.Clock
+}
+
+var wire = &memoryWire{
+	peer:       make(map[string]string),
+	ends:       make(map[string][]*memoryHandle),
+	impairment: make(map[string]Impairment),
+	rand:       rand.New(rand.NewSource(1)),
+	clk:        clock.NewRealClock(),
+}
+
+func init() {
+	Register(PktIoTypeMemory, openMemory)
+}
+
+// ConnectWire will connect two memory interfaces back to back,
+// any previous connection on either interface is removed
+func ConnectWire(ifNameA, ifNameB string) {
+	wire.mutex.Lock()
+	defer wire.mutex.Unlock()
+	for _, ifName := range []string{ifNameA, ifNameB} {
+		if peer, ok := wire.peer[ifName]; ok {
+			delete(wire.peer, peer)
+		}
+	}
+	wire.peer[ifNameA] = ifNameB
+	wire.peer[ifNameB] = ifNameA
+}
+
+// DisconnectWire will remove the connection from the interface,
+// this is the equivalent of pulling the cable
+func DisconnectWire(ifName string) {
+	wire.mutex.Lock()
+	defer wire.mutex.Unlock()
+	if peer, ok := wire.peer[ifName]; ok {
+		delete(wire.peer, peer)
+		delete(wire.peer, ifName)
+	}
+}
+
+// SetWireImpairment will delay and/or drop the frames transmitted by
+// the interface, a zero Impairment removes it
+func SetWireImpairment(ifName string, imp Impairment) {
+	wire.mutex.Lock()
+	defer wire.mutex.Unlock()
+	if imp == (Impairment{}) {
+		delete(wire.impairment, ifName)
+	} else {
+		wire.impairment[ifName] = imp
+	}
+}
+
+// SetWireSeed will reseed the random loss so that runs are repeatable
+func SetWireSeed(seed int64) {
+	wire.mutex.Lock()
+	defer wire.mutex.Unlock()
+	wire.rand = rand.New(rand.NewSource(seed))
+}
+
+// SetWireClock will replace the clock used to delay frames and to
+// timestamp received frames
+func SetWireClock(c clock.Clock) {
+	wire.mutex.Lock()
+	defer wire.mutex.Unlock()
+	wire.clk = c
+}
+
+func memoryFilterCompile(expr string) ([]memoryFilterFunc, error) {
+	terms, err := filterParse(expr)
+	if err != nil {
+		return nil, err
+	}
+	filter := make([]memoryFilterFunc, 0, len(terms))
+	for _, t := range terms {
+		if t.dst == nil {
+			proto := []byte{uint8(t.proto >> 8), uint8(t.proto)}
+			filter = append(filter, func(data []byte) bool {
+				return len(data) >= 14 && bytes.Equal(data[12:14], proto)
+			})
+		} else {
+			mac := t.dst
+			filter = append(filter, func(data []byte) bool {
+				return len(data) >= 6 && bytes.Equal(data[0:6], mac)
+			})
+		}
+	}
+	return filter, nil
+}
+
+func openMemory(ifName string, cfg Config) (Handle, error) {
+	filter, err := memoryFilterCompile(cfg.Filter)
+	if err != nil {
+		return nil, err
+	}
+	h := &memoryHandle{
+		ifName: ifName,
+		filter: filter,
+		rxChan: make(chan gopacket.Packet, PktIoMemoryRxChanSize),
+	}
+	wire.mutex.Lock()
+	defer wire.mutex.Unlock()
+	wire.ends[ifName] = append(wire.ends[ifName], h)
+	return h, nil
+}
+
+func (h *memoryHandle) Packets() chan gopacket.Packet {
+	return h.rxChan
+}
+
+func (h *memoryHandle) match(data []byte) bool {
+	if len(h.filter) == 0 {
+		return true
+	}
+	for _, f := range h.filter {
+		if f(data) {
+			return true
+		}
+	}
+	return false
+}
+
+// WritePacketData will deliver the frame to the peer end of the wire,
+// like a real link the frame is dropped if nobody is listening
+func (h *memoryHandle) WritePacketData(data []byte) error {
+	wire.mutex.Lock()
+	defer wire.mutex.Unlock()
+	if h.closed {
+		return PktIoErrClosed
+	}
+	peer, ok := wire.peer[h.ifName]
+	if !ok {
+		return nil
+	}
+
+	imp := wire.impairment[h.ifName]
+	if imp.LossPercent > 0 &&
+		wire.rand.Intn(100) < imp.LossPercent {
+		return nil
+	}
+
+	buf := make([]byte, len(data))
+	copy(buf, data)
+	if imp.Delay > 0 {
+		src := h.ifName
+		wire.clk.AfterFunc(imp.Delay, func() {
+			wire.mutex.Lock()
+			defer wire.mutex.Unlock()
+			// frame is lost if the cable was pulled while in flight
+			if wire.peer[src] == peer {
+				wire.deliverLocked(peer, buf)
+			}
+		})
+	} else {
+		wire.deliverLocked(peer, buf)
+	}
+	return nil
+}
+
+func (w *memoryWire) deliverLocked(ifName string, data []byte) {
+	for _, h := range w.ends[ifName] {
+		if h.closed || !h.match(data) {
+			continue
+		}
+		pkt := gopacket.NewPacket(data, layers.LinkTypeEthernet, gopacket.Default)
+		m := pkt.Metadata()
+		m.Timestamp = w.clk.Now()
+		m.CaptureLength = len(data)
+		m.Length = len(data)
+
+		select {
+		case h.rxChan <- pkt:
+		default:
+			// rx queue full, drop
+		}
+	}
+}
+
+func (h *memoryHandle) Close() {
+	wire.mutex.Lock()
+	defer wire.mutex.Unlock()
+	if h.closed {
+		return
+	}
+	h.closed = true
+	close(h.rxChan)
+	ends := wire.ends[h.ifName]
+	for i, e := range ends {
+		if e == h {
+			wire.ends[h.ifName] = append(ends[:i], ends[i+1:]...)
+			break
+		}
+	}
+	if len(wire.ends[h.ifName]) == 0 {
+		delete(wire.ends, h.ifName)
+	}
+}
@@ -0,0 +1,68 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// pcap backend, uses libpcap for rx and tx
+package pktio
+
+import (
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/pcap"
+)
+
+type pcapHandle struct {
+	handle *pcap.Handle
+	src    *gopacket.PacketSource
+}
+
+func init() {
+	Register(PktIoTypePcap, openPcap)
+}
+
+func openPcap(ifName string, cfg Config) (Handle, error) {
+	handle, err := pcap.OpenLive(ifName, cfg.SnapLen, cfg.Promiscuous, cfg.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Filter != "" {
+		if err = handle.SetBPFFilter(cfg.Filter); err != nil {
+			handle.Close()
+			return nil, err
+		}
+	}
+	return &pcapHandle{
+		handle: handle,
+		src:    gopacket.NewPacketSource(handle, handle.LinkType()),
+	}, nil
+}
+
+func (h *pcapHandle) Packets() chan gopacket.Packet {
+	return h.src.Packets()
+}
+
+func (h *pcapHandle) WritePacketData(data []byte) error {
+	return h.handle.WritePacketData(data)
+}
+
+func (h *pcapHandle) Close() {
+	h.handle.Close()
+}
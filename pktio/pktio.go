@@ -0,0 +1,169 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// pktio provides the packet I/O abstraction shared by the l2 daemons.
+// A daemon opens a Handle per interface from its configured backend and
+// then only deals with the Packets channel and WritePacketData.
+package pktio
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/gopacket"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Backend types which may be selected through Config.Type
+const (
+	PktIoTypePcap     = "pcap"
+	PktIoTypeAfPacket = "afpacket"
+	PktIoTypeMemory   = "memory"
+)
+
+const (
+	PktIoDefaultSnapLen = 65536
+	PktIoDefaultTimeout = 50 * time.Millisecond
+)
+
+var PktIoErrUnknownType = errors.New("pktio: unknown backend type")
+
+// filterTerm is one "ether proto X" or "ether dst MAC" primitive, the
+// backends which do not use libpcap only understand these terms joined
+// by "or", which covers what the daemons use
+type filterTerm struct {
+	// zero when the term matches on the destination
+	proto uint16
+	dst   net.HardwareAddr
+}
+
+func filterParse(expr string) ([]filterTerm, error) {
+	terms := make([]filterTerm, 0)
+	if strings.TrimSpace(expr) == "" {
+		return terms, nil
+	}
+	for _, term := range strings.Split(expr, " or ") {
+		f := strings.Fields(term)
+		if len(f) != 3 || f[0] != "ether" {
+			return nil, fmt.Errorf("pktio: unsupported filter %s", term)
+		}
+		switch f[1] {
+		case "proto":
+			v, err := strconv.ParseUint(f[2], 0, 16)
+			if err != nil {
+				return nil, err
+			}
+			terms = append(terms, filterTerm{proto: uint16(v)})
+		case "dst":
+			mac, err := net.ParseMAC(f[2])
+			if err != nil {
+				return nil, err
+			}
+			if len(mac) != 6 {
+				return nil, fmt.Errorf("pktio: unsupported filter %s", term)
+			}
+			terms = append(terms, filterTerm{dst: mac})
+		default:
+			return nil, fmt.Errorf("pktio: unsupported filter %s", term)
+		}
+	}
+	return terms, nil
+}
+
+// Config describes how a daemon wants its interfaces opened
+type Config struct {
+	// one of the PktIoType* backends, empty means pcap
+	Type        string
+	SnapLen     int32
+	Promiscuous bool
+	// read timeout, used by the backends to poll for close
+	Timeout time.Duration
+	// BPF filter expression applied to rx, empty means receive all
+	Filter string
+}
+
+// Handle is an open interface, the Packets channel is closed
+// once the Handle is closed
+type Handle interface {
+	Packets() chan gopacket.Packet
+	WritePacketData(data []byte) error
+	Close()
+}
+
+// OpenFunc is supplied by each backend
+type OpenFunc func(ifName string, cfg Config) (Handle, error)
+
+var backendMutex sync.RWMutex
+var backendMap = make(map[string]OpenFunc)
+
+// DefaultConfig returns the config the daemons used prior to
+// the backends being selectable
+func DefaultConfig() Config {
+	return Config{
+		Type:    PktIoTypePcap,
+		SnapLen: PktIoDefaultSnapLen,
+		Timeout: PktIoDefaultTimeout,
+	}
+}
+
+// Register will add a backend, backends register themselves from init
+func Register(pktIoType string, f OpenFunc) {
+	backendMutex.Lock()
+	defer backendMutex.Unlock()
+	backendMap[pktIoType] = f
+}
+
+// IsSupported will return true if the backend is available on this platform
+func IsSupported(pktIoType string) bool {
+	if pktIoType == "" {
+		pktIoType = PktIoTypePcap
+	}
+	backendMutex.RLock()
+	defer backendMutex.RUnlock()
+	_, ok := backendMap[pktIoType]
+	return ok
+}
+
+// Open will open the interface using the backend selected in the config
+func Open(ifName string, cfg Config) (Handle, error) {
+	if cfg.Type == "" {
+		cfg.Type = PktIoTypePcap
+	}
+	if cfg.SnapLen == 0 {
+		cfg.SnapLen = PktIoDefaultSnapLen
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = PktIoDefaultTimeout
+	}
+
+	backendMutex.RLock()
+	f, ok := backendMap[cfg.Type]
+	backendMutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%s %s", PktIoErrUnknownType, cfg.Type)
+	}
+	return f(ifName, cfg)
+}
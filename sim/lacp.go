@@ -0,0 +1,186 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// lacp.go
+package sim
+
+import (
+	"errors"
+	"fmt"
+	lacp "l2/lacp/protocol"
+	"time"
+)
+
+// Lag is a lacp aggregator on a node together with its member ports
+type Lag struct {
+	Node  *Node
+	Num   int
+	Id    int
+	Key   uint16
+	Ports []*Port
+
+	mode     int
+	interval time.Duration
+}
+
+// LacpAggCreate will create an aggregator on the node and attach the
+// given ports to it, mode is one of lacp.LacpModeOn/Active/Passive
+func (n *Node) LacpAggCreate(lagNum int, mode int, interval time.Duration, ports ...*Port) (*Lag, error) {
+	for _, lag := range n.Lags {
+		if lag.Num == lagNum {
+			return nil, errors.New(fmt.Sprintf("SIM: node %s lag %d already exists", n.Name, lagNum))
+		}
+	}
+	for _, p := range ports {
+		if p.Node != n {
+			return nil, errors.New(fmt.Sprintf("SIM: port %s does not belong to node %s", p.Name, n.Name))
+		}
+		if p.lag != nil {
+			return nil, errors.New(fmt.Sprintf("SIM: port %s already a member of lag %d", p.Name, p.lag.Num))
+		}
+	}
+
+	// the key must be unique amongst all simulated systems as the
+	// lacp aggregator and port tables are global
+	key := uint16(n.Num<<8 | lagNum)
+	lag := &Lag{
+		Node:     n,
+		Num:      lagNum,
+		Id:       int(key),
+		Key:      key,
+		mode:     mode,
+		interval: interval,
+	}
+
+	// the aggregator is created first so that the ports register their
+	// tx with the correct system
+	lacp.CreateLaAgg(&lacp.LaAggConfig{
+		Name: fmt.Sprintf("%s-lag%d", n.Name, lagNum),
+		Id:   lag.Id,
+		Key:  lag.Key,
+		Lacp: lacp.LacpConfigInfo{
+			Interval:       interval,
+			Mode:           uint32(mode),
+			SystemIdMac:    n.Mac.String(),
+			SystemPriority: 128,
+		},
+	})
+	n.Lags = append(n.Lags, lag)
+
+	for _, p := range ports {
+		lag.AddPort(p)
+	}
+	return lag, nil
+}
+
+// AddPort will attach a port of the lag node to the lag
+func (lag *Lag) AddPort(p *Port) {
+	timeout := lacp.LacpLongTimeoutTime
+	if lag.interval == lacp.LacpFastPeriodicTime {
+		timeout = lacp.LacpShortTimeoutTime
+	}
+	lacp.CreateLaAggPort(&lacp.LaAggPortConfig{
+		Id:      uint16(p.IfIndex),
+		Prio:    0x80,
+		Key:     lag.Key,
+		AggId:   lag.Id,
+		Enable:  true,
+		Mode:    lag.mode,
+		Timeout: timeout,
+		Properties: lacp.PortProperties{
+			Mac:    p.Mac,
+			Speed:  SimPortSpeed * 1000000,
+			Duplex: lacp.LacpPortDuplexFull,
+			Mtu:    1500,
+		},
+		IntfId: p.Name,
+	})
+	p.lag = lag
+	lag.Ports = append(lag.Ports, p)
+
+	// the simulator has no asicd so link state must be pushed
+	if p.Link != nil && !p.Link.Up {
+		lag.linkStateNotify(p, false)
+	}
+}
+
+// DelPort will detach the port from the lag
+func (lag *Lag) DelPort(p *Port) {
+	for i, m := range lag.Ports {
+		if m == p {
+			lacp.DeleteLaAggPort(uint16(p.IfIndex))
+			lag.Ports = append(lag.Ports[:i], lag.Ports[i+1:]...)
+			p.lag = nil
+			return
+		}
+	}
+}
+
+func (lag *Lag) delete() {
+	lacp.DeleteLaAgg(lag.Id)
+	for _, p := range lag.Ports {
+		p.lag = nil
+	}
+	lag.Ports = nil
+}
+
+func (lag *Lag) linkStateNotify(p *Port, up bool) {
+	var lp *lacp.LaAggPort
+	if lacp.LaFindPortById(uint16(p.IfIndex), &lp) {
+		if up {
+			lp.LaAggPortEnabled()
+		} else {
+			lp.LaAggPortDisable()
+		}
+		lp.LinkOperStatus = up
+	}
+}
+
+// Distributing returns true when the member port is distributing frames
+func (lag *Lag) Distributing(p *Port) bool {
+	if p.lag != lag {
+		return false
+	}
+	state := lacp.GetLaAggPortActorOperState(uint16(p.IfIndex))
+	return lacp.LacpStateIsSet(state, lacp.LacpStateDistributingBit)
+}
+
+// CheckMembers will validate that exactly the given ports are
+// distributing within the lag
+func (lag *Lag) CheckMembers(expected ...*Port) error {
+	want := make(map[*Port]bool)
+	for _, p := range expected {
+		want[p] = true
+	}
+	for _, p := range lag.Ports {
+		if lag.Distributing(p) != want[p] {
+			return errors.New(fmt.Sprintf("SIM: lag %s-%d port %s distributing %t expected %t",
+				lag.Node.Name, lag.Num, p.Name, lag.Distributing(p), want[p]))
+		}
+		delete(want, p)
+	}
+	for p := range want {
+		return errors.New(fmt.Sprintf("SIM: port %s is not a member of lag %s-%d", p.Name, lag.Node.Name, lag.Num))
+	}
+	return nil
+}
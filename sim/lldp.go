@@ -0,0 +1,189 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// lldp.go
+package sim
+
+import (
+	"errors"
+	"fmt"
+	"l2/lldp/config"
+	"l2/lldp/server"
+	"l2/lldp/utils"
+	"l2/pktio"
+	"models"
+	"utils/logging"
+)
+
+// simLldp holds the lldp server of a node along with the plugins which
+// replace asicd, the config db and the system db
+type simLldp struct {
+	node *Node
+	svr  *server.LLDPServer
+}
+
+type simLldpAsicPlugin struct {
+	node *Node
+}
+
+func (a *simLldpAsicPlugin) GetPortsInfo() []*config.PortInfo {
+	portsInfo := make([]*config.PortInfo, 0)
+	for _, p := range a.node.Ports {
+		state := server.LLDP_PORT_STATE_UP
+		if p.Link != nil && !p.Link.Up {
+			state = server.LLDP_PORT_STATE_DOWN
+		}
+		portsInfo = append(portsInfo, &config.PortInfo{
+			IfIndex:   p.IfIndex,
+			Name:      p.Name,
+			OperState: state,
+			MacAddr:   p.Mac.String(),
+		})
+	}
+	return portsInfo
+}
+
+func (a *simLldpAsicPlugin) Start() {
+}
+
+type simLldpCfgPlugin struct {
+}
+
+func (c *simLldpCfgPlugin) Start() error {
+	return nil
+}
+
+type simLldpSysPlugin struct {
+}
+
+func (s *simLldpSysPlugin) Start() {
+}
+
+// GetSystemCapabilities sim nodes run stp and lacp
+func (s *simLldpSysPlugin) GetSystemCapabilities() config.SystemCapabilities {
+	return config.SystemCapabilities{
+		Supported: config.SYS_CAP_BRIDGE,
+		Enabled:   config.SYS_CAP_BRIDGE,
+	}
+}
+
+// GetInventory sim nodes have no hardware inventory
+func (s *simLldpSysPlugin) GetInventory() config.Inventory {
+	return config.Inventory{
+		Manufacturer: "SnapRoute",
+		ModelName:    "sim",
+	}
+}
+
+// LldpEnable will start an lldp server on the node and enable lldp on
+// all of its ports, ports added to the node afterwards do not run lldp
+func (n *Node) LldpEnable() {
+	if n.lldp != nil {
+		return
+	}
+	if debug.Logger == nil {
+		logger, _ := logging.NewLogger("lldpd", "LLDP", true)
+		debug.SetLogger(logger)
+	}
+
+	svr := server.LLDPNewServer(&simLldpAsicPlugin{node: n},
+		&simLldpCfgPlugin{}, &simLldpSysPlugin{})
+	svr.SetPktIoType(pktio.PktIoTypeMemory)
+	svr.SetClock(n.sim.Clock)
+	svr.Global = &config.Global{
+		Enable: true,
+	}
+	svr.SysInfo = &models.SystemParam{
+		SwitchMac: n.Mac.String(),
+		Hostname:  n.Name,
+	}
+	n.lldp = &simLldp{
+		node: n,
+		svr:  svr,
+	}
+
+	svr.LLDPStartPorts()
+	for _, p := range n.Ports {
+		svr.IntfCfgCh <- &config.Intf{
+			IfIndex: p.IfIndex,
+			Enable:  true,
+		}
+	}
+}
+
+// LldpDisable will stop lldp rx/tx on all of the node ports
+func (n *Node) LldpDisable() {
+	if n.lldp == nil {
+		return
+	}
+	n.lldp.svr.GblCfgCh <- &config.Global{
+		Enable: false,
+	}
+	n.lldp = nil
+}
+
+func (l *simLldp) linkStateNotify(p *Port, up bool) {
+	state := server.LLDP_PORT_STATE_DOWN
+	if up {
+		state = server.LLDP_PORT_STATE_UP
+	}
+	l.svr.IfStateCh <- &config.PortState{
+		IfIndex: p.IfIndex,
+		IfState: state,
+	}
+}
+
+// LldpNeighbor returns the neighbor learned on the port, false is
+// returned when no neighbor has been learned
+func (p *Port) LldpNeighbor() (config.IntfState, bool) {
+	if p.Node.lldp == nil {
+		return config.IntfState{}, false
+	}
+	_, count, states := p.Node.lldp.svr.GetIntfStates(0, len(p.Node.Ports))
+	for i := 0; i < count; i++ {
+		if states[i].IfIndex == p.IfIndex && states[i].PeerMac != "" {
+			return states[i], true
+		}
+	}
+	return config.IntfState{}, false
+}
+
+// LldpCheckNeighbors will validate that every up link between two lldp
+// enabled nodes has learned the port on the other end of the link
+func (s *Sim) LldpCheckNeighbors() error {
+	for _, l := range s.Links {
+		if !l.Up || l.A.Node.lldp == nil || l.B.Node.lldp == nil {
+			continue
+		}
+		for _, p := range []*Port{l.A, l.B} {
+			nbr, ok := p.LldpNeighbor()
+			if !ok {
+				return errors.New(fmt.Sprintf("SIM: port %s has not learned a neighbor", p.Name))
+			}
+			if nbr.Port != p.Peer().Name {
+				return errors.New(fmt.Sprintf("SIM: port %s learned neighbor port %s expected %s", p.Name, nbr.Port, p.Peer().Name))
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,310 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// sim is a multi node l2 simulator.  Each Node runs the real stp, lacp and
+// lldp state machines and each Link is a pktio memory wire so arbitrary
+// topologies may be built, impaired and checked for convergence within
+// a single test process.
+//
+// All protocol timers run on a clock.ManualClock so long timeouts may be
+// simulated in a fraction of the wall time.
+//
+// The stp and lacp modules keep their state in package globals so only
+// one Sim may exist at a time within a process.
+package sim
+
+import (
+	"errors"
+	"fmt"
+	"l2/clock"
+	lacp "l2/lacp/protocol"
+	"l2/pktio"
+	stp "l2/stp/protocol"
+	"net"
+	"time"
+)
+
+// ports per node, keeps the ifindex within the 12 bit stp port number
+const SimMaxPortsPerNode = 63
+
+// default polling interval used when waiting for convergence
+const SimPollInterval = 100 * time.Millisecond
+
+// virtual time advanced per step, the wall time yield between steps
+// allows the protocol go routines to react to expired timers
+const SimClockStep = 100 * time.Millisecond
+const SimClockYield = 2 * time.Millisecond
+
+type Sim struct {
+	Nodes []*Node
+	Links []*Link
+
+	Clock *clock.ManualClock
+
+	nodeMap map[string]*Node
+
+	prevStpPktIoCfg  pktio.Config
+	prevLacpPktIoCfg pktio.Config
+	prevStpClock     clock.Clock
+	prevLacpClock    clock.Clock
+}
+
+type Node struct {
+	Name  string
+	Num   int
+	Mac   net.HardwareAddr
+	Ports []*Port
+
+	sim *Sim
+
+	// stp
+	stpEnabled    bool
+	stpBrgIfIndex int32
+
+	// lacp
+	Lags []*Lag
+
+	// lldp
+	lldp *simLldp
+}
+
+type Port struct {
+	Node    *Node
+	Name    string
+	Num     int
+	IfIndex int32
+	Mac     net.HardwareAddr
+	Link    *Link
+
+	lag *Lag
+	stp bool
+}
+
+type Link struct {
+	A  *Port
+	B  *Port
+	Up bool
+}
+
+// NewSim will create an empty topology, all daemons are switched to the
+// memory packet I/O backend and the simulation clock until Stop is called
+func NewSim() *Sim {
+	s := &Sim{
+		Clock:            clock.NewManualClock(time.Now()),
+		nodeMap:          make(map[string]*Node),
+		prevStpPktIoCfg:  stp.StpPktIoConfigGet(),
+		prevLacpPktIoCfg: lacp.LacpPktIoConfigGet(),
+		prevStpClock:     stp.StpClockGet(),
+		prevLacpClock:    lacp.LacpClockGet(),
+	}
+
+	cfg := pktio.DefaultConfig()
+	cfg.Type = pktio.PktIoTypeMemory
+	stp.StpPktIoConfigSet(cfg)
+	lacp.LacpPktIoConfigSet(cfg)
+	stp.StpClockSet(s.Clock)
+	lacp.LacpClockSet(s.Clock)
+	pktio.SetWireClock(s.Clock)
+	return s
+}
+
+// Stop will tear down all the protocols and links of the simulation
+func (s *Sim) Stop() {
+	for _, n := range s.Nodes {
+		n.LldpDisable()
+		for _, lag := range n.Lags {
+			lag.delete()
+		}
+		n.Lags = nil
+		n.StpDisable()
+	}
+	for _, l := range s.Links {
+		pktio.SetWireImpairment(l.A.Name, pktio.Impairment{})
+		pktio.SetWireImpairment(l.B.Name, pktio.Impairment{})
+		pktio.DisconnectWire(l.A.Name)
+	}
+	stp.StpPktIoConfigSet(s.prevStpPktIoCfg)
+	lacp.LacpPktIoConfigSet(s.prevLacpPktIoCfg)
+	stp.StpClockSet(s.prevStpClock)
+	lacp.LacpClockSet(s.prevLacpClock)
+	pktio.SetWireClock(clock.NewRealClock())
+}
+
+// AddNode will create a new node, the node number is used to derive
+// the node mac and the ifindex of its ports
+func (s *Sim) AddNode(name string) *Node {
+	if n, ok := s.nodeMap[name]; ok {
+		return n
+	}
+	num := len(s.Nodes) + 1
+	n := &Node{
+		Name: name,
+		Num:  num,
+		Mac:  net.HardwareAddr{0x00, 0x53, 0x00, 0x00, uint8(num >> 8), uint8(num)},
+		sim:  s,
+	}
+	s.Nodes = append(s.Nodes, n)
+	s.nodeMap[name] = n
+	return n
+}
+
+func (s *Sim) Node(name string) *Node {
+	return s.nodeMap[name]
+}
+
+func (n *Node) addPort() (*Port, error) {
+	num := len(n.Ports) + 1
+	if num > SimMaxPortsPerNode {
+		return nil, errors.New(fmt.Sprintf("SIM: node %s has no free ports", n.Name))
+	}
+	p := &Port{
+		Node:    n,
+		Name:    fmt.Sprintf("SIM%d-%d", n.Num, num),
+		Num:     num,
+		IfIndex: int32(n.Num<<6 | num),
+		Mac:     net.HardwareAddr{0x02, 0x53, uint8(n.Num >> 8), uint8(n.Num), 0x00, uint8(num)},
+	}
+	n.Ports = append(n.Ports, p)
+	return p, nil
+}
+
+// AddLink will create a new port on each node and connect them
+func (s *Sim) AddLink(nameA, nameB string) (*Link, error) {
+	a := s.AddNode(nameA)
+	b := s.AddNode(nameB)
+
+	pa, err := a.addPort()
+	if err != nil {
+		return nil, err
+	}
+	pb, err := b.addPort()
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Link{A: pa, B: pb, Up: true}
+	pa.Link = l
+	pb.Link = l
+	s.Links = append(s.Links, l)
+	pktio.ConnectWire(pa.Name, pb.Name)
+
+	// new ports join stp if it is already running on the node
+	for _, p := range []*Port{pa, pb} {
+		if p.Node.stpEnabled {
+			p.Node.stpPortCreate(p)
+		}
+	}
+	return l, nil
+}
+
+// Peer returns the port at the other end of the link
+func (p *Port) Peer() *Port {
+	if p.Link == nil {
+		return nil
+	}
+	if p.Link.A == p {
+		return p.Link.B
+	}
+	return p.Link.A
+}
+
+// Fail will pull the cable, both ends see link down
+func (l *Link) Fail() {
+	if !l.Up {
+		return
+	}
+	l.Up = false
+	pktio.DisconnectWire(l.A.Name)
+	for _, p := range []*Port{l.A, l.B} {
+		p.linkStateNotify(false)
+	}
+}
+
+// Restore will reconnect the cable, both ends see link up
+func (l *Link) Restore() {
+	if l.Up {
+		return
+	}
+	l.Up = true
+	pktio.ConnectWire(l.A.Name, l.B.Name)
+	for _, p := range []*Port{l.A, l.B} {
+		p.linkStateNotify(true)
+	}
+}
+
+// Impair will delay and/or drop frames in both directions, link
+// state is not affected
+func (l *Link) Impair(delay time.Duration, lossPercent int) {
+	imp := pktio.Impairment{Delay: delay, LossPercent: lossPercent}
+	pktio.SetWireImpairment(l.A.Name, imp)
+	pktio.SetWireImpairment(l.B.Name, imp)
+}
+
+// ImpairOneWay will only affect frames transmitted by the given port,
+// a loss of 100 simulates a unidirectional link failure
+func (l *Link) ImpairOneWay(from *Port, delay time.Duration, lossPercent int) {
+	pktio.SetWireImpairment(from.Name, pktio.Impairment{Delay: delay, LossPercent: lossPercent})
+}
+
+func (p *Port) linkStateNotify(up bool) {
+	if p.stp {
+		if up {
+			stp.StpPortLinkUp(p.IfIndex)
+		} else {
+			stp.StpPortLinkDown(p.IfIndex)
+		}
+	}
+	if p.lag != nil {
+		p.lag.linkStateNotify(p, up)
+	}
+	if p.Node.lldp != nil {
+		p.Node.lldp.linkStateNotify(p, up)
+	}
+}
+
+// Advance will move the simulation clock forward in steps of
+// SimClockStep firing the protocol timers on the way
+func (s *Sim) Advance(d time.Duration) {
+	for d > 0 {
+		step := SimClockStep
+		if d < step {
+			step = d
+		}
+		s.Clock.Advance(step)
+		d -= step
+		time.Sleep(SimClockYield)
+	}
+}
+
+// WaitFor will advance the simulation until check succeeds or the
+// timeout of simulated time expires, the last error from check is
+// returned on timeout
+func (s *Sim) WaitFor(timeout time.Duration, check func() error) error {
+	var err error
+	for waited := time.Duration(0); ; waited += SimPollInterval {
+		if err = check(); err == nil || waited >= timeout {
+			return err
+		}
+		s.Advance(SimPollInterval)
+	}
+}
@@ -0,0 +1,186 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// sim_test.go
+package sim
+
+import (
+	"fmt"
+	lacp "l2/lacp/protocol"
+	"testing"
+	"time"
+)
+
+func TestSimStpRingConvergence(t *testing.T) {
+	s := NewSim()
+	defer s.Stop()
+
+	// four node ring
+	ring := []string{"sw1", "sw2", "sw3", "sw4"}
+	for i, name := range ring {
+		if _, err := s.AddLink(name, ring[(i+1)%len(ring)]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, n := range s.Nodes {
+		n.LldpEnable()
+	}
+
+	// sw1 is the expected root
+	for i, name := range ring {
+		if err := s.Node(name).StpEnable(uint16(4096 * (i + 1))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.WaitFor(time.Second*40, s.StpCheckConverged); err != nil {
+		t.Fatal("Ring did not converge", err)
+	}
+	if !s.Node("sw1").StpIsRoot() {
+		t.Error("Expected sw1 to be the root bridge")
+	}
+	// first lldp frame is sent after the 30 second tx interval
+	if err := s.WaitFor(time.Second*40, s.LldpCheckNeighbors); err != nil {
+		t.Error("LLDP neighbors not learned", err)
+	}
+
+	// a ring must block exactly one link
+	blocked := 0
+	for _, l := range s.Links {
+		if !l.A.forwarding() || !l.B.forwarding() {
+			blocked++
+		}
+	}
+	if blocked != 1 {
+		t.Error(fmt.Sprintf("Expected a single blocked link found %d", blocked))
+	}
+
+	// failing a link of the root must open the blocked link
+	s.Links[0].Fail()
+	if err := s.WaitFor(time.Second*40, s.StpCheckConverged); err != nil {
+		t.Fatal("Ring did not re-converge after link failure", err)
+	}
+	for _, l := range s.Links[1:] {
+		if !l.A.forwarding() || !l.B.forwarding() {
+			t.Error(fmt.Sprintf("Expected link %s-%s to be forwarding", l.A.Name, l.B.Name))
+		}
+	}
+
+	s.Links[0].Restore()
+	if err := s.WaitFor(time.Second*40, s.StpCheckConverged); err != nil {
+		t.Fatal("Ring did not re-converge after link restore", err)
+	}
+}
+
+func TestSimLacpLagMembership(t *testing.T) {
+	s := NewSim()
+	defer s.Stop()
+
+	for i := 0; i < 3; i++ {
+		if _, err := s.AddLink("sw1", "sw2"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sw1 := s.Node("sw1")
+	sw2 := s.Node("sw2")
+	lag1, err := sw1.LacpAggCreate(1, lacp.LacpModeActive, lacp.LacpFastPeriodicTime, sw1.Ports...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lag2, err := sw2.LacpAggCreate(1, lacp.LacpModeActive, lacp.LacpFastPeriodicTime, sw2.Ports...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(expected ...*Port) func() error {
+		return func() error {
+			var peers []*Port
+			for _, p := range expected {
+				peers = append(peers, p.Peer())
+			}
+			if err := lag1.CheckMembers(expected...); err != nil {
+				return err
+			}
+			return lag2.CheckMembers(peers...)
+		}
+	}
+
+	if err := s.WaitFor(time.Second*15, check(sw1.Ports...)); err != nil {
+		t.Fatal("Lag did not come up", err)
+	}
+
+	s.Links[1].Fail()
+	if err := s.WaitFor(time.Second*15, check(sw1.Ports[0], sw1.Ports[2])); err != nil {
+		t.Error("Failed link was not removed from lag", err)
+	}
+
+	s.Links[1].Restore()
+	if err := s.WaitFor(time.Second*15, check(sw1.Ports...)); err != nil {
+		t.Error("Restored link did not rejoin lag", err)
+	}
+
+	if err := s.CheckLoopFree(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSimLacpLongTimeout(t *testing.T) {
+	s := NewSim()
+	defer s.Stop()
+
+	for i := 0; i < 2; i++ {
+		if _, err := s.AddLink("sw1", "sw2"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sw1 := s.Node("sw1")
+	sw2 := s.Node("sw2")
+	lag1, err := sw1.LacpAggCreate(1, lacp.LacpModeActive, lacp.LacpSlowPeriodicTime, sw1.Ports...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = sw2.LacpAggCreate(1, lacp.LacpModeActive, lacp.LacpSlowPeriodicTime, sw2.Ports...); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = s.WaitFor(time.Second*90, func() error { return lag1.CheckMembers(sw1.Ports...) }); err != nil {
+		t.Fatal("Lag did not come up", err)
+	}
+
+	// unidirectional failure, sw1 stops receiving lacpdus on the second
+	// link but its link stays up so only the 90 second timeout detects it
+	s.Links[1].ImpairOneWay(sw2.Ports[1], 0, 100)
+
+	// last lacpdu was received at most 30 seconds ago
+	s.Advance(time.Second * 55)
+	if err = lag1.CheckMembers(sw1.Ports...); err != nil {
+		t.Error("Port removed from lag before long timeout expired", err)
+	}
+
+	if err = s.WaitFor(time.Second*45, func() error { return lag1.CheckMembers(sw1.Ports[0]) }); err != nil {
+		t.Error("Port was not removed from lag after long timeout", err)
+	}
+}
@@ -0,0 +1,254 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// stp.go
+package sim
+
+import (
+	"errors"
+	"fmt"
+	stp "l2/stp/protocol"
+)
+
+// bridge ifindex base, keeps the simulated bridges out of the vlan range
+const SimStpBrgIfIndexBase = 0x10000
+
+// speed reported for every simulated port, used for the auto path cost
+const SimPortSpeed = 1000
+
+// SimStpBridgeConfig holds the bridge timers applied by StpEnable
+var SimStpBridgeConfig = stp.StpBridgeConfig{
+	MaxAge:       20,
+	HelloTime:    2,
+	ForwardDelay: 15,
+	ForceVersion: 2,
+	TxHoldCount:  6,
+}
+
+func (n *Node) StpBrgIfIndex() int32 {
+	return n.stpBrgIfIndex
+}
+
+// StpEnable will create a bridge on the node and add all its ports
+func (n *Node) StpEnable(priority uint16) error {
+	if n.stpEnabled {
+		return nil
+	}
+	c := SimStpBridgeConfig
+	c.Priority = priority
+	c.Vlan = stp.DEFAULT_STP_BRIDGE_VLAN
+	c.BrgIfIndex = int32(SimStpBrgIfIndexBase + n.Num)
+	copy(c.BridgeMac[:], n.Mac)
+
+	err := stp.StpBridgeCreate(&c)
+	if err != nil {
+		return err
+	}
+	n.stpBrgIfIndex = c.BrgIfIndex
+	n.stpEnabled = true
+
+	for _, p := range n.Ports {
+		if err = n.stpPortCreate(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *Node) StpDisable() {
+	if !n.stpEnabled {
+		return
+	}
+	for _, p := range n.Ports {
+		n.stpPortDelete(p)
+	}
+	c := &stp.StpBridgeConfig{
+		Vlan:       stp.DEFAULT_STP_BRIDGE_VLAN,
+		BrgIfIndex: n.stpBrgIfIndex,
+	}
+	stp.StpBridgeDelete(c)
+	n.stpEnabled = false
+}
+
+func (n *Node) stpPortCreate(p *Port) error {
+	stp.StpPortConfigMapSet(p.IfIndex, p.Name, p.Mac, SimPortSpeed)
+	c := &stp.StpPortConfig{
+		IfIndex:    p.IfIndex,
+		Priority:   128,
+		Enable:     true,
+		BrgIfIndex: n.stpBrgIfIndex,
+	}
+	err := stp.StpPortCreate(c)
+	if err != nil {
+		return err
+	}
+	p.stp = true
+	if p.Link != nil && !p.Link.Up {
+		stp.StpPortLinkDown(p.IfIndex)
+	}
+	return nil
+}
+
+func (n *Node) stpPortDelete(p *Port) {
+	if !p.stp {
+		return
+	}
+	c := &stp.StpPortConfig{
+		IfIndex:    p.IfIndex,
+		BrgIfIndex: n.stpBrgIfIndex,
+	}
+	stp.StpPortDelete(c)
+	stp.StpPortConfigMapDelete(p.IfIndex)
+	p.stp = false
+}
+
+func (n *Node) StpBridge() *stp.Bridge {
+	var b *stp.Bridge
+	if n.stpEnabled && stp.StpFindBridgeByIfIndex(n.stpBrgIfIndex, &b) {
+		return b
+	}
+	return nil
+}
+
+func (p *Port) StpPort() *stp.StpPort {
+	var sp *stp.StpPort
+	if p.stp && stp.StpFindPortByIfIndex(p.IfIndex, p.Node.stpBrgIfIndex, &sp) {
+		return sp
+	}
+	return nil
+}
+
+// StpForwarding returns true when the port is in forwarding state,
+// a lag member follows the lag rather than its own stp state
+func (p *Port) StpForwarding() bool {
+	sp := p.StpPort()
+	return sp != nil && sp.Forwarding
+}
+
+// StpIsRoot returns true when the node believes it is the root bridge
+func (n *Node) StpIsRoot() bool {
+	b := n.StpBridge()
+	return b != nil && b.BridgePriority.RootBridgeId == b.BridgeIdentifier
+}
+
+// StpCheckConverged will validate that all stp enabled nodes agree on a
+// single root, that every other node has exactly one root port and that
+// the forwarding topology contains no loops
+func (s *Sim) StpCheckConverged() error {
+	var root *stp.BridgeId
+	var rootNode *Node
+	for _, n := range s.Nodes {
+		b := n.StpBridge()
+		if b == nil {
+			continue
+		}
+		if root == nil {
+			root = &b.BridgePriority.RootBridgeId
+		} else if *root != b.BridgePriority.RootBridgeId {
+			return errors.New(fmt.Sprintf("SIM: node %s root %v does not match %v", n.Name, b.BridgePriority.RootBridgeId, *root))
+		}
+		if n.StpIsRoot() {
+			rootNode = n
+		}
+	}
+	if root == nil {
+		return errors.New("SIM: no stp bridges found")
+	}
+	if rootNode == nil {
+		return errors.New(fmt.Sprintf("SIM: root %v is not a simulated node", *root))
+	}
+
+	for _, n := range s.Nodes {
+		if n.StpBridge() == nil {
+			continue
+		}
+		rootPorts := 0
+		for _, p := range n.Ports {
+			sp := p.StpPort()
+			if sp == nil {
+				continue
+			}
+			if sp.Role == stp.PortRoleRootPort {
+				rootPorts++
+			}
+			if sp.Role != sp.SelectedRole {
+				return errors.New(fmt.Sprintf("SIM: port %s role %d has not reached selected role %d", p.Name, sp.Role, sp.SelectedRole))
+			}
+		}
+		if n == rootNode && rootPorts != 0 {
+			return errors.New(fmt.Sprintf("SIM: root node %s has %d root ports", n.Name, rootPorts))
+		} else if n != rootNode && rootPorts != 1 {
+			return errors.New(fmt.Sprintf("SIM: node %s has %d root ports", n.Name, rootPorts))
+		}
+	}
+	return s.CheckLoopFree()
+}
+
+// CheckLoopFree will validate that the links which are forwarding on
+// both ends form a tree, all members of a lag count as a single link
+func (s *Sim) CheckLoopFree() error {
+	parent := make(map[*Node]*Node)
+	var find func(n *Node) *Node
+	find = func(n *Node) *Node {
+		if parent[n] == nil || parent[n] == n {
+			return n
+		}
+		parent[n] = find(parent[n])
+		return parent[n]
+	}
+
+	lagSeen := make(map[*Lag]bool)
+	for _, l := range s.Links {
+		if !l.Up || !l.A.forwarding() || !l.B.forwarding() {
+			continue
+		}
+		if l.A.lag != nil {
+			if lagSeen[l.A.lag] {
+				continue
+			}
+			lagSeen[l.A.lag] = true
+			if l.B.lag != nil {
+				lagSeen[l.B.lag] = true
+			}
+		}
+		ra := find(l.A.Node)
+		rb := find(l.B.Node)
+		if ra == rb {
+			return errors.New(fmt.Sprintf("SIM: forwarding loop through link %s-%s", l.A.Name, l.B.Name))
+		}
+		parent[ra] = rb
+	}
+	return nil
+}
+
+// forwarding returns the data plane state of the port, ports without
+// stp always forward
+func (p *Port) forwarding() bool {
+	if p.lag != nil && !p.lag.Distributing(p) {
+		return false
+	}
+	if !p.stp {
+		return true
+	}
+	return p.StpForwarding()
+}
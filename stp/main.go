@@ -28,6 +28,7 @@ import (
 	"flag"
 	"fmt"
 	"git.apache.org/thrift.git/lib/go/thrift"
+	"l2/pktio"
 	stp "l2/stp/protocol"
 	"l2/stp/rpc"
 	"stpd"
@@ -41,7 +42,15 @@ func main() {
 
 	// lookup port
 	paramsDir := flag.String("params", "./params", "Params directory")
+	pktIoType := flag.String("pktio", pktio.PktIoTypePcap, "Packet I/O backend: pcap, afpacket or memory")
 	flag.Parse()
+	if !pktio.IsSupported(*pktIoType) {
+		fmt.Println("Unsupported packet I/O backend", *pktIoType)
+		return
+	}
+	pktIoCfg := pktio.DefaultConfig()
+	pktIoCfg.Type = *pktIoType
+	stp.StpPktIoConfigSet(pktIoCfg)
 	path := *paramsDir
 	if path[len(path)-1] != '/' {
 		path = path + "/"
@@ -49,6 +58,11 @@ func main() {
 	fileName := path + "clients.json"
 	asicdConfName := path + "asicd.conf"
 
+	hwPlugin, err := stp.StpHwPluginFromParams(path)
+	if err != nil {
+		panic(err)
+	}
+
 	port := stp.GetClientPort(fileName, "stpd")
 	if port != 0 {
 		addr := fmt.Sprintf("localhost:%d", port)
@@ -63,13 +77,26 @@ func main() {
 		protocolFactory := thrift.NewTBinaryProtocolFactoryDefault()
 		server := thrift.NewTSimpleServer4(processor, transport, transportFactory, protocolFactory)
 
-		// connect to any needed services
-		stp.SaveSwitchMac(asicdConfName)
-		stp.ConnectToClients(fileName)
+		if plugin, ok := hwPlugin.(*stp.LinuxBridgeHwPlugin); ok {
+			// ports and switch mac are learned from the kernel bridge
+			if err = plugin.Init(); err != nil {
+				panic(err)
+			}
+		} else {
+			// connect to any needed services
+			stp.SaveSwitchMac(asicdConfName)
+			stp.ConnectToClients(fileName)
+		}
+		stp.StpHwPluginSet(hwPlugin)
 
 		// lets replay any config that is in the db
 		handler.ReadConfigFromDB()
 
+		// grpc and json/http gateway api run alongside the thrift server
+		if err = rpc.StartGrpcServer(handler, fileName); err != nil {
+			stp.StpLogger("ERROR", fmt.Sprintf("grpc server not started %s", err))
+		}
+
 		// Start keepalive routine
 		go keepalive.InitKeepAlive("stpd", path)
 
@@ -0,0 +1,361 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// bpdufilter_test.go
+package stp
+
+import (
+	"fmt"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"l2/errdisable"
+	"l2/pktio"
+	"testing"
+	"time"
+)
+
+var bpduFilterTestBridgeAddr = [6]uint8{0x00, 0x66, 0x66, 0x66, 0x66, 0x66}
+
+// UsedForTestOnlyBpduFilterSetup creates an edge port on a root bridge,
+// the port machines are only instanciated so that notifications are
+// queued.  The port transmits on a memory wire, the returned handle is
+// the peer end of the wire
+func UsedForTestOnlyBpduFilterSetup(ifindex int32, filter BpduFilterMode, t *testing.T) (*StpPort, pktio.Handle) {
+	bridgeId := CreateBridgeId(bpduFilterTestBridgeAddr, 0x8000, 0)
+	b := &Bridge{
+		BrgIfIndex:       DEFAULT_STP_BRIDGE_VLAN,
+		Vlan:             DEFAULT_STP_BRIDGE_VLAN,
+		ForceVersion:     2,
+		BridgeIdentifier: bridgeId,
+		BridgePriority: PriorityVector{
+			RootBridgeId:       bridgeId,
+			DesignatedBridgeId: bridgeId,
+		},
+	}
+	p := &StpPort{
+		IfIndex:      ifindex,
+		BrgIfIndex:   b.BrgIfIndex,
+		PortEnabled:  true,
+		PortId:       uint16(ifindex),
+		Priority:     0x80,
+		PortPathCost: 20000,
+		AdminEdge:    true,
+		OperEdge:     true,
+		SendRSTP:     true,
+		RstpVersion:  true,
+		InfoIs:       PortInfoStateMine,
+		Role:         PortRoleDesignatedPort,
+		SelectedRole: PortRoleDesignatedPort,
+		BpduFilter:   filter,
+		b:            b,
+	}
+	PimMachineFSMBuild(p)
+	PrxmMachineFSMBuild(p)
+	PtxmMachineFSMBuild(p)
+	BdmMachineFSMBuild(p)
+	PtmMachineFSMBuild(p)
+	TcMachineFSMBuild(p)
+	PstMachineFSMBuild(p)
+	PpmmMachineFSMBuild(p)
+	PrtMachineFSMBuild(p)
+
+	// admin edge port starts in the edge state of the bridge detection
+	// machine
+	p.BdmMachineFsm.Machine.ProcessEvent("TEST", BdmEventBeginAdminEdge, nil)
+	if p.BdmMachineFsm.Machine.Curr.CurrentState() != BdmStateEdge {
+		t.Error("Failed bridge detection machine not in edge state", p.BdmMachineFsm.Machine.Curr.CurrentState())
+	}
+
+	name := UsedForTestOnlyBpduFilterIfName(ifindex)
+	pktio.ConnectWire(name, name+"peer")
+	cfg := pktio.Config{Type: pktio.PktIoTypeMemory}
+	handle, err := pktio.Open(name, cfg)
+	if err != nil {
+		t.Fatal("Failed to open memory wire", name, err)
+	}
+	peer, err := pktio.Open(name+"peer", cfg)
+	if err != nil {
+		t.Fatal("Failed to open memory wire", name+"peer", err)
+	}
+	p.handle = handle
+	return p, peer
+}
+
+func UsedForTestOnlyBpduFilterIfName(ifindex int32) string {
+	return fmt.Sprintf("bpdufilter%d", ifindex)
+}
+
+func UsedForTestOnlyBpduFilterTeardown(p *StpPort, peer pktio.Handle) {
+	p.handle.Close()
+	peer.Close()
+	pktio.DisconnectWire(UsedForTestOnlyBpduFilterIfName(p.IfIndex))
+	StpErrDisableGet().Delete(p.IfIndex)
+}
+
+// UsedForTestOnlyBpduFilterRstpPacket is an RSTP BPDU from a designated
+// bridge which is superior to the test bridge
+func UsedForTestOnlyBpduFilterRstpPacket(t *testing.T) gopacket.Packet {
+	eth := layers.Ethernet{
+		SrcMAC:       []byte{0x00, 0x19, 0x06, 0xEA, 0xB8, 0x81},
+		DstMAC:       layers.BpduDMAC,
+		EthernetType: layers.EthernetTypeLLC,
+		Length:       uint16(layers.STPProtocolLength + 3),
+	}
+	llc := layers.LLC{
+		DSAP:    0x42,
+		IG:      false,
+		SSAP:    0x42,
+		CR:      false,
+		Control: 0x03,
+	}
+	rstp := layers.RSTP{
+		ProtocolId:        layers.RSTPProtocolIdentifier,
+		ProtocolVersionId: layers.RSTPProtocolVersion,
+		BPDUType:          layers.BPDUTypeRSTP,
+		Flags:             0,
+		RootId:            [8]byte{0x10, 0x00, 0x00, 0x19, 0x06, 0xEA, 0xB8, 0x80},
+		RootPathCost:      0,
+		BridgeId:          [8]byte{0x10, 0x00, 0x00, 0x19, 0x06, 0xEA, 0xB8, 0x80},
+		PortId:            0x8001,
+		MsgAge:            0,
+		MaxAge:            20 << 8,
+		HelloTime:         2 << 8,
+		FwdDelay:          15 << 8,
+		Version1Length:    0,
+	}
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{
+		FixLengths:       true,
+		ComputeChecksums: true,
+	}
+	if err := gopacket.SerializeLayers(buf, opts, &eth, &llc, &rstp); err != nil {
+		t.Fatal("Failed to serialize rstp bpdu", err)
+	}
+	return gopacket.NewPacket(buf.Bytes(), layers.LayerTypeEthernet, gopacket.Default)
+}
+
+// UsedForTestOnlyBpduFilterTxCount transmits from the port transmit
+// machine and returns the number of frames seen on the peer end
+func UsedForTestOnlyBpduFilterTxCount(p *StpPort, peer pktio.Handle) int {
+	p.PtxmMachineFsm.PtxmMachineTransmitRSTP(*p.PtxmMachineFsm.Machine, nil)
+	cnt := 0
+	for {
+		select {
+		case <-peer.Packets():
+			cnt++
+		default:
+			return cnt
+		}
+	}
+}
+
+func TestBpduFilterInterfaceRxDrop(t *testing.T) {
+	mock := NewMockHwPlugin()
+	prev := StpHwPluginGet()
+	StpHwPluginSet(mock)
+	defer StpHwPluginSet(prev)
+
+	p, peer := UsedForTestOnlyBpduFilterSetup(1, StpBpduFilterInterface, t)
+	defer UsedForTestOnlyBpduFilterTeardown(p, peer)
+	// bpdu guard is never triggered as the bpdu is dropped before
+	// reaching the port receive machine
+	p.BpduGuard = true
+
+	for i := uint64(1); i <= 2; i++ {
+		ProcessBpduFrame(p, BPDURxTypeRSTP, UsedForTestOnlyBpduFilterRstpPacket(t))
+		if p.BpduFilterRx != i {
+			t.Error("Failed bpdu not counted as filtered", p.BpduFilterRx, i)
+		}
+	}
+	if p.RcvdBPDU ||
+		len(p.PrxmMachineFsm.PrxmRxBpduPkt) != 0 {
+		t.Error("Failed filtered bpdu passed to the port receive machine", p.RcvdBPDU, len(p.PrxmMachineFsm.PrxmRxBpduPkt))
+	}
+	if p.BpduRx != 0 ||
+		p.RstpRx != 0 {
+		t.Error("Failed filtered bpdu counted as received", p.BpduRx, p.RstpRx)
+	}
+	if p.BpduGuardDetected() ||
+		len(mock.Calls(MockHwOpBPDUGuardDetected)) != 0 {
+		t.Error("Failed filtered bpdu triggered bpdu guard")
+	}
+	if !p.OperEdge {
+		t.Error("Failed filtered bpdu cleared operedge")
+	}
+
+	// nothing is transmitted regardless of operedge
+	if UsedForTestOnlyBpduFilterTxCount(p, peer) != 0 ||
+		p.BpduFilterTx != 1 ||
+		p.TxCount != 0 {
+		t.Error("Failed bpdu transmitted with interface bpdu filter", p.BpduFilterTx, p.TxCount)
+	}
+
+	// without the filter the bpdu is passed on to the port receive machine
+	p.BpduFilter = StpBpduFilterDisabled
+	ProcessBpduFrame(p, BPDURxTypeRSTP, UsedForTestOnlyBpduFilterRstpPacket(t))
+	if p.BpduFilterRx != 2 {
+		t.Error("Failed bpdu counted as filtered with bpdu filter disabled", p.BpduFilterRx)
+	}
+	if !p.RcvdBPDU ||
+		len(p.PrxmMachineFsm.PrxmRxBpduPkt) != 1 {
+		t.Error("Failed bpdu not passed to the port receive machine", p.RcvdBPDU, len(p.PrxmMachineFsm.PrxmRxBpduPkt))
+	}
+	<-p.PrxmMachineFsm.PrxmRxBpduPkt
+}
+
+func TestBpduFilterGlobalRevertOperEdge(t *testing.T) {
+	mock := NewMockHwPlugin()
+	prev := StpHwPluginGet()
+	StpHwPluginSet(mock)
+	defer StpHwPluginSet(prev)
+
+	p, peer := UsedForTestOnlyBpduFilterSetup(2, StpBpduFilterGlobal, t)
+	defer UsedForTestOnlyBpduFilterTeardown(p, peer)
+
+	// edge port does not transmit
+	if UsedForTestOnlyBpduFilterTxCount(p, peer) != 0 ||
+		p.BpduFilterTx != 1 ||
+		p.TxCount != 0 {
+		t.Error("Failed bpdu transmitted on edge port with global bpdu filter", p.BpduFilterTx, p.TxCount)
+	}
+
+	// the bpdu is passed to the port receive machine
+	ProcessBpduFrame(p, BPDURxTypeRSTP, UsedForTestOnlyBpduFilterRstpPacket(t))
+	if p.BpduFilterRx != 0 {
+		t.Error("Failed bpdu dropped with global bpdu filter", p.BpduFilterRx)
+	}
+	var rx RxBpduPdu
+	select {
+	case rx = <-p.PrxmMachineFsm.PrxmRxBpduPkt:
+	default:
+		t.Fatal("Failed bpdu not passed to the port receive machine")
+	}
+
+	prxm := p.PrxmMachineFsm
+	prxm.Machine.ProcessEvent("TEST", PrxmEventBegin, nil)
+	if prxm.Machine.Curr.CurrentState() != PrxmStateDiscard {
+		t.Fatal("Failed port receive machine not in discard state", prxm.Machine.Curr.CurrentState())
+	}
+	// the bpdu was received before the port receive machine discard
+	// state cleared it
+	p.RcvdBPDU = true
+	if rv := prxm.Machine.ProcessEvent("TEST", PrxmEventRcvdBpduAndPortEnabled, rx); rv != nil {
+		t.Fatal("Failed port receive machine did not process bpdu", rv)
+	}
+	if prxm.Machine.Curr.CurrentState() != PrxmStateReceive {
+		t.Error("Failed port receive machine not in receive state", prxm.Machine.Curr.CurrentState())
+	}
+
+	// even though admin edge is set the port reverts to a normal stp port
+	if p.OperEdge {
+		t.Error("Failed operedge not cleared by bpdu with global bpdu filter")
+	}
+	select {
+	case event := <-p.BdmMachineFsm.BdmEvents:
+		if event.e != BdmEventNotOperEdge {
+			t.Error("Failed operedge change not notified to the bridge detection machine", event.e)
+		}
+	default:
+		t.Error("Failed no event sent to the bridge detection machine")
+	}
+
+	// bpdu transmission resumes
+	if UsedForTestOnlyBpduFilterTxCount(p, peer) != 1 ||
+		p.BpduFilterTx != 1 ||
+		p.TxCount != 1 ||
+		p.RstpTx != 1 {
+		t.Error("Failed bpdu transmission not resumed after operedge cleared", p.BpduFilterTx, p.TxCount, p.RstpTx)
+	}
+}
+
+func TestBpduFilterGlobalBpduGuard(t *testing.T) {
+	mock := NewMockHwPlugin()
+	prev := StpHwPluginGet()
+	StpHwPluginSet(mock)
+	defer StpHwPluginSet(prev)
+
+	p, peer := UsedForTestOnlyBpduFilterSetup(3, StpBpduFilterGlobal, t)
+	defer UsedForTestOnlyBpduFilterTeardown(p, peer)
+	p.BpduGuard = true
+
+	evtChan := make(chan errdisable.Event, 2)
+	StpErrDisableGet().RegisterCallback("bpdufiltertest", func(evt errdisable.Event) {
+		if evt.IfIndex == p.IfIndex {
+			evtChan <- evt
+		}
+	})
+	defer StpErrDisableGet().DeRegisterCallback("bpdufiltertest")
+
+	p.PrxmMachineMain()
+	wait := make(chan string, 1)
+	p.PrxmMachineFsm.PrxmEvents <- MachineEvent{
+		e:            PrxmEventBegin,
+		src:          "TEST",
+		responseChan: wait,
+	}
+	<-wait
+
+	// bpdu guard takes precedence over the global bpdu filter on an
+	// admin edge port
+	ProcessBpduFrame(p, BPDURxTypeRSTP, UsedForTestOnlyBpduFilterRstpPacket(t))
+	select {
+	case evt := <-evtChan:
+		if evt.Type != errdisable.EventErrDisabled ||
+			evt.Cause != errdisable.CauseBpduGuard {
+			t.Error("Failed port not errdisabled by bpdu guard", evt.Type, evt.Cause)
+		}
+	case <-time.After(time.Second):
+		t.Error("Failed bpdu guard did not errdisable the port")
+	}
+	// the machine has finished processing the bpdu once stopped
+	p.PrxmMachineFsm.Stop()
+
+	if !p.BpduGuardDetected() {
+		t.Error("Failed bpdu guard not detected")
+	}
+	calls := mock.Calls(MockHwOpBPDUGuardDetected)
+	if len(calls) != 1 ||
+		calls[0].IfIndex != p.IfIndex ||
+		!calls[0].Enable {
+		t.Error("Failed bpdu guard not sent to the hw plugin", calls)
+	}
+	if p.BpduFilterRx != 0 ||
+		p.BpduRx != 1 ||
+		p.RstpRx != 1 {
+		t.Error("Failed bpdu not counted as received", p.BpduFilterRx, p.BpduRx, p.RstpRx)
+	}
+	// the bpdu was not processed so the port is still an edge port
+	if !p.OperEdge ||
+		p.PrxmMachineFsm.Machine.Curr.CurrentState() != PrxmStateDiscard {
+		t.Error("Failed bpdu processed on bpdu guard port", p.OperEdge, p.PrxmMachineFsm.Machine.Curr.CurrentState())
+	}
+
+	if err := StpPortErrDisableRecover(p.IfIndex); err != nil {
+		t.Error("Failed to recover port", err)
+	}
+	calls = mock.Calls(MockHwOpBPDUGuardDetected)
+	if len(calls) != 2 ||
+		calls[1].Enable {
+		t.Error("Failed bpdu guard recovery not sent to the hw plugin", calls)
+	}
+}
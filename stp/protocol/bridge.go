@@ -41,6 +41,8 @@ var StpBridgeMac [6]uint8
 type BridgeId [8]uint8
 type BridgeKey struct {
 	Vlan uint16
+	// only set when the bridge was created with an explicit BrgIfIndex
+	BrgIfIndex int32
 }
 
 type cfgFileJson struct {
@@ -72,6 +74,10 @@ type Bridge struct {
 	ForceVersion int32
 	TxHoldCount  uint64
 
+	// topology change fdb flush
+	FdbFlushPolicy   FdbFlushPolicy
+	FdbFlushHoldDown int32
+
 	// Vlan
 	Vlan uint16
 
@@ -85,8 +91,29 @@ type Bridge struct {
 	// hw stgId
 	StgId int32
 
+	// 13.8 MST Configuration Identifier
+	MstConfigId MstConfigId
+	// 13.26.4 MaxHops
+	MaxHops uint8
+	// MSTI's keyed by MSTID
+	MstiMap map[uint16]*MstInstance
+	// 13.26.x CIST regional root, internal root path cost and remaining
+	// hops transmitted in MST BPDU's
+	CistRegionalRootId       BridgeId
+	CistInternalRootPathCost uint32
+	CistRemainingHops        uint8
+	// protects the MSTI information which is accessed from the port rx,
+	// role selection, timer, state and tx machines
+	mstpMutex sync.Mutex
+
 	// a way to sync all machines
 	wg sync.WaitGroup
+
+	// key in the BridgeMapTable
+	key BridgeKey
+
+	// topology change telemetry
+	tc tcTelemetry
 }
 
 type PriorityVector struct {
@@ -130,7 +157,13 @@ func NewStpBridge(c *StpBridgeConfig) *Bridge {
 		vlan = DEFAULT_STP_BRIDGE_VLAN
 	}
 
-	bridgeId := CreateBridgeId(StpBridgeMac, c.Priority, vlan)
+	// more than one bridge may exist within the simulator
+	// so the bridge address may be supplied
+	bridgeMac := StpBridgeMac
+	if c.BridgeMac != [6]uint8{} {
+		bridgeMac = c.BridgeMac
+	}
+	bridgeId := CreateBridgeId(bridgeMac, c.Priority, vlan)
 
 	b := &Bridge{
 		Begin:            true,
@@ -156,12 +189,31 @@ func NewStpBridge(c *StpBridgeConfig) *Bridge {
 			MessageAge: 0}, // this will be set once a port is set as root
 		TxHoldCount: uint64(c.TxHoldCount),
 		Vlan:        vlan,
+		MaxHops:     c.MaxHops,
+		MstiMap:     make(map[uint16]*MstInstance),
 	}
+	b.FdbFlushPolicy = StpFdbFlushPolicyOperGet(FdbFlushPolicy(c.FdbFlushPolicy))
+	b.FdbFlushHoldDown = c.FdbFlushHoldDown
+
+	if b.MaxHops == 0 {
+		b.MaxHops = MstpMaxHopsDefault
+	}
+	// 13.8 default configuration name is the bridge address
+	mstConfigName := c.MstConfigName
+	if mstConfigName == "" {
+		mstConfigName = net.HardwareAddr(bridgeMac[:]).String()
+	}
+	b.MstConfigIdSet(mstConfigName, c.MstConfigRevision)
+	// bridge is its own regional root until it hears from the region
+	b.CistRegionalRootId = b.BridgeIdentifier
+	b.CistRemainingHops = b.MaxHops
 
 	key := BridgeKey{
-		Vlan: b.Vlan,
+		Vlan:       b.Vlan,
+		BrgIfIndex: c.BrgIfIndex,
 	}
 
+	b.key = key
 	BridgeMapTable[key] = b
 
 	if len(BridgeListTable) == 0 {
@@ -176,9 +228,12 @@ func NewStpBridge(c *StpBridgeConfig) *Bridge {
 	} else {
 		b.BrgIfIndex = int32(c.Vlan)
 	}
+	if c.BrgIfIndex != 0 {
+		b.BrgIfIndex = c.BrgIfIndex
+	}
 
 	// lets create the stg group
-	b.StgId = asicdCreateStgBridge([]uint16{b.Vlan})
+	b.StgId = StpHwPluginGet().CreateStg([]uint16{b.Vlan})
 	StpLogger("INFO", fmt.Sprintf("NEW BRIDGE: %#v\n", b))
 	return b
 }
@@ -202,18 +257,18 @@ func DelStpBridge(b *Bridge, force bool) {
 	}
 	b.Stop()
 
-	key := BridgeKey{
-		Vlan: b.Vlan,
+	for _, msti := range b.MstiMap {
+		DelMstInstance(msti)
 	}
 
-	delete(BridgeMapTable, key)
+	delete(BridgeMapTable, b.key)
 	for i, delBrg := range BridgeListTable {
 		if delBrg.BridgeIdentifier == b.BridgeIdentifier {
 			if len(BridgeListTable) == 1 {
 				BridgeListTable = nil
 			} else {
 				BridgeListTable = append(BridgeListTable[:i], BridgeListTable[i+1:]...)
-				asicdDeleteStgBridge(b.StgId, []uint16{b.Vlan})
+				StpHwPluginGet().DeleteStg(b.StgId, []uint16{b.Vlan})
 			}
 		}
 	}
@@ -27,6 +27,7 @@ package stp
 import (
 	"errors"
 	"fmt"
+	"net"
 )
 
 type StpBridgeConfig struct {
@@ -38,6 +39,26 @@ type StpBridgeConfig struct {
 	ForceVersion int32
 	TxHoldCount  int32
 	Vlan         uint16
+	// MSTP region
+	MstConfigName     string
+	MstConfigRevision uint16
+	MaxHops           uint8
+	// optional, allow more than one bridge of the same vlan to exist
+	// within a process as is done by the simulator.  By default the
+	// BrgIfIndex is derived from the vlan and the address is the switch mac
+	BrgIfIndex int32
+	BridgeMac  [6]uint8
+	// fdb flush on topology change, per port (default) or whole stg.
+	// Hold down in seconds rate limits the flushes of a port, 0 disabled
+	FdbFlushPolicy   int32
+	FdbFlushHoldDown int32
+}
+
+type StpMstiConfig struct {
+	BrgIfIndex int32
+	Mstid      uint16
+	Priority   uint16
+	Vlans      []uint16
 }
 
 type StpPortConfig struct {
@@ -53,11 +74,21 @@ type StpPortConfig struct {
 	BridgeAssurance   bool
 	BpduGuard         bool
 	BpduGuardInterval int32
+	RootGuard         bool
+	LoopGuard         bool
+	BpduFilter        int32
 }
 
 var StpPortConfigMap map[int32]StpPortConfig
 var StpBridgeConfigMap map[int32]StpBridgeConfig
 
+type StpMstiConfigKey struct {
+	BrgIfIndex int32
+	Mstid      uint16
+}
+
+var StpMstiConfigMap map[StpMstiConfigKey]StpMstiConfig
+
 func StpPortConfigGet(pId int32) *StpPortConfig {
 	c, ok := StpPortConfigMap[pId]
 	if ok {
@@ -120,10 +151,32 @@ func StpBrgConfigParamCheck(c *StpBridgeConfig) error {
 
 	// 1 == STP
 	// 2 == RSTP
-	// 3 == MSTP currently not support
+	// 3 == MSTP
 	if c.ForceVersion != 1 &&
-		c.ForceVersion != 2 {
-		return errors.New(fmt.Sprintf("Invalid Bridge Force Version %d valid 1 (STP) 2 (RSTP)", c.ForceVersion))
+		c.ForceVersion != 2 &&
+		c.ForceVersion != MstpProtocolVersion {
+		return errors.New(fmt.Sprintf("Invalid Bridge Force Version %d valid 1 (STP) 2 (RSTP) 3 (MSTP)", c.ForceVersion))
+	}
+
+	if c.FdbFlushPolicy != int32(StpFdbFlushPolicyPort) &&
+		c.FdbFlushPolicy != StpFdbFlushPolicyStg {
+		return errors.New(fmt.Sprintf("Invalid Bridge Fdb Flush Policy %d valid 0 (PORT) 1 (STG)", c.FdbFlushPolicy))
+	}
+
+	if c.FdbFlushHoldDown < 0 ||
+		c.FdbFlushHoldDown > 60 {
+		return errors.New(fmt.Sprintf("Invalid Bridge Fdb Flush Hold Down %d valid range 0 - 60", c.FdbFlushHoldDown))
+	}
+
+	if len(c.MstConfigName) > MstpConfigNameLength {
+		return errors.New(fmt.Sprintf("Invalid Bridge MST Config Name %s max length %d", c.MstConfigName, MstpConfigNameLength))
+	}
+
+	// zero will be converted to use default
+	if c.MaxHops != 0 &&
+		(c.MaxHops < MstpMaxHopsMin ||
+			c.MaxHops > MstpMaxHopsMax) {
+		return errors.New(fmt.Sprintf("Invalid Bridge Max Hops %d valid range %d - %d", c.MaxHops, MstpMaxHopsMin, MstpMaxHopsMax))
 	}
 
 	if c.TxHoldCount < 1 ||
@@ -142,6 +195,37 @@ func StpBrgConfigParamCheck(c *StpBridgeConfig) error {
 	return nil
 }
 
+func StpMstiConfigParamCheck(c *StpMstiConfig) error {
+	var b *Bridge
+
+	if !StpFindBridgeByIfIndex(c.BrgIfIndex, &b) {
+		return errors.New(fmt.Sprintf("Invalid MSTI %d Must be created against a valid bridge interface %d", c.Mstid, c.BrgIfIndex))
+	}
+
+	if c.Mstid < 1 ||
+		c.Mstid > MstpMaxMsti {
+		return errors.New(fmt.Sprintf("Invalid MSTI %d valid range 1 - %d", c.Mstid, MstpMaxMsti))
+	}
+
+	// 13.26.2 priority is set in increments of 4096
+	if c.Priority%4096 != 0 ||
+		c.Priority > 61440 {
+		return errors.New(fmt.Sprintf("Invalid MSTI %d Priority %d valid values 0 - 61440 in increments of 4096", c.Mstid, c.Priority))
+	}
+
+	for _, vlan := range c.Vlans {
+		if vlan < 1 ||
+			vlan > 4094 {
+			return errors.New(fmt.Sprintf("Invalid MSTI %d Vlan %d valid range 1 - 4094", c.Mstid, vlan))
+		}
+		// a vlan may only be mapped to a single msti
+		if mstid := b.MstiFindVlan(vlan); mstid != MstpCistMstid && mstid != c.Mstid {
+			return errors.New(fmt.Sprintf("Invalid MSTI %d Vlan %d already mapped to MSTI %d", c.Mstid, vlan, mstid))
+		}
+	}
+	return nil
+}
+
 func StpPortConfigParamCheck(c *StpPortConfig) error {
 
 	var p *StpPort
@@ -188,6 +272,26 @@ func StpPortConfigParamCheck(c *StpPortConfig) error {
 			p.BridgeAssurance {
 			return errors.New(fmt.Sprintf("Invalid Port %d Bridge Assurance only available on non Edge Ports", c.IfIndex))
 		}
+
+		if (p.OperEdge || c.AdminEdgePort) &&
+			c.LoopGuard {
+			return errors.New(fmt.Sprintf("Invalid Port %d Loop Guard only available on non Edge Ports", c.IfIndex))
+		}
+	}
+
+	if c.BpduFilter != int32(StpBpduFilterDisabled) &&
+		c.BpduFilter != StpBpduFilterGlobal &&
+		c.BpduFilter != StpBpduFilterInterface {
+		return errors.New(fmt.Sprintf("Invalid Port %d Bpdu Filter %d valid values 0 (DISABLED), 1 (GLOBAL), 2 (INTERFACE)", c.IfIndex, c.BpduFilter))
+	}
+
+	if c.BpduFilter == StpBpduFilterInterface &&
+		(c.BridgeAssurance || c.LoopGuard) {
+		return errors.New(fmt.Sprintf("Invalid Port %d Bpdu Filter interface mode can not be used with Bridge Assurance or Loop Guard", c.IfIndex))
+	}
+
+	if c.RootGuard && c.LoopGuard {
+		return errors.New(fmt.Sprintf("Invalid Port %d Root Guard and Loop Guard are mutually exclusive", c.IfIndex))
 	}
 	/*
 		Taken care of as part of create
@@ -216,7 +320,8 @@ func StpBridgeCreate(c *StpBridgeConfig) error {
 	}
 
 	key := BridgeKey{
-		Vlan: c.Vlan,
+		Vlan:       c.Vlan,
+		BrgIfIndex: c.BrgIfIndex,
 	}
 
 	if !StpFindBridgeById(key, &b) {
@@ -240,10 +345,16 @@ func StpBridgeDelete(c *StpBridgeConfig) error {
 	var b *Bridge
 
 	key := BridgeKey{
-		Vlan: c.Vlan,
+		Vlan:       c.Vlan,
+		BrgIfIndex: c.BrgIfIndex,
 	}
 	if StpFindBridgeById(key, &b) {
 		DelStpBridge(b, true)
+		for key, _ := range StpMstiConfigMap {
+			if key.BrgIfIndex == b.BrgIfIndex {
+				delete(StpMstiConfigMap, key)
+			}
+		}
 		for _, btmp := range StpBridgeConfigMap {
 			if btmp.Vlan == c.Vlan {
 				delete(StpBridgeConfigMap, b.BrgIfIndex)
@@ -255,6 +366,37 @@ func StpBridgeDelete(c *StpBridgeConfig) error {
 	return nil
 }
 
+func StpMstiCreate(c *StpMstiConfig) error {
+	var b *Bridge
+	if StpFindBridgeByIfIndex(c.BrgIfIndex, &b) {
+		if _, ok := b.MstiMap[c.Mstid]; ok {
+			return errors.New(fmt.Sprintf("Invalid config, MSTI %d bridge %d already exists", c.Mstid, c.BrgIfIndex))
+		}
+		err := StpMstiConfigParamCheck(c)
+		if err != nil {
+			return err
+		}
+		NewMstInstance(b, c.Mstid, c.Priority, c.Vlans)
+		StpMstiConfigMap[StpMstiConfigKey{BrgIfIndex: c.BrgIfIndex, Mstid: c.Mstid}] = *c
+		b.MstiReselect("CONFIG: MstiCreate")
+		return nil
+	}
+	return errors.New(fmt.Sprintf("Invalid config, bridge %d does not exist for MSTI %d", c.BrgIfIndex, c.Mstid))
+}
+
+func StpMstiDelete(c *StpMstiConfig) error {
+	var b *Bridge
+	if StpFindBridgeByIfIndex(c.BrgIfIndex, &b) {
+		if msti, ok := b.MstiMap[c.Mstid]; ok {
+			DelMstInstance(msti)
+			delete(StpMstiConfigMap, StpMstiConfigKey{BrgIfIndex: c.BrgIfIndex, Mstid: c.Mstid})
+			b.MstiReselect("CONFIG: MstiDelete")
+			return nil
+		}
+	}
+	return errors.New(fmt.Sprintf("Invalid config, MSTI %d bridge %d does not exists", c.Mstid, c.BrgIfIndex))
+}
+
 func StpPortCreate(c *StpPortConfig) error {
 	var p *StpPort
 	var b *Bridge
@@ -295,6 +437,11 @@ func StpPortDelete(c *StpPortConfig) error {
 		}
 		if !foundPort {
 			delete(StpPortConfigMap, c.IfIndex)
+			// port is no longer under stp control, bring it back up
+			if p.BpduGuardDetected() {
+				StpPortErrDisableRecover(p.IfIndex)
+			}
+			StpErrDisableGet().Delete(p.IfIndex)
 		}
 	} else {
 		return errors.New(fmt.Sprintf("Invalid config, port %d bridge %d does not exists", c.IfIndex, c.BrgIfIndex))
@@ -308,6 +455,7 @@ func StpPortAddToBridge(pId int32, brgifindex int32) {
 	if StpFindPortByIfIndex(pId, brgifindex, &p) && StpFindBridgeByIfIndex(brgifindex, &b) {
 		p.BridgeId = b.BridgeIdentifier
 		b.StpPorts = append(b.StpPorts, pId)
+		b.MstiPortAdd(p)
 		p.BEGIN(false)
 
 		// check all other bridge ports to see if any are AdminEdge
@@ -362,7 +510,7 @@ func StpPortEnable(pId int32, bId int32, enable bool) error {
 					p.PortEnabled = false
 				}
 			} else {
-				if asicdGetPortLinkStatus(pId) {
+				if StpHwPluginGet().GetPortLinkStatus(pId) {
 					defer p.NotifyPortEnabled("CONFIG: ", p.PortEnabled, true)
 					p.PortEnabled = true
 				}
@@ -438,6 +586,7 @@ func StpBrgForceVersion(bId int32, version int32) error {
 	if StpFindBridgeByIfIndex(bId, &b) {
 		// version 1 STP
 		// version 2 RSTP
+		// version 3 MSTP
 		if b.ForceVersion != version {
 			c := StpBrgConfigGet(bId)
 			prevval := c.ForceVersion
@@ -445,6 +594,14 @@ func StpBrgForceVersion(bId int32, version int32) error {
 			err := StpBrgConfigParamCheck(c)
 			if err == nil {
 				b.ForceVersion = version
+				// msti's only exist in hw while running MSTP
+				for _, msti := range b.MstiListGet() {
+					if b.MstpEnabled() {
+						msti.Activate()
+					} else {
+						msti.Deactivate()
+					}
+				}
 				for _, pId := range b.StpPorts {
 					if StpFindPortByIfIndex(pId, b.BrgIfIndex, &p) {
 						if b.ForceVersion == 1 {
@@ -657,6 +814,175 @@ func StpBrgTxHoldCountSet(bId int32, txholdcount uint16) error {
 	return nil
 }
 
+func StpBrgFdbFlushPolicySet(bId int32, policy int32) error {
+	var b *Bridge
+	if StpFindBridgeByIfIndex(bId, &b) {
+		c := StpBrgConfigGet(bId)
+		prevval := c.FdbFlushPolicy
+		c.FdbFlushPolicy = policy
+		err := StpBrgConfigParamCheck(c)
+		if err == nil {
+			b.FdbFlushPolicy = StpFdbFlushPolicyOperGet(FdbFlushPolicy(policy))
+		} else {
+			c.FdbFlushPolicy = prevval
+		}
+		return err
+	}
+	return errors.New(fmt.Sprintf("Invalid bridge %d supplied for setting Fdb Flush Policy", bId))
+}
+
+func StpBrgFdbFlushHoldDownSet(bId int32, holddown int32) error {
+	var b *Bridge
+	if StpFindBridgeByIfIndex(bId, &b) {
+		c := StpBrgConfigGet(bId)
+		prevval := c.FdbFlushHoldDown
+		c.FdbFlushHoldDown = holddown
+		err := StpBrgConfigParamCheck(c)
+		if err == nil {
+			b.FdbFlushHoldDown = holddown
+		} else {
+			c.FdbFlushHoldDown = prevval
+		}
+		return err
+	}
+	return errors.New(fmt.Sprintf("Invalid bridge %d supplied for setting Fdb Flush Hold Down", bId))
+}
+
+func StpBrgMaxHopsSet(bId int32, maxhops uint8) error {
+	var b *Bridge
+	if StpFindBridgeByIfIndex(bId, &b) {
+		c := StpBrgConfigGet(bId)
+		prevval := c.MaxHops
+		c.MaxHops = maxhops
+		err := StpBrgConfigParamCheck(c)
+		if err == nil {
+			if maxhops == 0 {
+				maxhops = MstpMaxHopsDefault
+			}
+			StpBridgeConfigMap[bId] = *c
+			b.mstpMutex.Lock()
+			b.MaxHops = maxhops
+			// 13.26.4 regional root of the cist and each msti originates max hops
+			if b.CistRegionalRootId == b.BridgeIdentifier {
+				b.CistRemainingHops = maxhops
+			}
+			for _, msti := range b.MstiMap {
+				if msti.RootPortId == 0 {
+					msti.RemainingHops = maxhops
+				}
+			}
+			b.mstpMutex.Unlock()
+		} else {
+			c.MaxHops = prevval
+		}
+		return err
+	}
+	return errors.New(fmt.Sprintf("Invalid bridge %d supplied for setting Max Hops", bId))
+}
+
+func StpBrgMstConfigNameSet(bId int32, name string) error {
+	var b *Bridge
+	if StpFindBridgeByIfIndex(bId, &b) {
+		c := StpBrgConfigGet(bId)
+		prevval := c.MstConfigName
+		c.MstConfigName = name
+		err := StpBrgConfigParamCheck(c)
+		if err == nil {
+			if name == "" {
+				mac := GetBridgeAddrFromBridgeId(b.BridgeIdentifier)
+				name = net.HardwareAddr(mac[:]).String()
+			}
+			StpBridgeConfigMap[bId] = *c
+			b.MstConfigIdSet(name, b.MstConfigId.Revision)
+			b.MstiReselect("CONFIG: MstConfigNameSet")
+		} else {
+			c.MstConfigName = prevval
+		}
+		return err
+	}
+	return errors.New(fmt.Sprintf("Invalid bridge %d supplied for setting MST Config Name", bId))
+}
+
+func StpBrgMstConfigRevisionSet(bId int32, revision uint16) error {
+	var b *Bridge
+	if StpFindBridgeByIfIndex(bId, &b) {
+		if b.MstConfigId.Revision != revision {
+			c := StpBrgConfigGet(bId)
+			prevval := c.MstConfigRevision
+			c.MstConfigRevision = revision
+			err := StpBrgConfigParamCheck(c)
+			if err == nil {
+				StpBridgeConfigMap[bId] = *c
+				b.MstConfigIdSet(MstConfigNameToString(b.MstConfigId.Name), revision)
+				b.MstiReselect("CONFIG: MstConfigRevisionSet")
+			} else {
+				c.MstConfigRevision = prevval
+			}
+			return err
+		}
+		return nil
+	}
+	return errors.New(fmt.Sprintf("Invalid bridge %d supplied for setting MST Config Revision", bId))
+}
+
+func StpMstiPrioritySet(bId int32, mstid uint16, priority uint16) error {
+	var b *Bridge
+	if StpFindBridgeByIfIndex(bId, &b) {
+		if msti, ok := b.MstiMap[mstid]; ok {
+			if msti.Priority != priority {
+				key := StpMstiConfigKey{BrgIfIndex: bId, Mstid: mstid}
+				c := StpMstiConfigMap[key]
+				c.Priority = priority
+				err := StpMstiConfigParamCheck(&c)
+				if err == nil {
+					StpMstiConfigMap[key] = c
+					b.mstpMutex.Lock()
+					msti.Priority = priority
+					msti.BridgeIdentifier = CreateBridgeId(GetBridgeAddrFromBridgeId(b.BridgeIdentifier), priority, mstid)
+					msti.BridgePriority.RootBridgeId = msti.BridgeIdentifier
+					msti.BridgePriority.DesignatedBridgeId = msti.BridgeIdentifier
+					b.mstpMutex.Unlock()
+					b.MstiReselect("CONFIG: MstiPrioritySet")
+				}
+				return err
+			}
+			return nil
+		}
+	}
+	return errors.New(fmt.Sprintf("Invalid MSTI %d or bridge %d supplied for setting MSTI Priority", mstid, bId))
+}
+
+func StpMstiVlansSet(bId int32, mstid uint16, vlans []uint16) error {
+	var b *Bridge
+	if StpFindBridgeByIfIndex(bId, &b) {
+		b.mstpMutex.Lock()
+		msti, ok := b.MstiMap[mstid]
+		b.mstpMutex.Unlock()
+		if ok {
+			key := StpMstiConfigKey{BrgIfIndex: bId, Mstid: mstid}
+			c := StpMstiConfigMap[key]
+			c.Vlans = vlans
+			err := StpMstiConfigParamCheck(&c)
+			if err == nil {
+				StpMstiConfigMap[key] = c
+				// hw stg must be re-created with the new vlan list
+				active := msti.StgId != -1
+				msti.Deactivate()
+				b.mstpMutex.Lock()
+				msti.Vlans = vlans
+				b.mstpMutex.Unlock()
+				if active {
+					msti.Activate()
+				}
+				b.MstConfigIdUpdate()
+				b.MstiReselect("CONFIG: MstiVlansSet")
+			}
+			return err
+		}
+	}
+	return errors.New(fmt.Sprintf("Invalid MSTI %d or bridge %d supplied for setting MSTI Vlans", mstid, bId))
+}
+
 func StpPortProtocolMigrationSet(pId int32, bId int32, protocolmigration bool) error {
 	var p *StpPort
 	if StpFindPortByIfIndex(pId, bId, &p) {
@@ -707,6 +1033,10 @@ func StpPortBpduGuardSet(pId int32, bId int32, bpduguard bool) error {
 					}
 					port.BpduGuard = bpduguard
 				}
+				// guard removed, bring the port back up
+				if !bpduguard && p.BpduGuardDetected() {
+					StpPortErrDisableRecover(p.IfIndex)
+				}
 			} else {
 				c.BpduGuard = prevval
 			}
@@ -749,3 +1079,107 @@ func StpPortBridgeAssuranceSet(pId int32, bId int32, bridgeassurance bool) error
 	}
 	return errors.New(fmt.Sprintf("Invalid port %d or bridge %d supplied for setting Bridge Assurance", pId, bId))
 }
+
+func StpPortBpduFilterSet(pId int32, bId int32, bpdufilter int32) error {
+	var p *StpPort
+	if StpFindPortByIfIndex(pId, bId, &p) {
+		if p.BpduFilter != BpduFilterMode(bpdufilter) {
+			c := StpPortConfigGet(pId)
+			prevval := c.BpduFilter
+			c.BpduFilter = bpdufilter
+			err := StpPortConfigParamCheck(c)
+			if err == nil {
+				// apply to all bridge ports
+				for _, port := range p.GetPortListToApplyConfigTo() {
+					StpMachineLogger("INFO", "CONFIG", port.IfIndex, port.BrgIfIndex, fmt.Sprintf("Setting Bpdu Filter %d", bpdufilter))
+					port.BpduFilter = BpduFilterMode(bpdufilter)
+				}
+			} else {
+				c.BpduFilter = prevval
+			}
+			return err
+		} else {
+			return nil
+		}
+	}
+	return errors.New(fmt.Sprintf("Invalid port %d or bridge %d supplied for setting Bpdu Filter", pId, bId))
+}
+
+func StpPortRootGuardSet(pId int32, bId int32, rootguard bool) error {
+	var p *StpPort
+	if StpFindPortByIfIndex(pId, bId, &p) {
+		if p.RootGuard != rootguard {
+			c := StpPortConfigGet(pId)
+			prevval := c.RootGuard
+			c.RootGuard = rootguard
+			err := StpPortConfigParamCheck(c)
+			if err == nil {
+				// apply to all bridge ports
+				for _, port := range p.GetPortListToApplyConfigTo() {
+					if rootguard {
+						StpMachineLogger("INFO", "CONFIG", port.IfIndex, port.BrgIfIndex, "Setting Root Guard")
+					} else {
+						StpMachineLogger("INFO", "CONFIG", port.IfIndex, port.BrgIfIndex, "Clearing Root Guard")
+					}
+					port.RootGuard = rootguard
+					port.RootGuardInconsistant = false
+					port.Selected = false
+					port.Reselect = true
+
+					port.b.PrsMachineFsm.PrsEvents <- MachineEvent{
+						e:   PrsEventReselect,
+						src: "CONFIG: PortRootGuardSet",
+					}
+				}
+			} else {
+				c.RootGuard = prevval
+			}
+			return err
+		} else {
+			return nil
+		}
+	}
+	return errors.New(fmt.Sprintf("Invalid port %d or bridge %d supplied for setting Root Guard", pId, bId))
+}
+
+func StpPortLoopGuardSet(pId int32, bId int32, loopguard bool) error {
+	var p *StpPort
+	if StpFindPortByIfIndex(pId, bId, &p) {
+		if p.LoopGuard != loopguard &&
+			!p.OperEdge {
+			c := StpPortConfigGet(pId)
+			prevval := c.LoopGuard
+			c.LoopGuard = loopguard
+			err := StpPortConfigParamCheck(c)
+			if err == nil {
+				// apply to all bridge ports
+				for _, port := range p.GetPortListToApplyConfigTo() {
+					if loopguard {
+						StpMachineLogger("INFO", "CONFIG", port.IfIndex, port.BrgIfIndex, "Setting Loop Guard")
+					} else {
+						StpMachineLogger("INFO", "CONFIG", port.IfIndex, port.BrgIfIndex, "Clearing Loop Guard")
+					}
+					port.LoopGuard = loopguard
+					if port.LoopGuardInconsistant {
+						// aged info was being held, let the port
+						// move on to designated
+						port.LoopGuardInconsistant = false
+						port.Selected = false
+						port.Reselect = true
+
+						port.b.PrsMachineFsm.PrsEvents <- MachineEvent{
+							e:   PrsEventReselect,
+							src: "CONFIG: PortLoopGuardSet",
+						}
+					}
+				}
+			} else {
+				c.LoopGuard = prevval
+			}
+			return err
+		} else {
+			return nil
+		}
+	}
+	return errors.New(fmt.Sprintf("Invalid port %d or bridge %d supplied for setting Loop Guard", pId, bId))
+}
@@ -45,6 +45,7 @@ const (
 	BPDURxTypeTopo
 	BPDURxTypeTopoAck
 	BPDURxTypePVST
+	BPDURxTypeMSTP
 )
 
 const (
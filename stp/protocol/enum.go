@@ -53,6 +53,8 @@ const (
 	PortRoleAlternatePort
 	PortRoleBackupPort
 	PortRoleDisabledPort
+	// 13.24.x MSTI boundary port whose CIST role is root
+	PortRoleMasterPort
 )
 
 type PointToPointMac int
@@ -62,3 +64,24 @@ const (
 	StpPointToPointForceFalse                 = 1
 	StpPointToPointAuto                       = 2
 )
+
+type FdbFlushPolicy int32
+
+const (
+	// 17.19.7 only the entries learned on the port are removed
+	StpFdbFlushPolicyPort FdbFlushPolicy = 0
+	// all entries of the spanning tree group are removed
+	StpFdbFlushPolicyStg = 1
+)
+
+type BpduFilterMode int32
+
+const (
+	// bpdus are sent and received as normal
+	StpBpduFilterDisabled BpduFilterMode = 0
+	// bpdus are not sent while the port is oper edge, a received bpdu
+	// removes the port from oper edge and bpdus will be sent again
+	StpBpduFilterGlobal = 1
+	// bpdus are never sent and received bpdus are dropped
+	StpBpduFilterInterface = 2
+)
@@ -0,0 +1,82 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// errdisable.go
+package stp
+
+import (
+	"fmt"
+	"l2/errdisable"
+	"sync"
+	"time"
+)
+
+// ports shut down by bpdu guard, recovery is driven by the stp clock.
+// The table is used by the PRX machines of all ports and the rpc handlers
+// so it is only created once
+var gStpErrDisable *errdisable.ErrDisable
+var gStpErrDisableOnce sync.Once
+
+// StpErrDisableGet returns the errdisable table of stpd
+func StpErrDisableGet() *errdisable.ErrDisable {
+	gStpErrDisableOnce.Do(func() {
+		gStpErrDisable = errdisable.New(StpClockGet(), stpErrDisableAction)
+		gStpErrDisable.RegisterCallback("stp", stpErrDisableLog)
+	})
+	return gStpErrDisable
+}
+
+func stpErrDisableAction(ifindex int32, cause errdisable.Cause, disable bool) error {
+	switch cause {
+	case errdisable.CauseBpduGuard:
+		return StpHwPluginGet().BPDUGuardDetected(ifindex, disable)
+	}
+	return nil
+}
+
+func stpErrDisableLog(evt errdisable.Event) {
+	StpLogger("INFO", fmt.Sprintf("Port %d %s cause %s count %d", evt.IfIndex, errdisable.EventStrMap[evt.Type], evt.Cause, evt.Count))
+}
+
+// BpduGuardErrDisable will shut the port down, the port is recovered
+// after BpduGuardInterval seconds or manually if the interval is zero
+func (p *StpPort) BpduGuardErrDisable() {
+	ed := StpErrDisableGet()
+	if !ed.IsDisabled(p.IfIndex, errdisable.CauseBpduGuard) {
+		StpMachineLogger("INFO", PrxmMachineModuleStr, p.IfIndex, p.BrgIfIndex, "BPDU Guard detected, errdisable port")
+		err := ed.Disable(p.IfIndex, errdisable.CauseBpduGuard, time.Duration(p.BpduGuardInterval)*time.Second)
+		if err != nil {
+			StpMachineLogger("ERROR", PrxmMachineModuleStr, p.IfIndex, p.BrgIfIndex, fmt.Sprintf("BPDU Guard errdisable failed %s", err))
+		}
+	}
+}
+
+// BpduGuardDetected port is currently errdisabled by bpdu guard
+func (p *StpPort) BpduGuardDetected() bool {
+	return StpErrDisableGet().IsDisabled(p.IfIndex, errdisable.CauseBpduGuard)
+}
+
+// StpPortErrDisableRecover will manually recover an errdisabled port
+func StpPortErrDisableRecover(pId int32) error {
+	return StpErrDisableGet().Recover(pId)
+}
@@ -0,0 +1,121 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// fdbflush.go
+package stp
+
+import (
+	"fmt"
+	"l2/clock"
+	"sync"
+	"time"
+)
+
+// fdbFlushState rate limits the fdb flushes of a port during a tc storm,
+// requests within the hold down are coalesced into a single flush once
+// the hold down expires
+type fdbFlushState struct {
+	mutex   sync.Mutex
+	last    time.Time
+	pending *clock.Timer
+	// statistics
+	flushes   uint64
+	coalesced uint64
+}
+
+// StpFdbFlushPolicyOperGet returns the policy a bridge runs, the port
+// policy falls back to the stg policy when the hw plugin can only flush
+// the whole stg
+func StpFdbFlushPolicyOperGet(policy FdbFlushPolicy) FdbFlushPolicy {
+	hw := StpHwPluginGet()
+	if policy == StpFdbFlushPolicyPort &&
+		!hw.FlushFdbPortSupported() {
+		StpLogger("INFO", fmt.Sprintf("%s hw plugin does not support a per port fdb flush, using the stg flush policy", hw.Name()))
+		return StpFdbFlushPolicyStg
+	}
+	return policy
+}
+
+// FdbFlushRequest 17.19.7 remove the fdb entries learned on this port
+func (p *StpPort) FdbFlushRequest() {
+	b := p.b
+	holddown := time.Duration(b.FdbFlushHoldDown) * time.Second
+
+	p.fdbFlush.mutex.Lock()
+	defer p.fdbFlush.mutex.Unlock()
+
+	if holddown == 0 {
+		p.fdbFlushNow()
+		return
+	}
+
+	now := StpClockGet().Now()
+	remaining := holddown - now.Sub(p.fdbFlush.last)
+	if p.fdbFlush.last.IsZero() || remaining <= 0 {
+		p.fdbFlushNow()
+	} else if p.fdbFlush.pending == nil {
+		StpMachineLogger("INFO", TcMachineModuleStr, p.IfIndex, p.BrgIfIndex, fmt.Sprintf("FDB Flush held down for %s", remaining))
+		p.fdbFlush.coalesced++
+		p.fdbFlush.pending = StpClockGet().AfterFunc(remaining, func() {
+			p.fdbFlush.mutex.Lock()
+			defer p.fdbFlush.mutex.Unlock()
+			p.fdbFlush.pending = nil
+			p.fdbFlushNow()
+		})
+	} else {
+		// flush already scheduled
+		p.fdbFlush.coalesced++
+	}
+}
+
+// fdbFlushNow must be called with the flush mutex held
+func (p *StpPort) fdbFlushNow() {
+	b := p.b
+	if b.FdbFlushPolicy == StpFdbFlushPolicyStg {
+		StpHwPluginGet().FlushFdb(b.StgId)
+	} else {
+		StpHwPluginGet().FlushFdbPort(b.StgId, p.IfIndex)
+	}
+	StpMachineLogger("INFO", TcMachineModuleStr, p.IfIndex, p.BrgIfIndex, "FDB Flush")
+	p.fdbFlush.last = StpClockGet().Now()
+	p.fdbFlush.flushes++
+}
+
+// FdbFlushStopPending will cancel a deferred flush, used when the port
+// is deleted
+func (p *StpPort) FdbFlushStopPending() {
+	p.fdbFlush.mutex.Lock()
+	if p.fdbFlush.pending != nil {
+		p.fdbFlush.pending.Stop()
+		p.fdbFlush.pending = nil
+	}
+	p.fdbFlush.mutex.Unlock()
+}
+
+// FdbFlushCounters returns the number of flushes done and the number of
+// requests which were coalesced by the hold down
+func (p *StpPort) FdbFlushCounters() (flushes uint64, coalesced uint64) {
+	p.fdbFlush.mutex.Lock()
+	defer p.fdbFlush.mutex.Unlock()
+	return p.fdbFlush.flushes, p.fdbFlush.coalesced
+}
@@ -0,0 +1,141 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// fdbflush_test.go
+package stp
+
+import (
+	"l2/clock"
+	"testing"
+	"time"
+)
+
+func TestFdbFlushHoldDown(t *testing.T) {
+	mock := NewMockHwPlugin()
+	prev := StpHwPluginGet()
+	StpHwPluginSet(mock)
+	defer StpHwPluginSet(prev)
+
+	clk := clock.NewManualClock(time.Unix(0, 0))
+	prevClk := StpClockGet()
+	StpClockSet(clk)
+	defer StpClockSet(prevClk)
+
+	b := &Bridge{}
+	b.StgId = 1
+	b.FdbFlushHoldDown = 5
+	p := &StpPort{IfIndex: 10, BrgIfIndex: 1, b: b}
+
+	// the deferred flush is done by the clock Advance which expires it
+	checkFlushes := func(expected int) {
+		if len(mock.Calls(MockHwOpFlushFdbPort)) != expected {
+			t.Error("ERROR Fdb flushes expected", expected, "got", len(mock.Calls(MockHwOpFlushFdbPort)))
+		}
+	}
+
+	// first request is flushed right away
+	p.FdbFlushRequest()
+	checkFlushes(1)
+
+	// requests within the hold down are coalesced into a single flush
+	clk.Advance(time.Second)
+	p.FdbFlushRequest()
+	p.FdbFlushRequest()
+	p.FdbFlushRequest()
+	if flushes, coalesced := p.FdbFlushCounters(); flushes != 1 || coalesced != 3 {
+		t.Error("ERROR Fdb flush requests not coalesced", flushes, coalesced)
+	}
+	if clk.Pending() != 1 {
+		t.Error("ERROR Expected a single deferred flush", clk.Pending())
+	}
+	clk.Advance(time.Second * 3)
+	checkFlushes(1)
+
+	// deferred flush is done once the hold down since the last flush expires
+	clk.Advance(time.Second)
+	checkFlushes(2)
+	if calls := mock.Calls(MockHwOpFlushFdbPort); calls[1].StgId != b.StgId ||
+		calls[1].IfIndex != p.IfIndex {
+		t.Error("ERROR Deferred fdb flush invalid", calls[1])
+	}
+	if flushes, _ := p.FdbFlushCounters(); flushes != 2 {
+		t.Error("ERROR Deferred fdb flush not counted", flushes)
+	}
+
+	// the deferred flush starts a new hold down, a pending flush is
+	// cancelled when the port is deleted
+	p.FdbFlushRequest()
+	p.FdbFlushStopPending()
+	clk.Advance(time.Second * 10)
+	checkFlushes(2)
+	if clk.Pending() != 0 {
+		t.Error("ERROR Deferred fdb flush not stopped", clk.Pending())
+	}
+
+	// outside the hold down the flush is done right away
+	p.FdbFlushRequest()
+	checkFlushes(3)
+
+	// no hold down
+	b.FdbFlushHoldDown = 0
+	p.FdbFlushRequest()
+	p.FdbFlushRequest()
+	checkFlushes(5)
+	if flushes, coalesced := p.FdbFlushCounters(); flushes != 5 || coalesced != 4 {
+		t.Error("ERROR Fdb flush counters invalid", flushes, coalesced)
+	}
+}
+
+func TestFdbFlushPolicyStgOnlyPlugin(t *testing.T) {
+	mock := NewMockHwPlugin()
+	prev := StpHwPluginGet()
+	StpHwPluginSet(mock)
+	defer StpHwPluginSet(prev)
+
+	if StpFdbFlushPolicyOperGet(StpFdbFlushPolicyPort) != StpFdbFlushPolicyPort {
+		t.Error("ERROR Expected port policy when the plugin supports a port flush")
+	}
+
+	// the port policy must not silently flush the whole stg
+	mock.NoFlushFdbPort = true
+	if StpFdbFlushPolicyOperGet(StpFdbFlushPolicyPort) != StpFdbFlushPolicyStg ||
+		StpFdbFlushPolicyOperGet(StpFdbFlushPolicyStg) != StpFdbFlushPolicyStg {
+		t.Error("ERROR Expected stg policy when the plugin only supports a stg flush")
+	}
+
+	b := &Bridge{}
+	b.StgId = 1
+	b.FdbFlushPolicy = StpFdbFlushPolicyOperGet(StpFdbFlushPolicyPort)
+	p := &StpPort{IfIndex: 10, BrgIfIndex: 1, b: b}
+	p.FdbFlushRequest()
+	if len(mock.Calls(MockHwOpFlushFdb)) != 1 ||
+		len(mock.Calls(MockHwOpFlushFdbPort)) != 0 {
+		t.Error("ERROR Expected stg flush", mock.Calls(MockHwOpFlushFdb), mock.Calls(MockHwOpFlushFdbPort))
+	}
+
+	asicd := &AsicdHwPlugin{}
+	if asicd.FlushFdbPortSupported() ||
+		asicd.FlushFdbPort(1, 10) != StpHwErrFlushFdbPortUnsupported {
+		t.Error("ERROR Expected asicd plugin to only support a stg flush")
+	}
+}
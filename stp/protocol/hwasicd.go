@@ -0,0 +1,66 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// hwasicd.go
+package stp
+
+// AsicdHwPlugin programs the hw through the asicd thrift client, calls
+// are a no-op when asicd is not connected
+type AsicdHwPlugin struct{}
+
+func (a *AsicdHwPlugin) Name() string {
+	return StpHwPluginAsicd
+}
+
+func (a *AsicdHwPlugin) GetPortLinkStatus(ifindex int32) bool {
+	return asicdGetPortLinkStatus(ifindex)
+}
+
+func (a *AsicdHwPlugin) CreateStg(vlanList []uint16) int32 {
+	return asicdCreateStgBridge(vlanList)
+}
+
+func (a *AsicdHwPlugin) DeleteStg(stgid int32, vlanList []uint16) error {
+	return asicdDeleteStgBridge(stgid, vlanList)
+}
+
+func (a *AsicdHwPlugin) SetStgPortState(stgid int32, ifindex int32, state int) error {
+	return asicdSetStgPortState(stgid, ifindex, state)
+}
+
+func (a *AsicdHwPlugin) FlushFdb(stgid int32) error {
+	return asicdFlushFdb(stgid)
+}
+
+// FlushFdbPort the asicd services only provide a flush of the whole stg
+func (a *AsicdHwPlugin) FlushFdbPort(stgid int32, ifindex int32) error {
+	return StpHwErrFlushFdbPortUnsupported
+}
+
+func (a *AsicdHwPlugin) FlushFdbPortSupported() bool {
+	return false
+}
+
+func (a *AsicdHwPlugin) BPDUGuardDetected(ifindex int32, enable bool) error {
+	return asicdBPDUGuardDetected(ifindex, enable)
+}
@@ -0,0 +1,321 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// hwlinux.go
+package stp
+
+import (
+	"asicd/pluginManager/pluginCommon"
+	"errors"
+	"fmt"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+	"io/ioutil"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+const LinuxBridgeHwPluginName = "linux"
+
+// linux bridge port states, include/uapi/linux/if_bridge.h
+const (
+	LinuxBrStateDisabled   uint8 = 0
+	LinuxBrStateListening  uint8 = 1
+	LinuxBrStateLearning   uint8 = 2
+	LinuxBrStateForwarding uint8 = 3
+	LinuxBrStateBlocking   uint8 = 4
+)
+
+var LinuxBrStateStrMap = map[uint8]string{
+	LinuxBrStateDisabled:   "disabled",
+	LinuxBrStateListening:  "listening",
+	LinuxBrStateLearning:   "learning",
+	LinuxBrStateForwarding: "forwarding",
+	LinuxBrStateBlocking:   "blocking",
+}
+
+// bridge stp_state as reported by sysfs, 2 means stp is run in user space
+const (
+	linuxBrNoStp     = 0
+	linuxBrKernelStp = 1
+	linuxBrUserStp   = 2
+)
+
+// LinuxBridgeHwPlugin programs a linux kernel bridge, port states are set
+// via netlink IFLA_BRPORT_STATE and fdb flushes via the iproute2 bridge
+// utility. The ports are expected to already be enslaved to the bridge.
+//
+// When the bridge is vlan aware (vlan_filtering) the vlans of a PVST/MSTI
+// stg are programmed per vlan on the bridge ports, otherwise the state
+// applies to the whole bridge port.
+type LinuxBridgeHwPlugin struct {
+	mutex      sync.Mutex
+	BridgeName string
+	VlanAware  bool
+	nextStgId  int32
+	stgs       map[int32][]uint16
+}
+
+func NewLinuxBridgeHwPlugin(bridgeName string) *LinuxBridgeHwPlugin {
+	return &LinuxBridgeHwPlugin{
+		BridgeName: bridgeName,
+		nextStgId:  1,
+		stgs:       make(map[int32][]uint16),
+	}
+}
+
+func (l *LinuxBridgeHwPlugin) Name() string {
+	return LinuxBridgeHwPluginName
+}
+
+func linuxBridgeSysfsPath(bridgeName string, attr string) string {
+	return fmt.Sprintf("/sys/class/net/%s/bridge/%s", bridgeName, attr)
+}
+
+func linuxBridgeSysfsRead(bridgeName string, attr string) (int, error) {
+	data, err := ioutil.ReadFile(linuxBridgeSysfsPath(bridgeName, attr))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+// Init takes over stp from the kernel and learns the bridge ports and mac,
+// this replaces the port discovery done via asicd
+func (l *LinuxBridgeHwPlugin) Init() error {
+	br, err := netlink.LinkByName(l.BridgeName)
+	if err != nil {
+		return errors.New(fmt.Sprintf("LinuxBridge: unable to find bridge %s: %s", l.BridgeName, err))
+	}
+
+	// asking for stp will run /sbin/bridge-stp, if it accepts the kernel
+	// leaves stp to user space otherwise the kernel stp is started which
+	// does not allow the port states to be set
+	err = ioutil.WriteFile(linuxBridgeSysfsPath(l.BridgeName, "stp_state"), []byte("1"), 0644)
+	if err != nil {
+		return errors.New(fmt.Sprintf("LinuxBridge: unable to enable stp on bridge %s: %s", l.BridgeName, err))
+	}
+	if mode, _ := linuxBridgeSysfsRead(l.BridgeName, "stp_state"); mode != linuxBrUserStp {
+		ioutil.WriteFile(linuxBridgeSysfsPath(l.BridgeName, "stp_state"), []byte("0"), 0644)
+		return errors.New(fmt.Sprintf("LinuxBridge: kernel stp active on bridge %s, /sbin/bridge-stp must return 0 for %s", l.BridgeName, l.BridgeName))
+	}
+
+	if vlanFiltering, err := linuxBridgeSysfsRead(l.BridgeName, "vlan_filtering"); err == nil {
+		l.VlanAware = vlanFiltering == 1
+	}
+
+	mac := br.Attrs().HardwareAddr
+	if len(mac) == 6 {
+		StpBridgeMac = [6]uint8{mac[0], mac[1], mac[2], mac[3], mac[4], mac[5]}
+	}
+
+	links, err := netlink.LinkList()
+	if err != nil {
+		return err
+	}
+	for _, link := range links {
+		attrs := link.Attrs()
+		if attrs.MasterIndex == br.Attrs().Index {
+			StpPortConfigMapSet(int32(attrs.Index), attrs.Name, attrs.HardwareAddr, 0)
+			StpLogger("INIT", fmt.Sprintf("LinuxBridge %s: Found Port IfIndex %d Name %s\n", l.BridgeName, attrs.Index, attrs.Name))
+		}
+	}
+	StpLogger("INFO", fmt.Sprintf("LinuxBridge %s: user space stp enabled vlan aware %t", l.BridgeName, l.VlanAware))
+	return nil
+}
+
+func linuxPortNameGet(ifindex int32) (string, error) {
+	pc, ok := PortConfigMap[ifindex]
+	if !ok || pc.Name == "" {
+		return "", errors.New(fmt.Sprintf("Unknown port %d", ifindex))
+	}
+	return pc.Name, nil
+}
+
+func linuxPortLinkGet(ifindex int32) (netlink.Link, error) {
+	name, err := linuxPortNameGet(ifindex)
+	if err != nil {
+		return nil, err
+	}
+	return netlink.LinkByName(name)
+}
+
+func linuxStpPortStateToBrState(state int) uint8 {
+	switch state {
+	case pluginCommon.STP_PORT_STATE_FORWARDING:
+		return LinuxBrStateForwarding
+	case pluginCommon.STP_PORT_STATE_LEARNING:
+		return LinuxBrStateLearning
+	}
+	return LinuxBrStateBlocking
+}
+
+// stgVlans returns the vlans of the stg which need per vlan programming,
+// empty when the state/flush applies to the whole port
+func (l *LinuxBridgeHwPlugin) stgVlans(stgid int32) []uint16 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	vlans := make([]uint16, 0)
+	if !l.VlanAware {
+		return vlans
+	}
+	for _, v := range l.stgs[stgid] {
+		if v != 0 &&
+			v != DEFAULT_STP_BRIDGE_VLAN {
+			vlans = append(vlans, v)
+		}
+	}
+	return vlans
+}
+
+func (l *LinuxBridgeHwPlugin) GetPortLinkStatus(ifindex int32) bool {
+	link, err := linuxPortLinkGet(ifindex)
+	if err != nil {
+		StpLogger("INFO", fmt.Sprintf("LinuxBridge: could not get status for port %d: %s", ifindex, err))
+		return true
+	}
+	return link.Attrs().Flags&net.FlagUp == net.FlagUp
+}
+
+// CreateStg the kernel bridge has a single stp instance so the stg id is
+// only tracked locally
+func (l *LinuxBridgeHwPlugin) CreateStg(vlanList []uint16) int32 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	stgid := l.nextStgId
+	l.nextStgId++
+	l.stgs[stgid] = append([]uint16(nil), vlanList...)
+	StpLogger("INFO", fmt.Sprintf("LinuxBridge %s: Created Stg Group %d with vlans %#v", l.BridgeName, stgid, vlanList))
+	return stgid
+}
+
+func (l *LinuxBridgeHwPlugin) DeleteStg(stgid int32, vlanList []uint16) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if _, ok := l.stgs[stgid]; !ok {
+		return errors.New(fmt.Sprintf("LinuxBridge %s: Unknown Stg Group %d", l.BridgeName, stgid))
+	}
+	delete(l.stgs, stgid)
+	return nil
+}
+
+func (l *LinuxBridgeHwPlugin) SetStgPortState(stgid int32, ifindex int32, state int) error {
+	brstate := linuxStpPortStateToBrState(state)
+	vlans := l.stgVlans(stgid)
+	if len(vlans) == 0 {
+		link, err := linuxPortLinkGet(ifindex)
+		if err != nil {
+			return err
+		}
+		return linuxBrPortStateSet(link, brstate)
+	}
+
+	// PVST/MSTI, the port state is kept per vlan
+	name, err := linuxPortNameGet(ifindex)
+	if err != nil {
+		return err
+	}
+	for _, v := range vlans {
+		err = linuxBridgeCmd("vlan", "set", "dev", name, "vid", strconv.Itoa(int(v)), "state", LinuxBrStateStrMap[brstate])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// linuxBrPortStateSet sets IFLA_BRPORT_STATE within the IFLA_PROTINFO of
+// the bridge port
+func linuxBrPortStateSet(link netlink.Link, state uint8) error {
+	req := nl.NewNetlinkRequest(syscall.RTM_SETLINK, syscall.NLM_F_ACK)
+	msg := nl.NewIfInfomsg(syscall.AF_BRIDGE)
+	msg.Index = int32(link.Attrs().Index)
+	req.AddData(msg)
+
+	protinfo := nl.NewRtAttr(syscall.IFLA_PROTINFO|syscall.NLA_F_NESTED, nil)
+	nl.NewRtAttrChild(protinfo, nl.IFLA_BRPORT_STATE, []byte{state})
+	req.AddData(protinfo)
+
+	_, err := req.Execute(syscall.NETLINK_ROUTE, 0)
+	return err
+}
+
+// linuxBridgeCmd runs the iproute2 bridge utility
+var linuxBridgeCmd = func(args ...string) error {
+	out, err := exec.Command("bridge", args...).CombinedOutput()
+	if err != nil {
+		return errors.New(fmt.Sprintf("bridge %v failed: %s %s", args, err, out))
+	}
+	return nil
+}
+
+// linuxBridgeFdbFlush only dynamically learned entries are removed, the
+// flush is done per vlan of the stg when the bridge is vlan aware
+func (l *LinuxBridgeHwPlugin) linuxBridgeFdbFlush(stgid int32, brport string) error {
+	args := []string{"fdb", "flush", "dev", l.BridgeName}
+	if brport != "" {
+		args = append(args, "brport", brport)
+	}
+	vlans := l.stgVlans(stgid)
+	if len(vlans) == 0 {
+		return linuxBridgeCmd(append(args, "dynamic")...)
+	}
+	for _, v := range vlans {
+		vargs := append(append([]string(nil), args...), "vlan", strconv.Itoa(int(v)), "dynamic")
+		if err := linuxBridgeCmd(vargs...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *LinuxBridgeHwPlugin) FlushFdb(stgid int32) error {
+	return l.linuxBridgeFdbFlush(stgid, "")
+}
+
+func (l *LinuxBridgeHwPlugin) FlushFdbPort(stgid int32, ifindex int32) error {
+	name, err := linuxPortNameGet(ifindex)
+	if err != nil {
+		return err
+	}
+	return l.linuxBridgeFdbFlush(stgid, name)
+}
+
+func (l *LinuxBridgeHwPlugin) FlushFdbPortSupported() bool {
+	return true
+}
+
+func (l *LinuxBridgeHwPlugin) BPDUGuardDetected(ifindex int32, enable bool) error {
+	link, err := linuxPortLinkGet(ifindex)
+	if err != nil {
+		return err
+	}
+	if enable {
+		return netlink.LinkSetDown(link)
+	}
+	return netlink.LinkSetUp(link)
+}
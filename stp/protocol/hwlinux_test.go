@@ -0,0 +1,89 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// hwlinux_test.go
+package stp
+
+import (
+	"asicd/pluginManager/pluginCommon"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLinuxBridgePvstVlanAware(t *testing.T) {
+	cmds := make([]string, 0)
+	prevCmd := linuxBridgeCmd
+	linuxBridgeCmd = func(args ...string) error {
+		cmds = append(cmds, strings.Join(args, " "))
+		return nil
+	}
+	defer func() { linuxBridgeCmd = prevCmd }()
+
+	ifindex := int32(0x0ADDBEE0)
+	StpPortConfigMapSet(ifindex, "swp1", nil, 0)
+	defer StpPortConfigMapDelete(ifindex)
+
+	l := NewLinuxBridgeHwPlugin("br0")
+	l.VlanAware = true
+	rstpStg := l.CreateStg([]uint16{DEFAULT_STP_BRIDGE_VLAN})
+	pvstStg := l.CreateStg([]uint16{100, 200})
+	if rstpStg == pvstStg {
+		t.Error("ERROR Stg ids not unique", rstpStg, pvstStg)
+	}
+
+	// pvst state is programmed per vlan
+	l.SetStgPortState(pvstStg, ifindex, pluginCommon.STP_PORT_STATE_FORWARDING)
+	l.FlushFdbPort(pvstStg, ifindex)
+	expected := []string{
+		"vlan set dev swp1 vid 100 state forwarding",
+		"vlan set dev swp1 vid 200 state forwarding",
+		"fdb flush dev br0 brport swp1 vlan 100 dynamic",
+		"fdb flush dev br0 brport swp1 vlan 200 dynamic",
+	}
+	if !reflect.DeepEqual(cmds, expected) {
+		t.Error("ERROR Invalid pvst bridge commands", cmds)
+	}
+
+	// the default vlan applies to the whole bridge
+	cmds = cmds[:0]
+	l.FlushFdb(rstpStg)
+	if !reflect.DeepEqual(cmds, []string{"fdb flush dev br0 dynamic"}) {
+		t.Error("ERROR Invalid rstp bridge commands", cmds)
+	}
+
+	// without vlan filtering the vlans of the stg are ignored
+	cmds = cmds[:0]
+	l.VlanAware = false
+	l.FlushFdb(pvstStg)
+	if !reflect.DeepEqual(cmds, []string{"fdb flush dev br0 dynamic"}) {
+		t.Error("ERROR Invalid non vlan aware bridge commands", cmds)
+	}
+
+	if err := l.DeleteStg(pvstStg, []uint16{100, 200}); err != nil {
+		t.Error("ERROR Unable to delete stg", err)
+	}
+	if err := l.DeleteStg(pvstStg, []uint16{100, 200}); err == nil {
+		t.Error("ERROR Stg deleted twice")
+	}
+}
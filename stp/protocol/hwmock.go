@@ -0,0 +1,144 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// hwmock.go
+package stp
+
+import (
+	"sync"
+)
+
+// MockHwPlugin records every call made to the hw so that tests can assert
+// against it
+type MockHwPlugin struct {
+	mutex     sync.Mutex
+	nextStgId int32
+	calls     []MockHwCall
+	// LinkStatus link state returned per ifindex, defaults to up
+	LinkStatus map[int32]bool
+	// Err is returned by all calls which return an error
+	Err error
+	// NoFlushFdbPort makes the plugin only support the stg fdb flush
+	NoFlushFdbPort bool
+}
+
+type MockHwCall struct {
+	Op      string
+	StgId   int32
+	IfIndex int32
+	State   int
+	Vlans   []uint16
+	Enable  bool
+}
+
+const (
+	MockHwOpCreateStg         = "CreateStg"
+	MockHwOpDeleteStg         = "DeleteStg"
+	MockHwOpSetStgPortState   = "SetStgPortState"
+	MockHwOpFlushFdb          = "FlushFdb"
+	MockHwOpFlushFdbPort      = "FlushFdbPort"
+	MockHwOpBPDUGuardDetected = "BPDUGuardDetected"
+)
+
+func NewMockHwPlugin() *MockHwPlugin {
+	return &MockHwPlugin{
+		nextStgId:  1,
+		LinkStatus: make(map[int32]bool),
+	}
+}
+
+func (m *MockHwPlugin) record(c MockHwCall) {
+	m.mutex.Lock()
+	m.calls = append(m.calls, c)
+	m.mutex.Unlock()
+}
+
+// Calls returns a copy of the calls recorded, optionally filtered by op
+func (m *MockHwPlugin) Calls(op string) []MockHwCall {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	calls := make([]MockHwCall, 0)
+	for _, c := range m.calls {
+		if op == "" || c.Op == op {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+func (m *MockHwPlugin) Reset() {
+	m.mutex.Lock()
+	m.calls = nil
+	m.mutex.Unlock()
+}
+
+func (m *MockHwPlugin) Name() string {
+	return "mock"
+}
+
+func (m *MockHwPlugin) GetPortLinkStatus(ifindex int32) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if up, ok := m.LinkStatus[ifindex]; ok {
+		return up
+	}
+	return true
+}
+
+func (m *MockHwPlugin) CreateStg(vlanList []uint16) int32 {
+	m.mutex.Lock()
+	stgid := m.nextStgId
+	m.nextStgId++
+	m.mutex.Unlock()
+	m.record(MockHwCall{Op: MockHwOpCreateStg, StgId: stgid, Vlans: append([]uint16(nil), vlanList...)})
+	return stgid
+}
+
+func (m *MockHwPlugin) DeleteStg(stgid int32, vlanList []uint16) error {
+	m.record(MockHwCall{Op: MockHwOpDeleteStg, StgId: stgid, Vlans: append([]uint16(nil), vlanList...)})
+	return m.Err
+}
+
+func (m *MockHwPlugin) SetStgPortState(stgid int32, ifindex int32, state int) error {
+	m.record(MockHwCall{Op: MockHwOpSetStgPortState, StgId: stgid, IfIndex: ifindex, State: state})
+	return m.Err
+}
+
+func (m *MockHwPlugin) FlushFdb(stgid int32) error {
+	m.record(MockHwCall{Op: MockHwOpFlushFdb, StgId: stgid})
+	return m.Err
+}
+
+func (m *MockHwPlugin) FlushFdbPort(stgid int32, ifindex int32) error {
+	m.record(MockHwCall{Op: MockHwOpFlushFdbPort, StgId: stgid, IfIndex: ifindex})
+	return m.Err
+}
+
+func (m *MockHwPlugin) FlushFdbPortSupported() bool {
+	return !m.NoFlushFdbPort
+}
+
+func (m *MockHwPlugin) BPDUGuardDetected(ifindex int32, enable bool) error {
+	m.record(MockHwCall{Op: MockHwOpBPDUGuardDetected, IfIndex: ifindex, Enable: enable})
+	return m.Err
+}
@@ -0,0 +1,115 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// hwplugin.go
+package stp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sync"
+)
+
+const (
+	StpHwPluginAsicd = "asicd"
+	StpHwPluginLinux = LinuxBridgeHwPluginName
+)
+
+// HwPlugin abstracts the hw programming needed by the protocol so that
+// stpd is not tied to asicd, the protocol should only call the hw through
+// the plugin returned by StpHwPluginGet
+type HwPlugin interface {
+	// Name of the plugin used for logging
+	Name() string
+	// GetPortLinkStatus returns true when the link is up
+	GetPortLinkStatus(ifindex int32) bool
+	// CreateStg creates a spanning tree group for the given vlans, returns
+	// the stg id or -1 on failure
+	CreateStg(vlanList []uint16) int32
+	DeleteStg(stgid int32, vlanList []uint16) error
+	// SetStgPortState state is one of pluginCommon.STP_PORT_STATE_XXX
+	SetStgPortState(stgid int32, ifindex int32, state int) error
+	// FlushFdb removes all the fdb entries learned within the stg
+	FlushFdb(stgid int32) error
+	// FlushFdbPort removes the fdb entries learned on the port within the stg
+	FlushFdbPort(stgid int32, ifindex int32) error
+	// FlushFdbPortSupported returns false when the hw can only flush the
+	// whole stg, bridges then run the stg flush policy
+	FlushFdbPortSupported() bool
+	// BPDUGuardDetected will error disable the port when enable is true
+	// and clear the error disable when enable is false
+	BPDUGuardDetected(ifindex int32, enable bool) error
+}
+
+var StpHwErrFlushFdbPortUnsupported = errors.New("stp: hw plugin does not support a per port fdb flush")
+
+var gHwPluginMutex sync.RWMutex
+var gHwPlugin HwPlugin = &AsicdHwPlugin{}
+
+// StpHwPluginSet should be called before any bridge/port is created
+func StpHwPluginSet(p HwPlugin) {
+	gHwPluginMutex.Lock()
+	gHwPlugin = p
+	gHwPluginMutex.Unlock()
+}
+
+func StpHwPluginGet() HwPlugin {
+	gHwPluginMutex.RLock()
+	defer gHwPluginMutex.RUnlock()
+	return gHwPlugin
+}
+
+type StpHwConfigJson struct {
+	HwPlugin string `json:"HwPlugin"`
+	// Bridge is the linux bridge controlled by the linux plugin
+	Bridge string `json:"Bridge"`
+}
+
+// StpHwPluginFromParams will create the plugin configured in stpd.conf of
+// the params directory, asicd is used when the file does not exist or does
+// not specify a plugin
+func StpHwPluginFromParams(paramsDir string) (HwPlugin, error) {
+	var cfg StpHwConfigJson
+
+	fileName := paramsDir + "stpd.conf"
+	bytes, err := ioutil.ReadFile(fileName)
+	if err == nil {
+		err = json.Unmarshal(bytes, &cfg)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Error in Unmarshalling %s: %s", fileName, err))
+		}
+	}
+
+	switch cfg.HwPlugin {
+	case "", StpHwPluginAsicd:
+		return &AsicdHwPlugin{}, nil
+	case StpHwPluginLinux:
+		if cfg.Bridge == "" {
+			cfg.Bridge = "br0"
+		}
+		return NewLinuxBridgeHwPlugin(cfg.Bridge), nil
+	}
+	return nil, errors.New(fmt.Sprintf("Unsupported hw plugin %s in %s", cfg.HwPlugin, fileName))
+}
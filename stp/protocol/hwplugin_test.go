@@ -0,0 +1,125 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// hwplugin_test.go
+package stp
+
+import (
+	"asicd/pluginManager/pluginCommon"
+	"io/ioutil"
+	"l2/errdisable"
+	"os"
+	"testing"
+)
+
+func TestHwPluginMockRecordsCalls(t *testing.T) {
+	mock := NewMockHwPlugin()
+	prev := StpHwPluginGet()
+	StpHwPluginSet(mock)
+	defer StpHwPluginSet(prev)
+
+	b := &Bridge{}
+	b.StgId = StpHwPluginGet().CreateStg([]uint16{DEFAULT_STP_BRIDGE_VLAN})
+	p := &StpPort{IfIndex: 10, BrgIfIndex: 1, b: b}
+	pstm := &PstMachine{p: p}
+
+	pstm.enableLearning()
+	pstm.enableForwarding()
+	pstm.disableForwarding()
+
+	expected := []int{
+		pluginCommon.STP_PORT_STATE_LEARNING,
+		pluginCommon.STP_PORT_STATE_FORWARDING,
+		pluginCommon.STP_PORT_STATE_BLOCKING,
+	}
+	calls := mock.Calls(MockHwOpSetStgPortState)
+	if len(calls) != len(expected) {
+		t.Fatal("ERROR Port state calls not recorded", calls)
+	}
+	for i, c := range calls {
+		if c.StgId != b.StgId ||
+			c.IfIndex != p.IfIndex ||
+			c.State != expected[i] {
+			t.Error("ERROR Port state call invalid", i, c)
+		}
+	}
+
+	// per port flush is the default policy
+	p.FdbFlushRequest()
+	if calls := mock.Calls(MockHwOpFlushFdbPort); len(calls) != 1 ||
+		calls[0].StgId != b.StgId ||
+		calls[0].IfIndex != p.IfIndex {
+		t.Error("ERROR Fdb port flush not recorded", calls)
+	}
+	b.FdbFlushPolicy = StpFdbFlushPolicyStg
+	p.FdbFlushRequest()
+	if calls := mock.Calls(MockHwOpFlushFdb); len(calls) != 1 ||
+		calls[0].StgId != b.StgId {
+		t.Error("ERROR Fdb stg flush not recorded", calls)
+	}
+
+	// bpdu guard shutdown is done through the plugin
+	stpErrDisableAction(p.IfIndex, errdisable.CauseBpduGuard, true)
+	if calls := mock.Calls(MockHwOpBPDUGuardDetected); len(calls) != 1 ||
+		calls[0].IfIndex != p.IfIndex ||
+		!calls[0].Enable {
+		t.Error("ERROR BPDU guard shutdown not recorded", calls)
+	}
+
+	mock.LinkStatus[p.IfIndex] = false
+	if StpHwPluginGet().GetPortLinkStatus(p.IfIndex) {
+		t.Error("ERROR Link status not taken from plugin")
+	}
+
+	mock.Reset()
+	if len(mock.Calls("")) != 0 {
+		t.Error("ERROR Mock calls not reset")
+	}
+}
+
+func TestHwPluginFromParams(t *testing.T) {
+	if p, err := StpHwPluginFromParams("/nonexistent/"); err != nil || p.Name() != StpHwPluginAsicd {
+		t.Error("ERROR Expected asicd plugin when stpd.conf does not exist", err)
+	}
+
+	dir, err := ioutil.TempDir("", "stpd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := dir + "/"
+
+	ioutil.WriteFile(path+"stpd.conf", []byte(`{"HwPlugin": "linux", "Bridge": "br1"}`), 0644)
+	p, err := StpHwPluginFromParams(path)
+	if err != nil || p.Name() != StpHwPluginLinux {
+		t.Fatal("ERROR Expected linux plugin", err)
+	}
+	if lp := p.(*LinuxBridgeHwPlugin); lp.BridgeName != "br1" {
+		t.Error("ERROR Expected bridge from stpd.conf", lp.BridgeName)
+	}
+
+	ioutil.WriteFile(path+"stpd.conf", []byte(`{"HwPlugin": "unknown"}`), 0644)
+	if _, err = StpHwPluginFromParams(path); err == nil {
+		t.Error("ERROR Expected unsupported plugin to fail")
+	}
+}
@@ -37,6 +37,7 @@ func init() {
 	BridgeMapTable = make(map[BridgeKey]*Bridge, 0)
 	StpPortConfigMap = make(map[int32]StpPortConfig, 0)
 	StpBridgeConfigMap = make(map[int32]StpBridgeConfig, 0)
+	StpMstiConfigMap = make(map[StpMstiConfigKey]StpMstiConfig, 0)
 
 	asicdmutex = &sync.Mutex{}
 
@@ -0,0 +1,290 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// mstiprt.go
+// 802.1Q-2014 13.37 Port Role Transitions and 13.38 Port State Transitions
+// for each MSTI port.  Ports internal to the region run the proposal/agreement
+// handshake per MSTI using the flags of the MSTI Configuration Messages.
+// Ports at the region boundary, or which have not heard from a bridge in the
+// region, follow the CIST port state.  All functions must be called with the
+// bridge mstpMutex held.
+package stp
+
+import (
+	"asicd/pluginManager/pluginCommon"
+	"fmt"
+)
+
+// prtRoleChanged clears the handshake with the neighbor when the msti
+// port takes on a new role
+func (msti *MstInstance) prtRoleChanged(mp *MstiPort) {
+	mp.Proposing = false
+	mp.Proposed = false
+	mp.Agree = false
+	mp.Agreed = false
+	mp.Synced = !mp.Learn && !mp.Forward
+}
+
+// allSynced 13.27.x all ports other than the root port are synced
+func (msti *MstInstance) allSynced() bool {
+	for _, mp := range msti.Ports {
+		if mp.Role != PortRoleRootPort &&
+			mp.Role != PortRoleDisabledPort &&
+			!mp.Synced {
+			return false
+		}
+	}
+	return true
+}
+
+// reRooted 13.27.x no other port is a recent root port
+func (msti *MstInstance) reRooted(ifindex int32) bool {
+	for _, mp := range msti.Ports {
+		if mp.IfIndex != ifindex && mp.RrWhile != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (msti *MstInstance) setSyncTree() {
+	for _, mp := range msti.Ports {
+		mp.Sync = true
+	}
+}
+
+func (msti *MstInstance) setReRootTree() {
+	for _, mp := range msti.Ports {
+		mp.ReRoot = true
+	}
+}
+
+// prtTreeRun will run the port role transitions of every port of the msti
+// until no further transition occurs, as a transition on one port such as
+// setSyncTree may enable a transition on another
+func (msti *MstInstance) prtTreeRun() {
+	var p *StpPort
+	b := msti.b
+
+	ports := make([]*StpPort, 0)
+	for _, pId := range b.StpPorts {
+		if StpFindPortByIfIndex(pId, b.BrgIfIndex, &p) {
+			if _, ok := msti.Ports[p.IfIndex]; ok {
+				ports = append(ports, p)
+			}
+		}
+	}
+
+	for i := 0; i <= len(ports)+1; i++ {
+		changed := false
+		for _, p := range ports {
+			mp := msti.Ports[p.IfIndex]
+			prev := *mp
+			msti.prtRun(p, mp)
+			changed = changed || prev != *mp
+		}
+		if !changed {
+			break
+		}
+	}
+	for _, p := range ports {
+		msti.pstRun(p, msti.Ports[p.IfIndex])
+	}
+}
+
+// prtRun 13.37 port role transitions of a single msti port
+func (msti *MstInstance) prtRun(p *StpPort, mp *MstiPort) {
+	b := msti.b
+
+	if !p.PortEnabled || mp.Role == PortRoleDisabledPort {
+		// DISABLE_PORT / DISABLED_PORT
+		mp.Learn = false
+		mp.Forward = false
+		mp.Proposing = false
+		mp.Proposed = false
+		mp.Agree = false
+		mp.Agreed = false
+		mp.Synced = true
+		mp.Sync = false
+		mp.ReRoot = false
+		mp.RrWhile = 0
+		mp.FdWhile = int32(b.RootTimes.MaxAge)
+		return
+	}
+
+	if !p.RcvdInternal {
+		// boundary port, the CIST is responsible for the
+		// proposal/agreement handshake with the neighbor
+		mp.Learn = p.Learning
+		mp.Forward = p.Forwarding
+		mp.Agree = p.Agree
+		mp.Proposing = false
+		mp.Proposed = false
+		mp.Synced = true
+		mp.Sync = false
+		mp.ReRoot = false
+		mp.RrWhile = 0
+		return
+	}
+
+	switch mp.Role {
+	case PortRoleRootPort:
+		msti.prtRootPort(p, mp)
+	case PortRoleDesignatedPort, PortRoleMasterPort:
+		msti.prtDesignatedPort(p, mp)
+	case PortRoleAlternatePort, PortRoleBackupPort:
+		msti.prtAlternatePort(p, mp)
+	}
+}
+
+func (msti *MstInstance) prtRootPort(p *StpPort, mp *MstiPort) {
+	fwdDelay := int32(msti.b.RootTimes.ForwardingDelay)
+
+	// ROOT_PROPOSED
+	if mp.Proposed && !mp.Agree {
+		msti.setSyncTree()
+		mp.Proposed = false
+	}
+	// ROOT_AGREED
+	if (msti.allSynced() && !mp.Agree) ||
+		(mp.Proposed && mp.Agree) {
+		mp.Proposed = false
+		mp.Agree = true
+	}
+	// the root port is always in sync with the root
+	mp.Synced = true
+	mp.Sync = false
+	// REROOT
+	if !mp.Forward && !mp.ReRoot {
+		msti.setReRootTree()
+	}
+	// ROOT_PORT
+	mp.RrWhile = fwdDelay
+	// ROOT_LEARN / ROOT_FORWARD
+	reRooted := msti.reRooted(mp.IfIndex)
+	if (mp.FdWhile == 0 || (reRooted && mp.RbWhile == 0)) && !mp.Learn {
+		mp.Learn = true
+		mp.FdWhile = fwdDelay
+	}
+	if (mp.FdWhile == 0 || (reRooted && mp.RbWhile == 0)) && mp.Learn && !mp.Forward {
+		mp.Forward = true
+		mp.FdWhile = 0
+	}
+	// REROOTED
+	if mp.ReRoot && mp.Forward {
+		mp.ReRoot = false
+	}
+}
+
+func (msti *MstInstance) prtDesignatedPort(p *StpPort, mp *MstiPort) {
+	fwdDelay := int32(msti.b.RootTimes.ForwardingDelay)
+
+	// a port which is learning or forwarding without an agreement
+	// from the neighbor is not in sync
+	if (mp.Learn || mp.Forward) && !mp.Agreed && !p.OperEdge {
+		mp.Synced = false
+	}
+	// DESIGNATED_PROPOSE
+	if !mp.Forward && !mp.Agreed && !mp.Proposing && !p.OperEdge {
+		mp.Proposing = true
+	}
+	// DESIGNATED_SYNCED
+	if (!mp.Learn && !mp.Forward && !mp.Synced) ||
+		(mp.Agreed && !mp.Synced) ||
+		(p.OperEdge && !mp.Synced) ||
+		(mp.Sync && mp.Synced) {
+		mp.RrWhile = 0
+		mp.Synced = true
+		mp.Sync = false
+	}
+	// DESIGNATED_RETIRED
+	if mp.RrWhile == 0 && mp.ReRoot {
+		mp.ReRoot = false
+	}
+	// DESIGNATED_DISCARD
+	if ((mp.Sync && !mp.Synced) || (mp.ReRoot && mp.RrWhile != 0)) &&
+		!p.OperEdge &&
+		(mp.Learn || mp.Forward) {
+		mp.Learn = false
+		mp.Forward = false
+		mp.FdWhile = fwdDelay
+	}
+	// DESIGNATED_LEARN / DESIGNATED_FORWARD
+	canForward := (mp.RrWhile == 0 || !mp.ReRoot) && !mp.Sync
+	if canForward && (mp.FdWhile == 0 || mp.Agreed || p.OperEdge) && !mp.Learn {
+		mp.Learn = true
+		mp.FdWhile = fwdDelay
+	}
+	if canForward && (mp.FdWhile == 0 || mp.Agreed || p.OperEdge) && mp.Learn && !mp.Forward {
+		mp.Forward = true
+		mp.FdWhile = 0
+		mp.Proposing = false
+	}
+}
+
+func (msti *MstInstance) prtAlternatePort(p *StpPort, mp *MstiPort) {
+	b := msti.b
+
+	// ALTERNATE_PROPOSED
+	if mp.Proposed && !mp.Agree {
+		msti.setSyncTree()
+		mp.Proposed = false
+	}
+	// ALTERNATE_AGREED
+	if (msti.allSynced() && !mp.Agree) ||
+		(mp.Proposed && mp.Agree) {
+		mp.Proposed = false
+		mp.Agree = true
+	}
+	// BLOCK_PORT / ALTERNATE_PORT
+	mp.Learn = false
+	mp.Forward = false
+	mp.FdWhile = int32(b.RootTimes.ForwardingDelay)
+	mp.Synced = true
+	mp.RrWhile = 0
+	mp.Sync = false
+	mp.ReRoot = false
+	if mp.Role == PortRoleBackupPort {
+		mp.RbWhile = int32(2 * b.RootTimes.HelloTime)
+	}
+}
+
+// pstRun 13.38 port state transitions, program the msti port state in hw
+func (msti *MstInstance) pstRun(p *StpPort, mp *MstiPort) {
+	if mp.Learn == mp.Learning &&
+		mp.Forward == mp.Forwarding {
+		return
+	}
+	state := pluginCommon.STP_PORT_STATE_BLOCKING
+	if mp.Forward {
+		state = pluginCommon.STP_PORT_STATE_FORWARDING
+	} else if mp.Learn {
+		state = pluginCommon.STP_PORT_STATE_LEARNING
+	}
+	StpMachineLogger("INFO", MstpModuleStr, p.IfIndex, p.BrgIfIndex, fmt.Sprintf("MSTI %d stg %d port state learning[%t] forwarding[%t]", msti.Mstid, msti.StgId, mp.Learn, mp.Forward))
+	if msti.StgId != -1 {
+		StpHwPluginGet().SetStgPortState(msti.StgId, p.IfIndex, state)
+	}
+	mp.Learning = mp.Learn
+	mp.Forwarding = mp.Forward
+}
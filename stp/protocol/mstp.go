@@ -0,0 +1,884 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// 802.1Q-2014 Multiple Spanning Tree Protocol
+// The CIST is run by the existing RSTP state machines of the Bridge.  Each MSTI
+// keeps its own priority vectors, roles and runs its own port role and port
+// state transitions (see mstiprt.go) driven by the CIST port receive, role
+// selection, timer tick and state transitions.  The MSTI information is
+// protected by the bridge mstpMutex as it is accessed from each of those.
+package stp
+
+import (
+	"asicd/pluginManager/pluginCommon"
+	"crypto/hmac"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"sort"
+)
+
+const MstpModuleStr = "MSTP"
+
+// 14.4 BPDU encoding
+const (
+	MstpProtocolVersion        = 3
+	MstpMaxMsti                = 64
+	MstpMaxHopsDefault         = 20
+	MstpMaxHopsMin             = 6
+	MstpMaxHopsMax             = 40
+	MstpConfigIdFormatSelector = 0
+	MstpConfigNameLength       = 32
+	MstpConfigIdLength         = 51
+	MstpMstiConfigMsgLength    = 16
+	// length of the bpdu up to and including the CIST Remaining Hops
+	MstpBpduBaseLength = 102
+	// offset of the Version 3 Length field
+	MstpVersion3LengthOffset = 36
+	// Version 3 length not including the MSTI Configuration Messages
+	MstpVersion3BaseLength = 64
+	// 13.7 MSTID 0 represents the CIST
+	MstpCistMstid = 0
+)
+
+// 13.8 MST Configuration Identifier, Digest signature key
+var MstpDigestSignatureKey = []byte{0x13, 0xAC, 0x06, 0xA6, 0x2E, 0x47, 0xFD, 0x51,
+	0xF9, 0x5D, 0x2B, 0xA2, 0x43, 0xCD, 0x03, 0x46}
+
+// 13.8 MST Configuration Identifier
+type MstConfigId struct {
+	FormatSelector uint8
+	Name           [MstpConfigNameLength]uint8
+	Revision       uint16
+	Digest         [16]uint8
+}
+
+// 14.6.1 MSTI Configuration Messages
+type MstiConfigMsg struct {
+	Flags                uint8
+	RegionalRootId       BridgeId
+	InternalRootPathCost uint32
+	BridgePriority       uint8
+	PortPriority         uint8
+	RemainingHops        uint8
+}
+
+// 14.6 MST BPDU parameters
+type MstpBpdu struct {
+	ProtocolId               uint16
+	ProtocolVersionId        uint8
+	BPDUType                 uint8
+	CistFlags                uint8
+	CistRootId               BridgeId
+	CistExternalRootPathCost uint32
+	CistRegionalRootId       BridgeId
+	CistPortId               uint16
+	MsgAge                   uint16
+	MaxAge                   uint16
+	HelloTime                uint16
+	FwdDelay                 uint16
+	Version1Length           uint8
+	Version3Length           uint16
+	MstConfigId              MstConfigId
+	CistInternalRootPathCost uint32
+	CistBridgeId             BridgeId
+	CistRemainingHops        uint8
+	MstiConfigMsgs           []MstiConfigMsg
+}
+
+// MstiPort holds the per MSTI information for a CIST port
+type MstiPort struct {
+	IfIndex int32
+	// 13.25.33 msti port priority vector
+	PortPriority PriorityVector
+	// priority vector received in the last MSTI configuration message
+	MsgPriority PriorityVector
+	MsgFlags    uint8
+	RcvdHops    uint8
+	// valid MSTI message has been received from within the region
+	RcvdMsti bool
+	// 13.25.43 / 13.25.68
+	Role         PortRole
+	SelectedRole PortRole
+	Master       bool
+	// 13.27 per MSTI port role transition variables
+	Proposing bool
+	Proposed  bool
+	Agree     bool
+	Agreed    bool
+	Sync      bool
+	Synced    bool
+	ReRoot    bool
+	Learn     bool
+	Forward   bool
+	// 13.23 per MSTI timers, in seconds
+	FdWhile int32
+	RrWhile int32
+	RbWhile int32
+	// 13.38 port state as programmed in hw
+	Learning   bool
+	Forwarding bool
+	// 13.27.31 internal port path cost
+	InternalPortPathCost uint32
+	Priority             uint16
+}
+
+// MstInstance holds the MSTI information for a bridge
+type MstInstance struct {
+	Mstid uint16
+	Vlans []uint16
+	// 13.26.2
+	BridgeIdentifier BridgeId
+	// 13.26.3 msti bridge priority vector, root priority vector once
+	// role selection has been run
+	BridgePriority PriorityVector
+	RootPortId     int32
+	RemainingHops  uint8
+	Priority       uint16
+
+	// hw stgId
+	StgId int32
+
+	Ports map[int32]*MstiPort
+
+	b *Bridge
+}
+
+func MstConfigNameCreate(name string) (n [MstpConfigNameLength]uint8) {
+	copy(n[:], []byte(name))
+	return n
+}
+
+func MstConfigNameToString(n [MstpConfigNameLength]uint8) string {
+	l := 0
+	for l < MstpConfigNameLength && n[l] != 0 {
+		l++
+	}
+	return string(n[:l])
+}
+
+// MstConfigDigestCreate: 13.8
+// The digest is a HMAC-MD5 over the 4096 entry MST Configuration Table where
+// each entry is the two octet MSTID of the vlan, vlans not mapped are
+// allocated to the CIST (0)
+func MstConfigDigestCreate(vlanToMsti map[uint16]uint16) (digest [16]uint8) {
+	table := make([]byte, 4096*2)
+	for vlan, mstid := range vlanToMsti {
+		if vlan < 4096 {
+			binary.BigEndian.PutUint16(table[vlan*2:], mstid)
+		}
+	}
+	mac := hmac.New(md5.New, MstpDigestSignatureKey)
+	mac.Write(table)
+	copy(digest[:], mac.Sum(nil))
+	return digest
+}
+
+func (b *Bridge) MstpEnabled() bool {
+	return b.ForceVersion >= MstpProtocolVersion
+}
+
+// MstConfigIdSet will set the MST Configuration Name and Revision Level
+func (b *Bridge) MstConfigIdSet(name string, revision uint16) {
+	b.mstpMutex.Lock()
+	b.MstConfigId.Name = MstConfigNameCreate(name)
+	b.MstConfigId.Revision = revision
+	b.mstpMutex.Unlock()
+	b.MstConfigIdUpdate()
+}
+
+// MstConfigIdUpdate will re-calculate the digest based on the vlan to msti
+// mapping of the bridge
+func (b *Bridge) MstConfigIdUpdate() {
+	b.mstpMutex.Lock()
+	defer b.mstpMutex.Unlock()
+	vlanToMsti := make(map[uint16]uint16)
+	for _, msti := range b.MstiMap {
+		for _, vlan := range msti.Vlans {
+			vlanToMsti[vlan] = msti.Mstid
+		}
+	}
+	b.MstConfigId.FormatSelector = MstpConfigIdFormatSelector
+	b.MstConfigId.Digest = MstConfigDigestCreate(vlanToMsti)
+	StpMachineLogger("INFO", MstpModuleStr, -1, b.BrgIfIndex, fmt.Sprintf("MST Config Id name[%s] revision[%d] digest[%x]",
+		MstConfigNameToString(b.MstConfigId.Name), b.MstConfigId.Revision, b.MstConfigId.Digest))
+}
+
+// MstiFindVlan returns the msti a vlan is mapped to, CIST if not mapped
+func (b *Bridge) MstiFindVlan(vlan uint16) uint16 {
+	for _, msti := range b.MstiMap {
+		for _, v := range msti.Vlans {
+			if v == vlan {
+				return msti.Mstid
+			}
+		}
+	}
+	return MstpCistMstid
+}
+
+// MstiListGet returns the configured msti's in order of mstid
+func (b *Bridge) MstiListGet() []*MstInstance {
+	mstidList := make([]int, 0)
+	for mstid, _ := range b.MstiMap {
+		mstidList = append(mstidList, int(mstid))
+	}
+	sort.Ints(mstidList)
+	mstiList := make([]*MstInstance, 0)
+	for _, mstid := range mstidList {
+		mstiList = append(mstiList, b.MstiMap[uint16(mstid)])
+	}
+	return mstiList
+}
+
+// MstiReselect will force role selection to be re-run so that msti
+// changes are applied to all ports of the bridge
+func (b *Bridge) MstiReselect(src string) {
+	var p *StpPort
+	for _, pId := range b.StpPorts {
+		if StpFindPortByIfIndex(pId, b.BrgIfIndex, &p) {
+			p.Selected = false
+			p.Reselect = true
+		}
+	}
+	if b.PrsMachineFsm != nil {
+		b.PrsMachineFsm.PrsEvents <- MachineEvent{
+			e:   PrsEventReselect,
+			src: src,
+		}
+	}
+}
+
+func NewMstInstance(b *Bridge, mstid uint16, priority uint16, vlans []uint16) *MstInstance {
+	var p *StpPort
+	bridgeId := CreateBridgeId(GetBridgeAddrFromBridgeId(b.BridgeIdentifier), priority, mstid)
+	msti := &MstInstance{
+		Mstid:            mstid,
+		Vlans:            vlans,
+		BridgeIdentifier: bridgeId,
+		BridgePriority: PriorityVector{
+			RootBridgeId:       bridgeId,
+			DesignatedBridgeId: bridgeId,
+		},
+		RemainingHops: b.MaxHops,
+		Priority:      priority,
+		StgId:         -1,
+		Ports:         make(map[int32]*MstiPort),
+		b:             b,
+	}
+
+	b.mstpMutex.Lock()
+	// msti ports are created up front so the port machines only
+	// ever look them up
+	for _, pId := range b.StpPorts {
+		if StpFindPortByIfIndex(pId, b.BrgIfIndex, &p) {
+			msti.mstiPortAdd(p)
+		}
+	}
+	if b.MstiMap == nil {
+		b.MstiMap = make(map[uint16]*MstInstance)
+	}
+	b.MstiMap[mstid] = msti
+	b.mstpMutex.Unlock()
+	b.MstConfigIdUpdate()
+
+	if b.MstpEnabled() {
+		msti.Activate()
+	}
+	return msti
+}
+
+func DelMstInstance(msti *MstInstance) {
+	b := msti.b
+	msti.Deactivate()
+	b.mstpMutex.Lock()
+	delete(b.MstiMap, msti.Mstid)
+	b.mstpMutex.Unlock()
+	b.MstConfigIdUpdate()
+}
+
+// Activate will create the hw stg for the vlans associated with the msti
+func (msti *MstInstance) Activate() {
+	if msti.StgId == -1 {
+		msti.StgId = StpHwPluginGet().CreateStg(msti.Vlans)
+		StpMachineLogger("INFO", MstpModuleStr, -1, msti.b.BrgIfIndex, fmt.Sprintf("MSTI %d activated stg %d vlans %v", msti.Mstid, msti.StgId, msti.Vlans))
+	}
+}
+
+// Deactivate will delete the hw stg associated with the msti, the msti
+// ports return to the disabled role until the msti is activated
+func (msti *MstInstance) Deactivate() {
+	b := msti.b
+	if msti.StgId != -1 {
+		StpHwPluginGet().DeleteStg(msti.StgId, msti.Vlans)
+		StpMachineLogger("INFO", MstpModuleStr, -1, b.BrgIfIndex, fmt.Sprintf("MSTI %d deactivated stg %d", msti.Mstid, msti.StgId))
+		msti.StgId = -1
+	}
+	b.mstpMutex.Lock()
+	for ifindex, mp := range msti.Ports {
+		msti.Ports[ifindex] = &MstiPort{
+			IfIndex:              ifindex,
+			Role:                 PortRoleDisabledPort,
+			SelectedRole:         PortRoleDisabledPort,
+			Synced:               true,
+			FdWhile:              int32(b.RootTimes.MaxAge),
+			InternalPortPathCost: mp.InternalPortPathCost,
+			Priority:             mp.Priority,
+		}
+	}
+	b.mstpMutex.Unlock()
+}
+
+// mstiPortAdd must be called with the bridge mstpMutex held
+func (msti *MstInstance) mstiPortAdd(p *StpPort) {
+	if _, ok := msti.Ports[p.IfIndex]; !ok {
+		msti.Ports[p.IfIndex] = &MstiPort{
+			IfIndex:              p.IfIndex,
+			Role:                 PortRoleDisabledPort,
+			SelectedRole:         PortRoleDisabledPort,
+			Synced:               true,
+			FdWhile:              int32(msti.b.RootTimes.MaxAge),
+			InternalPortPathCost: p.PortPathCost,
+			Priority:             p.Priority,
+		}
+	}
+}
+
+// MstiPortGet returns the msti port info, nil if the port is not part of
+// the bridge.  Must be called with the bridge mstpMutex held
+func (msti *MstInstance) MstiPortGet(p *StpPort) *MstiPort {
+	return msti.Ports[p.IfIndex]
+}
+
+// MstiPortAdd creates the msti port info for a port which is being added
+// to the bridge
+func (b *Bridge) MstiPortAdd(p *StpPort) {
+	b.mstpMutex.Lock()
+	defer b.mstpMutex.Unlock()
+	for _, msti := range b.MstiMap {
+		msti.mstiPortAdd(p)
+	}
+}
+
+// MstiPortDel removes the msti port info for a port which is being
+// removed from the bridge
+func (b *Bridge) MstiPortDel(p *StpPort) {
+	b.mstpMutex.Lock()
+	defer b.mstpMutex.Unlock()
+	for _, msti := range b.MstiMap {
+		if mp, ok := msti.Ports[p.IfIndex]; ok {
+			if msti.StgId != -1 {
+				StpHwPluginGet().SetStgPortState(msti.StgId, p.IfIndex, pluginCommon.STP_PORT_STATE_BLOCKING)
+			}
+			mp.Learning = false
+			mp.Forwarding = false
+			delete(msti.Ports, p.IfIndex)
+		}
+	}
+}
+
+// MstpBpduDecode will decode the MST BPDU, data should start at the
+// Protocol Identifier
+func MstpBpduDecode(data []byte, bpdu *MstpBpdu) error {
+	if len(data) < MstpBpduBaseLength {
+		return errors.New(fmt.Sprintf("MSTP: Invalid BPDU length %d", len(data)))
+	}
+	bpdu.ProtocolId = binary.BigEndian.Uint16(data[0:2])
+	bpdu.ProtocolVersionId = data[2]
+	bpdu.BPDUType = data[3]
+	bpdu.CistFlags = data[4]
+	copy(bpdu.CistRootId[:], data[5:13])
+	bpdu.CistExternalRootPathCost = binary.BigEndian.Uint32(data[13:17])
+	copy(bpdu.CistRegionalRootId[:], data[17:25])
+	bpdu.CistPortId = binary.BigEndian.Uint16(data[25:27])
+	bpdu.MsgAge = binary.BigEndian.Uint16(data[27:29])
+	bpdu.MaxAge = binary.BigEndian.Uint16(data[29:31])
+	bpdu.HelloTime = binary.BigEndian.Uint16(data[31:33])
+	bpdu.FwdDelay = binary.BigEndian.Uint16(data[33:35])
+	bpdu.Version1Length = data[35]
+	bpdu.Version3Length = binary.BigEndian.Uint16(data[36:38])
+	bpdu.MstConfigId.FormatSelector = data[38]
+	copy(bpdu.MstConfigId.Name[:], data[39:71])
+	bpdu.MstConfigId.Revision = binary.BigEndian.Uint16(data[71:73])
+	copy(bpdu.MstConfigId.Digest[:], data[73:89])
+	bpdu.CistInternalRootPathCost = binary.BigEndian.Uint32(data[89:93])
+	copy(bpdu.CistBridgeId[:], data[93:101])
+	bpdu.CistRemainingHops = data[101]
+
+	// 14.4 (d) Version 3 Length must be a multiple of 16 and agree with the
+	// length of the received frame
+	if bpdu.Version3Length < MstpVersion3BaseLength ||
+		(bpdu.Version3Length-MstpVersion3BaseLength)%MstpMstiConfigMsgLength != 0 ||
+		len(data) < MstpBpduBaseLength+int(bpdu.Version3Length-MstpVersion3BaseLength) {
+		return errors.New(fmt.Sprintf("MSTP: Invalid Version 3 Length %d bpdu length %d", bpdu.Version3Length, len(data)))
+	}
+
+	numMsti := int(bpdu.Version3Length-MstpVersion3BaseLength) / MstpMstiConfigMsgLength
+	if numMsti > MstpMaxMsti {
+		return errors.New(fmt.Sprintf("MSTP: Invalid number of MSTI Configuration Messages %d", numMsti))
+	}
+	bpdu.MstiConfigMsgs = make([]MstiConfigMsg, numMsti)
+	for i := 0; i < numMsti; i++ {
+		msg := data[MstpBpduBaseLength+i*MstpMstiConfigMsgLength:]
+		bpdu.MstiConfigMsgs[i].Flags = msg[0]
+		copy(bpdu.MstiConfigMsgs[i].RegionalRootId[:], msg[1:9])
+		bpdu.MstiConfigMsgs[i].InternalRootPathCost = binary.BigEndian.Uint32(msg[9:13])
+		bpdu.MstiConfigMsgs[i].BridgePriority = msg[13]
+		bpdu.MstiConfigMsgs[i].PortPriority = msg[14]
+		bpdu.MstiConfigMsgs[i].RemainingHops = msg[15]
+	}
+	return nil
+}
+
+// MstpBpduEncode will encode the MST BPDU starting at the Protocol Identifier
+func MstpBpduEncode(bpdu *MstpBpdu) []byte {
+	bpdu.Version3Length = uint16(MstpVersion3BaseLength + len(bpdu.MstiConfigMsgs)*MstpMstiConfigMsgLength)
+	data := make([]byte, MstpBpduBaseLength+len(bpdu.MstiConfigMsgs)*MstpMstiConfigMsgLength)
+
+	binary.BigEndian.PutUint16(data[0:2], bpdu.ProtocolId)
+	data[2] = bpdu.ProtocolVersionId
+	data[3] = bpdu.BPDUType
+	data[4] = bpdu.CistFlags
+	copy(data[5:13], bpdu.CistRootId[:])
+	binary.BigEndian.PutUint32(data[13:17], bpdu.CistExternalRootPathCost)
+	copy(data[17:25], bpdu.CistRegionalRootId[:])
+	binary.BigEndian.PutUint16(data[25:27], bpdu.CistPortId)
+	binary.BigEndian.PutUint16(data[27:29], bpdu.MsgAge)
+	binary.BigEndian.PutUint16(data[29:31], bpdu.MaxAge)
+	binary.BigEndian.PutUint16(data[31:33], bpdu.HelloTime)
+	binary.BigEndian.PutUint16(data[33:35], bpdu.FwdDelay)
+	data[35] = bpdu.Version1Length
+	binary.BigEndian.PutUint16(data[36:38], bpdu.Version3Length)
+	data[38] = bpdu.MstConfigId.FormatSelector
+	copy(data[39:71], bpdu.MstConfigId.Name[:])
+	binary.BigEndian.PutUint16(data[71:73], bpdu.MstConfigId.Revision)
+	copy(data[73:89], bpdu.MstConfigId.Digest[:])
+	binary.BigEndian.PutUint32(data[89:93], bpdu.CistInternalRootPathCost)
+	copy(data[93:101], bpdu.CistBridgeId[:])
+	data[101] = bpdu.CistRemainingHops
+
+	for i, m := range bpdu.MstiConfigMsgs {
+		msg := data[MstpBpduBaseLength+i*MstpMstiConfigMsgLength:]
+		msg[0] = m.Flags
+		copy(msg[1:9], m.RegionalRootId[:])
+		binary.BigEndian.PutUint32(msg[9:13], m.InternalRootPathCost)
+		msg[13] = m.BridgePriority
+		msg[14] = m.PortPriority
+		msg[15] = m.RemainingHops
+	}
+	return data
+}
+
+// MstpSetMstiFlags: 14.6.1 (a) the topology change acknowledgement bit
+// is replaced by the master flag
+func MstpSetMstiFlags(master uint8, agreement uint8, forwarding uint8, learning uint8, role uint8, proposal uint8, topochange uint8, flags *uint8) {
+	StpSetBpduFlags(master, agreement, forwarding, learning, role, proposal, topochange, flags)
+}
+
+func MstpGetMstiMaster(flags uint8) bool {
+	return flags>>7&0x1 == 1
+}
+
+// MstpRcvdBpdu: 13.28.x rcvInfo/rcvMsgs
+// Records the MST configuration, CIST regional root information and MSTI
+// messages of a received BPDU, bpdu is nil when the BPDU is not an MST BPDU
+// and thus was sent from outside of the region.  The CIST portion of the
+// BPDU is handled by the Port Receive state machine
+func (p *StpPort) MstpRcvdBpdu(bpdu *MstpBpdu) {
+	b := p.b
+
+	b.mstpMutex.Lock()
+	// 13.26.5 rcvdInternal, BPDU was transmitted by a bridge in the same region
+	rcvdInternal := bpdu != nil && bpdu.MstConfigId == b.MstConfigId
+	boundaryChanged := rcvdInternal != p.RcvdInternal
+	if boundaryChanged {
+		StpMachineLogger("INFO", MstpModuleStr, p.IfIndex, p.BrgIfIndex, fmt.Sprintf("Port region boundary changed internal[%t]", rcvdInternal))
+	}
+	p.RcvdInternal = rcvdInternal
+	if rcvdInternal {
+		p.RcvdRegionalRootId = bpdu.CistRegionalRootId
+		p.RcvdInternalPathCost = bpdu.CistInternalRootPathCost
+		p.RcvdRemainingHops = bpdu.CistRemainingHops
+	}
+
+	reselect := boundaryChanged
+	// MSTI messages are only valid from within the region
+	for _, msti := range b.MstiMap {
+		mp := msti.MstiPortGet(p)
+		if mp == nil {
+			continue
+		}
+		found := false
+		if rcvdInternal {
+			for _, m := range bpdu.MstiConfigMsgs {
+				// 14.6.1 MSTID is encoded in the system id extension of the regional root
+				if GetBridgeVlanFromBridgeId(m.RegionalRootId) != msti.Mstid {
+					continue
+				}
+				found = true
+				// 13.27.x remaining hops exhausted, information is discarded
+				if m.RemainingHops == 0 {
+					found = false
+					break
+				}
+				msgPriority := PriorityVector{
+					RootBridgeId:       m.RegionalRootId,
+					RootPathCost:       m.InternalRootPathCost,
+					DesignatedBridgeId: CreateBridgeId(GetBridgeAddrFromBridgeId(bpdu.CistBridgeId), uint16(m.BridgePriority)<<8, msti.Mstid),
+					DesignatedPortId:   uint16(m.PortPriority)<<8 | (bpdu.CistPortId & 0x0fff),
+				}
+				if !mp.RcvdMsti ||
+					mp.MsgPriority != msgPriority ||
+					StpGetBpduRole(mp.MsgFlags) != StpGetBpduRole(m.Flags) {
+					reselect = true
+				}
+				// 13.28.x rcvAgreements / recordProposal
+				if StpGetBpduRole(m.Flags) == PortRoleDesignatedPort {
+					if StpGetBpduProposal(m.Flags) {
+						mp.Proposed = true
+					}
+				} else if StpGetBpduAgreement(m.Flags) {
+					mp.Agreed = true
+					mp.Proposing = false
+				}
+				mp.MsgPriority = msgPriority
+				mp.MsgFlags = m.Flags
+				mp.RcvdHops = m.RemainingHops
+				break
+			}
+		}
+		if !found && mp.RcvdMsti {
+			reselect = true
+		}
+		mp.RcvdMsti = found
+		// proposals and agreements are acted on immediately, a new
+		// priority vector waits for role selection
+		if found && !reselect {
+			msti.prtTreeRun()
+		}
+	}
+	b.mstpMutex.Unlock()
+
+	if boundaryChanged {
+		p.Selected = false
+		p.Reselect = true
+	}
+	if reselect && b.PrsMachineFsm != nil {
+		b.PrsMachineFsm.PrsEvents <- MachineEvent{
+			e:   PrsEventReselect,
+			src: MstpModuleStr,
+		}
+	}
+}
+
+// MstpCistRegionalUpdate: 13.26.x updtRolesTree for the CIST regional root.
+// When the CIST root port is internal to the region the regional root,
+// internal root path cost and remaining hops are learned from it, otherwise
+// this bridge is the regional root.  Must be called with the bridge
+// mstpMutex held
+func (b *Bridge) MstpCistRegionalUpdate() {
+	var p *StpPort
+
+	b.CistRegionalRootId = b.BridgeIdentifier
+	b.CistInternalRootPathCost = 0
+	b.CistRemainingHops = b.MaxHops
+	for _, pId := range b.StpPorts {
+		if StpFindPortByIfIndex(pId, b.BrgIfIndex, &p) &&
+			p.SelectedRole == PortRoleRootPort &&
+			p.RcvdInternal &&
+			p.InfoIs == PortInfoStateReceived &&
+			p.RcvdRemainingHops > 0 {
+			b.CistRegionalRootId = p.RcvdRegionalRootId
+			b.CistInternalRootPathCost = p.RcvdInternalPathCost + p.PortPathCost
+			b.CistRemainingHops = p.RcvdRemainingHops - 1
+			break
+		}
+	}
+}
+
+// MstpCistRegionalInfoGet returns the MST Configuration Identifier, CIST
+// regional root, internal root path cost and remaining hops to be transmitted
+func (b *Bridge) MstpCistRegionalInfoGet() (MstConfigId, BridgeId, uint32, uint8) {
+	b.mstpMutex.Lock()
+	defer b.mstpMutex.Unlock()
+	return b.MstConfigId, b.CistRegionalRootId, b.CistInternalRootPathCost, b.CistRemainingHops
+}
+
+// MstiUpdtRolesTree: 13.27.x updtRolesTree for each MSTI
+// Called as part of CIST role selection.  Internal ports select the
+// MSTI root port from the received MSTI priority vectors, boundary ports
+// take the role of the CIST port (root port becomes master port).
+func (b *Bridge) MstiUpdtRolesTree() {
+	var p *StpPort
+
+	if !b.MstpEnabled() {
+		return
+	}
+
+	b.mstpMutex.Lock()
+	defer b.mstpMutex.Unlock()
+
+	b.MstpCistRegionalUpdate()
+
+	for _, msti := range b.MstiListGet() {
+		rootVector := PriorityVector{
+			RootBridgeId:       msti.BridgeIdentifier,
+			DesignatedBridgeId: msti.BridgeIdentifier,
+		}
+		rootPortId := int32(0)
+		rootHops := b.MaxHops
+
+		// find the msti root port
+		for _, pId := range b.StpPorts {
+			if StpFindPortByIfIndex(pId, b.BrgIfIndex, &p) {
+				mp := msti.MstiPortGet(p)
+				if mp == nil ||
+					!p.PortEnabled ||
+					!p.RcvdInternal ||
+					!mp.RcvdMsti ||
+					p.InfoIs != PortInfoStateReceived {
+					continue
+				}
+				// do not use info that we sent
+				if CompareBridgeAddr(GetBridgeAddrFromBridgeId(mp.MsgPriority.DesignatedBridgeId),
+					GetBridgeAddrFromBridgeId(msti.BridgeIdentifier)) == 0 {
+					continue
+				}
+				candidate := mp.MsgPriority
+				candidate.RootPathCost += mp.InternalPortPathCost
+				candidate.BridgePortId = mp.Priority<<8 | p.PortId
+				if rootPortId == 0 ||
+					IsMsgPriorityVectorSuperiorThanPortPriorityVector(&candidate, &rootVector) {
+					if CompareBridgeId(candidate.RootBridgeId, msti.BridgeIdentifier) < 0 {
+						rootVector = candidate
+						rootPortId = int32(p.IfIndex)
+						rootHops = mp.RcvdHops - 1
+					}
+				}
+			}
+		}
+
+		msti.RootPortId = rootPortId
+		msti.RemainingHops = rootHops
+		msti.BridgePriority.RootBridgeId = rootVector.RootBridgeId
+		msti.BridgePriority.RootPathCost = rootVector.RootPathCost
+		msti.BridgePriority.DesignatedBridgeId = msti.BridgeIdentifier
+
+		// assign roles
+		for _, pId := range b.StpPorts {
+			if StpFindPortByIfIndex(pId, b.BrgIfIndex, &p) {
+				mp := msti.MstiPortGet(p)
+				if mp == nil {
+					continue
+				}
+				localPortId := mp.Priority<<8 | p.PortId
+				designatedVector := PriorityVector{
+					RootBridgeId:       msti.BridgePriority.RootBridgeId,
+					RootPathCost:       msti.BridgePriority.RootPathCost,
+					DesignatedBridgeId: msti.BridgeIdentifier,
+					DesignatedPortId:   localPortId,
+				}
+				mp.Master = false
+				if !p.PortEnabled || p.InfoIs == PortInfoStateDisabled {
+					mp.SelectedRole = PortRoleDisabledPort
+				} else if !p.RcvdInternal && p.InfoIs == PortInfoStateReceived {
+					// 13.27.x boundary port, msti role follows the cist role
+					switch p.SelectedRole {
+					case PortRoleRootPort:
+						mp.SelectedRole = PortRoleMasterPort
+						mp.Master = true
+					default:
+						mp.SelectedRole = p.SelectedRole
+					}
+					mp.PortPriority = designatedVector
+				} else if rootPortId == p.IfIndex {
+					mp.SelectedRole = PortRoleRootPort
+					mp.PortPriority = mp.MsgPriority
+				} else if mp.RcvdMsti &&
+					p.InfoIs == PortInfoStateReceived &&
+					IsDesignatedPriorytVectorNotHigherThanPortPriorityVector(&designatedVector, &mp.MsgPriority) {
+					if CompareBridgeAddr(GetBridgeAddrFromBridgeId(mp.MsgPriority.DesignatedBridgeId),
+						GetBridgeAddrFromBridgeId(msti.BridgeIdentifier)) != 0 {
+						mp.SelectedRole = PortRoleAlternatePort
+					} else {
+						mp.SelectedRole = PortRoleBackupPort
+					}
+					mp.PortPriority = mp.MsgPriority
+				} else {
+					// agreement was given for the previous port priority vector
+					if mp.PortPriority != designatedVector {
+						mp.Agreed = false
+					}
+					mp.SelectedRole = PortRoleDesignatedPort
+					mp.PortPriority = designatedVector
+				}
+
+				if mp.Role != mp.SelectedRole {
+					StpMachineLogger("INFO", MstpModuleStr, p.IfIndex, p.BrgIfIndex, fmt.Sprintf("MSTI %d role changed %d -> %d", msti.Mstid, mp.Role, mp.SelectedRole))
+					msti.prtRoleChanged(mp)
+				}
+				mp.Role = mp.SelectedRole
+			}
+		}
+		msti.prtTreeRun()
+	}
+}
+
+// MstiPortStateUpdate is called when the CIST port state changes so that
+// the msti ports at the region boundary can follow
+func (p *StpPort) MstiPortStateUpdate() {
+	b := p.b
+	if b == nil || !b.MstpEnabled() {
+		return
+	}
+	b.mstpMutex.Lock()
+	defer b.mstpMutex.Unlock()
+	for _, msti := range b.MstiMap {
+		msti.prtTreeRun()
+	}
+}
+
+// MstiDecrementTimerCounters is called on the port timer tick to run the
+// msti port timers
+func (p *StpPort) MstiDecrementTimerCounters() {
+	b := p.b
+	if b == nil || !b.MstpEnabled() {
+		return
+	}
+	b.mstpMutex.Lock()
+	defer b.mstpMutex.Unlock()
+	for _, msti := range b.MstiMap {
+		mp := msti.MstiPortGet(p)
+		if mp == nil {
+			continue
+		}
+		if mp.FdWhile > 0 {
+			mp.FdWhile--
+		}
+		if mp.RrWhile > 0 {
+			mp.RrWhile--
+		}
+		if mp.RbWhile > 0 {
+			mp.RbWhile--
+		}
+		msti.prtTreeRun()
+	}
+}
+
+func (p *StpPort) BuildMstiConfigMsgs() []MstiConfigMsg {
+	b := p.b
+	b.mstpMutex.Lock()
+	defer b.mstpMutex.Unlock()
+	msgs := make([]MstiConfigMsg, 0)
+	for _, msti := range b.MstiListGet() {
+		mp := msti.MstiPortGet(p)
+		if mp == nil {
+			continue
+		}
+		var flags uint8
+		MstpSetMstiFlags(ConvertBoolToUint8(mp.Master),
+			ConvertBoolToUint8(mp.Agree),
+			ConvertBoolToUint8(mp.Forwarding),
+			ConvertBoolToUint8(mp.Learning),
+			ConvertRoleToPktRole(mp.Role),
+			ConvertBoolToUint8(mp.Proposing && mp.Role == PortRoleDesignatedPort),
+			ConvertBoolToUint8(p.TcWhileTimer.count != 0),
+			&flags)
+
+		msgs = append(msgs, MstiConfigMsg{
+			Flags:                flags,
+			RegionalRootId:       msti.BridgePriority.RootBridgeId,
+			InternalRootPathCost: msti.BridgePriority.RootPathCost,
+			BridgePriority:       uint8(msti.Priority >> 8 & 0xf0),
+			PortPriority:         uint8(mp.Priority & 0xf0),
+			RemainingHops:        msti.RemainingHops,
+		})
+	}
+	return msgs
+}
+
+// TxMSTP: 14.4 transmit an MST BPDU, CIST information is the same as what
+// would be sent in an RST BPDU along with the CIST regional root vector
+func (p *StpPort) TxMSTP() {
+	b := p.b
+	eth, llc := p.BuildRSTPEthernetLlcHeaders()
+
+	var flags uint8
+	StpSetBpduFlags(ConvertBoolToUint8(p.TcAck),
+		ConvertBoolToUint8(p.Agree),
+		ConvertBoolToUint8(p.Forwarding),
+		ConvertBoolToUint8(p.Learning),
+		ConvertRoleToPktRole(p.Role),
+		ConvertBoolToUint8(p.Proposed),
+		ConvertBoolToUint8(p.TcWhileTimer.count != 0),
+		&flags)
+
+	mstConfigId, regionalRootId, internalRootPathCost, remainingHops := b.MstpCistRegionalInfoGet()
+	bpdu := &MstpBpdu{
+		ProtocolId:               layers.RSTPProtocolIdentifier,
+		ProtocolVersionId:        MstpProtocolVersion,
+		BPDUType:                 uint8(layers.BPDUTypeRSTP),
+		CistFlags:                flags,
+		CistRootId:               p.PortPriority.RootBridgeId,
+		CistExternalRootPathCost: uint32(b.BridgePriority.RootPathCost),
+		CistRegionalRootId:       regionalRootId,
+		CistPortId:               uint16(p.PortId | p.Priority<<8),
+		MsgAge:                   uint16(b.RootTimes.MessageAge << 8),
+		MaxAge:                   uint16(b.RootTimes.MaxAge << 8),
+		HelloTime:                uint16(b.RootTimes.HelloTime << 8),
+		FwdDelay:                 uint16(b.RootTimes.ForwardingDelay << 8),
+		Version1Length:           0,
+		MstConfigId:              mstConfigId,
+		CistInternalRootPathCost: internalRootPathCost,
+		CistBridgeId:             b.BridgePriority.DesignatedBridgeId,
+		CistRemainingHops:        remainingHops,
+		MstiConfigMsgs:           p.BuildMstiConfigMsgs(),
+	}
+
+	payload := gopacket.Payload(MstpBpduEncode(bpdu))
+	eth.Length = uint16(len(payload) + 3)
+
+	// Set up buffer and options for serialization.
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{
+		FixLengths:       true,
+		ComputeChecksums: true,
+	}
+	gopacket.SerializeLayers(buf, opts, &eth, &llc, &payload)
+	if err := p.handle.WritePacketData(buf.Bytes()); err != nil {
+		StpLogger("ERROR", fmt.Sprintf("Error writing packet to interface %s\n", err))
+		return
+	}
+	pIntf, _ := PortConfigMap[p.IfIndex]
+	p.SetTxPortCounters(BPDURxTypeMSTP)
+	if p.TcWhileTimer.count != 0 {
+		StpMachineLogger("INFO", "TX", p.IfIndex, p.BrgIfIndex, fmt.Sprintf("Sent TC packet on interface %s\n", pIntf.Name))
+		p.SetTxPortCounters(BPDURxTypeTopo)
+	}
+	if p.TcAck {
+		StpMachineLogger("INFO", "TX", p.IfIndex, p.BrgIfIndex, fmt.Sprintf("Sent TC Ack packet on interface %s\n", pIntf.Name))
+		p.SetTxPortCounters(BPDURxTypeTopoAck)
+	}
+}
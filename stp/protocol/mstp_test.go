@@ -0,0 +1,404 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// mstp_test.go
+package stp
+
+import (
+	"asicd/pluginManager/pluginCommon"
+	"encoding/hex"
+	"reflect"
+	"testing"
+)
+
+func TestMstConfigDigestNoMsti(t *testing.T) {
+
+	// all vlans mapped to the CIST
+	digest := MstConfigDigestCreate(map[uint16]uint16{})
+	if hex.EncodeToString(digest[:]) != "ac36177f50283cd4b83821d8ab26de62" {
+		t.Error("ERROR MST Config Digest for all vlans mapped to CIST is invalid", hex.EncodeToString(digest[:]))
+	}
+
+	digest2 := MstConfigDigestCreate(map[uint16]uint16{10: 1})
+	if digest == digest2 {
+		t.Error("ERROR MST Config Digest did not change when vlan mapped to MSTI")
+	}
+}
+
+func TestMstpBpduEncodeDecode(t *testing.T) {
+
+	bpdu := &MstpBpdu{
+		ProtocolId:               0,
+		ProtocolVersionId:        MstpProtocolVersion,
+		BPDUType:                 2,
+		CistFlags:                0x7c,
+		CistRootId:               BridgeId{0x80, 0x00, 0x00, 0x11, 0x11, 0x11, 0x11, 0x11},
+		CistExternalRootPathCost: 20000,
+		CistRegionalRootId:       BridgeId{0x80, 0x00, 0x00, 0x22, 0x22, 0x22, 0x22, 0x22},
+		CistPortId:               0x8001,
+		MsgAge:                   1 << 8,
+		MaxAge:                   20 << 8,
+		HelloTime:                2 << 8,
+		FwdDelay:                 15 << 8,
+		MstConfigId: MstConfigId{
+			FormatSelector: MstpConfigIdFormatSelector,
+			Name:           MstConfigNameCreate("region1"),
+			Revision:       1,
+			Digest:         MstConfigDigestCreate(map[uint16]uint16{10: 1, 20: 2}),
+		},
+		CistInternalRootPathCost: 2000,
+		CistBridgeId:             BridgeId{0x80, 0x00, 0x00, 0x22, 0x22, 0x22, 0x22, 0x22},
+		CistRemainingHops:        MstpMaxHopsDefault,
+		MstiConfigMsgs: []MstiConfigMsg{
+			MstiConfigMsg{
+				Flags:                0x7c,
+				RegionalRootId:       BridgeId{0x80, 0x01, 0x00, 0x22, 0x22, 0x22, 0x22, 0x22},
+				InternalRootPathCost: 0,
+				BridgePriority:       0x80,
+				PortPriority:         0x80,
+				RemainingHops:        MstpMaxHopsDefault,
+			},
+			MstiConfigMsg{
+				Flags:                0x0c,
+				RegionalRootId:       BridgeId{0x10, 0x02, 0x00, 0x33, 0x33, 0x33, 0x33, 0x33},
+				InternalRootPathCost: 2000,
+				BridgePriority:       0x80,
+				PortPriority:         0x80,
+				RemainingHops:        MstpMaxHopsDefault - 1,
+			},
+		},
+	}
+
+	data := MstpBpduEncode(bpdu)
+	if len(data) != MstpBpduBaseLength+2*MstpMstiConfigMsgLength {
+		t.Error("ERROR MST BPDU encoded length invalid", len(data))
+	}
+	if bpdu.Version3Length != MstpVersion3BaseLength+2*MstpMstiConfigMsgLength {
+		t.Error("ERROR MST BPDU Version 3 Length invalid", bpdu.Version3Length)
+	}
+
+	var rxbpdu MstpBpdu
+	err := MstpBpduDecode(data, &rxbpdu)
+	if err != nil {
+		t.Error("ERROR MST BPDU decode failed", err)
+	}
+	if !reflect.DeepEqual(*bpdu, rxbpdu) {
+		t.Errorf("ERROR MST BPDU decode does not match encode\ntx %#v\nrx %#v", *bpdu, rxbpdu)
+	}
+
+	// truncated msti messages should be rejected
+	err = MstpBpduDecode(data[:len(data)-1], &rxbpdu)
+	if err == nil {
+		t.Error("ERROR MST BPDU decode of truncated frame passed")
+	}
+}
+
+var mstpTestBridgeAddr = [6]uint8{0x00, 0x55, 0x55, 0x55, 0x55, 0x55}
+var mstpTestNeighborA = [6]uint8{0x00, 0x11, 0x11, 0x11, 0x11, 0x11}
+var mstpTestNeighborB = [6]uint8{0x00, 0x22, 0x22, 0x22, 0x22, 0x22}
+
+// UsedForTestOnlyMstpTestSetup creates an MSTP bridge with MSTI 1 and three
+// ports, ports 1 and 2 have received info from the region and port 3 is a
+// designated port at the region boundary
+func UsedForTestOnlyMstpTestSetup(t *testing.T) (*Bridge, *MstInstance, []*StpPort, *MockHwPlugin) {
+	mock := NewMockHwPlugin()
+	StpHwPluginSet(mock)
+
+	b := &Bridge{
+		BrgIfIndex:       77,
+		ForceVersion:     MstpProtocolVersion,
+		BridgeIdentifier: CreateBridgeId(mstpTestBridgeAddr, 0x8000, 0),
+		MaxHops:          MstpMaxHopsDefault,
+		RootTimes: Times{
+			ForwardingDelay: BridgeForwardDelayDefault,
+			HelloTime:       BridgeHelloTimeDefault,
+			MaxAge:          BridgeMaxAgeDefault,
+		},
+		MstiMap: make(map[uint16]*MstInstance),
+	}
+	b.MstConfigIdSet("region1", 1)
+
+	ports := make([]*StpPort, 0)
+	for i := int32(1); i <= 3; i++ {
+		p := &StpPort{
+			IfIndex:      i,
+			BrgIfIndex:   b.BrgIfIndex,
+			PortEnabled:  true,
+			PortId:       uint16(i),
+			Priority:     0x80,
+			PortPathCost: 20000,
+			InfoIs:       PortInfoStateReceived,
+			SelectedRole: PortRoleAlternatePort,
+			b:            b,
+		}
+		PortMapTable[PortMapKey{p.IfIndex, p.BrgIfIndex}] = p
+		b.StpPorts = append(b.StpPorts, p.IfIndex)
+		ports = append(ports, p)
+	}
+	ports[2].InfoIs = PortInfoStateMine
+	ports[2].SelectedRole = PortRoleDesignatedPort
+
+	msti := NewMstInstance(b, 1, 0x8000, []uint16{10})
+	if msti.StgId == -1 || len(msti.Ports) != len(ports) {
+		t.Fatal("ERROR MSTI not activated with all bridge ports", msti.StgId, msti.Ports)
+	}
+	return b, msti, ports, mock
+}
+
+func UsedForTestOnlyMstpTestTeardown(ports []*StpPort, prev HwPlugin) {
+	for _, p := range ports {
+		delete(PortMapTable, PortMapKey{p.IfIndex, p.BrgIfIndex})
+	}
+	StpHwPluginSet(prev)
+}
+
+// UsedForTestOnlyMstpBpdu builds an MST BPDU from the region carrying a
+// single MSTI 1 configuration message
+func UsedForTestOnlyMstpBpdu(b *Bridge, sender [6]uint8, mstiPriority uint8, regionalRoot BridgeId, cost uint32, role PortRole, agreement bool, proposal bool) *MstpBpdu {
+	var flags uint8
+	MstpSetMstiFlags(0,
+		ConvertBoolToUint8(agreement),
+		0,
+		0,
+		ConvertRoleToPktRole(role),
+		ConvertBoolToUint8(proposal),
+		0,
+		&flags)
+
+	return &MstpBpdu{
+		ProtocolVersionId:        MstpProtocolVersion,
+		CistRootId:               CreateBridgeId(mstpTestNeighborA, 0x8000, 0),
+		CistRegionalRootId:       CreateBridgeId(mstpTestNeighborA, 0x8000, 0),
+		CistPortId:               0x8001,
+		MstConfigId:              b.MstConfigId,
+		CistInternalRootPathCost: 100,
+		CistBridgeId:             CreateBridgeId(sender, 0x8000, 0),
+		CistRemainingHops:        MstpMaxHopsDefault,
+		MstiConfigMsgs: []MstiConfigMsg{
+			MstiConfigMsg{
+				Flags:                flags,
+				RegionalRootId:       regionalRoot,
+				InternalRootPathCost: cost,
+				BridgePriority:       mstiPriority,
+				PortPriority:         0x80,
+				RemainingHops:        MstpMaxHopsDefault,
+			},
+		},
+	}
+}
+
+func UsedForTestOnlyMstpLastPortState(mock *MockHwPlugin, stgid int32, ifindex int32) int {
+	state := -1
+	for _, c := range mock.Calls(MockHwOpSetStgPortState) {
+		if c.StgId == stgid && c.IfIndex == ifindex {
+			state = c.State
+		}
+	}
+	return state
+}
+
+func TestMstiRootPortAgreesAndForwards(t *testing.T) {
+	prev := StpHwPluginGet()
+	b, msti, ports, mock := UsedForTestOnlyMstpTestSetup(t)
+	defer UsedForTestOnlyMstpTestTeardown(ports, prev)
+
+	// neighbor A is the msti regional root and proposes, neighbor B
+	// offers a worse path to the same root
+	rootId := CreateBridgeId(mstpTestNeighborA, 0x1000, 1)
+	ports[0].SelectedRole = PortRoleRootPort
+	ports[0].MstpRcvdBpdu(UsedForTestOnlyMstpBpdu(b, mstpTestNeighborA, 0x10, rootId, 0, PortRoleDesignatedPort, false, true))
+	ports[1].MstpRcvdBpdu(UsedForTestOnlyMstpBpdu(b, mstpTestNeighborB, 0x20, rootId, 20000, PortRoleDesignatedPort, false, false))
+	// port 3 never hears from the region
+	ports[2].MstpRcvdBpdu(nil)
+
+	b.MstiUpdtRolesTree()
+
+	mp1, mp2, mp3 := msti.Ports[1], msti.Ports[2], msti.Ports[3]
+	if mp1.Role != PortRoleRootPort ||
+		mp2.Role != PortRoleAlternatePort ||
+		mp3.Role != PortRoleDesignatedPort {
+		t.Fatal("ERROR MSTI roles not selected", mp1.Role, mp2.Role, mp3.Role)
+	}
+	if msti.RootPortId != 1 ||
+		msti.RemainingHops != MstpMaxHopsDefault-1 ||
+		msti.BridgePriority.RootBridgeId != rootId {
+		t.Error("ERROR MSTI root info not learned from root port", msti.RootPortId, msti.RemainingHops, msti.BridgePriority)
+	}
+
+	// all other ports are synced so the root port agrees and, as no other
+	// port is a recent root, transitions straight to forwarding
+	if !mp1.Agree || !mp1.Forwarding {
+		t.Error("ERROR MSTI root port did not agree and forward", mp1.Agree, mp1.Forwarding)
+	}
+	if UsedForTestOnlyMstpLastPortState(mock, msti.StgId, 1) != pluginCommon.STP_PORT_STATE_FORWARDING {
+		t.Error("ERROR MSTI root port forwarding state not programmed", mock.Calls(MockHwOpSetStgPortState))
+	}
+	if mp2.Learning || mp2.Forwarding {
+		t.Error("ERROR MSTI alternate port not discarding")
+	}
+
+	// the boundary port follows the cist port state
+	if mp3.Forwarding {
+		t.Error("ERROR MSTI boundary port forwarding while CIST port discarding")
+	}
+	ports[2].Learning = true
+	ports[2].Forwarding = true
+	ports[2].MstiPortStateUpdate()
+	if !mp3.Forwarding ||
+		UsedForTestOnlyMstpLastPortState(mock, msti.StgId, 3) != pluginCommon.STP_PORT_STATE_FORWARDING {
+		t.Error("ERROR MSTI boundary port did not follow CIST port state")
+	}
+
+	// cist regional root is learned from the internal cist root port
+	_, regionalRootId, cost, hops := b.MstpCistRegionalInfoGet()
+	if regionalRootId != CreateBridgeId(mstpTestNeighborA, 0x8000, 0) ||
+		cost != 100+ports[0].PortPathCost ||
+		hops != MstpMaxHopsDefault-1 {
+		t.Error("ERROR CIST regional root info not learned", regionalRootId, cost, hops)
+	}
+
+	msgs := ports[0].BuildMstiConfigMsgs()
+	if len(msgs) != 1 ||
+		!StpGetBpduAgreement(msgs[0].Flags) ||
+		StpGetBpduProposal(msgs[0].Flags) ||
+		StpGetBpduRole(msgs[0].Flags) != PortRoleRootPort ||
+		msgs[0].RegionalRootId != rootId ||
+		msgs[0].RemainingHops != MstpMaxHopsDefault-1 {
+		t.Error("ERROR MSTI root port config message invalid", msgs)
+	}
+}
+
+func TestMstiDesignatedPortProposalAgreement(t *testing.T) {
+	prev := StpHwPluginGet()
+	b, msti, ports, _ := UsedForTestOnlyMstpTestSetup(t)
+	defer UsedForTestOnlyMstpTestTeardown(ports, prev)
+
+	// this bridge is the msti regional root
+	ports[1].PortEnabled = false
+	neighborId := CreateBridgeId(mstpTestNeighborA, 0xf000, 1)
+	ports[0].MstpRcvdBpdu(UsedForTestOnlyMstpBpdu(b, mstpTestNeighborA, 0xf0, neighborId, 0, PortRoleDesignatedPort, false, false))
+	b.MstiUpdtRolesTree()
+
+	mp := msti.Ports[1]
+	if mp.Role != PortRoleDesignatedPort {
+		t.Fatal("ERROR MSTI port not designated", mp.Role)
+	}
+	if !mp.Proposing || mp.Learning || mp.Forwarding {
+		t.Error("ERROR MSTI designated port not proposing and discarding", mp.Proposing, mp.Learning, mp.Forwarding)
+	}
+	msgs := ports[0].BuildMstiConfigMsgs()
+	if len(msgs) != 1 || !StpGetBpduProposal(msgs[0].Flags) {
+		t.Error("ERROR MSTI designated port proposal not sent", msgs)
+	}
+
+	// neighbor agrees, port may forward without waiting forward delay
+	ports[0].MstpRcvdBpdu(UsedForTestOnlyMstpBpdu(b, mstpTestNeighborA, 0xf0, msti.BridgeIdentifier, 20000, PortRoleRootPort, true, false))
+	b.MstiUpdtRolesTree()
+	if !mp.Agreed || mp.Proposing || !mp.Forwarding {
+		t.Error("ERROR MSTI designated port did not forward on agreement", mp.Agreed, mp.Proposing, mp.Forwarding)
+	}
+}
+
+func TestMstiDesignatedPortForwardDelay(t *testing.T) {
+	prev := StpHwPluginGet()
+	b, msti, ports, _ := UsedForTestOnlyMstpTestSetup(t)
+	defer UsedForTestOnlyMstpTestTeardown(ports, prev)
+
+	ports[1].PortEnabled = false
+	neighborId := CreateBridgeId(mstpTestNeighborA, 0xf000, 1)
+	ports[0].MstpRcvdBpdu(UsedForTestOnlyMstpBpdu(b, mstpTestNeighborA, 0xf0, neighborId, 0, PortRoleDesignatedPort, false, false))
+	b.MstiUpdtRolesTree()
+
+	// no agreement from the neighbor, port must wait for fdWhile to expire
+	// before learning and then again before forwarding
+	mp := msti.Ports[1]
+	for i := 0; i < int(b.RootTimes.MaxAge)-1; i++ {
+		ports[0].MstiDecrementTimerCounters()
+	}
+	if mp.Learning {
+		t.Error("ERROR MSTI designated port learning before fdWhile expired")
+	}
+	ports[0].MstiDecrementTimerCounters()
+	if !mp.Learning || mp.Forwarding {
+		t.Error("ERROR MSTI designated port not learning after fdWhile expired", mp.Learning, mp.Forwarding)
+	}
+	for i := 0; i < int(b.RootTimes.ForwardingDelay)-1; i++ {
+		ports[0].MstiDecrementTimerCounters()
+	}
+	if mp.Forwarding {
+		t.Error("ERROR MSTI designated port forwarding before forward delay expired")
+	}
+	ports[0].MstiDecrementTimerCounters()
+	if !mp.Forwarding {
+		t.Error("ERROR MSTI designated port not forwarding after forward delay expired")
+	}
+}
+
+func TestMstiPortAddDel(t *testing.T) {
+	prev := StpHwPluginGet()
+	b, msti, ports, _ := UsedForTestOnlyMstpTestSetup(t)
+	defer UsedForTestOnlyMstpTestTeardown(ports, prev)
+
+	p := &StpPort{IfIndex: 4, BrgIfIndex: b.BrgIfIndex, Priority: 0x80, b: b}
+	b.MstiPortAdd(p)
+	if msti.MstiPortGet(p) == nil {
+		t.Error("ERROR MSTI port not created when port added to bridge")
+	}
+	b.MstiPortDel(p)
+	if msti.MstiPortGet(p) != nil {
+		t.Error("ERROR MSTI port not removed when port deleted from bridge")
+	}
+}
+
+func TestStpBrgMstConfigRevisionSet(t *testing.T) {
+	prev := StpHwPluginGet()
+	StpHwPluginSet(NewMockHwPlugin())
+	defer StpHwPluginSet(prev)
+
+	bridgeconfig := &StpBridgeConfig{
+		Address:      "00:55:55:55:55:55",
+		Priority:     0x8000,
+		MaxAge:       BridgeMaxAgeDefault,
+		HelloTime:    BridgeHelloTimeDefault,
+		ForwardDelay: BridgeForwardDelayDefault,
+		ForceVersion: MstpProtocolVersion,
+		TxHoldCount:  TransmitHoldCountDefault,
+		BrgIfIndex:   78,
+	}
+	b := NewStpBridge(bridgeconfig)
+	StpBridgeConfigMap[b.BrgIfIndex] = *bridgeconfig
+	defer func() {
+		DelStpBridge(b, true)
+		delete(StpBridgeConfigMap, b.BrgIfIndex)
+	}()
+
+	if err := StpBrgMstConfigRevisionSet(b.BrgIfIndex, 5); err != nil {
+		t.Fatal("ERROR MST Config Revision set failed", err)
+	}
+	if StpBridgeConfigMap[b.BrgIfIndex].MstConfigRevision != 5 {
+		t.Error("ERROR MST Config Revision not saved in bridge config", StpBridgeConfigMap[b.BrgIfIndex])
+	}
+	if b.MstConfigId.Revision != 5 {
+		t.Error("ERROR MST Config Revision not applied to bridge", b.MstConfigId.Revision)
+	}
+}
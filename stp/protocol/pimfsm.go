@@ -188,6 +188,9 @@ func (pim *PimMachine) PimMachineDisabled(m fsm.Machine, data interface{}) fsm.S
 	p.Agreed = false
 	p.RcvdInfoWhiletimer.count = 0
 	p.InfoIs = PortInfoStateDisabled
+	// link went down, guard state starts over when the port comes back
+	p.LoopGuardInconsistant = false
+	p.RootGuardInconsistant = false
 	defer p.NotifySelectedChanged(PimMachineModuleStr, p.Selected, false)
 	p.Selected = false
 	defer pim.NotifyReselectChanged(p.Reselect, true)
@@ -198,6 +201,16 @@ func (pim *PimMachine) PimMachineDisabled(m fsm.Machine, data interface{}) fsm.S
 // PimMachineAged
 func (pim *PimMachine) PimMachineAged(m fsm.Machine, data interface{}) fsm.State {
 	p := pim.p
+	// loop guard, info was not refreshed on a root, alternate or backup
+	// port which may mean the link has become unidirectional so keep the
+	// port from becoming designated until a bpdu is received
+	if p.LoopGuard &&
+		(p.Role == PortRoleRootPort ||
+			p.Role == PortRoleAlternatePort ||
+			p.Role == PortRoleBackupPort) {
+		StpMachineLogger("INFO", PimMachineModuleStr, p.IfIndex, p.BrgIfIndex, "Loop Guard inconsistant, info aged on port")
+		p.LoopGuardInconsistant = true
+	}
 	p.InfoIs = PortInfoStateAged
 	defer p.NotifySelectedChanged(PimMachineModuleStr, p.Selected, false)
 	p.Selected = false
@@ -254,6 +267,16 @@ func (pim *PimMachine) PimMachineReceive(m fsm.Machine, data interface{}) fsm.St
 		p.BridgeAssuranceInconsistant = false
 	}
 
+	// loop guard recovery, port priority still holds the aged info so
+	// a repeated bpdu needs to be recorded again
+	if p.LoopGuardInconsistant {
+		StpMachineLogger("INFO", PimMachineModuleStr, p.IfIndex, p.BrgIfIndex, "Loop Guard recovered, bpdu received on port")
+		p.LoopGuardInconsistant = false
+		if p.RcvdInfo == RepeatedDesignatedInfo {
+			p.RcvdInfo = SuperiorDesignatedInfo
+		}
+	}
+
 	return PimStateReceive
 }
 
@@ -1125,3 +1125,51 @@ func TestPimCurrentStateInfoIsEqualReceivedAndrcvdInfoWhileEqualZeroAndNotUpdtIn
 
 // TODO test Superior Designated
 //func TestPimCurrentStateRcvdMsgAndNotUpdtInfo()
+
+func TestPimCurrentStateRcvdInfoWhileExpiredLoopGuard(t *testing.T) {
+	testChan := make(chan string)
+	p := UsedForTestOnlyPimStartInCurrentState(t)
+
+	// alternate port stops receiving bpdus
+	p.LoopGuard = true
+	p.Role = PortRoleAlternatePort
+	p.InfoIs = PortInfoStateReceived
+	p.RcvdInfoWhiletimer.count = 0
+	p.UpdtInfo = false
+	p.RcvdMsg = false
+	p.PimMachineFsm.PimEvents <- MachineEvent{e: PimEventInflsEqualReceivedAndRcvdInfoWhileEqualZeroAndNotUpdtInfoAndNotRcvdMsg,
+		src:          "TEST",
+		responseChan: testChan,
+	}
+	<-testChan
+
+	UsedForTestOnlyPimCheckAgedState(p, t)
+	if !p.LoopGuardInconsistant {
+		t.Error("Failed loop guard inconsistant not set on aged alternate port")
+	}
+	UsedForTestOnlyPimTestTeardown(p, t)
+}
+
+func TestPimCurrentStateRcvdInfoWhileExpiredLoopGuardDesignated(t *testing.T) {
+	testChan := make(chan string)
+	p := UsedForTestOnlyPimStartInCurrentState(t)
+
+	// designated ports are not protected by loop guard
+	p.LoopGuard = true
+	p.Role = PortRoleDesignatedPort
+	p.InfoIs = PortInfoStateReceived
+	p.RcvdInfoWhiletimer.count = 0
+	p.UpdtInfo = false
+	p.RcvdMsg = false
+	p.PimMachineFsm.PimEvents <- MachineEvent{e: PimEventInflsEqualReceivedAndRcvdInfoWhileEqualZeroAndNotUpdtInfoAndNotRcvdMsg,
+		src:          "TEST",
+		responseChan: testChan,
+	}
+	<-testChan
+
+	UsedForTestOnlyPimCheckAgedState(p, t)
+	if p.LoopGuardInconsistant {
+		t.Error("Failed loop guard inconsistant set on aged designated port")
+	}
+	UsedForTestOnlyPimTestTeardown(p, t)
+}
@@ -27,10 +27,9 @@ package stp
 import (
 	"asicd/pluginManager/pluginCommon"
 	"fmt"
-	"github.com/google/gopacket"
 	"github.com/google/gopacket/layers"
-	"github.com/google/gopacket/pcap"
 	"github.com/vishvananda/netlink"
+	"l2/pktio"
 	"net"
 	"strings"
 	"sync"
@@ -42,6 +41,22 @@ var PortMapTable map[PortMapKey]*StpPort
 var PortListTable []*StpPort
 var PortConfigMap map[int32]portConfig
 
+// STP/RSTP/MSTP and PVST BPDUs are all that stp needs to receive
+const StpPktIoFilter = "ether dst 01:80:c2:00:00:00 or ether dst 01:00:0c:cc:cc:cd"
+
+// packet I/O backend used when opening the port interfaces
+var gStpPktIoConfig = pktio.DefaultConfig()
+
+// StpPktIoConfigSet will select the packet I/O backend used by
+// ports created after this call
+func StpPktIoConfigSet(cfg pktio.Config) {
+	gStpPktIoConfig = cfg
+}
+
+func StpPktIoConfigGet() pktio.Config {
+	return gStpPktIoConfig
+}
+
 const PortConfigModuleStr = "PORT CFG"
 
 type PortMapKey struct {
@@ -56,6 +71,21 @@ type portConfig struct {
 	IfIndex      int32
 }
 
+// StpPortConfigMapSet will add an interface which is not learned from
+// asicd, the simulator uses this for its virtual interfaces
+func StpPortConfigMapSet(ifIndex int32, name string, mac net.HardwareAddr, speed int32) {
+	PortConfigMap[ifIndex] = portConfig{
+		Name:         name,
+		HardwareAddr: mac,
+		Speed:        speed,
+		IfIndex:      ifIndex,
+	}
+}
+
+func StpPortConfigMapDelete(ifIndex int32) {
+	delete(PortConfigMap, ifIndex)
+}
+
 type StpPort struct {
 	IfIndex        int32
 	ProtocolPortId uint16
@@ -67,6 +97,7 @@ type StpPort struct {
 	AdminEdge                   bool
 	AutoEdgePort                bool // optional
 	AdminPathCost               int32
+	BpduFilter                  BpduFilterMode
 	BpduGuard                   bool
 	BpduGuardInterval           int32
 	BridgeAssurance             bool
@@ -78,6 +109,8 @@ type StpPort struct {
 	InfoIs                      PortInfoState
 	Learn                       bool
 	Learning                    bool
+	LoopGuard                   bool
+	LoopGuardInconsistant       bool
 	Mcheck                      bool
 	MsgPriority                 PriorityVector
 	MsgTimes                    Times
@@ -92,6 +125,10 @@ type StpPort struct {
 	Proposed                    bool
 	Proposing                   bool
 	RcvdBPDU                    bool
+	RcvdInternal                bool     // 13.26.x
+	RcvdRegionalRootId          BridgeId // 13.26.x protected by the bridge mstpMutex
+	RcvdInternalPathCost        uint32
+	RcvdRemainingHops           uint8
 	RcvdInfo                    PortDesignatedRcvInfo
 	RcvdMsg                     bool
 	RcvdRSTP                    bool
@@ -103,6 +140,8 @@ type StpPort struct {
 	ReRoot                      bool
 	Reselect                    bool
 	Role                        PortRole
+	RootGuard                   bool
+	RootGuardInconsistant       bool
 	Selected                    bool
 	SelectedRole                PortRole
 	SendRSTP                    bool
@@ -138,9 +177,18 @@ type StpPort struct {
 	RstpTx  uint64
 	PvstRx  uint64
 	PvstTx  uint64
+	MstpRx  uint64
+	MstpTx  uint64
+
+	// bpdus dropped by bpdu filter
+	BpduFilterRx uint64
+	BpduFilterTx uint64
 
 	ForwardingTransitions uint64
 
+	// topology change fdb flush hold down
+	fdbFlush fdbFlushState
+
 	// 17.17
 	EdgeDelayWhileTimer PortTimer
 	FdWhileTimer        PortTimer
@@ -151,7 +199,6 @@ type StpPort struct {
 	RrWhileTimer        PortTimer
 	TcWhileTimer        PortTimer
 	BAWhileTimer        PortTimer
-	BPDUGuardTimer      PortTimer
 
 	PrxmMachineFsm *PrxmMachine
 	PtmMachineFsm  *PtmMachine
@@ -166,7 +213,7 @@ type StpPort struct {
 	begin bool
 
 	// handle used to tx packets to linux if
-	handle *pcap.Handle
+	handle pktio.Handle
 
 	// a way to sync all machines
 	wg sync.WaitGroup
@@ -206,7 +253,7 @@ func NewStpPort(c *StpPortConfig) *StpPort {
 				}
 			}
 		*/
-		enabled = asicdGetPortLinkStatus(c.IfIndex)
+		enabled = StpHwPluginGet().GetPortLinkStatus(c.IfIndex)
 	} else {
 		// in the case of tests we may not find the actual link so lets force
 		// enabled to configured value
@@ -257,6 +304,9 @@ func NewStpPort(c *StpPortConfig) *StpPort {
 		BridgeAssurance:   c.BridgeAssurance,
 		BpduGuard:         c.BpduGuard,
 		BpduGuardInterval: c.BpduGuardInterval,
+		RootGuard:         c.RootGuard,
+		LoopGuard:         c.LoopGuard,
+		BpduFilter:        BpduFilterMode(c.BpduFilter),
 		b:                 b, // reference to brige
 	}
 
@@ -293,11 +343,15 @@ func NewStpPort(c *StpPortConfig) *StpPort {
 
 	// lets setup the port receive/transmit handle
 	ifName, _ := PortConfigMap[p.IfIndex]
-	handle, err := pcap.OpenLive(ifName.Name, 65536, false, 50*time.Millisecond)
+	pktIoCfg := StpPktIoConfigGet()
+	if pktIoCfg.Filter == "" {
+		pktIoCfg.Filter = StpPktIoFilter
+	}
+	handle, err := pktio.Open(ifName.Name, pktIoCfg)
 	if err != nil {
 		// failure here may be ok as this may be SIM
 		if !strings.Contains(ifName.Name, "SIM") {
-			StpLogger("ERROR", fmt.Sprintf("Error creating pcap OpenLive handle for port %d %s %s\n", p.IfIndex, ifName.Name, err))
+			StpLogger("ERROR", fmt.Sprintf("Error creating %s handle for port %d %s %s\n", pktIoCfg.Type, p.IfIndex, ifName.Name, err))
 		}
 		return p
 	}
@@ -306,7 +360,8 @@ func NewStpPort(c *StpPortConfig) *StpPort {
 	p.handle = handle
 	StpLogger("INFO", fmt.Sprintf("NEW PORT: %#v\n", p))
 
-	if strings.Contains(ifName.Name, "eth") {
+	if strings.Contains(ifName.Name, "eth") ||
+		StpHwPluginGet().Name() == LinuxBridgeHwPluginName {
 		p.PollLinuxLinkStatus()
 	}
 
@@ -348,6 +403,8 @@ func (p *StpPort) PollLinuxLinkStatus() {
 }
 func DelStpPort(p *StpPort) {
 	p.Stop()
+	p.FdbFlushStopPending()
+	p.b.MstiPortDel(p)
 	key := PortMapKey{
 		IfIndex:    p.IfIndex,
 		BrgIfIndex: p.b.BrgIfIndex,
@@ -475,9 +532,10 @@ func (p *StpPort) BEGIN(restart bool) {
 			src: PortConfigModuleStr})
 
 		// start rx routine
-		src := gopacket.NewPacketSource(p.handle, layers.LayerTypeEthernet)
-		in := src.Packets()
-		BpduRxMain(p.IfIndex, p.b.BrgIfIndex, in)
+		if p.handle != nil {
+			in := p.handle.Packets()
+			BpduRxMain(p.IfIndex, p.b.BrgIfIndex, in)
+		}
 	}
 
 	// Ptm
@@ -613,6 +671,12 @@ func (p *StpPort) GetPortListToApplyConfigTo() (newlist []*StpPort) {
 	return
 }
 
+// IsBpduTxFiltered: bpdu filter is preventing bpdus from being sent on the port
+func (p *StpPort) IsBpduTxFiltered() bool {
+	return p.BpduFilter == StpBpduFilterInterface ||
+		(p.BpduFilter == StpBpduFilterGlobal && p.OperEdge)
+}
+
 func (p *StpPort) SetRxPortCounters(ptype BPDURxType) {
 	p.BpduRx++
 	switch ptype {
@@ -626,6 +690,8 @@ func (p *StpPort) SetRxPortCounters(ptype BPDURxType) {
 		p.TcAckRx++
 	case BPDURxTypePVST:
 		p.PvstRx++
+	case BPDURxTypeMSTP:
+		p.MstpRx++
 	}
 }
 
@@ -642,6 +708,8 @@ func (p *StpPort) SetTxPortCounters(ptype BPDURxType) {
 		p.TcAckTx++
 	case BPDURxTypePVST:
 		p.PvstTx++
+	case BPDURxTypeMSTP:
+		p.MstpTx++
 	}
 }
 
@@ -710,7 +778,9 @@ func (p *StpPort) SaveMsgRcvInfo(data interface{}) {
 }
 
 func (p *StpPort) BridgeProtocolVersionGet() uint8 {
-	// TODO get the protocol version from the bridge
+	if p.b != nil && p.b.MstpEnabled() {
+		return MstpProtocolVersion
+	}
 	// Below is the default
 	return layers.RSTPProtocolVersion
 }
@@ -2142,7 +2212,9 @@ func (p *StpPort) NotifyProposingChanged(src string, oldproposing bool, newpropo
 
 	}
 }
-func (p *StpPort) NotifyRcvdTcRcvdTcnRcvdTcAck(oldrcvdtc bool, oldrcvdtcn bool, oldrcvdtcack bool, newrcvdtc bool, newrcvdtcn bool, newrcvdtcack bool) {
+
+// sender of the bpdu is handed to the TC machine with the tc events
+func (p *StpPort) NotifyRcvdTcRcvdTcnRcvdTcAck(oldrcvdtc bool, oldrcvdtcn bool, oldrcvdtcack bool, newrcvdtc bool, newrcvdtcn bool, newrcvdtcack bool, sender BpduSender) {
 
 	// only care if there was a change
 	//if oldrcvdtc != newrcvdtc ||
@@ -2154,8 +2226,9 @@ func (p *StpPort) NotifyRcvdTcRcvdTcnRcvdTcAck(oldrcvdtc bool, oldrcvdtcn bool,
 		(p.TcMachineFsm.Machine.Curr.CurrentState() == TcStateLearning ||
 			p.TcMachineFsm.Machine.Curr.CurrentState() == TcStateActive) {
 		p.TcMachineFsm.TcEvents <- MachineEvent{
-			e:   TcEventRcvdTc,
-			src: RxModuleStr,
+			e:    TcEventRcvdTc,
+			src:  RxModuleStr,
+			data: sender,
 		}
 	}
 	if p.RcvdTcn &&
@@ -2163,8 +2236,9 @@ func (p *StpPort) NotifyRcvdTcRcvdTcnRcvdTcAck(oldrcvdtc bool, oldrcvdtcn bool,
 			p.TcMachineFsm.Machine.Curr.CurrentState() == TcStateActive) {
 
 		p.TcMachineFsm.TcEvents <- MachineEvent{
-			e:   TcEventRcvdTcn,
-			src: RxModuleStr,
+			e:    TcEventRcvdTcn,
+			src:  RxModuleStr,
+			data: sender,
 		}
 	}
 	if p.RcvdTcAck &&
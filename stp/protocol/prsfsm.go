@@ -159,6 +159,7 @@ func (prsm *PrsMachine) PrsMachineInitBridge(m fsm.Machine, data interface{}) fs
 func (prsm *PrsMachine) PrsMachineRoleSelection(m fsm.Machine, data interface{}) fsm.State {
 	prsm.clearReselectTree()
 	prsm.updtRolesTree()
+	prsm.b.MstiUpdtRolesTree()
 	prsm.setSelectedTree()
 
 	return PrsStateRoleSelection
@@ -300,7 +301,9 @@ func (prsm *PrsMachine) updtRolesTree() {
 			if prsm.debugLevel > 1 {
 				StpMachineLogger("INFO", PrsMachineModuleStr, p.IfIndex, p.BrgIfIndex, fmt.Sprintf("updtRolesTree: InfoIs %d", p.InfoIs))
 			}
-			if p.InfoIs == PortInfoStateReceived {
+			// root guard, port is not allowed to be selected as root port
+			if p.InfoIs == PortInfoStateReceived &&
+				!p.RootGuard {
 
 				/*if CompareBridgeAddr(GetBridgeAddrFromBridgeId(myBridgeId),
 					GetBridgeAddrFromBridgeId(p.PortPriority.DesignatedBridgeId)) == 0 {
@@ -430,6 +433,36 @@ func (prsm *PrsMachine) updtRolesTree() {
 				if prsm.debugLevel > 1 {
 					StpMachineLogger("INFO", PrsMachineModuleStr, p.IfIndex, p.BrgIfIndex, "updtRolesTree: Bridge Assurance port role selected ALTERNATE")
 				}
+			} else if p.RootGuard &&
+				p.PortEnabled &&
+				p.InfoIs == PortInfoStateReceived &&
+				prsm.isRootGuardSuperiorInfo(p) {
+				// root guard, superior info received keep the port discarding
+				// until the info ages out
+				if !p.RootGuardInconsistant {
+					StpMachineLogger("INFO", PrsMachineModuleStr, p.IfIndex, p.BrgIfIndex, "updtRolesTree: Root Guard inconsistant, superior info received")
+				}
+				p.RootGuardInconsistant = true
+				defer p.NotifyUpdtInfoChanged(PrsMachineModuleStr, p.UpdtInfo, false)
+				p.UpdtInfo = false
+				defer p.NotifySelectedRoleChanged(PrsMachineModuleStr, p.SelectedRole, PortRoleAlternatePort)
+				p.SelectedRole = PortRoleAlternatePort
+				if prsm.debugLevel > 1 {
+					StpMachineLogger("INFO", PrsMachineModuleStr, p.IfIndex, p.BrgIfIndex, "updtRolesTree: Root Guard port role selected ALTERNATE")
+				}
+			} else if p.LoopGuard &&
+				p.PortEnabled &&
+				p.InfoIs == PortInfoStateAged &&
+				p.LoopGuardInconsistant {
+				// loop guard, aged info on a root or alternate port
+				// keep the port discarding rather than designated
+				defer p.NotifyUpdtInfoChanged(PrsMachineModuleStr, p.UpdtInfo, false)
+				p.UpdtInfo = false
+				defer p.NotifySelectedRoleChanged(PrsMachineModuleStr, p.SelectedRole, PortRoleAlternatePort)
+				p.SelectedRole = PortRoleAlternatePort
+				if prsm.debugLevel > 1 {
+					StpMachineLogger("INFO", PrsMachineModuleStr, p.IfIndex, p.BrgIfIndex, "updtRolesTree: Loop Guard port role selected ALTERNATE")
+				}
 			} else if !p.PortEnabled || p.InfoIs == PortInfoStateDisabled {
 				// 17.21.25 (f) if port is disabled
 				defer p.NotifySelectedRoleChanged(PrsMachineModuleStr, p.SelectedRole, PortRoleDisabledPort)
@@ -538,6 +571,11 @@ func (prsm *PrsMachine) updtRolesTree() {
 					}
 				}
 			}
+			if p.RootGuardInconsistant &&
+				p.SelectedRole != PortRoleAlternatePort {
+				StpMachineLogger("INFO", PrsMachineModuleStr, p.IfIndex, p.BrgIfIndex, "updtRolesTree: Root Guard recovered")
+				p.RootGuardInconsistant = false
+			}
 			p.PortPriority.DesignatedPortId = desgPortId
 			p.PortPriority.BridgePortId = brgPortId
 
@@ -545,6 +583,19 @@ func (prsm *PrsMachine) updtRolesTree() {
 	}
 }
 
+// isRootGuardSuperiorInfo: port info would have made this port the root port
+// had root guard not excluded it from the root port selection
+func (prsm *PrsMachine) isRootGuardSuperiorInfo(p *StpPort) bool {
+	b := prsm.b
+	compare := CompareBridgeId(p.PortPriority.RootBridgeId, b.BridgePriority.RootBridgeId)
+	if compare < 0 {
+		return true
+	} else if compare == 0 {
+		return p.PortPriority.RootPathCost+p.PortPathCost < b.BridgePriority.RootPathCost
+	}
+	return false
+}
+
 // setSelectedTree: 17.21.16
 func (prsm *PrsMachine) setSelectedTree() {
 	var p *StpPort
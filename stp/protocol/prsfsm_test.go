@@ -0,0 +1,197 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// prsfsm_test.go
+package stp
+
+import (
+	"testing"
+)
+
+var prsTestBridgeAddr = [6]uint8{0x00, 0x55, 0x55, 0x55, 0x55, 0x55}
+var prsTestSuperiorAddr = [6]uint8{0x00, 0x11, 0x11, 0x11, 0x11, 0x11}
+var prsTestInferiorAddr = [6]uint8{0x00, 0x22, 0x22, 0x22, 0x22, 0x22}
+
+// UsedForTestOnlyPrsRootGuardSetup creates a root bridge with two
+// designated ports, port 1 has root guard enabled.  The port machines are
+// only instanciated so that the role selection notifications are queued
+func UsedForTestOnlyPrsRootGuardSetup() (*Bridge, []*StpPort) {
+	bridgeId := CreateBridgeId(prsTestBridgeAddr, 0x8000, 0)
+	b := &Bridge{
+		BrgIfIndex:       88,
+		BridgeIdentifier: bridgeId,
+		BridgePriority: PriorityVector{
+			RootBridgeId:       bridgeId,
+			DesignatedBridgeId: bridgeId,
+		},
+	}
+	NewStpPrsMachine(b)
+
+	ports := make([]*StpPort, 0)
+	for i := int32(1); i <= 2; i++ {
+		p := &StpPort{
+			IfIndex:      i,
+			BrgIfIndex:   b.BrgIfIndex,
+			PortEnabled:  true,
+			PortId:       uint16(i),
+			Priority:     0x80,
+			PortPathCost: 20000,
+			InfoIs:       PortInfoStateMine,
+			Role:         PortRoleDesignatedPort,
+			SelectedRole: PortRoleDesignatedPort,
+			PortPriority: PriorityVector{
+				RootBridgeId:       bridgeId,
+				DesignatedBridgeId: bridgeId,
+				DesignatedPortId:   uint16(0x80<<8 | i),
+			},
+			b: b,
+		}
+		PimMachineFSMBuild(p)
+		PrxmMachineFSMBuild(p)
+		PtxmMachineFSMBuild(p)
+		BdmMachineFSMBuild(p)
+		PtmMachineFSMBuild(p)
+		TcMachineFSMBuild(p)
+		PstMachineFSMBuild(p)
+		PpmmMachineFSMBuild(p)
+		PrtMachineFSMBuild(p)
+		PortMapTable[PortMapKey{p.IfIndex, p.BrgIfIndex}] = p
+		b.StpPorts = append(b.StpPorts, p.IfIndex)
+		ports = append(ports, p)
+	}
+	ports[0].RootGuard = true
+	return b, ports
+}
+
+func UsedForTestOnlyPrsRootGuardTeardown(ports []*StpPort) {
+	for _, p := range ports {
+		delete(PortMapTable, PortMapKey{p.IfIndex, p.BrgIfIndex})
+	}
+}
+
+// UsedForTestOnlyPrsRcvdInfo the port received the info of a designated
+// bridge which claims root bridge root
+func UsedForTestOnlyPrsRcvdInfo(p *StpPort, root BridgeId) {
+	p.InfoIs = PortInfoStateReceived
+	p.PortPriority = PriorityVector{
+		RootBridgeId:       root,
+		DesignatedBridgeId: root,
+		DesignatedPortId:   0x8001,
+	}
+}
+
+func TestPrsRootGuardSuperiorInfo(t *testing.T) {
+	b, ports := UsedForTestOnlyPrsRootGuardSetup()
+	defer UsedForTestOnlyPrsRootGuardTeardown(ports)
+	p := ports[0]
+
+	// inferior info is not affected by root guard
+	UsedForTestOnlyPrsRcvdInfo(p, CreateBridgeId(prsTestInferiorAddr, 0xf000, 0))
+	b.PrsMachineFsm.updtRolesTree()
+	if p.RootGuardInconsistant ||
+		p.SelectedRole != PortRoleDesignatedPort {
+		t.Error("Failed root guard port with inferior info should stay designated", p.SelectedRole)
+	}
+
+	// superior info would make the port the root port, it is kept
+	// discarding instead and the bridge stays root
+	superiorRoot := CreateBridgeId(prsTestSuperiorAddr, 0x1000, 0)
+	UsedForTestOnlyPrsRcvdInfo(p, superiorRoot)
+	b.PrsMachineFsm.updtRolesTree()
+	if !p.RootGuardInconsistant {
+		t.Error("Failed root guard inconsistant not set on superior info")
+	}
+	if p.SelectedRole != PortRoleAlternatePort ||
+		p.UpdtInfo {
+		t.Error("Failed root guard port not selected alternate", p.SelectedRole, p.UpdtInfo)
+	}
+	if b.RootPortId != 0 ||
+		b.BridgePriority.RootBridgeId != b.BridgeIdentifier {
+		t.Error("Failed bridge root changed by root guard port", b.RootPortId, b.BridgePriority.RootBridgeId)
+	}
+	if ports[1].SelectedRole != PortRoleDesignatedPort {
+		t.Error("Failed other port not designated", ports[1].SelectedRole)
+	}
+	select {
+	case event := <-p.PrtMachineFsm.PrtEvents:
+		if event.e != PrtEventSelectedRoleEqualAlternateAndRoleNotEqualSelectedRoleAndSelectedAndNotUpdtInfo {
+			t.Error("Failed alternate role not notified to the port role transition machine", event.e)
+		}
+	default:
+		t.Error("Failed no event sent to the port role transition machine")
+	}
+
+	// superior info is refreshed while the port is inconsistant
+	b.PrsMachineFsm.updtRolesTree()
+	if !p.RootGuardInconsistant ||
+		p.SelectedRole != PortRoleAlternatePort {
+		t.Error("Failed root guard inconsistant cleared while superior info received", p.SelectedRole)
+	}
+
+	// without root guard the same info selects the port as root port
+	p.RootGuard = false
+	b.PrsMachineFsm.updtRolesTree()
+	if p.SelectedRole != PortRoleRootPort ||
+		b.BridgePriority.RootBridgeId != superiorRoot {
+		t.Error("Failed superior info not selected as root without root guard", p.SelectedRole, b.BridgePriority.RootBridgeId)
+	}
+	if p.RootGuardInconsistant {
+		t.Error("Failed root guard inconsistant not cleared when root guard disabled")
+	}
+}
+
+func TestPrsRootGuardRecoverOnAgeOut(t *testing.T) {
+	b, ports := UsedForTestOnlyPrsRootGuardSetup()
+	defer UsedForTestOnlyPrsRootGuardTeardown(ports)
+	p := ports[0]
+
+	UsedForTestOnlyPrsRcvdInfo(p, CreateBridgeId(prsTestSuperiorAddr, 0x1000, 0))
+	b.PrsMachineFsm.updtRolesTree()
+	if !p.RootGuardInconsistant {
+		t.Fatal("Failed root guard inconsistant not set on superior info")
+	}
+
+	// the superior bpdus stop, the Port Information machine ages out the
+	// info once rcvdInfoWhile expires
+	p.InfoIs = PortInfoStateAged
+	b.PrsMachineFsm.updtRolesTree()
+	if p.RootGuardInconsistant {
+		t.Error("Failed root guard inconsistant not cleared on age out")
+	}
+	if p.SelectedRole != PortRoleDesignatedPort ||
+		!p.UpdtInfo {
+		t.Error("Failed root guard port not designated after age out", p.SelectedRole, p.UpdtInfo)
+	}
+	if b.RootPortId != 0 ||
+		b.BridgePriority.RootBridgeId != b.BridgeIdentifier {
+		t.Error("Failed bridge no longer root after age out", b.RootPortId, b.BridgePriority.RootBridgeId)
+	}
+
+	// a new superior bpdu puts the port back into root inconsistant
+	UsedForTestOnlyPrsRcvdInfo(p, CreateBridgeId(prsTestSuperiorAddr, 0x1000, 0))
+	b.PrsMachineFsm.updtRolesTree()
+	if !p.RootGuardInconsistant ||
+		p.SelectedRole != PortRoleAlternatePort {
+		t.Error("Failed root guard inconsistant not set again on superior info", p.SelectedRole)
+	}
+}
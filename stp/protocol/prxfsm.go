@@ -57,8 +57,10 @@ const (
 )
 
 type RxBpduPdu struct {
-	pdu          interface{}
-	ptype        BPDURxType
+	pdu   interface{}
+	ptype BPDURxType
+	// passed on to the TC machine with the tc events
+	sender       BpduSender
 	src          string
 	responseChan chan string
 }
@@ -179,8 +181,11 @@ func (prxm *PrxmMachine) PrxmMachineReceive(m fsm.Machine, data interface{}) fsm
 	defer p.NotifyRcvdMsgChanged(PrxmMachineModuleStr, p.RcvdMsg, rcvdMsg, data)
 	p.RcvdMsg = rcvdMsg
 
-	/* do not transition to NOT OperEdge if AdminEdge is set */
-	if !p.AdminEdge && p.AutoEdgePort {
+	/* do not transition to NOT OperEdge if AdminEdge is set, unless
+	   global bpdu filter is set in which case the port reverts to a
+	   normal stp port */
+	if (!p.AdminEdge && p.AutoEdgePort) ||
+		(p.BpduFilter == StpBpduFilterGlobal && p.OperEdge) {
 		defer p.NotifyOperEdgeChanged(PrxmMachineModuleStr, p.OperEdge, false)
 		p.OperEdge = false
 	}
@@ -278,12 +283,7 @@ func (p *StpPort) PrxmMachineMain() {
 
 				if p.BpduGuard &&
 					p.AdminEdge {
-					if p.BPDUGuardTimer.count != 0 {
-						p.BPDUGuardTimer.count = p.BpduGuardInterval
-						asicdBPDUGuardDetected(p.IfIndex, true)
-					} else {
-						p.BPDUGuardTimer.count = p.BpduGuardInterval
-					}
+					p.BpduGuardErrDisable()
 				} else {
 
 					//fmt.Println("Event PKT Rx", p.IfIndex, p.BrgIfIndex, rx.src, PrxmStateStrMap[m.Machine.Curr.CurrentState()], rx.ptype, p.RcvdMsg, p.PortEnabled)
@@ -386,7 +386,8 @@ func (prxm *PrxmMachine) UpdtBPDUVersion(data interface{}) bool {
 		// the BPDUType, but for completness going to add the check anyways
 		rstp := bpduLayer.(*layers.RSTP)
 		flags = uint8(rstp.Flags)
-		if rstp.ProtocolVersionId == layers.RSTPProtocolVersion &&
+		// MST BPDU carries the CIST info in the RSTP portion of the BPDU
+		if rstp.ProtocolVersionId >= layers.RSTPProtocolVersion &&
 			rstp.BPDUType == layers.BPDUTypeRSTP {
 			// Inform the Port Protocol Migration STate machine
 			// that we have received a RSTP packet when we were previously
@@ -410,7 +411,7 @@ func (prxm *PrxmMachine) UpdtBPDUVersion(data interface{}) bool {
 
 		//StpMachineLogger("INFO", PrtMachineModuleStr, p.IfIndex, p.BrgIfIndex, fmt.Sprintf("Received RSTP packet flags rcvdRSTP[%t] sendRSTP[%t]", rstp.Flags, p.RcvdRSTP, p.SendRSTP))
 
-		defer p.NotifyRcvdTcRcvdTcnRcvdTcAck(p.RcvdTc, p.RcvdTcn, p.RcvdTcAck, StpGetBpduTopoChange(flags), false, false)
+		defer p.NotifyRcvdTcRcvdTcnRcvdTcAck(p.RcvdTc, p.RcvdTcn, p.RcvdTcAck, StpGetBpduTopoChange(flags), false, false, bpdumsg.sender)
 		p.RcvdTc = StpGetBpduTopoChange(flags)
 		p.RcvdTcn = false
 		p.RcvdTcAck = StpGetBpduTopoChangeAck(flags)
@@ -454,7 +455,7 @@ func (prxm *PrxmMachine) UpdtBPDUVersion(data interface{}) bool {
 
 		//StpMachineLogger("INFO", PrxmMachineModuleStr, p.IfIndex, p.BrgIfIndex, fmt.Sprintf("Received PVST packet flags", pvst.Flags))
 
-		defer p.NotifyRcvdTcRcvdTcnRcvdTcAck(p.RcvdTc, p.RcvdTcn, p.RcvdTcAck, StpGetBpduTopoChange(flags), false, StpGetBpduTopoChangeAck(flags))
+		defer p.NotifyRcvdTcRcvdTcnRcvdTcAck(p.RcvdTc, p.RcvdTcn, p.RcvdTcAck, StpGetBpduTopoChange(flags), false, StpGetBpduTopoChangeAck(flags), bpdumsg.sender)
 		p.RcvdTc = StpGetBpduTopoChange(flags)
 		p.RcvdTcn = false
 		p.RcvdTcAck = StpGetBpduTopoChangeAck(flags)
@@ -497,7 +498,7 @@ func (prxm *PrxmMachine) UpdtBPDUVersion(data interface{}) bool {
 		}
 
 		StpMachineLogger("INFO", PrtMachineModuleStr, p.IfIndex, p.BrgIfIndex, fmt.Sprintf("Received STP packet %#v", stp))
-		defer p.NotifyRcvdTcRcvdTcnRcvdTcAck(p.RcvdTc, p.RcvdTcn, p.RcvdTcAck, StpGetBpduTopoChange(flags), false, StpGetBpduTopoChangeAck(flags))
+		defer p.NotifyRcvdTcRcvdTcnRcvdTcAck(p.RcvdTc, p.RcvdTcn, p.RcvdTcAck, StpGetBpduTopoChange(flags), false, StpGetBpduTopoChangeAck(flags), bpdumsg.sender)
 		p.RcvdTc = StpGetBpduTopoChange(flags)
 		p.RcvdTcn = false
 		p.RcvdTcAck = StpGetBpduTopoChangeAck(flags)
@@ -533,7 +534,7 @@ func (prxm *PrxmMachine) UpdtBPDUVersion(data interface{}) bool {
 			}
 			validPdu = true
 			StpMachineLogger("INFO", PrtMachineModuleStr, p.IfIndex, p.BrgIfIndex, "Received TCN packet")
-			defer p.NotifyRcvdTcRcvdTcnRcvdTcAck(p.RcvdTc, p.RcvdTcn, p.RcvdTcAck, false, true, false)
+			defer p.NotifyRcvdTcRcvdTcnRcvdTcAck(p.RcvdTc, p.RcvdTcn, p.RcvdTcAck, false, true, false, bpdumsg.sender)
 			p.RcvdTc = false
 			p.RcvdTcn = true
 			p.RcvdTcAck = false
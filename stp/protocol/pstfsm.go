@@ -156,6 +156,7 @@ func (pstm *PstMachine) PstMachineDiscarding(m fsm.Machine, data interface{}) fs
 	pstm.disableForwarding()
 	defer pstm.NotifyForwardingChanged(p.Forwarding, false)
 	p.Forwarding = false
+	p.MstiPortStateUpdate()
 	return PstStateDiscarding
 }
 
@@ -165,6 +166,7 @@ func (pstm *PstMachine) PstMachineLearning(m fsm.Machine, data interface{}) fsm.
 	pstm.enableLearning()
 	defer pstm.NotifyLearningChanged(p.Learning, true)
 	p.Learning = true
+	p.MstiPortStateUpdate()
 	return PstStateLearning
 }
 
@@ -174,6 +176,7 @@ func (pstm *PstMachine) PstMachineForwarding(m fsm.Machine, data interface{}) fs
 	pstm.enableForwarding()
 	defer pstm.NotifyForwardingChanged(p.Forwarding, true)
 	p.Forwarding = true
+	p.MstiPortStateUpdate()
 	return PstStateForwarding
 }
 
@@ -426,24 +429,24 @@ func (pstm *PstMachine) NotifyForwardingChanged(oldforwarding bool, newforwardin
 func (pstm *PstMachine) disableLearning() {
 	p := pstm.p
 	StpMachineLogger("INFO", PstMachineModuleStr, p.IfIndex, p.BrgIfIndex, "Calling Asic to do disable learning")
-	asicdSetStgPortState(p.b.StgId, p.IfIndex, pluginCommon.STP_PORT_STATE_BLOCKING)
+	StpHwPluginGet().SetStgPortState(p.b.StgId, p.IfIndex, pluginCommon.STP_PORT_STATE_BLOCKING)
 }
 
 func (pstm *PstMachine) disableForwarding() {
 	p := pstm.p
 	StpMachineLogger("INFO", PstMachineModuleStr, p.IfIndex, p.BrgIfIndex, "Calling Asic to do disable forwarding")
-	asicdSetStgPortState(p.b.StgId, p.IfIndex, pluginCommon.STP_PORT_STATE_BLOCKING)
+	StpHwPluginGet().SetStgPortState(p.b.StgId, p.IfIndex, pluginCommon.STP_PORT_STATE_BLOCKING)
 }
 
 func (pstm *PstMachine) enableLearning() {
 	p := pstm.p
 	StpMachineLogger("INFO", PstMachineModuleStr, p.IfIndex, p.BrgIfIndex, "Calling Asic to do enable learning")
-	asicdSetStgPortState(p.b.StgId, p.IfIndex, pluginCommon.STP_PORT_STATE_LEARNING)
+	StpHwPluginGet().SetStgPortState(p.b.StgId, p.IfIndex, pluginCommon.STP_PORT_STATE_LEARNING)
 }
 
 func (pstm *PstMachine) enableForwarding() {
 	p := pstm.p
 	StpMachineLogger("INFO", PstMachineModuleStr, p.IfIndex, p.BrgIfIndex, "Calling Asic to do enable forwarding")
-	asicdSetStgPortState(p.b.StgId, p.IfIndex, pluginCommon.STP_PORT_STATE_FORWARDING)
+	StpHwPluginGet().SetStgPortState(p.b.StgId, p.IfIndex, pluginCommon.STP_PORT_STATE_FORWARDING)
 	p.ForwardingTransitions += 1
 }
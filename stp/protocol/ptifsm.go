@@ -26,7 +26,7 @@ package stp
 
 import (
 	//"fmt"
-	"time"
+	"l2/clock"
 	"utils/fsm"
 )
 
@@ -65,7 +65,7 @@ type PtmMachine struct {
 	log chan string
 
 	// timer type
-	TickTimer *time.Timer
+	TickTimer *clock.Timer
 	Tick      bool
 
 	// Reference to StpPort
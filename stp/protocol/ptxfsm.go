@@ -180,8 +180,12 @@ func (ptxm *PtxmMachine) PtxmMachineTransmitRSTP(m fsm.Machine, data interface{}
 	p := ptxm.p
 
 	p.NewInfo = false
-	p.TxRSTP()
-	p.TxCount++
+	if p.IsBpduTxFiltered() {
+		p.BpduFilterTx++
+	} else {
+		p.TxRSTP()
+		p.TxCount++
+	}
 	p.TcAck = false
 
 	return PtxmStateTransmitRSTP
@@ -191,8 +195,12 @@ func (ptxm *PtxmMachine) PtxmMachineTransmitTCN(m fsm.Machine, data interface{})
 	p := ptxm.p
 
 	p.NewInfo = false
-	p.TxTCN()
-	p.TxCount++
+	if p.IsBpduTxFiltered() {
+		p.BpduFilterTx++
+	} else {
+		p.TxTCN()
+		p.TxCount++
+	}
 
 	return PtxmStateTransmitTCN
 }
@@ -201,8 +209,12 @@ func (ptxm *PtxmMachine) PtxmMachineTransmitConfig(m fsm.Machine, data interface
 	p := ptxm.p
 
 	p.NewInfo = false
-	p.TxConfig()
-	p.TxCount++
+	if p.IsBpduTxFiltered() {
+		p.BpduFilterTx++
+	} else {
+		p.TxConfig()
+		p.TxCount++
+	}
 	p.TcAck = false
 
 	return PtxmStateTransmitConfig
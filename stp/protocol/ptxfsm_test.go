@@ -439,3 +439,26 @@ func TestTxHelloWhenEqualZeroTransmitTCN(t *testing.T) {
 	close(testWait)
 	close(testChan)
 }
+
+func TestTxBpduFilterMode(t *testing.T) {
+	p := &StpPort{}
+
+	for _, test := range []struct {
+		filter   BpduFilterMode
+		operedge bool
+		filtered bool
+	}{
+		{StpBpduFilterDisabled, false, false},
+		{StpBpduFilterDisabled, true, false},
+		{StpBpduFilterGlobal, false, false},
+		{StpBpduFilterGlobal, true, true},
+		{StpBpduFilterInterface, false, true},
+		{StpBpduFilterInterface, true, true},
+	} {
+		p.BpduFilter = test.filter
+		p.OperEdge = test.operedge
+		if p.IsBpduTxFiltered() != test.filtered {
+			t.Error(fmt.Sprintf("Failed bpdu filter %d operedge %t expected filtered %t", test.filter, test.operedge, test.filtered))
+		}
+	}
+}
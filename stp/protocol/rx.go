@@ -224,20 +224,50 @@ func ProcessBpduFrame(p *StpPort, ptype BPDURxType, packet gopacket.Packet) {
 
 	//fmt.Printf("ProcessBpduFrame on port/bridge\n", pId, bId)
 	//fmt.Printf("ProcessBpduFrame %T\n", bpduLayer)
+	// interface bpdu filter, the bpdu is dropped without being processed
+	// so bpdu guard and the port counters never see it
+	if p.BpduFilter == StpBpduFilterInterface {
+		p.BpduFilterRx++
+		return
+	}
+
 	// lets find the port via the info in the packet
 	p.RcvdBPDU = true
+	sender := StpGetBpduSender(packet)
+
+	// 13.28.x MST BPDU carries the region config and msti messages after
+	// the CIST information, the CIST portion is handled as an RSTP BPDU
+	if p.b.MstpEnabled() {
+		var mstpBpdu *MstpBpdu
+		if rstp, ok := bpduLayer.(*layers.RSTP); ok &&
+			rstp.ProtocolVersionId >= MstpProtocolVersion {
+			if llcLayer := packet.Layer(layers.LayerTypeLLC); llcLayer != nil {
+				var mstp MstpBpdu
+				if err := MstpBpduDecode(llcLayer.LayerPayload(), &mstp); err == nil {
+					ptype = BPDURxTypeMSTP
+					mstpBpdu = &mstp
+				} else {
+					StpMachineLogger("ERROR", RxModuleStr, p.IfIndex, p.BrgIfIndex, err.Error())
+				}
+			}
+		}
+		// BPDU's other than MST BPDU's are from outside the region
+		p.MstpRcvdBpdu(mstpBpdu)
+	}
 	//fmt.Println("Sending rx message to Port Rcvd State Machine", p.IfIndex, p.BrgIfIndex)
 	if p.PrxmMachineFsm != nil {
 		if pvstLayer == nil {
 			p.PrxmMachineFsm.PrxmRxBpduPkt <- RxBpduPdu{
-				pdu:   bpduLayer, // this is a pointer
-				ptype: ptype,
-				src:   RxModuleStr}
+				pdu:    bpduLayer, // this is a pointer
+				ptype:  ptype,
+				sender: sender,
+				src:    RxModuleStr}
 		} else {
 			p.PrxmMachineFsm.PrxmRxBpduPkt <- RxBpduPdu{
-				pdu:   pvstLayer, // this is a pointer
-				ptype: ptype,
-				src:   RxModuleStr}
+				pdu:    pvstLayer, // this is a pointer
+				ptype:  ptype,
+				sender: sender,
+				src:    RxModuleStr}
 
 		}
 
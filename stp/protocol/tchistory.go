@@ -0,0 +1,158 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// tchistory.go
+package stp
+
+import (
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"sync"
+	"time"
+)
+
+// number of topology change events kept per bridge
+const StpTcHistorySize = 32
+
+type TcEventType int
+
+const (
+	// tc detected locally, a port transitioned to forwarding
+	TcEventTypeDetected TcEventType = iota + 1
+	// tc flag received in a config/rstp bpdu
+	TcEventTypeRcvdTc
+	// tcn bpdu received
+	TcEventTypeRcvdTcn
+)
+
+var TcEventTypeStrMap = map[TcEventType]string{
+	TcEventTypeDetected: "Detected",
+	TcEventTypeRcvdTc:   "RcvdTc",
+	TcEventTypeRcvdTcn:  "RcvdTcn",
+}
+
+// TcEvent records a single topology change, the sender is only valid for
+// received events and the sender bridge id is not carried in a tcn
+type TcEvent struct {
+	Seq            uint64
+	Type           TcEventType
+	Time           time.Time
+	IfIndex        int32
+	SenderBridgeId BridgeId
+	SenderMac      [6]uint8
+}
+
+// TcStats is the per bridge topology change summary
+type TcStats struct {
+	Count            uint64
+	LastTime         time.Time
+	LastRcvdIfIndex  int32
+	LastRcvdBridgeId BridgeId
+	LastRcvdMac      [6]uint8
+}
+
+type tcTelemetry struct {
+	mutex   sync.Mutex
+	stats   TcStats
+	history [StpTcHistorySize]TcEvent
+}
+
+// BpduSender is the source of the last bpdu received on a port
+type BpduSender struct {
+	BridgeId BridgeId
+	Mac      [6]uint8
+}
+
+// StpGetBpduSender returns who sent the bpdu, it is handed along with the
+// bpdu to the PRX machine and from there with the tc events to the TC
+// machine so that a topology change can be traced back to its source
+func StpGetBpduSender(packet gopacket.Packet) BpduSender {
+	sender := BpduSender{}
+	if ethernetLayer := packet.Layer(layers.LayerTypeEthernet); ethernetLayer != nil {
+		copy(sender.Mac[:], ethernetLayer.(*layers.Ethernet).SrcMAC)
+	}
+	if pvstLayer := packet.Layer(layers.LayerTypePVST); pvstLayer != nil {
+		sender.BridgeId = pvstLayer.(*layers.PVST).BridgeId
+	} else if bpduLayer := packet.Layer(layers.LayerTypeBPDU); bpduLayer != nil {
+		switch bpdu := bpduLayer.(type) {
+		case *layers.STP:
+			sender.BridgeId = bpdu.BridgeId
+		case *layers.RSTP:
+			sender.BridgeId = bpdu.BridgeId
+		}
+	}
+	return sender
+}
+
+// TcRecord will add the topology change event to the bridge history, the
+// sender is ignored for locally detected events
+func (p *StpPort) TcRecord(evtType TcEventType, sender BpduSender) {
+	b := p.b
+	if b == nil {
+		return
+	}
+	evt := TcEvent{
+		Type:    evtType,
+		Time:    StpClockGet().Now(),
+		IfIndex: p.IfIndex,
+	}
+	if evtType != TcEventTypeDetected {
+		evt.SenderBridgeId = sender.BridgeId
+		evt.SenderMac = sender.Mac
+	}
+
+	b.tc.mutex.Lock()
+	b.tc.stats.Count++
+	evt.Seq = b.tc.stats.Count
+	b.tc.stats.LastTime = evt.Time
+	if evtType != TcEventTypeDetected {
+		b.tc.stats.LastRcvdIfIndex = evt.IfIndex
+		b.tc.stats.LastRcvdBridgeId = evt.SenderBridgeId
+		b.tc.stats.LastRcvdMac = evt.SenderMac
+	}
+	b.tc.history[(evt.Seq-1)%StpTcHistorySize] = evt
+	b.tc.mutex.Unlock()
+}
+
+// TcStatsGet returns the topology change summary of the bridge
+func (b *Bridge) TcStatsGet() TcStats {
+	b.tc.mutex.Lock()
+	defer b.tc.mutex.Unlock()
+	return b.tc.stats
+}
+
+// TcHistoryGet returns the most recent topology change events, oldest first
+func (b *Bridge) TcHistoryGet() []TcEvent {
+	b.tc.mutex.Lock()
+	defer b.tc.mutex.Unlock()
+	count := b.tc.stats.Count
+	first := uint64(1)
+	if count > StpTcHistorySize {
+		first = count - StpTcHistorySize + 1
+	}
+	history := make([]TcEvent, 0, count-first+1)
+	for seq := first; seq <= count; seq++ {
+		history = append(history, b.tc.history[(seq-1)%StpTcHistorySize])
+	}
+	return history
+}
@@ -0,0 +1,76 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// tchistory_test.go
+package stp
+
+import (
+	"testing"
+)
+
+func TestTcHistoryWrap(t *testing.T) {
+	b := &Bridge{}
+	p := &StpPort{IfIndex: 1, b: b}
+
+	// local events carry no sender
+	p.TcRecord(TcEventTypeDetected, BpduSender{Mac: [6]uint8{0x00, 0x11, 0x22, 0x33, 0x44, 0x57}})
+	if history := b.TcHistoryGet(); len(history) != 1 ||
+		history[0].Seq != 1 ||
+		history[0].Type != TcEventTypeDetected ||
+		history[0].SenderMac != [6]uint8{} {
+		t.Error("ERROR TC history invalid after first event", history)
+	}
+
+	// received tc records the sender
+	sender := BpduSender{
+		BridgeId: BridgeId{0x80, 0x00, 0x00, 0x11, 0x22, 0x33, 0x44, 0x55},
+		Mac:      [6]uint8{0x00, 0x11, 0x22, 0x33, 0x44, 0x56},
+	}
+	p.IfIndex = 2
+	p.TcRecord(TcEventTypeRcvdTc, sender)
+	stats := b.TcStatsGet()
+	if stats.Count != 2 ||
+		stats.LastRcvdIfIndex != 2 ||
+		stats.LastRcvdBridgeId != sender.BridgeId ||
+		stats.LastRcvdMac != sender.Mac {
+		t.Error("ERROR TC stats did not record sender", stats)
+	}
+
+	// only the most recent events are kept
+	for i := 0; i < StpTcHistorySize+5; i++ {
+		p.TcRecord(TcEventTypeRcvdTcn, sender)
+	}
+	count := uint64(StpTcHistorySize + 7)
+	if b.TcStatsGet().Count != count {
+		t.Error("ERROR TC count invalid", b.TcStatsGet().Count)
+	}
+	history := b.TcHistoryGet()
+	if len(history) != StpTcHistorySize {
+		t.Error("ERROR TC history not bounded", len(history))
+	}
+	for i, evt := range history {
+		if evt.Seq != count-StpTcHistorySize+1+uint64(i) {
+			t.Error("ERROR TC history out of order", i, evt.Seq)
+		}
+	}
+}
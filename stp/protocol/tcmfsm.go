@@ -96,6 +96,10 @@ type TcMachine struct {
 	TcKillSignalEvent chan MachineEvent
 	// enable logging
 	TcLogEnableEvent chan bool
+
+	// sender of the last tc/tcn bpdu, received with the tc events from
+	// the PRX machine and only used by the TC machine go routine
+	rcvdBpduSender BpduSender
 }
 
 func (m *TcMachine) GetCurrStateStr() string {
@@ -183,6 +187,7 @@ func (tcm *TcMachine) TcMachineLearning(m fsm.Machine, data interface{}) fsm.Sta
 func (tcm *TcMachine) TcMachineDetected(m fsm.Machine, data interface{}) fsm.State {
 	p := tcm.p
 	newinfonotificationsent := tcm.newTcWhile()
+	p.TcRecord(TcEventTypeDetected, BpduSender{})
 	tcm.setTcPropTree()
 	if !newinfonotificationsent {
 		defer tcm.NotifyNewInfoChanged(p.NewInfo, true)
@@ -199,7 +204,9 @@ func (tcm *TcMachine) TcMachineActive(m fsm.Machine, data interface{}) fsm.State
 
 // TcMachineNotifyTcn
 func (tcm *TcMachine) TcMachineNotifiedTcn(m fsm.Machine, data interface{}) fsm.State {
+	p := tcm.p
 
+	p.TcRecord(TcEventTypeRcvdTcn, tcm.rcvdBpduSender)
 	tcm.newTcWhile()
 
 	return TcStateNotifiedTcn
@@ -209,6 +216,7 @@ func (tcm *TcMachine) TcMachineNotifiedTcn(m fsm.Machine, data interface{}) fsm.
 func (tcm *TcMachine) TcMachineNotifiedTc(m fsm.Machine, data interface{}) fsm.State {
 	p := tcm.p
 
+	p.TcRecord(TcEventTypeRcvdTc, tcm.rcvdBpduSender)
 	p.RcvdTcn = false
 	p.RcvdTc = false
 	if p.Role == PortRoleDesignatedPort {
@@ -353,6 +361,10 @@ func (p *StpPort) TcMachineMain() {
 					break
 				}
 
+				if sender, ok := event.data.(BpduSender); ok {
+					m.rcvdBpduSender = sender
+				}
+
 				//fmt.Println("Event Rx", event.src, event.e)
 				rv := m.Machine.ProcessEvent(event.src, event.e, nil)
 				if rv != nil {
@@ -547,11 +559,9 @@ func (tcm *TcMachine) FlushFdb() {
 	// or adjust timer to flush once flushing
 	// is complete lets clear FdbFlush and
 	// send event to TCM
-	// TODO work out mechanism with asicd
-	//var delay time.Duration = time.Second * 1
-	asicdFlushFdb(p.b.StgId)
-	//time.Sleep(delay)
-	StpMachineLogger("INFO", PtxmMachineModuleStr, p.IfIndex, p.BrgIfIndex, "FDB Flush")
+	// flush is either done now or deferred until the hold down
+	// expires, either way the entries will be removed
+	p.FdbFlushRequest()
 	p.FdbFlush = false
 	if p.Learn &&
 		p.TcMachineFsm.Machine.Curr.CurrentState() == TcStateInactive {
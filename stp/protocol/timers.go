@@ -26,9 +26,26 @@ package stp
 
 import (
 	//"fmt"
+	"l2/clock"
 	"time"
 )
 
+// clock used by the port timers tick, tests use a clock.ManualClock
+// in order to step the port timers
+var gStpClock clock.Clock = clock.NewRealClock()
+
+// StpClockSet must be called before any ports are created as running
+// timers keep the clock they were created with
+func StpClockSet(c clock.Clock) {
+	gStpClock = c
+	// errdisable recovery timers use the stp clock as well
+	StpErrDisableGet().SetClock(c)
+}
+
+func StpClockGet() clock.Clock {
+	return gStpClock
+}
+
 type TimerType int
 
 const (
@@ -62,7 +79,7 @@ func TimerTypeStrStateMapInit() {
 func (m *PtmMachine) TickTimerStart() {
 
 	if m.TickTimer == nil {
-		m.TickTimer = time.NewTimer(time.Second * 1)
+		m.TickTimer = StpClockGet().NewTimer(time.Second * 1)
 	} else {
 		m.TickTimer.Reset(time.Second * 1)
 	}
@@ -232,6 +249,9 @@ func (p *StpPort) DecrementTimerCounters() {
 			defer p.NotifyTcWhileTimerExpired()
 		}
 	}
+	// msti prt owner
+	p.MstiDecrementTimerCounters()
+
 	// Bridge Assurance
 	if p.BridgeAssurance &&
 		!p.OperEdge &&
@@ -245,17 +265,6 @@ func (p *StpPort) DecrementTimerCounters() {
 			p.SelectedRole = PortRoleDisabledPort
 		}
 	}
-
-	if p.BpduGuard &&
-		p.OperEdge &&
-		p.BPDUGuardTimer.count > 0 {
-		p.BPDUGuardTimer.count--
-		// condition has not been detected lets clear the
-		// Detection
-		if p.BPDUGuardTimer.count == 0 {
-			asicdBPDUGuardDetected(p.IfIndex, false)
-		}
-	}
 }
 
 func (p *StpPort) NotifyEdgeDelayWhileTimerExpired() {
@@ -182,6 +182,11 @@ func (p *StpPort) TxPVST() {
 
 func (p *StpPort) TxRSTP() {
 
+	if p.b.MstpEnabled() {
+		p.TxMSTP()
+		return
+	}
+
 	if p.b.Vlan != DEFAULT_STP_BRIDGE_VLAN {
 		p.TxPVST()
 		return
@@ -0,0 +1,91 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// stperrdisablehandler.go
+package rpc
+
+import (
+	"errors"
+	"fmt"
+	"l2/errdisable"
+	stp "l2/stp/protocol"
+	"stpd"
+	"time"
+)
+
+func ConvertErrDisableEntryToThriftState(entry errdisable.Entry, state *stpd.StpPortErrDisableState) {
+	state.IfIndex = entry.IfIndex
+	state.ErrDisabled = ConvertBoolToInt32(entry.Active)
+	state.Cause = entry.Cause.String()
+	state.Count = int32(entry.Count)
+	state.DisableTime = entry.DisableTime.String()
+	state.RecoveryInterval = int32(entry.Interval / time.Second)
+	if !entry.RecoveryTime.IsZero() {
+		state.RecoveryTime = entry.RecoveryTime.String()
+	}
+}
+
+// GetStpPortErrDisableState will return the errdisable history of a port
+func (s *STPDServiceHandler) GetStpPortErrDisableState(ifIndex int32) (*stpd.StpPortErrDisableState, error) {
+	state := &stpd.StpPortErrDisableState{}
+
+	entry, ok := stp.StpErrDisableGet().Get(ifIndex)
+	if !ok {
+		return state, errors.New(fmt.Sprintf("STP: Error port ifindex %d has never been errdisabled", ifIndex))
+	}
+	ConvertErrDisableEntryToThriftState(entry, state)
+	return state, nil
+}
+
+// GetBulkStpPortErrDisableState will return the errdisable history of all
+// ports which have been errdisabled
+func (s *STPDServiceHandler) GetBulkStpPortErrDisableState(fromIndex stpd.Int, count stpd.Int) (obj *stpd.StpPortErrDisableStateGetInfo, err error) {
+
+	var returnStates []*stpd.StpPortErrDisableState
+	var returnStateGetInfo stpd.StpPortErrDisableStateGetInfo
+	validCount := stpd.Int(0)
+	toIndex := fromIndex
+	obj = &returnStateGetInfo
+
+	entryList := stp.StpErrDisableGet().List()
+	entryListLen := stpd.Int(len(entryList))
+	for currIndex := fromIndex; validCount != count && currIndex < entryListLen; currIndex++ {
+		state := &stpd.StpPortErrDisableState{}
+		ConvertErrDisableEntryToThriftState(entryList[currIndex], state)
+		returnStates = append(returnStates, state)
+		validCount++
+		toIndex++
+	}
+
+	moreRoutes := false
+	if fromIndex+count < entryListLen {
+		moreRoutes = true
+	}
+	obj.StpPortErrDisableStateList = returnStates
+	obj.StartIdx = fromIndex
+	obj.EndIdx = toIndex + 1
+	obj.More = moreRoutes
+	obj.Count = validCount
+
+	return obj, nil
+}
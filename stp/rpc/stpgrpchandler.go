@@ -0,0 +1,295 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// stpgrpchandler.go
+package rpc
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/net/context"
+	"l2/grpcapi"
+	stp "l2/stp/protocol"
+	"stpd"
+	"stpdpb"
+)
+
+// STPDGrpcHandler implements the grpc service by converting the grpc messages
+// to/from the thrift objects and calling the thrift service handler
+type STPDGrpcHandler struct {
+	s *STPDServiceHandler
+}
+
+func NewSTPDGrpcHandler(s *STPDServiceHandler) *STPDGrpcHandler {
+	return &STPDGrpcHandler{s: s}
+}
+
+// stpConfigs holds the config objects applied by the thrift handlers, an
+// update only carries the attributes being updated and is applied on top
+// of the current object
+var stpConfigs = grpcapi.NewConfigCache()
+
+func stpBridgeInstanceKey(config *stpd.StpBridgeInstance) string {
+	return fmt.Sprintf("StpBridgeInstance-%d", config.Vlan)
+}
+
+func stpPortKey(config *stpd.StpPort) string {
+	return fmt.Sprintf("StpPort-%d-%d", config.IfIndex, config.BrgIfIndex)
+}
+
+func stpMstInstanceKey(config *stpd.StpMstInstance) string {
+	return fmt.Sprintf("StpMstInstance-%d-%d", config.Vlan, config.Msti)
+}
+
+func (g *STPDGrpcHandler) CreateStpBridgeInstance(ctx context.Context, in *stpdpb.StpBridgeInstance) (*stpdpb.Result, error) {
+	config := stpd.NewStpBridgeInstance()
+	if err := grpcapi.Convert(in, config); err != nil {
+		return nil, err
+	}
+	ok, err := g.s.CreateStpBridgeInstance(config)
+	return &stpdpb.Result{Ok: ok}, err
+}
+
+func (g *STPDGrpcHandler) UpdateStpBridgeInstance(ctx context.Context, in *stpdpb.StpBridgeInstanceUpdate) (*stpdpb.Result, error) {
+	config := stpd.NewStpBridgeInstance()
+	if err := grpcapi.Convert(in.Obj, config); err != nil {
+		return nil, err
+	}
+	attrset, err := grpcapi.AttrSet(config, in.Attrs)
+	if err != nil {
+		return nil, err
+	}
+	// the key attributes select the current object, only the requested
+	// attributes are taken from the update
+	obj, exists := stpConfigs.Get(stpBridgeInstanceKey(config))
+	if !exists {
+		return nil, errors.New(fmt.Sprintf("StpBridgeInstance vlan %d not found", config.Vlan))
+	}
+	origconfig := obj.(*stpd.StpBridgeInstance)
+	if err = grpcapi.Overlay(config, origconfig, attrset); err != nil {
+		return nil, err
+	}
+	ok, err := g.s.UpdateStpBridgeInstance(origconfig, config, attrset, nil)
+	return &stpdpb.Result{Ok: ok}, err
+}
+
+func (g *STPDGrpcHandler) DeleteStpBridgeInstance(ctx context.Context, in *stpdpb.StpBridgeInstance) (*stpdpb.Result, error) {
+	config := stpd.NewStpBridgeInstance()
+	if err := grpcapi.Convert(in, config); err != nil {
+		return nil, err
+	}
+	ok, err := g.s.DeleteStpBridgeInstance(config)
+	return &stpdpb.Result{Ok: ok}, err
+}
+
+func (g *STPDGrpcHandler) CreateStpPort(ctx context.Context, in *stpdpb.StpPort) (*stpdpb.Result, error) {
+	config := stpd.NewStpPort()
+	if err := grpcapi.Convert(in, config); err != nil {
+		return nil, err
+	}
+	ok, err := g.s.CreateStpPort(config)
+	return &stpdpb.Result{Ok: ok}, err
+}
+
+func (g *STPDGrpcHandler) UpdateStpPort(ctx context.Context, in *stpdpb.StpPortUpdate) (*stpdpb.Result, error) {
+	config := stpd.NewStpPort()
+	if err := grpcapi.Convert(in.Obj, config); err != nil {
+		return nil, err
+	}
+	attrset, err := grpcapi.AttrSet(config, in.Attrs)
+	if err != nil {
+		return nil, err
+	}
+	// the key attributes select the current object, only the requested
+	// attributes are taken from the update
+	obj, exists := stpConfigs.Get(stpPortKey(config))
+	if !exists {
+		return nil, errors.New(fmt.Sprintf("StpPort %d bridge %d not found", config.IfIndex, config.BrgIfIndex))
+	}
+	origconfig := obj.(*stpd.StpPort)
+	if err = grpcapi.Overlay(config, origconfig, attrset); err != nil {
+		return nil, err
+	}
+	ok, err := g.s.UpdateStpPort(origconfig, config, attrset, nil)
+	return &stpdpb.Result{Ok: ok}, err
+}
+
+func (g *STPDGrpcHandler) DeleteStpPort(ctx context.Context, in *stpdpb.StpPort) (*stpdpb.Result, error) {
+	config := stpd.NewStpPort()
+	if err := grpcapi.Convert(in, config); err != nil {
+		return nil, err
+	}
+	ok, err := g.s.DeleteStpPort(config)
+	return &stpdpb.Result{Ok: ok}, err
+}
+
+func (g *STPDGrpcHandler) CreateStpMstInstance(ctx context.Context, in *stpdpb.StpMstInstance) (*stpdpb.Result, error) {
+	config := stpd.NewStpMstInstance()
+	if err := grpcapi.Convert(in, config); err != nil {
+		return nil, err
+	}
+	ok, err := g.s.CreateStpMstInstance(config)
+	return &stpdpb.Result{Ok: ok}, err
+}
+
+func (g *STPDGrpcHandler) UpdateStpMstInstance(ctx context.Context, in *stpdpb.StpMstInstanceUpdate) (*stpdpb.Result, error) {
+	config := stpd.NewStpMstInstance()
+	if err := grpcapi.Convert(in.Obj, config); err != nil {
+		return nil, err
+	}
+	attrset, err := grpcapi.AttrSet(config, in.Attrs)
+	if err != nil {
+		return nil, err
+	}
+	// the key attributes select the current object, only the requested
+	// attributes are taken from the update
+	obj, exists := stpConfigs.Get(stpMstInstanceKey(config))
+	if !exists {
+		return nil, errors.New(fmt.Sprintf("StpMstInstance vlan %d msti %d not found", config.Vlan, config.Msti))
+	}
+	origconfig := obj.(*stpd.StpMstInstance)
+	if err = grpcapi.Overlay(config, origconfig, attrset); err != nil {
+		return nil, err
+	}
+	ok, err := g.s.UpdateStpMstInstance(origconfig, config, attrset, nil)
+	return &stpdpb.Result{Ok: ok}, err
+}
+
+func (g *STPDGrpcHandler) DeleteStpMstInstance(ctx context.Context, in *stpdpb.StpMstInstance) (*stpdpb.Result, error) {
+	config := stpd.NewStpMstInstance()
+	if err := grpcapi.Convert(in, config); err != nil {
+		return nil, err
+	}
+	ok, err := g.s.DeleteStpMstInstance(config)
+	return &stpdpb.Result{Ok: ok}, err
+}
+
+func (g *STPDGrpcHandler) GetStpBridgeState(ctx context.Context, in *stpdpb.StpBridgeState) (*stpdpb.StpBridgeState, error) {
+	obj, err := g.s.GetStpBridgeState(int16(in.Vlan))
+	if err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		return nil, errors.New(fmt.Sprintf("StpBridgeState vlan %d not found", in.Vlan))
+	}
+	out := &stpdpb.StpBridgeState{}
+	err = grpcapi.Convert(obj, out)
+	return out, err
+}
+
+func (g *STPDGrpcHandler) GetBulkStpBridgeState(ctx context.Context, in *stpdpb.BulkRequest) (*stpdpb.StpBridgeStateGetInfo, error) {
+	obj, err := g.s.GetBulkStpBridgeState(stpd.Int(in.FromIndex), stpd.Int(in.Count))
+	if err != nil {
+		return nil, err
+	}
+	out := &stpdpb.StpBridgeStateGetInfo{}
+	err = grpcapi.Convert(obj, out)
+	return out, err
+}
+
+func (g *STPDGrpcHandler) GetStpPortState(ctx context.Context, in *stpdpb.StpPortState) (*stpdpb.StpPortState, error) {
+	obj, err := g.s.GetStpPortState(in.IfIndex, in.BrgIfIndex)
+	if err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		return nil, errors.New(fmt.Sprintf("StpPortState port %d bridge %d not found", in.IfIndex, in.BrgIfIndex))
+	}
+	out := &stpdpb.StpPortState{}
+	err = grpcapi.Convert(obj, out)
+	return out, err
+}
+
+func (g *STPDGrpcHandler) GetBulkStpPortState(ctx context.Context, in *stpdpb.BulkRequest) (*stpdpb.StpPortStateGetInfo, error) {
+	obj, err := g.s.GetBulkStpPortState(stpd.Int(in.FromIndex), stpd.Int(in.Count))
+	if err != nil {
+		return nil, err
+	}
+	out := &stpdpb.StpPortStateGetInfo{}
+	err = grpcapi.Convert(obj, out)
+	return out, err
+}
+
+func (g *STPDGrpcHandler) GetStpPortErrDisableState(ctx context.Context, in *stpdpb.StpPortErrDisableState) (*stpdpb.StpPortErrDisableState, error) {
+	obj, err := g.s.GetStpPortErrDisableState(in.IfIndex)
+	if err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		return nil, errors.New(fmt.Sprintf("StpPortErrDisableState %d not found", in.IfIndex))
+	}
+	out := &stpdpb.StpPortErrDisableState{}
+	err = grpcapi.Convert(obj, out)
+	return out, err
+}
+
+func (g *STPDGrpcHandler) GetBulkStpPortErrDisableState(ctx context.Context, in *stpdpb.BulkRequest) (*stpdpb.StpPortErrDisableStateGetInfo, error) {
+	obj, err := g.s.GetBulkStpPortErrDisableState(stpd.Int(in.FromIndex), stpd.Int(in.Count))
+	if err != nil {
+		return nil, err
+	}
+	out := &stpdpb.StpPortErrDisableStateGetInfo{}
+	err = grpcapi.Convert(obj, out)
+	return out, err
+}
+
+func (g *STPDGrpcHandler) GetStpTcHistoryState(ctx context.Context, in *stpdpb.StpTcHistoryState) (*stpdpb.StpTcHistoryState, error) {
+	obj, err := g.s.GetStpTcHistoryState(int16(in.Vlan), in.Seq)
+	if err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		return nil, errors.New(fmt.Sprintf("StpTcHistoryState vlan %d seq %d not found", in.Vlan, in.Seq))
+	}
+	out := &stpdpb.StpTcHistoryState{}
+	err = grpcapi.Convert(obj, out)
+	return out, err
+}
+
+func (g *STPDGrpcHandler) GetBulkStpTcHistoryState(ctx context.Context, in *stpdpb.BulkRequest) (*stpdpb.StpTcHistoryStateGetInfo, error) {
+	obj, err := g.s.GetBulkStpTcHistoryState(stpd.Int(in.FromIndex), stpd.Int(in.Count))
+	if err != nil {
+		return nil, err
+	}
+	out := &stpdpb.StpTcHistoryStateGetInfo{}
+	err = grpcapi.Convert(obj, out)
+	return out, err
+}
+
+// StartGrpcServer starts the grpc server and json/http gateway of stpd
+// when the stpd-grpc entry is present in clients.json
+func StartGrpcServer(s *STPDServiceHandler, fileName string) error {
+	grpcPort, gwPort := grpcapi.ClientPortsGet(fileName, "stpd")
+	if grpcPort == 0 {
+		return nil
+	}
+	srv := grpcapi.NewServer("stpd", grpcPort, gwPort)
+	stpdpb.RegisterSTPDServicesServer(srv.GrpcServer(), NewSTPDGrpcHandler(s))
+	err := srv.Start(stpdpb.RegisterSTPDServicesHandlerFromEndpoint, func(err error) {
+		stp.StpLogger("ERROR", err.Error())
+	})
+	if err == nil {
+		stp.StpLogger("INFO", fmt.Sprintf("Started STP grpc server on %s gateway on %s", srv.GrpcAddr, srv.GatewayAddr))
+	}
+	return err
+}
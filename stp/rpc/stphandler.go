@@ -44,6 +44,8 @@ func NewSTPDServiceHandler() *STPDServiceHandler {
 	//lacp.LacpStartTime = time.Now()
 	// link up/down events for now
 	startEvtHandler()
+	// errdisable events
+	startNotificationPublisher()
 	return &STPDServiceHandler{}
 }
 
@@ -58,6 +60,32 @@ func ConvertThriftBrgConfigToStpBrgConfig(config *stpd.StpBridgeInstance, brgcon
 	brgconfig.ForwardDelay = uint16(config.ForwardDelay)
 	brgconfig.ForceVersion = int32(config.ForceVersion)
 	brgconfig.TxHoldCount = int32(config.TxHoldCount)
+	brgconfig.MstConfigName = config.MstConfigName
+	brgconfig.MstConfigRevision = uint16(config.MstConfigRevision)
+	brgconfig.MaxHops = uint8(config.MaxHops)
+	brgconfig.FdbFlushPolicy = config.FdbFlushPolicy
+	brgconfig.FdbFlushHoldDown = config.FdbFlushHoldDown
+}
+
+func ConvertThriftMstInstanceToStpMstiConfig(config *stpd.StpMstInstance, msticonfig *stp.StpMstiConfig) error {
+	var b *stp.Bridge
+	key := stp.BridgeKey{
+		Vlan: uint16(config.Vlan),
+	}
+	if key.Vlan == 0 {
+		key.Vlan = stp.DEFAULT_STP_BRIDGE_VLAN
+	}
+	if !stp.StpFindBridgeById(key, &b) {
+		return errors.New(fmt.Sprintf("STP: Error could not find bridge vlan %d for MSTI %d", config.Vlan, config.Msti))
+	}
+	msticonfig.BrgIfIndex = b.BrgIfIndex
+	msticonfig.Mstid = uint16(config.Msti)
+	msticonfig.Priority = uint16(config.Priority)
+	msticonfig.Vlans = make([]uint16, 0)
+	for _, vlan := range config.Vlans {
+		msticonfig.Vlans = append(msticonfig.Vlans, uint16(vlan))
+	}
+	return nil
 }
 
 func ConvertInt32ToBool(val int32) bool {
@@ -88,6 +116,9 @@ func ConvertThriftPortConfigToStpPortConfig(config *stpd.StpPort, portconfig *st
 	portconfig.BridgeAssurance = ConvertInt32ToBool(config.BridgeAssurance)
 	portconfig.BpduGuard = ConvertInt32ToBool(config.BpduGuard)
 	portconfig.BpduGuardInterval = config.BpduGuardInterval
+	portconfig.RootGuard = ConvertInt32ToBool(config.RootGuard)
+	portconfig.LoopGuard = ConvertInt32ToBool(config.LoopGuard)
+	portconfig.BpduFilter = config.BpduFilter
 }
 
 func ConvertBridgeIdToString(bridgeid stp.BridgeId) string {
@@ -174,6 +205,7 @@ func (s *STPDServiceHandler) CreateStpBridgeInstance(config *stpd.StpBridgeInsta
 	err := stp.StpBrgConfigParamCheck(brgconfig)
 	if err == nil {
 		stp.StpBridgeCreate(brgconfig)
+		stpConfigs.Set(stpBridgeInstanceKey(config), config)
 		return true, err
 	}
 	return false, err
@@ -221,6 +253,27 @@ func (s *STPDServiceHandler) HandleDbReadStpPort(dbHdl *dbutils.DBUtil) error {
 	return nil
 }
 
+func (s *STPDServiceHandler) HandleDbReadStpMstInstance(dbHdl *dbutils.DBUtil) error {
+	if dbHdl != nil {
+		var dbObj models.StpMstInstance
+		objList, err := dbHdl.GetAllObjFromDb(dbObj)
+		if err != nil {
+			stp.StpLogger("ERROR", "DB Query failed when retrieving StpMstInstance objects")
+			return err
+		}
+		for idx := 0; idx < len(objList); idx++ {
+			obj := stpd.NewStpMstInstance()
+			dbObject := objList[idx].(models.StpMstInstance)
+			models.ConvertstpdStpMstInstanceObjToThrift(&dbObject, obj)
+			_, err = s.CreateStpMstInstance(obj)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func (s *STPDServiceHandler) ReadConfigFromDB() error {
 
 	dbHdl := dbutils.NewDBUtil(nil)
@@ -241,6 +294,11 @@ func (s *STPDServiceHandler) ReadConfigFromDB() error {
 		return err
 	}
 
+	if err = s.HandleDbReadStpMstInstance(dbHdl); err != nil {
+		stp.StpLogger("ERROR", "Error getting All StpMstInstance objects")
+		return err
+	}
+
 	return nil
 }
 
@@ -253,6 +311,7 @@ func (s *STPDServiceHandler) DeleteStpBridgeInstance(config *stpd.StpBridgeInsta
 	ConvertThriftBrgConfigToStpBrgConfig(config, brgconfig)
 	err := stp.StpBridgeDelete(brgconfig)
 	if err == nil {
+		stpConfigs.Delete(stpBridgeInstanceKey(config))
 		return true, err
 	}
 	return false, err
@@ -310,8 +369,25 @@ func (s *STPDServiceHandler) UpdateStpBridgeInstance(origconfig *stpd.StpBridgeI
 			if objName == "ForceVersion" {
 				stp.StpBrgForceVersion(brgIfIndex, updateconfig.ForceVersion)
 			}
+			// MSTP region, causes re-selection
+			if objName == "MstConfigName" {
+				stp.StpBrgMstConfigNameSet(brgIfIndex, updateconfig.MstConfigName)
+			}
+			if objName == "MstConfigRevision" {
+				stp.StpBrgMstConfigRevisionSet(brgIfIndex, uint16(updateconfig.MstConfigRevision))
+			}
+			if objName == "MaxHops" {
+				stp.StpBrgMaxHopsSet(brgIfIndex, uint8(updateconfig.MaxHops))
+			}
+			if objName == "FdbFlushPolicy" {
+				stp.StpBrgFdbFlushPolicySet(brgIfIndex, updateconfig.FdbFlushPolicy)
+			}
+			if objName == "FdbFlushHoldDown" {
+				stp.StpBrgFdbFlushHoldDownSet(brgIfIndex, updateconfig.FdbFlushHoldDown)
+			}
 		}
 	}
+	stpConfigs.Set(stpBridgeInstanceKey(updateconfig), updateconfig)
 	return true, nil
 }
 
@@ -323,6 +399,7 @@ func (s *STPDServiceHandler) CreateStpPort(config *stpd.StpPort) (bool, error) {
 	if err == nil {
 		err = stp.StpPortCreate(portconfig)
 		if err == nil {
+			stpConfigs.Set(stpPortKey(config), config)
 			return true, err
 		}
 	}
@@ -336,6 +413,7 @@ func (s *STPDServiceHandler) DeleteStpPort(config *stpd.StpPort) (bool, error) {
 
 	err := stp.StpPortDelete(portconfig)
 	if err == nil {
+		stpConfigs.Delete(stpPortKey(config))
 		return true, err
 	}
 	return false, err
@@ -405,6 +483,15 @@ func (s *STPDServiceHandler) UpdateStpPort(origconfig *stpd.StpPort, updateconfi
 				err = stp.StpPortBridgeAssuranceSet(ifIndex, brgIfIndex, ConvertInt32ToBool(updateconfig.BridgeAssurance))
 
 			}
+			if objName == "BpduFilter" {
+				err = stp.StpPortBpduFilterSet(ifIndex, brgIfIndex, updateconfig.BpduFilter)
+			}
+			if objName == "RootGuard" {
+				err = stp.StpPortRootGuardSet(ifIndex, brgIfIndex, ConvertInt32ToBool(updateconfig.RootGuard))
+			}
+			if objName == "LoopGuard" {
+				err = stp.StpPortLoopGuardSet(ifIndex, brgIfIndex, ConvertInt32ToBool(updateconfig.LoopGuard))
+			}
 
 			if err != nil {
 				return false, err
@@ -412,6 +499,67 @@ func (s *STPDServiceHandler) UpdateStpPort(origconfig *stpd.StpPort, updateconfi
 		}
 	}
 
+	stpConfigs.Set(stpPortKey(updateconfig), updateconfig)
+	return true, nil
+}
+
+func (s *STPDServiceHandler) CreateStpMstInstance(config *stpd.StpMstInstance) (bool, error) {
+	stp.StpLogger("INFO", fmt.Sprintf("CreateStpMstInstance (server): created %#v", config))
+	msticonfig := &stp.StpMstiConfig{}
+	err := ConvertThriftMstInstanceToStpMstiConfig(config, msticonfig)
+	if err == nil {
+		err = stp.StpMstiConfigParamCheck(msticonfig)
+		if err == nil {
+			err = stp.StpMstiCreate(msticonfig)
+			if err == nil {
+				stpConfigs.Set(stpMstInstanceKey(config), config)
+				return true, err
+			}
+		}
+	}
+	return false, err
+}
+
+func (s *STPDServiceHandler) DeleteStpMstInstance(config *stpd.StpMstInstance) (bool, error) {
+	stp.StpLogger("INFO", "DeleteStpMstInstance (server): deleted ")
+	msticonfig := &stp.StpMstiConfig{}
+	err := ConvertThriftMstInstanceToStpMstiConfig(config, msticonfig)
+	if err == nil {
+		err = stp.StpMstiDelete(msticonfig)
+		if err == nil {
+			stpConfigs.Delete(stpMstInstanceKey(config))
+			return true, err
+		}
+	}
+	return false, err
+}
+
+func (s *STPDServiceHandler) UpdateStpMstInstance(origconfig *stpd.StpMstInstance, updateconfig *stpd.StpMstInstance, attrset []bool, op []*stpd.PatchOpInfo) (bool, error) {
+	msticonfig := &stp.StpMstiConfig{}
+	objTyp := reflect.TypeOf(*origconfig)
+
+	err := ConvertThriftMstInstanceToStpMstiConfig(updateconfig, msticonfig)
+	if err != nil {
+		return false, err
+	}
+
+	for i := 0; i < objTyp.NumField(); i++ {
+		objName := objTyp.Field(i).Name
+		if attrset[i] {
+			stp.StpLogger("INFO", fmt.Sprintf("UpdateStpMstInstance (server): changed %s", objName))
+
+			if objName == "Priority" {
+				err = stp.StpMstiPrioritySet(msticonfig.BrgIfIndex, msticonfig.Mstid, msticonfig.Priority)
+			}
+			if objName == "Vlans" {
+				err = stp.StpMstiVlansSet(msticonfig.BrgIfIndex, msticonfig.Mstid, msticonfig.Vlans)
+			}
+			if err != nil {
+				return false, err
+			}
+		}
+	}
+	stpConfigs.Set(stpMstInstanceKey(updateconfig), updateconfig)
 	return true, nil
 }
 
@@ -431,8 +579,7 @@ func (s *STPDServiceHandler) GetStpBridgeState(vlan int16) (*stpd.StpBridgeState
 		sbs.Priority = int32(stp.GetBridgePriorityFromBridgeId(b.BridgePriority.DesignatedBridgeId))
 		sbs.IfIndex = b.BrgIfIndex
 		sbs.ProtocolSpecification = 2
-		//nextStpBridgeState.TimeSinceTopologyChange uint32 //The time (in hundredths of a second) since the last time a topology change was detected by the bridge entity. For RSTP, this reports the time since the tcWhile timer for any port on this Bridge was nonzero.
-		//nextStpBridgeState.TopChanges              uint32 //The total number of topology changes detected by this bridge since the management entity was last reset or initialized.
+		ConvertTcStatsToThriftBridgeState(b, sbs)
 		sbs.DesignatedRoot = ConvertBridgeIdToString(b.BridgePriority.RootBridgeId)
 		sbs.RootCost = int32(b.BridgePriority.RootPathCost)
 		sbs.RootPort = int32(b.BridgePriority.DesignatedPortId)
@@ -441,6 +588,12 @@ func (s *STPDServiceHandler) GetStpBridgeState(vlan int16) (*stpd.StpBridgeState
 		sbs.HoldTime = int32(b.TxHoldCount)
 		sbs.ForwardDelay = int32(b.RootTimes.ForwardingDelay)
 		sbs.Vlan = int16(b.Vlan)
+		sbs.MstConfigName = stp.MstConfigNameToString(b.MstConfigId.Name)
+		sbs.MstConfigRevision = int32(b.MstConfigId.Revision)
+		sbs.MstConfigDigest = fmt.Sprintf("%x", b.MstConfigId.Digest)
+		sbs.MaxHops = int32(b.MaxHops)
+		sbs.FdbFlushPolicy = int32(b.FdbFlushPolicy)
+		sbs.FdbFlushHoldDown = b.FdbFlushHoldDown
 	} else {
 		return sbs, errors.New(fmt.Sprintf("STP: Error could not find bridge vlan %d", vlan))
 	}
@@ -476,8 +629,7 @@ func (s *STPDServiceHandler) GetBulkStpBridgeState(fromIndex stpd.Int, count stp
 		nextStpBridgeState.Priority = int32(stp.GetBridgePriorityFromBridgeId(b.BridgePriority.DesignatedBridgeId))
 		nextStpBridgeState.IfIndex = b.BrgIfIndex
 		nextStpBridgeState.ProtocolSpecification = 2
-		//nextStpBridgeState.TimeSinceTopologyChange uint32 //The time (in hundredths of a second) since the last time a topology change was detected by the bridge entity. For RSTP, this reports the time since the tcWhile timer for any port on this Bridge was nonzero.
-		//nextStpBridgeState.TopChanges              uint32 //The total number of topology changes detected by this bridge since the management entity was last reset or initialized.
+		ConvertTcStatsToThriftBridgeState(b, nextStpBridgeState)
 		nextStpBridgeState.DesignatedRoot = ConvertBridgeIdToString(b.BridgePriority.RootBridgeId)
 		nextStpBridgeState.RootCost = int32(b.BridgePriority.RootPathCost)
 		nextStpBridgeState.RootPort = int32(b.BridgePriority.DesignatedPortId)
@@ -486,6 +638,12 @@ func (s *STPDServiceHandler) GetBulkStpBridgeState(fromIndex stpd.Int, count stp
 		nextStpBridgeState.HoldTime = int32(b.TxHoldCount)
 		nextStpBridgeState.ForwardDelay = int32(b.RootTimes.ForwardingDelay)
 		nextStpBridgeState.Vlan = int16(b.Vlan)
+		nextStpBridgeState.MstConfigName = stp.MstConfigNameToString(b.MstConfigId.Name)
+		nextStpBridgeState.MstConfigRevision = int32(b.MstConfigId.Revision)
+		nextStpBridgeState.MstConfigDigest = fmt.Sprintf("%x", b.MstConfigId.Digest)
+		nextStpBridgeState.MaxHops = int32(b.MaxHops)
+		nextStpBridgeState.FdbFlushPolicy = int32(b.FdbFlushPolicy)
+		nextStpBridgeState.FdbFlushHoldDown = b.FdbFlushHoldDown
 
 		if len(returnStpBridgeStates) == 0 {
 			returnStpBridgeStates = make([]*stpd.StpBridgeState, 0)
@@ -539,7 +697,15 @@ func (s *STPDServiceHandler) GetStpPortState(ifIndex int32, brgIfIndex int32) (*
 		sps.BridgeAssurance = ConvertBoolToInt32(p.BridgeAssurance)
 		// Bpdu Guard
 		sps.BpduGuard = ConvertBoolToInt32(p.BpduGuard)
-		sps.BpduGuardDetected = ConvertBoolToInt32(p.BPDUGuardTimer.GetCount() != 0)
+		sps.BpduGuardDetected = ConvertBoolToInt32(p.BpduGuardDetected())
+		// Bpdu Filter
+		sps.BpduFilter = int32(p.BpduFilter)
+		// Root Guard
+		sps.RootGuard = ConvertBoolToInt32(p.RootGuard)
+		sps.RootGuardInconsistant = ConvertBoolToInt32(p.RootGuardInconsistant)
+		// Loop Guard
+		sps.LoopGuard = ConvertBoolToInt32(p.LoopGuard)
+		sps.LoopGuardInconsistant = ConvertBoolToInt32(p.LoopGuardInconsistant)
 		// root timers
 		sps.MaxAge = int32(p.PortTimes.MaxAge)
 		sps.ForwardDelay = int32(p.PortTimes.ForwardingDelay)
@@ -555,8 +721,15 @@ func (s *STPDServiceHandler) GetStpPortState(ifIndex int32, brgIfIndex int32) (*
 		sps.TcAckOutPkts = int64(p.TcAckTx)
 		sps.PvstInPkts = int64(p.PvstRx)
 		sps.PvstOutPkts = int64(p.PvstTx)
+		sps.MstpInPkts = int64(p.MstpRx)
+		sps.MstpOutPkts = int64(p.MstpTx)
 		sps.BpduInPkts = int64(p.BpduRx)
 		sps.BpduOutPkts = int64(p.BpduTx)
+		sps.BpduFilterInPkts = int64(p.BpduFilterRx)
+		sps.BpduFilterOutPkts = int64(p.BpduFilterTx)
+		fdbFlushes, fdbFlushesCoalesced := p.FdbFlushCounters()
+		sps.FdbFlushes = int64(fdbFlushes)
+		sps.FdbFlushesHeldDown = int64(fdbFlushesCoalesced)
 		// fsm-states
 		sps.PimPrevState = p.PimMachineFsm.GetPrevStateStr()
 		sps.PimCurrState = p.PimMachineFsm.GetCurrStateStr()
@@ -637,7 +810,15 @@ func (s *STPDServiceHandler) GetBulkStpPortState(fromIndex stpd.Int, count stpd.
 		nextStpPortState.BridgeAssurance = ConvertBoolToInt32(p.BridgeAssurance)
 		// Bpdu Guard
 		nextStpPortState.BpduGuard = ConvertBoolToInt32(p.BpduGuard)
-		nextStpPortState.BpduGuardDetected = ConvertBoolToInt32(p.BPDUGuardTimer.GetCount() != 0)
+		nextStpPortState.BpduGuardDetected = ConvertBoolToInt32(p.BpduGuardDetected())
+		// Bpdu Filter
+		nextStpPortState.BpduFilter = int32(p.BpduFilter)
+		// Root Guard
+		nextStpPortState.RootGuard = ConvertBoolToInt32(p.RootGuard)
+		nextStpPortState.RootGuardInconsistant = ConvertBoolToInt32(p.RootGuardInconsistant)
+		// Loop Guard
+		nextStpPortState.LoopGuard = ConvertBoolToInt32(p.LoopGuard)
+		nextStpPortState.LoopGuardInconsistant = ConvertBoolToInt32(p.LoopGuardInconsistant)
 		// root timers
 		nextStpPortState.MaxAge = int32(p.PortTimes.MaxAge)
 		nextStpPortState.ForwardDelay = int32(p.PortTimes.ForwardingDelay)
@@ -653,8 +834,15 @@ func (s *STPDServiceHandler) GetBulkStpPortState(fromIndex stpd.Int, count stpd.
 		nextStpPortState.TcAckOutPkts = int64(p.TcAckTx)
 		nextStpPortState.PvstInPkts = int64(p.PvstRx)
 		nextStpPortState.PvstOutPkts = int64(p.PvstTx)
+		nextStpPortState.MstpInPkts = int64(p.MstpRx)
+		nextStpPortState.MstpOutPkts = int64(p.MstpTx)
 		nextStpPortState.BpduInPkts = int64(p.BpduRx)
 		nextStpPortState.BpduOutPkts = int64(p.BpduTx)
+		nextStpPortState.BpduFilterInPkts = int64(p.BpduFilterRx)
+		nextStpPortState.BpduFilterOutPkts = int64(p.BpduFilterTx)
+		fdbFlushes, fdbFlushesCoalesced := p.FdbFlushCounters()
+		nextStpPortState.FdbFlushes = int64(fdbFlushes)
+		nextStpPortState.FdbFlushesHeldDown = int64(fdbFlushesCoalesced)
 		// fsm-states
 		nextStpPortState.PimPrevState = p.PimMachineFsm.GetPrevStateStr()
 		nextStpPortState.PimCurrState = p.PimMachineFsm.GetCurrStateStr()
@@ -0,0 +1,132 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// stpnotifyhandler.go
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/op/go-nanomsg"
+	"l2/errdisable"
+	stp "l2/stp/protocol"
+)
+
+// address other daemons subscribe to for stpd notifications
+const STPD_PUB_SOCKET_ADDR = "ipc:///tmp/stpd_all.ipc"
+
+// notification types
+const (
+	StpNotifyErrDisabled = iota + 1
+	StpNotifyErrDisableRecovered
+)
+
+// StpdNotification is published for each notification, Msg is the json
+// encoded message of the MsgType
+type StpdNotification struct {
+	MsgType uint8
+	Msg     []byte
+}
+
+// StpErrDisableNotifyMsg is sent when a port is errdisabled or recovered
+type StpErrDisableNotifyMsg struct {
+	IfIndex      int32
+	Cause        string
+	Count        uint32
+	TimeStamp    string
+	RecoveryTime string
+}
+
+var StpdPub *nanomsg.PubSocket
+
+// notifications are queued so that the State machines never block
+// on the publisher socket
+var stpdNotifyChan chan StpdNotification
+
+func processErrDisableNotification(evt errdisable.Event) {
+	msgType := uint8(StpNotifyErrDisabled)
+	if evt.Type == errdisable.EventRecovered {
+		msgType = StpNotifyErrDisableRecovered
+	}
+	msg := StpErrDisableNotifyMsg{
+		IfIndex:   evt.IfIndex,
+		Cause:     evt.Cause.String(),
+		Count:     evt.Count,
+		TimeStamp: evt.Time.String(),
+	}
+	if !evt.Recovery.IsZero() {
+		msg.RecoveryTime = evt.Recovery.String()
+	}
+	msgBuf, err := json.Marshal(msg)
+	if err != nil {
+		fmt.Println("Error in marshalling notification msg", err)
+		return
+	}
+	select {
+	case stpdNotifyChan <- StpdNotification{MsgType: msgType, Msg: msgBuf}:
+	default:
+		fmt.Println("Notification queue full, dropping", errdisable.EventStrMap[evt.Type])
+	}
+}
+
+func publishNotifications(pub *nanomsg.PubSocket) {
+	for notification := range stpdNotifyChan {
+		buf, err := json.Marshal(notification)
+		if err != nil {
+			fmt.Println("Error in marshalling notification", err)
+			continue
+		}
+		_, err = pub.Send(buf, nanomsg.DontWait)
+		if err != nil {
+			fmt.Println("Error in publishing notification", err)
+		}
+	}
+}
+
+func setupNotificationPublisher(address string) {
+	fmt.Println("Setting up notification publisher")
+	pub, err := nanomsg.NewPubSocket()
+	if err != nil {
+		fmt.Println("Failed to open pub socket")
+		return
+	}
+	ep, err := pub.Bind(address)
+	if err != nil {
+		fmt.Println("Failed to bind pub socket - ", ep)
+		return
+	}
+	fmt.Println("Bound to ", ep.Address)
+	err = pub.SetSendBuffer(1024 * 1024)
+	if err != nil {
+		fmt.Println("Failed to set send buffer size")
+		return
+	}
+	StpdPub = pub
+	stpdNotifyChan = make(chan StpdNotification, 100)
+	stp.StpErrDisableGet().RegisterCallback("stpd", processErrDisableNotification)
+	go publishNotifications(pub)
+}
+
+func startNotificationPublisher() {
+	setupNotificationPublisher(STPD_PUB_SOCKET_ADDR)
+}
@@ -0,0 +1,126 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __  
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  | 
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  | 
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   | 
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  | 
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__| 
+//                                                                                                           
+
+// stptchandler.go
+package rpc
+
+import (
+	"errors"
+	"fmt"
+	stp "l2/stp/protocol"
+	"stpd"
+	"time"
+)
+
+// ConvertTcStatsToThriftBridgeState fills in the topology change fields of the
+// bridge state
+func ConvertTcStatsToThriftBridgeState(b *stp.Bridge, sbs *stpd.StpBridgeState) {
+	stats := b.TcStatsGet()
+	sbs.TopChanges = uint32(stats.Count)
+	if stats.Count != 0 {
+		// hundredths of a second
+		sbs.TimeSinceTopologyChange = uint32(stp.StpClockGet().Now().Sub(stats.LastTime) / (time.Millisecond * 10))
+		sbs.LastTopologyChangeTime = stats.LastTime.String()
+	}
+	if stats.LastRcvdIfIndex != 0 {
+		sbs.LastTcRcvdIfIndex = stats.LastRcvdIfIndex
+		sbs.LastTcSenderBridgeId = ConvertBridgeIdToString(stats.LastRcvdBridgeId)
+		sbs.LastTcSenderAddress = ConvertAddrToString(stats.LastRcvdMac)
+	}
+}
+
+func ConvertTcEventToThriftTcHistoryState(b *stp.Bridge, evt stp.TcEvent, state *stpd.StpTcHistoryState) {
+	state.Vlan = int16(b.Vlan)
+	state.Seq = int64(evt.Seq)
+	state.Type = stp.TcEventTypeStrMap[evt.Type]
+	state.Time = evt.Time.String()
+	state.IfIndex = evt.IfIndex
+	if evt.Type != stp.TcEventTypeDetected {
+		state.SenderBridgeId = ConvertBridgeIdToString(evt.SenderBridgeId)
+		state.SenderAddress = ConvertAddrToString(evt.SenderMac)
+	}
+}
+
+// GetStpTcHistoryState will return a single topology change event of a bridge
+func (s *STPDServiceHandler) GetStpTcHistoryState(vlan int16, seq int64) (*stpd.StpTcHistoryState, error) {
+	state := &stpd.StpTcHistoryState{}
+
+	key := stp.BridgeKey{
+		Vlan: uint16(vlan),
+	}
+	var b *stp.Bridge
+	if !stp.StpFindBridgeById(key, &b) {
+		return state, errors.New(fmt.Sprintf("STP: Error could not find bridge vlan %d", vlan))
+	}
+	for _, evt := range b.TcHistoryGet() {
+		if int64(evt.Seq) == seq {
+			ConvertTcEventToThriftTcHistoryState(b, evt, state)
+			return state, nil
+		}
+	}
+	return state, errors.New(fmt.Sprintf("STP: Error bridge vlan %d topology change %d not in history", vlan, seq))
+}
+
+// GetBulkStpTcHistoryState will return the recent topology change events of
+// all bridges, oldest first per bridge
+func (s *STPDServiceHandler) GetBulkStpTcHistoryState(fromIndex stpd.Int, count stpd.Int) (obj *stpd.StpTcHistoryStateGetInfo, err error) {
+
+	var returnStates []*stpd.StpTcHistoryState
+	var returnStateGetInfo stpd.StpTcHistoryStateGetInfo
+	validCount := stpd.Int(0)
+	toIndex := fromIndex
+	obj = &returnStateGetInfo
+
+	// flatten the history of all bridges
+	type brgTcEvent struct {
+		b   *stp.Bridge
+		evt stp.TcEvent
+	}
+	var evtList []brgTcEvent
+	for _, b := range stp.BridgeListTable {
+		for _, evt := range b.TcHistoryGet() {
+			evtList = append(evtList, brgTcEvent{b: b, evt: evt})
+		}
+	}
+
+	evtListLen := stpd.Int(len(evtList))
+	for currIndex := fromIndex; validCount != count && currIndex < evtListLen; currIndex++ {
+		state := &stpd.StpTcHistoryState{}
+		ConvertTcEventToThriftTcHistoryState(evtList[currIndex].b, evtList[currIndex].evt, state)
+		returnStates = append(returnStates, state)
+		validCount++
+		toIndex++
+	}
+
+	moreRoutes := false
+	if fromIndex+count < evtListLen {
+		moreRoutes = true
+	}
+	obj.StpTcHistoryStateList = returnStates
+	obj.StartIdx = fromIndex
+	obj.EndIdx = toIndex + 1
+	obj.More = moreRoutes
+	obj.Count = validCount
+
+	return obj, nil
+}